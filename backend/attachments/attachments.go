@@ -0,0 +1,32 @@
+// Package attachments holds what workout attachments need beyond the
+// blob store they live in: thumbnails of uploaded photos, the signed
+// expiring URLs they are downloaded through, and the job sweeping away
+// the blobs of deleted attachments.
+package attachments
+
+import "github.com/google/uuid"
+
+// Defaults for Limits.
+const (
+	DefaultMaxSize = 100 << 20
+	DefaultQuota   = 1 << 30
+)
+
+// Limits bound what users may upload.
+type Limits struct {
+	// MaxSize is the largest file accepted, in bytes.
+	MaxSize int64
+	// Quota is how many bytes of attachments, thumbnails included, each
+	// user may store.
+	Quota int64
+}
+
+// StorageKey returns the blob key of attachment id's file.
+func StorageKey(id uuid.UUID) string {
+	return "attachments/" + id.String()
+}
+
+// ThumbnailKey returns the blob key of attachment id's thumbnail.
+func ThumbnailKey(id uuid.UUID) string {
+	return "attachments/" + id.String() + "-thumbnail"
+}
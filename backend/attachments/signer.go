@@ -0,0 +1,63 @@
+package attachments
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"workout-tracker/backend/signing"
+
+	"github.com/google/uuid"
+)
+
+// Variants of an attachment that can be downloaded.
+const (
+	VariantOriginal  = "original"
+	VariantThumbnail = "thumbnail"
+)
+
+// DefaultURLTTL is how long download URLs stay valid unless configured
+// otherwise.
+const DefaultURLTTL = 15 * time.Minute
+
+// Errors returned by Signer.Verify.
+var (
+	ErrInvalidSignature = errors.New("invalid download signature")
+	ErrExpired          = errors.New("download link expired")
+)
+
+// Signer issues and checks the signatures of download URLs. A URL grants
+// access to one variant of one attachment until it expires, without a
+// login, so that it works in <img> tags and can be handed to a player.
+type Signer struct {
+	key *signing.Key
+}
+
+// NewSigner returns a Signer keyed with secret. An empty secret gets a
+// random key, which is fine for development but invalidates every URL
+// handed out before a restart.
+func NewSigner(secret string) *Signer {
+	return &Signer{key: signing.NewKey(secret)}
+}
+
+// Sign returns the signature granting access to variant of attachment id
+// until expires.
+func (s *Signer) Sign(id uuid.UUID, variant string, expires time.Time) string {
+	return s.key.Sign(payload(id, variant, expires.Unix()))
+}
+
+// Verify checks that signature grants access to variant of attachment id
+// until expires, a Unix time, and that now is before then.
+func (s *Signer) Verify(id uuid.UUID, variant string, expires int64, signature string, now time.Time) error {
+	if !s.key.Valid(payload(id, variant, expires), signature) {
+		return ErrInvalidSignature
+	}
+	if now.Unix() >= expires {
+		return ErrExpired
+	}
+	return nil
+}
+
+func payload(id uuid.UUID, variant string, expires int64) string {
+	return id.String() + ":" + variant + ":" + strconv.FormatInt(expires, 10)
+}
@@ -0,0 +1,76 @@
+package attachments
+
+import (
+	"context"
+	"log/slog"
+
+	"workout-tracker/backend/blob"
+	"workout-tracker/backend/jobs"
+	"workout-tracker/backend/models"
+
+	"gorm.io/gorm"
+)
+
+// KindSweep is the job that deletes the blobs of deleted attachments.
+const KindSweep = "attachments.sweep"
+
+// sweepBatch bounds how many attachments one pass of the sweep loads.
+const sweepBatch = 500
+
+// Sweeper removes deleted attachments for good: first their blobs, then
+// their rows. Attachments are deleted one by one through the API, and
+// soft-deleted in bulk when their workout is purged.
+type Sweeper struct {
+	db    *gorm.DB
+	store blob.Store
+}
+
+func NewSweeper(db *gorm.DB, store blob.Store) *Sweeper {
+	return &Sweeper{db: db, store: store}
+}
+
+// Register installs the sweep job on r and schedules it hourly on s.
+func (s *Sweeper) Register(r *jobs.Runner, sch *jobs.Scheduler) error {
+	r.Handle(KindSweep, func(ctx context.Context, _ models.Job) error {
+		_, err := s.Sweep(ctx)
+		return err
+	})
+	return sch.Every("attachments-sweep", "45 * * * *", KindSweep, nil)
+}
+
+// Sweep deletes the blobs and rows of every soft-deleted attachment,
+// returning how many went. A row is only removed once its blobs are, so
+// a failed pass is finished by the next one.
+func (s *Sweeper) Sweep(ctx context.Context) (int, error) {
+	db := s.db.WithContext(ctx)
+	n := 0
+	defer func() {
+		if n > 0 {
+			slog.InfoContext(ctx, "attachments: swept deleted attachments", "rows", n)
+		}
+	}()
+	for {
+		var list []models.Attachment
+		err := db.Unscoped().Where("deleted_at IS NOT NULL").Order("id").Limit(sweepBatch).Find(&list).Error
+		if err != nil {
+			return n, err
+		}
+		for _, a := range list {
+			for _, key := range []string{a.StorageKey, a.ThumbnailKey} {
+				if key == "" {
+					continue
+				}
+				if err := s.store.Delete(ctx, key); err != nil {
+					return n, err
+				}
+			}
+			if err := db.Unscoped().Delete(&models.Attachment{}, a.ID).Error; err != nil {
+				return n, err
+			}
+			n++
+		}
+		if len(list) < sweepBatch {
+			return n, nil
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package attachments
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	_ "image/gif" // register the GIF decoder
+	"image/jpeg"
+	_ "image/png" // register the PNG decoder
+	"io"
+)
+
+// ThumbnailSize bounds the width and height of thumbnails, in pixels.
+const ThumbnailSize = 320
+
+// maxPixels bounds the images thumbnails are made of, so a small file
+// declaring huge dimensions cannot exhaust memory when decoded.
+const maxPixels = 50_000_000
+
+// ErrTooLarge is returned for images with more than maxPixels pixels.
+var ErrTooLarge = errors.New("image dimensions too large")
+
+// Thumbnail decodes the JPEG, PNG or GIF image r holds and returns a JPEG
+// of it scaled to fit ThumbnailSize, along with the original's width and
+// height. Images already that small are re-encoded at their size.
+func Thumbnail(r io.ReadSeeker) ([]byte, int, int, error) {
+	cfg, _, err := image.DecodeConfig(r)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	if cfg.Width*cfg.Height > maxPixels {
+		return nil, 0, 0, ErrTooLarge
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, 0, 0, err
+	}
+	src, _, err := image.Decode(r)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	var b bytes.Buffer
+	if err := jpeg.Encode(&b, scale(src, ThumbnailSize), &jpeg.Options{Quality: 80}); err != nil {
+		return nil, 0, 0, err
+	}
+	return b.Bytes(), cfg.Width, cfg.Height, nil
+}
+
+// scale shrinks src to fit a size×size box, keeping its aspect ratio. Each
+// destination pixel averages the source pixels it covers, which is all a
+// downscale needs to avoid aliasing.
+func scale(src image.Image, size int) image.Image {
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	dw, dh := w, h
+	if w > size || h > size {
+		if w >= h {
+			dw, dh = size, max(1, h*size/w)
+		} else {
+			dw, dh = max(1, w*size/h), size
+		}
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := range dh {
+		y0, y1 := b.Min.Y+y*h/dh, b.Min.Y+max((y+1)*h/dh, y*h/dh+1)
+		for x := range dw {
+			x0, x1 := b.Min.X+x*w/dw, b.Min.X+max((x+1)*w/dw, x*w/dw+1)
+			var r, g, bl, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					pr, pg, pb, pa := src.At(sx, sy).RGBA()
+					r, g, bl, a = r+uint64(pr), g+uint64(pg), bl+uint64(pb), a+uint64(pa)
+					n++
+				}
+			}
+			i := dst.PixOffset(x, y)
+			dst.Pix[i] = uint8(r / n >> 8)
+			dst.Pix[i+1] = uint8(g / n >> 8)
+			dst.Pix[i+2] = uint8(bl / n >> 8)
+			dst.Pix[i+3] = uint8(a / n >> 8)
+		}
+	}
+	return dst
+}
@@ -0,0 +1,123 @@
+// Package audit keeps a trail of every change made to users and workouts:
+// who made it, what changed and where the request came from.
+//
+// Middleware attaches the caller to each API request's context and GORM
+// callbacks (see RegisterCallbacks) write the trail in the same transaction
+// as the change, so handlers need only pass their request context to the
+// database. Writes made without one, such as background jobs, are recorded
+// with no actor.
+package audit
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net"
+
+	"workout-tracker/backend/middleware"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/gin-gonic/gin"
+)
+
+// RequestIDHeader carries the request ID. A client-supplied value is kept so
+// a request can be traced across services; otherwise one is generated. It
+// is echoed on every response.
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLen bounds client-supplied request IDs.
+const maxRequestIDLen = 128
+
+// Actor is who made a request and where it came from.
+type Actor struct {
+	// UserID is the local user, once a handler has resolved it; 0 until
+	// then.
+	UserID int64
+	// Subject, TokenType and ClientID describe the Zitadel token; empty
+	// when auth is disabled.
+	Subject   string
+	TokenType string
+	ClientID  string
+	IP        string
+	UserAgent string
+	RequestID string
+}
+
+type actorKey struct{}
+
+// WithActor returns a copy of ctx carrying a.
+func WithActor(ctx context.Context, a *Actor) context.Context {
+	return context.WithValue(ctx, actorKey{}, a)
+}
+
+// FromContext returns the actor attached to ctx, or nil.
+func FromContext(ctx context.Context) *Actor {
+	a, _ := ctx.Value(actorKey{}).(*Actor)
+	return a
+}
+
+// SetUserID records the local user behind the request in ctx, if ctx
+// carries an actor.
+func SetUserID(ctx context.Context, userID int64) {
+	if a := FromContext(ctx); a != nil {
+		a.UserID = userID
+	}
+}
+
+// Middleware attaches an Actor for the caller to every request. Register
+// it after authentication so the token is known, and before the routes;
+// the caller's address comes from ClientIP when that runs in front of the
+// API.
+func Middleware(ctx huma.Context, next func(huma.Context)) {
+	reqID := ctx.Header(RequestIDHeader)
+	if reqID == "" || len(reqID) > maxRequestIDLen {
+		reqID = newRequestID()
+	}
+	ctx.SetHeader(RequestIDHeader, reqID)
+
+	a := &Actor{
+		IP:        clientIP(ctx),
+		UserAgent: ctx.Header("User-Agent"),
+		RequestID: reqID,
+	}
+	if auth := middleware.GetAuth(ctx.Context()); auth != nil {
+		a.Subject = auth.UserID()
+		a.TokenType = auth.TokenType
+		a.ClientID = auth.ClientID
+		if a.TokenType == "" {
+			a.TokenType = "Bearer"
+		}
+	}
+	next(huma.WithContext(ctx, WithActor(ctx.Context(), a)))
+}
+
+type clientIPKey struct{}
+
+// ClientIP is Gin middleware that records the caller's address for
+// Middleware. It is c.ClientIP, which only believes X-Forwarded-For and
+// X-Real-IP when they come from a proxy trusted with
+// gin.Engine.SetTrustedProxies.
+func ClientIP(c *gin.Context) {
+	c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), clientIPKey{}, c.ClientIP()))
+	c.Next()
+}
+
+// clientIP returns the address ClientIP recorded, or the connection's peer
+// when it has not run. Forwarded headers are never read here: anyone can
+// send them.
+func clientIP(ctx huma.Context) string {
+	if ip, ok := ctx.Context().Value(clientIPKey{}).(string); ok {
+		return ip
+	}
+	host, _, err := net.SplitHostPort(ctx.RemoteAddr())
+	if err != nil {
+		return ctx.RemoteAddr()
+	}
+	return host
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
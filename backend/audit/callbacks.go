@@ -0,0 +1,220 @@
+package audit
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"time"
+
+	"workout-tracker/backend/events"
+	"workout-tracker/backend/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// beforeKey holds the rows an update is about to change, as they were.
+const beforeKey = "audit:before"
+
+// resourceTypes maps the audited tables to their resource type.
+var resourceTypes = map[string]string{
+	"workouts": events.ResourceWorkout,
+	"users":    events.ResourceUser,
+}
+
+// Change is one field's value before and after a write; Old is nil for
+// creates and New for deletes.
+type Change struct {
+	Old any `json:"old"`
+	New any `json:"new"`
+}
+
+// RegisterCallbacks installs GORM callbacks that add an audit log entry,
+// in the write's own transaction, for every user and workout created,
+// updated or deleted through db. The actor is taken from the statement's
+// context (see Middleware), so handlers must write through
+// db.WithContext(ctx) to be attributed.
+//
+// Writes through a loaded model get one entry per row with a field-level
+// diff; the row is read back before an update to diff against. Bulk
+// statements such as Model(&T{}).Where(...).Delete() have no rows to
+// describe and get a single entry holding the SQL and rows affected.
+func RegisterCallbacks(db *gorm.DB) error {
+	cb := db.Callback()
+	if err := cb.Update().After("gorm:begin_transaction").Before("gorm:update").Register("audit:load", load); err != nil {
+		return err
+	}
+	if err := cb.Create().After("gorm:create").Before("gorm:commit_or_rollback_transaction").Register("audit:record", record(models.AuditActionCreate)); err != nil {
+		return err
+	}
+	if err := cb.Update().After("gorm:update").Before("gorm:commit_or_rollback_transaction").Register("audit:record", record(models.AuditActionUpdate)); err != nil {
+		return err
+	}
+	return cb.Delete().After("gorm:delete").Before("gorm:commit_or_rollback_transaction").Register("audit:record", record(models.AuditActionDelete))
+}
+
+// load reads the rows an update targets as they are before it runs.
+func load(db *gorm.DB) {
+	if db.Error != nil || db.Statement.Schema == nil {
+		return
+	}
+	if _, ok := resourceTypes[db.Statement.Schema.Table]; !ok {
+		return
+	}
+	before := map[int64]map[string]any{}
+	events.EachRow(db.Statement.ReflectValue, func(row reflect.Value) {
+		id := rowID(db, row)
+		if id == 0 || db.Error != nil {
+			return
+		}
+		current := reflect.New(row.Type())
+		err := db.Session(&gorm.Session{NewDB: true}).Unscoped().Where("id = ?", id).Take(current.Interface()).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return
+		}
+		if err != nil {
+			db.AddError(err)
+			return
+		}
+		if fields, ok := Snapshot(db, current.Elem().Interface()); ok {
+			before[id] = fields
+		}
+	})
+	db.InstanceSet(beforeKey, before)
+}
+
+// record adds the statement's audit log entries.
+func record(action string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		if db.Error != nil || db.Statement.Schema == nil {
+			return
+		}
+		resource, ok := resourceTypes[db.Statement.Schema.Table]
+		if !ok {
+			return
+		}
+		var before map[int64]map[string]any
+		if v, ok := db.InstanceGet(beforeKey); ok {
+			before = v.(map[int64]map[string]any)
+		}
+
+		var entries []models.AuditLog
+		bulk := true
+		events.EachRow(db.Statement.ReflectValue, func(row reflect.Value) {
+			id := rowID(db, row)
+			if id == 0 {
+				return
+			}
+			bulk = false
+			fields, ok := Snapshot(db, row.Interface())
+			if !ok {
+				return
+			}
+			var changes map[string]Change
+			switch action {
+			case models.AuditActionCreate:
+				changes = Diff(nil, fields)
+			case models.AuditActionUpdate:
+				if changes = Diff(before[id], fields); len(changes) == 0 {
+					return
+				}
+			case models.AuditActionDelete:
+				// A soft delete has already stamped the row; record what
+				// was removed rather than the stamp.
+				delete(fields, "deleted_at")
+				changes = Diff(fields, nil)
+			}
+			e := entry(db, action, resource, &id, changes)
+			if pub, ok := row.FieldByName("PublicID").Interface().(uuid.UUID); ok {
+				e.ResourcePublicID = &pub
+			}
+			entries = append(entries, e)
+		})
+		if bulk && db.RowsAffected > 0 {
+			entries = append(entries, entry(db, action, resource, nil, map[string]any{
+				"statement":     db.Dialector.Explain(db.Statement.SQL.String(), db.Statement.Vars...),
+				"rows_affected": db.RowsAffected,
+			}))
+		}
+		if len(entries) == 0 {
+			return
+		}
+		// A fresh statement on the same connection keeps the insert inside
+		// the write's transaction.
+		if err := db.Session(&gorm.Session{NewDB: true}).Create(&entries).Error; err != nil {
+			db.AddError(err)
+		}
+	}
+}
+
+func entry(db *gorm.DB, action, resource string, id *int64, changes any) models.AuditLog {
+	raw, err := json.Marshal(changes)
+	if err != nil {
+		raw = []byte("{}")
+	}
+	e := models.AuditLog{Action: action, ResourceType: resource, ResourceID: id, Changes: raw}
+	if a := FromContext(db.Statement.Context); a != nil {
+		if a.UserID != 0 {
+			uid := a.UserID
+			e.ActorUserID = &uid
+		}
+		e.Subject, e.TokenType, e.ClientID = a.Subject, a.TokenType, a.ClientID
+		e.IP, e.UserAgent, e.RequestID = a.IP, a.UserAgent, a.RequestID
+	}
+	return e
+}
+
+// Snapshot returns the audited fields of a user or workout as they appear
+// in API responses, plus deleted_at, reporting false for other models.
+// Secrets such as password hashes are never included, and the timestamps
+// and version every write touches are left out.
+func Snapshot(db *gorm.DB, row any) (map[string]any, bool) {
+	e, ok := events.Describe(db, row)
+	if !ok {
+		return nil, false
+	}
+	var fields map[string]any
+	if err := json.Unmarshal(e.Payload, &fields); err != nil {
+		return nil, false
+	}
+	delete(fields, "created_at")
+	delete(fields, "updated_at")
+	delete(fields, "version")
+	fields["deleted_at"] = nil
+	if v := reflect.ValueOf(row).FieldByName("DeletedAt"); v.IsValid() {
+		if d, ok := v.Interface().(gorm.DeletedAt); ok && d.Valid {
+			fields["deleted_at"] = d.Time.Format(time.RFC3339Nano)
+		}
+	}
+	return fields, true
+}
+
+// Diff returns the fields whose values differ between before and after,
+// either of which may be nil.
+func Diff(before, after map[string]any) map[string]Change {
+	changes := map[string]Change{}
+	for k, v := range after {
+		if old, ok := before[k]; !ok || !reflect.DeepEqual(old, v) {
+			changes[k] = Change{Old: before[k], New: v}
+		}
+	}
+	for k, v := range before {
+		if _, ok := after[k]; !ok {
+			changes[k] = Change{Old: v}
+		}
+	}
+	return changes
+}
+
+func rowID(db *gorm.DB, row reflect.Value) int64 {
+	f := db.Statement.Schema.PrioritizedPrimaryField
+	if f == nil {
+		return 0
+	}
+	v, zero := f.ValueOf(db.Statement.Context, row)
+	if zero {
+		return 0
+	}
+	id, _ := v.(int64)
+	return id
+}
@@ -0,0 +1,56 @@
+// Package blob stores opaque files, such as workout attachments, by key.
+// Store has an implementation on the local filesystem and one on any
+// S3-compatible object store; the database keeps only keys and metadata.
+//
+// Keys are slash-separated paths of letters, digits and "-._~" in each
+// segment, such as "attachments/<user>/<id>".
+package blob
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ErrNotFound is returned for keys with nothing stored under them.
+var ErrNotFound = errors.New("blob not found")
+
+// Store keeps blobs by key.
+type Store interface {
+	// Put stores the size bytes r holds under key, replacing what was
+	// there. r is read from its start.
+	Put(ctx context.Context, key string, r io.ReadSeeker, size int64, contentType string) error
+	// Get opens the blob under key, or returns ErrNotFound. The caller
+	// closes it.
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete removes the blob under key. Deleting a missing blob is not an
+	// error, so deletes can be retried.
+	Delete(ctx context.Context, key string) error
+}
+
+// checkKey rejects keys that could escape a store's root or that object
+// stores treat specially.
+func checkKey(key string) error {
+	if key == "" {
+		return fmt.Errorf("blob: empty key")
+	}
+	for _, seg := range strings.Split(key, "/") {
+		if seg == "" || seg == "." || seg == ".." {
+			return fmt.Errorf("blob: invalid key %q", key)
+		}
+		for _, c := range seg {
+			if !unreserved(c) {
+				return fmt.Errorf("blob: invalid key %q", key)
+			}
+		}
+	}
+	return nil
+}
+
+// unreserved reports whether c needs no escaping in a URL path.
+func unreserved(c rune) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' ||
+		c == '-' || c == '.' || c == '_' || c == '~'
+}
@@ -0,0 +1,167 @@
+// Package blobtest provides an in-memory S3-compatible server, for testing
+// blob.S3 without a real object store, much as a local MinIO would be
+// used.
+package blobtest
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"workout-tracker/backend/blob"
+)
+
+// S3 serves the object API of one bucket: PUT, GET and DELETE of objects,
+// with requests authenticated by Signature Version 4.
+type S3 struct {
+	*httptest.Server
+	Bucket string
+	Region string
+	blob.Credentials
+
+	mu      sync.Mutex
+	objects map[string]object
+}
+
+type object struct {
+	data        []byte
+	contentType string
+}
+
+// NewS3 starts a server for bucket accepting requests signed with creds.
+// Close it when done.
+func NewS3(bucket, region string, creds blob.Credentials) *S3 {
+	s := &S3{Bucket: bucket, Region: region, Credentials: creds, objects: map[string]object{}}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	return s
+}
+
+// Store returns a blob.S3 talking to s.
+func (s *S3) Store() *blob.S3 {
+	return &blob.S3{Endpoint: s.URL, Region: s.Region, Bucket: s.Bucket, Credentials: s.Credentials, Client: s.Client()}
+}
+
+// Object returns what is stored under key.
+func (s *S3) Object(key string) ([]byte, string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	o, ok := s.objects[key]
+	return o.data, o.contentType, ok
+}
+
+// Len returns how many objects are stored.
+func (s *S3) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.objects)
+}
+
+func (s *S3) serve(w http.ResponseWriter, r *http.Request) {
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if bucket != s.Bucket {
+		writeError(w, http.StatusNotFound, "NoSuchBucket")
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "IncompleteBody")
+		return
+	}
+	if code := s.authenticate(r, body); code != "" {
+		writeError(w, http.StatusForbidden, code)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch r.Method {
+	case http.MethodPut:
+		s.objects[key] = object{data: body, contentType: r.Header.Get("Content-Type")}
+	case http.MethodGet:
+		o, ok := s.objects[key]
+		if !ok {
+			writeError(w, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		w.Header().Set("Content-Type", o.contentType)
+		_, _ = w.Write(o.data)
+	case http.MethodDelete:
+		delete(s.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "MethodNotAllowed")
+	}
+}
+
+// authenticate checks r's signature and payload hash the way S3 does,
+// returning the error code to answer with, or "" when they hold.
+func (s *S3) authenticate(r *http.Request, body []byte) string {
+	auth := r.Header.Get("Authorization")
+	cred, signed, ok := parseAuthorization(auth)
+	if !ok {
+		return "AccessDenied"
+	}
+	if id, _, _ := strings.Cut(cred, "/"); id != s.AccessKeyID {
+		return "InvalidAccessKeyId"
+	}
+	t, err := time.Parse("20060102T150405Z", r.Header.Get("X-Amz-Date"))
+	if err != nil || time.Since(t).Abs() > 15*time.Minute {
+		return "RequestTimeTooSkewed"
+	}
+	payloadHash := r.Header.Get("X-Amz-Content-Sha256")
+	if sum := sha256.Sum256(body); payloadHash != hex.EncodeToString(sum[:]) {
+		return "XAmzContentSHA256Mismatch"
+	}
+
+	// Sign the request again as the client would have, from the headers it
+	// says it signed.
+	check := r.Clone(r.Context())
+	check.Header = http.Header{}
+	for _, name := range strings.Split(signed, ";") {
+		if name != "host" {
+			check.Header[http.CanonicalHeaderKey(name)] = r.Header.Values(name)
+		}
+	}
+	blob.SignV4(check, s.Credentials, s.Region, "s3", payloadHash, t)
+	if check.Header.Get("Authorization") != auth {
+		return "SignatureDoesNotMatch"
+	}
+	return ""
+}
+
+// parseAuthorization returns the credential and signed headers of a
+// Signature Version 4 Authorization header.
+func parseAuthorization(auth string) (cred, signed string, ok bool) {
+	rest, ok := strings.CutPrefix(auth, "AWS4-HMAC-SHA256 ")
+	if !ok {
+		return "", "", false
+	}
+	for _, part := range strings.Split(rest, ", ") {
+		name, value, _ := strings.Cut(part, "=")
+		switch name {
+		case "Credential":
+			cred = value
+		case "SignedHeaders":
+			signed = value
+		}
+	}
+	return cred, signed, cred != "" && signed != ""
+}
+
+func writeError(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	var b bytes.Buffer
+	_ = xml.NewEncoder(&b).Encode(struct {
+		XMLName xml.Name `xml:"Error"`
+		Code    string
+	}{Code: code})
+	_, _ = w.Write(b.Bytes())
+}
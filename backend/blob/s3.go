@@ -0,0 +1,125 @@
+package blob
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// S3 stores blobs as objects in a bucket of an S3-compatible store, such
+// as Amazon S3 or MinIO. Objects are addressed path-style, under
+// Endpoint/Bucket/, which every such store supports.
+type S3 struct {
+	// Endpoint is the store's base URL, e.g. https://s3.eu-west-1.amazonaws.com
+	// or http://localhost:9000.
+	Endpoint string
+	Region   string
+	Bucket   string
+	Credentials
+	// Client sends the requests; nil means http.DefaultClient.
+	Client *http.Client
+}
+
+// Put uploads r with its SHA-256, so the store rejects a body corrupted on
+// the way.
+func (s *S3) Put(ctx context.Context, key string, r io.ReadSeeker, size int64, contentType string) error {
+	h := sha256.New()
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if _, err := io.Copy(h, r); err != nil {
+		return err
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	req, err := s.request(ctx, http.MethodPut, key, io.NopCloser(r))
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	resp, err := s.do(req, hex.EncodeToString(h.Sum(nil)))
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+func (s *S3) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	req, err := s.request(ctx, http.MethodGet, key, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.do(req, EmptyPayloadHash)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+func (s *S3) Delete(ctx context.Context, key string) error {
+	req, err := s.request(ctx, http.MethodDelete, key, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := s.do(req, EmptyPayloadHash)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+func (s *S3) request(ctx context.Context, method, key string, body io.ReadCloser) (*http.Request, error) {
+	if err := checkKey(key); err != nil {
+		return nil, err
+	}
+	// Keys need no escaping, so the path is sent as it is signed.
+	return http.NewRequestWithContext(ctx, method, strings.TrimRight(s.Endpoint, "/")+"/"+s.Bucket+"/"+key, body)
+}
+
+// s3Error is the body of an S3 error response.
+type s3Error struct {
+	Code    string
+	Message string
+}
+
+// do signs and sends req, turning error responses into errors.
+func (s *S3) do(req *http.Request, payloadHash string) (*http.Response, error) {
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	SignV4(req, s.Credentials, s.Region, "s3", payloadHash, time.Now())
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 300 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+	var e s3Error
+	raw, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if xml.Unmarshal(raw, &e) != nil || e.Code == "" {
+		e.Code = strconv.Itoa(resp.StatusCode)
+	}
+	return nil, fmt.Errorf("s3: %s %s: %s %s", req.Method, req.URL.Path, e.Code, e.Message)
+}
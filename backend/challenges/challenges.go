@@ -0,0 +1,134 @@
+// Package challenges computes challenge standings from participants'
+// workouts and freezes them into final results once a challenge is over.
+package challenges
+
+import (
+	"fmt"
+	"time"
+
+	"workout-tracker/backend/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// metricExprs maps challenge metrics onto an aggregate over the LEFT JOINed
+// workouts, so participants without workouts still rank with a value of 0.
+// Distance challenges are won by the longest single workout.
+var metricExprs = map[string]string{
+	models.ChallengeMetricTotalMinutes: "COALESCE(SUM(workouts.duration_minutes), 0)",
+	models.ChallengeMetricSessions:     "COUNT(workouts.id)",
+	models.ChallengeMetricDistance:     "COALESCE(MAX(workouts.distance_meters), 0)",
+}
+
+// Standing is one participant's position in a challenge.
+type Standing struct {
+	Rank         int
+	UserID       int64
+	UserPublicID uuid.UUID
+	Name         string
+	Value        int64
+}
+
+// Standings computes the live ranking for c. A workout counts when it was
+// performed inside [StartsAt, EndsAt) and logged before the challenge froze,
+// which lets participants back-fill sessions during the late-log grace period.
+func Standings(db *gorm.DB, c models.Challenge) ([]Standing, error) {
+	expr, ok := metricExprs[c.Metric]
+	if !ok {
+		return nil, fmt.Errorf("challenges: unknown metric %q", c.Metric)
+	}
+	standings := []Standing{}
+	err := db.Model(&models.ChallengeParticipant{}).
+		Select("challenge_participants.user_id, users.public_id AS user_public_id, users.name, "+expr+" AS value").
+		Joins("JOIN users ON users.id = challenge_participants.user_id").
+		Joins("LEFT JOIN workouts ON workouts.user_id = challenge_participants.user_id"+
+			" AND workouts.deleted_at IS NULL"+
+			" AND workouts.performed_at >= ? AND workouts.performed_at < ?"+
+			" AND workouts.created_at < ?", c.StartsAt, c.EndsAt, c.FreezesAt()).
+		Where("challenge_participants.challenge_id = ?", c.ID).
+		Group("challenge_participants.user_id, users.public_id, users.name").
+		Order("value DESC, users.name").
+		Scan(&standings).Error
+	if err != nil {
+		return nil, err
+	}
+	for i := range standings {
+		standings[i].Rank = i + 1
+		// Ties share the rank of the first participant with that value.
+		if i > 0 && standings[i].Value == standings[i-1].Value {
+			standings[i].Rank = standings[i-1].Rank
+		}
+	}
+	return standings, nil
+}
+
+// FrozenStandings returns the final results stored by Finalize.
+func FrozenStandings(db *gorm.DB, c models.Challenge) ([]Standing, error) {
+	var participants []models.ChallengeParticipant
+	err := db.Preload("User").
+		Where("challenge_id = ?", c.ID).
+		Order("final_rank, user_id").
+		Find(&participants).Error
+	if err != nil {
+		return nil, err
+	}
+	standings := make([]Standing, 0, len(participants))
+	for _, p := range participants {
+		s := Standing{UserID: p.UserID, UserPublicID: p.User.PublicID, Name: p.User.Name}
+		if p.FinalRank != nil {
+			s.Rank = *p.FinalRank
+		}
+		if p.FinalValue != nil {
+			s.Value = *p.FinalValue
+		}
+		standings = append(standings, s)
+	}
+	return standings, nil
+}
+
+// Finalize freezes c's standings once now has passed its FreezesAt. It is
+// safe to call concurrently from several requests or instances: the
+// conditional UPDATE on finalized_at lets exactly one caller win, and Finalize
+// reports whether that was this call. c.FinalizedAt is updated either way.
+func Finalize(db *gorm.DB, c *models.Challenge, now time.Time) (bool, error) {
+	if c.FinalizedAt != nil || now.Before(c.FreezesAt()) {
+		return false, nil
+	}
+	won := false
+	err := db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&models.Challenge{}).
+			Where("id = ? AND finalized_at IS NULL", c.ID).
+			Update("finalized_at", now)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return nil
+		}
+		won = true
+
+		standings, err := Standings(tx, *c)
+		if err != nil {
+			return err
+		}
+		for _, s := range standings {
+			err := tx.Model(&models.ChallengeParticipant{}).
+				Where("challenge_id = ? AND user_id = ?", c.ID, s.UserID).
+				Updates(map[string]any{"final_rank": s.Rank, "final_value": s.Value}).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+	if won {
+		c.FinalizedAt = &now
+	} else if err := db.Select("finalized_at").First(c, c.ID).Error; err != nil {
+		return false, err
+	}
+	return won, nil
+}
@@ -1,13 +1,31 @@
 package db
 
 import (
+	"workout-tracker/backend/audit"
+	"workout-tracker/backend/events"
+
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
 )
 
-func Connect(dsn string) (*gorm.DB, error) {
-	return gorm.Open(postgres.Open(dsn), &gorm.Config{
+// Connect opens the database and installs the change-data callbacks, so
+// every workout and user write is logged to the events table, announced on
+// the returned bus and recorded in the audit log. Call bus.Run to start
+// receiving events.
+func Connect(dsn string) (*gorm.DB, *events.PGBus, error) {
+	gdb, err := gorm.Open(postgres.Open(dsn), &gorm.Config{
 		Logger: logger.Default.LogMode(logger.Info),
 	})
+	if err != nil {
+		return nil, nil, err
+	}
+	bus := events.NewPGBus(gdb, dsn)
+	if err := events.RegisterCallbacks(gdb, bus); err != nil {
+		return nil, nil, err
+	}
+	if err := audit.RegisterCallbacks(gdb); err != nil {
+		return nil, nil, err
+	}
+	return gdb, bus, nil
 }
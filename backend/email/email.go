@@ -0,0 +1,259 @@
+// Package email sends templated notification email: welcome messages,
+// weekly summaries, personal records and coach invites, plus planned
+// session reminders when email is one of a plan's channels.
+//
+// Every template has a plain-text and an HTML variant under templates/ and
+// belongs to a category users can switch off in their notification
+// preferences. Mail in an optional category carries a signed unsubscribe
+// link and List-Unsubscribe headers (RFC 8058 one-click).
+package email
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/mail"
+	"net/url"
+	"strings"
+	"time"
+
+	"workout-tracker/backend/models"
+	"workout-tracker/backend/notify"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Template names.
+const (
+	TemplateWelcome       = "welcome"
+	TemplateWeeklySummary = "weekly_summary"
+	TemplatePRAchieved    = "pr_achieved"
+	TemplateCoachInvite   = "coach_invite"
+	TemplateReminder      = "reminder"
+)
+
+// Notification categories. Account mail is transactional and always sent;
+// the rest can be switched off one by one or together with CategoryAll.
+const (
+	CategoryAccount       = "account"
+	CategoryWeeklySummary = "weekly_summary"
+	CategoryAchievements  = "achievements"
+	CategoryInvites       = "invites"
+	CategoryReminders     = "reminders"
+	CategoryAll           = "all"
+)
+
+// Categories lists the categories users can unsubscribe from, CategoryAll
+// included.
+var Categories = []string{CategoryWeeklySummary, CategoryAchievements, CategoryInvites, CategoryReminders, CategoryAll}
+
+// Metrics a personal record can be set in.
+const (
+	MetricDuration = "duration"
+	MetricDistance = "distance"
+	MetricWeight   = "weight"
+)
+
+// WelcomeData is the data for TemplateWelcome.
+type WelcomeData struct{}
+
+// WeeklySummaryData is the data for TemplateWeeklySummary.
+type WeeklySummaryData struct {
+	WeekStart           time.Time `json:"week_start"`
+	WeekEnd             time.Time `json:"week_end"`
+	Workouts            int       `json:"workouts"`
+	TotalMinutes        int       `json:"total_minutes"`
+	TotalDistanceMeters int       `json:"total_distance_meters"`
+}
+
+// Record is one personal record a workout set: Value beats the previous
+// best in Metric (minutes, meters, or kilograms lifted in Exercise).
+type Record struct {
+	Metric   string  `json:"metric"`
+	Exercise string  `json:"exercise,omitempty"`
+	Value    float64 `json:"value"`
+	Previous float64 `json:"previous"`
+}
+
+// PRAchievedData is the data for TemplatePRAchieved.
+type PRAchievedData struct {
+	WorkoutID   uuid.UUID `json:"workout_id"`
+	WorkoutName string    `json:"workout_name"`
+	Records     []Record  `json:"records"`
+}
+
+// CoachInviteData is the data for TemplateCoachInvite.
+type CoachInviteData struct {
+	OrganizationID   uuid.UUID `json:"organization_id"`
+	OrganizationName string    `json:"organization_name"`
+	InvitedBy        string    `json:"invited_by,omitempty"`
+}
+
+// ReminderData is the data for TemplateReminder, which wraps a
+// notify.Message.
+type ReminderData struct {
+	Subject string `json:"subject"`
+	Text    string `json:"text"`
+}
+
+// registry maps each template to its category and a constructor for its
+// data, used to decode queued jobs.
+var registry = map[string]struct {
+	category string
+	newData  func() any
+}{
+	TemplateWelcome:       {CategoryAccount, func() any { return &WelcomeData{} }},
+	TemplateWeeklySummary: {CategoryWeeklySummary, func() any { return &WeeklySummaryData{} }},
+	TemplatePRAchieved:    {CategoryAchievements, func() any { return &PRAchievedData{} }},
+	TemplateCoachInvite:   {CategoryInvites, func() any { return &CoachInviteData{} }},
+	TemplateReminder:      {CategoryReminders, func() any { return &ReminderData{} }},
+}
+
+// Sender delivers rendered email; notify.SMTP is the production one.
+type Sender interface {
+	SendEmail(ctx context.Context, e notify.Email) error
+}
+
+// LogSender logs email instead of sending it, for instances without SMTP.
+type LogSender struct{}
+
+func (LogSender) SendEmail(ctx context.Context, e notify.Email) error {
+	slog.InfoContext(ctx, "email: not sent, SMTP is not configured", "to", e.To.Address, "subject", e.Subject)
+	return nil
+}
+
+// Mailer renders templates for users and sends them, honouring their
+// notification preferences.
+type Mailer struct {
+	db        *gorm.DB
+	sender    Sender
+	signer    *Signer
+	templates map[string]templateSet
+
+	// BaseURL is the public origin of the API and app, used for links in
+	// mail. Unsubscribe links point at BaseURL/unsubscribe, which is outside
+	// /api so it works without a token.
+	BaseURL string
+	// Now is the clock; tests may pin it.
+	Now func() time.Time
+}
+
+// New returns a Mailer sending through sender, signing unsubscribe links
+// with signer.
+func New(db *gorm.DB, sender Sender, signer *Signer, baseURL string) (*Mailer, error) {
+	sets, err := parseTemplates()
+	if err != nil {
+		return nil, err
+	}
+	return &Mailer{
+		db:        db,
+		sender:    sender,
+		signer:    signer,
+		templates: sets,
+		BaseURL:   strings.TrimRight(baseURL, "/"),
+		Now:       time.Now,
+	}, nil
+}
+
+// Deliver renders template name with data for userID and sends it, unless
+// the user has switched the template's category off. Users that no longer
+// exist are skipped.
+func (m *Mailer) Deliver(ctx context.Context, userID int64, name string, data any) error {
+	var user models.User
+	if err := m.db.WithContext(ctx).First(&user, userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+	return m.deliver(ctx, notify.Recipient{UserID: user.ID, PublicID: user.PublicID, Email: user.Email, Name: user.Name}, name, data)
+}
+
+// Send delivers a notify.Message as a reminder email, making Mailer the
+// email notify.Channel.
+func (m *Mailer) Send(ctx context.Context, to notify.Recipient, msg notify.Message) error {
+	return m.deliver(ctx, to, TemplateReminder, ReminderData{Subject: msg.Subject, Text: msg.Text})
+}
+
+func (m *Mailer) deliver(ctx context.Context, to notify.Recipient, name string, data any) error {
+	tmpl, ok := registry[name]
+	if !ok {
+		return fmt.Errorf("unknown email template %q", name)
+	}
+	if to.Email == "" {
+		return errors.New("recipient has no email address")
+	}
+	v := view{Recipient: to, Data: data, AppURL: m.BaseURL}
+	var headers map[string]string
+	if tmpl.category != CategoryAccount {
+		enabled, err := Enabled(m.db.WithContext(ctx), to.UserID, tmpl.category)
+		if err != nil {
+			return err
+		}
+		if !enabled {
+			return nil
+		}
+		v.UnsubscribeURL = m.UnsubscribeURL(to.PublicID, tmpl.category)
+		headers = map[string]string{
+			"List-Unsubscribe":      "<" + v.UnsubscribeURL + ">",
+			"List-Unsubscribe-Post": "List-Unsubscribe=One-Click",
+		}
+	}
+	subject, text, html, err := m.templates[name].render(v)
+	if err != nil {
+		return err
+	}
+	return m.sender.SendEmail(ctx, notify.Email{
+		To:      mail.Address{Name: to.Name, Address: to.Email},
+		Subject: subject,
+		Text:    text,
+		HTML:    html,
+		Headers: headers,
+	})
+}
+
+// UnsubscribeURL returns the link that switches category off for the user
+// with public ID userID.
+func (m *Mailer) UnsubscribeURL(userID uuid.UUID, category string) string {
+	return m.BaseURL + "/unsubscribe?token=" + url.QueryEscape(m.signer.Token(userID, category))
+}
+
+// Enabled reports whether userID gets email in category.
+func Enabled(db *gorm.DB, userID int64, category string) (bool, error) {
+	if category == CategoryAccount {
+		return true, nil
+	}
+	var n int64
+	err := db.Model(&models.NotificationPreference{}).
+		Where("user_id = ? AND category IN ? AND NOT email", userID, []string{category, CategoryAll}).
+		Count(&n).Error
+	return n == 0, err
+}
+
+// Preferences returns userID's email setting for every category in
+// Categories, defaulting to enabled.
+func Preferences(db *gorm.DB, userID int64) (map[string]bool, error) {
+	var rows []models.NotificationPreference
+	if err := db.Where("user_id = ?", userID).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	prefs := make(map[string]bool, len(Categories))
+	for _, c := range Categories {
+		prefs[c] = true
+	}
+	for _, r := range rows {
+		prefs[r.Category] = r.Email
+	}
+	return prefs, nil
+}
+
+// SetPreference switches email in category on or off for userID.
+func SetPreference(db *gorm.DB, userID int64, category string, enabled bool) error {
+	return db.Omit(clause.Associations).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "category"}},
+		DoUpdates: clause.AssignmentColumns([]string{"email", "updated_at"}),
+	}).Create(&models.NotificationPreference{UserID: userID, Category: category, Email: enabled}).Error
+}
@@ -0,0 +1,231 @@
+package email
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"workout-tracker/backend/events"
+	"workout-tracker/backend/jobs"
+	"workout-tracker/backend/models"
+	"workout-tracker/backend/schemas"
+	"workout-tracker/backend/training"
+
+	"gorm.io/gorm"
+)
+
+// Queue is the job queue email is sent from, so a slow relay doesn't hold
+// up other background work.
+const Queue = "email"
+
+// Kinds of the email jobs.
+const (
+	KindSend            = "email.send"
+	KindWeeklySummaries = "email.weekly_summaries"
+)
+
+// Consumer is the Mailer's name in the events outbox.
+const Consumer = "email"
+
+// SendArgs are the arguments of a KindSend job.
+type SendArgs struct {
+	UserID   int64           `json:"user_id"`
+	Template string          `json:"template"`
+	Data     json.RawMessage `json:"data,omitempty"`
+}
+
+// Enqueue queues template name with data for userID. Pass the transaction
+// that made the change the email is about, so it goes out if and only if
+// the change commits.
+func Enqueue(db *gorm.DB, userID int64, name string, data any, opts ...jobs.Option) error {
+	if _, ok := registry[name]; !ok {
+		return fmt.Errorf("unknown email template %q", name)
+	}
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	opts = append([]jobs.Option{jobs.InQueue(Queue)}, opts...)
+	_, err = jobs.Enqueue(db, KindSend, SendArgs{UserID: userID, Template: name, Data: raw}, opts...)
+	return err
+}
+
+// Register installs the email job handlers on r, with two workers on
+// Queue, and schedules weekly summaries for Monday mornings on s.
+func (m *Mailer) Register(r *jobs.Runner, s *jobs.Scheduler) error {
+	r.Queue(Queue, 2)
+	r.Handle(KindSend, func(ctx context.Context, job models.Job) error {
+		var args SendArgs
+		if err := json.Unmarshal(job.Args, &args); err != nil {
+			return err
+		}
+		tmpl, ok := registry[args.Template]
+		if !ok {
+			return fmt.Errorf("unknown email template %q", args.Template)
+		}
+		data := tmpl.newData()
+		if len(args.Data) > 0 {
+			if err := json.Unmarshal(args.Data, data); err != nil {
+				return err
+			}
+		}
+		return m.Deliver(ctx, args.UserID, args.Template, data)
+	})
+	r.Handle(KindWeeklySummaries, func(ctx context.Context, _ models.Job) error {
+		return m.QueueWeeklySummaries(ctx)
+	})
+	return s.Every("weekly-summaries", "0 8 * * 1", KindWeeklySummaries, nil)
+}
+
+// QueueWeeklySummaries queues a summary of the seven days before today for
+// every user who worked out in the last four weeks. Each user's summary is
+// keyed by week, so a rerun doesn't send it twice.
+func (m *Mailer) QueueWeeklySummaries(ctx context.Context) error {
+	now := m.Now().UTC()
+	end := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	start := end.AddDate(0, 0, -7)
+
+	var rows []struct {
+		UserID   int64
+		Workouts int
+		Minutes  int
+		Meters   int
+	}
+	err := m.db.WithContext(ctx).Model(&models.Workout{}).
+		Select("user_id, "+
+			"COUNT(*) FILTER (WHERE performed_at >= ?) AS workouts, "+
+			"COALESCE(SUM(duration_minutes) FILTER (WHERE performed_at >= ?), 0) AS minutes, "+
+			"COALESCE(SUM(distance_meters) FILTER (WHERE performed_at >= ?), 0) AS meters",
+			start, start, start).
+		Where("performed_at >= ? AND performed_at < ?", end.AddDate(0, 0, -28), end).
+		Group("user_id").
+		Scan(&rows).Error
+	if err != nil {
+		return err
+	}
+	for _, r := range rows {
+		data := WeeklySummaryData{
+			WeekStart:           start,
+			WeekEnd:             end.AddDate(0, 0, -1),
+			Workouts:            r.Workouts,
+			TotalMinutes:        r.Minutes,
+			TotalDistanceMeters: r.Meters,
+		}
+		key := fmt.Sprintf("weekly-summary:%d:%s", r.UserID, start.Format(time.DateOnly))
+		if err := Enqueue(m.db.WithContext(ctx), r.UserID, TemplateWeeklySummary, data, jobs.Unique(key)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// HandleEvent is the Mailer's outbox consumer: it queues a welcome email
+// for new users and a personal-record email for workouts that beat the
+// user's previous longest duration or distance, or their heaviest lift in
+// an exercise.
+func (m *Mailer) HandleEvent(_ context.Context, tx *gorm.DB, e events.Event) error {
+	switch e.Type {
+	case events.TypeUserCreated:
+		return Enqueue(tx, e.ResourceID, TemplateWelcome, WelcomeData{})
+	case events.TypeWorkoutCreated:
+		var w schemas.WorkoutResponse
+		if err := json.Unmarshal(e.Payload, &w); err != nil {
+			return err
+		}
+		records, err := PersonalRecords(tx, e.UserID, e.ResourceID, w)
+		if err != nil || len(records) == 0 {
+			return err
+		}
+		return Enqueue(tx, e.UserID, TemplatePRAchieved, PRAchievedData{
+			WorkoutID:   w.ID,
+			WorkoutName: w.Name,
+			Records:     records,
+		})
+	}
+	return nil
+}
+
+// PersonalRecords returns the records w, which is workout workoutID of
+// user userID, sets against the user's other workouts. A user's first
+// workout sets none. Lifts are compared per exercise, by the heaviest
+// working set: warm-ups count for nothing, and neither does an exercise's
+// first appearance.
+func PersonalRecords(db *gorm.DB, userID, workoutID int64, w schemas.WorkoutResponse) ([]Record, error) {
+	var best struct {
+		N           int64
+		MaxDuration int
+		MaxDistance int
+	}
+	err := db.Model(&models.Workout{}).
+		Select("COUNT(*) AS n, COALESCE(MAX(duration_minutes), 0) AS max_duration, COALESCE(MAX(distance_meters), 0) AS max_distance").
+		Where("user_id = ? AND id <> ?", userID, workoutID).
+		Scan(&best).Error
+	if err != nil || best.N == 0 {
+		return nil, err
+	}
+	var records []Record
+	if w.DurationMinutes > best.MaxDuration {
+		records = append(records, Record{Metric: MetricDuration, Value: float64(w.DurationMinutes), Previous: float64(best.MaxDuration)})
+	}
+	if w.DistanceMeters > best.MaxDistance {
+		records = append(records, Record{Metric: MetricDistance, Value: float64(w.DistanceMeters), Previous: float64(best.MaxDistance)})
+	}
+	lifts, err := weightRecords(db, userID, workoutID, w.Exercises)
+	return append(records, lifts...), err
+}
+
+// weightRecords returns the exercises of a workout whose heaviest working
+// set beats the heaviest working set of the same exercise, matched by
+// name whatever its case, in the user's other workouts.
+func weightRecords(db *gorm.DB, userID, workoutID int64, exercises []training.Exercise) ([]Record, error) {
+	var lifts []Record
+	index := map[string]int{}
+	for _, e := range exercises {
+		for _, s := range e.Sets {
+			if !s.Working() || s.WeightKg == nil {
+				continue
+			}
+			key := strings.ToLower(e.Name)
+			i, ok := index[key]
+			if !ok {
+				i = len(lifts)
+				index[key] = i
+				lifts = append(lifts, Record{Metric: MetricWeight, Exercise: e.Name})
+			}
+			lifts[i].Value = max(lifts[i].Value, *s.WeightKg)
+		}
+	}
+	if len(lifts) == 0 {
+		return nil, nil
+	}
+
+	var rows []struct {
+		Exercise string
+		Best     float64
+	}
+	err := db.Raw(`SELECT lower(e->>'name') AS exercise, MAX((s->>'weight_kg')::float8) AS best
+  FROM workouts w, jsonb_array_elements(w.exercises) e, jsonb_array_elements(e->'sets') s
+  WHERE w.user_id = ? AND w.id <> ? AND w.deleted_at IS NULL AND lower(e->>'name') IN ?
+    AND s->>'weight_kg' IS NOT NULL AND NOT COALESCE((s->>'warmup')::boolean, false)
+  GROUP BY lower(e->>'name')`, userID, workoutID, slices.Sorted(maps.Keys(index))).Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	best := make(map[string]float64, len(rows))
+	for _, r := range rows {
+		best[r.Exercise] = r.Best
+	}
+	var records []Record
+	for _, lift := range lifts {
+		previous, ok := best[strings.ToLower(lift.Exercise)]
+		if ok && lift.Value > previous {
+			lift.Previous = previous
+			records = append(records, lift)
+		}
+	}
+	return records, nil
+}
@@ -0,0 +1,99 @@
+package email
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"strconv"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	"workout-tracker/backend/notify"
+)
+
+//go:embed templates/*.tmpl
+var templateFS embed.FS
+
+// view is what every template is executed with; Data is the template's
+// own data type.
+type view struct {
+	Recipient      notify.Recipient
+	Subject        string
+	Data           any
+	AppURL         string
+	UnsubscribeURL string
+}
+
+// templateSet holds the parsed text and HTML variants of one template.
+// The text file also defines the subject.
+type templateSet struct {
+	text *texttemplate.Template
+	html *htmltemplate.Template
+}
+
+var funcs = map[string]any{
+	"date": func(t time.Time) string { return t.Format("Mon 2 Jan") },
+	"km":   func(m int) string { return fmt.Sprintf("%.2f km", float64(m)/1000) },
+	"duration": func(min int) string {
+		if min < 60 {
+			return fmt.Sprintf("%d min", min)
+		}
+		return fmt.Sprintf("%dh %02dm", min/60, min%60)
+	},
+	"record": func(r Record) string {
+		format := func(v float64) string { return fmt.Sprintf("%d min", int(v)) }
+		label := "Longest workout"
+		switch r.Metric {
+		case MetricDistance:
+			format = func(v float64) string { return fmt.Sprintf("%.2f km", v/1000) }
+			label = "Longest distance"
+		case MetricWeight:
+			format = func(v float64) string { return strconv.FormatFloat(v, 'f', -1, 64) + " kg" }
+			label = "Heaviest " + r.Exercise
+		}
+		return fmt.Sprintf("%s: %s (previous best %s)", label, format(r.Value), format(r.Previous))
+	},
+}
+
+// parseTemplates parses every template in the registry against the shared
+// layouts, so a broken template fails at startup rather than on send.
+func parseTemplates() (map[string]templateSet, error) {
+	sets := make(map[string]templateSet, len(registry))
+	for name := range registry {
+		text, err := texttemplate.New(name).Funcs(funcs).ParseFS(templateFS,
+			"templates/layout.txt.tmpl", "templates/"+name+".txt.tmpl")
+		if err != nil {
+			return nil, fmt.Errorf("template %s: %w", name, err)
+		}
+		html, err := htmltemplate.New(name).Funcs(funcs).ParseFS(templateFS,
+			"templates/layout.html.tmpl", "templates/"+name+".html.tmpl")
+		if err != nil {
+			return nil, fmt.Errorf("template %s: %w", name, err)
+		}
+		sets[name] = templateSet{text: text, html: html}
+	}
+	return sets, nil
+}
+
+// render executes the subject, plain-text and HTML parts of a template.
+func (t templateSet) render(v view) (subject, text, html string, err error) {
+	var b bytes.Buffer
+	if err := t.text.ExecuteTemplate(&b, "subject", v); err != nil {
+		return "", "", "", err
+	}
+	v.Subject = strings.TrimSpace(b.String())
+
+	b.Reset()
+	if err := t.text.ExecuteTemplate(&b, "layout", v); err != nil {
+		return "", "", "", err
+	}
+	text = b.String()
+
+	b.Reset()
+	if err := t.html.ExecuteTemplate(&b, "layout", v); err != nil {
+		return "", "", "", err
+	}
+	return v.Subject, text, b.String(), nil
+}
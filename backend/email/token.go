@@ -0,0 +1,61 @@
+package email
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+
+	"workout-tracker/backend/signing"
+
+	"github.com/google/uuid"
+)
+
+// ErrInvalidToken is returned for unsubscribe tokens that are malformed or
+// were not signed with this Signer's key.
+var ErrInvalidToken = errors.New("invalid unsubscribe token")
+
+// Signer issues and checks the tokens in unsubscribe links. A token names a
+// user and a category and needs no login to use, so it is signed rather
+// than stored.
+type Signer struct {
+	key *signing.Key
+}
+
+// NewSigner returns a Signer keyed with secret. An empty secret gets a
+// random key, which is fine for development but invalidates every link
+// sent before a restart.
+func NewSigner(secret string) *Signer {
+	return &Signer{key: signing.NewKey(secret)}
+}
+
+// Token returns a token that unsubscribes the user with public ID userID
+// from category.
+func (s *Signer) Token(userID uuid.UUID, category string) string {
+	payload := base64.RawURLEncoding.EncodeToString([]byte(userID.String() + ":" + category))
+	return payload + "." + s.key.Sign(payload)
+}
+
+// Verify checks token and returns the public ID of the user and the
+// category it was issued for.
+func (s *Signer) Verify(token string) (uuid.UUID, string, error) {
+	payload, sig, ok := strings.Cut(token, ".")
+	if !ok {
+		return uuid.Nil, "", ErrInvalidToken
+	}
+	if !s.key.Valid(payload, sig) {
+		return uuid.Nil, "", ErrInvalidToken
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return uuid.Nil, "", ErrInvalidToken
+	}
+	id, category, ok := strings.Cut(string(raw), ":")
+	if !ok {
+		return uuid.Nil, "", ErrInvalidToken
+	}
+	userID, err := uuid.Parse(id)
+	if err != nil {
+		return uuid.Nil, "", ErrInvalidToken
+	}
+	return userID, category, nil
+}
@@ -0,0 +1,108 @@
+package events
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// hookPool wraps a database's connection pool so that every transaction
+// begun through it is a hookTx. GORM's own transactions, whether opened by
+// a write or by db.Transaction, begin through the pool.
+type hookPool struct {
+	gorm.ConnPool
+}
+
+func (p hookPool) BeginTx(ctx context.Context, opts *sql.TxOptions) (gorm.ConnPool, error) {
+	var (
+		tx  gorm.ConnPool
+		err error
+	)
+	switch beginner := p.ConnPool.(type) {
+	case gorm.TxBeginner:
+		tx, err = beginner.BeginTx(ctx, opts)
+	case gorm.ConnPoolBeginner:
+		tx, err = beginner.BeginTx(ctx, opts)
+	default:
+		return nil, gorm.ErrInvalidTransaction
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &hookTx{ConnPool: tx, pool: p}, nil
+}
+
+// GetDBConn returns the wrapped *sql.DB, for gorm.DB.DB.
+func (p hookPool) GetDBConn() (*sql.DB, error) {
+	if c, ok := p.ConnPool.(gorm.GetDBConnector); ok {
+		return c.GetDBConn()
+	}
+	db, _ := p.ConnPool.(*sql.DB)
+	return db, nil
+}
+
+// hookTx is a transaction that runs functions once it has committed.
+// Functions added after a savepoint are dropped when the transaction rolls
+// back to it, as the writes that added them are.
+type hookTx struct {
+	gorm.ConnPool
+	pool  hookPool
+	after []func()
+	marks map[string]int
+}
+
+// afterCommit arranges for fn to run once tx has committed.
+func (tx *hookTx) afterCommit(fn func()) {
+	tx.after = append(tx.after, fn)
+}
+
+func (tx *hookTx) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	res, err := tx.ConnPool.ExecContext(ctx, query, args...)
+	if err != nil {
+		return res, err
+	}
+	// GORM's Postgres dialector sets and rolls back to savepoints with
+	// these statements.
+	if name, ok := strings.CutPrefix(query, "ROLLBACK TO SAVEPOINT "); ok {
+		if n, ok := tx.marks[name]; ok {
+			tx.after = tx.after[:n]
+		}
+	} else if name, ok := strings.CutPrefix(query, "SAVEPOINT "); ok {
+		if tx.marks == nil {
+			tx.marks = make(map[string]int)
+		}
+		tx.marks[name] = len(tx.after)
+	}
+	return res, nil
+}
+
+func (tx *hookTx) Commit() error {
+	if err := tx.ConnPool.(gorm.TxCommitter).Commit(); err != nil {
+		return err
+	}
+	for _, fn := range tx.after {
+		fn()
+	}
+	return nil
+}
+
+func (tx *hookTx) Rollback() error {
+	return tx.ConnPool.(gorm.TxCommitter).Rollback()
+}
+
+// GetDBConn returns the *sql.DB the transaction was begun on, for
+// gorm.DB.DB.
+func (tx *hookTx) GetDBConn() (*sql.DB, error) {
+	return tx.pool.GetDBConn()
+}
+
+// installHooks makes the transactions of db support afterCommit.
+func installHooks(db *gorm.DB) {
+	if _, ok := db.ConnPool.(hookPool); ok {
+		return
+	}
+	db.ConnPool = hookPool{db.ConnPool}
+	db.Statement.ConnPool = db.ConnPool
+}
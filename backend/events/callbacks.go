@@ -0,0 +1,191 @@
+package events
+
+import (
+	"encoding/json"
+	"reflect"
+
+	"workout-tracker/backend/models"
+	"workout-tracker/backend/schemas"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// RegisterCallbacks installs GORM callbacks that log an event for every
+// workout and user created, updated or deleted through db, and publish the
+// logged events on bus after the write commits.
+//
+// Only writes made through a loaded model (Create(&w), Save(&w),
+// Delete(&w)) are seen; bulk Model(&T{}).Where(...) updates carry no row to
+// describe and are not logged. When the write runs inside a caller's
+// transaction, its events are published once that transaction commits and
+// dropped if it, or the savepoint the write ran under, rolls back. To see
+// those commits, RegisterCallbacks wraps db's connection pool.
+func RegisterCallbacks(db *gorm.DB, bus Bus) error {
+	installHooks(db)
+	r := &recorder{bus: bus}
+	cb := db.Callback()
+	if err := cb.Create().After("gorm:create").Before("gorm:commit_or_rollback_transaction").Register("events:record", r.record(created)); err != nil {
+		return err
+	}
+	if err := cb.Update().After("gorm:update").Before("gorm:commit_or_rollback_transaction").Register("events:record", r.record(updated)); err != nil {
+		return err
+	}
+	return cb.Delete().After("gorm:delete").Before("gorm:commit_or_rollback_transaction").Register("events:record", r.record(deleted))
+}
+
+type change int
+
+const (
+	created change = iota
+	updated
+	deleted
+)
+
+var eventTypes = map[string][3]string{
+	ResourceWorkout: {TypeWorkoutCreated, TypeWorkoutUpdated, TypeWorkoutDeleted},
+	ResourceUser:    {TypeUserCreated, TypeUserUpdated, TypeUserDeleted},
+}
+
+type recorder struct {
+	bus Bus
+}
+
+// record logs an event for each tracked row the statement wrote, in the
+// statement's own transaction, and publishes the events once that
+// transaction commits.
+func (r *recorder) record(c change) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		if db.Error != nil || db.Statement.Schema == nil {
+			return
+		}
+		var pending []Event
+		EachRow(db.Statement.ReflectValue, func(row reflect.Value) {
+			if db.Error != nil {
+				return
+			}
+			e, ok := Describe(db, row.Interface())
+			if !ok {
+				return
+			}
+			e.Type = eventTypes[e.ResourceType][c]
+			logged, err := insert(db, e)
+			if err != nil {
+				db.AddError(err)
+				return
+			}
+			pending = append(pending, logged)
+		})
+		if db.Error != nil || len(pending) == 0 {
+			return
+		}
+		publish := func() {
+			for _, e := range pending {
+				r.bus.Publish(e)
+			}
+		}
+		if tx, ok := db.Statement.ConnPool.(*hookTx); ok {
+			tx.afterCommit(publish)
+		} else {
+			// Without a transaction the write has already committed.
+			publish()
+		}
+	}
+}
+
+func insert(db *gorm.DB, e Event) (Event, error) {
+	row := models.Event{
+		Type:             e.Type,
+		ResourceType:     e.ResourceType,
+		ResourceID:       e.ResourceID,
+		ResourcePublicID: e.ResourcePublicID,
+		UserID:           e.UserID,
+		Visibility:       e.Visibility,
+		Payload:          e.Payload,
+	}
+	// A fresh statement on the same connection keeps the insert inside the
+	// write's transaction.
+	if err := db.Session(&gorm.Session{NewDB: true}).Create(&row).Error; err != nil {
+		return Event{}, err
+	}
+	return FromModel(row), nil
+}
+
+// Describe builds the event for a tracked model, without its Type,
+// reporting false for anything else. db looks up the public IDs the
+// payload refers to; a failed lookup is added to db's errors.
+func Describe(db *gorm.DB, row any) (Event, bool) {
+	var (
+		e       Event
+		payload any
+	)
+	switch m := row.(type) {
+	case models.Workout:
+		if m.ID == 0 {
+			return Event{}, false
+		}
+		var owner struct{ PublicID uuid.UUID }
+		err := db.Session(&gorm.Session{NewDB: true}).Unscoped().Model(&models.User{}).
+			Select("public_id").Where("id = ?", m.UserID).Take(&owner).Error
+		if err != nil {
+			db.AddError(err)
+			return Event{}, false
+		}
+		e = Event{ResourceType: ResourceWorkout, ResourceID: m.ID, ResourcePublicID: m.PublicID, UserID: m.UserID, Visibility: m.Visibility}
+		payload = schemas.WorkoutResponse{
+			ID:              m.PublicID,
+			UserID:          owner.PublicID,
+			Name:            m.Name,
+			Description:     m.Description,
+			DurationMinutes: m.DurationMinutes,
+			DistanceMeters:  m.DistanceMeters,
+			PerformedAt:     m.PerformedAt,
+			Visibility:      m.Visibility,
+			Tags:            m.Tags,
+			CustomFields:    m.CustomFields,
+			Exercises:       m.Exercises,
+			Groups:          m.Groups,
+			Version:         m.Version,
+			CreatedAt:       m.CreatedAt,
+			UpdatedAt:       m.UpdatedAt,
+		}
+	case models.User:
+		if m.ID == 0 {
+			return Event{}, false
+		}
+		e = Event{ResourceType: ResourceUser, ResourceID: m.ID, ResourcePublicID: m.PublicID, UserID: m.ID, Visibility: models.VisibilityPrivate}
+		payload = schemas.UserResponse{
+			ID:        m.PublicID,
+			Email:     m.Email,
+			Name:      m.Name,
+			Version:   m.Version,
+			CreatedAt: m.CreatedAt,
+			UpdatedAt: m.UpdatedAt,
+		}
+	default:
+		return Event{}, false
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return Event{}, false
+	}
+	e.Payload = body
+	return e, true
+}
+
+// EachRow calls fn with every struct in v, which may be a struct, a
+// pointer to one, or a slice or array of either. GORM callbacks use it to
+// visit the rows of db.Statement.ReflectValue.
+func EachRow(v reflect.Value, fn func(reflect.Value)) {
+	v = reflect.Indirect(v)
+	switch v.Kind() {
+	case reflect.Struct:
+		fn(v)
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if el := reflect.Indirect(v.Index(i)); el.Kind() == reflect.Struct {
+				fn(el)
+			}
+		}
+	}
+}
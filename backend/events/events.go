@@ -0,0 +1,138 @@
+// Package events carries resource change notifications from the write path
+// to live subscribers such as the /api/v1/events SSE stream.
+//
+// Every event is first appended to the events table inside the transaction
+// that made the change, then published on a Bus once that transaction
+// commits; both steps are done by the GORM callbacks installed with
+// RegisterCallbacks. The log makes events durable and resumable; the Bus
+// only has to deliver them to whoever is listening right now.
+package events
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"workout-tracker/backend/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Event types.
+const (
+	TypeWorkoutCreated = "workout.created"
+	TypeWorkoutUpdated = "workout.updated"
+	TypeWorkoutDeleted = "workout.deleted"
+	TypeUserCreated    = "user.created"
+	TypeUserUpdated    = "user.updated"
+	TypeUserDeleted    = "user.deleted"
+
+	TypeSessionReminder = "planned_session.reminder"
+	TypeSessionMissed   = "planned_session.missed"
+)
+
+// Resource types.
+const (
+	ResourceWorkout = "workout"
+	ResourceUser    = "user"
+	ResourceSession = "planned_session"
+)
+
+// subscriberBuffer is how many events a subscriber may fall behind before
+// MemoryBus drops it.
+const subscriberBuffer = 64
+
+// Event is a committed change as seen by subscribers.
+type Event struct {
+	ID           int64
+	Type         string
+	ResourceType string
+	ResourceID   int64
+	// ResourcePublicID is the resource's ID as the API shows it.
+	ResourcePublicID uuid.UUID
+	UserID           int64
+	Visibility       string
+	Payload          json.RawMessage
+	CreatedAt        time.Time
+}
+
+// Bus fans committed events out to subscribers. Implementations must not
+// block publishers on slow subscribers.
+type Bus interface {
+	Publish(e Event)
+	// Subscribe returns a channel of events published after the call and a
+	// function that unsubscribes. The channel is closed on unsubscribe or
+	// when the bus gives up on a subscriber that fell too far behind; the
+	// subscriber is expected to resume from the log in that case.
+	Subscribe() (<-chan Event, func())
+}
+
+// Since returns logged events with an ID greater than afterID, oldest first.
+func Since(db *gorm.DB, afterID int64, limit int) ([]Event, error) {
+	var rows []models.Event
+	if err := db.Where("id > ?", afterID).Order("id").Limit(limit).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	out := make([]Event, len(rows))
+	for i, r := range rows {
+		out[i] = FromModel(r)
+	}
+	return out, nil
+}
+
+// FromModel converts a logged row into an Event.
+func FromModel(r models.Event) Event {
+	return Event{
+		ID:               r.ID,
+		Type:             r.Type,
+		ResourceType:     r.ResourceType,
+		ResourceID:       r.ResourceID,
+		ResourcePublicID: r.ResourcePublicID,
+		UserID:           r.UserID,
+		Visibility:       r.Visibility,
+		Payload:          r.Payload,
+		CreatedAt:        r.CreatedAt,
+	}
+}
+
+// MemoryBus is an in-process Bus. It only reaches subscribers in the same
+// process, which is enough for a single instance.
+type MemoryBus struct {
+	mu   sync.Mutex
+	subs map[chan Event]struct{}
+}
+
+func NewMemoryBus() *MemoryBus {
+	return &MemoryBus{subs: make(map[chan Event]struct{})}
+}
+
+func (b *MemoryBus) Publish(e Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.subs {
+		select {
+		case ch <- e:
+		default:
+			// Too far behind: cut the subscriber loose rather than block.
+			delete(b.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+func (b *MemoryBus) Subscribe() (<-chan Event, func()) {
+	ch := make(chan Event, subscriberBuffer)
+	b.mu.Lock()
+	b.subs[ch] = struct{}{}
+	b.mu.Unlock()
+
+	return ch, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if _, ok := b.subs[ch]; ok {
+			delete(b.subs, ch)
+			close(ch)
+		}
+	}
+}
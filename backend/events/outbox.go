@@ -0,0 +1,227 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"slices"
+	"sync"
+	"time"
+
+	"workout-tracker/backend/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Handler processes one event for an outbox consumer. It runs inside the
+// transaction that marks the message processed, so any database writes it
+// makes through tx commit exactly once together with that mark. Side
+// effects outside the database should be queued through tx rather than
+// performed directly.
+type Handler func(ctx context.Context, tx *gorm.DB, e Event) error
+
+// Outbox gives every registered consumer its own durable copy of each
+// logged event and runs the consumers' handlers, leasing messages with
+// SELECT ... FOR UPDATE SKIP LOCKED so several instances can share the work
+// without handling a message twice. The exported fields may be adjusted
+// before Run is called.
+type Outbox struct {
+	db *gorm.DB
+
+	mu        sync.RWMutex
+	consumers map[string]Handler
+
+	// BatchSize is how many messages one transaction leases.
+	BatchSize int
+	// MaxAttempts is how many times a failing message is retried before it
+	// is set aside as failed.
+	MaxAttempts int
+	// Backoff returns the delay before retrying after the given attempt.
+	Backoff func(attempt int) time.Duration
+	// PollInterval is how often consumers are checked without a wake-up.
+	PollInterval time.Duration
+	// Now is the clock; tests may pin it.
+	Now func() time.Time
+}
+
+// NewOutbox returns an Outbox for db and installs the callback that writes
+// its messages whenever an event is logged. Consumers must be registered
+// before the first write they should see.
+func NewOutbox(db *gorm.DB) (*Outbox, error) {
+	o := &Outbox{
+		db:           db,
+		consumers:    make(map[string]Handler),
+		BatchSize:    100,
+		MaxAttempts:  10,
+		Backoff:      outboxBackoff,
+		PollInterval: 5 * time.Second,
+		Now:          time.Now,
+	}
+	err := db.Callback().Create().After("gorm:create").Before("gorm:commit_or_rollback_transaction").
+		Register("outbox:write", o.write)
+	if err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+// outboxBackoff waits attempt² seconds, capped at ten minutes.
+func outboxBackoff(attempt int) time.Duration {
+	return min(time.Duration(attempt*attempt)*time.Second, 10*time.Minute)
+}
+
+// Register adds a consumer. name identifies its messages in the table and
+// must stay stable across deploys.
+func (o *Outbox) Register(name string, h Handler) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.consumers[name] = h
+}
+
+func (o *Outbox) names() []string {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	names := make([]string, 0, len(o.consumers))
+	for name := range o.consumers {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// write queues a message per consumer for each event the statement logged,
+// in the statement's own transaction.
+func (o *Outbox) write(db *gorm.DB) {
+	if db.Error != nil || db.Statement.Schema == nil || db.Statement.Table != "events" {
+		return
+	}
+	names := o.names()
+	if len(names) == 0 {
+		return
+	}
+	now := o.Now()
+	var msgs []models.OutboxMessage
+	EachRow(db.Statement.ReflectValue, func(row reflect.Value) {
+		if e, ok := row.Interface().(models.Event); ok && e.ID != 0 {
+			for _, name := range names {
+				msgs = append(msgs, models.OutboxMessage{Consumer: name, EventID: e.ID, AvailableAt: now})
+			}
+		}
+	})
+	if len(msgs) == 0 {
+		return
+	}
+	err := db.Session(&gorm.Session{NewDB: true}).Omit(clause.Associations).Create(&msgs).Error
+	if err != nil {
+		db.AddError(fmt.Errorf("outbox: %w", err))
+	}
+}
+
+// Run processes every consumer's messages until ctx is cancelled, waking on
+// each event published on bus and otherwise every PollInterval.
+func (o *Outbox) Run(ctx context.Context, bus Bus) {
+	live, unsubscribe := bus.Subscribe()
+	defer func() { unsubscribe() }()
+	ticker := time.NewTicker(o.PollInterval)
+	defer ticker.Stop()
+	for {
+		for _, name := range o.names() {
+			for {
+				n, err := o.Process(ctx, name)
+				if err != nil {
+					slog.Error("outbox: processing failed", "consumer", name, "err", err)
+					break
+				}
+				if n < o.BatchSize || ctx.Err() != nil {
+					break
+				}
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case _, ok := <-live:
+			if !ok {
+				live, unsubscribe = bus.Subscribe()
+			}
+		case <-ticker.C:
+		}
+	}
+}
+
+// Process leases one batch of available messages for consumer, runs its
+// handler on each and records the outcome, all in one transaction. It
+// returns how many messages it leased. A failing handler only rolls back
+// its own message's work: it is retried after Backoff, and set aside as
+// failed after MaxAttempts.
+func (o *Outbox) Process(ctx context.Context, consumer string) (int, error) {
+	o.mu.RLock()
+	handle, ok := o.consumers[consumer]
+	o.mu.RUnlock()
+	if !ok {
+		return 0, fmt.Errorf("outbox: unknown consumer %q", consumer)
+	}
+
+	var leased int
+	err := o.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var msgs []models.OutboxMessage
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("consumer = ? AND processed_at IS NULL AND failed_at IS NULL AND available_at <= ?", consumer, o.Now()).
+			Order("id").
+			Limit(o.BatchSize).
+			Find(&msgs).Error
+		if err != nil || len(msgs) == 0 {
+			return err
+		}
+		leased = len(msgs)
+
+		ids := make([]int64, len(msgs))
+		for i, m := range msgs {
+			ids[i] = m.EventID
+		}
+		var rows []models.Event
+		if err := tx.Where("id IN ?", ids).Find(&rows).Error; err != nil {
+			return err
+		}
+		byID := make(map[int64]Event, len(rows))
+		for _, r := range rows {
+			byID[r.ID] = FromModel(r)
+		}
+
+		for _, m := range msgs {
+			if err := tx.SavePoint("outbox_message").Error; err != nil {
+				return err
+			}
+			e, found := byID[m.EventID]
+			herr := errors.New("event not found")
+			if found {
+				herr = handle(ctx, tx, e)
+			}
+			updates := map[string]any{"attempts": m.Attempts + 1}
+			now := o.Now()
+			switch {
+			case herr == nil:
+				updates["processed_at"] = now
+			default:
+				if err := tx.RollbackTo("outbox_message").Error; err != nil {
+					return err
+				}
+				updates["last_error"] = herr.Error()
+				if m.Attempts+1 >= o.MaxAttempts {
+					updates["failed_at"] = now
+				} else {
+					updates["available_at"] = now.Add(o.Backoff(m.Attempts + 1))
+				}
+				slog.Warn("outbox: handler failed", "consumer", consumer, "event_id", m.EventID, "attempt", m.Attempts+1, "err", herr)
+			}
+			if err := tx.Model(&models.OutboxMessage{}).Where("id = ?", m.ID).Updates(updates).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return leased, err
+}
@@ -0,0 +1,165 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"gorm.io/gorm"
+)
+
+// Channel is the Postgres NOTIFY channel PGBus signals on.
+const Channel = "workout_tracker_events"
+
+var (
+	// pollInterval bounds how long a missed notification can delay events.
+	pollInterval = 30 * time.Second
+	// Reconnect delays double from minBackoff up to maxBackoff.
+	minBackoff = time.Second
+	maxBackoff = 30 * time.Second
+)
+
+// Lookback is how far behind the newest delivered ID each catch-up reads
+// again. IDs are assigned at insert but become visible at commit, so a
+// slow transaction can commit an event below one already delivered.
+const Lookback = 100
+
+// catchUpBatch bounds how many logged events one catch-up query reads.
+const catchUpBatch = 500
+
+// PGBus is a Bus shared by every instance connected to the same database.
+//
+// Publish sends a NOTIFY carrying only the event ID. Each instance's
+// listener (Run) treats a notification as a wake-up: it reads everything
+// new from the events table and fans it out to local subscribers. Reading
+// from the log rather than trusting notification payloads means a dropped
+// connection or a lost NOTIFY only delays events until the next reconnect
+// or poll.
+type PGBus struct {
+	db    *gorm.DB
+	dsn   string
+	local *MemoryBus
+
+	mu        sync.Mutex
+	started   bool
+	floor     int64 // end of the log when the bus started
+	lastID    int64
+	delivered map[int64]struct{}
+}
+
+func NewPGBus(db *gorm.DB, dsn string) *PGBus {
+	return &PGBus{db: db, dsn: dsn, local: NewMemoryBus(), delivered: make(map[int64]struct{})}
+}
+
+func (b *PGBus) Publish(e Event) {
+	if err := b.db.Exec("SELECT pg_notify(?, ?)", Channel, strconv.FormatInt(e.ID, 10)).Error; err != nil {
+		// Listeners still pick the event up at their next poll.
+		slog.Error("event bus: notify failed", "event_id", e.ID, "err", err)
+	}
+}
+
+func (b *PGBus) Subscribe() (<-chan Event, func()) {
+	return b.local.Subscribe()
+}
+
+// Run listens for notifications until ctx is cancelled, reconnecting with
+// backoff whenever the connection drops and catching up on the log after
+// every reconnect.
+func (b *PGBus) Run(ctx context.Context) {
+	backoff := minBackoff
+	for {
+		connected, err := b.listen(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if connected {
+			backoff = minBackoff
+		}
+		slog.Warn("event bus: listener disconnected", "err", err, "retry_in", backoff)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, maxBackoff)
+	}
+}
+
+// listen holds one LISTEN connection until it fails, reporting whether it
+// got as far as listening.
+func (b *PGBus) listen(ctx context.Context) (bool, error) {
+	conn, err := pgx.Connect(ctx, b.dsn)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close(context.Background())
+
+	if _, err := conn.Exec(ctx, "LISTEN "+pgx.Identifier{Channel}.Sanitize()); err != nil {
+		return false, err
+	}
+	// Anything committed while we were not listening is only in the log.
+	if err := b.CatchUp(); err != nil {
+		return true, err
+	}
+	for {
+		waitCtx, cancel := context.WithTimeout(ctx, pollInterval)
+		_, err := conn.WaitForNotification(waitCtx)
+		cancel()
+		if err != nil {
+			if ctx.Err() != nil || conn.IsClosed() || !errors.Is(err, context.DeadlineExceeded) {
+				return true, err
+			}
+			// Poll timeout: fall through and check the log anyway.
+		}
+		if err := b.CatchUp(); err != nil {
+			return true, err
+		}
+	}
+}
+
+// CatchUp reads events logged since the last delivered one and publishes
+// them to local subscribers. The first call only records where the log
+// currently ends, so a freshly started instance does not replay history.
+func (b *PGBus) CatchUp() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.started {
+		if err := b.db.Raw("SELECT COALESCE(MAX(id), 0) FROM events").Scan(&b.floor).Error; err != nil {
+			return err
+		}
+		b.lastID = b.floor
+		b.started = true
+		return nil
+	}
+
+	after := max(b.lastID-Lookback, b.floor)
+	for {
+		batch, err := Since(b.db, after, catchUpBatch)
+		if err != nil {
+			return err
+		}
+		for _, e := range batch {
+			after = e.ID
+			if _, ok := b.delivered[e.ID]; ok {
+				continue
+			}
+			b.delivered[e.ID] = struct{}{}
+			b.lastID = max(b.lastID, e.ID)
+			b.local.Publish(e)
+		}
+		if len(batch) < catchUpBatch {
+			break
+		}
+	}
+	for id := range b.delivered {
+		if id <= b.lastID-Lookback {
+			delete(b.delivered, id)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,481 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"workout-tracker/backend/attachments"
+	"workout-tracker/backend/blob"
+	"workout-tracker/backend/models"
+	"workout-tracker/backend/schemas"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// maxFilenameLen bounds the filenames kept for attachments, in bytes.
+	maxFilenameLen = 255
+	// formOverhead is what an upload's body may hold beyond the file
+	// itself: part boundaries and headers.
+	formOverhead = 64 << 10
+	// multipartMaxMemory is how much of a parsed form is kept in memory
+	// before the rest spills to disk, as in Huma's adapters.
+	multipartMaxMemory = 8 << 20
+)
+
+// AttachmentHandler stores files users attach to their workouts. Uploads
+// go to the blob store and their metadata to the database; downloads go
+// through signed URLs that expire, so they work without an Authorization
+// header.
+type AttachmentHandler struct {
+	db     *gorm.DB
+	store  blob.Store
+	signer *attachments.Signer
+	limits attachments.Limits
+	// Now returns the current time; tests replace it.
+	Now func() time.Time
+}
+
+func NewAttachmentHandler(db *gorm.DB, store blob.Store, signer *attachments.Signer, limits attachments.Limits) *AttachmentHandler {
+	return &AttachmentHandler{db: db, store: store, signer: signer, limits: limits, Now: time.Now}
+}
+
+func (h *AttachmentHandler) RegisterRoutes(api huma.API) {
+	v1_0 := huma.NewGroup(api, "/api/v1")
+	huma.Post(v1_0, "/workouts/{workoutId}/attachments", h.UploadAttachment, limitUpload(v1_0, h.limits.MaxSize+formOverhead))
+	huma.Get(v1_0, "/workouts/{workoutId}/attachments", h.ListAttachments)
+	huma.Get(v1_0, "/attachments/{attachmentId}", h.GetAttachment)
+	huma.Delete(v1_0, "/attachments/{attachmentId}", h.DeleteAttachment)
+	huma.Get(v1_0, "/me/attachments/usage", h.GetUsage)
+
+	// Download URLs are opened by <img> and <video> tags, which send no
+	// token, so their signature is what authorizes them.
+	huma.Register(api, huma.Operation{
+		OperationID: "download-attachment",
+		Method:      http.MethodGet,
+		Path:        "/files/attachments/{attachmentId}",
+		Summary:     "Download an attachment through a signed URL",
+	}, h.DownloadAttachment)
+}
+
+// limitUpload refuses multipart bodies longer than limit with a 413 while
+// they are read, before any of them is spooled to disk, rather than after
+// the whole form has been parsed.
+func limitUpload(api huma.API, limit int64) func(*huma.Operation) {
+	return func(op *huma.Operation) {
+		op.Middlewares = append(op.Middlewares, func(ctx huma.Context, next func(huma.Context)) {
+			tooLarge := func() {
+				_ = huma.WriteErr(api, ctx, http.StatusRequestEntityTooLarge, "request body is larger than "+formatBytes(limit))
+			}
+			if n, err := strconv.ParseInt(ctx.Header("Content-Length"), 10, 64); err == nil && n > limit {
+				tooLarge()
+				return
+			}
+			r := &http.Request{
+				Method: ctx.Method(),
+				Header: http.Header{"Content-Type": {ctx.Header("Content-Type")}},
+				Body:   http.MaxBytesReader(nil, io.NopCloser(ctx.BodyReader()), limit),
+			}
+			err := r.ParseMultipartForm(multipartMaxMemory)
+			if r.MultipartForm != nil {
+				defer r.MultipartForm.RemoveAll()
+			}
+			var maxErr *http.MaxBytesError
+			if errors.As(err, &maxErr) {
+				tooLarge()
+				return
+			}
+			next(&parsedForm{humaContext: ctx, form: r.MultipartForm, err: err})
+		})
+	}
+}
+
+// humaContext lets parsedForm embed huma.Context, whose Context method
+// would otherwise clash with the embedded field's name.
+type humaContext = huma.Context
+
+// parsedForm is a huma.Context whose multipart form has already been read.
+type parsedForm struct {
+	humaContext
+	form *multipart.Form
+	err  error
+}
+
+func (c *parsedForm) GetMultipartForm() (*multipart.Form, error) {
+	return c.form, c.err
+}
+
+// visibleWorkout loads a workout the caller is allowed to see, answering
+// 404 for both missing and hidden workouts.
+func (h *AttachmentHandler) visibleWorkout(ctx context.Context, q *gorm.DB) (*models.Workout, error) {
+	var w models.Workout
+	if err := q.First(&w).Error; err != nil {
+		return nil, huma.NewError(http.StatusNotFound, "workout not found")
+	}
+	viewerID, err := resolveUserID(ctx, h.db)
+	if err != nil {
+		return nil, huma.Error500InternalServerError("failed to resolve user")
+	}
+	visible, err := canViewWorkout(h.db, w, viewerID)
+	if err != nil {
+		return nil, huma.Error500InternalServerError("failed to check visibility")
+	}
+	if !visible {
+		return nil, huma.NewError(http.StatusNotFound, "workout not found")
+	}
+	return &w, nil
+}
+
+// UploadAttachment stores the uploaded file, and a thumbnail when it is a
+// photo, then records it against the caller's quota. Files are stored
+// before their row is written and deleted again if it can't be, so a
+// failed upload leaves nothing behind.
+func (h *AttachmentHandler) UploadAttachment(ctx context.Context, input *schemas.UploadAttachmentInput) (*schemas.UploadAttachmentOutput, error) {
+	userID, err := callerID(ctx, h.db, input.UserID)
+	if err != nil {
+		return nil, err
+	}
+	w, err := h.visibleWorkout(ctx, h.db.Where("public_id = ?", input.WorkoutID))
+	if err != nil {
+		return nil, err
+	}
+	if w.UserID != userID {
+		return nil, huma.NewError(http.StatusForbidden, "only the workout's owner can attach files")
+	}
+
+	f := input.RawBody.Data().File
+	defer f.Close()
+	if f.Size == 0 {
+		return nil, huma.Error422UnprocessableEntity("file is empty")
+	}
+	if f.Size > h.limits.MaxSize {
+		return nil, huma.NewError(http.StatusRequestEntityTooLarge, "file is larger than "+formatBytes(h.limits.MaxSize))
+	}
+
+	a := models.Attachment{
+		WorkoutID: w.ID,
+		UserID:    userID,
+		Filename:  cleanFilename(f.Filename),
+		Size:      f.Size,
+	}
+	if a.PublicID, err = uuid.NewV7(); err != nil {
+		return nil, huma.Error500InternalServerError("failed to store attachment")
+	}
+	if a.ContentType, err = sniffContentType(f, f.ContentType); err != nil {
+		return nil, huma.Error500InternalServerError("failed to read upload")
+	}
+	a.Kind = attachmentKind(a.ContentType)
+
+	var thumb []byte
+	if a.Kind == models.AttachmentKindPhoto {
+		// Formats the standard library can't decode, and images too large
+		// to, are kept without a thumbnail.
+		thumb, a.Width, a.Height, err = attachments.Thumbnail(f)
+		if err != nil {
+			slog.InfoContext(ctx, "attachments: no thumbnail", "content_type", a.ContentType, "err", err)
+			thumb = nil
+		}
+	}
+
+	a.StorageKey = attachments.StorageKey(a.PublicID)
+	if err := h.store.Put(ctx, a.StorageKey, f, a.Size, a.ContentType); err != nil {
+		slog.ErrorContext(ctx, "attachments: storing file failed", "key", a.StorageKey, "err", err)
+		return nil, huma.Error500InternalServerError("failed to store attachment")
+	}
+	if thumb != nil {
+		a.ThumbnailKey = attachments.ThumbnailKey(a.PublicID)
+		a.ThumbnailSize = int64(len(thumb))
+		if err := h.store.Put(ctx, a.ThumbnailKey, bytes.NewReader(thumb), a.ThumbnailSize, "image/jpeg"); err != nil {
+			h.removeBlobs(ctx, a)
+			slog.ErrorContext(ctx, "attachments: storing thumbnail failed", "key", a.ThumbnailKey, "err", err)
+			return nil, huma.Error500InternalServerError("failed to store attachment")
+		}
+	}
+
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		// Locking the owner serializes their uploads, so concurrent ones
+		// can't each fit the quota on their own and exceed it together.
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&models.User{}, userID).Error; err != nil {
+			return err
+		}
+		used, err := h.usage(tx, userID)
+		if err != nil {
+			return err
+		}
+		if used.Used+a.Size+a.ThumbnailSize > h.limits.Quota {
+			return huma.NewError(http.StatusForbidden, "storage quota of "+formatBytes(h.limits.Quota)+" exceeded")
+		}
+		return tx.Omit("User").Create(&a).Error
+	})
+	if err != nil {
+		h.removeBlobs(ctx, a)
+		var se huma.StatusError
+		if errors.As(err, &se) {
+			return nil, se
+		}
+		return nil, huma.Error500InternalServerError("failed to store attachment")
+	}
+	r := h.toResponse(a, w.PublicID)
+	return &schemas.UploadAttachmentOutput{Status: http.StatusCreated, Body: &r}, nil
+}
+
+// removeBlobs deletes the files of an attachment that was not recorded.
+// It runs even when the request was cancelled, which is often why.
+func (h *AttachmentHandler) removeBlobs(ctx context.Context, a models.Attachment) {
+	ctx = context.WithoutCancel(ctx)
+	for _, key := range []string{a.StorageKey, a.ThumbnailKey} {
+		if key == "" {
+			continue
+		}
+		if err := h.store.Delete(ctx, key); err != nil {
+			slog.WarnContext(ctx, "attachments: removing orphaned blob failed", "key", key, "err", err)
+		}
+	}
+}
+
+func (h *AttachmentHandler) ListAttachments(ctx context.Context, input *schemas.ListAttachmentsInput) (*schemas.ListAttachmentsOutput, error) {
+	w, err := h.visibleWorkout(ctx, h.db.Where("public_id = ?", input.WorkoutID))
+	if err != nil {
+		return nil, err
+	}
+	var list []models.Attachment
+	if err := h.db.Where("workout_id = ?", w.ID).Order("created_at, id").Find(&list).Error; err != nil {
+		return nil, huma.Error500InternalServerError("failed to fetch attachments")
+	}
+	out := &schemas.ListAttachmentsOutput{Body: make([]schemas.AttachmentResponse, len(list))}
+	for i, a := range list {
+		out.Body[i] = h.toResponse(a, w.PublicID)
+	}
+	return out, nil
+}
+
+// attachment loads an attachment on a workout the caller can see.
+func (h *AttachmentHandler) attachment(ctx context.Context, id uuid.UUID) (*models.Attachment, *models.Workout, error) {
+	var a models.Attachment
+	if err := h.db.Where("public_id = ?", id).First(&a).Error; err != nil {
+		return nil, nil, huma.NewError(http.StatusNotFound, "attachment not found")
+	}
+	w, err := h.visibleWorkout(ctx, h.db.Where("id = ?", a.WorkoutID))
+	if err != nil {
+		var se huma.StatusError
+		if errors.As(err, &se) && se.GetStatus() == http.StatusNotFound {
+			return nil, nil, huma.NewError(http.StatusNotFound, "attachment not found")
+		}
+		return nil, nil, err
+	}
+	return &a, w, nil
+}
+
+func (h *AttachmentHandler) GetAttachment(ctx context.Context, input *schemas.GetAttachmentInput) (*schemas.GetAttachmentOutput, error) {
+	a, w, err := h.attachment(ctx, input.AttachmentID)
+	if err != nil {
+		return nil, err
+	}
+	r := h.toResponse(*a, w.PublicID)
+	return &schemas.GetAttachmentOutput{Body: &r}, nil
+}
+
+// DeleteAttachment removes an attachment from its workout and frees its
+// quota at once. Its files are deleted by the attachments sweep; until
+// then, URLs already handed out stop working.
+func (h *AttachmentHandler) DeleteAttachment(ctx context.Context, input *schemas.GetAttachmentInput) (*struct{}, error) {
+	a, _, err := h.attachment(ctx, input.AttachmentID)
+	if err != nil {
+		return nil, err
+	}
+	userID, err := resolveUserID(ctx, h.db)
+	if err != nil {
+		return nil, huma.Error500InternalServerError("failed to resolve user")
+	}
+	if userID != 0 && userID != a.UserID {
+		return nil, huma.NewError(http.StatusForbidden, "not allowed to delete this attachment")
+	}
+	if err := h.db.Delete(a).Error; err != nil {
+		return nil, huma.Error500InternalServerError("failed to delete attachment")
+	}
+	return nil, nil
+}
+
+// GetUsage reports how much of their quota the caller's attachments take
+// up. Attachments of workouts in the trash count until they are purged.
+func (h *AttachmentHandler) GetUsage(ctx context.Context, input *schemas.AttachmentUsageInput) (*schemas.AttachmentUsageOutput, error) {
+	userID, err := callerID(ctx, h.db, input.UserID)
+	if err != nil {
+		return nil, err
+	}
+	used, err := h.usage(h.db, userID)
+	if err != nil {
+		return nil, huma.Error500InternalServerError("failed to compute usage")
+	}
+	used.Quota = h.limits.Quota
+	return &schemas.AttachmentUsageOutput{Body: used}, nil
+}
+
+func (h *AttachmentHandler) usage(db *gorm.DB, userID int64) (schemas.AttachmentUsageResponse, error) {
+	var u schemas.AttachmentUsageResponse
+	err := db.Model(&models.Attachment{}).
+		Select("COALESCE(SUM(size + COALESCE(thumbnail_size, 0)), 0) AS used, COUNT(*) AS attachments").
+		Where("user_id = ?", userID).
+		Scan(&u).Error
+	return u, err
+}
+
+// DownloadAttachment streams a variant of an attachment to whoever holds a
+// valid signed URL for it. The signature is checked before anything is
+// looked up.
+func (h *AttachmentHandler) DownloadAttachment(ctx context.Context, input *schemas.DownloadAttachmentInput) (*huma.StreamResponse, error) {
+	now := h.Now()
+	if err := h.signer.Verify(input.AttachmentID, input.Variant, input.Expires, input.Signature, now); err != nil {
+		return nil, huma.NewError(http.StatusForbidden, "invalid or expired download link")
+	}
+	var a models.Attachment
+	if err := h.db.Where("public_id = ?", input.AttachmentID).First(&a).Error; err != nil {
+		return nil, huma.NewError(http.StatusNotFound, "attachment not found")
+	}
+	key, contentType, size, filename := a.StorageKey, a.ContentType, a.Size, a.Filename
+	if input.Variant == attachments.VariantThumbnail {
+		if a.ThumbnailKey == "" {
+			return nil, huma.NewError(http.StatusNotFound, "attachment has no thumbnail")
+		}
+		key, contentType, size = a.ThumbnailKey, "image/jpeg", a.ThumbnailSize
+		filename = strings.TrimSuffix(filename, path.Ext(filename)) + "-thumbnail.jpg"
+	}
+	body, err := h.store.Get(ctx, key)
+	if errors.Is(err, blob.ErrNotFound) {
+		return nil, huma.NewError(http.StatusNotFound, "attachment not found")
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "attachments: reading blob failed", "key", key, "err", err)
+		return nil, huma.Error500InternalServerError("failed to read attachment")
+	}
+
+	disposition := "attachment"
+	if a.Kind == models.AttachmentKindPhoto || a.Kind == models.AttachmentKindVideo {
+		disposition = "inline"
+	}
+	return &huma.StreamResponse{Body: func(hctx huma.Context) {
+		defer body.Close()
+		hctx.SetHeader("Content-Type", contentType)
+		hctx.SetHeader("Content-Length", strconv.FormatInt(size, 10))
+		hctx.SetHeader("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": filename}))
+		hctx.SetHeader("X-Content-Type-Options", "nosniff")
+		// Caches may keep the file for as long as the link is valid.
+		hctx.SetHeader("Cache-Control", "private, max-age="+strconv.FormatInt(max(0, input.Expires-now.Unix()), 10))
+		hctx.SetStatus(http.StatusOK)
+		if _, err := io.Copy(hctx.BodyWriter(), body); err != nil {
+			slog.WarnContext(ctx, "attachments: download interrupted", "key", key, "err", err)
+		}
+	}}, nil
+}
+
+// toResponse describes a, with download URLs valid for
+// attachments.DefaultURLTTL.
+func (h *AttachmentHandler) toResponse(a models.Attachment, workoutID uuid.UUID) schemas.AttachmentResponse {
+	expires := h.Now().Add(attachments.DefaultURLTTL).Truncate(time.Second)
+	r := schemas.AttachmentResponse{
+		ID:          a.PublicID,
+		WorkoutID:   workoutID,
+		Kind:        a.Kind,
+		Filename:    a.Filename,
+		ContentType: a.ContentType,
+		Size:        a.Size,
+		Width:       a.Width,
+		Height:      a.Height,
+		URL:         h.downloadURL(a.PublicID, attachments.VariantOriginal, expires),
+		ExpiresAt:   expires,
+		CreatedAt:   a.CreatedAt,
+	}
+	if a.ThumbnailKey != "" {
+		r.ThumbnailURL = h.downloadURL(a.PublicID, attachments.VariantThumbnail, expires)
+	}
+	return r
+}
+
+func (h *AttachmentHandler) downloadURL(id uuid.UUID, variant string, expires time.Time) string {
+	q := url.Values{
+		"variant":   {variant},
+		"expires":   {strconv.FormatInt(expires.Unix(), 10)},
+		"signature": {h.signer.Sign(id, variant, expires)},
+	}
+	return "/files/attachments/" + id.String() + "?" + q.Encode()
+}
+
+// sniffContentType returns the content type of f judged from its first
+// bytes, and rewinds it. The type the client declared is only trusted for
+// videos the sniffer doesn't know, such as QuickTime, since any other
+// type it names could make a browser render the file as a page.
+func sniffContentType(f io.ReadSeeker, declared string) (string, error) {
+	head := make([]byte, 512)
+	n, err := io.ReadFull(f, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return "", err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	ct := http.DetectContentType(head[:n])
+	if ct == "application/octet-stream" {
+		if mt, _, err := mime.ParseMediaType(declared); err == nil && strings.HasPrefix(mt, "video/") {
+			return mt, nil
+		}
+	}
+	return ct, nil
+}
+
+func attachmentKind(contentType string) string {
+	switch {
+	case strings.HasPrefix(contentType, "image/"):
+		return models.AttachmentKindPhoto
+	case strings.HasPrefix(contentType, "video/"):
+		return models.AttachmentKindVideo
+	}
+	return models.AttachmentKindFile
+}
+
+// cleanFilename keeps the last element of the uploaded file's name,
+// without control characters, shortened to maxFilenameLen bytes.
+func cleanFilename(name string) string {
+	name = strings.ReplaceAll(name, `\`, "/")
+	name = strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f {
+			return -1
+		}
+		return r
+	}, path.Base(name))
+	for len(name) > maxFilenameLen {
+		_, size := utf8.DecodeLastRuneInString(name)
+		name = name[:len(name)-size]
+	}
+	if name == "" || name == "." || name == "/" {
+		return "file"
+	}
+	return name
+}
+
+// formatBytes renders n bytes in the largest binary unit it fills.
+func formatBytes(n int64) string {
+	for _, u := range []struct {
+		size int64
+		name string
+	}{{1 << 30, "GiB"}, {1 << 20, "MiB"}, {1 << 10, "KiB"}} {
+		if n >= u.size {
+			return strconv.FormatFloat(float64(n)/float64(u.size), 'f', -1, 64) + " " + u.name
+		}
+	}
+	return strconv.FormatInt(n, 10) + " bytes"
+}
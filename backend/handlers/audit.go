@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"workout-tracker/backend/models"
+	"workout-tracker/backend/schemas"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// AuditHandler lets admins search the audit log.
+type AuditHandler struct {
+	db *gorm.DB
+}
+
+func NewAuditHandler(db *gorm.DB) *AuditHandler {
+	return &AuditHandler{db: db}
+}
+
+func (h *AuditHandler) RegisterRoutes(api huma.API) {
+	v1_0 := huma.NewGroup(api, "/api/v1")
+	huma.Get(v1_0, "/admin/audit-logs", h.ListAuditLogs)
+}
+
+func (h *AuditHandler) ListAuditLogs(ctx context.Context, input *schemas.ListAuditLogsInput) (*schemas.ListAuditLogsOutput, error) {
+	if err := requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+	q := h.db.Model(&models.AuditLog{})
+	if input.ActorUserID != uuid.Nil {
+		q = q.Where("actor_user_id = (?)", h.db.Unscoped().Model(&models.User{}).Select("id").Where("public_id = ?", input.ActorUserID))
+	}
+	if input.Subject != "" {
+		q = q.Where("subject = ?", input.Subject)
+	}
+	if input.ResourceType != "" {
+		q = q.Where("resource_type = ?", input.ResourceType)
+	}
+	if input.ResourceID != uuid.Nil {
+		q = q.Where("resource_public_id = ?", input.ResourceID)
+	}
+	if input.Action != "" {
+		q = q.Where("action = ?", input.Action)
+	}
+	if input.RequestID != "" {
+		q = q.Where("request_id = ?", input.RequestID)
+	}
+	if !input.From.IsZero() {
+		q = q.Where("created_at >= ?", input.From)
+	}
+	if !input.To.IsZero() {
+		q = q.Where("created_at < ?", input.To)
+	}
+	if input.Cursor != "" {
+		at, publicID, err := decodeFeedCursor(input.Cursor)
+		if err != nil {
+			return nil, huma.NewError(http.StatusBadRequest, "invalid cursor")
+		}
+		id, err := lookupID(h.db, &models.AuditLog{}, publicID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, huma.NewError(http.StatusBadRequest, "invalid cursor")
+		}
+		if err != nil {
+			return nil, huma.Error500InternalServerError("failed to fetch audit log")
+		}
+		q = q.Where("(created_at, id) < (?, ?)", at, id)
+	}
+
+	var logs []models.AuditLog
+	if err := q.Order("created_at DESC, id DESC").Limit(input.Limit + 1).Find(&logs).Error; err != nil {
+		return nil, huma.Error500InternalServerError("failed to fetch audit log")
+	}
+	page := &schemas.AuditLogPage{Items: make([]schemas.AuditLogResponse, 0, len(logs))}
+	if len(logs) > input.Limit {
+		logs = logs[:input.Limit]
+		last := logs[len(logs)-1]
+		page.NextCursor = encodeFeedCursor(last.CreatedAt, last.PublicID)
+	}
+	var actorIDs []int64
+	for _, l := range logs {
+		if l.ActorUserID != nil {
+			actorIDs = append(actorIDs, *l.ActorUserID)
+		}
+	}
+	actors, err := publicIDs(h.db, &models.User{}, actorIDs...)
+	if err != nil {
+		return nil, huma.Error500InternalServerError("failed to fetch audit log")
+	}
+	for _, l := range logs {
+		page.Items = append(page.Items, schemas.AuditLogResponse{
+			ID:           l.PublicID,
+			CreatedAt:    l.CreatedAt,
+			ActorUserID:  optionalPublicID(actors, l.ActorUserID),
+			Subject:      l.Subject,
+			TokenType:    l.TokenType,
+			ClientID:     l.ClientID,
+			Action:       l.Action,
+			ResourceType: l.ResourceType,
+			ResourceID:   l.ResourcePublicID,
+			Changes:      l.Changes,
+			IP:           l.IP,
+			UserAgent:    l.UserAgent,
+			RequestID:    l.RequestID,
+		})
+	}
+	return &schemas.ListAuditLogsOutput{Body: page}, nil
+}
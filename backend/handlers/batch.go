@@ -0,0 +1,212 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"workout-tracker/backend/models"
+	"workout-tracker/backend/patch"
+	"workout-tracker/backend/schemas"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// errBatchAbandoned rolls back an atomic batch at its first failed
+// operation.
+var errBatchAbandoned = errors.New("batch abandoned")
+
+// BatchWorkouts applies up to schemas.MaxBatchOperations workout creates,
+// updates and deletes in one transaction. In atomic mode the first failure
+// rolls back the lot; in best-effort mode each operation runs in a
+// savepoint of its own, so a failure only undoes that operation. Server
+// errors abort the batch in either mode, leaving it safe to retry whole.
+func (h *WorkoutHandler) BatchWorkouts(ctx context.Context, input *schemas.BatchWorkoutsInput) (*schemas.BatchWorkoutsOutput, error) {
+	userID, err := resolveUserID(ctx, h.db)
+	if err != nil {
+		return nil, huma.Error500InternalServerError("failed to resolve user")
+	}
+	ops := input.Body.Operations
+	atomic := input.Body.Mode != schemas.BatchBestEffort
+	results := make([]schemas.WorkoutBatchResult, len(ops))
+	failed := -1
+	err = h.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for i, op := range ops {
+			var status int
+			var workout *schemas.WorkoutResponse
+			var opErr error
+			if atomic {
+				status, workout, opErr = h.batchOp(tx, userID, op)
+			} else {
+				opErr = tx.Transaction(func(sp *gorm.DB) error {
+					var err error
+					status, workout, err = h.batchOp(sp, userID, op)
+					return err
+				})
+			}
+			results[i] = batchResult(i, status, workout, opErr)
+			if results[i].Status >= http.StatusInternalServerError {
+				return opErr
+			}
+			if opErr != nil && atomic {
+				failed = i
+				return errBatchAbandoned
+			}
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errBatchAbandoned) {
+		return nil, huma.Error500InternalServerError("failed to apply batch")
+	}
+	if failed >= 0 {
+		for i := range results {
+			switch {
+			case i < failed:
+				results[i] = batchResult(i, 0, nil, huma.NewError(http.StatusFailedDependency,
+					fmt.Sprintf("rolled back: operation %d failed", failed)))
+			case i > failed:
+				results[i] = batchResult(i, 0, nil, huma.NewError(http.StatusFailedDependency,
+					fmt.Sprintf("not attempted: operation %d failed", failed)))
+			}
+		}
+	}
+
+	out := &schemas.BatchWorkoutsOutput{}
+	out.Body.Results = results
+	for _, r := range results {
+		if r.Error != nil {
+			out.Body.Failed++
+		} else {
+			out.Body.Applied++
+		}
+	}
+	return out, nil
+}
+
+// batchOp applies one operation of a batch through tx for userID (0 in
+// dev/test mode) and returns the status it would have got as a request of
+// its own, with the workout it created or updated. Errors are Huma errors.
+func (h *WorkoutHandler) batchOp(tx *gorm.DB, userID int64, op schemas.WorkoutBatchOperation) (int, *schemas.WorkoutResponse, error) {
+	if op.Op == "create" {
+		if op.Workout == nil {
+			return 0, nil, huma.Error422UnprocessableEntity("workout is required to create one")
+		}
+		ownerID := userID
+		if ownerID == 0 {
+			// Dev/test fallback: accept user_id from the workout.
+			if op.Workout.UserID == uuid.Nil {
+				return 0, nil, huma.NewError(http.StatusUnauthorized, "authentication required")
+			}
+			var err error
+			if ownerID, err = lookupUserID(tx, op.Workout.UserID); err != nil {
+				return 0, nil, err
+			}
+		}
+		workout := newWorkout(ownerID, *op.Workout)
+		r, err := createWorkout(tx, &workout, ownerID)
+		if isDuplicate(err) {
+			return 0, nil, huma.Error409Conflict("a workout with this id already exists")
+		}
+		if clientError(err) {
+			return 0, nil, err
+		}
+		if err != nil {
+			return 0, nil, huma.Error500InternalServerError("failed to create workout")
+		}
+		return http.StatusCreated, &r, nil
+	}
+
+	if op.WorkoutID == uuid.Nil {
+		return 0, nil, huma.Error422UnprocessableEntity("workout_id is required to " + op.Op + " a workout")
+	}
+	var workout models.Workout
+	if err := tx.Where("public_id = ?", op.WorkoutID).First(&workout).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, nil, huma.NewError(http.StatusNotFound, "workout not found")
+		}
+		return 0, nil, huma.Error500InternalServerError("failed to fetch workout")
+	}
+	if userID != 0 && workout.UserID != userID {
+		return 0, nil, huma.NewError(http.StatusNotFound, "workout not found")
+	}
+	if op.IfMatch != "" && strings.Trim(strings.TrimPrefix(op.IfMatch, "W/"), `"`) != versionTag(workout.Version) {
+		return 0, nil, huma.Error412PreconditionFailed("if_match precondition failed, found workout with ETag " + etag(workout.Version))
+	}
+
+	var err error
+	switch op.Op {
+	case "update":
+		if op.Patch == nil {
+			return 0, nil, huma.Error422UnprocessableEntity("patch is required to update a workout")
+		}
+		body, _ := json.Marshal(op.Patch)
+		fields, patchErr := patch.To(workoutFields(workout), patch.MergePatchType, body)
+		if patchErr != nil {
+			return 0, nil, patchErr
+		}
+		var r schemas.WorkoutResponse
+		if r, err = updateWorkout(tx, &workout, fields, userID); err == nil {
+			return http.StatusOK, &r, nil
+		}
+	case "delete":
+		err = deleteWorkout(tx, &workout, op.IfMatch != "")
+		if err == nil {
+			return http.StatusNoContent, nil, nil
+		}
+	default:
+		return 0, nil, huma.Error422UnprocessableEntity("unknown operation " + op.Op)
+	}
+	if errors.Is(err, errStale) {
+		return 0, nil, huma.Error412PreconditionFailed("workout was changed by another request; reload it and retry")
+	}
+	if clientError(err) {
+		return 0, nil, err
+	}
+	return 0, nil, huma.Error500InternalServerError("failed to " + op.Op + " workout")
+}
+
+// clientError reports whether err is a Huma error the client caused, such
+// as the 422 checkWorkoutFields answers invalid tags with, rather than a
+// server failure.
+func clientError(err error) bool {
+	var se huma.StatusError
+	return errors.As(err, &se) && se.GetStatus() < http.StatusInternalServerError
+}
+
+// batchResult reports the outcome of operation i.
+func batchResult(i, status int, workout *schemas.WorkoutResponse, err error) schemas.WorkoutBatchResult {
+	r := schemas.WorkoutBatchResult{Index: i, Status: status}
+	if err != nil {
+		r.Status = http.StatusInternalServerError
+		var se huma.StatusError
+		if errors.As(err, &se) {
+			r.Status = se.GetStatus()
+		}
+		if !errors.As(err, &r.Error) {
+			r.Error = &huma.ErrorModel{Status: r.Status, Title: http.StatusText(r.Status), Detail: err.Error()}
+		}
+		return r
+	}
+	r.Workout = workout
+	return r
+}
+
+// literalPath keeps an operation with a custom method, such as
+// "/workouts:batch", to its own path: Gin reads ":batch" as a path
+// parameter and would route "/workoutsfoo" to it as well.
+func literalPath(api huma.API) func(*huma.Operation) {
+	return func(op *huma.Operation) {
+		op.Middlewares = append(op.Middlewares, func(ctx huma.Context, next func(huma.Context)) {
+			if ctx.URL().Path != ctx.Operation().Path {
+				_ = huma.WriteErr(api, ctx, http.StatusNotFound, "no route for "+ctx.URL().Path)
+				return
+			}
+			next(ctx)
+		})
+	}
+}
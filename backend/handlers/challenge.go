@@ -0,0 +1,375 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"time"
+	_ "time/tzdata" // challenge timezones must resolve even on minimal images
+
+	"workout-tracker/backend/challenges"
+	"workout-tracker/backend/middleware"
+	"workout-tracker/backend/models"
+	"workout-tracker/backend/roles"
+	"workout-tracker/backend/schemas"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/danielgtaylor/huma/v2/sse"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// standingsPollInterval is how often the standings stream recomputes.
+var standingsPollInterval = 5 * time.Second
+
+type ChallengeHandler struct {
+	db *gorm.DB
+}
+
+func NewChallengeHandler(db *gorm.DB) *ChallengeHandler {
+	return &ChallengeHandler{db: db}
+}
+
+func (h *ChallengeHandler) RegisterRoutes(api huma.API) {
+	v1_0 := huma.NewGroup(api, "/api/v1")
+	huma.Get(v1_0, "/challenges", h.ListChallenges)
+	huma.Post(v1_0, "/challenges", h.CreateChallenge)
+	huma.Get(v1_0, "/challenges/{challengeId}", h.GetChallenge)
+	huma.Post(v1_0, "/challenges/{challengeId}/join", h.JoinChallenge)
+	huma.Delete(v1_0, "/challenges/{challengeId}/join", h.LeaveChallenge)
+	huma.Get(v1_0, "/challenges/{challengeId}/standings", h.GetStandings)
+	sse.Register(v1_0, huma.Operation{
+		OperationID: "stream-challenge-standings",
+		Method:      http.MethodGet,
+		Path:        "/challenges/{challengeId}/standings/stream",
+		Summary:     "Stream challenge standings",
+		Description: "Sends the standings immediately and again whenever they change. The stream ends after the final results are sent.",
+	}, map[string]any{"standings": schemas.ChallengeStandings{}}, h.StreamStandings)
+}
+
+// requireMembership checks that userID belongs to orgID, optionally with one
+// of roles. userID 0 (dev/test mode) always passes.
+func (h *ChallengeHandler) requireMembership(orgID, userID int64, roles ...string) error {
+	if userID == 0 {
+		return nil
+	}
+	var m models.Membership
+	if err := h.db.Where("organization_id = ? AND user_id = ?", orgID, userID).First(&m).Error; err != nil {
+		return huma.NewError(http.StatusForbidden, "organization membership required")
+	}
+	if len(roles) > 0 && !slices.Contains(roles, m.Role) {
+		return huma.NewError(http.StatusForbidden, "insufficient organization role")
+	}
+	return nil
+}
+
+func (h *ChallengeHandler) ListChallenges(ctx context.Context, input *schemas.ListChallengesInput) (*schemas.ListChallengesOutput, error) {
+	q := h.db.Order("starts_at DESC")
+	if middleware.GetAuth(ctx) != nil {
+		// Organizations' challenges are listed to their members only.
+		userID, err := callerID(ctx, h.db, uuid.Nil)
+		if err != nil {
+			return nil, err
+		}
+		q = q.Where("organization_id IS NULL OR organization_id IN (?)",
+			h.db.Model(&models.Membership{}).Select("organization_id").Where("user_id = ?", userID))
+	}
+	if input.OrganizationID != uuid.Nil {
+		q = q.Where("organization_id = (?)",
+			h.db.Model(&models.Organization{}).Select("id").Where("public_id = ?", input.OrganizationID))
+	}
+	now := time.Now()
+	switch input.Status {
+	case "upcoming":
+		q = q.Where("starts_at > ?", now)
+	case "active":
+		q = q.Where("starts_at <= ? AND ends_at > ?", now, now)
+	case "ended":
+		q = q.Where("ends_at <= ?", now)
+	}
+
+	var list []models.Challenge
+	if err := q.Find(&list).Error; err != nil {
+		return nil, huma.Error500InternalServerError("failed to fetch challenges")
+	}
+	body, err := challengeResponses(h.db, list...)
+	if err != nil {
+		return nil, huma.Error500InternalServerError("failed to fetch challenges")
+	}
+	return &schemas.ListChallengesOutput{Body: body}, nil
+}
+
+func (h *ChallengeHandler) CreateChallenge(ctx context.Context, input *schemas.CreateChallengeInput) (*schemas.CreateChallengeOutput, error) {
+	userID, err := callerID(ctx, h.db, input.Body.UserID)
+	if err != nil {
+		return nil, err
+	}
+	var orgID *int64
+	if input.Body.OrganizationID != nil {
+		id, err := lookupID(h.db, &models.Organization{}, *input.Body.OrganizationID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, huma.NewError(http.StatusNotFound, "organization not found")
+		}
+		if err != nil {
+			return nil, huma.Error500InternalServerError("failed to fetch organization")
+		}
+		orgID = &id
+	}
+	if authCtx := middleware.GetAuth(ctx); authCtx != nil {
+		// Organizations run their own challenges; site-wide ones are for admins.
+		if orgID != nil {
+			err := h.requireMembership(*orgID, userID, models.MembershipRoleOwner, models.MembershipRoleCoach)
+			if err != nil {
+				return nil, err
+			}
+		} else if !authCtx.IsGrantedRole(roles.Admin) {
+			return nil, huma.NewError(http.StatusForbidden, "admin role required for site-wide challenges")
+		}
+	}
+
+	loc, err := time.LoadLocation(input.Body.Timezone)
+	if err != nil {
+		return nil, huma.NewError(http.StatusUnprocessableEntity, "unknown timezone "+input.Body.Timezone)
+	}
+	start, err := time.ParseInLocation(time.DateOnly, input.Body.StartDate, loc)
+	if err != nil {
+		return nil, huma.NewError(http.StatusUnprocessableEntity, "invalid start_date")
+	}
+	end, err := time.ParseInLocation(time.DateOnly, input.Body.EndDate, loc)
+	if err != nil {
+		return nil, huma.NewError(http.StatusUnprocessableEntity, "invalid end_date")
+	}
+	if end.Before(start) {
+		return nil, huma.NewError(http.StatusUnprocessableEntity, "end_date must not be before start_date")
+	}
+
+	c := models.Challenge{
+		BaseModel:      models.BaseModel{PublicID: input.Body.ID},
+		OrganizationID: orgID,
+		CreatedByID:    userID,
+		Name:           input.Body.Name,
+		Description:    input.Body.Description,
+		Metric:         input.Body.Metric,
+		Timezone:       loc.String(),
+		StartsAt:       start.UTC(),
+		// end_date is inclusive: the window closes at the following local midnight.
+		EndsAt:       end.AddDate(0, 0, 1).UTC(),
+		LateLogHours: input.Body.LateLogHours,
+	}
+	if err := h.db.Omit("Organization", "CreatedBy").Create(&c).Error; err != nil {
+		if isDuplicate(err) {
+			return nil, huma.NewError(http.StatusConflict, "a challenge with this id already exists")
+		}
+		return nil, huma.Error500InternalServerError("failed to create challenge")
+	}
+	r, err := challengeResponses(h.db, c)
+	if err != nil {
+		return nil, huma.Error500InternalServerError("failed to fetch challenge")
+	}
+	return &schemas.CreateChallengeOutput{Status: 201, Body: &r[0]}, nil
+}
+
+func (h *ChallengeHandler) GetChallenge(ctx context.Context, input *schemas.GetChallengeInput) (*schemas.GetChallengeOutput, error) {
+	c, err := h.visibleChallenge(ctx, input.ChallengeID)
+	if err != nil {
+		return nil, err
+	}
+	r, err := challengeResponses(h.db, *c)
+	if err != nil {
+		return nil, huma.Error500InternalServerError("failed to fetch challenge")
+	}
+	return &schemas.GetChallengeOutput{Body: &r[0]}, nil
+}
+
+func (h *ChallengeHandler) JoinChallenge(ctx context.Context, input *schemas.JoinChallengeInput) (*struct{}, error) {
+	userID, err := callerID(ctx, h.db, input.Body.UserID)
+	if err != nil {
+		return nil, err
+	}
+	var c models.Challenge
+	if err := h.db.Where("public_id = ?", input.ChallengeID).First(&c).Error; err != nil {
+		return nil, huma.NewError(http.StatusNotFound, "challenge not found")
+	}
+	if !time.Now().Before(c.EndsAt) {
+		return nil, huma.NewError(http.StatusConflict, "challenge has ended")
+	}
+	if c.OrganizationID != nil && middleware.GetAuth(ctx) != nil {
+		if err := h.requireMembership(*c.OrganizationID, userID); err != nil {
+			return nil, err
+		}
+	}
+
+	p := models.ChallengeParticipant{ChallengeID: c.ID, UserID: userID}
+	err = h.db.Where(models.ChallengeParticipant{ChallengeID: c.ID, UserID: userID}).
+		FirstOrCreate(&p).Error
+	if err != nil {
+		return nil, huma.Error500InternalServerError("failed to join challenge")
+	}
+	return nil, nil
+}
+
+func (h *ChallengeHandler) LeaveChallenge(ctx context.Context, input *schemas.LeaveChallengeInput) (*struct{}, error) {
+	userID, err := callerID(ctx, h.db, input.UserID)
+	if err != nil {
+		return nil, err
+	}
+	var c models.Challenge
+	if err := h.db.Where("public_id = ?", input.ChallengeID).First(&c).Error; err != nil {
+		return nil, huma.NewError(http.StatusNotFound, "challenge not found")
+	}
+	if !time.Now().Before(c.EndsAt) {
+		return nil, huma.NewError(http.StatusConflict, "challenge has ended")
+	}
+	err = h.db.Unscoped().
+		Where("challenge_id = ? AND user_id = ?", c.ID, userID).
+		Delete(&models.ChallengeParticipant{}).Error
+	if err != nil {
+		return nil, huma.Error500InternalServerError("failed to leave challenge")
+	}
+	return nil, nil
+}
+
+// visibleChallenge loads a challenge the caller may see. An organization's
+// challenge and its standings are for its members only, as is joining it;
+// to anyone else it is not found, like any other challenge they may not
+// see.
+func (h *ChallengeHandler) visibleChallenge(ctx context.Context, challengeID uuid.UUID) (*models.Challenge, error) {
+	var c models.Challenge
+	if err := h.db.Where("public_id = ?", challengeID).First(&c).Error; err != nil {
+		return nil, huma.NewError(http.StatusNotFound, "challenge not found")
+	}
+	if c.OrganizationID != nil && middleware.GetAuth(ctx) != nil {
+		userID, err := callerID(ctx, h.db, uuid.Nil)
+		if err != nil {
+			return nil, err
+		}
+		if err := h.requireMembership(*c.OrganizationID, userID); err != nil {
+			return nil, huma.NewError(http.StatusNotFound, "challenge not found")
+		}
+	}
+	return &c, nil
+}
+
+// standings returns the current standings for c, freezing them first if
+// the late-log grace period has passed.
+func (h *ChallengeHandler) standings(c *models.Challenge) (*schemas.ChallengeStandings, error) {
+	now := time.Now()
+	if _, err := challenges.Finalize(h.db, c, now); err != nil {
+		return nil, huma.Error500InternalServerError("failed to finalize challenge")
+	}
+
+	var list []challenges.Standing
+	var err error
+	if c.FinalizedAt != nil {
+		list, err = challenges.FrozenStandings(h.db, *c)
+		now = *c.FinalizedAt
+	} else {
+		list, err = challenges.Standings(h.db, *c)
+	}
+	if err != nil {
+		return nil, huma.Error500InternalServerError("failed to compute standings")
+	}
+
+	body := &schemas.ChallengeStandings{
+		ChallengeID: c.PublicID,
+		Metric:      c.Metric,
+		Final:       c.FinalizedAt != nil,
+		AsOf:        now,
+		Entries:     make([]schemas.StandingEntry, len(list)),
+	}
+	for i, s := range list {
+		body.Entries[i] = schemas.StandingEntry{Rank: s.Rank, UserID: s.UserPublicID, Name: s.Name, Value: s.Value}
+	}
+	return body, nil
+}
+
+func (h *ChallengeHandler) GetStandings(ctx context.Context, input *schemas.GetChallengeInput) (*schemas.ChallengeStandingsOutput, error) {
+	c, err := h.visibleChallenge(ctx, input.ChallengeID)
+	if err != nil {
+		return nil, err
+	}
+	body, err := h.standings(c)
+	if err != nil {
+		return nil, err
+	}
+	return &schemas.ChallengeStandingsOutput{Body: body}, nil
+}
+
+// StreamStandings polls the standings and pushes them whenever the ranking
+// changes, ending the stream once the final results have been sent. Callers
+// who may not see the standings get an empty stream.
+func (h *ChallengeHandler) StreamStandings(ctx context.Context, input *schemas.GetChallengeInput, send sse.Sender) {
+	c, err := h.visibleChallenge(ctx, input.ChallengeID)
+	if err != nil {
+		return
+	}
+	ticker := time.NewTicker(standingsPollInterval)
+	defer ticker.Stop()
+
+	var last []schemas.StandingEntry
+	for {
+		body, err := h.standings(c)
+		if err != nil {
+			return
+		}
+		if last == nil || !slices.Equal(last, body.Entries) {
+			if err := send.Data(*body); err != nil {
+				return
+			}
+			last = body.Entries
+		}
+		if body.Final {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// challengeResponses maps challenges to responses, looking up the public IDs
+// of their organizations and creators.
+func challengeResponses(db *gorm.DB, list ...models.Challenge) ([]schemas.ChallengeResponse, error) {
+	orgIDs := make([]int64, 0, len(list))
+	creatorIDs := make([]int64, len(list))
+	for i, c := range list {
+		if c.OrganizationID != nil {
+			orgIDs = append(orgIDs, *c.OrganizationID)
+		}
+		creatorIDs[i] = c.CreatedByID
+	}
+	orgs, err := publicIDs(db, &models.Organization{}, orgIDs...)
+	if err != nil {
+		return nil, err
+	}
+	creators, err := publicIDs(db, &models.User{}, creatorIDs...)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]schemas.ChallengeResponse, len(list))
+	for i, c := range list {
+		out[i] = challengeToResponse(c, optionalPublicID(orgs, c.OrganizationID), creators[c.CreatedByID])
+	}
+	return out, nil
+}
+
+func challengeToResponse(c models.Challenge, orgID *uuid.UUID, createdByID uuid.UUID) schemas.ChallengeResponse {
+	return schemas.ChallengeResponse{
+		ID:             c.PublicID,
+		OrganizationID: orgID,
+		CreatedByID:    createdByID,
+		Name:           c.Name,
+		Description:    c.Description,
+		Metric:         c.Metric,
+		Timezone:       c.Timezone,
+		StartsAt:       c.StartsAt,
+		EndsAt:         c.EndsAt,
+		LateLogHours:   c.LateLogHours,
+		FreezesAt:      c.FreezesAt(),
+		FinalizedAt:    c.FinalizedAt,
+		CreatedAt:      c.CreatedAt,
+	}
+}
@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"workout-tracker/backend/events"
+	"workout-tracker/backend/models"
+	"workout-tracker/backend/schemas"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/danielgtaylor/huma/v2/sse"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// replayBatch bounds how many logged events one replay query reads.
+const replayBatch = 500
+
+type EventHandler struct {
+	db  *gorm.DB
+	bus events.Bus
+}
+
+func NewEventHandler(db *gorm.DB, bus events.Bus) *EventHandler {
+	return &EventHandler{db: db, bus: bus}
+}
+
+func (h *EventHandler) RegisterRoutes(api huma.API) {
+	v1_0 := huma.NewGroup(api, "/api/v1")
+	sse.Register(v1_0, huma.Operation{
+		OperationID: "stream-events",
+		Method:      http.MethodGet,
+		Path:        "/events",
+		Summary:     "Stream workout changes",
+		Description: "Pushes created/updated/deleted events for workouts the caller may see. " +
+			"Reconnecting with Last-Event-ID replays everything missed since that event.",
+	}, map[string]any{
+		events.TypeWorkoutCreated: schemas.WorkoutCreatedEvent{},
+		events.TypeWorkoutUpdated: schemas.WorkoutUpdatedEvent{},
+		events.TypeWorkoutDeleted: schemas.WorkoutDeletedEvent{},
+	}, h.StreamEvents)
+}
+
+// StreamEvents subscribes to live events first and only then replays the
+// log after the client's last seen ID, so nothing committed in between is
+// lost. IDs are assigned at insert but become visible at commit, so an
+// event can arrive after one with a higher ID; like PGBus.CatchUp, the
+// stream remembers what it sent within events.Lookback of the newest ID
+// and only skips those, rather than everything below the newest.
+func (h *EventHandler) StreamEvents(ctx context.Context, input *schemas.StreamEventsInput, send sse.Sender) {
+	viewerID, err := resolveUserID(ctx, h.db)
+	if err != nil {
+		slog.ErrorContext(ctx, "event stream: failed to resolve user", "err", err)
+		return
+	}
+	if viewerID == 0 && input.UserID != uuid.Nil {
+		// Dev/test fallback: honour the query-param viewer.
+		if viewerID, err = lookupID(h.db, &models.User{}, input.UserID); err != nil {
+			slog.ErrorContext(ctx, "event stream: failed to resolve viewer", "err", err)
+			return
+		}
+	}
+
+	live, unsubscribe := h.bus.Subscribe()
+	defer unsubscribe()
+
+	// The client has seen everything up to floor; newest is the highest ID
+	// sent since.
+	floor := max(input.LastEventID, input.After)
+	newest := floor
+	delivered := make(map[int64]struct{})
+	deliver := func(e events.Event) error {
+		if e.ID <= max(floor, newest-events.Lookback) {
+			return nil
+		}
+		if _, ok := delivered[e.ID]; ok {
+			return nil
+		}
+		if err := h.send(send, e, viewerID); err != nil {
+			return err
+		}
+		delivered[e.ID] = struct{}{}
+		if e.ID > newest {
+			newest = e.ID
+			for id := range delivered {
+				if id <= newest-events.Lookback {
+					delete(delivered, id)
+				}
+			}
+		}
+		return nil
+	}
+
+	if floor > 0 {
+		after := floor
+		for {
+			batch, err := events.Since(h.db, after, replayBatch)
+			if err != nil {
+				slog.ErrorContext(ctx, "event stream: replay failed", "err", err)
+				return
+			}
+			for _, e := range batch {
+				if err := deliver(e); err != nil {
+					return
+				}
+				after = e.ID
+			}
+			if len(batch) < replayBatch {
+				break
+			}
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case e, ok := <-live:
+			if !ok {
+				// Dropped for falling behind; the client reconnects and
+				// resumes from the log.
+				return
+			}
+			if err := deliver(e); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// send writes e to the stream if it is a workout event and viewerID may see
+// the workout it concerns.
+func (h *EventHandler) send(send sse.Sender, e events.Event, viewerID int64) error {
+	if e.ResourceType != events.ResourceWorkout {
+		return nil
+	}
+	visible, err := canViewWorkout(h.db, models.Workout{UserID: e.UserID, Visibility: e.Visibility}, viewerID)
+	if err != nil || !visible {
+		return err
+	}
+
+	data := schemas.WorkoutEvent{EventID: e.ID, WorkoutID: e.ResourcePublicID, OccurredAt: e.CreatedAt}
+	if err := json.Unmarshal(e.Payload, &data.Workout); err != nil {
+		return err
+	}
+	var msg any
+	switch e.Type {
+	case events.TypeWorkoutCreated:
+		msg = schemas.WorkoutCreatedEvent(data)
+	case events.TypeWorkoutUpdated:
+		msg = schemas.WorkoutUpdatedEvent(data)
+	case events.TypeWorkoutDeleted:
+		msg = schemas.WorkoutDeletedEvent(data)
+	default:
+		return nil
+	}
+	return send(sse.Message{ID: int(e.ID), Data: msg})
+}
@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+
+	"workout-tracker/backend/training"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/google/uuid"
+)
+
+// maxExercises and maxGroups bound the exercises and groups of one
+// workout.
+const (
+	maxExercises = 50
+	maxGroups    = 50
+)
+
+// minMembers is how many exercises or nested groups a group of each type
+// needs. Timed groups may hold a single exercise.
+var minMembers = map[string]int{
+	training.GroupSuperset: 2,
+	training.GroupGiantSet: 3,
+	training.GroupCircuit:  2,
+}
+
+var tempoPattern = regexp.MustCompile(`^([0-9]{1,2}|X)(-([0-9]{1,2}|X)){3}$`)
+
+// normalizeExercises trims the names and notes of exercises, gives new
+// exercises an ID and checks what the API schema can't: that each set
+// records reps or a duration, gives RPE or RIR but not both, and combines
+// its flags sensibly. Tempos are stored with an upper-case X. The result
+// is never nil.
+func normalizeExercises(exercises []training.Exercise) ([]training.Exercise, error) {
+	if len(exercises) > maxExercises {
+		return nil, huma.Error422UnprocessableEntity(fmt.Sprintf("a workout can have at most %d exercises", maxExercises))
+	}
+	out := make([]training.Exercise, len(exercises))
+	seen := make(map[uuid.UUID]bool, len(exercises))
+	for i, e := range exercises {
+		e.Name = strings.TrimSpace(e.Name)
+		e.Notes = strings.TrimSpace(e.Notes)
+		if e.Name == "" {
+			return nil, huma.Error422UnprocessableEntity(fmt.Sprintf("exercise %d: name must not be blank", i+1))
+		}
+		if e.ID == uuid.Nil {
+			id, err := uuid.NewV7()
+			if err != nil {
+				return nil, err
+			}
+			e.ID = id
+		}
+		if seen[e.ID] {
+			return nil, huma.Error422UnprocessableEntity(fmt.Sprintf("exercise %d: duplicate id %s", i+1, e.ID))
+		}
+		seen[e.ID] = true
+
+		sets := make([]training.Set, len(e.Sets))
+		for j, s := range e.Sets {
+			if err := checkSet(&s, j); err != nil {
+				return nil, huma.Error422UnprocessableEntity(fmt.Sprintf("exercise %d (%s), set %d: %s", i+1, e.Name, j+1, err))
+			}
+			sets[j] = s
+		}
+		e.Sets = sets
+		out[i] = e
+	}
+	return out, nil
+}
+
+// checkSet normalizes s, the index'th set of its exercise, and returns
+// what is wrong with it.
+func checkSet(s *training.Set, index int) error {
+	s.Notes = strings.TrimSpace(s.Notes)
+	s.Tempo = strings.ToUpper(strings.TrimSpace(s.Tempo))
+	switch {
+	case s.Reps == nil && s.DurationSeconds == nil:
+		return fmt.Errorf("record reps or a duration")
+	case s.RPE != nil && s.RIR != nil:
+		return fmt.Errorf("give RPE or RIR, not both")
+	case s.RPE != nil && (*s.RPE < 1 || *s.RPE > 10 || math.Mod(*s.RPE*2, 1) != 0):
+		return fmt.Errorf("RPE must be between 1 and 10 in steps of 0.5")
+	case s.Tempo != "" && !tempoPattern.MatchString(s.Tempo):
+		return fmt.Errorf("tempo must be four phases such as 3-1-1-0")
+	case s.Warmup && (s.DropSet || s.Failure):
+		return fmt.Errorf("a warm-up set can't be a drop set or taken to failure")
+	case s.DropSet && index == 0:
+		return fmt.Errorf("the first set can't be a drop set")
+	}
+	return nil
+}
+
+// normalizeGroups checks groups against exercises, the normalized
+// exercises of the same workout: that they form a tree as
+// training.Tree requires, and that each group has what its type needs.
+// Tabatas and EMOMs left without timings get the standard ones. The
+// result is never nil.
+func normalizeGroups(groups []training.Group, exercises []training.Exercise) ([]training.Group, error) {
+	if len(groups) > maxGroups {
+		return nil, huma.Error422UnprocessableEntity(fmt.Sprintf("a workout can have at most %d groups", maxGroups))
+	}
+	out := make([]training.Group, len(groups))
+	for i, g := range groups {
+		g.Name = strings.TrimSpace(g.Name)
+		if g.ID == uuid.Nil {
+			return nil, huma.Error422UnprocessableEntity(fmt.Sprintf("group %d: id is required", i+1))
+		}
+		if err := checkGroup(&g); err != nil {
+			return nil, huma.Error422UnprocessableEntity(fmt.Sprintf("group %d (%s): %s", i+1, g.Type, err))
+		}
+		out[i] = g
+	}
+	tree, err := training.Tree(out, exercises)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	if err := checkMembers(tree); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	return out, nil
+}
+
+// checkGroup normalizes the timings of g and returns what is wrong with
+// them.
+func checkGroup(g *training.Group) error {
+	switch g.Type {
+	case training.GroupEMOM:
+		if g.Rounds == nil {
+			return fmt.Errorf("give the number of intervals as rounds")
+		}
+		if g.RestSeconds != nil || g.DurationSeconds != nil {
+			return fmt.Errorf("an EMOM rests within its intervals and has no time cap")
+		}
+		defaultTo(&g.WorkSeconds, 60)
+	case training.GroupAMRAP:
+		if g.DurationSeconds == nil {
+			return fmt.Errorf("give the time cap as duration_seconds")
+		}
+		if g.WorkSeconds != nil || g.RestSeconds != nil {
+			return fmt.Errorf("an AMRAP has no work or rest periods")
+		}
+	case training.GroupTabata:
+		if g.DurationSeconds != nil {
+			return fmt.Errorf("a Tabata has no time cap")
+		}
+		defaultTo(&g.Rounds, 8)
+		defaultTo(&g.WorkSeconds, 20)
+		defaultTo(&g.RestSeconds, 10)
+	default:
+		if g.WorkSeconds != nil || g.DurationSeconds != nil {
+			return fmt.Errorf("work_seconds and duration_seconds are for EMOMs, AMRAPs and Tabatas")
+		}
+	}
+	return nil
+}
+
+// checkMembers checks that every group in nodes holds as many exercises or
+// nested groups as its type needs.
+func checkMembers(nodes []training.Node) error {
+	for _, n := range nodes {
+		if n.Group == nil {
+			continue
+		}
+		if need := minMembers[n.Group.Type]; len(n.Children) < need {
+			return fmt.Errorf("group %s: a %s needs at least %d exercises", n.Group.ID, strings.ReplaceAll(n.Group.Type, "_", " "), need)
+		}
+		if err := checkMembers(n.Children); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// defaultTo sets *field to v when it is unset.
+func defaultTo(field **int, v int) {
+	if *field == nil {
+		*field = &v
+	}
+}
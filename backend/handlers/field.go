@@ -0,0 +1,382 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"workout-tracker/backend/models"
+	"workout-tracker/backend/patch"
+	"workout-tracker/backend/schemas"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Limits on the tags and custom field values of a workout.
+const (
+	maxTags         = 20
+	maxTagLength    = 32
+	maxFieldTextLen = 500
+)
+
+// FieldHandler manages the custom fields users record on workouts, and
+// lists the tags they have used.
+type FieldHandler struct {
+	db *gorm.DB
+}
+
+func NewFieldHandler(db *gorm.DB) *FieldHandler {
+	return &FieldHandler{db: db}
+}
+
+func (h *FieldHandler) RegisterRoutes(api huma.API) {
+	v1_0 := huma.NewGroup(api, "/api/v1")
+	huma.Get(v1_0, "/custom-fields", h.ListCustomFields)
+	huma.Post(v1_0, "/custom-fields", h.CreateCustomField)
+	huma.Get(v1_0, "/custom-fields/{fieldId}", h.GetCustomField)
+	huma.Patch(v1_0, "/custom-fields/{fieldId}", h.UpdateCustomField, patch.Operation[schemas.CustomFieldFields](v1_0))
+	huma.Delete(v1_0, "/custom-fields/{fieldId}", h.DeleteCustomField)
+	huma.Get(v1_0, "/tags", h.ListTags)
+}
+
+// authorize loads a custom field the caller owns, answering 404 for
+// other users'. Every caller passes in dev/test mode.
+func (h *FieldHandler) authorize(ctx context.Context, fieldID uuid.UUID) (*models.CustomField, error) {
+	var f models.CustomField
+	if err := h.db.Where("public_id = ?", fieldID).First(&f).Error; err != nil {
+		return nil, huma.NewError(http.StatusNotFound, "custom field not found")
+	}
+	userID, err := resolveUserID(ctx, h.db)
+	if err != nil {
+		return nil, huma.Error500InternalServerError("failed to resolve user")
+	}
+	if userID != 0 && userID != f.UserID {
+		return nil, huma.NewError(http.StatusNotFound, "custom field not found")
+	}
+	return &f, nil
+}
+
+func (h *FieldHandler) ListCustomFields(ctx context.Context, input *schemas.ListCustomFieldsInput) (*schemas.ListCustomFieldsOutput, error) {
+	userID, err := callerID(ctx, h.db, input.UserID)
+	if err != nil {
+		return nil, err
+	}
+	var list []models.CustomField
+	if err := h.db.Where("user_id = ?", userID).Order("key").Find(&list).Error; err != nil {
+		return nil, huma.Error500InternalServerError("failed to fetch custom fields")
+	}
+	out := &schemas.ListCustomFieldsOutput{Body: make([]schemas.CustomFieldResponse, len(list))}
+	for i, f := range list {
+		out.Body[i] = customFieldToResponse(f)
+	}
+	return out, nil
+}
+
+func (h *FieldHandler) CreateCustomField(ctx context.Context, input *schemas.CreateCustomFieldInput) (*schemas.CreateCustomFieldOutput, error) {
+	userID, err := callerID(ctx, h.db, input.Body.UserID)
+	if err != nil {
+		return nil, err
+	}
+	f := models.CustomField{
+		BaseModel: models.BaseModel{PublicID: input.Body.ID},
+		UserID:    userID,
+		Key:       input.Body.Key,
+		Name:      strings.TrimSpace(input.Body.Name),
+		Type:      input.Body.Type,
+		Options:   input.Body.Options,
+		Unit:      strings.TrimSpace(input.Body.Unit),
+	}
+	if err := checkFieldOptions(&f); err != nil {
+		return nil, err
+	}
+	if err := h.db.Omit("User").Create(&f).Error; err != nil {
+		if isDuplicate(err) {
+			return nil, huma.NewError(http.StatusConflict, "a custom field with this key or id already exists")
+		}
+		return nil, huma.Error500InternalServerError("failed to create custom field")
+	}
+	r := customFieldToResponse(f)
+	return &schemas.CreateCustomFieldOutput{Status: 201, Body: &r}, nil
+}
+
+func (h *FieldHandler) GetCustomField(ctx context.Context, input *schemas.GetCustomFieldInput) (*schemas.GetCustomFieldOutput, error) {
+	f, err := h.authorize(ctx, input.FieldID)
+	if err != nil {
+		return nil, err
+	}
+	r := customFieldToResponse(*f)
+	return &schemas.GetCustomFieldOutput{Body: &r}, nil
+}
+
+func (h *FieldHandler) UpdateCustomField(ctx context.Context, input *schemas.UpdateCustomFieldInput) (*schemas.GetCustomFieldOutput, error) {
+	f, err := h.authorize(ctx, input.FieldID)
+	if err != nil {
+		return nil, err
+	}
+	options := f.Options
+	if options == nil {
+		options = []string{}
+	}
+	fields, err := patch.To(schemas.CustomFieldFields{Name: f.Name, Options: options, Unit: f.Unit}, input.ContentType, input.Body)
+	if err != nil {
+		return nil, err
+	}
+	f.Name = strings.TrimSpace(fields.Name)
+	f.Unit = strings.TrimSpace(fields.Unit)
+	f.Options = fields.Options
+	if err := checkFieldOptions(f); err != nil {
+		return nil, err
+	}
+	if err := h.db.Omit("User").Save(f).Error; err != nil {
+		return nil, huma.Error500InternalServerError("failed to update custom field")
+	}
+	r := customFieldToResponse(*f)
+	return &schemas.GetCustomFieldOutput{Body: &r}, nil
+}
+
+// DeleteCustomField deletes a field definition. Values already recorded
+// on workouts are kept, and the key can be reused.
+func (h *FieldHandler) DeleteCustomField(ctx context.Context, input *schemas.GetCustomFieldInput) (*struct{}, error) {
+	f, err := h.authorize(ctx, input.FieldID)
+	if err != nil {
+		return nil, err
+	}
+	if err := h.db.Delete(f).Error; err != nil {
+		return nil, huma.Error500InternalServerError("failed to delete custom field")
+	}
+	return nil, nil
+}
+
+// ListTags lists the tags on the caller's workouts, most used first.
+func (h *FieldHandler) ListTags(ctx context.Context, input *schemas.ListTagsInput) (*schemas.ListTagsOutput, error) {
+	userID, err := callerID(ctx, h.db, input.UserID)
+	if err != nil {
+		return nil, err
+	}
+	out := &schemas.ListTagsOutput{Body: []schemas.TagResponse{}}
+	err = h.db.Raw(`SELECT tag, COUNT(*) AS workouts
+FROM workouts, jsonb_array_elements_text(workouts.tags) AS tag
+WHERE workouts.user_id = ? AND workouts.deleted_at IS NULL
+GROUP BY tag
+ORDER BY workouts DESC, tag`, userID).Scan(&out.Body).Error
+	if err != nil {
+		return nil, huma.Error500InternalServerError("failed to fetch tags")
+	}
+	return out, nil
+}
+
+// checkFieldOptions tidies the options of f, which only enum fields have
+// and enum fields need.
+func checkFieldOptions(f *models.CustomField) error {
+	if f.Name == "" {
+		return huma.Error422UnprocessableEntity("name must not be blank")
+	}
+	if f.Type != models.FieldTypeEnum {
+		if len(f.Options) > 0 {
+			return huma.Error422UnprocessableEntity("only enum fields have options")
+		}
+		f.Options = nil
+		return nil
+	}
+	var options []string
+	for _, o := range f.Options {
+		if o = strings.TrimSpace(o); o != "" && !slices.Contains(options, o) {
+			options = append(options, o)
+		}
+	}
+	if len(options) == 0 {
+		return huma.Error422UnprocessableEntity("enum fields need at least one option")
+	}
+	f.Options = options
+	return nil
+}
+
+func customFieldToResponse(f models.CustomField) schemas.CustomFieldResponse {
+	return schemas.CustomFieldResponse{
+		ID:        f.PublicID,
+		Key:       f.Key,
+		Name:      f.Name,
+		Type:      f.Type,
+		Options:   f.Options,
+		Unit:      f.Unit,
+		CreatedAt: f.CreatedAt,
+		UpdatedAt: f.UpdatedAt,
+	}
+}
+
+// normalizeTags trims and lower-cases tags, dropping duplicates, so the
+// same label always matches itself. The result is sorted and never nil.
+func normalizeTags(tags []string) ([]string, error) {
+	out := []string{}
+	for _, t := range tags {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if t == "" {
+			return nil, huma.Error422UnprocessableEntity("tags must not be blank")
+		}
+		if utf8.RuneCountInString(t) > maxTagLength {
+			return nil, huma.Error422UnprocessableEntity(fmt.Sprintf("tag %q is longer than %d characters", t, maxTagLength))
+		}
+		out = append(out, t)
+	}
+	slices.Sort(out)
+	out = slices.Compact(out)
+	if len(out) > maxTags {
+		return nil, huma.Error422UnprocessableEntity(fmt.Sprintf("a workout can have at most %d tags", maxTags))
+	}
+	return out, nil
+}
+
+// customFields loads userID's field definitions by key.
+func customFields(db *gorm.DB, userID int64) (map[string]models.CustomField, error) {
+	var list []models.CustomField
+	if err := db.Where("user_id = ?", userID).Find(&list).Error; err != nil {
+		return nil, err
+	}
+	out := make(map[string]models.CustomField, len(list))
+	for _, f := range list {
+		out[f.Key] = f
+	}
+	return out, nil
+}
+
+// checkWorkoutFields normalizes the tags, exercises and groups of workout
+// and checks its custom field values against its owner's field definitions.
+// Values unchanged from prev, what the workout held before, are let be, so
+// a workout keeps values of fields since deleted or changed.
+func checkWorkoutFields(tx *gorm.DB, workout *models.Workout, prev map[string]any) error {
+	tags, err := normalizeTags(workout.Tags)
+	if err != nil {
+		return err
+	}
+	workout.Tags = tags
+	if workout.Exercises, err = normalizeExercises(workout.Exercises); err != nil {
+		return err
+	}
+	if workout.Groups, err = normalizeGroups(workout.Groups, workout.Exercises); err != nil {
+		return err
+	}
+
+	values := make(map[string]any, len(workout.CustomFields))
+	for k, v := range workout.CustomFields {
+		// A null value, such as a JSON Merge Patch leaves, removes it.
+		if v != nil {
+			values[k] = v
+		}
+	}
+	workout.CustomFields = values
+	var defs map[string]models.CustomField
+	for key, v := range values {
+		if old, ok := prev[key]; ok && reflect.DeepEqual(old, v) {
+			continue
+		}
+		if defs == nil {
+			if defs, err = customFields(tx, workout.UserID); err != nil {
+				return err
+			}
+		}
+		def, ok := defs[key]
+		if !ok {
+			return huma.Error422UnprocessableEntity(fmt.Sprintf("unknown custom field %q", key))
+		}
+		if err := checkFieldValue(def, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkFieldValue checks that v, decoded from JSON, is a valid value of
+// field def.
+func checkFieldValue(def models.CustomField, v any) error {
+	ok := false
+	switch def.Type {
+	case models.FieldTypeNumber:
+		_, ok = v.(float64)
+	case models.FieldTypeBoolean:
+		_, ok = v.(bool)
+	case models.FieldTypeText:
+		var s string
+		if s, ok = v.(string); ok && utf8.RuneCountInString(s) > maxFieldTextLen {
+			return huma.Error422UnprocessableEntity(fmt.Sprintf("custom field %q is longer than %d characters", def.Key, maxFieldTextLen))
+		}
+	case models.FieldTypeEnum:
+		s, isString := v.(string)
+		if isString && !slices.Contains(def.Options, s) {
+			return huma.Error422UnprocessableEntity(fmt.Sprintf("custom field %q must be one of %s", def.Key, strings.Join(def.Options, ", ")))
+		}
+		ok = isString
+	}
+	if !ok {
+		return huma.Error422UnprocessableEntity(fmt.Sprintf("custom field %q must be a %s", def.Key, def.Type))
+	}
+	return nil
+}
+
+// Operators of custom field filters; the ordering ones only apply to
+// number fields.
+var fieldFilterOps = map[string]string{
+	"eq": "=", "ne": "<>", "lt": "<", "lte": "<=", "gt": ">", "gte": ">=",
+}
+
+// filterByFields narrows q, a query of userID's workouts, to those whose
+// custom fields match every filter, each written key:op:value.
+func filterByFields(db, q *gorm.DB, userID int64, filters []string) (*gorm.DB, error) {
+	defs, err := customFields(db, userID)
+	if err != nil {
+		return nil, huma.Error500InternalServerError("failed to fetch custom fields")
+	}
+	for _, filter := range filters {
+		key, rest, _ := strings.Cut(filter, ":")
+		op, raw, found := strings.Cut(rest, ":")
+		sqlOp, known := fieldFilterOps[op]
+		if !found || !known {
+			return nil, huma.Error422UnprocessableEntity(fmt.Sprintf("field filter %q must be key:op:value, op one of eq, ne, lt, lte, gt, gte", filter))
+		}
+		def, ok := defs[key]
+		if !ok {
+			return nil, huma.Error422UnprocessableEntity(fmt.Sprintf("unknown custom field %q", key))
+		}
+		var v any = raw
+		switch def.Type {
+		case models.FieldTypeNumber:
+			n, err := strconv.ParseFloat(raw, 64)
+			if err != nil {
+				return nil, huma.Error422UnprocessableEntity(fmt.Sprintf("custom field %q must be a number", key))
+			}
+			v = n
+		case models.FieldTypeBoolean:
+			b, err := strconv.ParseBool(raw)
+			if err != nil {
+				return nil, huma.Error422UnprocessableEntity(fmt.Sprintf("custom field %q must be a boolean", key))
+			}
+			v = b
+		}
+		if def.Type == models.FieldTypeNumber && op != "eq" && op != "ne" {
+			// Values recorded under a key that has since been reused for
+			// another type are skipped rather than failing the cast.
+			q = q.Where("CASE WHEN jsonb_typeof(custom_fields -> ?) = 'number' THEN (custom_fields ->> ?)::numeric END "+sqlOp+" ?", key, key, v)
+			continue
+		}
+		if op != "eq" && op != "ne" {
+			return nil, huma.Error422UnprocessableEntity(fmt.Sprintf("%s only compares number fields", op))
+		}
+		doc, err := json.Marshal(map[string]any{key: v})
+		if err != nil {
+			return nil, huma.Error500InternalServerError("failed to filter workouts")
+		}
+		if op == "eq" {
+			q = q.Where("custom_fields @> ?", string(doc))
+		} else {
+			q = q.Where("custom_fields -> ? IS NOT NULL AND NOT custom_fields @> ?", key, string(doc))
+		}
+	}
+	return q, nil
+}
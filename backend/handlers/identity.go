@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"workout-tracker/backend/audit"
+	"workout-tracker/backend/middleware"
+	"workout-tracker/backend/models"
+	"workout-tracker/backend/roles"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// resolveUserID returns the local user.ID for the current request.
+// When Zitadel auth is active it finds or auto-creates a local User from the
+// token claims, and the membership of their claimed organization with it,
+// so membership checks see it from the first request on. In dev/test mode
+// (no auth context) it returns 0 so callers can fall back to a user_id
+// supplied in the request body. The user is recorded as the request's
+// actor in the audit log.
+func resolveUserID(ctx context.Context, db *gorm.DB) (int64, error) {
+	info := middleware.GetUserInfo(ctx)
+	if info == nil {
+		return 0, nil
+	}
+
+	var user models.User
+	db = db.WithContext(ctx)
+	err := db.Where("zitadel_id = ?", info.ZitadelID).First(&user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		err = provisionUser(db, info, &user)
+	}
+	if err != nil {
+		return 0, err
+	}
+	if err := syncClaimedOrganization(db, info, user.ID); err != nil {
+		return 0, err
+	}
+	audit.SetUserID(ctx, user.ID)
+	return user.ID, nil
+}
+
+// provisionUser creates the local user for the first request from a
+// Zitadel user.
+func provisionUser(db *gorm.DB, info *middleware.UserInfo, user *models.User) error {
+	email := info.Email
+	if email == "" {
+		email = info.ZitadelID + "@zitadel.local"
+	}
+	name := info.Username
+	if name == "" {
+		name = info.ZitadelID
+	}
+	*user = models.User{ZitadelID: &info.ZitadelID, Email: email, Name: name}
+	return db.Create(user).Error
+}
+
+// syncClaimedOrganization maps the caller's Zitadel organization claim onto a
+// local Organization and Membership, creating either on first sight.
+func syncClaimedOrganization(db *gorm.DB, info *middleware.UserInfo, userID int64) error {
+	if info.OrgID == "" {
+		return nil
+	}
+	name := info.OrgName
+	if name == "" {
+		name = info.OrgID
+	}
+
+	var org models.Organization
+	err := db.Where(models.Organization{ZitadelOrgID: &info.OrgID}).
+		Attrs(models.Organization{Name: name}).
+		FirstOrCreate(&org).Error
+	if err != nil {
+		return err
+	}
+	var m models.Membership
+	return db.Where(models.Membership{OrganizationID: org.ID, UserID: userID}).
+		Attrs(models.Membership{Role: models.MembershipRoleMember}).
+		FirstOrCreate(&m).Error
+}
+
+// callerID resolves the current user like resolveUserID, falling back to
+// the user with public ID devUserID (taken from the request in dev/test
+// mode). It returns a 401 error when neither identifies a user.
+func callerID(ctx context.Context, db *gorm.DB, devUserID uuid.UUID) (int64, error) {
+	userID, err := resolveUserID(ctx, db)
+	if err != nil {
+		return 0, huma.Error500InternalServerError("failed to resolve user")
+	}
+	if userID != 0 {
+		return userID, nil
+	}
+	if devUserID == uuid.Nil {
+		return 0, huma.NewError(http.StatusUnauthorized, "authentication required")
+	}
+	if userID, err = lookupUserID(db.WithContext(ctx), devUserID); err != nil {
+		return 0, err
+	}
+	audit.SetUserID(ctx, userID)
+	return userID, nil
+}
+
+// requireAdmin answers 403 unless the caller holds the admin role. Every
+// caller passes in dev/test mode.
+func requireAdmin(ctx context.Context) error {
+	if authCtx := middleware.GetAuth(ctx); authCtx != nil && !authCtx.IsGrantedRole(roles.Admin) {
+		return huma.NewError(http.StatusForbidden, "admin role required")
+	}
+	return nil
+}
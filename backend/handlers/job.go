@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"workout-tracker/backend/models"
+	"workout-tracker/backend/schemas"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// JobHandler lets admins inspect the background job queue and deal with
+// dead jobs.
+type JobHandler struct {
+	db *gorm.DB
+}
+
+func NewJobHandler(db *gorm.DB) *JobHandler {
+	return &JobHandler{db: db}
+}
+
+func (h *JobHandler) RegisterRoutes(api huma.API) {
+	v1_0 := huma.NewGroup(api, "/api/v1")
+	huma.Get(v1_0, "/admin/queues", h.QueueStats)
+	huma.Get(v1_0, "/admin/jobs", h.ListJobs)
+	huma.Get(v1_0, "/admin/jobs/{jobId}", h.GetJob)
+	huma.Post(v1_0, "/admin/jobs/{jobId}/retry", h.RetryJob)
+	huma.Delete(v1_0, "/admin/jobs/{jobId}", h.DeleteJob)
+}
+
+func (h *JobHandler) QueueStats(ctx context.Context, _ *struct{}) (*schemas.JobStatsOutput, error) {
+	if err := requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+	var rows []struct {
+		Queue  string
+		Status string
+		Count  int64
+	}
+	err := h.db.Model(&models.Job{}).
+		Select("queue, status, COUNT(*) AS count").
+		Group("queue, status").
+		Order("queue").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, huma.Error500InternalServerError("failed to fetch queue stats")
+	}
+	out := &schemas.JobStatsOutput{Body: []schemas.JobQueueStats{}}
+	for _, r := range rows {
+		if n := len(out.Body); n == 0 || out.Body[n-1].Queue != r.Queue {
+			out.Body = append(out.Body, schemas.JobQueueStats{Queue: r.Queue})
+		}
+		s := &out.Body[len(out.Body)-1]
+		switch r.Status {
+		case models.JobStatusPending:
+			s.Pending = r.Count
+		case models.JobStatusRunning:
+			s.Running = r.Count
+		case models.JobStatusSucceeded:
+			s.Succeeded = r.Count
+		case models.JobStatusDead:
+			s.Dead = r.Count
+		}
+	}
+	return out, nil
+}
+
+func (h *JobHandler) ListJobs(ctx context.Context, input *schemas.ListJobsInput) (*schemas.ListJobsOutput, error) {
+	if err := requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+	q := h.db.Order("id DESC").Limit(input.Limit)
+	if input.Queue != "" {
+		q = q.Where("queue = ?", input.Queue)
+	}
+	if input.Kind != "" {
+		q = q.Where("kind = ?", input.Kind)
+	}
+	if input.Status != "" {
+		q = q.Where("status = ?", input.Status)
+	}
+	var list []models.Job
+	if err := q.Find(&list).Error; err != nil {
+		return nil, huma.Error500InternalServerError("failed to fetch jobs")
+	}
+	out := &schemas.ListJobsOutput{Body: make([]schemas.JobResponse, len(list))}
+	for i, j := range list {
+		out.Body[i] = jobToResponse(j)
+	}
+	return out, nil
+}
+
+func (h *JobHandler) job(ctx context.Context, jobID uuid.UUID) (*models.Job, error) {
+	if err := requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+	var j models.Job
+	if err := h.db.Where("public_id = ?", jobID).First(&j).Error; err != nil {
+		return nil, huma.NewError(http.StatusNotFound, "job not found")
+	}
+	return &j, nil
+}
+
+func (h *JobHandler) GetJob(ctx context.Context, input *schemas.GetJobInput) (*schemas.GetJobOutput, error) {
+	j, err := h.job(ctx, input.JobID)
+	if err != nil {
+		return nil, err
+	}
+	r := jobToResponse(*j)
+	return &schemas.GetJobOutput{Body: &r}, nil
+}
+
+// RetryJob runs a dead or pending job again straight away with a fresh set
+// of attempts. Its last error is kept until the next run.
+func (h *JobHandler) RetryJob(ctx context.Context, input *schemas.GetJobInput) (*schemas.GetJobOutput, error) {
+	j, err := h.job(ctx, input.JobID)
+	if err != nil {
+		return nil, err
+	}
+	res := h.db.Model(&models.Job{}).
+		Where("id = ? AND status IN ?", j.ID, []string{models.JobStatusDead, models.JobStatusPending}).
+		Updates(map[string]any{
+			"attempts":    0,
+			"finished_at": nil,
+			"run_at":      time.Now(),
+			"status":      models.JobStatusPending,
+		})
+	if res.Error != nil {
+		return nil, huma.Error500InternalServerError("failed to retry job")
+	}
+	if res.RowsAffected == 0 {
+		return nil, huma.NewError(http.StatusConflict, "only dead or pending jobs can be retried")
+	}
+	if err := h.db.First(j, j.ID).Error; err != nil {
+		return nil, huma.Error500InternalServerError("failed to fetch job")
+	}
+	r := jobToResponse(*j)
+	return &schemas.GetJobOutput{Body: &r}, nil
+}
+
+// DeleteJob cancels a pending job or discards a dead one. Running jobs
+// cannot be removed from under their worker.
+func (h *JobHandler) DeleteJob(ctx context.Context, input *schemas.GetJobInput) (*struct{}, error) {
+	j, err := h.job(ctx, input.JobID)
+	if err != nil {
+		return nil, err
+	}
+	res := h.db.Where("status <> ?", models.JobStatusRunning).Delete(&models.Job{}, j.ID)
+	if res.Error != nil {
+		return nil, huma.Error500InternalServerError("failed to delete job")
+	}
+	if res.RowsAffected == 0 {
+		return nil, huma.NewError(http.StatusConflict, "job is running")
+	}
+	return nil, nil
+}
+
+func jobToResponse(j models.Job) schemas.JobResponse {
+	return schemas.JobResponse{
+		ID:          j.PublicID,
+		Queue:       j.Queue,
+		Kind:        j.Kind,
+		Args:        j.Args,
+		Status:      j.Status,
+		RunAt:       j.RunAt,
+		Attempts:    j.Attempts,
+		MaxAttempts: j.MaxAttempts,
+		LockedUntil: j.LockedUntil,
+		LastError:   j.LastError,
+		FinishedAt:  j.FinishedAt,
+		UniqueKey:   j.UniqueKey,
+		CreatedAt:   j.CreatedAt,
+		UpdatedAt:   j.UpdatedAt,
+	}
+}
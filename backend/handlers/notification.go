@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+
+	"workout-tracker/backend/email"
+	"workout-tracker/backend/models"
+	"workout-tracker/backend/schemas"
+
+	"github.com/danielgtaylor/huma/v2"
+	"gorm.io/gorm"
+)
+
+type NotificationHandler struct {
+	db     *gorm.DB
+	signer *email.Signer
+}
+
+// NewNotificationHandler returns a handler that checks unsubscribe links
+// against signer, which must be the one the Mailer signs them with.
+func NewNotificationHandler(db *gorm.DB, signer *email.Signer) *NotificationHandler {
+	return &NotificationHandler{db: db, signer: signer}
+}
+
+func (h *NotificationHandler) RegisterRoutes(api huma.API) {
+	v1_0 := huma.NewGroup(api, "/api/v1")
+	huma.Get(v1_0, "/me/notification-preferences", h.GetPreferences)
+	huma.Put(v1_0, "/me/notification-preferences", h.UpdatePreferences)
+
+	// Unsubscribe links are opened from mail clients without a token, so
+	// they live outside /api. POST is the RFC 8058 one-click request.
+	huma.Register(api, huma.Operation{
+		OperationID: "unsubscribe-get",
+		Method:      http.MethodGet,
+		Path:        "/unsubscribe",
+		Summary:     "Unsubscribe from an email category",
+	}, h.Unsubscribe)
+	huma.Register(api, huma.Operation{
+		OperationID: "unsubscribe-post",
+		Method:      http.MethodPost,
+		Path:        "/unsubscribe",
+		Summary:     "Unsubscribe from an email category (one-click)",
+	}, h.Unsubscribe)
+}
+
+func (h *NotificationHandler) GetPreferences(ctx context.Context, input *schemas.GetNotificationPreferencesInput) (*schemas.NotificationPreferencesOutput, error) {
+	userID, err := callerID(ctx, h.db, input.UserID)
+	if err != nil {
+		return nil, err
+	}
+	prefs, err := email.Preferences(h.db, userID)
+	if err != nil {
+		return nil, huma.Error500InternalServerError("failed to fetch notification preferences")
+	}
+	return &schemas.NotificationPreferencesOutput{Body: schemas.NotificationPreferencesResponse{Email: prefs}}, nil
+}
+
+func (h *NotificationHandler) UpdatePreferences(ctx context.Context, input *schemas.UpdateNotificationPreferencesInput) (*schemas.NotificationPreferencesOutput, error) {
+	userID, err := callerID(ctx, h.db, input.Body.UserID)
+	if err != nil {
+		return nil, err
+	}
+	for category := range input.Body.Email {
+		if !slices.Contains(email.Categories, category) {
+			return nil, huma.Error422UnprocessableEntity(fmt.Sprintf("unknown notification category %q", category))
+		}
+	}
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		for _, category := range email.Categories {
+			if enabled, ok := input.Body.Email[category]; ok {
+				if err := email.SetPreference(tx, userID, category, enabled); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, huma.Error500InternalServerError("failed to save notification preferences")
+	}
+	prefs, err := email.Preferences(h.db, userID)
+	if err != nil {
+		return nil, huma.Error500InternalServerError("failed to fetch notification preferences")
+	}
+	return &schemas.NotificationPreferencesOutput{Body: schemas.NotificationPreferencesResponse{Email: prefs}}, nil
+}
+
+func (h *NotificationHandler) Unsubscribe(_ context.Context, input *schemas.UnsubscribeInput) (*schemas.UnsubscribeOutput, error) {
+	publicID, category, err := h.signer.Verify(input.Token)
+	if err != nil || !slices.Contains(email.Categories, category) {
+		return nil, huma.Error400BadRequest("invalid or expired unsubscribe link")
+	}
+	userID, err := lookupID(h.db, &models.User{}, publicID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, huma.Error400BadRequest("invalid or expired unsubscribe link")
+	}
+	if err != nil {
+		return nil, huma.Error500InternalServerError("failed to fetch user")
+	}
+	if err := email.SetPreference(h.db, userID, category, false); err != nil {
+		return nil, huma.Error500InternalServerError("failed to unsubscribe")
+	}
+	return &schemas.UnsubscribeOutput{Body: schemas.UnsubscribeResponse{Category: category, Unsubscribed: true}}, nil
+}
@@ -0,0 +1,536 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"time"
+
+	"workout-tracker/backend/email"
+	"workout-tracker/backend/models"
+	"workout-tracker/backend/patch"
+	"workout-tracker/backend/schemas"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// defaultStatsPeriod is used when an aggregate request omits ?from.
+const defaultStatsPeriod = 30 * 24 * time.Hour
+
+// leaderboardMetrics maps the public metric names onto their SQL aggregate.
+var leaderboardMetrics = map[string]string{
+	"total_minutes": "COALESCE(SUM(workouts.duration_minutes), 0)",
+	"sessions":      "COUNT(*)",
+	"active_days":   "COUNT(DISTINCT DATE(workouts.performed_at))",
+}
+
+type OrganizationHandler struct {
+	db *gorm.DB
+}
+
+func NewOrganizationHandler(db *gorm.DB) *OrganizationHandler {
+	return &OrganizationHandler{db: db}
+}
+
+func (h *OrganizationHandler) RegisterRoutes(api huma.API) {
+	v1_0 := huma.NewGroup(api, "/api/v1")
+	huma.Get(v1_0, "/organizations", h.ListOrganizations)
+	huma.Post(v1_0, "/organizations", h.CreateOrganization)
+	huma.Get(v1_0, "/organizations/{orgId}", h.GetOrganization)
+	huma.Get(v1_0, "/organizations/{orgId}/members", h.ListMembers)
+	huma.Post(v1_0, "/organizations/{orgId}/members", h.AddMember)
+	huma.Patch(v1_0, "/organizations/{orgId}/members/{userId}", h.UpdateMember, patch.Operation[schemas.MemberFields](v1_0))
+	huma.Delete(v1_0, "/organizations/{orgId}/members/{userId}", h.RemoveMember)
+	huma.Get(v1_0, "/organizations/{orgId}/templates", h.ListTemplates)
+	huma.Post(v1_0, "/organizations/{orgId}/templates", h.CreateTemplate)
+	huma.Delete(v1_0, "/organizations/{orgId}/templates/{templateId}", h.DeleteTemplate)
+	huma.Get(v1_0, "/organizations/{orgId}/stats/volume", h.TeamVolume)
+	huma.Get(v1_0, "/organizations/{orgId}/stats/attendance", h.Attendance)
+	huma.Get(v1_0, "/organizations/{orgId}/leaderboard", h.Leaderboard)
+}
+
+// authorize loads the organization and, when auth is active, the caller's
+// membership in it. If roles is non-empty the membership must hold one of
+// them. In dev/test mode (no auth context) the returned membership is nil
+// and every caller is allowed.
+func (h *OrganizationHandler) authorize(ctx context.Context, orgID uuid.UUID, roles ...string) (*models.Organization, *models.Membership, error) {
+	var org models.Organization
+	if err := h.db.Where("public_id = ?", orgID).First(&org).Error; err != nil {
+		return nil, nil, huma.NewError(http.StatusNotFound, "organization not found")
+	}
+
+	userID, err := resolveUserID(ctx, h.db)
+	if err != nil {
+		return nil, nil, huma.Error500InternalServerError("failed to resolve user")
+	}
+	if userID == 0 {
+		return &org, nil, nil
+	}
+
+	var m models.Membership
+	if err := h.db.Where("organization_id = ? AND user_id = ?", org.ID, userID).First(&m).Error; err != nil {
+		return nil, nil, huma.NewError(http.StatusForbidden, "organization membership required")
+	}
+	if len(roles) > 0 && !slices.Contains(roles, m.Role) {
+		return nil, nil, huma.NewError(http.StatusForbidden, "insufficient organization role")
+	}
+	return &org, &m, nil
+}
+
+func (h *OrganizationHandler) ListOrganizations(ctx context.Context, input *schemas.ListOrganizationsInput) (*schemas.ListOrganizationsOutput, error) {
+	userID, err := resolveUserID(ctx, h.db)
+	if err != nil {
+		return nil, huma.Error500InternalServerError("failed to resolve user")
+	}
+	if userID == 0 && input.UserID != uuid.Nil {
+		// Dev/test fallback: honour the query-param filter.
+		if userID, err = lookupUserID(h.db, input.UserID); err != nil {
+			return nil, err
+		}
+	}
+
+	if userID == 0 {
+		var orgs []models.Organization
+		if err := h.db.Find(&orgs).Error; err != nil {
+			return nil, huma.Error500InternalServerError("failed to fetch organizations")
+		}
+		out := &schemas.ListOrganizationsOutput{Body: make([]schemas.OrganizationResponse, len(orgs))}
+		for i, o := range orgs {
+			out.Body[i] = organizationToResponse(o, "")
+		}
+		return out, nil
+	}
+
+	var memberships []models.Membership
+	if err := h.db.Preload("Organization").Where("user_id = ?", userID).Find(&memberships).Error; err != nil {
+		return nil, huma.Error500InternalServerError("failed to fetch organizations")
+	}
+	out := &schemas.ListOrganizationsOutput{Body: make([]schemas.OrganizationResponse, len(memberships))}
+	for i, m := range memberships {
+		out.Body[i] = organizationToResponse(m.Organization, m.Role)
+	}
+	return out, nil
+}
+
+func (h *OrganizationHandler) CreateOrganization(ctx context.Context, input *schemas.CreateOrganizationInput) (*schemas.CreateOrganizationOutput, error) {
+	userID, err := callerID(ctx, h.db, input.Body.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	org := models.Organization{BaseModel: models.BaseModel{PublicID: input.Body.ID}, Name: input.Body.Name}
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&org).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.Membership{
+			OrganizationID: org.ID,
+			UserID:         userID,
+			Role:           models.MembershipRoleOwner,
+		}).Error
+	})
+	if err != nil {
+		if isDuplicate(err) {
+			return nil, huma.NewError(http.StatusConflict, "an organization with this id already exists")
+		}
+		return nil, huma.Error500InternalServerError("failed to create organization")
+	}
+	r := organizationToResponse(org, models.MembershipRoleOwner)
+	return &schemas.CreateOrganizationOutput{Status: 201, Body: &r}, nil
+}
+
+func (h *OrganizationHandler) GetOrganization(ctx context.Context, input *schemas.GetOrganizationInput) (*schemas.GetOrganizationOutput, error) {
+	org, m, err := h.authorize(ctx, input.OrgID)
+	if err != nil {
+		return nil, err
+	}
+	role := ""
+	if m != nil {
+		role = m.Role
+	}
+	r := organizationToResponse(*org, role)
+	return &schemas.GetOrganizationOutput{Body: &r}, nil
+}
+
+func (h *OrganizationHandler) ListMembers(ctx context.Context, input *schemas.ListMembersInput) (*schemas.ListMembersOutput, error) {
+	org, _, err := h.authorize(ctx, input.OrgID)
+	if err != nil {
+		return nil, err
+	}
+	var memberships []models.Membership
+	if err := h.db.Preload("User").Where("organization_id = ?", org.ID).Find(&memberships).Error; err != nil {
+		return nil, huma.Error500InternalServerError("failed to fetch members")
+	}
+	out := &schemas.ListMembersOutput{Body: make([]schemas.MemberResponse, len(memberships))}
+	for i, m := range memberships {
+		out.Body[i] = memberToResponse(m)
+	}
+	return out, nil
+}
+
+func (h *OrganizationHandler) AddMember(ctx context.Context, input *schemas.AddMemberInput) (*schemas.AddMemberOutput, error) {
+	org, caller, err := h.authorize(ctx, input.OrgID, models.MembershipRoleOwner, models.MembershipRoleCoach)
+	if err != nil {
+		return nil, err
+	}
+	role := input.Body.Role
+	if role == "" {
+		role = models.MembershipRoleMember
+	}
+	if caller != nil && caller.Role != models.MembershipRoleOwner && role != models.MembershipRoleMember {
+		return nil, huma.NewError(http.StatusForbidden, "only owners can grant the "+role+" role")
+	}
+
+	var user models.User
+	if err := h.db.Where("public_id = ?", input.Body.UserID).First(&user).Error; err != nil {
+		return nil, huma.NewError(http.StatusNotFound, "user not found")
+	}
+	var existing int64
+	if err := h.db.Model(&models.Membership{}).
+		Where("organization_id = ? AND user_id = ?", org.ID, user.ID).
+		Count(&existing).Error; err != nil {
+		return nil, huma.Error500InternalServerError("failed to add member")
+	}
+	if existing > 0 {
+		return nil, huma.NewError(http.StatusConflict, "user is already a member")
+	}
+
+	m := models.Membership{OrganizationID: org.ID, UserID: user.ID, User: user, Role: role}
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("User", "Organization").Create(&m).Error; err != nil {
+			return err
+		}
+		if role == models.MembershipRoleCoach {
+			return inviteCoach(tx, org, caller, m.UserID)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, huma.Error500InternalServerError("failed to add member")
+	}
+	r := memberToResponse(m)
+	return &schemas.AddMemberOutput{Status: 201, Body: &r}, nil
+}
+
+func (h *OrganizationHandler) UpdateMember(ctx context.Context, input *schemas.UpdateMemberInput) (*schemas.MemberOutput, error) {
+	org, caller, err := h.authorize(ctx, input.OrgID)
+	if err != nil {
+		return nil, err
+	}
+	m, err := h.member(org.ID, input.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := patch.To(schemas.MemberFields{Role: m.Role, ShareStats: m.ShareStats}, input.ContentType, input.Body)
+	if err != nil {
+		return nil, err
+	}
+	becameCoach := false
+	if f.Role != m.Role {
+		if caller != nil && caller.Role != models.MembershipRoleOwner {
+			return nil, huma.NewError(http.StatusForbidden, "only owners can change roles")
+		}
+		if m.Role == models.MembershipRoleOwner {
+			if err := h.ensureAnotherOwner(org.ID, m.UserID); err != nil {
+				return nil, err
+			}
+		}
+		m.Role = f.Role
+		becameCoach = m.Role == models.MembershipRoleCoach
+	}
+	if f.ShareStats != m.ShareStats {
+		// Opting in to aggregates is a personal choice nobody else can make.
+		if caller != nil && caller.UserID != m.UserID {
+			return nil, huma.NewError(http.StatusForbidden, "members can only change their own share_stats")
+		}
+		m.ShareStats = f.ShareStats
+	}
+
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("User", "Organization").Save(m).Error; err != nil {
+			return err
+		}
+		if becameCoach {
+			return inviteCoach(tx, org, caller, m.UserID)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, huma.Error500InternalServerError("failed to update member")
+	}
+	r := memberToResponse(*m)
+	return &schemas.MemberOutput{Body: &r}, nil
+}
+
+// inviteCoach queues the email telling userID they were made a coach of
+// org, naming the member who did it when known.
+func inviteCoach(tx *gorm.DB, org *models.Organization, caller *models.Membership, userID int64) error {
+	data := email.CoachInviteData{OrganizationID: org.PublicID, OrganizationName: org.Name}
+	if caller != nil {
+		var inviter models.User
+		if err := tx.Select("name").First(&inviter, caller.UserID).Error; err == nil {
+			data.InvitedBy = inviter.Name
+		}
+	}
+	return email.Enqueue(tx, userID, email.TemplateCoachInvite, data)
+}
+
+func (h *OrganizationHandler) RemoveMember(ctx context.Context, input *schemas.RemoveMemberInput) (*struct{}, error) {
+	org, caller, err := h.authorize(ctx, input.OrgID)
+	if err != nil {
+		return nil, err
+	}
+	m, err := h.member(org.ID, input.UserID)
+	if err != nil {
+		return nil, err
+	}
+	// Members may leave on their own; removing others is reserved to owners.
+	if caller != nil && caller.UserID != m.UserID && caller.Role != models.MembershipRoleOwner {
+		return nil, huma.NewError(http.StatusForbidden, "only owners can remove other members")
+	}
+	if m.Role == models.MembershipRoleOwner {
+		if err := h.ensureAnotherOwner(org.ID, m.UserID); err != nil {
+			return nil, err
+		}
+	}
+	// Hard delete so the (organization_id, user_id) unique index allows
+	// re-adding the user later.
+	if err := h.db.Unscoped().Delete(m).Error; err != nil {
+		return nil, huma.Error500InternalServerError("failed to remove member")
+	}
+	return nil, nil
+}
+
+// member loads the membership in orgID of the user with public ID userID,
+// with the user.
+func (h *OrganizationHandler) member(orgID int64, userID uuid.UUID) (*models.Membership, error) {
+	var m models.Membership
+	err := h.db.Joins("User").
+		Where("memberships.organization_id = ? AND \"User\".public_id = ?", orgID, userID).
+		First(&m).Error
+	if err != nil {
+		return nil, huma.NewError(http.StatusNotFound, "member not found")
+	}
+	return &m, nil
+}
+
+// ensureAnotherOwner rejects changes that would leave the organization
+// without an owner once userID stops being one.
+func (h *OrganizationHandler) ensureAnotherOwner(orgID, userID int64) error {
+	var owners int64
+	if err := h.db.Model(&models.Membership{}).
+		Where("organization_id = ? AND role = ? AND user_id <> ?", orgID, models.MembershipRoleOwner, userID).
+		Count(&owners).Error; err != nil {
+		return huma.Error500InternalServerError("failed to count owners")
+	}
+	if owners == 0 {
+		return huma.NewError(http.StatusConflict, "organization must keep at least one owner")
+	}
+	return nil
+}
+
+func (h *OrganizationHandler) ListTemplates(ctx context.Context, input *schemas.ListTemplatesInput) (*schemas.ListTemplatesOutput, error) {
+	org, _, err := h.authorize(ctx, input.OrgID)
+	if err != nil {
+		return nil, err
+	}
+	var templates []models.WorkoutTemplate
+	if err := h.db.Where("organization_id = ?", org.ID).Order("name").Find(&templates).Error; err != nil {
+		return nil, huma.Error500InternalServerError("failed to fetch templates")
+	}
+	authorIDs := make([]int64, len(templates))
+	for i, t := range templates {
+		authorIDs[i] = t.AuthorID
+	}
+	authors, err := publicIDs(h.db, &models.User{}, authorIDs...)
+	if err != nil {
+		return nil, huma.Error500InternalServerError("failed to fetch templates")
+	}
+	out := &schemas.ListTemplatesOutput{Body: make([]schemas.TemplateResponse, len(templates))}
+	for i, t := range templates {
+		out.Body[i] = templateToResponse(t, org.PublicID, authors[t.AuthorID])
+	}
+	return out, nil
+}
+
+func (h *OrganizationHandler) CreateTemplate(ctx context.Context, input *schemas.CreateTemplateInput) (*schemas.CreateTemplateOutput, error) {
+	org, caller, err := h.authorize(ctx, input.OrgID, models.MembershipRoleOwner, models.MembershipRoleCoach)
+	if err != nil {
+		return nil, err
+	}
+	var authorID int64
+	if caller != nil {
+		authorID = caller.UserID
+	} else if input.Body.AuthorID == uuid.Nil {
+		// Dev/test fallback: author_id must come from the request body.
+		return nil, huma.NewError(http.StatusUnauthorized, "authentication required")
+	} else if authorID, err = lookupUserID(h.db, input.Body.AuthorID); err != nil {
+		return nil, err
+	}
+
+	t := models.WorkoutTemplate{
+		BaseModel:       models.BaseModel{PublicID: input.Body.ID},
+		OrganizationID:  &org.ID,
+		AuthorID:        authorID,
+		Name:            input.Body.Name,
+		Description:     input.Body.Description,
+		DurationMinutes: input.Body.DurationMinutes,
+	}
+	if err := h.db.Omit("Author", "Organization").Create(&t).Error; err != nil {
+		if isDuplicate(err) {
+			return nil, huma.NewError(http.StatusConflict, "a template with this id already exists")
+		}
+		return nil, huma.Error500InternalServerError("failed to create template")
+	}
+	author, err := publicID(h.db, &models.User{}, authorID)
+	if err != nil {
+		return nil, huma.Error500InternalServerError("failed to fetch template")
+	}
+	r := templateToResponse(t, org.PublicID, author)
+	return &schemas.CreateTemplateOutput{Status: 201, Body: &r}, nil
+}
+
+func (h *OrganizationHandler) DeleteTemplate(ctx context.Context, input *schemas.DeleteTemplateInput) (*struct{}, error) {
+	org, _, err := h.authorize(ctx, input.OrgID, models.MembershipRoleOwner, models.MembershipRoleCoach)
+	if err != nil {
+		return nil, err
+	}
+	res := h.db.Where("organization_id = ? AND public_id = ?", org.ID, input.TemplateID).Delete(&models.WorkoutTemplate{})
+	if res.Error != nil {
+		return nil, huma.Error500InternalServerError("failed to delete template")
+	}
+	if res.RowsAffected == 0 {
+		return nil, huma.NewError(http.StatusNotFound, "template not found")
+	}
+	return nil, nil
+}
+
+// sharedWorkouts returns a query over workouts in [from, to) logged by
+// members of orgID who opted in to sharing their stats.
+func (h *OrganizationHandler) sharedWorkouts(orgID int64, from, to time.Time) *gorm.DB {
+	return h.db.Model(&models.Workout{}).
+		Joins("JOIN memberships ON memberships.user_id = workouts.user_id"+
+			" AND memberships.organization_id = ? AND memberships.share_stats AND memberships.deleted_at IS NULL", orgID).
+		Where("workouts.performed_at >= ? AND workouts.performed_at < ?", from, to)
+}
+
+func (h *OrganizationHandler) TeamVolume(ctx context.Context, input *schemas.OrgStatsInput) (*schemas.TeamVolumeOutput, error) {
+	org, _, err := h.authorize(ctx, input.OrgID)
+	if err != nil {
+		return nil, err
+	}
+	from, to := periodBounds(input.PeriodParams)
+
+	var body schemas.TeamVolumeResponse
+	err = h.sharedWorkouts(org.ID, from, to).
+		Select("COUNT(DISTINCT workouts.user_id) AS members, COUNT(*) AS sessions," +
+			" COALESCE(SUM(workouts.duration_minutes), 0) AS total_minutes").
+		Scan(&body).Error
+	if err != nil {
+		return nil, huma.Error500InternalServerError("failed to compute team volume")
+	}
+	body.From, body.To = from, to
+	return &schemas.TeamVolumeOutput{Body: &body}, nil
+}
+
+func (h *OrganizationHandler) Attendance(ctx context.Context, input *schemas.OrgStatsInput) (*schemas.AttendanceOutput, error) {
+	org, _, err := h.authorize(ctx, input.OrgID)
+	if err != nil {
+		return nil, err
+	}
+	from, to := periodBounds(input.PeriodParams)
+
+	body := []schemas.AttendanceEntry{}
+	err = h.sharedWorkouts(org.ID, from, to).
+		Select("users.public_id AS user_id, users.name, COUNT(*) AS sessions," +
+			" COUNT(DISTINCT DATE(workouts.performed_at)) AS active_days").
+		Joins("JOIN users ON users.id = workouts.user_id").
+		Group("users.public_id, users.name").
+		Order("sessions DESC, users.name").
+		Scan(&body).Error
+	if err != nil {
+		return nil, huma.Error500InternalServerError("failed to compute attendance")
+	}
+	return &schemas.AttendanceOutput{Body: body}, nil
+}
+
+func (h *OrganizationHandler) Leaderboard(ctx context.Context, input *schemas.LeaderboardInput) (*schemas.LeaderboardOutput, error) {
+	org, _, err := h.authorize(ctx, input.OrgID)
+	if err != nil {
+		return nil, err
+	}
+	from, to := periodBounds(input.PeriodParams)
+	expr, ok := leaderboardMetrics[input.Metric]
+	if !ok {
+		return nil, huma.NewError(http.StatusUnprocessableEntity, "unknown metric "+input.Metric)
+	}
+
+	body := []schemas.LeaderboardEntry{}
+	err = h.sharedWorkouts(org.ID, from, to).
+		Select("users.public_id AS user_id, users.name, " + expr + " AS value").
+		Joins("JOIN users ON users.id = workouts.user_id").
+		Group("users.public_id, users.name").
+		Order("value DESC, users.name").
+		Limit(input.Limit).
+		Scan(&body).Error
+	if err != nil {
+		return nil, huma.Error500InternalServerError("failed to compute leaderboard")
+	}
+	for i := range body {
+		body[i].Rank = i + 1
+		// Ties share the rank of the first entry with that value.
+		if i > 0 && body[i].Value == body[i-1].Value {
+			body[i].Rank = body[i-1].Rank
+		}
+	}
+	return &schemas.LeaderboardOutput{Body: body}, nil
+}
+
+// periodBounds fills in the defaults for an aggregate period.
+func periodBounds(p schemas.PeriodParams) (time.Time, time.Time) {
+	to := p.To
+	if to.IsZero() {
+		to = time.Now()
+	}
+	from := p.From
+	if from.IsZero() {
+		from = to.Add(-defaultStatsPeriod)
+	}
+	return from, to
+}
+
+func organizationToResponse(o models.Organization, role string) schemas.OrganizationResponse {
+	return schemas.OrganizationResponse{
+		ID:        o.PublicID,
+		Name:      o.Name,
+		Role:      role,
+		CreatedAt: o.CreatedAt,
+		UpdatedAt: o.UpdatedAt,
+	}
+}
+
+func memberToResponse(m models.Membership) schemas.MemberResponse {
+	return schemas.MemberResponse{
+		UserID:     m.User.PublicID,
+		Name:       m.User.Name,
+		Role:       m.Role,
+		ShareStats: m.ShareStats,
+		JoinedAt:   m.CreatedAt,
+	}
+}
+
+func templateToResponse(t models.WorkoutTemplate, orgID, authorID uuid.UUID) schemas.TemplateResponse {
+	return schemas.TemplateResponse{
+		ID:              t.PublicID,
+		OrganizationID:  &orgID,
+		AuthorID:        authorID,
+		Name:            t.Name,
+		Description:     t.Description,
+		DurationMinutes: t.DurationMinutes,
+		CreatedAt:       t.CreatedAt,
+		UpdatedAt:       t.UpdatedAt,
+	}
+}
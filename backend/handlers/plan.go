@@ -0,0 +1,557 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"workout-tracker/backend/models"
+	"workout-tracker/backend/netguard"
+	"workout-tracker/backend/patch"
+	"workout-tracker/backend/planner"
+	"workout-tracker/backend/schemas"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type PlanHandler struct {
+	db *gorm.DB
+}
+
+func NewPlanHandler(db *gorm.DB) *PlanHandler {
+	return &PlanHandler{db: db}
+}
+
+func (h *PlanHandler) RegisterRoutes(api huma.API) {
+	v1_0 := huma.NewGroup(api, "/api/v1")
+	huma.Get(v1_0, "/planned-workouts", h.ListPlans)
+	huma.Post(v1_0, "/planned-workouts", h.CreatePlan)
+	huma.Get(v1_0, "/planned-workouts/{planId}", h.GetPlan)
+	huma.Patch(v1_0, "/planned-workouts/{planId}", h.UpdatePlan, patch.Operation[schemas.PlanFields](v1_0))
+	huma.Delete(v1_0, "/planned-workouts/{planId}", h.DeletePlan)
+	huma.Get(v1_0, "/planned-sessions", h.ListSessions)
+	huma.Get(v1_0, "/planned-sessions/{sessionId}", h.GetSession)
+	huma.Post(v1_0, "/planned-sessions/{sessionId}/complete", h.CompleteSession)
+	huma.Post(v1_0, "/planned-sessions/{sessionId}/skip", h.SkipSession)
+	huma.Post(v1_0, "/planned-sessions/{sessionId}/reschedule", h.RescheduleSession)
+	huma.Get(v1_0, "/reports/missed-sessions", h.MissedSessions)
+	huma.Post(v1_0, "/push-subscriptions", h.CreatePushSubscription)
+	huma.Delete(v1_0, "/push-subscriptions/{subscriptionId}", h.DeletePushSubscription)
+}
+
+// owns reports whether the caller may act on something owned by ownerID.
+// Every caller passes in dev/test mode.
+func (h *PlanHandler) owns(ctx context.Context, ownerID int64) (bool, error) {
+	userID, err := resolveUserID(ctx, h.db)
+	if err != nil {
+		return false, huma.Error500InternalServerError("failed to resolve user")
+	}
+	return userID == 0 || userID == ownerID, nil
+}
+
+// plan loads one of the caller's plans, answering 404 for other users'.
+func (h *PlanHandler) plan(ctx context.Context, planID uuid.UUID) (*models.PlannedWorkout, error) {
+	var p models.PlannedWorkout
+	if err := h.db.Where("public_id = ?", planID).First(&p).Error; err != nil {
+		return nil, huma.NewError(http.StatusNotFound, "planned workout not found")
+	}
+	ok, err := h.owns(ctx, p.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, huma.NewError(http.StatusNotFound, "planned workout not found")
+	}
+	return &p, nil
+}
+
+// session loads one of the caller's sessions with its plan, answering 404
+// for other users'.
+func (h *PlanHandler) session(ctx context.Context, sessionID uuid.UUID) (*models.PlannedSession, error) {
+	var s models.PlannedSession
+	err := h.db.Preload("Plan", func(db *gorm.DB) *gorm.DB { return db.Unscoped() }).
+		Where("public_id = ?", sessionID).First(&s).Error
+	if err != nil {
+		return nil, huma.NewError(http.StatusNotFound, "planned session not found")
+	}
+	ok, err := h.owns(ctx, s.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, huma.NewError(http.StatusNotFound, "planned session not found")
+	}
+	return &s, nil
+}
+
+// validatePlan checks what the schema cannot: the zone and the rule.
+func validatePlan(p models.PlannedWorkout) error {
+	if _, err := time.LoadLocation(p.Timezone); err != nil {
+		return huma.Error422UnprocessableEntity("unknown timezone " + p.Timezone)
+	}
+	if p.RRule != "" {
+		if _, err := planner.ParseRRule(p.RRule); err != nil {
+			return huma.Error422UnprocessableEntity(err.Error())
+		}
+	}
+	return nil
+}
+
+func (h *PlanHandler) ListPlans(ctx context.Context, input *schemas.ListPlansInput) (*schemas.ListPlansOutput, error) {
+	userID, err := resolveUserID(ctx, h.db)
+	if err != nil {
+		return nil, huma.Error500InternalServerError("failed to resolve user")
+	}
+	if userID == 0 && input.UserID != uuid.Nil {
+		// Dev/test fallback: honour the query-param filter.
+		if userID, err = lookupUserID(h.db, input.UserID); err != nil {
+			return nil, err
+		}
+	}
+	q := h.db.Order("id")
+	if userID != 0 {
+		q = q.Where("user_id = ?", userID)
+	}
+	var list []models.PlannedWorkout
+	if err := q.Find(&list).Error; err != nil {
+		return nil, huma.Error500InternalServerError("failed to fetch planned workouts")
+	}
+	body, err := planResponses(h.db, list...)
+	if err != nil {
+		return nil, huma.Error500InternalServerError("failed to fetch planned workouts")
+	}
+	return &schemas.ListPlansOutput{Body: body}, nil
+}
+
+func (h *PlanHandler) CreatePlan(ctx context.Context, input *schemas.CreatePlanInput) (*schemas.CreatePlanOutput, error) {
+	userID, err := callerID(ctx, h.db, input.Body.UserID)
+	if err != nil {
+		return nil, err
+	}
+	p := models.PlannedWorkout{
+		BaseModel:           models.BaseModel{PublicID: input.Body.ID},
+		UserID:              userID,
+		Name:                input.Body.Name,
+		Description:         input.Body.Description,
+		DurationMinutes:     input.Body.DurationMinutes,
+		StartsAt:            input.Body.StartsAt,
+		Timezone:            input.Body.Timezone,
+		RRule:               input.Body.RRule,
+		ReminderChannels:    input.Body.ReminderChannels,
+		RemindMinutesBefore: input.Body.RemindMinutesBefore,
+		AutoReschedule:      input.Body.AutoReschedule,
+	}
+	if err := validatePlan(p); err != nil {
+		return nil, err
+	}
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit(clause.Associations).Create(&p).Error; err != nil {
+			return err
+		}
+		return planner.Materialize(tx, p, time.Now())
+	})
+	if err != nil {
+		if isDuplicate(err) {
+			return nil, huma.NewError(http.StatusConflict, "a planned workout with this id already exists")
+		}
+		return nil, huma.Error500InternalServerError("failed to create planned workout")
+	}
+	r, err := planResponses(h.db, p)
+	if err != nil {
+		return nil, huma.Error500InternalServerError("failed to fetch planned workout")
+	}
+	return &schemas.CreatePlanOutput{Status: http.StatusCreated, Body: &r[0]}, nil
+}
+
+func (h *PlanHandler) GetPlan(ctx context.Context, input *schemas.GetPlanInput) (*schemas.GetPlanOutput, error) {
+	p, err := h.plan(ctx, input.PlanID)
+	if err != nil {
+		return nil, err
+	}
+	r, err := planResponses(h.db, *p)
+	if err != nil {
+		return nil, huma.Error500InternalServerError("failed to fetch planned workout")
+	}
+	return &schemas.GetPlanOutput{Body: &r[0]}, nil
+}
+
+// UpdatePlan patches a plan's fields. When its schedule changes, upcoming sessions
+// nobody has acted on are regenerated; past and handled ones are kept.
+func (h *PlanHandler) UpdatePlan(ctx context.Context, input *schemas.UpdatePlanInput) (*schemas.GetPlanOutput, error) {
+	p, err := h.plan(ctx, input.PlanID)
+	if err != nil {
+		return nil, err
+	}
+	before := *p
+	f, err := patch.To(planFields(*p), input.ContentType, input.Body)
+	if err != nil {
+		return nil, err
+	}
+	p.Name = f.Name
+	p.Description = f.Description
+	p.DurationMinutes = f.DurationMinutes
+	p.StartsAt = f.StartsAt
+	p.Timezone = f.Timezone
+	p.RRule = f.RRule
+	p.ReminderChannels = f.ReminderChannels
+	p.RemindMinutesBefore = f.RemindMinutesBefore
+	p.AutoReschedule = f.AutoReschedule
+	if err := validatePlan(*p); err != nil {
+		return nil, err
+	}
+	replan := !p.StartsAt.Equal(before.StartsAt) || p.Timezone != before.Timezone || p.RRule != before.RRule
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit(clause.Associations).Save(p).Error; err != nil {
+			return err
+		}
+		if !replan {
+			return nil
+		}
+		now := time.Now()
+		if err := planner.ClearFuture(tx, p.ID, now); err != nil {
+			return err
+		}
+		return planner.Materialize(tx, *p, now)
+	})
+	if err != nil {
+		return nil, huma.Error500InternalServerError("failed to update planned workout")
+	}
+	r, err := planResponses(h.db, *p)
+	if err != nil {
+		return nil, huma.Error500InternalServerError("failed to fetch planned workout")
+	}
+	return &schemas.GetPlanOutput{Body: &r[0]}, nil
+}
+
+func planFields(p models.PlannedWorkout) schemas.PlanFields {
+	channels := p.ReminderChannels
+	if channels == nil {
+		channels = []string{}
+	}
+	return schemas.PlanFields{
+		Name:                p.Name,
+		Description:         p.Description,
+		DurationMinutes:     p.DurationMinutes,
+		StartsAt:            p.StartsAt,
+		Timezone:            p.Timezone,
+		RRule:               p.RRule,
+		ReminderChannels:    channels,
+		RemindMinutesBefore: p.RemindMinutesBefore,
+		AutoReschedule:      p.AutoReschedule,
+	}
+}
+
+// DeletePlan removes a plan and its upcoming sessions; sessions already done,
+// skipped or missed stay in the user's history.
+func (h *PlanHandler) DeletePlan(ctx context.Context, input *schemas.GetPlanInput) (*struct{}, error) {
+	p, err := h.plan(ctx, input.PlanID)
+	if err != nil {
+		return nil, err
+	}
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if err := planner.ClearFuture(tx, p.ID, time.Now()); err != nil {
+			return err
+		}
+		return tx.Delete(p).Error
+	})
+	if err != nil {
+		return nil, huma.Error500InternalServerError("failed to delete planned workout")
+	}
+	return nil, nil
+}
+
+func (h *PlanHandler) ListSessions(ctx context.Context, input *schemas.ListSessionsInput) (*schemas.ListSessionsOutput, error) {
+	userID, err := callerID(ctx, h.db, input.UserID)
+	if err != nil {
+		return nil, err
+	}
+	from, to := input.From, input.To
+	if from.IsZero() {
+		from = time.Now()
+	}
+	if to.IsZero() {
+		to = from.AddDate(0, 0, 14)
+	}
+	q := h.db.Preload("Plan", func(db *gorm.DB) *gorm.DB { return db.Unscoped() }).
+		Where("user_id = ? AND scheduled_at >= ? AND scheduled_at < ?", userID, from, to).
+		Order("scheduled_at")
+	if input.Status != "" {
+		q = q.Where("status = ?", input.Status)
+	}
+	var list []models.PlannedSession
+	if err := q.Find(&list).Error; err != nil {
+		return nil, huma.Error500InternalServerError("failed to fetch planned sessions")
+	}
+	body, err := sessionResponses(h.db, list...)
+	if err != nil {
+		return nil, huma.Error500InternalServerError("failed to fetch planned sessions")
+	}
+	return &schemas.ListSessionsOutput{Body: body}, nil
+}
+
+func (h *PlanHandler) GetSession(ctx context.Context, input *schemas.GetSessionInput) (*schemas.GetSessionOutput, error) {
+	s, err := h.session(ctx, input.SessionID)
+	if err != nil {
+		return nil, err
+	}
+	r, err := sessionResponses(h.db, *s)
+	if err != nil {
+		return nil, huma.Error500InternalServerError("failed to fetch planned session")
+	}
+	return &schemas.GetSessionOutput{Body: &r[0]}, nil
+}
+
+// CompleteSession marks a session done by linking the workout that
+// fulfilled it. Missed sessions can still be completed by a late log.
+func (h *PlanHandler) CompleteSession(ctx context.Context, input *schemas.CompleteSessionInput) (*schemas.GetSessionOutput, error) {
+	s, err := h.session(ctx, input.SessionID)
+	if err != nil {
+		return nil, err
+	}
+	var w models.Workout
+	err = h.db.Where("user_id = ? AND public_id = ?", s.UserID, input.Body.WorkoutID).First(&w).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, huma.Error422UnprocessableEntity("workout not found")
+	}
+	if err != nil {
+		return nil, huma.Error500InternalServerError("failed to fetch workout")
+	}
+	s.Status = models.SessionStatusDone
+	s.WorkoutID = &w.ID
+	if err := h.db.Model(s).Select("Status", "WorkoutID").Updates(s).Error; err != nil {
+		return nil, huma.Error500InternalServerError("failed to complete planned session")
+	}
+	r, err := sessionResponses(h.db, *s)
+	if err != nil {
+		return nil, huma.Error500InternalServerError("failed to fetch planned session")
+	}
+	return &schemas.GetSessionOutput{Body: &r[0]}, nil
+}
+
+func (h *PlanHandler) SkipSession(ctx context.Context, input *schemas.GetSessionInput) (*schemas.GetSessionOutput, error) {
+	s, err := h.session(ctx, input.SessionID)
+	if err != nil {
+		return nil, err
+	}
+	if s.Status == models.SessionStatusDone {
+		return nil, huma.NewError(http.StatusConflict, "planned session is already done")
+	}
+	s.Status = models.SessionStatusSkipped
+	if err := h.db.Model(s).Select("Status").Updates(s).Error; err != nil {
+		return nil, huma.Error500InternalServerError("failed to skip planned session")
+	}
+	r, err := sessionResponses(h.db, *s)
+	if err != nil {
+		return nil, huma.Error500InternalServerError("failed to fetch planned session")
+	}
+	return &schemas.GetSessionOutput{Body: &r[0]}, nil
+}
+
+// RescheduleSession moves a scheduled or missed session to a new time and
+// re-arms its reminder.
+func (h *PlanHandler) RescheduleSession(ctx context.Context, input *schemas.RescheduleSessionInput) (*schemas.GetSessionOutput, error) {
+	s, err := h.session(ctx, input.SessionID)
+	if err != nil {
+		return nil, err
+	}
+	if s.Status != models.SessionStatusScheduled && s.Status != models.SessionStatusMissed {
+		return nil, huma.NewError(http.StatusConflict, "only scheduled or missed sessions can be rescheduled")
+	}
+	if !input.Body.ScheduledAt.After(time.Now()) {
+		return nil, huma.Error422UnprocessableEntity("scheduled_at must be in the future")
+	}
+	s.Status = models.SessionStatusScheduled
+	s.ScheduledAt = input.Body.ScheduledAt
+	s.RemindedAt = nil
+	s.Reschedules++
+	err = h.db.Model(s).Select("Status", "ScheduledAt", "RemindedAt", "Reschedules").Updates(s).Error
+	if err != nil {
+		return nil, huma.Error500InternalServerError("failed to reschedule planned session")
+	}
+	r, err := sessionResponses(h.db, *s)
+	if err != nil {
+		return nil, huma.Error500InternalServerError("failed to fetch planned session")
+	}
+	return &schemas.GetSessionOutput{Body: &r[0]}, nil
+}
+
+// MissedSessions reports on how well the caller kept to their plans over a
+// period, listing the sessions they missed.
+func (h *PlanHandler) MissedSessions(ctx context.Context, input *schemas.MissedSessionsInput) (*schemas.MissedSessionsOutput, error) {
+	userID, err := callerID(ctx, h.db, input.UserID)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	from, to := input.From, input.To
+	if to.IsZero() {
+		to = now
+	}
+	if from.IsZero() {
+		from = to.AddDate(0, 0, -30)
+	}
+
+	report := schemas.MissedSessionsReport{From: from, To: to, Sessions: []schemas.PlannedSessionResponse{}}
+	var counts struct {
+		Planned, Done, Skipped, Missed, Past int64
+	}
+	err = h.db.Model(&models.PlannedSession{}).
+		Select("COUNT(*) AS planned, "+
+			"COUNT(*) FILTER (WHERE status = ?) AS done, "+
+			"COUNT(*) FILTER (WHERE status = ?) AS skipped, "+
+			"COUNT(*) FILTER (WHERE missed_at IS NOT NULL) AS missed, "+
+			"COUNT(*) FILTER (WHERE scheduled_at < ?) AS past",
+			models.SessionStatusDone, models.SessionStatusSkipped, now).
+		Where("user_id = ? AND occurs_at >= ? AND occurs_at < ?", userID, from, to).
+		Scan(&counts).Error
+	if err != nil {
+		return nil, huma.Error500InternalServerError("failed to build report")
+	}
+	report.Planned, report.Done, report.Skipped, report.Missed = counts.Planned, counts.Done, counts.Skipped, counts.Missed
+	if counts.Past > 0 {
+		report.Adherence = float64(counts.Done) / float64(counts.Past)
+	}
+
+	var missed []models.PlannedSession
+	err = h.db.Preload("Plan", func(db *gorm.DB) *gorm.DB { return db.Unscoped() }).
+		Where("user_id = ? AND occurs_at >= ? AND occurs_at < ? AND missed_at IS NOT NULL", userID, from, to).
+		Order("occurs_at DESC").
+		Find(&missed).Error
+	if err != nil {
+		return nil, huma.Error500InternalServerError("failed to build report")
+	}
+	if len(missed) > 0 {
+		if report.Sessions, err = sessionResponses(h.db, missed...); err != nil {
+			return nil, huma.Error500InternalServerError("failed to build report")
+		}
+	}
+	return &schemas.MissedSessionsOutput{Body: &report}, nil
+}
+
+// CreatePushSubscription registers a browser for Web Push reminders. The
+// endpoint is unique, so re-subscribing refreshes its keys and owner.
+// Push services are public https endpoints, so anything else is refused.
+func (h *PlanHandler) CreatePushSubscription(ctx context.Context, input *schemas.CreatePushSubscriptionInput) (*schemas.CreatePushSubscriptionOutput, error) {
+	if err := netguard.CheckURL(input.Body.Endpoint, "https"); err != nil {
+		return nil, huma.Error422UnprocessableEntity("endpoint: " + err.Error())
+	}
+	userID, err := callerID(ctx, h.db, input.Body.UserID)
+	if err != nil {
+		return nil, err
+	}
+	s := models.PushSubscription{
+		UserID:   userID,
+		Endpoint: input.Body.Endpoint,
+		P256dh:   input.Body.Keys.P256dh,
+		Auth:     input.Body.Keys.Auth,
+	}
+	// Returning the public ID hands a refreshed subscription its old one.
+	err = h.db.Omit(clause.Associations).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "endpoint"}},
+		DoUpdates: clause.AssignmentColumns([]string{"user_id", "p256dh", "auth", "updated_at", "deleted_at"}),
+	}, clause.Returning{Columns: []clause.Column{{Name: "id"}, {Name: "public_id"}}}).Create(&s).Error
+	if err != nil {
+		return nil, huma.Error500InternalServerError("failed to save push subscription")
+	}
+	return &schemas.CreatePushSubscriptionOutput{
+		Status: http.StatusCreated,
+		Body:   &schemas.PushSubscriptionResponse{ID: s.PublicID, Endpoint: s.Endpoint, CreatedAt: s.CreatedAt},
+	}, nil
+}
+
+func (h *PlanHandler) DeletePushSubscription(ctx context.Context, input *schemas.DeletePushSubscriptionInput) (*struct{}, error) {
+	var s models.PushSubscription
+	if err := h.db.Where("public_id = ?", input.SubscriptionID).First(&s).Error; err != nil {
+		return nil, huma.NewError(http.StatusNotFound, "push subscription not found")
+	}
+	ok, err := h.owns(ctx, s.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, huma.NewError(http.StatusNotFound, "push subscription not found")
+	}
+	if err := h.db.Unscoped().Delete(&s).Error; err != nil {
+		return nil, huma.Error500InternalServerError("failed to delete push subscription")
+	}
+	return nil, nil
+}
+
+// planResponses maps plans to responses, looking up their owners' public
+// IDs.
+func planResponses(db *gorm.DB, list ...models.PlannedWorkout) ([]schemas.PlannedWorkoutResponse, error) {
+	userIDs := make([]int64, len(list))
+	for i, p := range list {
+		userIDs[i] = p.UserID
+	}
+	users, err := publicIDs(db, &models.User{}, userIDs...)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]schemas.PlannedWorkoutResponse, len(list))
+	for i, p := range list {
+		out[i] = planToResponse(p, users[p.UserID])
+	}
+	return out, nil
+}
+
+func planToResponse(p models.PlannedWorkout, userID uuid.UUID) schemas.PlannedWorkoutResponse {
+	channels := p.ReminderChannels
+	if channels == nil {
+		channels = []string{}
+	}
+	return schemas.PlannedWorkoutResponse{
+		ID:                  p.PublicID,
+		UserID:              userID,
+		Name:                p.Name,
+		Description:         p.Description,
+		DurationMinutes:     p.DurationMinutes,
+		StartsAt:            p.StartsAt,
+		Timezone:            p.Timezone,
+		RRule:               p.RRule,
+		ReminderChannels:    channels,
+		RemindMinutesBefore: p.RemindMinutesBefore,
+		AutoReschedule:      p.AutoReschedule,
+		CreatedAt:           p.CreatedAt,
+		UpdatedAt:           p.UpdatedAt,
+	}
+}
+
+// sessionResponses maps sessions, which must have their plans loaded, to
+// responses, looking up the public IDs of the workouts that completed them.
+func sessionResponses(db *gorm.DB, list ...models.PlannedSession) ([]schemas.PlannedSessionResponse, error) {
+	workoutIDs := make([]int64, 0, len(list))
+	for _, s := range list {
+		if s.WorkoutID != nil {
+			workoutIDs = append(workoutIDs, *s.WorkoutID)
+		}
+	}
+	workouts, err := publicIDs(db, &models.Workout{}, workoutIDs...)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]schemas.PlannedSessionResponse, len(list))
+	for i, s := range list {
+		out[i] = sessionToResponse(s, optionalPublicID(workouts, s.WorkoutID))
+	}
+	return out, nil
+}
+
+func sessionToResponse(s models.PlannedSession, workoutID *uuid.UUID) schemas.PlannedSessionResponse {
+	return schemas.PlannedSessionResponse{
+		ID:          s.PublicID,
+		PlanID:      s.Plan.PublicID,
+		Name:        s.Plan.Name,
+		OccursAt:    s.OccursAt,
+		ScheduledAt: s.ScheduledAt,
+		Status:      s.Status,
+		WorkoutID:   workoutID,
+		RemindedAt:  s.RemindedAt,
+		MissedAt:    s.MissedAt,
+		Reschedules: s.Reschedules,
+	}
+}
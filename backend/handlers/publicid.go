@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+
+	"workout-tracker/backend/models"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+)
+
+// The API names every record by its public ID, a UUID, and never shows the
+// int64 primary keys rows reference each other by. Handlers look records up
+// by public ID and map the IDs a response refers to with publicIDs.
+
+// publicIDs maps IDs of model's table to the records' public IDs. Deleted
+// records are included, since responses can still refer to them. Zero IDs,
+// unset optional references, are skipped.
+func publicIDs(db *gorm.DB, model any, ids ...int64) (map[int64]uuid.UUID, error) {
+	ids = slices.DeleteFunc(slices.Clone(ids), func(id int64) bool { return id == 0 })
+	slices.Sort(ids)
+	ids = slices.Compact(ids)
+	out := make(map[int64]uuid.UUID, len(ids))
+	if len(ids) == 0 {
+		return out, nil
+	}
+	var rows []struct {
+		ID       int64
+		PublicID uuid.UUID
+	}
+	if err := db.Unscoped().Model(model).Select("id", "public_id").Where("id IN ?", ids).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	for _, r := range rows {
+		out[r.ID] = r.PublicID
+	}
+	return out, nil
+}
+
+// publicID returns the public ID of model's record id.
+func publicID(db *gorm.DB, model any, id int64) (uuid.UUID, error) {
+	ids, err := publicIDs(db, model, id)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	return ids[id], nil
+}
+
+// optionalPublicID maps an optional reference to its public ID, nil when
+// unset.
+func optionalPublicID(ids map[int64]uuid.UUID, id *int64) *uuid.UUID {
+	if id == nil {
+		return nil
+	}
+	pub := ids[*id]
+	return &pub
+}
+
+// lookupID returns the ID of model's record with public ID publicID,
+// gorm.ErrRecordNotFound when there is none.
+func lookupID(db *gorm.DB, model any, publicID uuid.UUID) (int64, error) {
+	var id int64
+	err := db.Model(model).Select("id").Where("public_id = ?", publicID).Take(&id).Error
+	return id, err
+}
+
+// lookupUserID resolves a user named in a request to their ID, answering
+// 404 when there is no such user.
+func lookupUserID(db *gorm.DB, publicID uuid.UUID) (int64, error) {
+	id, err := lookupID(db, &models.User{}, publicID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, huma.NewError(http.StatusNotFound, "user not found")
+	}
+	if err != nil {
+		return 0, huma.Error500InternalServerError("failed to fetch user")
+	}
+	return id, nil
+}
+
+// isDuplicate reports whether err is a unique constraint violation, such
+// as a client creating a record with a public ID already taken.
+func isDuplicate(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
+}
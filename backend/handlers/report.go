@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"workout-tracker/backend/models"
+	"workout-tracker/backend/reports"
+	"workout-tracker/backend/schemas"
+
+	"github.com/danielgtaylor/huma/v2"
+	"gorm.io/gorm"
+)
+
+type ReportHandler struct {
+	db *gorm.DB
+}
+
+func NewReportHandler(db *gorm.DB) *ReportHandler {
+	return &ReportHandler{db: db}
+}
+
+func (h *ReportHandler) RegisterRoutes(api huma.API) {
+	v1_0 := huma.NewGroup(api, "/api/v1")
+	huma.Get(v1_0, "/me/reports", h.ListReports)
+	huma.Get(v1_0, "/me/reports/{period}", h.GetReport)
+	huma.Get(v1_0, "/me/reports/{period}/document", h.GetReportDocument)
+}
+
+func (h *ReportHandler) ListReports(ctx context.Context, input *schemas.ListReportsInput) (*schemas.ListReportsOutput, error) {
+	userID, err := callerID(ctx, h.db, input.UserID)
+	if err != nil {
+		return nil, err
+	}
+	q := h.db.Select("id", "public_id", "period", "period_start", "period_end", "created_at", "updated_at").
+		Where("user_id = ?", userID).
+		Order("period_start DESC, id DESC").
+		Limit(input.Limit)
+	if input.Period != "" {
+		q = q.Where("period = ?", input.Period)
+	}
+	var list []models.Report
+	if err := q.Find(&list).Error; err != nil {
+		return nil, huma.Error500InternalServerError("failed to fetch reports")
+	}
+	out := &schemas.ListReportsOutput{Body: make([]schemas.ReportSummary, len(list))}
+	for i, r := range list {
+		out.Body[i] = schemas.ReportSummary{ID: r.PublicID, Period: r.Period, Start: r.PeriodStart, End: r.PeriodEnd, GeneratedAt: r.UpdatedAt}
+	}
+	return out, nil
+}
+
+func (h *ReportHandler) GetReport(ctx context.Context, input *schemas.GetReportInput) (*schemas.GetReportOutput, error) {
+	r, err := h.report(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	return &schemas.GetReportOutput{Body: r}, nil
+}
+
+func (h *ReportHandler) GetReportDocument(ctx context.Context, input *schemas.GetReportInput) (*schemas.ReportDocumentOutput, error) {
+	r, err := h.report(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := reports.RenderHTML(*r)
+	if err != nil {
+		return nil, huma.Error500InternalServerError("failed to render report")
+	}
+	return &schemas.ReportDocumentOutput{
+		ContentType:        "text/html; charset=utf-8",
+		ContentDisposition: fmt.Sprintf(`attachment; filename="%s-report-%s.html"`, r.Period, r.Start.Format(time.DateOnly)),
+		Body:               doc,
+	}, nil
+}
+
+// report returns the stored report for the requested period, generating
+// and storing it first if the scheduled job hasn't. A period still in
+// progress is built on the fly and not stored.
+func (h *ReportHandler) report(ctx context.Context, input *schemas.GetReportInput) (*schemas.ReportResponse, error) {
+	userID, err := callerID(ctx, h.db, input.UserID)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	var start, end time.Time
+	if input.Start == "" {
+		start, end, err = reports.LastCompleted(input.Period, now)
+	} else {
+		day, perr := time.Parse(time.DateOnly, input.Start)
+		if perr != nil {
+			return nil, huma.Error422UnprocessableEntity("start must be a date (YYYY-MM-DD)")
+		}
+		start, end, err = reports.Bounds(input.Period, day)
+	}
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	if start.After(now) {
+		return nil, huma.Error422UnprocessableEntity("period has not started yet")
+	}
+	owner, err := publicID(h.db, &models.User{}, userID)
+	if err != nil {
+		return nil, huma.Error500InternalServerError("failed to fetch user")
+	}
+
+	if end.After(now) {
+		data, err := reports.Build(h.db, userID, input.Period, start)
+		if err != nil {
+			return nil, huma.Error500InternalServerError("failed to build report")
+		}
+		return &schemas.ReportResponse{UserID: owner, GeneratedAt: now, Partial: true, ReportData: data}, nil
+	}
+
+	var stored models.Report
+	err = h.db.Where("user_id = ? AND period = ? AND period_start = ?", userID, input.Period, start).First(&stored).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		data, berr := reports.Build(h.db, userID, input.Period, start)
+		if berr != nil {
+			return nil, huma.Error500InternalServerError("failed to build report")
+		}
+		stored, err = reports.Store(h.db, userID, data)
+	}
+	if err != nil {
+		return nil, huma.Error500InternalServerError("failed to fetch report")
+	}
+	r := schemas.ReportResponse{ID: &stored.PublicID, UserID: owner, GeneratedAt: stored.UpdatedAt}
+	if err := json.Unmarshal(stored.Data, &r.ReportData); err != nil {
+		return nil, huma.Error500InternalServerError("failed to read report")
+	}
+	return &r, nil
+}
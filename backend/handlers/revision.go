@@ -0,0 +1,233 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"workout-tracker/backend/audit"
+	"workout-tracker/backend/models"
+	"workout-tracker/backend/schemas"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+func (h *WorkoutHandler) ListRevisions(ctx context.Context, input *schemas.ListRevisionsInput) (*schemas.ListRevisionsOutput, error) {
+	w, err := h.viewable(ctx, input.WorkoutID)
+	if err != nil {
+		return nil, err
+	}
+	var revs []models.WorkoutRevision
+	if err := h.db.Where("workout_id = ?", w.ID).Order("revision DESC").Find(&revs).Error; err != nil {
+		return nil, huma.Error500InternalServerError("failed to fetch revisions")
+	}
+	var authorIDs []int64
+	for _, r := range revs {
+		if r.AuthorID != nil {
+			authorIDs = append(authorIDs, *r.AuthorID)
+		}
+	}
+	authors, err := publicIDs(h.db, &models.User{}, authorIDs...)
+	if err != nil {
+		return nil, huma.Error500InternalServerError("failed to fetch revisions")
+	}
+	out := &schemas.ListRevisionsOutput{Body: make([]schemas.WorkoutRevisionResponse, len(revs))}
+	for i, r := range revs {
+		resp, err := revisionToResponse(r, authors)
+		if err != nil {
+			return nil, huma.Error500InternalServerError("failed to read revision")
+		}
+		out.Body[i] = resp
+	}
+	return out, nil
+}
+
+// DiffRevisions compares a revision with an earlier one, by default the
+// revision before it.
+func (h *WorkoutHandler) DiffRevisions(ctx context.Context, input *schemas.DiffRevisionsInput) (*schemas.DiffRevisionsOutput, error) {
+	w, err := h.viewable(ctx, input.WorkoutID)
+	if err != nil {
+		return nil, err
+	}
+	against := input.Against
+	if against == 0 {
+		against = input.Revision - 1
+	}
+	to, err := h.revision(w.ID, input.Revision)
+	if err != nil {
+		return nil, err
+	}
+	after, err := revisionFields(to.Snapshot)
+	if err != nil {
+		return nil, huma.Error500InternalServerError("failed to read revision")
+	}
+	var before map[string]any
+	if against > 0 {
+		from, err := h.revision(w.ID, against)
+		if err != nil {
+			return nil, err
+		}
+		if before, err = revisionFields(from.Snapshot); err != nil {
+			return nil, huma.Error500InternalServerError("failed to read revision")
+		}
+	}
+	diff := &schemas.WorkoutRevisionDiff{From: against, To: input.Revision, Changes: map[string]schemas.FieldChange{}}
+	for field, c := range audit.Diff(before, after) {
+		diff.Changes[field] = schemas.FieldChange{Old: c.Old, New: c.New}
+	}
+	return &schemas.DiffRevisionsOutput{Body: diff}, nil
+}
+
+// RestoreRevision puts a workout back as an earlier revision left it. The
+// restore is itself recorded as a new revision.
+func (h *WorkoutHandler) RestoreRevision(ctx context.Context, input *schemas.RestoreRevisionInput) (*schemas.GetWorkoutOutput, error) {
+	var w models.Workout
+	if err := h.db.Where("public_id = ?", input.WorkoutID).First(&w).Error; err != nil {
+		return nil, huma.NewError(http.StatusNotFound, "workout not found")
+	}
+	userID, err := resolveUserID(ctx, h.db)
+	if err != nil {
+		return nil, huma.Error500InternalServerError("failed to resolve user")
+	}
+	if userID != 0 && userID != w.UserID {
+		return nil, huma.NewError(http.StatusNotFound, "workout not found")
+	}
+	rev, err := h.revision(w.ID, input.Revision)
+	if err != nil {
+		return nil, err
+	}
+	var snap schemas.WorkoutResponse
+	if err := json.Unmarshal(rev.Snapshot, &snap); err != nil {
+		return nil, huma.Error500InternalServerError("failed to read revision")
+	}
+	w.Name = snap.Name
+	w.Description = snap.Description
+	w.DurationMinutes = snap.DurationMinutes
+	w.DistanceMeters = snap.DistanceMeters
+	w.PerformedAt = snap.PerformedAt
+	w.Visibility = snap.Visibility
+	w.Tags = nonNilTags(snap.Tags)
+	w.CustomFields = nonNilFields(snap.CustomFields)
+	w.Exercises = nonNilExercises(snap.Exercises)
+	w.Groups = nonNilGroups(snap.Groups)
+
+	var r schemas.WorkoutResponse
+	err = h.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := saveVersioned(tx, &w, &w.Version); err != nil {
+			return err
+		}
+		r, err = recordRevision(tx, w, userID, &rev.Revision)
+		return err
+	})
+	if errors.Is(err, errStale) {
+		return nil, huma.Error412PreconditionFailed("workout was changed by another request; retry")
+	}
+	if err != nil {
+		return nil, huma.Error500InternalServerError("failed to restore revision")
+	}
+	return &schemas.GetWorkoutOutput{ETag: etag(w.Version), Body: &r}, nil
+}
+
+// viewable loads a workout the caller may see, answering 404 otherwise.
+func (h *WorkoutHandler) viewable(ctx context.Context, workoutID uuid.UUID) (*models.Workout, error) {
+	var w models.Workout
+	if err := h.db.Where("public_id = ?", workoutID).First(&w).Error; err != nil {
+		return nil, huma.NewError(http.StatusNotFound, "workout not found")
+	}
+	viewerID, err := resolveUserID(ctx, h.db)
+	if err != nil {
+		return nil, huma.Error500InternalServerError("failed to resolve user")
+	}
+	visible, err := canViewWorkout(h.db, w, viewerID)
+	if err != nil {
+		return nil, huma.Error500InternalServerError("failed to check visibility")
+	}
+	if !visible {
+		return nil, huma.NewError(http.StatusNotFound, "workout not found")
+	}
+	return &w, nil
+}
+
+func (h *WorkoutHandler) revision(workoutID int64, number int) (*models.WorkoutRevision, error) {
+	var rev models.WorkoutRevision
+	err := h.db.Where("workout_id = ? AND revision = ?", workoutID, number).First(&rev).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, huma.NewError(http.StatusNotFound, "revision not found")
+	}
+	if err != nil {
+		return nil, huma.Error500InternalServerError("failed to fetch revision")
+	}
+	return &rev, nil
+}
+
+// recordRevision stores w, as tx just wrote it, as the workout's next
+// revision and returns it as the API shows it. authorID may be 0 when no
+// user is known.
+func recordRevision(tx *gorm.DB, w models.Workout, authorID int64, restoredFrom *int) (schemas.WorkoutResponse, error) {
+	r, err := workoutResponse(tx, w)
+	if err != nil {
+		return schemas.WorkoutResponse{}, err
+	}
+	snapshot, err := json.Marshal(r)
+	if err != nil {
+		return schemas.WorkoutResponse{}, err
+	}
+	var last int
+	err = tx.Model(&models.WorkoutRevision{}).
+		Select("COALESCE(MAX(revision), 0)").
+		Where("workout_id = ?", w.ID).
+		Scan(&last).Error
+	if err != nil {
+		return schemas.WorkoutResponse{}, err
+	}
+	rev := models.WorkoutRevision{WorkoutID: w.ID, Revision: last + 1, RestoredFrom: restoredFrom, Snapshot: snapshot}
+	if authorID != 0 {
+		rev.AuthorID = &authorID
+	}
+	return *r, tx.Omit(clause.Associations).Create(&rev).Error
+}
+
+// revisionToResponse converts r, mapping its author with authors.
+func revisionToResponse(r models.WorkoutRevision, authors map[int64]uuid.UUID) (schemas.WorkoutRevisionResponse, error) {
+	out := schemas.WorkoutRevisionResponse{
+		Revision:     r.Revision,
+		AuthorID:     optionalPublicID(authors, r.AuthorID),
+		RestoredFrom: r.RestoredFrom,
+		CreatedAt:    r.CreatedAt,
+	}
+	err := json.Unmarshal(r.Snapshot, &out.Workout)
+	return out, err
+}
+
+// revisionFields decodes a snapshot into the fields a diff compares,
+// normalised so snapshots written at different times compare equal when
+// the workout didn't change.
+func revisionFields(snapshot json.RawMessage) (map[string]any, error) {
+	var w schemas.WorkoutResponse
+	if err := json.Unmarshal(snapshot, &w); err != nil {
+		return nil, err
+	}
+	w.PerformedAt = w.PerformedAt.UTC()
+	// Revisions from before workouts had tags, custom fields, exercises and
+	// groups lack them.
+	w.Tags = nonNilTags(w.Tags)
+	w.CustomFields = nonNilFields(w.CustomFields)
+	w.Exercises = nonNilExercises(w.Exercises)
+	w.Groups = nonNilGroups(w.Groups)
+	raw, err := json.Marshal(w)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]any
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return nil, err
+	}
+	delete(fields, "created_at")
+	delete(fields, "updated_at")
+	delete(fields, "version")
+	return fields, nil
+}
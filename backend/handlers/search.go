@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"strconv"
+
+	"workout-tracker/backend/schemas"
+	"workout-tracker/backend/search"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// SearchHandler serves full-text search over what the caller may see.
+type SearchHandler struct {
+	db *gorm.DB
+}
+
+func NewSearchHandler(db *gorm.DB) *SearchHandler {
+	return &SearchHandler{db: db}
+}
+
+func (h *SearchHandler) RegisterRoutes(api huma.API) {
+	v1_0 := huma.NewGroup(api, "/api/v1")
+	huma.Register(v1_0, huma.Operation{
+		OperationID: "search",
+		Method:      http.MethodGet,
+		Path:        "/search",
+		Summary:     "Search workouts",
+		Description: "Finds workouts whose name or description contain the words in q, ranked by relevance with " +
+			"matches in names ahead of matches in descriptions. Results include the title and excerpts with the " +
+			"matching words marked. Set prefix for typeahead: the last word then also matches longer words it starts.",
+	}, h.Search)
+}
+
+func (h *SearchHandler) Search(ctx context.Context, input *schemas.SearchInput) (*schemas.SearchOutput, error) {
+	viewerID, err := resolveUserID(ctx, h.db)
+	if err != nil {
+		return nil, huma.Error500InternalServerError("failed to resolve user")
+	}
+	if viewerID == 0 && input.UserID != uuid.Nil {
+		// Dev/test fallback: search as the user named in the query.
+		if viewerID, err = lookupUserID(h.db.WithContext(ctx), input.UserID); err != nil {
+			return nil, err
+		}
+	}
+	offset, err := decodeOffsetCursor(input.Cursor)
+	if err != nil {
+		return nil, huma.NewError(http.StatusBadRequest, "invalid cursor")
+	}
+
+	results, err := search.Search(h.db.WithContext(ctx), search.Options{
+		Text:     input.Q,
+		Prefix:   input.Prefix,
+		ViewerID: viewerID,
+		Limit:    input.Limit + 1,
+		Offset:   offset,
+	})
+	if err != nil {
+		return nil, huma.Error500InternalServerError("failed to search")
+	}
+	page := &schemas.SearchPage{Items: results}
+	if len(results) > input.Limit {
+		page.Items = results[:input.Limit]
+		page.NextCursor = encodeOffsetCursor(offset + input.Limit)
+	}
+	return &schemas.SearchOutput{Body: page}, nil
+}
+
+// Ranked results have no key to resume after, so their cursors count the
+// results already returned.
+
+func encodeOffsetCursor(offset int) string {
+	return base64.RawURLEncoding.EncodeToString(strconv.AppendInt(nil, int64(offset), 10))
+}
+
+func decodeOffsetCursor(cursor string) (int, error) {
+	if cursor == "" {
+		return 0, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, err
+	}
+	offset, err := strconv.Atoi(string(raw))
+	if err == nil && offset < 0 {
+		err = strconv.ErrRange
+	}
+	return offset, err
+}
@@ -0,0 +1,384 @@
+package handlers
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"workout-tracker/backend/models"
+	"workout-tracker/backend/notify"
+	"workout-tracker/backend/schemas"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type SocialHandler struct {
+	db       *gorm.DB
+	notifier notify.Notifier
+}
+
+func NewSocialHandler(db *gorm.DB, notifier notify.Notifier) *SocialHandler {
+	return &SocialHandler{db: db, notifier: notifier}
+}
+
+func (h *SocialHandler) RegisterRoutes(api huma.API) {
+	v1_0 := huma.NewGroup(api, "/api/v1")
+	huma.Post(v1_0, "/users/{userId}/follow", h.Follow)
+	huma.Delete(v1_0, "/users/{userId}/follow", h.Unfollow)
+	huma.Get(v1_0, "/users/{userId}/followers", h.ListFollowers)
+	huma.Get(v1_0, "/users/{userId}/following", h.ListFollowing)
+	huma.Get(v1_0, "/feed", h.Feed)
+	huma.Post(v1_0, "/workouts/{workoutId}/likes", h.LikeWorkout)
+	huma.Delete(v1_0, "/workouts/{workoutId}/likes", h.UnlikeWorkout)
+	huma.Get(v1_0, "/workouts/{workoutId}/comments", h.ListComments)
+	huma.Post(v1_0, "/workouts/{workoutId}/comments", h.CreateComment)
+	huma.Delete(v1_0, "/workouts/{workoutId}/comments/{commentId}", h.DeleteComment)
+}
+
+// notify hands n to the configured Notifier. Delivery problems are logged
+// rather than failing the request that triggered them.
+func (h *SocialHandler) notify(ctx context.Context, n notify.Notification) {
+	if n.RecipientID == n.ActorID {
+		return
+	}
+	if err := h.notifier.Notify(ctx, n); err != nil {
+		slog.WarnContext(ctx, "notification failed", "kind", n.Kind, "recipient", n.RecipientID, "err", err)
+	}
+}
+
+// visibleWorkout loads a workout the caller is allowed to see, answering 404
+// for both missing and hidden workouts so their existence isn't leaked.
+func (h *SocialHandler) visibleWorkout(ctx context.Context, workoutID uuid.UUID) (*models.Workout, error) {
+	var w models.Workout
+	if err := h.db.Where("public_id = ?", workoutID).First(&w).Error; err != nil {
+		return nil, huma.NewError(http.StatusNotFound, "workout not found")
+	}
+	viewerID, err := resolveUserID(ctx, h.db)
+	if err != nil {
+		return nil, huma.Error500InternalServerError("failed to resolve user")
+	}
+	visible, err := canViewWorkout(h.db, w, viewerID)
+	if err != nil {
+		return nil, huma.Error500InternalServerError("failed to check visibility")
+	}
+	if !visible {
+		return nil, huma.NewError(http.StatusNotFound, "workout not found")
+	}
+	return &w, nil
+}
+
+func (h *SocialHandler) Follow(ctx context.Context, input *schemas.FollowInput) (*struct{}, error) {
+	followerID, err := callerID(ctx, h.db, input.Body.FollowerID)
+	if err != nil {
+		return nil, err
+	}
+	var followee models.User
+	if err := h.db.Where("public_id = ?", input.UserID).First(&followee).Error; err != nil {
+		return nil, huma.NewError(http.StatusNotFound, "user not found")
+	}
+	if followerID == followee.ID {
+		return nil, huma.NewError(http.StatusUnprocessableEntity, "users cannot follow themselves")
+	}
+
+	f := models.Follow{FollowerID: followerID, FolloweeID: followee.ID}
+	res := h.db.Where(models.Follow{FollowerID: followerID, FolloweeID: followee.ID}).FirstOrCreate(&f)
+	if res.Error != nil {
+		return nil, huma.Error500InternalServerError("failed to follow user")
+	}
+	if res.RowsAffected > 0 {
+		h.notify(ctx, notify.Notification{Kind: notify.KindFollow, RecipientID: followee.ID, ActorID: followerID})
+	}
+	return nil, nil
+}
+
+func (h *SocialHandler) Unfollow(ctx context.Context, input *schemas.UnfollowInput) (*struct{}, error) {
+	followerID, err := callerID(ctx, h.db, input.FollowerID)
+	if err != nil {
+		return nil, err
+	}
+	err = h.db.Unscoped().
+		Where("follower_id = ? AND followee_id = (?)", followerID, h.userByPublicID(input.UserID)).
+		Delete(&models.Follow{}).Error
+	if err != nil {
+		return nil, huma.Error500InternalServerError("failed to unfollow user")
+	}
+	return nil, nil
+}
+
+func (h *SocialHandler) ListFollowers(ctx context.Context, input *schemas.ListFollowsInput) (*schemas.ListFollowsOutput, error) {
+	var follows []models.Follow
+	if err := h.db.Preload("Follower").Where("followee_id = (?)", h.userByPublicID(input.UserID)).Order("created_at DESC").Find(&follows).Error; err != nil {
+		return nil, huma.Error500InternalServerError("failed to fetch followers")
+	}
+	out := &schemas.ListFollowsOutput{Body: make([]schemas.FollowResponse, len(follows))}
+	for i, f := range follows {
+		out.Body[i] = schemas.FollowResponse{UserID: f.Follower.PublicID, Name: f.Follower.Name, Since: f.CreatedAt}
+	}
+	return out, nil
+}
+
+func (h *SocialHandler) ListFollowing(ctx context.Context, input *schemas.ListFollowsInput) (*schemas.ListFollowsOutput, error) {
+	var follows []models.Follow
+	if err := h.db.Preload("Followee").Where("follower_id = (?)", h.userByPublicID(input.UserID)).Order("created_at DESC").Find(&follows).Error; err != nil {
+		return nil, huma.Error500InternalServerError("failed to fetch followed users")
+	}
+	out := &schemas.ListFollowsOutput{Body: make([]schemas.FollowResponse, len(follows))}
+	for i, f := range follows {
+		out.Body[i] = schemas.FollowResponse{UserID: f.Followee.PublicID, Name: f.Followee.Name, Since: f.CreatedAt}
+	}
+	return out, nil
+}
+
+// Feed assembles the viewer's activity feed on read: the newest shared
+// workouts of everyone they follow, paginated by a (created_at, id) keyset
+// rather than an offset, so later pages don't re-read the earlier ones.
+// idx_workouts_feed is ordered to match the keyset.
+func (h *SocialHandler) Feed(ctx context.Context, input *schemas.FeedInput) (*schemas.FeedOutput, error) {
+	viewerID, err := callerID(ctx, h.db, input.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	q := h.db.Preload("User").
+		Where("user_id IN (?)", h.db.Model(&models.Follow{}).Select("followee_id").Where("follower_id = ?", viewerID)).
+		Where("visibility IN ?", []string{models.VisibilityFollowers, models.VisibilityPublic})
+	if input.Cursor != "" {
+		at, publicID, err := decodeFeedCursor(input.Cursor)
+		if err != nil {
+			return nil, huma.NewError(http.StatusBadRequest, "invalid cursor")
+		}
+		// The workout a page ended on may have been deleted since.
+		id, err := lookupID(h.db.Unscoped(), &models.Workout{}, publicID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, huma.NewError(http.StatusBadRequest, "invalid cursor")
+		}
+		if err != nil {
+			return nil, huma.Error500InternalServerError("failed to fetch feed")
+		}
+		q = q.Where("(created_at, id) < (?, ?)", at, id)
+	}
+
+	var workouts []models.Workout
+	if err := q.Order("created_at DESC, id DESC").Limit(input.Limit + 1).Find(&workouts).Error; err != nil {
+		return nil, huma.Error500InternalServerError("failed to fetch feed")
+	}
+
+	page := &schemas.FeedPage{Items: []schemas.FeedItem{}}
+	if len(workouts) > input.Limit {
+		workouts = workouts[:input.Limit]
+		last := workouts[len(workouts)-1]
+		page.NextCursor = encodeFeedCursor(last.CreatedAt, last.PublicID)
+	}
+	if len(workouts) == 0 {
+		return &schemas.FeedOutput{Body: page}, nil
+	}
+
+	ids := make([]int64, len(workouts))
+	for i, w := range workouts {
+		ids[i] = w.ID
+	}
+	likes, err := h.countByWorkout(&models.WorkoutLike{}, ids)
+	if err != nil {
+		return nil, huma.Error500InternalServerError("failed to count likes")
+	}
+	comments, err := h.countByWorkout(&models.WorkoutComment{}, ids)
+	if err != nil {
+		return nil, huma.Error500InternalServerError("failed to count comments")
+	}
+	var liked []int64
+	if err := h.db.Model(&models.WorkoutLike{}).
+		Where("workout_id IN ? AND user_id = ?", ids, viewerID).
+		Pluck("workout_id", &liked).Error; err != nil {
+		return nil, huma.Error500InternalServerError("failed to fetch likes")
+	}
+	likedSet := make(map[int64]bool, len(liked))
+	for _, id := range liked {
+		likedSet[id] = true
+	}
+
+	page.Items = make([]schemas.FeedItem, len(workouts))
+	for i, w := range workouts {
+		page.Items[i] = schemas.FeedItem{
+			WorkoutResponse: workoutToResponse(w, w.User.PublicID),
+			AuthorName:      w.User.Name,
+			LikeCount:       likes[w.ID],
+			CommentCount:    comments[w.ID],
+			LikedByMe:       likedSet[w.ID],
+		}
+	}
+	return &schemas.FeedOutput{Body: page}, nil
+}
+
+// countByWorkout counts rows of model per workout_id for the given workouts.
+func (h *SocialHandler) countByWorkout(model any, workoutIDs []int64) (map[int64]int64, error) {
+	var rows []struct {
+		WorkoutID int64
+		N         int64
+	}
+	err := h.db.Model(model).
+		Select("workout_id, COUNT(*) AS n").
+		Where("workout_id IN ?", workoutIDs).
+		Group("workout_id").
+		Scan(&rows).Error
+	counts := make(map[int64]int64, len(rows))
+	for _, r := range rows {
+		counts[r.WorkoutID] = r.N
+	}
+	return counts, err
+}
+
+func (h *SocialHandler) LikeWorkout(ctx context.Context, input *schemas.LikeWorkoutInput) (*struct{}, error) {
+	userID, err := callerID(ctx, h.db, input.Body.UserID)
+	if err != nil {
+		return nil, err
+	}
+	w, err := h.visibleWorkout(ctx, input.WorkoutID)
+	if err != nil {
+		return nil, err
+	}
+
+	like := models.WorkoutLike{WorkoutID: w.ID, UserID: userID}
+	res := h.db.Where(models.WorkoutLike{WorkoutID: w.ID, UserID: userID}).FirstOrCreate(&like)
+	if res.Error != nil {
+		return nil, huma.Error500InternalServerError("failed to like workout")
+	}
+	if res.RowsAffected > 0 {
+		h.notify(ctx, notify.Notification{Kind: notify.KindLike, RecipientID: w.UserID, ActorID: userID, WorkoutID: w.ID})
+	}
+	return nil, nil
+}
+
+func (h *SocialHandler) UnlikeWorkout(ctx context.Context, input *schemas.UnlikeWorkoutInput) (*struct{}, error) {
+	userID, err := callerID(ctx, h.db, input.UserID)
+	if err != nil {
+		return nil, err
+	}
+	err = h.db.Unscoped().
+		Where("workout_id = (?) AND user_id = ?",
+			h.db.Model(&models.Workout{}).Select("id").Where("public_id = ?", input.WorkoutID), userID).
+		Delete(&models.WorkoutLike{}).Error
+	if err != nil {
+		return nil, huma.Error500InternalServerError("failed to unlike workout")
+	}
+	return nil, nil
+}
+
+func (h *SocialHandler) ListComments(ctx context.Context, input *schemas.ListCommentsInput) (*schemas.ListCommentsOutput, error) {
+	w, err := h.visibleWorkout(ctx, input.WorkoutID)
+	if err != nil {
+		return nil, err
+	}
+	var comments []models.WorkoutComment
+	if err := h.db.Preload("User").Where("workout_id = ?", w.ID).Order("created_at").Find(&comments).Error; err != nil {
+		return nil, huma.Error500InternalServerError("failed to fetch comments")
+	}
+	out := &schemas.ListCommentsOutput{Body: make([]schemas.CommentResponse, len(comments))}
+	for i, c := range comments {
+		out.Body[i] = commentToResponse(c, w.PublicID)
+	}
+	return out, nil
+}
+
+func (h *SocialHandler) CreateComment(ctx context.Context, input *schemas.CreateCommentInput) (*schemas.CreateCommentOutput, error) {
+	userID, err := callerID(ctx, h.db, input.Body.UserID)
+	if err != nil {
+		return nil, err
+	}
+	w, err := h.visibleWorkout(ctx, input.WorkoutID)
+	if err != nil {
+		return nil, err
+	}
+
+	c := models.WorkoutComment{
+		BaseModel: models.BaseModel{PublicID: input.Body.ID},
+		WorkoutID: w.ID,
+		UserID:    userID,
+		Body:      input.Body.Body,
+	}
+	if err := h.db.Create(&c).Error; err != nil {
+		if isDuplicate(err) {
+			return nil, huma.NewError(http.StatusConflict, "a comment with this id already exists")
+		}
+		return nil, huma.Error500InternalServerError("failed to create comment")
+	}
+	if err := h.db.First(&c.User, userID).Error; err != nil {
+		return nil, huma.Error500InternalServerError("failed to load comment author")
+	}
+	h.notify(ctx, notify.Notification{Kind: notify.KindComment, RecipientID: w.UserID, ActorID: userID, WorkoutID: w.ID, CommentID: c.ID})
+	r := commentToResponse(c, w.PublicID)
+	return &schemas.CreateCommentOutput{Status: 201, Body: &r}, nil
+}
+
+func (h *SocialHandler) DeleteComment(ctx context.Context, input *schemas.DeleteCommentInput) (*struct{}, error) {
+	var c models.WorkoutComment
+	err := h.db.Preload("Workout").
+		Where("workout_id = (?) AND public_id = ?",
+			h.db.Model(&models.Workout{}).Select("id").Where("public_id = ?", input.WorkoutID), input.CommentID).
+		First(&c).Error
+	if err != nil {
+		return nil, huma.NewError(http.StatusNotFound, "comment not found")
+	}
+	userID, err := resolveUserID(ctx, h.db)
+	if err != nil {
+		return nil, huma.Error500InternalServerError("failed to resolve user")
+	}
+	// Comments can be removed by their author or by the workout's owner.
+	if userID != 0 && userID != c.UserID && userID != c.Workout.UserID {
+		return nil, huma.NewError(http.StatusForbidden, "not allowed to delete this comment")
+	}
+	if err := h.db.Delete(&c).Error; err != nil {
+		return nil, huma.Error500InternalServerError("failed to delete comment")
+	}
+	return nil, nil
+}
+
+// userByPublicID is a subquery for the ID of the user with public ID
+// userID.
+func (h *SocialHandler) userByPublicID(userID uuid.UUID) *gorm.DB {
+	return h.db.Model(&models.User{}).Select("id").Where("public_id = ?", userID)
+}
+
+// encodeFeedCursor names the last workout of a page by its creation time
+// and public ID; Feed resolves the ID again when the cursor comes back.
+func encodeFeedCursor(at time.Time, id uuid.UUID) string {
+	return base64.RawURLEncoding.EncodeToString(fmt.Appendf(nil, "%d:%s", at.UnixNano(), id))
+}
+
+func decodeFeedCursor(cursor string) (time.Time, uuid.UUID, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, uuid.Nil, err
+	}
+	nanos, id, ok := strings.Cut(string(raw), ":")
+	if !ok {
+		return time.Time{}, uuid.Nil, errors.New("malformed cursor")
+	}
+	n, err := strconv.ParseInt(nanos, 10, 64)
+	if err != nil {
+		return time.Time{}, uuid.Nil, err
+	}
+	publicID, err := uuid.Parse(id)
+	if err != nil {
+		return time.Time{}, uuid.Nil, err
+	}
+	return time.Unix(0, n).UTC(), publicID, nil
+}
+
+func commentToResponse(c models.WorkoutComment, workoutID uuid.UUID) schemas.CommentResponse {
+	return schemas.CommentResponse{
+		ID:         c.PublicID,
+		WorkoutID:  workoutID,
+		UserID:     c.User.PublicID,
+		AuthorName: c.User.Name,
+		Body:       c.Body,
+		CreatedAt:  c.CreatedAt,
+	}
+}
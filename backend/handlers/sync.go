@@ -0,0 +1,371 @@
+package handlers
+
+import (
+	"cmp"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"slices"
+	"strconv"
+	"time"
+
+	"workout-tracker/backend/audit"
+	"workout-tracker/backend/events"
+	"workout-tracker/backend/models"
+	"workout-tracker/backend/patch"
+	"workout-tracker/backend/schemas"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// syncSettle is how far a sync cursor trails the newest change. Event IDs
+// are handed out as rows are inserted, not as their transactions commit,
+// so a cursor at the newest event could skip one a slower transaction
+// commits later. Changes inside the window are sent again on the next
+// sync, which clients that apply changes by version take in their stride.
+const syncSettle = 5 * time.Second
+
+// SyncHandler serves delta sync for offline-first clients.
+type SyncHandler struct {
+	db *gorm.DB
+}
+
+func NewSyncHandler(db *gorm.DB) *SyncHandler {
+	return &SyncHandler{db: db}
+}
+
+func (h *SyncHandler) RegisterRoutes(api huma.API) {
+	v1_0 := huma.NewGroup(api, "/api/v1")
+	huma.Register(v1_0, huma.Operation{
+		OperationID: "sync-workouts",
+		Method:      http.MethodPost,
+		Path:        "/sync",
+		Summary:     "Sync workouts with an offline client",
+		Description: "Applies the changes a client made since its last sync, then returns every workout changed " +
+			"or deleted since the cursor. Conflicts are resolved field by field and the server is authoritative: " +
+			"a field changed both on the server since the client's base version and by the client keeps the " +
+			"server's value and is reported as a conflict, while fields only the client changed are applied. " +
+			"A deletion made on the server wins over the client's edits, and a deletion made on the client is " +
+			"refused when the server changed the workout since the client's base version.",
+	}, h.Sync)
+}
+
+// Sync pushes the client's changes, each in a transaction of its own, and
+// then reads the changes since the cursor, so the client learns the
+// versions its own changes were given. A server error stops the sync with
+// the changes before it applied; sending them again is safe, since a
+// change the server already has is no conflict.
+func (h *SyncHandler) Sync(ctx context.Context, input *schemas.SyncInput) (*schemas.SyncOutput, error) {
+	userID, err := callerID(ctx, h.db, input.Body.UserID)
+	if err != nil {
+		return nil, err
+	}
+	after, err := decodeSyncCursor(input.Body.Cursor)
+	if err != nil {
+		return nil, huma.NewError(http.StatusBadRequest, "invalid cursor")
+	}
+	owner, err := publicID(h.db, &models.User{}, userID)
+	if err != nil {
+		return nil, huma.Error500InternalServerError("failed to fetch user")
+	}
+
+	out := &schemas.SyncOutput{}
+	out.Body.Results = make([]schemas.SyncChangeResult, len(input.Body.Changes))
+	for i, c := range input.Body.Changes {
+		var res schemas.SyncChangeResult
+		err := h.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			var err error
+			res, err = syncChange(tx, userID, owner, c)
+			return err
+		})
+		var se huma.StatusError
+		if errors.As(err, &se) && se.GetStatus() < http.StatusInternalServerError {
+			res = schemas.SyncChangeResult{Status: schemas.SyncRejected}
+			errors.As(err, &res.Error)
+		} else if err != nil {
+			return nil, huma.Error500InternalServerError("failed to apply changes")
+		}
+		res.Index = i
+		out.Body.Results[i] = res
+	}
+
+	limit := input.Body.Limit
+	if limit <= 0 {
+		limit = 100
+	}
+	changes, next, more, err := h.changes(ctx, userID, owner, after, limit)
+	if err != nil {
+		return nil, huma.Error500InternalServerError("failed to fetch changes")
+	}
+	out.Body.Changes = changes
+	out.Body.NextCursor = encodeSyncCursor(next)
+	// A page of changes too recent to move the cursor past would come back
+	// unchanged; leave them for the next sync.
+	out.Body.HasMore = more && next != after
+	return out, nil
+}
+
+// syncChange applies one pushed change for userID, whose public ID is
+// owner, through tx. Changes the server turns down are returned as Huma
+// errors.
+func syncChange(tx *gorm.DB, userID int64, owner uuid.UUID, c schemas.SyncChange) (schemas.SyncChangeResult, error) {
+	res := schemas.SyncChangeResult{Status: schemas.SyncApplied}
+	if c.Fields == nil {
+		c.Fields = map[string]any{}
+	}
+	if c.ID == uuid.Nil {
+		return res, huma.Error422UnprocessableEntity("id is required")
+	}
+	var w models.Workout
+	err := tx.Unscoped().Where("public_id = ?", c.ID).Take(&w).Error
+	switch {
+	case err == nil && w.UserID != userID:
+		return res, huma.NewError(http.StatusNotFound, "workout not found")
+	case errors.Is(err, gorm.ErrRecordNotFound) && c.Deleted:
+		// Created and deleted before it ever reached the server.
+		return res, nil
+	case errors.Is(err, gorm.ErrRecordNotFound) && c.BaseVersion != 0:
+		// The client synced it before, so it has since been deleted for good.
+		res.Status = schemas.SyncConflict
+		res.Conflicts = []schemas.SyncFieldConflict{{Field: "deleted", ClientValue: false, ServerValue: true}}
+		res.Workout = &schemas.SyncedWorkout{ID: c.ID, Deleted: true}
+		return res, nil
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return syncCreate(tx, userID, owner, c)
+	case err != nil:
+		return res, err
+	case w.DeletedAt.Valid:
+		if !c.Deleted {
+			res.Status = schemas.SyncConflict
+			res.Conflicts = []schemas.SyncFieldConflict{{Field: "deleted", ClientValue: false, ServerValue: true}}
+		}
+		res.Workout = syncedWorkout(w, owner)
+		return res, nil
+	}
+
+	current := workoutFields(w)
+	changed, known, err := changedSince(tx, w, owner, c.BaseVersion)
+	if err != nil {
+		return res, err
+	}
+	if c.Deleted {
+		if !known || len(changed) > 0 {
+			res.Status = schemas.SyncConflict
+			res.Conflicts = []schemas.SyncFieldConflict{{Field: "deleted", ClientValue: true, ServerValue: false}}
+			res.Workout = syncedWorkout(w, owner)
+			return res, nil
+		}
+		if err := deleteWorkout(tx, &w, true); err != nil {
+			return res, staleSync(err)
+		}
+		w.DeletedAt = gorm.DeletedAt{Time: time.Now(), Valid: true}
+		res.Workout = syncedWorkout(w, owner)
+		return res, nil
+	}
+
+	body, _ := json.Marshal(c.Fields)
+	desired, err := patch.To(current, patch.MergePatchType, body)
+	if err != nil {
+		return res, err
+	}
+	want, have := fieldValues(desired), fieldValues(current)
+	for name := range c.Fields {
+		if (!known || changed[name]) && !reflect.DeepEqual(want[name], have[name]) {
+			res.Conflicts = append(res.Conflicts, schemas.SyncFieldConflict{Field: name, ClientValue: want[name], ServerValue: have[name]})
+			want[name] = have[name]
+		}
+	}
+	if len(res.Conflicts) > 0 {
+		res.Status = schemas.SyncConflict
+		slices.SortFunc(res.Conflicts, func(a, b schemas.SyncFieldConflict) int {
+			return cmp.Compare(a.Field, b.Field)
+		})
+	}
+	if !reflect.DeepEqual(want, have) {
+		var resolved schemas.WorkoutFields
+		raw, _ := json.Marshal(want)
+		if err := json.Unmarshal(raw, &resolved); err != nil {
+			return res, err
+		}
+		if _, err := updateWorkout(tx, &w, resolved, userID); err != nil {
+			return res, staleSync(err)
+		}
+	}
+	res.Workout = syncedWorkout(w, owner)
+	return res, nil
+}
+
+// syncCreate creates a workout a client made offline.
+func syncCreate(tx *gorm.DB, userID int64, owner uuid.UUID, c schemas.SyncChange) (schemas.SyncChangeResult, error) {
+	res := schemas.SyncChangeResult{Status: schemas.SyncApplied}
+	body, _ := json.Marshal(c.Fields)
+	f, err := patch.To(workoutFields(models.Workout{PerformedAt: time.Now()}), patch.MergePatchType, body)
+	if err != nil {
+		return res, err
+	}
+	w := newWorkout(userID, schemas.NewWorkout{
+		ID:              c.ID,
+		Name:            f.Name,
+		Description:     f.Description,
+		DurationMinutes: f.DurationMinutes,
+		DistanceMeters:  f.DistanceMeters,
+		PerformedAt:     f.PerformedAt,
+		Visibility:      f.Visibility,
+		Tags:            f.Tags,
+		CustomFields:    f.CustomFields,
+		Exercises:       f.Exercises,
+		Groups:          f.Groups,
+	})
+	if _, err := createWorkout(tx, &w, userID); err != nil {
+		return res, err
+	}
+	res.Workout = syncedWorkout(w, owner)
+	return res, nil
+}
+
+// changedSince returns the fields of w changed since version base. known
+// is false when the workout at base can't be found, so every field must be
+// assumed changed.
+func changedSince(tx *gorm.DB, w models.Workout, owner uuid.UUID, base int64) (map[string]bool, bool, error) {
+	if base == w.Version {
+		return nil, true, nil
+	}
+	if base == 0 {
+		return nil, false, nil
+	}
+	var rev models.WorkoutRevision
+	err := tx.Where("workout_id = ? AND snapshot->>'version' = ?", w.ID, strconv.FormatInt(base, 10)).
+		Order("revision DESC").Take(&rev).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	before, err := revisionFields(rev.Snapshot)
+	if err != nil {
+		return nil, false, err
+	}
+	snapshot, err := json.Marshal(workoutToResponse(w, owner))
+	if err != nil {
+		return nil, false, err
+	}
+	after, err := revisionFields(snapshot)
+	if err != nil {
+		return nil, false, err
+	}
+	changed := map[string]bool{}
+	for field := range audit.Diff(before, after) {
+		changed[field] = true
+	}
+	return changed, true, nil
+}
+
+// staleSync reports a change lost to a write racing the sync.
+func staleSync(err error) error {
+	if errors.Is(err, errStale) {
+		return huma.Error409Conflict("workout changed while syncing; sync again")
+	}
+	return err
+}
+
+// fieldValues returns f as the JSON object a client sees.
+func fieldValues(f schemas.WorkoutFields) map[string]any {
+	f.PerformedAt = f.PerformedAt.UTC()
+	raw, _ := json.Marshal(f)
+	var values map[string]any
+	_ = json.Unmarshal(raw, &values)
+	return values
+}
+
+// changes returns userID's workouts changed after event ID after, in the
+// order of their latest change, with the cursor to carry on from and
+// whether more are waiting.
+func (h *SyncHandler) changes(ctx context.Context, userID int64, owner uuid.UUID, after int64, limit int) ([]schemas.SyncedWorkout, int64, bool, error) {
+	var rows []struct {
+		ResourceID       int64
+		ResourcePublicID uuid.UUID
+		LastEvent        int64
+		ChangedAt        time.Time
+	}
+	err := h.db.WithContext(ctx).Model(&models.Event{}).
+		Select("resource_id, resource_public_id, MAX(id) AS last_event, MAX(created_at) AS changed_at").
+		Where("user_id = ? AND resource_type = ? AND id > ?", userID, events.ResourceWorkout, after).
+		Group("resource_id, resource_public_id").
+		Order("last_event").
+		Limit(limit + 1).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, 0, false, err
+	}
+	more := len(rows) > limit
+	if more {
+		rows = rows[:limit]
+	}
+	ids := make([]int64, len(rows))
+	for i, r := range rows {
+		ids[i] = r.ResourceID
+	}
+	byID := map[int64]models.Workout{}
+	if len(ids) > 0 {
+		var workouts []models.Workout
+		if err := h.db.WithContext(ctx).Unscoped().Where("id IN ?", ids).Find(&workouts).Error; err != nil {
+			return nil, 0, false, err
+		}
+		for _, w := range workouts {
+			byID[w.ID] = w
+		}
+	}
+
+	next := after
+	cutoff := time.Now().Add(-syncSettle)
+	settled := true
+	out := make([]schemas.SyncedWorkout, len(rows))
+	for i, r := range rows {
+		if w, ok := byID[r.ResourceID]; ok {
+			out[i] = *syncedWorkout(w, owner)
+		} else {
+			// Purged from the trash: only the tombstone is left to send.
+			out[i] = schemas.SyncedWorkout{ID: r.ResourcePublicID, Deleted: true}
+		}
+		// The cursor stops short of the first change that may not have
+		// settled.
+		settled = settled && r.ChangedAt.Before(cutoff)
+		if settled {
+			next = r.LastEvent
+		}
+	}
+	return out, next, more, nil
+}
+
+func syncedWorkout(w models.Workout, owner uuid.UUID) *schemas.SyncedWorkout {
+	s := &schemas.SyncedWorkout{ID: w.PublicID, Version: w.Version}
+	if w.DeletedAt.Valid {
+		s.Deleted = true
+		s.DeletedAt = &w.DeletedAt.Time
+		return s
+	}
+	r := workoutToResponse(w, owner)
+	s.Workout = &r
+	return s
+}
+
+func encodeSyncCursor(eventID int64) string {
+	return base64.RawURLEncoding.EncodeToString(strconv.AppendInt(nil, eventID, 10))
+}
+
+func decodeSyncCursor(cursor string) (int64, error) {
+	if cursor == "" {
+		return 0, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(string(raw), 10, 64)
+}
@@ -2,14 +2,18 @@ package handlers
 
 import (
 	"context"
+	"errors"
 	"net/http"
 
 	"workout-tracker/backend/middleware"
 	"workout-tracker/backend/models"
+	"workout-tracker/backend/patch"
 	"workout-tracker/backend/roles"
 	"workout-tracker/backend/schemas"
 
 	"github.com/danielgtaylor/huma/v2"
+	"github.com/danielgtaylor/huma/v2/conditional"
+	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
@@ -26,6 +30,8 @@ func (h *UserHandler) RegisterRoutes(api huma.API) {
 	huma.Get(v1_0, "/users", h.ListUsers)
 	huma.Get(v1_0, "/users/{userId}", h.GetUser)
 	huma.Post(v1_0, "/users", h.CreateUser)
+	huma.Put(v1_0, "/users/{userId}", h.ReplaceUser)
+	huma.Patch(v1_0, "/users/{userId}", h.UpdateUser, patch.Operation[schemas.UserFields](v1_0))
 }
 
 func (h *UserHandler) ListUsers(ctx context.Context, input *schemas.ListUsersInput) (*schemas.ListUsersOutput, error) {
@@ -50,31 +56,101 @@ func (h *UserHandler) ListUsers(ctx context.Context, input *schemas.ListUsersInp
 
 func (h *UserHandler) GetUser(ctx context.Context, input *schemas.GetUserInput) (*schemas.GetUserOutput, error) {
 	var user models.User
-	if err := h.db.First(&user, input.UserID).Error; err != nil {
+	if err := h.db.Where("public_id = ?", input.UserID).First(&user).Error; err != nil {
 		return nil, huma.NewError(http.StatusNotFound, "user not found")
 	}
+	if err := input.PreconditionFailed(versionTag(user.Version), user.UpdatedAt); err != nil {
+		return nil, huma.ErrorWithHeaders(err, http.Header{"ETag": {etag(user.Version)}})
+	}
 	r := userToResponse(user)
-	return &schemas.GetUserOutput{Body: &r}, nil
+	return &schemas.GetUserOutput{ETag: etag(user.Version), Body: &r}, nil
 }
 
 func (h *UserHandler) CreateUser(ctx context.Context, input *schemas.CreateUserInput) (*schemas.CreateUserOutput, error) {
 	user := models.User{
+		BaseModel:    models.BaseModel{PublicID: input.Body.ID},
 		Email:        input.Body.Email,
 		Name:         input.Body.Name,
 		PasswordHash: input.Body.Password, // TODO: hash before storing
 	}
-	if err := h.db.Create(&user).Error; err != nil {
+	if err := h.db.WithContext(ctx).Create(&user).Error; err != nil {
+		if isDuplicate(err) {
+			return nil, huma.NewError(http.StatusConflict, "a user with this id already exists")
+		}
 		return nil, huma.Error500InternalServerError("failed to create user")
 	}
 	r := userToResponse(user)
 	return &schemas.CreateUserOutput{Status: 201, Body: &r}, nil
 }
 
+// UpdateUser applies a JSON Merge Patch or JSON Patch to a user's fields.
+func (h *UserHandler) UpdateUser(ctx context.Context, input *schemas.UpdateUserInput) (*schemas.UpdateUserOutput, error) {
+	user, err := h.editable(ctx, input.UserID, input.Params)
+	if err != nil {
+		return nil, err
+	}
+	fields, err := patch.To(userFields(*user), input.ContentType, input.Body)
+	if err != nil {
+		return nil, err
+	}
+	return h.replace(ctx, user, fields)
+}
+
+// ReplaceUser sets every field of a user.
+func (h *UserHandler) ReplaceUser(ctx context.Context, input *schemas.ReplaceUserInput) (*schemas.UpdateUserOutput, error) {
+	user, err := h.editable(ctx, input.UserID, input.Params)
+	if err != nil {
+		return nil, err
+	}
+	return h.replace(ctx, user, input.Body)
+}
+
+// editable loads a user the caller may edit, themselves unless they are
+// an admin, checking the request's preconditions against it.
+func (h *UserHandler) editable(ctx context.Context, userID uuid.UUID, cond conditional.Params) (*models.User, error) {
+	callerID, err := resolveUserID(ctx, h.db)
+	if err != nil {
+		return nil, huma.Error500InternalServerError("failed to resolve user")
+	}
+	var user models.User
+	if err := h.db.Where("public_id = ?", userID).First(&user).Error; err != nil {
+		return nil, huma.NewError(http.StatusNotFound, "user not found")
+	}
+	if callerID != 0 && callerID != user.ID {
+		if err := requireAdmin(ctx); err != nil {
+			return nil, err
+		}
+	}
+	if err := cond.PreconditionFailed(versionTag(user.Version), user.UpdatedAt); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (h *UserHandler) replace(ctx context.Context, user *models.User, f schemas.UserFields) (*schemas.UpdateUserOutput, error) {
+	user.Email = f.Email
+	user.Name = f.Name
+	err := saveVersioned(h.db.WithContext(ctx), user, &user.Version)
+	if errors.Is(err, errStale) {
+		return nil, huma.Error412PreconditionFailed("user was changed by another request; reload it and retry")
+	}
+	if err != nil {
+		return nil, huma.Error500InternalServerError("failed to update user")
+	}
+	r := userToResponse(*user)
+	return &schemas.UpdateUserOutput{ETag: etag(user.Version), Body: &r}, nil
+}
+
+func userFields(u models.User) schemas.UserFields {
+	return schemas.UserFields{Email: u.Email, Name: u.Name}
+}
+
 func userToResponse(u models.User) schemas.UserResponse {
 	return schemas.UserResponse{
-		ID:        u.ID,
+		ID:        u.PublicID,
 		Email:     u.Email,
 		Name:      u.Name,
+		Version:   u.Version,
 		CreatedAt: u.CreatedAt,
 		UpdatedAt: u.UpdatedAt,
 	}
@@ -0,0 +1,23 @@
+package handlers
+
+import (
+	"workout-tracker/backend/models"
+
+	"gorm.io/gorm"
+)
+
+// canViewWorkout reports whether viewerID may read w. A viewerID of 0 means
+// auth is disabled (dev/test mode), in which case everything is visible.
+func canViewWorkout(db *gorm.DB, w models.Workout, viewerID int64) (bool, error) {
+	switch {
+	case viewerID == 0, w.UserID == viewerID, w.Visibility == models.VisibilityPublic:
+		return true, nil
+	case w.Visibility == models.VisibilityFollowers:
+		var n int64
+		err := db.Model(&models.Follow{}).
+			Where("follower_id = ? AND followee_id = ?", viewerID, w.UserID).
+			Count(&n).Error
+		return n > 0, err
+	}
+	return false, nil
+}
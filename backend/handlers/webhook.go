@@ -0,0 +1,328 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"time"
+
+	"workout-tracker/backend/middleware"
+	"workout-tracker/backend/models"
+	"workout-tracker/backend/netguard"
+	"workout-tracker/backend/patch"
+	"workout-tracker/backend/roles"
+	"workout-tracker/backend/schemas"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type WebhookHandler struct {
+	db *gorm.DB
+}
+
+func NewWebhookHandler(db *gorm.DB) *WebhookHandler {
+	return &WebhookHandler{db: db}
+}
+
+func (h *WebhookHandler) RegisterRoutes(api huma.API) {
+	v1_0 := huma.NewGroup(api, "/api/v1")
+	huma.Get(v1_0, "/webhooks", h.ListWebhooks)
+	huma.Post(v1_0, "/webhooks", h.CreateWebhook)
+	huma.Get(v1_0, "/webhooks/{webhookId}", h.GetWebhook)
+	huma.Patch(v1_0, "/webhooks/{webhookId}", h.UpdateWebhook, patch.Operation[schemas.WebhookFields](v1_0))
+	huma.Delete(v1_0, "/webhooks/{webhookId}", h.DeleteWebhook)
+	huma.Get(v1_0, "/webhooks/{webhookId}/deliveries", h.ListDeliveries)
+	huma.Get(v1_0, "/webhooks/{webhookId}/deliveries/{deliveryId}", h.GetDelivery)
+	huma.Post(v1_0, "/webhooks/{webhookId}/deliveries/{deliveryId}/redeliver", h.Redeliver)
+}
+
+// authorize loads a webhook the caller may manage: their own, or a
+// site-wide one if they are an admin. Others get a 404 so webhook IDs are
+// not disclosed. Every caller passes in dev/test mode.
+func (h *WebhookHandler) authorize(ctx context.Context, webhookID uuid.UUID) (*models.Webhook, error) {
+	var w models.Webhook
+	if err := h.db.Where("public_id = ?", webhookID).First(&w).Error; err != nil {
+		return nil, huma.NewError(http.StatusNotFound, "webhook not found")
+	}
+	userID, err := resolveUserID(ctx, h.db)
+	if err != nil {
+		return nil, huma.Error500InternalServerError("failed to resolve user")
+	}
+	if userID == 0 {
+		return &w, nil
+	}
+	if w.UserID == nil {
+		if !middleware.GetAuth(ctx).IsGrantedRole(roles.Admin) {
+			return nil, huma.NewError(http.StatusNotFound, "webhook not found")
+		}
+	} else if *w.UserID != userID {
+		return nil, huma.NewError(http.StatusNotFound, "webhook not found")
+	}
+	return &w, nil
+}
+
+func (h *WebhookHandler) ListWebhooks(ctx context.Context, input *schemas.ListWebhooksInput) (*schemas.ListWebhooksOutput, error) {
+	userID, err := resolveUserID(ctx, h.db)
+	if err != nil {
+		return nil, huma.Error500InternalServerError("failed to resolve user")
+	}
+	q := h.db.Order("id")
+	if userID != 0 {
+		if middleware.GetAuth(ctx).IsGrantedRole(roles.Admin) {
+			q = q.Where("user_id = ? OR user_id IS NULL", userID)
+		} else {
+			q = q.Where("user_id = ?", userID)
+		}
+	} else if input.UserID != uuid.Nil {
+		// Dev/test fallback: honour the query-param filter.
+		q = q.Where("user_id = (?)", h.db.Model(&models.User{}).Select("id").Where("public_id = ?", input.UserID))
+	}
+
+	var list []models.Webhook
+	if err := q.Find(&list).Error; err != nil {
+		return nil, huma.Error500InternalServerError("failed to fetch webhooks")
+	}
+	body, err := webhookResponses(h.db, list...)
+	if err != nil {
+		return nil, huma.Error500InternalServerError("failed to fetch webhooks")
+	}
+	return &schemas.ListWebhooksOutput{Body: body}, nil
+}
+
+// checkWebhookURL rejects webhook URLs that aren't http(s) or that point
+// at an internal host. Hostnames resolving to internal addresses are
+// refused by the Dispatcher's client when it connects.
+func checkWebhookURL(raw string) error {
+	if err := netguard.CheckURL(raw, "https", "http"); err != nil {
+		return huma.Error422UnprocessableEntity("url: " + err.Error())
+	}
+	return nil
+}
+
+func (h *WebhookHandler) CreateWebhook(ctx context.Context, input *schemas.CreateWebhookInput) (*schemas.CreateWebhookOutput, error) {
+	if err := checkWebhookURL(input.Body.URL); err != nil {
+		return nil, err
+	}
+	w := models.Webhook{
+		BaseModel:  models.BaseModel{PublicID: input.Body.ID},
+		URL:        input.Body.URL,
+		EventTypes: input.Body.EventTypes,
+		Secret:     input.Body.Secret,
+		Active:     true,
+	}
+	if input.Body.SiteWide {
+		if authCtx := middleware.GetAuth(ctx); authCtx != nil && !authCtx.IsGrantedRole(roles.Admin) {
+			return nil, huma.NewError(http.StatusForbidden, "admin role required for site-wide webhooks")
+		}
+	} else {
+		userID, err := callerID(ctx, h.db, input.Body.UserID)
+		if err != nil {
+			return nil, err
+		}
+		w.UserID = &userID
+	}
+	if w.Secret == "" {
+		buf := make([]byte, 32)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, huma.Error500InternalServerError("failed to generate secret")
+		}
+		w.Secret = hex.EncodeToString(buf)
+	}
+	if err := h.db.Omit("User").Create(&w).Error; err != nil {
+		if isDuplicate(err) {
+			return nil, huma.NewError(http.StatusConflict, "a webhook with this id already exists")
+		}
+		return nil, huma.Error500InternalServerError("failed to create webhook")
+	}
+	r, err := webhookResponses(h.db, w)
+	if err != nil {
+		return nil, huma.Error500InternalServerError("failed to fetch webhook")
+	}
+	r[0].Secret = w.Secret
+	return &schemas.CreateWebhookOutput{Status: 201, Body: &r[0]}, nil
+}
+
+func (h *WebhookHandler) GetWebhook(ctx context.Context, input *schemas.GetWebhookInput) (*schemas.GetWebhookOutput, error) {
+	w, err := h.authorize(ctx, input.WebhookID)
+	if err != nil {
+		return nil, err
+	}
+	r, err := webhookResponses(h.db, *w)
+	if err != nil {
+		return nil, huma.Error500InternalServerError("failed to fetch webhook")
+	}
+	return &schemas.GetWebhookOutput{Body: &r[0]}, nil
+}
+
+func (h *WebhookHandler) UpdateWebhook(ctx context.Context, input *schemas.UpdateWebhookInput) (*schemas.GetWebhookOutput, error) {
+	w, err := h.authorize(ctx, input.WebhookID)
+	if err != nil {
+		return nil, err
+	}
+	eventTypes := w.EventTypes
+	if eventTypes == nil {
+		eventTypes = []string{}
+	}
+	f, err := patch.To(schemas.WebhookFields{URL: w.URL, EventTypes: eventTypes, Active: w.Active}, input.ContentType, input.Body)
+	if err != nil {
+		return nil, err
+	}
+	if f.URL != w.URL {
+		if err := checkWebhookURL(f.URL); err != nil {
+			return nil, err
+		}
+	}
+	w.URL = f.URL
+	w.EventTypes = f.EventTypes
+	w.Active = f.Active
+	if err := h.db.Omit("User").Save(w).Error; err != nil {
+		return nil, huma.Error500InternalServerError("failed to update webhook")
+	}
+	r, err := webhookResponses(h.db, *w)
+	if err != nil {
+		return nil, huma.Error500InternalServerError("failed to fetch webhook")
+	}
+	return &schemas.GetWebhookOutput{Body: &r[0]}, nil
+}
+
+func (h *WebhookHandler) DeleteWebhook(ctx context.Context, input *schemas.GetWebhookInput) (*struct{}, error) {
+	w, err := h.authorize(ctx, input.WebhookID)
+	if err != nil {
+		return nil, err
+	}
+	// Pending deliveries fail on their next attempt once the webhook is gone.
+	if err := h.db.Delete(w).Error; err != nil {
+		return nil, huma.Error500InternalServerError("failed to delete webhook")
+	}
+	return nil, nil
+}
+
+func (h *WebhookHandler) ListDeliveries(ctx context.Context, input *schemas.ListDeliveriesInput) (*schemas.ListDeliveriesOutput, error) {
+	w, err := h.authorize(ctx, input.WebhookID)
+	if err != nil {
+		return nil, err
+	}
+	q := h.db.Where("webhook_id = ?", w.ID).Order("id DESC").Limit(100)
+	if input.Status != "" {
+		q = q.Where("status = ?", input.Status)
+	}
+	var list []models.WebhookDelivery
+	if err := q.Find(&list).Error; err != nil {
+		return nil, huma.Error500InternalServerError("failed to fetch deliveries")
+	}
+	out := &schemas.ListDeliveriesOutput{Body: make([]schemas.WebhookDeliveryResponse, len(list))}
+	for i, d := range list {
+		out.Body[i] = deliveryToResponse(d, w.PublicID)
+	}
+	return out, nil
+}
+
+// delivery loads a delivery of an authorized webhook, with the webhook.
+func (h *WebhookHandler) delivery(ctx context.Context, webhookID, deliveryID uuid.UUID) (*models.Webhook, *models.WebhookDelivery, error) {
+	w, err := h.authorize(ctx, webhookID)
+	if err != nil {
+		return nil, nil, err
+	}
+	var d models.WebhookDelivery
+	if err := h.db.Where("webhook_id = ? AND public_id = ?", w.ID, deliveryID).First(&d).Error; err != nil {
+		return nil, nil, huma.NewError(http.StatusNotFound, "delivery not found")
+	}
+	return w, &d, nil
+}
+
+func (h *WebhookHandler) GetDelivery(ctx context.Context, input *schemas.GetDeliveryInput) (*schemas.GetDeliveryOutput, error) {
+	w, d, err := h.delivery(ctx, input.WebhookID, input.DeliveryID)
+	if err != nil {
+		return nil, err
+	}
+	var attempts []models.WebhookAttempt
+	if err := h.db.Where("delivery_id = ?", d.ID).Order("id").Find(&attempts).Error; err != nil {
+		return nil, huma.Error500InternalServerError("failed to fetch attempts")
+	}
+	r := deliveryToResponse(*d, w.PublicID)
+	r.History = make([]schemas.WebhookAttemptResponse, len(attempts))
+	for i, a := range attempts {
+		r.History[i] = schemas.WebhookAttemptResponse{
+			ID:         a.PublicID,
+			StatusCode: a.StatusCode,
+			Error:      a.Error,
+			DurationMS: a.DurationMS,
+			CreatedAt:  a.CreatedAt,
+		}
+	}
+	return &schemas.GetDeliveryOutput{Body: &r}, nil
+}
+
+// Redeliver puts a delivery back in the queue with a fresh set of attempts;
+// earlier attempts stay in its history.
+func (h *WebhookHandler) Redeliver(ctx context.Context, input *schemas.GetDeliveryInput) (*schemas.RedeliverOutput, error) {
+	w, d, err := h.delivery(ctx, input.WebhookID, input.DeliveryID)
+	if err != nil {
+		return nil, err
+	}
+	d.Status = models.DeliveryStatusPending
+	d.Attempts = 0
+	d.NextAttemptAt = time.Now()
+	d.DeliveredAt = nil
+	err = h.db.Model(d).Select("Status", "Attempts", "NextAttemptAt", "DeliveredAt").Updates(d).Error
+	if err != nil {
+		return nil, huma.Error500InternalServerError("failed to queue redelivery")
+	}
+	r := deliveryToResponse(*d, w.PublicID)
+	return &schemas.RedeliverOutput{Status: http.StatusAccepted, Body: &r}, nil
+}
+
+// webhookResponses maps webhooks to responses, looking up the public IDs
+// of their owners.
+func webhookResponses(db *gorm.DB, list ...models.Webhook) ([]schemas.WebhookResponse, error) {
+	userIDs := make([]int64, 0, len(list))
+	for _, w := range list {
+		if w.UserID != nil {
+			userIDs = append(userIDs, *w.UserID)
+		}
+	}
+	users, err := publicIDs(db, &models.User{}, userIDs...)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]schemas.WebhookResponse, len(list))
+	for i, w := range list {
+		out[i] = webhookToResponse(w, optionalPublicID(users, w.UserID))
+	}
+	return out, nil
+}
+
+func webhookToResponse(w models.Webhook, userID *uuid.UUID) schemas.WebhookResponse {
+	types := w.EventTypes
+	if types == nil {
+		types = []string{}
+	}
+	return schemas.WebhookResponse{
+		ID:         w.PublicID,
+		UserID:     userID,
+		URL:        w.URL,
+		EventTypes: types,
+		Active:     w.Active,
+		CreatedAt:  w.CreatedAt,
+		UpdatedAt:  w.UpdatedAt,
+	}
+}
+
+func deliveryToResponse(d models.WebhookDelivery, webhookID uuid.UUID) schemas.WebhookDeliveryResponse {
+	r := schemas.WebhookDeliveryResponse{
+		ID:          d.PublicID,
+		WebhookID:   webhookID,
+		EventID:     d.EventID,
+		Status:      d.Status,
+		Attempts:    d.Attempts,
+		DeliveredAt: d.DeliveredAt,
+		CreatedAt:   d.CreatedAt,
+	}
+	if d.Status == models.DeliveryStatusPending {
+		r.NextAttemptAt = &d.NextAttemptAt
+	}
+	return r
+}
@@ -2,166 +2,545 @@ package handlers
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"net/http"
+	"strconv"
+	"time"
 
-	"workout-tracker/backend/middleware"
+	"workout-tracker/backend/jobs"
 	"workout-tracker/backend/models"
+	"workout-tracker/backend/patch"
 	"workout-tracker/backend/schemas"
+	"workout-tracker/backend/training"
 
 	"github.com/danielgtaylor/huma/v2"
+	"github.com/danielgtaylor/huma/v2/conditional"
+	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
 type WorkoutHandler struct {
 	db *gorm.DB
+	// retention is how long deleted workouts stay in the trash before the
+	// purge job removes them; it only informs clients here.
+	retention time.Duration
 }
 
-func NewWorkoutHandler(db *gorm.DB) *WorkoutHandler {
-	return &WorkoutHandler{db: db}
+func NewWorkoutHandler(db *gorm.DB, retention time.Duration) *WorkoutHandler {
+	return &WorkoutHandler{db: db, retention: retention}
 }
 
 func (h *WorkoutHandler) RegisterRoutes(api huma.API) {
 	v1_0 := huma.NewGroup(api, "/api/v1")
 	huma.Get(v1_0, "/workouts", h.ListWorkouts)
+	huma.Get(v1_0, "/workouts/trash", h.ListTrash)
+	huma.Delete(v1_0, "/workouts/trash", h.EmptyTrash)
+	huma.Delete(v1_0, "/workouts/trash/{workoutId}", h.PurgeWorkout)
 	huma.Get(v1_0, "/workouts/{workoutId}", h.GetWorkout)
 	huma.Post(v1_0, "/workouts", h.CreateWorkout)
-	huma.Patch(v1_0, "/workouts/{workoutId}", h.UpdateWorkout)
+	huma.Post(v1_0, "/workouts:batch", h.BatchWorkouts, literalPath(v1_0))
+	huma.Put(v1_0, "/workouts/{workoutId}", h.ReplaceWorkout)
+	huma.Patch(v1_0, "/workouts/{workoutId}", h.UpdateWorkout, patch.Operation[schemas.WorkoutFields](v1_0))
 	huma.Delete(v1_0, "/workouts/{workoutId}", h.DeleteWorkout)
+	huma.Post(v1_0, "/workouts/{workoutId}/restore", h.RestoreWorkout)
+	huma.Get(v1_0, "/workouts/{workoutId}/revisions", h.ListRevisions)
+	huma.Get(v1_0, "/workouts/{workoutId}/revisions/{revision}/diff", h.DiffRevisions)
+	huma.Post(v1_0, "/workouts/{workoutId}/revisions/{revision}/restore", h.RestoreRevision)
 }
 
-// resolveUserID returns the local user.ID for the current request.
-// When Zitadel auth is active it finds or auto-creates a local User from the
-// token claims. In dev/test mode (no auth context) it returns 0 so callers
-// can fall back to a user_id supplied in the request body.
-func (h *WorkoutHandler) resolveUserID(ctx context.Context) (int64, error) {
-	info := middleware.GetUserInfo(ctx)
-	if info == nil {
-		return 0, nil
-	}
-
-	var user models.User
-	err := h.db.Where("zitadel_id = ?", info.ZitadelID).First(&user).Error
-	if err == nil {
-		return user.ID, nil
-	}
-	if !errors.Is(err, gorm.ErrRecordNotFound) {
-		return 0, err
+// owns reports whether the caller may manage a workout owned by ownerID.
+// Every caller passes in dev/test mode.
+func (h *WorkoutHandler) owns(ctx context.Context, ownerID int64) (bool, error) {
+	userID, err := resolveUserID(ctx, h.db)
+	if err != nil {
+		return false, huma.Error500InternalServerError("failed to resolve user")
 	}
+	return userID == 0 || userID == ownerID, nil
+}
 
-	// First request from this Zitadel user — provision a local record.
-	email := info.Email
-	if email == "" {
-		email = info.ZitadelID + "@zitadel.local"
+// trashed loads one of the caller's deleted workouts, answering 404 for
+// live ones and other users'.
+func (h *WorkoutHandler) trashed(ctx context.Context, workoutID uuid.UUID) (*models.Workout, error) {
+	var w models.Workout
+	if err := h.db.Unscoped().Where("public_id = ? AND deleted_at IS NOT NULL", workoutID).First(&w).Error; err != nil {
+		return nil, huma.NewError(http.StatusNotFound, "workout not in trash")
 	}
-	name := info.Username
-	if name == "" {
-		name = info.ZitadelID
+	ok, err := h.owns(ctx, w.UserID)
+	if err != nil {
+		return nil, err
 	}
-	user = models.User{ZitadelID: &info.ZitadelID, Email: email, Name: name}
-	if err := h.db.Create(&user).Error; err != nil {
-		return 0, err
+	if !ok {
+		return nil, huma.NewError(http.StatusNotFound, "workout not in trash")
 	}
-	return user.ID, nil
+	return &w, nil
 }
 
 func (h *WorkoutHandler) ListWorkouts(ctx context.Context, input *schemas.ListWorkoutsInput) (*schemas.ListWorkoutsOutput, error) {
 	var workouts []models.Workout
 	q := h.db
 
-	userID, err := h.resolveUserID(ctx)
+	userID, err := resolveUserID(ctx, h.db)
 	if err != nil {
 		return nil, huma.Error500InternalServerError("failed to resolve user")
 	}
 	if userID != 0 {
 		// Auth active: scope results to the current user.
 		q = q.Where("user_id = ?", userID)
-	} else if input.UserID != 0 {
+	} else if input.UserID != uuid.Nil {
 		// Dev/test fallback: honour the query-param filter.
-		q = q.Where("user_id = ?", input.UserID)
+		q = q.Where("user_id = (?)", h.db.Model(&models.User{}).Select("id").Where("public_id = ?", input.UserID))
+	}
+	if len(input.Tags) > 0 {
+		tags, err := normalizeTags(input.Tags)
+		if err != nil {
+			return nil, err
+		}
+		doc, _ := json.Marshal(tags)
+		q = q.Where("tags @> ?", string(doc))
+	}
+	if len(input.Fields) > 0 {
+		// Filter values are read by the type of the owner's field, so
+		// there must be an owner.
+		ownerID, err := callerID(ctx, h.db, input.UserID)
+		if err != nil {
+			return nil, err
+		}
+		if q, err = filterByFields(h.db, q, ownerID, input.Fields); err != nil {
+			return nil, err
+		}
 	}
 
 	if err := q.Find(&workouts).Error; err != nil {
 		return nil, huma.Error500InternalServerError("failed to fetch workouts")
 	}
-	out := &schemas.ListWorkoutsOutput{Body: make([]schemas.WorkoutResponse, len(workouts))}
-	for i, w := range workouts {
-		out.Body[i] = workoutToResponse(w)
+	body, err := workoutResponses(h.db, workouts...)
+	if err != nil {
+		return nil, huma.Error500InternalServerError("failed to fetch workouts")
 	}
-	return out, nil
+	return &schemas.ListWorkoutsOutput{Body: body}, nil
 }
 
-func (h *WorkoutHandler) GetWorkout(ctx context.Context, input *schemas.GetWorkoutInput) (*schemas.GetWorkoutOutput, error) {
-	var workout models.Workout
-	if err := h.db.First(&workout, input.WorkoutID).Error; err != nil {
-		return nil, huma.NewError(http.StatusNotFound, "workout not found")
+func (h *WorkoutHandler) GetWorkout(ctx context.Context, input *schemas.GetWorkoutInput) (*schemas.GetWorkoutDetailOutput, error) {
+	workout, err := h.viewable(ctx, input.WorkoutID)
+	if err != nil {
+		return nil, err
 	}
-	r := workoutToResponse(workout)
-	return &schemas.GetWorkoutOutput{Body: &r}, nil
+	// Clients revalidating a cached copy get a 304 while it is current.
+	if err := input.PreconditionFailed(versionTag(workout.Version), workout.UpdatedAt); err != nil {
+		return nil, huma.ErrorWithHeaders(err, http.Header{"ETag": {etag(workout.Version)}})
+	}
+	r, err := workoutResponse(h.db, *workout)
+	if err != nil {
+		return nil, huma.Error500InternalServerError("failed to fetch workout")
+	}
+	structure, err := training.Tree(r.Groups, r.Exercises)
+	if err != nil {
+		return nil, huma.Error500InternalServerError("failed to fetch workout")
+	}
+	if structure == nil {
+		structure = []training.Node{}
+	}
+	body := &schemas.WorkoutDetailResponse{WorkoutResponse: *r, Structure: structure}
+	return &schemas.GetWorkoutDetailOutput{ETag: etag(workout.Version), Body: body}, nil
 }
 
 func (h *WorkoutHandler) CreateWorkout(ctx context.Context, input *schemas.CreateWorkoutInput) (*schemas.CreateWorkoutOutput, error) {
-	userID, err := h.resolveUserID(ctx)
+	userID, err := callerID(ctx, h.db, input.Body.UserID)
 	if err != nil {
-		return nil, huma.Error500InternalServerError("failed to resolve user")
-	}
-	if userID == 0 {
-		// Dev/test fallback: accept user_id from the request body.
-		if input.Body.UserID == 0 {
-			return nil, huma.NewError(http.StatusUnauthorized, "authentication required")
-		}
-		userID = input.Body.UserID
+		return nil, err
 	}
 
-	workout := models.Workout{
-		UserID:          userID,
-		Name:            input.Body.Name,
-		Description:     input.Body.Description,
-		DurationMinutes: input.Body.DurationMinutes,
+	workout := newWorkout(userID, input.Body)
+	var r schemas.WorkoutResponse
+	err = h.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		r, err = createWorkout(tx, &workout, userID)
+		return err
+	})
+	if isDuplicate(err) {
+		return nil, huma.Error409Conflict("a workout with this id already exists")
+	}
+	var se huma.StatusError
+	if errors.As(err, &se) {
+		return nil, err
 	}
-	if err := h.db.Create(&workout).Error; err != nil {
+	if err != nil {
 		return nil, huma.Error500InternalServerError("failed to create workout")
 	}
-	r := workoutToResponse(workout)
-	return &schemas.CreateWorkoutOutput{Status: 201, Body: &r}, nil
+	return &schemas.CreateWorkoutOutput{Status: 201, ETag: etag(workout.Version), Body: &r}, nil
 }
 
+// UpdateWorkout applies a JSON Merge Patch or JSON Patch to a workout's
+// fields. Only the fields the patch names change.
 func (h *WorkoutHandler) UpdateWorkout(ctx context.Context, input *schemas.UpdateWorkoutInput) (*schemas.UpdateWorkoutOutput, error) {
+	workout, err := h.editable(ctx, input.WorkoutID, input.Params)
+	if err != nil {
+		return nil, err
+	}
+	fields, err := patch.To(workoutFields(*workout), input.ContentType, input.Body)
+	if err != nil {
+		return nil, err
+	}
+	return h.replace(ctx, workout, fields)
+}
+
+// ReplaceWorkout sets every field of a workout; optional fields left out
+// are cleared.
+func (h *WorkoutHandler) ReplaceWorkout(ctx context.Context, input *schemas.ReplaceWorkoutInput) (*schemas.UpdateWorkoutOutput, error) {
+	workout, err := h.editable(ctx, input.WorkoutID, input.Params)
+	if err != nil {
+		return nil, err
+	}
+	return h.replace(ctx, workout, input.Body)
+}
+
+// editable loads a workout the caller owns, checking the request's
+// preconditions against it.
+func (h *WorkoutHandler) editable(ctx context.Context, workoutID uuid.UUID, cond conditional.Params) (*models.Workout, error) {
 	var workout models.Workout
-	if err := h.db.First(&workout, input.WorkoutID).Error; err != nil {
+	if err := h.db.Where("public_id = ?", workoutID).First(&workout).Error; err != nil {
+		return nil, huma.NewError(http.StatusNotFound, "workout not found")
+	}
+	ok, err := h.owns(ctx, workout.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
 		return nil, huma.NewError(http.StatusNotFound, "workout not found")
 	}
-	if input.Body.Name != "" {
-		workout.Name = input.Body.Name
+	// If-Match lets a client make sure it edits the version it has seen.
+	if err := cond.PreconditionFailed(versionTag(workout.Version), workout.UpdatedAt); err != nil {
+		return nil, err
 	}
-	if input.Body.Description != "" {
-		workout.Description = input.Body.Description
+	return &workout, nil
+}
+
+// replace writes f over workout.
+func (h *WorkoutHandler) replace(ctx context.Context, workout *models.Workout, f schemas.WorkoutFields) (*schemas.UpdateWorkoutOutput, error) {
+	authorID, err := resolveUserID(ctx, h.db)
+	if err != nil {
+		return nil, huma.Error500InternalServerError("failed to resolve user")
 	}
-	if input.Body.DurationMinutes != 0 {
-		workout.DurationMinutes = input.Body.DurationMinutes
+	var r schemas.WorkoutResponse
+	err = h.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		r, err = updateWorkout(tx, workout, f, authorID)
+		return err
+	})
+	if errors.Is(err, errStale) {
+		return nil, huma.Error412PreconditionFailed("workout was changed by another request; reload it and retry")
 	}
-	if err := h.db.Save(&workout).Error; err != nil {
+	var se huma.StatusError
+	if errors.As(err, &se) {
+		return nil, err
+	}
+	if err != nil {
 		return nil, huma.Error500InternalServerError("failed to update workout")
 	}
-	r := workoutToResponse(workout)
-	return &schemas.UpdateWorkoutOutput{Body: &r}, nil
+	return &schemas.UpdateWorkoutOutput{ETag: etag(workout.Version), Body: &r}, nil
 }
 
 func (h *WorkoutHandler) DeleteWorkout(ctx context.Context, input *schemas.DeleteWorkoutInput) (*struct{}, error) {
-	if err := h.db.Delete(&models.Workout{}, input.WorkoutID).Error; err != nil {
+	workout, err := h.editable(ctx, input.WorkoutID, input.Params)
+	if err != nil {
+		return nil, err
+	}
+	err = deleteWorkout(h.db.WithContext(ctx), workout, len(input.IfMatch) > 0)
+	if errors.Is(err, errStale) {
+		return nil, huma.Error412PreconditionFailed("workout was changed by another request; reload it and retry")
+	}
+	if err != nil {
 		return nil, huma.Error500InternalServerError("failed to delete workout")
 	}
 	return nil, nil
 }
 
-func workoutToResponse(w models.Workout) schemas.WorkoutResponse {
+func (h *WorkoutHandler) ListTrash(ctx context.Context, input *schemas.ListTrashInput) (*schemas.ListTrashOutput, error) {
+	userID, err := callerID(ctx, h.db, input.UserID)
+	if err != nil {
+		return nil, err
+	}
+	var workouts []models.Workout
+	err = h.db.Unscoped().
+		Where("user_id = ? AND deleted_at IS NOT NULL", userID).
+		Order("deleted_at DESC, id DESC").
+		Find(&workouts).Error
+	if err != nil {
+		return nil, huma.Error500InternalServerError("failed to fetch trash")
+	}
+	rs, err := workoutResponses(h.db, workouts...)
+	if err != nil {
+		return nil, huma.Error500InternalServerError("failed to fetch trash")
+	}
+	out := &schemas.ListTrashOutput{Body: make([]schemas.TrashedWorkoutResponse, len(workouts))}
+	for i, w := range workouts {
+		out.Body[i] = schemas.TrashedWorkoutResponse{
+			WorkoutResponse: rs[i],
+			DeletedAt:       w.DeletedAt.Time,
+			PurgesAt:        w.DeletedAt.Time.Add(h.retention),
+		}
+	}
+	return out, nil
+}
+
+func (h *WorkoutHandler) RestoreWorkout(ctx context.Context, input *schemas.RestoreWorkoutInput) (*schemas.GetWorkoutOutput, error) {
+	w, err := h.trashed(ctx, input.WorkoutID)
+	if err != nil {
+		return nil, err
+	}
+	err = h.db.WithContext(ctx).Unscoped().Model(w).
+		Updates(map[string]any{"deleted_at": nil, "version": gorm.Expr("version + 1")}).Error
+	if err != nil {
+		return nil, huma.Error500InternalServerError("failed to restore workout")
+	}
+	w.Version++
+	r, err := workoutResponse(h.db, *w)
+	if err != nil {
+		return nil, huma.Error500InternalServerError("failed to restore workout")
+	}
+	return &schemas.GetWorkoutOutput{ETag: etag(w.Version), Body: r}, nil
+}
+
+// PurgeWorkout deletes a workout in the trash for good, with its likes and
+// comments.
+func (h *WorkoutHandler) PurgeWorkout(ctx context.Context, input *schemas.PurgeWorkoutInput) (*struct{}, error) {
+	w, err := h.trashed(ctx, input.WorkoutID)
+	if err != nil {
+		return nil, err
+	}
+	err = h.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		_, err := jobs.PurgeWorkouts(tx, []int64{w.ID})
+		return err
+	})
+	if err != nil {
+		return nil, huma.Error500InternalServerError("failed to delete workout")
+	}
+	return nil, nil
+}
+
+// EmptyTrash deletes every workout in the caller's trash for good.
+func (h *WorkoutHandler) EmptyTrash(ctx context.Context, input *schemas.EmptyTrashInput) (*struct{}, error) {
+	userID, err := callerID(ctx, h.db, input.UserID)
+	if err != nil {
+		return nil, err
+	}
+	err = h.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		_, err := jobs.PurgeWorkouts(tx, tx.Unscoped().Model(&models.Workout{}).Select("id").
+			Where("user_id = ? AND deleted_at IS NOT NULL", userID))
+		return err
+	})
+	if err != nil {
+		return nil, huma.Error500InternalServerError("failed to empty trash")
+	}
+	return nil, nil
+}
+
+// newWorkout builds the workout userID creates from body.
+func newWorkout(userID int64, body schemas.NewWorkout) models.Workout {
+	workout := models.Workout{
+		BaseModel:       models.BaseModel{PublicID: body.ID},
+		UserID:          userID,
+		Name:            body.Name,
+		Description:     body.Description,
+		DurationMinutes: body.DurationMinutes,
+		DistanceMeters:  body.DistanceMeters,
+		PerformedAt:     body.PerformedAt,
+		Visibility:      body.Visibility,
+		Tags:            body.Tags,
+		CustomFields:    body.CustomFields,
+		Exercises:       body.Exercises,
+		Groups:          body.Groups,
+	}
+	if workout.PerformedAt.IsZero() {
+		workout.PerformedAt = time.Now()
+	}
+	if workout.Visibility == "" {
+		workout.Visibility = models.VisibilityPrivate
+	}
+	return workout
+}
+
+// createWorkout inserts workout with its first revision, returning it as
+// the API shows it. Invalid tags, custom field values, exercises or groups
+// are answered with a 422.
+func createWorkout(tx *gorm.DB, workout *models.Workout, authorID int64) (schemas.WorkoutResponse, error) {
+	if err := checkWorkoutFields(tx, workout, nil); err != nil {
+		return schemas.WorkoutResponse{}, err
+	}
+	if err := tx.Create(workout).Error; err != nil {
+		return schemas.WorkoutResponse{}, err
+	}
+	return recordRevision(tx, *workout, authorID, nil)
+}
+
+// updateWorkout writes f over workout, returning errStale when it changed
+// since it was read. Every update is kept as a revision, so what changed
+// can be reviewed and rolled back.
+func updateWorkout(tx *gorm.DB, workout *models.Workout, f schemas.WorkoutFields, authorID int64) (schemas.WorkoutResponse, error) {
+	prev := workout.CustomFields
+	workout.Tags = f.Tags
+	workout.CustomFields = f.CustomFields
+	workout.Exercises = f.Exercises
+	workout.Groups = f.Groups
+	if err := checkWorkoutFields(tx, workout, prev); err != nil {
+		return schemas.WorkoutResponse{}, err
+	}
+	workout.Name = f.Name
+	workout.Description = f.Description
+	workout.DurationMinutes = f.DurationMinutes
+	workout.DistanceMeters = f.DistanceMeters
+	workout.PerformedAt = f.PerformedAt
+	workout.Visibility = f.Visibility
+	if workout.Visibility == "" {
+		workout.Visibility = models.VisibilityPrivate
+	}
+	if err := saveVersioned(tx, workout, &workout.Version); err != nil {
+		return schemas.WorkoutResponse{}, err
+	}
+	return recordRevision(tx, *workout, authorID, nil)
+}
+
+// deleteWorkout moves workout to its owner's trash, where it stays until
+// restored or purged. Deleting the loaded row lets the change feed describe
+// what was removed. With checkVersion it returns errStale when the
+// workout changed since it was read, so a change racing an If-Match check
+// doesn't slip through.
+func deleteWorkout(tx *gorm.DB, workout *models.Workout, checkVersion bool) error {
+	if checkVersion {
+		tx = tx.Where("version = ?", workout.Version)
+	}
+	res := tx.Delete(workout)
+	if res.Error == nil && res.RowsAffected == 0 {
+		res.Error = errStale
+	}
+	return res.Error
+}
+
+func workoutFields(w models.Workout) schemas.WorkoutFields {
+	if w.Visibility == "" {
+		w.Visibility = models.VisibilityPrivate
+	}
+	return schemas.WorkoutFields{
+		Name:            w.Name,
+		Description:     w.Description,
+		DurationMinutes: w.DurationMinutes,
+		DistanceMeters:  w.DistanceMeters,
+		PerformedAt:     w.PerformedAt,
+		Visibility:      w.Visibility,
+		Tags:            nonNilTags(w.Tags),
+		CustomFields:    nonNilFields(w.CustomFields),
+		Exercises:       nonNilExercises(w.Exercises),
+		Groups:          nonNilGroups(w.Groups),
+	}
+}
+
+// workoutResponses converts workouts to responses, looking up the public
+// IDs of their owners.
+func workoutResponses(db *gorm.DB, workouts ...models.Workout) ([]schemas.WorkoutResponse, error) {
+	ownerIDs := make([]int64, len(workouts))
+	for i, w := range workouts {
+		ownerIDs[i] = w.UserID
+	}
+	owners, err := publicIDs(db, &models.User{}, ownerIDs...)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]schemas.WorkoutResponse, len(workouts))
+	for i, w := range workouts {
+		out[i] = workoutToResponse(w, owners[w.UserID])
+	}
+	return out, nil
+}
+
+func workoutResponse(db *gorm.DB, w models.Workout) (*schemas.WorkoutResponse, error) {
+	rs, err := workoutResponses(db, w)
+	if err != nil {
+		return nil, err
+	}
+	return &rs[0], nil
+}
+
+func workoutToResponse(w models.Workout, ownerID uuid.UUID) schemas.WorkoutResponse {
 	return schemas.WorkoutResponse{
-		ID:              w.ID,
-		UserID:          w.UserID,
+		ID:              w.PublicID,
+		UserID:          ownerID,
 		Name:            w.Name,
 		Description:     w.Description,
 		DurationMinutes: w.DurationMinutes,
+		DistanceMeters:  w.DistanceMeters,
+		PerformedAt:     w.PerformedAt,
+		Visibility:      w.Visibility,
+		Tags:            nonNilTags(w.Tags),
+		CustomFields:    nonNilFields(w.CustomFields),
+		Exercises:       nonNilExercises(w.Exercises),
+		Groups:          nonNilGroups(w.Groups),
+		Version:         w.Version,
 		CreatedAt:       w.CreatedAt,
 		UpdatedAt:       w.UpdatedAt,
 	}
 }
+
+// nonNilTags, nonNilFields, nonNilExercises and nonNilGroups show unset
+// tags, custom fields, exercises and groups as empty, never null.
+
+func nonNilTags(tags []string) []string {
+	if tags == nil {
+		return []string{}
+	}
+	return tags
+}
+
+func nonNilFields(fields map[string]any) map[string]any {
+	if fields == nil {
+		return map[string]any{}
+	}
+	return fields
+}
+
+func nonNilExercises(exercises []training.Exercise) []training.Exercise {
+	if exercises == nil {
+		return []training.Exercise{}
+	}
+	return exercises
+}
+
+func nonNilGroups(groups []training.Group) []training.Group {
+	if groups == nil {
+		return []training.Group{}
+	}
+	return groups
+}
+
+// errStale reports a write lost to a concurrent one.
+var errStale = errors.New("record changed since it was read")
+
+// saveVersioned saves row, read at *version, unless another write has
+// bumped the version since, and bumps it. It returns errStale when the
+// row has moved on, so read-modify-write handlers never clobber a change
+// they haven't seen.
+func saveVersioned(tx *gorm.DB, row any, version *int64) error {
+	read := *version
+	*version = read + 1
+	// Selecting every column stops Save from falling back to an upsert
+	// when the condition matches nothing.
+	res := tx.Select("*").Where("version = ?", read).Save(row)
+	if res.Error == nil && res.RowsAffected == 0 {
+		res.Error = errStale
+	}
+	if res.Error != nil {
+		*version = read
+	}
+	return res.Error
+}
+
+// versionTag is the bare entity tag of a record at version v, as
+// conditional.Params compares it.
+func versionTag(v int64) string {
+	return strconv.FormatInt(v, 10)
+}
+
+// etag is the ETag header value for a record at version v.
+func etag(v int64) string {
+	return `"` + versionTag(v) + `"`
+}
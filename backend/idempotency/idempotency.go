@@ -0,0 +1,317 @@
+// Package idempotency makes POST requests safe to retry. A client sends an
+// Idempotency-Key header with a value unique to the request it means; the
+// first request with the key is handled as usual and its response stored,
+// and retries within the window get that response back instead of
+// repeating the request.
+//
+// Keys are kept in Postgres, so retries are recognised whichever instance
+// they reach, and belong to the caller that used them. Reusing a key for a
+// different request is answered with 422, and retrying while the first
+// attempt is still running with 409. The instance running it holds the key
+// with a lease it renews until the response is stored, so a retry only
+// takes the key over once that instance has stopped renewing, i.e. died.
+package idempotency
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"workout-tracker/backend/jobs"
+	"workout-tracker/backend/middleware"
+	"workout-tracker/backend/models"
+
+	"github.com/danielgtaylor/huma/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// Header carries the client's key.
+	Header = "Idempotency-Key"
+	// ReplayedHeader is set to "true" on stored responses played back.
+	ReplayedHeader = "Idempotent-Replayed"
+	// DefaultWindow is how long keys are kept unless configured otherwise.
+	DefaultWindow = 24 * time.Hour
+	// KindPurge is the job that removes expired keys.
+	KindPurge = "idempotency.purge"
+)
+
+const (
+	maxKeyLen = 255
+	// lease is how long a claim on a key holds without being renewed. The
+	// handling instance renews it every lease/3 for as long as the first
+	// attempt runs, however long that is.
+	lease = 30 * time.Second
+	// defaultMaxBodyBytes bounds the body read for the fingerprint when the
+	// operation sets no limit of its own.
+	defaultMaxBodyBytes = 1 << 20
+)
+
+// Store keeps idempotency keys and the responses they got.
+type Store struct {
+	db     *gorm.DB
+	window time.Duration
+	// Now is the clock; tests may pin it.
+	Now func() time.Time
+}
+
+// New returns a store keeping keys for window (DefaultWindow when 0).
+func New(db *gorm.DB, window time.Duration) *Store {
+	if window <= 0 {
+		window = DefaultWindow
+	}
+	return &Store{db: db, window: window, Now: time.Now}
+}
+
+// Use installs the store's middleware on api and documents the
+// Idempotency-Key header on every POST operation. Like any Huma
+// middleware it only applies to operations registered after it.
+func (s *Store) Use(api huma.API) {
+	maxLen := maxKeyLen
+	oapi := api.OpenAPI()
+	oapi.OnAddOperation = append(oapi.OnAddOperation, func(_ *huma.OpenAPI, op *huma.Operation) {
+		if op.Method != http.MethodPost {
+			return
+		}
+		op.Parameters = append(op.Parameters, &huma.Param{
+			Name:        Header,
+			In:          "header",
+			Description: "Unique key making the request safe to retry: retries with the same key get the first response back",
+			Schema:      &huma.Schema{Type: "string", MaxLength: &maxLen},
+		})
+	})
+	api.UseMiddleware(s.Middleware(api))
+}
+
+// Middleware returns Huma middleware handling the Idempotency-Key header
+// on POST requests. Requests without the header, and other methods, pass
+// straight through.
+func (s *Store) Middleware(api huma.API) func(huma.Context, func(huma.Context)) {
+	return func(ctx huma.Context, next func(huma.Context)) {
+		key := ctx.Header(Header)
+		// Multipart uploads are parsed from the request itself rather than
+		// from the body this would read, and too large to keep anyway.
+		if ctx.Method() != http.MethodPost || key == "" || strings.HasPrefix(ctx.Header("Content-Type"), "multipart/") {
+			next(ctx)
+			return
+		}
+		if len(key) > maxKeyLen {
+			_ = huma.WriteErr(api, ctx, http.StatusBadRequest, Header+" must be at most 255 characters")
+			return
+		}
+
+		limit := ctx.Operation().MaxBodyBytes
+		if limit <= 0 {
+			limit = defaultMaxBodyBytes
+		}
+		// Read one byte past the limit so Huma still sees an oversized body
+		// as such.
+		body, err := io.ReadAll(io.LimitReader(ctx.BodyReader(), limit+1))
+		if err != nil {
+			_ = huma.WriteErr(api, ctx, http.StatusBadRequest, "failed to read request body")
+			return
+		}
+		rec := &recorder{humaContext: ctx, body: io.MultiReader(bytes.NewReader(body), ctx.BodyReader()), header: http.Header{}}
+
+		scope := ""
+		if auth := middleware.GetAuth(ctx.Context()); auth != nil {
+			scope = auth.UserID()
+		}
+		fp := fingerprint(ctx, body)
+		row, claimed, err := s.claim(ctx.Context(), scope, key, fp)
+		if err != nil {
+			_ = huma.WriteErr(api, ctx, http.StatusInternalServerError, "failed to check idempotency key")
+			return
+		}
+		switch {
+		case claimed:
+		case row.Fingerprint != fp:
+			_ = huma.WriteErr(api, ctx, http.StatusUnprocessableEntity, Header+" was already used for a different request")
+			return
+		case row.Status == 0:
+			_ = huma.WriteErr(api, ctx, http.StatusConflict, "a request with this "+Header+" is still being processed; retry later")
+			return
+		default:
+			replay(ctx, row)
+			return
+		}
+
+		// Release the key if the handler panics, so the retry runs it again.
+		done := false
+		defer func() {
+			if !done {
+				s.release(row)
+			}
+		}()
+		stop := s.hold(row)
+		next(rec)
+		stop()
+		done = true
+
+		status := rec.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		// Server errors may be transient: let a retry try again rather
+		// than replaying the failure.
+		if status >= http.StatusInternalServerError {
+			s.release(row)
+			return
+		}
+		header, _ := json.Marshal(rec.header)
+		res := s.db.Model(row).Where("status = 0").Updates(map[string]any{"status": status, "header": header, "body": rec.out.Bytes()})
+		switch {
+		case res.Error != nil:
+			slog.Error("idempotency: failed to store response", "key", key, "err", res.Error)
+			s.release(row)
+		case res.RowsAffected == 0:
+			// The lease ran out and a retry took the key over; its
+			// response is the one kept.
+			slog.Error("idempotency: claim on key was lost before the response was stored", "key", key)
+		}
+	}
+}
+
+// claim records key as in use by this request. When it already is, it
+// returns the existing row and false.
+func (s *Store) claim(ctx context.Context, scope, key, fp string) (*models.IdempotencyKey, bool, error) {
+	now := s.Now()
+	db := s.db.WithContext(ctx)
+	// Expired keys are free again, and so are keys whose first attempt
+	// died and stopped renewing its lease.
+	err := db.Where("scope = ? AND key = ? AND (expires_at <= ? OR (status = 0 AND locked_until <= ?))",
+		scope, key, now, now).
+		Delete(&models.IdempotencyKey{}).Error
+	if err != nil {
+		return nil, false, err
+	}
+	row := &models.IdempotencyKey{CreatedAt: now, ExpiresAt: now.Add(s.window), LockedUntil: now.Add(lease), Scope: scope, Key: key, Fingerprint: fp}
+	res := db.Clauses(clause.OnConflict{DoNothing: true}).Create(row)
+	if res.Error != nil {
+		return nil, false, res.Error
+	}
+	if res.RowsAffected == 1 {
+		return row, true, nil
+	}
+	existing := &models.IdempotencyKey{}
+	if err := db.Where("scope = ? AND key = ?", scope, key).Take(existing).Error; err != nil {
+		return nil, false, err
+	}
+	return existing, false, nil
+}
+
+// hold renews the lease on a claimed key until the returned func is
+// called.
+func (s *Store) hold(row *models.IdempotencyKey) (stop func()) {
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Go(func() {
+		t := time.NewTicker(lease / 3)
+		defer t.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-t.C:
+			}
+			res := s.db.Model(&models.IdempotencyKey{}).Where("id = ? AND status = 0", row.ID).
+				Update("locked_until", s.Now().Add(lease))
+			switch {
+			case res.Error != nil:
+				slog.Error("idempotency: failed to renew lease", "key", row.Key, "err", res.Error)
+			case res.RowsAffected == 0:
+				slog.Error("idempotency: claim on key was lost while the request ran", "key", row.Key)
+				return
+			}
+		}
+	})
+	return func() {
+		close(done)
+		wg.Wait()
+	}
+}
+
+// replay answers a retry with the response stored for row.
+func replay(ctx huma.Context, row *models.IdempotencyKey) {
+	var header http.Header
+	_ = json.Unmarshal(row.Header, &header)
+	for name, values := range header {
+		for i, v := range values {
+			if i == 0 {
+				ctx.SetHeader(name, v)
+			} else {
+				ctx.AppendHeader(name, v)
+			}
+		}
+	}
+	ctx.SetHeader(ReplayedHeader, "true")
+	ctx.SetStatus(row.Status)
+	_, _ = ctx.BodyWriter().Write(row.Body)
+}
+
+// release frees a claimed key.
+func (s *Store) release(row *models.IdempotencyKey) {
+	if err := s.db.Delete(row).Error; err != nil {
+		slog.Error("idempotency: failed to release key", "key", row.Key, "err", err)
+	}
+}
+
+// Purge removes expired keys.
+func (s *Store) Purge(ctx context.Context) (int64, error) {
+	res := s.db.WithContext(ctx).Where("expires_at <= ?", s.Now()).Delete(&models.IdempotencyKey{})
+	return res.RowsAffected, res.Error
+}
+
+// Register installs the purge job on r and schedules it hourly on sch.
+func (s *Store) Register(r *jobs.Runner, sch *jobs.Scheduler) error {
+	r.Handle(KindPurge, func(ctx context.Context, _ models.Job) error {
+		_, err := s.Purge(ctx)
+		return err
+	})
+	return sch.Every("idempotency-purge", "15 * * * *", KindPurge, nil)
+}
+
+// fingerprint identifies a request by its method, path and body.
+func fingerprint(ctx huma.Context, body []byte) string {
+	h := sha256.New()
+	u := ctx.URL()
+	h.Write([]byte(ctx.Method() + " " + u.Path + "?" + u.RawQuery + "\n"))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+type humaContext = huma.Context
+
+// recorder passes a request on with its body restored and keeps a copy of
+// the response written.
+type recorder struct {
+	humaContext
+	body   io.Reader
+	header http.Header
+	out    bytes.Buffer
+}
+
+func (r *recorder) BodyReader() io.Reader { return r.body }
+
+func (r *recorder) SetHeader(name, value string) {
+	r.header.Set(name, value)
+	r.humaContext.SetHeader(name, value)
+}
+
+func (r *recorder) AppendHeader(name, value string) {
+	r.header.Add(name, value)
+	r.humaContext.AppendHeader(name, value)
+}
+
+func (r *recorder) BodyWriter() io.Writer {
+	return io.MultiWriter(r.humaContext.BodyWriter(), &r.out)
+}
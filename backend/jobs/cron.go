@@ -0,0 +1,185 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Schedule is a parsed five-field cron expression: minute, hour, day of
+// month, month and day of week (0 or 7 is Sunday). Each field accepts *,
+// numbers, ranges (a-b), lists (a,b) and steps (*/n, a-b/n). As in cron,
+// when both day fields are restricted a time matches if either does.
+type Schedule struct {
+	minute, hour, dom, month, dow uint64
+	domAny, dowAny                bool
+}
+
+// ParseSchedule parses a five-field cron expression.
+func ParseSchedule(expr string) (Schedule, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return Schedule{}, fmt.Errorf("cron %q: want 5 fields, got %d", expr, len(fields))
+	}
+	var s Schedule
+	var err error
+	if s.minute, err = parseField(fields[0], 0, 59); err != nil {
+		return Schedule{}, fmt.Errorf("cron %q: minute: %w", expr, err)
+	}
+	if s.hour, err = parseField(fields[1], 0, 23); err != nil {
+		return Schedule{}, fmt.Errorf("cron %q: hour: %w", expr, err)
+	}
+	if s.dom, err = parseField(fields[2], 1, 31); err != nil {
+		return Schedule{}, fmt.Errorf("cron %q: day of month: %w", expr, err)
+	}
+	if s.month, err = parseField(fields[3], 1, 12); err != nil {
+		return Schedule{}, fmt.Errorf("cron %q: month: %w", expr, err)
+	}
+	if s.dow, err = parseField(fields[4], 0, 7); err != nil {
+		return Schedule{}, fmt.Errorf("cron %q: day of week: %w", expr, err)
+	}
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+	s.domAny = fields[2] == "*"
+	s.dowAny = fields[4] == "*"
+	return s, nil
+}
+
+// parseField turns one cron field into a bit set of the values it allows.
+func parseField(field string, lo, hi int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		rng, step := part, 1
+		if i := strings.IndexByte(part, '/'); i >= 0 {
+			n, err := strconv.Atoi(part[i+1:])
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("bad step in %q", part)
+			}
+			rng, step = part[:i], n
+		}
+		from, to := lo, hi
+		if rng != "*" {
+			a, b, isRange := strings.Cut(rng, "-")
+			var err error
+			if from, err = strconv.Atoi(a); err != nil {
+				return 0, fmt.Errorf("bad value %q", a)
+			}
+			to = from
+			if isRange {
+				if to, err = strconv.Atoi(b); err != nil {
+					return 0, fmt.Errorf("bad value %q", b)
+				}
+			} else if step > 1 {
+				to = hi
+			}
+		}
+		if from < lo || to > hi || from > to {
+			return 0, fmt.Errorf("%q out of range %d-%d", part, lo, hi)
+		}
+		for v := from; v <= to; v += step {
+			bits |= 1 << v
+		}
+	}
+	return bits, nil
+}
+
+// Matches reports whether t's minute is in the schedule.
+func (s Schedule) Matches(t time.Time) bool {
+	if s.minute&(1<<t.Minute()) == 0 || s.hour&(1<<t.Hour()) == 0 || s.month&(1<<int(t.Month())) == 0 {
+		return false
+	}
+	dom := s.dom&(1<<t.Day()) != 0
+	dow := s.dow&(1<<int(t.Weekday())) != 0
+	switch {
+	case s.domAny && s.dowAny:
+		return true
+	case s.domAny:
+		return dow
+	case s.dowAny:
+		return dom
+	default:
+		return dom || dow
+	}
+}
+
+type task struct {
+	name     string
+	schedule Schedule
+	kind     string
+	args     any
+	opts     []Option
+}
+
+// Scheduler enqueues jobs for periodic tasks. Every instance may run one:
+// each run is enqueued with a unique key of task name and minute, so only
+// the first instance to reach a slot creates the job.
+type Scheduler struct {
+	db *gorm.DB
+
+	mu    sync.Mutex
+	tasks []task
+
+	// Location is the time zone schedules are evaluated in.
+	Location *time.Location
+	// Now is the clock; tests may pin it.
+	Now func() time.Time
+}
+
+func NewScheduler(db *gorm.DB) *Scheduler {
+	return &Scheduler{db: db, Location: time.UTC, Now: time.Now}
+}
+
+// Every enqueues a job of kind with args whenever spec matches. name
+// identifies the task in unique keys and must stay stable across deploys.
+func (s *Scheduler) Every(name, spec, kind string, args any, opts ...Option) error {
+	sched, err := ParseSchedule(spec)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tasks = append(s.tasks, task{name: name, schedule: sched, kind: kind, args: args, opts: opts})
+	return nil
+}
+
+// Run enqueues due tasks at the start of every minute until ctx is
+// cancelled.
+func (s *Scheduler) Run(ctx context.Context) {
+	for {
+		if err := s.Tick(s.Now()); err != nil {
+			slog.Error("jobs: scheduling failed", "err", err)
+		}
+		next := s.Now().Truncate(time.Minute).Add(time.Minute)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Until(next)):
+		}
+	}
+}
+
+// Tick enqueues every task whose schedule matches the minute containing now.
+func (s *Scheduler) Tick(now time.Time) error {
+	slot := now.In(s.Location).Truncate(time.Minute)
+	s.mu.Lock()
+	tasks := append([]task(nil), s.tasks...)
+	s.mu.Unlock()
+	for _, t := range tasks {
+		if !t.schedule.Matches(slot) {
+			continue
+		}
+		key := t.name + ":" + slot.UTC().Format(time.RFC3339)
+		opts := append([]Option{RunAt(slot), Unique(key)}, t.opts...)
+		if _, err := Enqueue(s.db, t.kind, t.args, opts...); err != nil {
+			return fmt.Errorf("task %s: %w", t.name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,284 @@
+// Package jobs runs background work outside the request path.
+//
+// Jobs are rows in the jobs table. Anything with a *gorm.DB can Enqueue
+// one, optionally inside its own transaction; a Runner started from main
+// claims due jobs per queue with SELECT ... FOR UPDATE SKIP LOCKED, so any
+// number of instances can share the work, and retries failures with
+// backoff until a job is moved to the dead-letter state. A Scheduler
+// enqueues periodic tasks on cron schedules.
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"workout-tracker/backend/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DefaultQueue is used when a job names no queue.
+const DefaultQueue = "default"
+
+// ErrLeaseLost is returned when a run ends after its job's lease expired
+// and another worker claimed the job; the run's outcome is not recorded.
+var ErrLeaseLost = errors.New("jobs: lease lost")
+
+// Handler performs a job. A returned error schedules a retry; the job's
+// Args are decoded by the handler itself.
+type Handler func(ctx context.Context, job models.Job) error
+
+// Option customises a job being enqueued.
+type Option func(*models.Job)
+
+// InQueue puts the job on the named queue instead of DefaultQueue.
+func InQueue(name string) Option {
+	return func(j *models.Job) { j.Queue = name }
+}
+
+// RunAt delays the job until t.
+func RunAt(t time.Time) Option {
+	return func(j *models.Job) { j.RunAt = t }
+}
+
+// MaxAttempts overrides how many times the job is tried before it is dead.
+func MaxAttempts(n int) Option {
+	return func(j *models.Job) { j.MaxAttempts = n }
+}
+
+// Unique makes enqueueing another job with the same key a no-op for as
+// long as this one exists.
+func Unique(key string) Option {
+	return func(j *models.Job) { j.UniqueKey = &key }
+}
+
+// Enqueue adds a job of kind with args encoded as JSON. Pass a transaction
+// as db to enqueue atomically with other writes. When a Unique key is
+// already taken nothing is inserted and the returned job has ID 0.
+func Enqueue(db *gorm.DB, kind string, args any, opts ...Option) (models.Job, error) {
+	body, err := json.Marshal(args)
+	if err != nil {
+		return models.Job{}, err
+	}
+	j := models.Job{
+		Queue:       DefaultQueue,
+		Kind:        kind,
+		Args:        body,
+		Status:      models.JobStatusPending,
+		RunAt:       time.Now(),
+		MaxAttempts: 5,
+	}
+	for _, opt := range opts {
+		opt(&j)
+	}
+	q := db
+	if j.UniqueKey != nil {
+		q = q.Clauses(clause.OnConflict{DoNothing: true})
+	}
+	if err := q.Create(&j).Error; err != nil {
+		return models.Job{}, err
+	}
+	return j, nil
+}
+
+// Backoff waits 15 seconds after the first failure and quadruples the wait
+// after every further one, capped at six hours.
+func Backoff(attempt int) time.Duration {
+	const base, ceiling = 15 * time.Second, 6 * time.Hour
+	if attempt > 10 {
+		return ceiling
+	}
+	return min(base<<(2*(attempt-1)), ceiling)
+}
+
+// Runner executes jobs. Register handlers and queues before calling Run;
+// the exported fields may be adjusted up to the same point.
+type Runner struct {
+	db *gorm.DB
+
+	mu       sync.RWMutex
+	handlers map[string]Handler
+	queues   map[string]int
+
+	// Timeout bounds a single run and is the lease a claimed job is held
+	// for; a job still running after it is presumed lost and claimed again.
+	Timeout time.Duration
+	// Backoff returns the delay before retrying after the given attempt.
+	Backoff func(attempt int) time.Duration
+	// PollInterval is how long an idle worker waits before checking again.
+	PollInterval time.Duration
+	// Now is the clock; tests may pin it.
+	Now func() time.Time
+}
+
+func NewRunner(db *gorm.DB) *Runner {
+	return &Runner{
+		db:           db,
+		handlers:     make(map[string]Handler),
+		queues:       map[string]int{DefaultQueue: 1},
+		Timeout:      5 * time.Minute,
+		Backoff:      Backoff,
+		PollInterval: 2 * time.Second,
+		Now:          time.Now,
+	}
+}
+
+// Handle registers the handler for jobs of kind.
+func (r *Runner) Handle(kind string, h Handler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.handlers[kind] = h
+}
+
+// Queue sets how many jobs of the named queue this instance runs at once.
+func (r *Runner) Queue(name string, concurrency int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.queues[name] = concurrency
+}
+
+// Run starts the configured number of workers for every queue and blocks
+// until ctx is cancelled and they have finished their current jobs.
+func (r *Runner) Run(ctx context.Context) {
+	r.mu.RLock()
+	queues := make(map[string]int, len(r.queues))
+	for name, n := range r.queues {
+		queues[name] = n
+	}
+	r.mu.RUnlock()
+
+	var wg sync.WaitGroup
+	for name, n := range queues {
+		for range n {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				r.work(ctx, name)
+			}()
+		}
+	}
+	wg.Wait()
+}
+
+func (r *Runner) work(ctx context.Context, queue string) {
+	for {
+		ran, err := r.RunNext(ctx, queue)
+		if err != nil {
+			slog.Error("jobs: worker failed", "queue", queue, "err", err)
+		}
+		if ran && err == nil {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(r.PollInterval):
+		}
+	}
+}
+
+// RunNext claims the next due job on queue, runs it and records the
+// outcome. It reports whether a job was run.
+func (r *Runner) RunNext(ctx context.Context, queue string) (bool, error) {
+	job, err := r.claim(queue)
+	if err != nil || job == nil {
+		return false, err
+	}
+
+	r.mu.RLock()
+	handle, ok := r.handlers[job.Kind]
+	r.mu.RUnlock()
+
+	var runErr error
+	if !ok {
+		runErr = fmt.Errorf("no handler for job kind %q", job.Kind)
+	} else {
+		runCtx, cancel := context.WithTimeout(ctx, r.Timeout)
+		runErr = safeRun(runCtx, handle, *job)
+		cancel()
+	}
+	return true, r.finish(*job, runErr)
+}
+
+// safeRun turns a handler panic into an error so one bad job cannot take
+// its worker down.
+func safeRun(ctx context.Context, h Handler, job models.Job) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("panic: %v", p)
+		}
+	}()
+	return h(ctx, job)
+}
+
+// claim leases the next due job on queue: a pending job whose run_at has
+// passed, or a running one whose lease has expired.
+func (r *Runner) claim(queue string) (*models.Job, error) {
+	var job models.Job
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		now := r.Now()
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("queue = ? AND ((status = ? AND run_at <= ?) OR (status = ? AND locked_until < ?))",
+				queue, models.JobStatusPending, now, models.JobStatusRunning, now).
+			Order("run_at").
+			First(&job).Error
+		if err != nil {
+			return err
+		}
+		lease := now.Add(r.Timeout)
+		job.Status = models.JobStatusRunning
+		job.Attempts++
+		job.LockedUntil = &lease
+		return tx.Model(&job).Select("Status", "Attempts", "LockedUntil").Updates(&job).Error
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// finish records the outcome of a run: success, a retry after backoff, or
+// the dead-letter state once attempts are exhausted. It returns
+// ErrLeaseLost, discarding the outcome, when the job's lease has been
+// taken over.
+func (r *Runner) finish(job models.Job, runErr error) error {
+	now := r.Now()
+	updates := map[string]any{"locked_until": nil}
+	switch {
+	case runErr == nil:
+		updates["status"] = models.JobStatusSucceeded
+		updates["finished_at"] = now
+		updates["last_error"] = ""
+	case job.Attempts >= job.MaxAttempts:
+		updates["status"] = models.JobStatusDead
+		updates["finished_at"] = now
+		updates["last_error"] = runErr.Error()
+		slog.Error("jobs: job is dead", "job_id", job.ID, "kind", job.Kind, "attempts", job.Attempts, "err", runErr)
+	default:
+		updates["status"] = models.JobStatusPending
+		updates["run_at"] = now.Add(r.Backoff(job.Attempts))
+		updates["last_error"] = runErr.Error()
+		slog.Warn("jobs: job failed, will retry", "job_id", job.ID, "kind", job.Kind, "attempt", job.Attempts, "err", runErr)
+	}
+	// Only the worker still holding the lease may record the outcome; if it
+	// ran past it, another worker has claimed the job and owns it now.
+	res := r.db.Model(&models.Job{}).
+		Where("id = ? AND status = ? AND locked_until = ?", job.ID, models.JobStatusRunning, job.LockedUntil).
+		Updates(updates)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return fmt.Errorf("job %d: %w", job.ID, ErrLeaseLost)
+	}
+	return nil
+}
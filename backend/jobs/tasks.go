@@ -0,0 +1,207 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	"workout-tracker/backend/challenges"
+	"workout-tracker/backend/models"
+	"workout-tracker/backend/notify"
+
+	"gorm.io/gorm"
+)
+
+// Kinds of the built-in maintenance jobs.
+const (
+	KindPurgeDeleted        = "maintenance.purge_deleted"
+	KindPurgeOutbox         = "maintenance.purge_outbox"
+	KindFinalizeChallenges  = "challenges.finalize"
+	KindInactivityReminders = "reminders.inactivity"
+)
+
+// DefaultRetentionDays is how long soft-deleted rows, deleted workouts in
+// users' trash among them, are kept unless configured otherwise.
+const DefaultRetentionDays = 30
+
+// PurgeArgs are the arguments of a KindPurgeDeleted or KindPurgeOutbox
+// job.
+type PurgeArgs struct {
+	// RetentionDays is how long soft-deleted rows, or finished outbox
+	// messages, are kept before they are removed for good.
+	RetentionDays int `json:"retention_days"`
+}
+
+// RegisterMaintenance installs the built-in job handlers on r and their
+// schedules on s: a nightly purge of rows soft-deleted more than
+// retentionDays ago (DefaultRetentionDays when 0) and of outbox messages
+// finished as long ago, a frequent pass that
+// freezes the standings of ended challenges, and a weekly reminder to
+// users who stopped logging workouts, delivered through notifier.
+func RegisterMaintenance(r *Runner, s *Scheduler, notifier notify.Notifier, retentionDays int) error {
+	if retentionDays <= 0 {
+		retentionDays = DefaultRetentionDays
+	}
+	r.Handle(KindPurgeDeleted, func(ctx context.Context, job models.Job) error {
+		args := PurgeArgs{RetentionDays: retentionDays}
+		if len(job.Args) > 0 {
+			if err := json.Unmarshal(job.Args, &args); err != nil {
+				return err
+			}
+		}
+		return PurgeDeleted(r.db.WithContext(ctx), r.Now().AddDate(0, 0, -args.RetentionDays))
+	})
+	r.Handle(KindPurgeOutbox, func(ctx context.Context, job models.Job) error {
+		args := PurgeArgs{RetentionDays: retentionDays}
+		if len(job.Args) > 0 {
+			if err := json.Unmarshal(job.Args, &args); err != nil {
+				return err
+			}
+		}
+		n, err := PurgeOutbox(r.db.WithContext(ctx), r.Now().AddDate(0, 0, -args.RetentionDays))
+		if n > 0 {
+			slog.Info("jobs: purged rows", "table", "outbox_messages", "rows", n)
+		}
+		return err
+	})
+	r.Handle(KindFinalizeChallenges, func(ctx context.Context, _ models.Job) error {
+		return FinalizeChallenges(r.db.WithContext(ctx), r.Now())
+	})
+	r.Handle(KindInactivityReminders, func(ctx context.Context, _ models.Job) error {
+		return SendInactivityReminders(ctx, r.db.WithContext(ctx), notifier, r.Now())
+	})
+
+	if err := s.Every("purge-deleted", "30 3 * * *", KindPurgeDeleted, PurgeArgs{RetentionDays: retentionDays}); err != nil {
+		return err
+	}
+	if err := s.Every("purge-outbox", "45 3 * * *", KindPurgeOutbox, PurgeArgs{RetentionDays: retentionDays}); err != nil {
+		return err
+	}
+	if err := s.Every("finalize-challenges", "*/15 * * * *", KindFinalizeChallenges, nil); err != nil {
+		return err
+	}
+	return s.Every("inactivity-reminders", "0 9 * * 1", KindInactivityReminders, nil)
+}
+
+// PurgeDeleted permanently removes rows soft-deleted before cutoff, along
+// with the rows that reference them, in one transaction. Users and
+// organizations are kept: too much history hangs off them to drop it
+// implicitly. Succeeded jobs finished before cutoff go too; dead jobs stay
+// until an admin deals with them.
+func PurgeDeleted(db *gorm.DB, cutoff time.Time) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		deleted := func(model any) *gorm.DB {
+			return tx.Unscoped().Model(model).Select("id").Where("deleted_at < ?", cutoff)
+		}
+		steps := []struct {
+			model any
+			where string
+			args  []any
+		}{
+			// Children of purged plans, challenges and webhooks first, so the
+			// foreign keys still hold when their parents go.
+			{&models.WorkoutLike{}, "deleted_at < ?", []any{cutoff}},
+			{&models.WorkoutComment{}, "deleted_at < ?", []any{cutoff}},
+			{&models.PlannedSession{}, "deleted_at < ? OR plan_id IN (?)", []any{cutoff, deleted(&models.PlannedWorkout{})}},
+			{&models.PlannedWorkout{}, "deleted_at < ?", []any{cutoff}},
+			{&models.ChallengeParticipant{}, "deleted_at < ? OR challenge_id IN (?)", []any{cutoff, deleted(&models.Challenge{})}},
+			{&models.Challenge{}, "deleted_at < ?", []any{cutoff}},
+			{&models.WebhookAttempt{}, "delivery_id IN (?)", []any{
+				tx.Unscoped().Model(&models.WebhookDelivery{}).Select("id").
+					Where("deleted_at < ? OR webhook_id IN (?)", cutoff, deleted(&models.Webhook{})),
+			}},
+			{&models.WebhookDelivery{}, "deleted_at < ? OR webhook_id IN (?)", []any{cutoff, deleted(&models.Webhook{})}},
+			{&models.Webhook{}, "deleted_at < ?", []any{cutoff}},
+			{&models.Follow{}, "deleted_at < ?", []any{cutoff}},
+			{&models.Membership{}, "deleted_at < ?", []any{cutoff}},
+			{&models.WorkoutTemplate{}, "deleted_at < ?", []any{cutoff}},
+			{&models.Job{}, "status = ? AND finished_at < ?", []any{models.JobStatusSucceeded, cutoff}},
+		}
+		n, err := PurgeWorkouts(tx, deleted(&models.Workout{}))
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			slog.Info("jobs: purged rows", "table", "workouts", "rows", n)
+		}
+		for _, st := range steps {
+			res := tx.Unscoped().Where(st.where, st.args...).Delete(st.model)
+			if res.Error != nil {
+				return res.Error
+			}
+			if res.RowsAffected > 0 {
+				slog.Info("jobs: purged rows", "table", res.Statement.Table, "rows", res.RowsAffected)
+			}
+		}
+		return nil
+	})
+}
+
+// PurgeOutbox removes outbox messages processed, or given up on, before
+// cutoff, returning how many went. Pending messages are never touched.
+func PurgeOutbox(db *gorm.DB, cutoff time.Time) (int64, error) {
+	res := db.Where("processed_at < ? OR failed_at < ?", cutoff, cutoff).Delete(&models.OutboxMessage{})
+	return res.RowsAffected, res.Error
+}
+
+// PurgeWorkouts hard-deletes the workouts whose IDs ids holds (a slice or
+// a subquery) together with the rows that depend on them, revisions
+// included, returning how many workouts went. A purged workout no longer
+// fulfils its planned session, which stays in the plan's history. Its
+// attachments are only soft-deleted, leaving their blobs to the
+// attachments sweep.
+func PurgeWorkouts(tx *gorm.DB, ids any) (int64, error) {
+	err := tx.Model(&models.PlannedSession{}).Unscoped().
+		Where("workout_id IN (?)", ids).
+		Update("workout_id", nil).Error
+	if err != nil {
+		return 0, err
+	}
+	if err := tx.Where("workout_id IN (?)", ids).Delete(&models.Attachment{}).Error; err != nil {
+		return 0, err
+	}
+	for _, child := range []any{&models.WorkoutLike{}, &models.WorkoutComment{}, &models.WorkoutRevision{}} {
+		if err := tx.Unscoped().Where("workout_id IN (?)", ids).Delete(child).Error; err != nil {
+			return 0, err
+		}
+	}
+	res := tx.Unscoped().Where("id IN (?)", ids).Delete(&models.Workout{})
+	return res.RowsAffected, res.Error
+}
+
+// FinalizeChallenges freezes the standings of every challenge whose
+// late-logging window has closed by now.
+func FinalizeChallenges(db *gorm.DB, now time.Time) error {
+	var list []models.Challenge
+	if err := db.Where("finalized_at IS NULL AND ends_at < ?", now).Find(&list).Error; err != nil {
+		return err
+	}
+	for i := range list {
+		if _, err := challenges.Finalize(db, &list[i], now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SendInactivityReminders notifies every user whose latest workout was
+// performed between two and one weeks before now. Run weekly, that reaches
+// each lapsed user exactly once. Delivery failures are logged rather than
+// retried, so nobody is reminded twice.
+func SendInactivityReminders(ctx context.Context, db *gorm.DB, notifier notify.Notifier, now time.Time) error {
+	var userIDs []int64
+	err := db.Model(&models.Workout{}).
+		Group("user_id").
+		Having("MAX(performed_at) >= ? AND MAX(performed_at) < ?", now.AddDate(0, 0, -14), now.AddDate(0, 0, -7)).
+		Pluck("user_id", &userIDs).Error
+	if err != nil {
+		return err
+	}
+	for _, id := range userIDs {
+		if err := notifier.Notify(ctx, notify.Notification{Kind: notify.KindReminder, RecipientID: id}); err != nil {
+			slog.WarnContext(ctx, "notification failed", "kind", notify.KindReminder, "recipient", id, "err", err)
+		}
+	}
+	return nil
+}
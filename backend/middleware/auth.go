@@ -96,6 +96,10 @@ type UserInfo struct {
 	ZitadelID string
 	Email     string
 	Username  string
+	// OrgID and OrgName come from the urn:zitadel:iam:user:resourceowner
+	// claims and are empty unless the client requested that scope.
+	OrgID   string
+	OrgName string
 }
 
 // GetUserInfo extracts identity fields from the request context.
@@ -109,9 +113,16 @@ func GetUserInfo(ctx context.Context) *UserInfo {
 		ZitadelID: authCtx.UserID(),
 		Email:     authCtx.Email,
 		Username:  authCtx.Username,
+		OrgID:     authCtx.OrganizationID(),
+		OrgName:   orgNameClaim(authCtx),
 	}
 }
 
+func orgNameClaim(authCtx *oauth.IntrospectionContext) string {
+	name, _ := authCtx.Claims["urn:zitadel:iam:user:resourceowner:name"].(string)
+	return name
+}
+
 func extractBearer(r *http.Request) string {
 	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
 	if !ok || token == "" {
@@ -0,0 +1,35 @@
+package models
+
+// Attachment kinds, from the content type sniffed on upload.
+const (
+	AttachmentKindPhoto = "photo"
+	AttachmentKindVideo = "video"
+	AttachmentKindFile  = "file"
+)
+
+// Attachment is a file a user uploaded to one of their workouts: a
+// progress photo, a form-check video or anything else. The bytes live in
+// the blob store under StorageKey, and a photo's thumbnail under
+// ThumbnailKey.
+//
+// WorkoutID deliberately has no foreign key: purging a workout
+// soft-deletes its attachments rather than removing them, so that the
+// sweep job can delete their blobs before the rows go.
+type Attachment struct {
+	BaseModel
+	WorkoutID   int64 `gorm:"not null;index"`
+	UserID      int64 `gorm:"not null;index"`
+	User        User
+	Kind        string `gorm:"not null"`
+	Filename    string `gorm:"not null"`
+	ContentType string `gorm:"not null"`
+	// Size and ThumbnailSize, in bytes, both count against the user's
+	// quota.
+	Size          int64  `gorm:"not null"`
+	StorageKey    string `gorm:"not null"`
+	ThumbnailKey  string
+	ThumbnailSize int64
+	// Width and Height are a photo's dimensions in pixels.
+	Width  int
+	Height int
+}
@@ -0,0 +1,50 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Audit log actions.
+const (
+	AuditActionCreate = "create"
+	AuditActionUpdate = "update"
+	AuditActionDelete = "delete"
+)
+
+// AuditLog records one change to a user or workout: who made it, what
+// changed and where the request came from. Rows are only ever inserted and
+// deliberately carry no foreign keys, so the trail outlives what it refers
+// to.
+type AuditLog struct {
+	ID        int64     `gorm:"primaryKey;autoIncrement"`
+	PublicID  uuid.UUID `gorm:"type:uuid;not null;uniqueIndex"`
+	CreatedAt time.Time `gorm:"autoCreateTime;not null;index"`
+	// ActorUserID is the local user behind the request; nil for background
+	// jobs and callers not yet resolved to a user.
+	ActorUserID *int64 `gorm:"index"`
+	// Subject and TokenType identify the Zitadel token used, if any.
+	Subject   string `gorm:"not null;default:'';index"`
+	TokenType string `gorm:"not null;default:''"`
+	ClientID  string `gorm:"not null;default:''"`
+	Action    string `gorm:"not null"`
+	// ResourceID and ResourcePublicID are nil for bulk statements that
+	// matched rows by a filter. The public ID is copied so entries stay
+	// searchable by it after the resource is purged.
+	ResourceType     string     `gorm:"not null;index:idx_audit_logs_resource;index:idx_audit_logs_resource_public_id"`
+	ResourceID       *int64     `gorm:"index:idx_audit_logs_resource"`
+	ResourcePublicID *uuid.UUID `gorm:"type:uuid;index:idx_audit_logs_resource_public_id"`
+	// Changes maps each changed field to its old and new value; bulk
+	// statements record the SQL and rows affected instead.
+	Changes   json.RawMessage `gorm:"type:jsonb;not null"`
+	IP        string          `gorm:"not null;default:''"`
+	UserAgent string          `gorm:"not null;default:''"`
+	RequestID string          `gorm:"not null;default:'';index"`
+}
+
+func (l *AuditLog) BeforeCreate(*gorm.DB) error {
+	return assignPublicID(&l.PublicID)
+}
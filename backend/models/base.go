@@ -3,6 +3,7 @@ package models
 import (
 	"time"
 
+	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
@@ -11,8 +12,40 @@ import (
 // which Atlas picks up and writes as proper SQL-standard IDENTITY syntax in
 // migrations (rather than the legacy SERIAL shorthand).
 type BaseModel struct {
-	ID        int64          `gorm:"primaryKey;autoIncrement"`
+	ID int64 `gorm:"primaryKey;autoIncrement"`
+	// PublicID identifies the record in the API, which never exposes ID:
+	// sequential IDs leak how many records there are, and a client creating
+	// records offline can pick a UUID of its own.
+	PublicID  uuid.UUID      `gorm:"type:uuid;not null;uniqueIndex"`
 	CreatedAt time.Time      `gorm:"autoCreateTime"`
 	UpdatedAt time.Time      `gorm:"autoUpdateTime"`
 	DeletedAt gorm.DeletedAt `gorm:"index"` // gorm.DeletedAt enables soft-delete
+	// Version counts the writes to a record, starting at 1. Writes that
+	// must not clobber a concurrent change bump it conditionally and the
+	// API exposes it as the ETag.
+	Version int64 `gorm:"not null;default:1"`
+}
+
+// BeforeCreate starts every record at version 1 and gives it a public ID
+// unless the client chose one.
+func (m *BaseModel) BeforeCreate(*gorm.DB) error {
+	if m.Version == 0 {
+		m.Version = 1
+	}
+	return assignPublicID(&m.PublicID)
+}
+
+// assignPublicID sets id to a new UUIDv7 unless it is already set. Version
+// 7 UUIDs start with their creation time, so new rows are appended to the
+// public_id index rather than scattered across it.
+func assignPublicID(id *uuid.UUID) error {
+	if *id != uuid.Nil {
+		return nil
+	}
+	v, err := uuid.NewV7()
+	if err != nil {
+		return err
+	}
+	*id = v
+	return nil
 }
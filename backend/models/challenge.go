@@ -0,0 +1,49 @@
+package models
+
+import "time"
+
+// Challenge metrics: most minutes in total, most sessions, and the longest
+// distance covered in a single workout.
+const (
+	ChallengeMetricTotalMinutes = "total_minutes"
+	ChallengeMetricSessions     = "sessions"
+	ChallengeMetricDistance     = "distance"
+)
+
+// Challenge is a time-boxed competition over [StartsAt, EndsAt). The window
+// is stored in UTC but derived from calendar dates in Timezone, so a challenge
+// running "1–31 March" in Europe/Berlin starts at local midnight there.
+type Challenge struct {
+	BaseModel
+	OrganizationID *int64 `gorm:"index"` // nil for site-wide challenges created by an admin
+	Organization   *Organization
+	CreatedByID    int64 `gorm:"not null"`
+	CreatedBy      User
+	Name           string `gorm:"not null"`
+	Description    string
+	Metric         string    `gorm:"not null"`
+	Timezone       string    `gorm:"not null;default:UTC"`
+	StartsAt       time.Time `gorm:"not null"`
+	EndsAt         time.Time `gorm:"not null"`
+	// LateLogHours is how long after EndsAt workouts performed inside the
+	// window may still be logged and counted before results are frozen.
+	LateLogHours int `gorm:"not null;default:48"`
+	// FinalizedAt is set once standings have been frozen into the
+	// participants' FinalRank/FinalValue.
+	FinalizedAt *time.Time
+}
+
+// FreezesAt is the moment the challenge stops accepting late-logged workouts.
+func (c Challenge) FreezesAt() time.Time {
+	return c.EndsAt.Add(time.Duration(c.LateLogHours) * time.Hour)
+}
+
+type ChallengeParticipant struct {
+	BaseModel
+	ChallengeID int64 `gorm:"not null;uniqueIndex:idx_challenge_participants_pair"`
+	Challenge   Challenge
+	UserID      int64 `gorm:"not null;uniqueIndex:idx_challenge_participants_pair;index"`
+	User        User
+	FinalRank   *int
+	FinalValue  *int64
+}
@@ -0,0 +1,28 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Event is an append-only log entry describing a change to a resource. Its
+// ID doubles as the SSE event ID clients resume from via Last-Event-ID.
+type Event struct {
+	// idx_events_user_resource serves delta sync, which reads a user's
+	// changes to one resource type after a cursor.
+	ID           int64     `gorm:"primaryKey;autoIncrement;index:idx_events_user_resource,priority:3"`
+	CreatedAt    time.Time `gorm:"autoCreateTime;not null"`
+	Type         string    `gorm:"not null"`
+	ResourceType string    `gorm:"not null;index:idx_events_user_resource,priority:2"`
+	ResourceID   int64     `gorm:"not null"`
+	// ResourcePublicID is the resource's ID as the API shows it, kept so
+	// consumers can name resources that have since been purged.
+	ResourcePublicID uuid.UUID `gorm:"type:uuid"`
+	// UserID and Visibility capture who owned the resource and who could see
+	// it when the event happened, so replays can be filtered without joins.
+	UserID     int64 `gorm:"not null;index:idx_events_user_resource,priority:1"`
+	Visibility string
+	Payload    json.RawMessage `gorm:"type:jsonb"`
+}
@@ -0,0 +1,24 @@
+package models
+
+// Custom field types.
+const (
+	FieldTypeNumber  = "number"
+	FieldTypeText    = "text"
+	FieldTypeEnum    = "enum"
+	FieldTypeBoolean = "boolean"
+)
+
+// CustomField is a field a user defines to record on their workouts, such
+// as hours slept or mood. Values live on Workout.CustomFields under Key.
+// Deleting a field keeps the values already recorded, and frees its key.
+type CustomField struct {
+	BaseModel
+	UserID int64 `gorm:"not null;uniqueIndex:idx_custom_fields_user_key,where:deleted_at IS NULL"`
+	User   User
+	Key    string `gorm:"not null;uniqueIndex:idx_custom_fields_user_key,where:deleted_at IS NULL"`
+	Name   string `gorm:"not null"`
+	Type   string `gorm:"not null"`
+	// Options lists the values an enum field allows.
+	Options []string `gorm:"serializer:json;type:jsonb"`
+	Unit    string
+}
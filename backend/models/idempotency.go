@@ -0,0 +1,29 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// IdempotencyKey is a POST request made with an Idempotency-Key header
+// and the response it got, replayed when the client retries it. Status is
+// 0 while the first attempt is still being handled.
+type IdempotencyKey struct {
+	ID        int64     `gorm:"primaryKey;autoIncrement"`
+	CreatedAt time.Time `gorm:"autoCreateTime;not null"`
+	ExpiresAt time.Time `gorm:"not null;index"`
+	// LockedUntil is when the first attempt's lease on the key runs out.
+	// The instance handling it keeps pushing it back; once it passes, the
+	// attempt is taken to have died and a retry may claim the key.
+	LockedUntil time.Time `gorm:"not null"`
+	// Scope is the caller the key belongs to, their token subject; keys
+	// from different callers never collide.
+	Scope string `gorm:"not null;uniqueIndex:idx_idempotency_keys_scope_key"`
+	Key   string `gorm:"not null;uniqueIndex:idx_idempotency_keys_scope_key"`
+	// Fingerprint is a hash of the method, path and body the key was first
+	// used with.
+	Fingerprint string          `gorm:"not null"`
+	Status      int             `gorm:"not null;default:0"`
+	Header      json.RawMessage `gorm:"type:jsonb"`
+	Body        []byte
+}
@@ -0,0 +1,45 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Job statuses. A job that exhausts its attempts becomes dead and stays in
+// the table as the dead-letter queue until an admin retries or removes it.
+const (
+	JobStatusPending   = "pending"
+	JobStatusRunning   = "running"
+	JobStatusSucceeded = "succeeded"
+	JobStatusDead      = "dead"
+)
+
+// Job is a unit of background work. Workers claim due jobs with
+// SELECT ... FOR UPDATE SKIP LOCKED and hold them until LockedUntil; a job
+// whose worker died is claimed again once that lease expires.
+type Job struct {
+	ID          int64           `gorm:"primaryKey;autoIncrement"`
+	PublicID    uuid.UUID       `gorm:"type:uuid;not null;uniqueIndex"`
+	CreatedAt   time.Time       `gorm:"autoCreateTime;not null"`
+	UpdatedAt   time.Time       `gorm:"autoUpdateTime;not null"`
+	Queue       string          `gorm:"not null;default:default;index:idx_jobs_due"`
+	Kind        string          `gorm:"not null;index"`
+	Args        json.RawMessage `gorm:"type:jsonb"`
+	Status      string          `gorm:"not null;default:pending;index:idx_jobs_due"`
+	RunAt       time.Time       `gorm:"not null;default:now();index:idx_jobs_due"`
+	Attempts    int             `gorm:"not null;default:0"`
+	MaxAttempts int             `gorm:"not null;default:5"`
+	LockedUntil *time.Time
+	LastError   string
+	FinishedAt  *time.Time
+	// UniqueKey, when set, makes enqueueing the same work twice a no-op;
+	// the scheduler keys each periodic run by task and slot.
+	UniqueKey *string `gorm:"uniqueIndex"`
+}
+
+func (j *Job) BeforeCreate(*gorm.DB) error {
+	return assignPublicID(&j.PublicID)
+}
@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// NotificationPreference records whether a user wants email for one
+// category of notification. Categories without a row are enabled; the
+// category "all" switches off every category that can be unsubscribed from.
+type NotificationPreference struct {
+	ID        int64     `gorm:"primaryKey;autoIncrement"`
+	CreatedAt time.Time `gorm:"autoCreateTime;not null"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime;not null"`
+	UserID    int64     `gorm:"not null;uniqueIndex:idx_notification_preferences_user_category"`
+	User      User
+	Category  string `gorm:"not null;uniqueIndex:idx_notification_preferences_user_category"`
+	Email     bool   `gorm:"not null"`
+}
@@ -0,0 +1,40 @@
+package models
+
+// Membership roles within an Organization. Owners manage the organization and
+// its members, coaches manage shared templates, members can read both.
+const (
+	MembershipRoleOwner  = "owner"
+	MembershipRoleCoach  = "coach"
+	MembershipRoleMember = "member"
+)
+
+type Organization struct {
+	BaseModel
+	ZitadelOrgID *string `gorm:"uniqueIndex"` // nil for organizations created through the API
+	Name         string  `gorm:"not null"`
+}
+
+type Membership struct {
+	BaseModel
+	OrganizationID int64 `gorm:"not null;uniqueIndex:idx_memberships_org_user"`
+	Organization   Organization
+	UserID         int64 `gorm:"not null;uniqueIndex:idx_memberships_org_user;index"`
+	User           User
+	Role           string `gorm:"not null;default:member"`
+	// ShareStats opts the member into the organization's aggregate and
+	// leaderboard endpoints. Off by default.
+	ShareStats bool `gorm:"not null;default:false"`
+}
+
+// WorkoutTemplate is a reusable workout blueprint. Templates with an
+// OrganizationID belong to that organization's shared library.
+type WorkoutTemplate struct {
+	BaseModel
+	OrganizationID  *int64 `gorm:"index"`
+	Organization    *Organization
+	AuthorID        int64 `gorm:"not null"`
+	Author          User
+	Name            string `gorm:"not null"`
+	Description     string
+	DurationMinutes int
+}
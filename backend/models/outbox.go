@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// OutboxMessage hands one logged event to one named consumer. Rows are
+// written in the transaction that logged the event and marked processed in
+// the transaction that handled it, so each consumer sees each event once.
+type OutboxMessage struct {
+	ID          int64     `gorm:"primaryKey;autoIncrement"`
+	CreatedAt   time.Time `gorm:"autoCreateTime;not null"`
+	Consumer    string    `gorm:"not null;uniqueIndex:idx_outbox_messages_consumer_event;index:idx_outbox_messages_pending,where:processed_at IS NULL AND failed_at IS NULL"`
+	EventID     int64     `gorm:"not null;uniqueIndex:idx_outbox_messages_consumer_event"`
+	Event       Event
+	Attempts    int       `gorm:"not null;default:0"`
+	AvailableAt time.Time `gorm:"not null;index:idx_outbox_messages_pending"`
+	ProcessedAt *time.Time
+	// FailedAt is set once the consumer has given up on the message; it is
+	// kept for inspection, until the outbox purge job removes it, and never
+	// retried automatically.
+	FailedAt  *time.Time
+	LastError string
+}
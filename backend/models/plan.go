@@ -0,0 +1,68 @@
+package models
+
+import "time"
+
+// Planned session statuses.
+const (
+	SessionStatusScheduled = "scheduled"
+	SessionStatusDone      = "done"
+	SessionStatusMissed    = "missed"
+	SessionStatusSkipped   = "skipped"
+)
+
+// PlannedWorkout is a workout a user intends to do, once at StartsAt or
+// repeatedly following RRule (RFC 5545, with StartsAt as DTSTART). Its
+// occurrences are materialised ahead of time as PlannedSessions.
+type PlannedWorkout struct {
+	BaseModel
+	UserID          int64 `gorm:"not null;index"`
+	User            User
+	Name            string `gorm:"not null"`
+	Description     string
+	DurationMinutes int
+	StartsAt        time.Time `gorm:"not null"`
+	// Timezone is the IANA zone recurrences are expanded in, so a weekly
+	// 07:00 session stays at 07:00 across daylight saving changes.
+	Timezone string `gorm:"not null;default:UTC"`
+	RRule    string `gorm:"column:rrule"`
+	// ReminderChannels lists the notify channels reminders and missed-session
+	// reports go out on; none means the plan is silent.
+	ReminderChannels    []string `gorm:"serializer:json;type:jsonb"`
+	RemindMinutesBefore int      `gorm:"not null;default:60"`
+	// AutoReschedule moves a missed session to the same time the next day
+	// instead of leaving it missed.
+	AutoReschedule bool `gorm:"not null;default:false"`
+}
+
+// PlannedSession is one occurrence of a PlannedWorkout. OccursAt is the slot
+// the plan generated and never changes, so regenerating occurrences is
+// idempotent; ScheduledAt starts out equal to it and moves when the session
+// is rescheduled.
+type PlannedSession struct {
+	BaseModel
+	PlanID      int64 `gorm:"not null;uniqueIndex:idx_planned_sessions_occurrence"`
+	Plan        PlannedWorkout
+	UserID      int64     `gorm:"not null;index:idx_planned_sessions_user_scheduled"`
+	OccursAt    time.Time `gorm:"not null;uniqueIndex:idx_planned_sessions_occurrence"`
+	ScheduledAt time.Time `gorm:"not null;index:idx_planned_sessions_user_scheduled"`
+	Status      string    `gorm:"not null;default:scheduled"`
+	// WorkoutID links the workout that fulfilled the session.
+	WorkoutID  *int64
+	Workout    *Workout
+	RemindedAt *time.Time
+	// MissedAt is set when the session was found missed, and kept if it is
+	// then rescheduled so missed-session reports still count it.
+	MissedAt    *time.Time
+	Reschedules int `gorm:"not null;default:0"`
+}
+
+// PushSubscription is a browser's Web Push endpoint with the keys its
+// payloads are encrypted for.
+type PushSubscription struct {
+	BaseModel
+	UserID   int64 `gorm:"not null;index"`
+	User     User
+	Endpoint string `gorm:"not null;uniqueIndex"`
+	P256dh   string `gorm:"not null"`
+	Auth     string `gorm:"not null"`
+}
@@ -0,0 +1,35 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Report periods.
+const (
+	ReportPeriodWeekly  = "weekly"
+	ReportPeriodMonthly = "monthly"
+)
+
+// Report is a generated training summary for one user and period, kept so
+// past weeks and months can be looked at again exactly as they were
+// reported. Data holds the schemas.ReportData document.
+type Report struct {
+	ID          int64     `gorm:"primaryKey;autoIncrement"`
+	PublicID    uuid.UUID `gorm:"type:uuid;not null;uniqueIndex"`
+	CreatedAt   time.Time `gorm:"autoCreateTime;not null"`
+	UpdatedAt   time.Time `gorm:"autoUpdateTime;not null"`
+	UserID      int64     `gorm:"not null;uniqueIndex:idx_reports_user_period"`
+	User        User
+	Period      string          `gorm:"not null;uniqueIndex:idx_reports_user_period"`
+	PeriodStart time.Time       `gorm:"not null;uniqueIndex:idx_reports_user_period"`
+	PeriodEnd   time.Time       `gorm:"not null"`
+	Data        json.RawMessage `gorm:"type:jsonb;not null"`
+}
+
+func (r *Report) BeforeCreate(*gorm.DB) error {
+	return assignPublicID(&r.PublicID)
+}
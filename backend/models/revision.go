@@ -0,0 +1,25 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// WorkoutRevision is an immutable snapshot of a workout as one change left
+// it. Revision 1 is the workout as created; every update, including a
+// restore, adds the next one.
+type WorkoutRevision struct {
+	ID        int64     `gorm:"primaryKey;autoIncrement"`
+	CreatedAt time.Time `gorm:"autoCreateTime;not null"`
+	WorkoutID int64     `gorm:"not null;uniqueIndex:idx_workout_revisions_workout_revision"`
+	Workout   Workout
+	Revision  int `gorm:"not null;uniqueIndex:idx_workout_revisions_workout_revision"`
+	// AuthorID is the user who made the change; nil when it wasn't made on
+	// behalf of a known user.
+	AuthorID *int64
+	Author   *User
+	// RestoredFrom is the revision a restore copied.
+	RestoredFrom *int
+	// Snapshot is the workout as the API returned it after the change.
+	Snapshot json.RawMessage `gorm:"type:jsonb;not null"`
+}
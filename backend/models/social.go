@@ -0,0 +1,28 @@
+package models
+
+// Follow records that FollowerID follows FolloweeID. Rows are hard-deleted on
+// unfollow so the pair can be recreated.
+type Follow struct {
+	BaseModel
+	FollowerID int64 `gorm:"not null;uniqueIndex:idx_follows_pair"`
+	Follower   User
+	FolloweeID int64 `gorm:"not null;uniqueIndex:idx_follows_pair;index"`
+	Followee   User
+}
+
+type WorkoutLike struct {
+	BaseModel
+	WorkoutID int64 `gorm:"not null;uniqueIndex:idx_workout_likes_pair"`
+	Workout   Workout
+	UserID    int64 `gorm:"not null;uniqueIndex:idx_workout_likes_pair"`
+	User      User
+}
+
+type WorkoutComment struct {
+	BaseModel
+	WorkoutID int64 `gorm:"not null;index"`
+	Workout   Workout
+	UserID    int64 `gorm:"not null"`
+	User      User
+	Body      string `gorm:"not null"`
+}
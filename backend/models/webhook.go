@@ -0,0 +1,65 @@
+package models
+
+import (
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Webhook delivery statuses.
+const (
+	DeliveryStatusPending   = "pending"
+	DeliveryStatusSucceeded = "succeeded"
+	DeliveryStatusFailed    = "failed"
+)
+
+// Webhook is an integrator's subscription to change events. A webhook with a
+// UserID receives events about that user's own resources; a site-wide one
+// (no UserID, registered by an admin) receives every event.
+type Webhook struct {
+	BaseModel
+	UserID *int64 `gorm:"index"`
+	User   *User
+	URL    string `gorm:"not null"`
+	// EventTypes lists the event types delivered; empty means all of them.
+	EventTypes []string `gorm:"serializer:json;type:jsonb"`
+	Secret     string   `gorm:"not null"`
+	Active     bool     `gorm:"not null;default:true"`
+}
+
+// Subscribes reports whether events of type typ are delivered to w.
+func (w Webhook) Subscribes(typ string) bool {
+	return len(w.EventTypes) == 0 || slices.Contains(w.EventTypes, typ)
+}
+
+// WebhookDelivery is one event queued for one webhook. Each try is kept as a
+// WebhookAttempt; redelivering puts the same row back to pending.
+type WebhookDelivery struct {
+	BaseModel
+	WebhookID     int64 `gorm:"not null;uniqueIndex:idx_webhook_deliveries_event"`
+	Webhook       Webhook
+	EventID       int64 `gorm:"not null;uniqueIndex:idx_webhook_deliveries_event"`
+	Event         Event
+	Status        string    `gorm:"not null;default:pending;index:idx_webhook_deliveries_due"`
+	Attempts      int       `gorm:"not null;default:0"`
+	NextAttemptAt time.Time `gorm:"not null;index:idx_webhook_deliveries_due"`
+	DeliveredAt   *time.Time
+}
+
+// WebhookAttempt records the outcome of one HTTP request for a delivery.
+type WebhookAttempt struct {
+	ID         int64     `gorm:"primaryKey;autoIncrement"`
+	PublicID   uuid.UUID `gorm:"type:uuid;not null;uniqueIndex"`
+	CreatedAt  time.Time `gorm:"autoCreateTime;not null"`
+	DeliveryID int64     `gorm:"not null;index"`
+	Delivery   WebhookDelivery
+	StatusCode int
+	Error      string
+	DurationMS int64 `gorm:"column:duration_ms;not null"`
+}
+
+func (a *WebhookAttempt) BeforeCreate(*gorm.DB) error {
+	return assignPublicID(&a.PublicID)
+}
@@ -1,10 +1,46 @@
 package models
 
+import (
+	"time"
+
+	"workout-tracker/backend/training"
+)
+
+// Workout visibility levels. Private workouts are only visible to their
+// owner; followers-only workouts also appear in followers' feeds.
+const (
+	VisibilityPrivate   = "private"
+	VisibilityFollowers = "followers"
+	VisibilityPublic    = "public"
+)
+
 type Workout struct {
 	BaseModel
-	UserID          int64 `gorm:"not null;index"`
+	// idx_workouts_feed serves the activity feed's keyset pagination over
+	// followed users; BaseModel's timestamps can't carry a per-model tag.
+	UserID          int64 `gorm:"not null;index:idx_workouts_feed,expression:user_id\\,created_at DESC\\,id DESC"`
 	User            User
 	Name            string `gorm:"not null"`
 	Description     string
 	DurationMinutes int
+	DistanceMeters  int
+	// PerformedAt is when the workout took place, which may be well before
+	// CreatedAt for workouts logged after the fact.
+	PerformedAt time.Time `gorm:"not null;default:now();index"`
+	Visibility  string    `gorm:"not null;default:private"`
+	// Tags are the owner's labels for the workout, such as "deload",
+	// trimmed and lower-cased.
+	Tags []string `gorm:"serializer:json;type:jsonb;not null;default:'[]';index:idx_workouts_tags,type:gin"`
+	// CustomFields holds values of the owner's CustomFields by key.
+	CustomFields map[string]any `gorm:"serializer:json;type:jsonb;not null;default:'{}';index:idx_workouts_custom_fields,type:gin"`
+	// Exercises are what was done, in order, down to each set.
+	Exercises []training.Exercise `gorm:"serializer:json;type:jsonb;not null;default:'[]'"`
+	// Groups arrange Exercises into supersets, circuits and intervals.
+	Groups []training.Group `gorm:"serializer:json;type:jsonb;not null;default:'[]'"`
+	// SearchVector indexes Name and Description for the search package.
+	// Postgres generates it, so GORM neither writes nor reads it.
+	SearchVector string `gorm:"->:false;type:tsvector GENERATED ALWAYS AS (setweight(to_tsvector('english'::regconfig, COALESCE(name, '')) || to_tsvector('simple'::regconfig, COALESCE(name, '')), 'A') || setweight(to_tsvector('english'::regconfig, COALESCE(description, '')) || to_tsvector('simple'::regconfig, COALESCE(description, '')), 'B')) STORED;index:idx_workouts_search,type:gin"`
+	// ExerciseSearchVector indexes the names and notes of Exercises, so
+	// the search package finds the workouts to look for exercises in.
+	ExerciseSearchVector string `gorm:"->:false;type:tsvector GENERATED ALWAYS AS (setweight(to_tsvector('english'::regconfig, jsonb_path_query_array(exercises, '$[*].name')) || to_tsvector('simple'::regconfig, jsonb_path_query_array(exercises, '$[*].name')), 'A') || setweight(to_tsvector('english'::regconfig, jsonb_path_query_array(exercises, '$[*].notes')) || to_tsvector('simple'::regconfig, jsonb_path_query_array(exercises, '$[*].notes')) || to_tsvector('english'::regconfig, jsonb_path_query_array(exercises, '$[*].sets[*].notes')) || to_tsvector('simple'::regconfig, jsonb_path_query_array(exercises, '$[*].sets[*].notes')), 'B')) STORED;index:idx_workouts_exercise_search,type:gin"`
 }
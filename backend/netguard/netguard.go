@@ -0,0 +1,112 @@
+// Package netguard keeps requests to user-supplied URLs, such as webhook
+// and push endpoints, away from the server's own network.
+//
+// Checking a URL's host when it is registered is not enough: a public
+// hostname can resolve to an internal address, or be re-pointed at one
+// after the check. Client therefore refuses internal addresses when it
+// dials, after DNS resolution and on every redirect; CheckURL only gives
+// callers an early, friendlier error for URLs that can never work.
+package netguard
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ErrForbiddenAddress is returned when a URL or connection targets an
+// address outside the public internet.
+var ErrForbiddenAddress = errors.New("destination address is not allowed")
+
+// blocked lists the special-purpose ranges netip has no predicate for.
+var blocked = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),      // "this network"
+	netip.MustParsePrefix("100.64.0.0/10"),  // carrier-grade NAT
+	netip.MustParsePrefix("192.0.0.0/24"),   // IETF protocol assignments
+	netip.MustParsePrefix("198.18.0.0/15"),  // benchmarking
+	netip.MustParsePrefix("240.0.0.0/4"),    // reserved, and broadcast
+	netip.MustParsePrefix("64:ff9b::/96"),   // NAT64 of any IPv4 address
+	netip.MustParsePrefix("64:ff9b:1::/48"), // local-use NAT64
+}
+
+// Allowed reports whether addr is a public unicast address. Loopback,
+// private, link-local (which includes the 169.254.169.254 cloud metadata
+// service), multicast and the other special-purpose ranges are not.
+func Allowed(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsValid() || addr.IsUnspecified() || addr.IsLoopback() || addr.IsPrivate() ||
+		addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() || addr.IsInterfaceLocalMulticast() ||
+		addr.IsMulticast() {
+		return false
+	}
+	for _, p := range blocked {
+		if p.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// Control is a net.Dialer Control function that refuses to connect to
+// addresses Allowed rejects. It runs on the resolved address, so DNS
+// rebinding can't get past it.
+func Control(network, address string, _ syscall.RawConn) error {
+	ap, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("netguard: %s: %w", address, err)
+	}
+	if !Allowed(ap.Addr()) {
+		return fmt.Errorf("netguard: %s: %w", address, ErrForbiddenAddress)
+	}
+	return nil
+}
+
+// Client returns an HTTP client with the given timeout that only connects
+// to public addresses. It ignores proxy settings, since a proxy would make
+// the connection on its behalf.
+func Client(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{Timeout: 5 * time.Second, Control: Control}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{Timeout: timeout, Transport: transport}
+}
+
+// CheckURL checks that raw is an absolute URL with one of the given
+// schemes and a host that is not an internal address or name. Hostnames
+// are not resolved; Client enforces the rest when it connects.
+func CheckURL(raw string, schemes ...string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return err
+	}
+	if !slices.Contains(schemes, u.Scheme) {
+		return fmt.Errorf("scheme must be %s", strings.Join(schemes, " or "))
+	}
+	host := u.Hostname()
+	if host == "" {
+		return errors.New("host is required")
+	}
+	if u.User != nil {
+		return errors.New("credentials are not allowed")
+	}
+	if addr, err := netip.ParseAddr(host); err == nil {
+		if !Allowed(addr) {
+			return ErrForbiddenAddress
+		}
+		return nil
+	}
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") || strings.HasSuffix(host, ".internal") ||
+		!strings.Contains(host, ".") {
+		return ErrForbiddenAddress
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+// Channel names users pick reminders to go out on.
+const (
+	ChannelEmail   = "email"
+	ChannelWebhook = "webhook"
+	ChannelPush    = "push"
+)
+
+// Recipient is the user a message is addressed to.
+type Recipient struct {
+	UserID   int64
+	PublicID uuid.UUID
+	Email    string
+	Name     string
+}
+
+// Message is a notification rendered for delivery. Subject and Text are for
+// people; Kind, the resource fields and Data are for machines (webhook
+// event type and payload, push notification data).
+type Message struct {
+	Kind             string
+	Subject          string
+	Text             string
+	ResourceType     string
+	ResourceID       int64
+	ResourcePublicID uuid.UUID
+	Data             any
+}
+
+// Channel delivers messages over one medium.
+type Channel interface {
+	Send(ctx context.Context, to Recipient, m Message) error
+}
+
+// Channels maps channel names to the channels configured on this instance.
+type Channels map[string]Channel
+
+// Send delivers m to to on every named channel that is configured, skipping
+// the rest. It tries them all and joins their errors.
+func (cs Channels) Send(ctx context.Context, names []string, to Recipient, m Message) error {
+	var errs []error
+	for _, name := range names {
+		ch, ok := cs[name]
+		if !ok {
+			continue
+		}
+		if err := ch.Send(ctx, to, m); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,103 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"workout-tracker/backend/events"
+	"workout-tracker/backend/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// DefaultRoutes sends social notifications as push notifications only,
+// and inactivity reminders by email as well.
+var DefaultRoutes = map[string][]string{
+	KindFollow:   {ChannelPush},
+	KindLike:     {ChannelPush},
+	KindComment:  {ChannelPush},
+	KindReminder: {ChannelEmail, ChannelPush},
+}
+
+// NotificationData is the Data of a Message rendered from a Notification.
+type NotificationData struct {
+	ActorID   *uuid.UUID `json:"actor_id,omitempty"`
+	WorkoutID *uuid.UUID `json:"workout_id,omitempty"`
+}
+
+// ChannelNotifier is the Notifier that renders notifications as Messages
+// and delivers them on the configured Channels.
+type ChannelNotifier struct {
+	db       *gorm.DB
+	channels Channels
+	// Routes names the channels each notification kind goes out on; kinds
+	// without a route are dropped.
+	Routes map[string][]string
+}
+
+func NewChannelNotifier(db *gorm.DB, channels Channels) *ChannelNotifier {
+	return &ChannelNotifier{db: db, channels: channels, Routes: DefaultRoutes}
+}
+
+// Notify renders n for its recipient and sends it. Notifications whose
+// recipient, actor or workout no longer exists are dropped.
+func (c *ChannelNotifier) Notify(ctx context.Context, n Notification) error {
+	names := c.Routes[n.Kind]
+	if len(names) == 0 {
+		return nil
+	}
+	db := c.db.WithContext(ctx)
+	var to models.User
+	if err := db.First(&to, n.RecipientID).Error; err != nil {
+		return ignoreMissing(err)
+	}
+	var (
+		actor   models.User
+		workout models.Workout
+		data    NotificationData
+	)
+	if n.ActorID != 0 {
+		if err := db.First(&actor, n.ActorID).Error; err != nil {
+			return ignoreMissing(err)
+		}
+		data.ActorID = &actor.PublicID
+	}
+	if n.WorkoutID != 0 {
+		if err := db.First(&workout, n.WorkoutID).Error; err != nil {
+			return ignoreMissing(err)
+		}
+		data.WorkoutID = &workout.PublicID
+	}
+
+	m := Message{Kind: n.Kind, Data: data}
+	switch n.Kind {
+	case KindFollow:
+		m.Subject = actor.Name + " started following you"
+		m.ResourceType, m.ResourceID, m.ResourcePublicID = events.ResourceUser, actor.ID, actor.PublicID
+	case KindLike:
+		m.Subject = fmt.Sprintf("%s liked your workout %q", actor.Name, workout.Name)
+	case KindComment:
+		m.Subject = fmt.Sprintf("%s commented on your workout %q", actor.Name, workout.Name)
+	case KindReminder:
+		m.Subject = "Time for a workout?"
+		m.Text = "You haven't logged a workout for over a week. Even a short session keeps the habit going."
+	default:
+		return fmt.Errorf("unknown notification kind %q", n.Kind)
+	}
+	if n.WorkoutID != 0 {
+		m.ResourceType, m.ResourceID, m.ResourcePublicID = events.ResourceWorkout, workout.ID, workout.PublicID
+	}
+	if m.Text == "" {
+		m.Text = m.Subject + "."
+	}
+	return c.channels.Send(ctx, names, Recipient{UserID: to.ID, PublicID: to.PublicID, Email: to.Email, Name: to.Name}, m)
+}
+
+func ignoreMissing(err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,38 @@
+// Package notify defines the hook through which user-facing notifications
+// (new followers, likes, comments, ...) leave the request path. Handlers only
+// depend on the Notifier interface; delivery channels plug in behind it.
+// The Channel implementations here (SMTP, Webhook, WebPush) deliver rendered
+// Messages and are what users choose between for planned-session reminders.
+package notify
+
+import "context"
+
+// Notification kinds.
+const (
+	KindFollow  = "follow"
+	KindLike    = "like"
+	KindComment = "comment"
+	// KindReminder nudges a user who has stopped logging workouts; it has
+	// no actor.
+	KindReminder = "reminder"
+)
+
+// Notification describes something that happened to RecipientID because of
+// ActorID. WorkoutID and CommentID are zero when not applicable.
+type Notification struct {
+	Kind        string
+	RecipientID int64
+	ActorID     int64
+	WorkoutID   int64
+	CommentID   int64
+}
+
+type Notifier interface {
+	Notify(ctx context.Context, n Notification) error
+}
+
+// Nop discards every notification. It is the default when no Notifier is
+// configured.
+type Nop struct{}
+
+func (Nop) Notify(context.Context, Notification) error { return nil }
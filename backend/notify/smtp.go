@@ -0,0 +1,167 @@
+package notify
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Email is a message ready to go out over SMTP. HTML is optional; when it
+// is set the message is sent as multipart/alternative with Text as the
+// plain-text part.
+type Email struct {
+	To      mail.Address
+	Subject string
+	Text    string
+	HTML    string
+	// Headers are added verbatim, e.g. List-Unsubscribe.
+	Headers map[string]string
+}
+
+// SMTP sends email through a relay.
+type SMTP struct {
+	// Addr is the server's host:port.
+	Addr string
+	From mail.Address
+	// Auth is nil for servers that accept unauthenticated mail.
+	Auth smtp.Auth
+}
+
+// Send delivers m as a plain-text email, making SMTP a Channel.
+func (s *SMTP) Send(ctx context.Context, to Recipient, m Message) error {
+	if to.Email == "" {
+		return errors.New("recipient has no email address")
+	}
+	return s.SendEmail(ctx, Email{
+		To:      mail.Address{Name: to.Name, Address: to.Email},
+		Subject: m.Subject,
+		Text:    m.Text,
+	})
+}
+
+// SendEmail delivers e. The connection upgrades to TLS when the server
+// offers STARTTLS, and gives up when ctx is done.
+func (s *SMTP) SendEmail(ctx context.Context, e Email) error {
+	msg, err := s.compose(e)
+	if err != nil {
+		return err
+	}
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", s.Addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	host, _, _ := net.SplitHostPort(s.Addr)
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if s.Auth != nil {
+		if err := c.Auth(s.Auth); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(s.From.Address); err != nil {
+		return err
+	}
+	if err := c.Rcpt(e.To.Address); err != nil {
+		return err
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// compose renders e as an RFC 5322 message.
+func (s *SMTP) compose(e Email) ([]byte, error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", s.From.String())
+	fmt.Fprintf(&b, "To: %s\r\n", e.To.String())
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", e.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	keys := make([]string, 0, len(e.Headers))
+	for k := range e.Headers {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(&b, "%s: %s\r\n", k, e.Headers[k])
+	}
+	b.WriteString("MIME-Version: 1.0\r\n")
+
+	if e.HTML == "" {
+		b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+		b.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+		if err := writeQP(&b, e.Text); err != nil {
+			return nil, err
+		}
+		return []byte(b.String()), nil
+	}
+
+	boundary, err := newBoundary()
+	if err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(&b, "Content-Type: multipart/alternative; boundary=%q\r\n\r\n", boundary)
+	for _, part := range []struct{ typ, body string }{
+		{"text/plain", e.Text},
+		{"text/html", e.HTML},
+	} {
+		fmt.Fprintf(&b, "--%s\r\n", boundary)
+		fmt.Fprintf(&b, "Content-Type: %s; charset=utf-8\r\n", part.typ)
+		b.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+		if err := writeQP(&b, part.body); err != nil {
+			return nil, err
+		}
+		b.WriteString("\r\n")
+	}
+	fmt.Fprintf(&b, "--%s--\r\n", boundary)
+	return []byte(b.String()), nil
+}
+
+func writeQP(b *strings.Builder, s string) error {
+	w := quotedprintable.NewWriter(b)
+	if _, err := w.Write([]byte(strings.ReplaceAll(s, "\n", "\r\n"))); err != nil {
+		return err
+	}
+	return w.Close()
+}
+
+func newBoundary() (string, error) {
+	var buf [12]byte
+	if _, err := rand.Read(buf[:]); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf[:]), nil
+}
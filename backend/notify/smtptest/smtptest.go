@@ -0,0 +1,159 @@
+// Package smtptest provides an in-process SMTP server that captures the
+// mail it is sent, for tests of code that talks to notify.SMTP.
+package smtptest
+
+import (
+	"bufio"
+	"bytes"
+	"net"
+	"net/mail"
+	"strings"
+	"sync"
+)
+
+// Message is one captured message as the server received it.
+type Message struct {
+	From string
+	To   []string
+	Data []byte
+}
+
+// Parse parses the message's headers and body.
+func (m Message) Parse() (*mail.Message, error) {
+	return mail.ReadMessage(bytes.NewReader(m.Data))
+}
+
+// Server is a minimal SMTP server listening on a loopback port. It accepts
+// every message without authentication or TLS.
+type Server struct {
+	// Addr is the host:port the server listens on.
+	Addr string
+
+	ln   net.Listener
+	wg   sync.WaitGroup
+	mu   sync.Mutex
+	msgs []Message
+}
+
+// NewServer starts a Server on a random loopback port. Callers should
+// Close it when done.
+func NewServer() (*Server, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{Addr: ln.Addr().String(), ln: ln}
+	s.wg.Add(1)
+	go s.serve()
+	return s, nil
+}
+
+// Messages returns the messages captured so far, oldest first. A message
+// is captured before the server acknowledges its DATA, so it is visible
+// as soon as the client's send returns.
+func (s *Server) Messages() []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Message(nil), s.msgs...)
+}
+
+// Close stops the server and waits for open sessions to end.
+func (s *Server) Close() {
+	s.ln.Close()
+	s.wg.Wait()
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			defer conn.Close()
+			s.session(conn)
+		}()
+	}
+}
+
+func (s *Server) session(conn net.Conn) {
+	r := bufio.NewReader(conn)
+	reply := func(line string) bool {
+		_, err := conn.Write([]byte(line + "\r\n"))
+		return err == nil
+	}
+	if !reply("220 smtptest ready") {
+		return
+	}
+	var cur Message
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		verb, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "EHLO":
+			reply("250-smtptest")
+			reply("250 8BITMIME")
+		case "HELO":
+			reply("250 smtptest")
+		case "MAIL":
+			cur = Message{From: address(arg)}
+			reply("250 OK")
+		case "RCPT":
+			cur.To = append(cur.To, address(arg))
+			reply("250 OK")
+		case "DATA":
+			if !reply("354 end data with <CR><LF>.<CR><LF>") {
+				return
+			}
+			data, err := readData(r)
+			if err != nil {
+				return
+			}
+			cur.Data = data
+			s.mu.Lock()
+			s.msgs = append(s.msgs, cur)
+			s.mu.Unlock()
+			cur = Message{}
+			reply("250 OK")
+		case "RSET":
+			cur = Message{}
+			reply("250 OK")
+		case "NOOP":
+			reply("250 OK")
+		case "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("502 command not implemented")
+		}
+	}
+}
+
+// readData reads a dot-terminated DATA section, undoing dot-stuffing.
+func readData(r *bufio.Reader) ([]byte, error) {
+	var buf bytes.Buffer
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		if line == ".\r\n" || line == ".\n" {
+			return buf.Bytes(), nil
+		}
+		buf.WriteString(strings.TrimPrefix(line, "."))
+	}
+}
+
+// address extracts the mailbox from a "FROM:<a@b>" or "TO:<a@b>" argument.
+func address(arg string) string {
+	_, addr, _ := strings.Cut(arg, ":")
+	addr, _, _ = strings.Cut(strings.TrimSpace(addr), " ")
+	return strings.Trim(addr, "<>")
+}
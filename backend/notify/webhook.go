@@ -0,0 +1,33 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+
+	"workout-tracker/backend/models"
+
+	"gorm.io/gorm"
+)
+
+// Webhook delivers messages to the recipient's webhooks by logging them as
+// events of type m.Kind; the events outbox hands them to the webhook
+// dispatcher like any other change.
+type Webhook struct {
+	DB *gorm.DB
+}
+
+func (w *Webhook) Send(ctx context.Context, to Recipient, m Message) error {
+	payload, err := json.Marshal(m.Data)
+	if err != nil {
+		return err
+	}
+	return w.DB.WithContext(ctx).Create(&models.Event{
+		Type:             m.Kind,
+		ResourceType:     m.ResourceType,
+		ResourceID:       m.ResourceID,
+		ResourcePublicID: m.ResourcePublicID,
+		UserID:           to.UserID,
+		Visibility:       models.VisibilityPrivate,
+		Payload:          payload,
+	}).Error
+}
@@ -0,0 +1,250 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"workout-tracker/backend/models"
+	"workout-tracker/backend/netguard"
+
+	"gorm.io/gorm"
+)
+
+// recordSize is the aes128gcm record size advertised to push services; a
+// notification payload always fits in one record.
+const recordSize = 4096
+
+// maxPushPayload is the largest plaintext push services must accept
+// (RFC 8291 section 4) less the padding delimiter.
+const maxPushPayload = 3992
+
+// PushPayload is the JSON a service worker receives in its push event.
+type PushPayload struct {
+	Kind  string `json:"kind"`
+	Title string `json:"title"`
+	Body  string `json:"body"`
+	Data  any    `json:"data,omitempty"`
+}
+
+// WebPush delivers messages to every browser the recipient subscribed,
+// encrypting payloads per RFC 8291 and identifying this server with VAPID
+// (RFC 8292). Subscriptions the push service reports gone are deleted.
+type WebPush struct {
+	db  *gorm.DB
+	key *ecdsa.PrivateKey
+	pub string
+	// Subject is the VAPID contact, a mailto: or https: URL.
+	Subject string
+	// Client sends pushes. The default one refuses to connect to internal
+	// addresses; see netguard.
+	Client *http.Client
+	// TTL is how long push services keep an undelivered message.
+	TTL time.Duration
+}
+
+// NewWebPush returns a WebPush that signs with the VAPID private key given
+// as the base64url-encoded 32-byte P-256 scalar, the format VAPID key
+// generators print.
+func NewWebPush(db *gorm.DB, privateKey, subject string) (*WebPush, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("vapid private key: %w", err)
+	}
+	key, err := ecdsa.ParseRawPrivateKey(elliptic.P256(), raw)
+	if err != nil {
+		return nil, fmt.Errorf("vapid private key: %w", err)
+	}
+	pub, err := key.PublicKey.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	return &WebPush{
+		db:      db,
+		key:     key,
+		pub:     base64.RawURLEncoding.EncodeToString(pub),
+		Subject: subject,
+		Client:  netguard.Client(10 * time.Second),
+		TTL:     24 * time.Hour,
+	}, nil
+}
+
+// PublicKey returns the VAPID public key browsers pass to
+// pushManager.subscribe as applicationServerKey.
+func (p *WebPush) PublicKey() string {
+	return p.pub
+}
+
+func (p *WebPush) Send(ctx context.Context, to Recipient, m Message) error {
+	var subs []models.PushSubscription
+	if err := p.db.WithContext(ctx).Where("user_id = ?", to.UserID).Find(&subs).Error; err != nil {
+		return err
+	}
+	body, err := json.Marshal(PushPayload{Kind: m.Kind, Title: m.Subject, Body: m.Text, Data: m.Data})
+	if err != nil {
+		return err
+	}
+	if len(body) > maxPushPayload {
+		body, _ = json.Marshal(PushPayload{Kind: m.Kind, Title: m.Subject})
+	}
+	var errs []error
+	for _, s := range subs {
+		if err := p.push(ctx, s, body); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (p *WebPush) push(ctx context.Context, s models.PushSubscription, body []byte) error {
+	payload, err := EncryptPush(s.P256dh, s.Auth, body)
+	if err != nil {
+		return fmt.Errorf("subscription %d: %w", s.ID, err)
+	}
+	if !strings.HasPrefix(s.Endpoint, "https://") {
+		return fmt.Errorf("subscription %d: endpoint is not https", s.ID)
+	}
+	auth, err := p.vapid(s.Endpoint)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.Endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", auth)
+	req.Header.Set("Content-Encoding", "aes128gcm")
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("TTL", fmt.Sprint(int(p.TTL.Seconds())))
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		slog.Info("notify: push subscription expired", "subscription_id", s.ID)
+		return p.db.WithContext(ctx).Unscoped().Delete(&s).Error
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		return fmt.Errorf("subscription %d: push service answered %d", s.ID, resp.StatusCode)
+	}
+	return nil
+}
+
+// vapid returns the Authorization header value for a push to endpoint: a
+// short-lived ES256 JWT for the endpoint's origin plus our public key.
+func (p *WebPush) vapid(endpoint string) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", err
+	}
+	enc := base64.RawURLEncoding
+	header := enc.EncodeToString([]byte(`{"typ":"JWT","alg":"ES256"}`))
+	claims, err := json.Marshal(map[string]any{
+		"aud": u.Scheme + "://" + u.Host,
+		"exp": time.Now().Add(12 * time.Hour).Unix(),
+		"sub": p.Subject,
+	})
+	if err != nil {
+		return "", err
+	}
+	signing := header + "." + enc.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(signing))
+	r, s, err := ecdsa.Sign(rand.Reader, p.key, digest[:])
+	if err != nil {
+		return "", err
+	}
+	sig := make([]byte, 64)
+	r.FillBytes(sig[:32])
+	s.FillBytes(sig[32:])
+	return "vapid t=" + signing + "." + enc.EncodeToString(sig) + ", k=" + p.pub, nil
+}
+
+// EncryptPush encrypts plaintext for the subscription whose keys are p256dh
+// and auth (both base64url, as browsers report them) using the aes128gcm
+// content coding of RFC 8291.
+func EncryptPush(p256dh, auth string, plaintext []byte) ([]byte, error) {
+	uaRaw, err := decodeKey(p256dh)
+	if err != nil {
+		return nil, fmt.Errorf("p256dh: %w", err)
+	}
+	authSecret, err := decodeKey(auth)
+	if err != nil {
+		return nil, fmt.Errorf("auth: %w", err)
+	}
+	uaPublic, err := ecdh.P256().NewPublicKey(uaRaw)
+	if err != nil {
+		return nil, fmt.Errorf("p256dh: %w", err)
+	}
+	asPrivate, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	asPublic := asPrivate.PublicKey().Bytes()
+	shared, err := asPrivate.ECDH(uaPublic)
+	if err != nil {
+		return nil, err
+	}
+
+	keyInfo := "WebPush: info\x00" + string(uaRaw) + string(asPublic)
+	ikm, err := hkdf.Key(sha256.New, shared, authSecret, keyInfo, 32)
+	if err != nil {
+		return nil, err
+	}
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	cek, err := hkdf.Key(sha256.New, ikm, salt, "Content-Encoding: aes128gcm\x00", 16)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := hkdf.Key(sha256.New, ikm, salt, "Content-Encoding: nonce\x00", 12)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	// Header: salt, record size, key ID length and the key ID, which is our
+	// ephemeral public key. The single record ends with the 0x02 delimiter.
+	out := make([]byte, 0, 16+4+1+len(asPublic)+len(plaintext)+1+gcm.Overhead())
+	out = append(out, salt...)
+	out = binary.BigEndian.AppendUint32(out, recordSize)
+	out = append(out, byte(len(asPublic)))
+	out = append(out, asPublic...)
+	record := append(append([]byte(nil), plaintext...), 0x02)
+	return gcm.Seal(out, nonce, record, nil), nil
+}
+
+// decodeKey accepts base64url with or without padding; browsers and client
+// libraries disagree.
+func decodeKey(s string) ([]byte, error) {
+	if b, err := base64.RawURLEncoding.DecodeString(s); err == nil {
+		return b, nil
+	}
+	return base64.URLEncoding.DecodeString(s)
+}
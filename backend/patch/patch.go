@@ -0,0 +1,429 @@
+// Package patch applies partial updates to API resources: JSON Merge Patch
+// (RFC 7396) by default and JSON Patch (RFC 6902) when the request is sent
+// as application/json-patch+json.
+//
+// A patch is applied to the resource's writable fields as a JSON document
+// and the result is checked against the same schema a full replace (PUT)
+// is, so a field is only touched when the patch names it: null clears it,
+// and zero values such as 0 or "" are set like any other.
+package patch
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/danielgtaylor/huma/v2"
+)
+
+const (
+	// MergePatchType is the media type of an RFC 7396 merge patch. Plain
+	// application/json is treated the same.
+	MergePatchType = "application/merge-patch+json"
+	// JSONPatchType is the media type of an RFC 6902 JSON Patch.
+	JSONPatchType = "application/json-patch+json"
+)
+
+var (
+	// ErrTestFailed reports a JSON Patch "test" operation that didn't hold.
+	ErrTestFailed = errors.New("test operation failed")
+	// ErrPath reports a JSON Patch path that doesn't exist in the document.
+	ErrPath = errors.New("path not found")
+)
+
+// Body is a PATCH request body, kept as sent until To knows what it
+// applies to.
+type Body []byte
+
+func (b *Body) UnmarshalJSON(data []byte) error {
+	*b = append((*b)[:0], data...)
+	return nil
+}
+
+// Op is one JSON Patch operation.
+type Op struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// Merge applies an RFC 7396 merge patch to doc.
+func Merge(doc, patch []byte) ([]byte, error) {
+	var target, p any
+	if len(doc) > 0 {
+		if err := json.Unmarshal(doc, &target); err != nil {
+			return nil, err
+		}
+	}
+	if err := json.Unmarshal(patch, &p); err != nil {
+		return nil, err
+	}
+	return json.Marshal(merge(target, p))
+}
+
+func merge(target, patch any) any {
+	p, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+	t, ok := target.(map[string]any)
+	if !ok {
+		t = map[string]any{}
+	}
+	for k, v := range p {
+		if v == nil {
+			delete(t, k)
+			continue
+		}
+		t[k] = merge(t[k], v)
+	}
+	return t
+}
+
+// Apply applies an RFC 6902 JSON Patch to doc. The operations apply in
+// order and either all of them do or, on the first error, none.
+func Apply(doc []byte, ops []Op) ([]byte, error) {
+	var root any
+	if err := json.Unmarshal(doc, &root); err != nil {
+		return nil, err
+	}
+	for i, op := range ops {
+		var err error
+		if root, err = applyOp(root, op); err != nil {
+			return nil, fmt.Errorf("operation %d (%s %s): %w", i, op.Op, op.Path, err)
+		}
+	}
+	return json.Marshal(root)
+}
+
+func applyOp(root any, op Op) (any, error) {
+	path, err := pointer(op.Path)
+	if err != nil {
+		return nil, err
+	}
+	value := func() (any, error) {
+		if op.Value == nil {
+			return nil, errors.New("value is required")
+		}
+		var v any
+		err := json.Unmarshal(op.Value, &v)
+		return v, err
+	}
+	switch op.Op {
+	case "add":
+		v, err := value()
+		if err != nil {
+			return nil, err
+		}
+		return add(root, path, v)
+	case "remove":
+		root, _, err = remove(root, path)
+		return root, err
+	case "replace":
+		v, err := value()
+		if err != nil {
+			return nil, err
+		}
+		if len(path) == 0 {
+			return v, nil
+		}
+		if root, _, err = remove(root, path); err != nil {
+			return nil, err
+		}
+		return add(root, path, v)
+	case "move", "copy":
+		from, err := pointer(op.From)
+		if err != nil {
+			return nil, err
+		}
+		var v any
+		if op.Op == "move" {
+			if op.Path != op.From && strings.HasPrefix(op.Path, op.From+"/") {
+				return nil, errors.New("cannot move a value into itself")
+			}
+			root, v, err = remove(root, from)
+		} else {
+			v, err = get(root, from)
+			v = clone(v)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return add(root, path, v)
+	case "test":
+		want, err := value()
+		if err != nil {
+			return nil, err
+		}
+		got, err := get(root, path)
+		if err != nil {
+			return nil, err
+		}
+		if !reflect.DeepEqual(got, want) {
+			return nil, ErrTestFailed
+		}
+		return root, nil
+	}
+	return nil, fmt.Errorf("unknown operation %q", op.Op)
+}
+
+// pointer splits an RFC 6901 JSON Pointer into its reference tokens.
+func pointer(p string) ([]string, error) {
+	if p == "" {
+		return nil, nil
+	}
+	if p[0] != '/' {
+		return nil, fmt.Errorf("invalid JSON pointer %q", p)
+	}
+	tokens := strings.Split(p[1:], "/")
+	for i, t := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(t, "~1", "/"), "~0", "~")
+	}
+	return tokens, nil
+}
+
+// index resolves an array reference token; "-" (past the end) is only
+// allowed when adding.
+func index(token string, n int, adding bool) (int, error) {
+	if adding && token == "-" {
+		return n, nil
+	}
+	i, err := strconv.Atoi(token)
+	if err != nil || i < 0 || (token != "0" && token[0] == '0') {
+		return 0, fmt.Errorf("invalid array index %q", token)
+	}
+	if i > n || (!adding && i == n) {
+		return 0, ErrPath
+	}
+	return i, nil
+}
+
+func get(node any, path []string) (any, error) {
+	for _, t := range path {
+		switch n := node.(type) {
+		case map[string]any:
+			v, ok := n[t]
+			if !ok {
+				return nil, ErrPath
+			}
+			node = v
+		case []any:
+			i, err := index(t, len(n), false)
+			if err != nil {
+				return nil, err
+			}
+			node = n[i]
+		default:
+			return nil, ErrPath
+		}
+	}
+	return node, nil
+}
+
+// add sets the value at path, inserting into arrays, and returns the new
+// document root.
+func add(node any, path []string, v any) (any, error) {
+	if len(path) == 0 {
+		return v, nil
+	}
+	t, rest := path[0], path[1:]
+	switch n := node.(type) {
+	case map[string]any:
+		if len(rest) == 0 {
+			n[t] = v
+			return n, nil
+		}
+		child, ok := n[t]
+		if !ok {
+			return nil, ErrPath
+		}
+		child, err := add(child, rest, v)
+		n[t] = child
+		return n, err
+	case []any:
+		i, err := index(t, len(n), len(rest) == 0)
+		if err != nil {
+			return nil, err
+		}
+		if len(rest) == 0 {
+			return append(n[:i], append([]any{v}, n[i:]...)...), nil
+		}
+		n[i], err = add(n[i], rest, v)
+		return n, err
+	}
+	return nil, ErrPath
+}
+
+// remove deletes the value at path and returns the new document root and
+// the value removed.
+func remove(node any, path []string) (any, any, error) {
+	if len(path) == 0 {
+		return nil, nil, errors.New("cannot remove the whole document")
+	}
+	t, rest := path[0], path[1:]
+	switch n := node.(type) {
+	case map[string]any:
+		child, ok := n[t]
+		if !ok {
+			return nil, nil, ErrPath
+		}
+		if len(rest) == 0 {
+			delete(n, t)
+			return n, child, nil
+		}
+		child, removed, err := remove(child, rest)
+		n[t] = child
+		return n, removed, err
+	case []any:
+		i, err := index(t, len(n), false)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(rest) == 0 {
+			removed := n[i]
+			return append(n[:i], n[i+1:]...), removed, nil
+		}
+		child, removed, err := remove(n[i], rest)
+		n[i] = child
+		return n, removed, err
+	}
+	return nil, nil, ErrPath
+}
+
+func clone(v any) any {
+	raw, _ := json.Marshal(v)
+	var out any
+	_ = json.Unmarshal(raw, &out)
+	return out
+}
+
+// To applies body, sent with contentType, to current, a resource's
+// writable fields, and returns them as patched. The result must satisfy
+// T's schema; errors are Huma errors ready to return from a handler.
+func To[T any](current T, contentType string, body Body) (T, error) {
+	var out T
+	doc, err := json.Marshal(current)
+	if err != nil {
+		return out, huma.Error500InternalServerError("failed to read resource")
+	}
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch mediaType {
+	case JSONPatchType:
+		var ops []Op
+		if err := json.Unmarshal(body, &ops); err != nil {
+			return out, huma.Error400BadRequest("malformed JSON Patch: " + err.Error())
+		}
+		doc, err = Apply(doc, ops)
+	case MergePatchType, "application/json", "":
+		doc, err = Merge(doc, body)
+		if err != nil {
+			return out, huma.Error400BadRequest("malformed merge patch: " + err.Error())
+		}
+	default:
+		return out, huma.NewError(http.StatusUnsupportedMediaType,
+			fmt.Sprintf("patches must be sent as %s or %s", MergePatchType, JSONPatchType))
+	}
+	if errors.Is(err, ErrTestFailed) {
+		return out, huma.Error409Conflict(err.Error())
+	}
+	if err != nil {
+		return out, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	var parsed any
+	if err := json.Unmarshal(doc, &parsed); err != nil {
+		return out, huma.Error500InternalServerError("failed to read patched resource")
+	}
+	v := validatorFor(reflect.TypeFor[T]())
+	pb := huma.NewPathBuffer([]byte{}, 0)
+	pb.Push("body")
+	res := &huma.ValidateResult{}
+	huma.Validate(v.registry, v.schema, pb, huma.ModeWriteToServer, parsed, res)
+	if len(res.Errors) > 0 {
+		return out, huma.Error422UnprocessableEntity("patched resource is invalid", res.Errors...)
+	}
+	if err := json.Unmarshal(doc, &out); err != nil {
+		return out, huma.Error422UnprocessableEntity(err.Error())
+	}
+	return out, nil
+}
+
+type validator struct {
+	registry huma.Registry
+	schema   *huma.Schema
+}
+
+var (
+	validatorsMu sync.Mutex
+	validators   = map[reflect.Type]*validator{}
+)
+
+// validatorFor returns t's schema in a registry of its own, so validating
+// one type never races with another being added.
+func validatorFor(t reflect.Type) *validator {
+	validatorsMu.Lock()
+	defer validatorsMu.Unlock()
+	if v, ok := validators[t]; ok {
+		return v
+	}
+	r := huma.NewMapRegistry("#/components/schemas/", huma.DefaultSchemaNamer)
+	v := &validator{registry: r, schema: r.Schema(t, true, "")}
+	validators[t] = v
+	return v
+}
+
+// Operation returns an operation handler, for huma.Patch, documenting the
+// request body as a patch of a resource whose writable fields are T. The
+// input's Body must be a Body. Huma's own body validation is skipped: the
+// body is only checked once applied, by To.
+func Operation[T any](api huma.API) func(*huma.Operation) {
+	registry := api.OpenAPI().Components.Schemas
+	fields := registry.Schema(reflect.TypeFor[T](), true, "")
+	for fields.Ref != "" {
+		fields = registry.SchemaFromRef(fields.Ref)
+	}
+	mergeSchema := &huma.Schema{
+		Type:                 "object",
+		Description:          "Fields to change; null clears a field.",
+		Properties:           map[string]*huma.Schema{},
+		AdditionalProperties: false,
+	}
+	for name, p := range fields.Properties {
+		if p.ReadOnly {
+			continue
+		}
+		optional := *p
+		optional.Nullable = true
+		mergeSchema.Properties[name] = &optional
+	}
+	opSchema := &huma.Schema{
+		Type: "array",
+		Items: &huma.Schema{
+			Type:     "object",
+			Required: []string{"op", "path"},
+			Properties: map[string]*huma.Schema{
+				"op":    {Type: "string", Enum: []any{"add", "remove", "replace", "move", "copy", "test"}},
+				"path":  {Type: "string", Description: "JSON Pointer to the field operated on"},
+				"from":  {Type: "string", Description: "JSON Pointer to the source of a move or copy"},
+				"value": {Description: "The value to add, replace or test for"},
+			},
+		},
+	}
+	return func(op *huma.Operation) {
+		op.SkipValidateBody = true
+		op.RequestBody = &huma.RequestBody{
+			Required: true,
+			Content: map[string]*huma.MediaType{
+				MergePatchType: {Schema: mergeSchema},
+				JSONPatchType:  {Schema: opSchema},
+			},
+		}
+	}
+}
@@ -0,0 +1,292 @@
+// Package planner turns planned workouts into dated sessions and follows up
+// on them: it materialises recurring plans a few weeks ahead, reminds users
+// before a session and reports (or reschedules) the sessions they miss.
+package planner
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"workout-tracker/backend/events"
+	"workout-tracker/backend/jobs"
+	"workout-tracker/backend/models"
+	"workout-tracker/backend/notify"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Horizon is how far ahead recurring plans are materialised.
+const Horizon = 28 * 24 * time.Hour
+
+// MissGrace is how long after a session should have ended it is reported
+// missed if no workout was linked.
+const MissGrace = 12 * time.Hour
+
+// maxAutoReschedules bounds how often one session is moved by
+// AutoReschedule before it is left missed.
+const maxAutoReschedules = 3
+
+// Kinds of the planner's jobs.
+const (
+	KindMaterialize = "planner.materialize"
+	KindRemind      = "planner.remind"
+	KindMissed      = "planner.missed"
+)
+
+// SessionPayload is the machine-readable part of reminder and missed-session
+// notifications, sent as the webhook payload and push data.
+type SessionPayload struct {
+	SessionID   uuid.UUID `json:"session_id"`
+	PlanID      uuid.UUID `json:"plan_id"`
+	Name        string    `json:"name"`
+	ScheduledAt time.Time `json:"scheduled_at"`
+	Status      string    `json:"status"`
+	// RescheduledTo is set when a missed session was moved automatically.
+	RescheduledTo *time.Time `json:"rescheduled_to,omitempty"`
+}
+
+// Occurrences returns plan's occurrences in [from, to). A plan without a
+// rule has the single occurrence StartsAt.
+func Occurrences(plan models.PlannedWorkout, from, to time.Time) ([]time.Time, error) {
+	loc, err := time.LoadLocation(plan.Timezone)
+	if err != nil {
+		return nil, err
+	}
+	if plan.RRule == "" {
+		if plan.StartsAt.Before(from) || !plan.StartsAt.Before(to) {
+			return nil, nil
+		}
+		return []time.Time{plan.StartsAt}, nil
+	}
+	rec, err := ParseRRule(plan.RRule)
+	if err != nil {
+		return nil, err
+	}
+	return rec.Between(plan.StartsAt.In(loc), from, to), nil
+}
+
+// Materialize creates plan's sessions up to Horizon from now. A one-off
+// plan gets its session whenever it is. Existing sessions are left alone,
+// so it is safe to call repeatedly.
+func Materialize(db *gorm.DB, plan models.PlannedWorkout, now time.Time) error {
+	from, to := now, now.Add(Horizon)
+	if plan.RRule == "" {
+		from, to = plan.StartsAt, plan.StartsAt.Add(time.Nanosecond)
+	}
+	times, err := Occurrences(plan, from, to)
+	if err != nil || len(times) == 0 {
+		return err
+	}
+	rows := make([]models.PlannedSession, len(times))
+	for i, t := range times {
+		rows[i] = models.PlannedSession{
+			PlanID:      plan.ID,
+			UserID:      plan.UserID,
+			OccursAt:    t.UTC(),
+			ScheduledAt: t.UTC(),
+			Status:      models.SessionStatusScheduled,
+		}
+	}
+	return db.Omit(clause.Associations).
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&rows).Error
+}
+
+// ClearFuture removes plan's upcoming sessions that nobody has touched yet,
+// ahead of the plan changing or going away. Sessions that were done,
+// skipped, missed or moved are history and stay.
+func ClearFuture(db *gorm.DB, planID int64, now time.Time) error {
+	return db.Unscoped().
+		Where("plan_id = ? AND status = ? AND occurs_at >= ? AND reschedules = 0 AND missed_at IS NULL",
+			planID, models.SessionStatusScheduled, now).
+		Delete(&models.PlannedSession{}).Error
+}
+
+// Planner runs the planner's periodic jobs.
+type Planner struct {
+	db       *gorm.DB
+	channels notify.Channels
+
+	// Now is the clock; tests may pin it.
+	Now func() time.Time
+}
+
+// New returns a Planner that notifies users on channels.
+func New(db *gorm.DB, channels notify.Channels) *Planner {
+	return &Planner{db: db, channels: channels, Now: time.Now}
+}
+
+// Register installs the planner's job handlers on r and their schedules on
+// s: hourly materialisation, reminders every minute and a missed-session
+// check every quarter hour.
+func (p *Planner) Register(r *jobs.Runner, s *jobs.Scheduler) error {
+	r.Handle(KindMaterialize, func(ctx context.Context, _ models.Job) error { return p.MaterializeAll(ctx) })
+	r.Handle(KindRemind, func(ctx context.Context, _ models.Job) error { return p.SendReminders(ctx) })
+	r.Handle(KindMissed, func(ctx context.Context, _ models.Job) error { return p.ReportMissed(ctx) })
+
+	if err := s.Every("planner-materialize", "5 * * * *", KindMaterialize, nil); err != nil {
+		return err
+	}
+	if err := s.Every("planner-remind", "* * * * *", KindRemind, nil, jobs.MaxAttempts(1)); err != nil {
+		return err
+	}
+	return s.Every("planner-missed", "*/15 * * * *", KindMissed, nil)
+}
+
+// MaterializeAll extends every recurring plan's sessions to the horizon.
+func (p *Planner) MaterializeAll(ctx context.Context) error {
+	db := p.db.WithContext(ctx)
+	now := p.Now()
+	var plans []models.PlannedWorkout
+	return db.Where("rrule <> ''").FindInBatches(&plans, 100, func(tx *gorm.DB, _ int) error {
+		for _, plan := range plans {
+			if err := Materialize(db, plan, now); err != nil {
+				slog.Error("planner: materialize failed", "plan_id", plan.ID, "err", err)
+			}
+		}
+		return nil
+	}).Error
+}
+
+// due loads scheduled sessions of live plans matching where, with their
+// plan and its owner.
+func (p *Planner) due(ctx context.Context, where string, args ...any) ([]models.PlannedSession, error) {
+	var list []models.PlannedSession
+	err := p.db.WithContext(ctx).
+		Select("planned_sessions.*").
+		Joins("JOIN planned_workouts ON planned_workouts.id = planned_sessions.plan_id AND planned_workouts.deleted_at IS NULL").
+		Where("planned_sessions.status = ?", models.SessionStatusScheduled).
+		Where(where, args...).
+		Preload("Plan.User").
+		Order("planned_sessions.scheduled_at").
+		Find(&list).Error
+	return list, err
+}
+
+// SendReminders reminds users of sessions starting within their plan's
+// reminder lead time. Each session is claimed before its reminder goes out,
+// so it is sent at most once however many instances run this.
+func (p *Planner) SendReminders(ctx context.Context) error {
+	now := p.Now()
+	list, err := p.due(ctx,
+		"planned_sessions.reminded_at IS NULL AND planned_sessions.scheduled_at > ? "+
+			"AND planned_sessions.scheduled_at - make_interval(mins => planned_workouts.remind_minutes_before) <= ? "+
+			"AND jsonb_array_length(COALESCE(planned_workouts.reminder_channels, '[]')) > 0",
+		now, now)
+	if err != nil {
+		return err
+	}
+	for _, s := range list {
+		res := p.db.WithContext(ctx).Model(&models.PlannedSession{}).
+			Where("id = ? AND reminded_at IS NULL", s.ID).
+			Update("reminded_at", now)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			continue
+		}
+		loc := location(s.Plan)
+		p.send(ctx, s, notify.Message{
+			Kind:    events.TypeSessionReminder,
+			Subject: fmt.Sprintf("Reminder: %s at %s", s.Plan.Name, s.ScheduledAt.In(loc).Format("15:04")),
+			Text: fmt.Sprintf("%s is planned for %s.",
+				s.Plan.Name, s.ScheduledAt.In(loc).Format("Monday 2 January at 15:04 MST")),
+			Data: payload(s),
+		})
+	}
+	return nil
+}
+
+// ReportMissed finds sessions that ended MissGrace ago without a linked
+// workout, marks them missed and tells their owner. Plans with
+// AutoReschedule move the session to the same time the next day instead,
+// up to maxAutoReschedules times.
+func (p *Planner) ReportMissed(ctx context.Context) error {
+	now := p.Now()
+	list, err := p.due(ctx,
+		"planned_sessions.scheduled_at + make_interval(mins => planned_workouts.duration_minutes) < ?",
+		now.Add(-MissGrace))
+	if err != nil {
+		return err
+	}
+	for _, s := range list {
+		updates := map[string]any{"missed_at": now}
+		var movedTo *time.Time
+		if s.Plan.AutoReschedule && s.Reschedules < maxAutoReschedules {
+			next := s.ScheduledAt.In(location(s.Plan)).AddDate(0, 0, 1)
+			for next.Before(now) {
+				next = next.AddDate(0, 0, 1)
+			}
+			next = next.UTC()
+			movedTo = &next
+			updates["scheduled_at"] = next
+			updates["reminded_at"] = nil
+			updates["reschedules"] = s.Reschedules + 1
+		} else {
+			updates["status"] = models.SessionStatusMissed
+		}
+		res := p.db.WithContext(ctx).Model(&models.PlannedSession{}).
+			Where("id = ? AND status = ? AND scheduled_at = ?", s.ID, models.SessionStatusScheduled, s.ScheduledAt).
+			Updates(updates)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			continue
+		}
+
+		text := fmt.Sprintf("You missed %s, planned for %s.",
+			s.Plan.Name, s.ScheduledAt.In(location(s.Plan)).Format("Monday 2 January at 15:04 MST"))
+		data := payload(s)
+		data.Status = models.SessionStatusMissed
+		if movedTo != nil {
+			text += fmt.Sprintf(" It has been moved to %s.", movedTo.In(location(s.Plan)).Format("Monday 2 January at 15:04 MST"))
+			data.Status = models.SessionStatusScheduled
+			data.RescheduledTo = movedTo
+		}
+		p.send(ctx, s, notify.Message{
+			Kind:    events.TypeSessionMissed,
+			Subject: "Missed: " + s.Plan.Name,
+			Text:    text,
+			Data:    data,
+		})
+	}
+	return nil
+}
+
+// send delivers m about s on the plan's channels. Failures are logged, not
+// retried: a late reminder is worse than none.
+func (p *Planner) send(ctx context.Context, s models.PlannedSession, m notify.Message) {
+	m.ResourceType = events.ResourceSession
+	m.ResourceID = s.ID
+	m.ResourcePublicID = s.PublicID
+	to := notify.Recipient{UserID: s.Plan.User.ID, PublicID: s.Plan.User.PublicID, Email: s.Plan.User.Email, Name: s.Plan.User.Name}
+	if err := p.channels.Send(ctx, s.Plan.ReminderChannels, to, m); err != nil {
+		slog.WarnContext(ctx, "planner: notification failed", "kind", m.Kind, "session_id", s.ID, "err", err)
+	}
+}
+
+func payload(s models.PlannedSession) SessionPayload {
+	return SessionPayload{
+		SessionID:   s.PublicID,
+		PlanID:      s.Plan.PublicID,
+		Name:        s.Plan.Name,
+		ScheduledAt: s.ScheduledAt,
+		Status:      s.Status,
+	}
+}
+
+// location is the plan's zone, falling back to UTC for zones that have
+// since become unknown to the tz database.
+func location(plan models.PlannedWorkout) *time.Location {
+	loc, err := time.LoadLocation(plan.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
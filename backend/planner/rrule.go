@@ -0,0 +1,240 @@
+package planner
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxPeriods bounds how many periods expansion walks, so a rule whose
+// filters never match cannot loop forever.
+const maxPeriods = 10000
+
+var weekdays = map[string]time.Weekday{
+	"SU": time.Sunday, "MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday,
+	"TH": time.Thursday, "FR": time.Friday, "SA": time.Saturday,
+}
+
+// dayRule is one BYDAY entry. N is the ordinal within the month (1 for the
+// first, -1 for the last) and is only allowed with FREQ=MONTHLY; 0 means
+// every such weekday.
+type dayRule struct {
+	N   int
+	Day time.Weekday
+}
+
+// Recurrence is the subset of an RFC 5545 RRULE the planner supports:
+// FREQ=DAILY, WEEKLY or MONTHLY with INTERVAL, COUNT, UNTIL, BYDAY (with
+// ordinals for MONTHLY) and BYMONTHDAY (negative counts from month end).
+// Weeks start on Monday.
+type Recurrence struct {
+	Freq       string
+	Interval   int
+	Count      int
+	Until      time.Time
+	ByDay      []dayRule
+	ByMonthDay []int
+}
+
+// ParseRRule parses rule, with or without its "RRULE:" prefix.
+func ParseRRule(rule string) (Recurrence, error) {
+	r := Recurrence{Interval: 1}
+	rule = strings.TrimPrefix(strings.TrimSpace(rule), "RRULE:")
+	for _, part := range strings.Split(rule, ";") {
+		name, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return Recurrence{}, fmt.Errorf("rrule: malformed part %q", part)
+		}
+		var err error
+		switch strings.ToUpper(name) {
+		case "FREQ":
+			r.Freq = strings.ToUpper(value)
+			if r.Freq != "DAILY" && r.Freq != "WEEKLY" && r.Freq != "MONTHLY" {
+				return Recurrence{}, fmt.Errorf("rrule: unsupported FREQ %q", value)
+			}
+		case "INTERVAL":
+			if r.Interval, err = strconv.Atoi(value); err != nil || r.Interval < 1 {
+				return Recurrence{}, fmt.Errorf("rrule: bad INTERVAL %q", value)
+			}
+		case "COUNT":
+			if r.Count, err = strconv.Atoi(value); err != nil || r.Count < 1 {
+				return Recurrence{}, fmt.Errorf("rrule: bad COUNT %q", value)
+			}
+		case "UNTIL":
+			if r.Until, err = parseUntil(value); err != nil {
+				return Recurrence{}, err
+			}
+		case "BYDAY":
+			for _, d := range strings.Split(value, ",") {
+				d = strings.ToUpper(d)
+				if len(d) < 2 {
+					return Recurrence{}, fmt.Errorf("rrule: bad BYDAY %q", d)
+				}
+				day, ok := weekdays[d[len(d)-2:]]
+				if !ok {
+					return Recurrence{}, fmt.Errorf("rrule: bad BYDAY %q", d)
+				}
+				n := 0
+				if prefix := d[:len(d)-2]; prefix != "" {
+					if n, err = strconv.Atoi(prefix); err != nil || n == 0 || n < -5 || n > 5 {
+						return Recurrence{}, fmt.Errorf("rrule: bad BYDAY %q", d)
+					}
+				}
+				r.ByDay = append(r.ByDay, dayRule{N: n, Day: day})
+			}
+		case "BYMONTHDAY":
+			for _, d := range strings.Split(value, ",") {
+				n, err := strconv.Atoi(d)
+				if err != nil || n == 0 || n < -31 || n > 31 {
+					return Recurrence{}, fmt.Errorf("rrule: bad BYMONTHDAY %q", d)
+				}
+				r.ByMonthDay = append(r.ByMonthDay, n)
+			}
+		case "WKST":
+			if strings.ToUpper(value) != "MO" {
+				return Recurrence{}, fmt.Errorf("rrule: only WKST=MO is supported")
+			}
+		default:
+			return Recurrence{}, fmt.Errorf("rrule: unsupported part %s", name)
+		}
+	}
+	if r.Freq == "" {
+		return Recurrence{}, fmt.Errorf("rrule: FREQ is required")
+	}
+	if r.Count > 0 && !r.Until.IsZero() {
+		return Recurrence{}, fmt.Errorf("rrule: COUNT and UNTIL are mutually exclusive")
+	}
+	for _, d := range r.ByDay {
+		if d.N != 0 && r.Freq != "MONTHLY" {
+			return Recurrence{}, fmt.Errorf("rrule: BYDAY ordinals need FREQ=MONTHLY")
+		}
+	}
+	return r, nil
+}
+
+func parseUntil(v string) (time.Time, error) {
+	for _, layout := range []string{"20060102T150405Z", "20060102"} {
+		if t, err := time.Parse(layout, v); err == nil {
+			if layout == "20060102" {
+				// A date UNTIL includes that whole day.
+				t = t.Add(24*time.Hour - time.Second)
+			}
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("rrule: bad UNTIL %q", v)
+}
+
+// Between returns the occurrences of the rule starting at dtstart that fall
+// in [from, to), in order. Occurrences take dtstart's time of day in
+// dtstart's location. COUNT is counted from dtstart, so the result is the
+// same however the window is chosen.
+func (r Recurrence) Between(dtstart, from, to time.Time) []time.Time {
+	var out []time.Time
+	seen := 0
+	for n := 0; n < maxPeriods; n++ {
+		for _, t := range r.period(dtstart, n) {
+			if t.Before(dtstart) {
+				continue
+			}
+			if !r.Until.IsZero() && t.After(r.Until) {
+				return out
+			}
+			if !t.Before(to) {
+				return out
+			}
+			seen++
+			if !t.Before(from) {
+				out = append(out, t)
+			}
+			if r.Count > 0 && seen >= r.Count {
+				return out
+			}
+		}
+	}
+	return out
+}
+
+// period returns the candidate occurrences of the n-th period after the one
+// containing dtstart, in order.
+func (r Recurrence) period(dtstart time.Time, n int) []time.Time {
+	y, m, d := dtstart.Date()
+	at := func(y int, m time.Month, d int) (time.Time, bool) {
+		t := time.Date(y, m, d, dtstart.Hour(), dtstart.Minute(), dtstart.Second(), 0, dtstart.Location())
+		return t, t.Day() == d && t.Month() == m
+	}
+
+	var out []time.Time
+	switch r.Freq {
+	case "DAILY":
+		t, _ := at(y, m, d+n*r.Interval)
+		if r.matchesDay(t) && r.matchesMonthDay(t) {
+			out = append(out, t)
+		}
+	case "WEEKLY":
+		monday := d - (int(dtstart.Weekday())+6)%7 + 7*n*r.Interval
+		days := r.ByDay
+		if len(days) == 0 {
+			days = []dayRule{{Day: dtstart.Weekday()}}
+		}
+		for off := range 7 {
+			t, _ := at(y, m, monday+off)
+			if slices.ContainsFunc(days, func(dr dayRule) bool { return dr.Day == t.Weekday() }) && r.matchesMonthDay(t) {
+				out = append(out, t)
+			}
+		}
+	case "MONTHLY":
+		first := time.Date(y, m+time.Month(n*r.Interval), 1, 0, 0, 0, 0, dtstart.Location())
+		last := first.AddDate(0, 1, -1).Day()
+		for day := 1; day <= last; day++ {
+			t, ok := at(first.Year(), first.Month(), day)
+			if !ok {
+				continue
+			}
+			switch {
+			case len(r.ByDay) == 0 && len(r.ByMonthDay) == 0:
+				if day == d {
+					out = append(out, t)
+				}
+			case r.matchesMonthDay(t) && r.matchesOrdinalDay(t, last):
+				out = append(out, t)
+			}
+		}
+	}
+	return out
+}
+
+func (r Recurrence) matchesDay(t time.Time) bool {
+	return len(r.ByDay) == 0 || slices.ContainsFunc(r.ByDay, func(dr dayRule) bool { return dr.Day == t.Weekday() })
+}
+
+func (r Recurrence) matchesMonthDay(t time.Time) bool {
+	if len(r.ByMonthDay) == 0 {
+		return true
+	}
+	last := time.Date(t.Year(), t.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day()
+	for _, md := range r.ByMonthDay {
+		if md == t.Day() || (md < 0 && last+md+1 == t.Day()) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesOrdinalDay checks BYDAY within a month, where 2TU is the second
+// Tuesday and -1FR the last Friday.
+func (r Recurrence) matchesOrdinalDay(t time.Time, last int) bool {
+	if len(r.ByDay) == 0 {
+		return true
+	}
+	fromStart := (t.Day()-1)/7 + 1
+	fromEnd := -((last-t.Day())/7 + 1)
+	for _, dr := range r.ByDay {
+		if dr.Day == t.Weekday() && (dr.N == 0 || dr.N == fromStart || dr.N == fromEnd) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,81 @@
+package reports
+
+import (
+	"bytes"
+	_ "embed"
+	"fmt"
+	"html/template"
+	"math"
+	"strconv"
+	"time"
+
+	"workout-tracker/backend/models"
+	"workout-tracker/backend/schemas"
+)
+
+//go:embed templates/report.html.tmpl
+var reportTemplate string
+
+var document = template.Must(template.New("report").Funcs(template.FuncMap{
+	"title": func(r schemas.ReportResponse) string {
+		if r.Period == models.ReportPeriodMonthly {
+			return "Monthly report: " + r.Start.Format("January 2006")
+		}
+		return "Weekly report: week of " + r.Start.Format("2 January 2006")
+	},
+	"periodNoun": func(period string) string {
+		if period == models.ReportPeriodMonthly {
+			return "month"
+		}
+		return "week"
+	},
+	"date":    func(t time.Time) string { return t.Format("Mon 2 Jan 2006") },
+	"lastDay": func(end time.Time) time.Time { return end.AddDate(0, 0, -1) },
+	"km":      func(m int) string { return fmt.Sprintf("%.2f km", float64(m)/1000) },
+	"duration": func(min int) string {
+		if min < 60 {
+			return fmt.Sprintf("%d min", min)
+		}
+		return fmt.Sprintf("%dh %02dm", min/60, min%60)
+	},
+	"metric": func(metric string, v float64) string {
+		switch metric {
+		case MetricDistance:
+			return fmt.Sprintf("%.2f km", v/1000)
+		case MetricWeight:
+			return kg(v)
+		}
+		return fmt.Sprintf("%.0f min", v)
+	},
+	"kg": kg,
+	"delta": func(d int, unit string) template.HTML {
+		switch {
+		case d > 0:
+			return template.HTML(fmt.Sprintf(`<span class="up">+%d%s</span>`, d, template.HTMLEscapeString(unit)))
+		case d < 0:
+			return template.HTML(fmt.Sprintf(`<span class="down">%d%s</span>`, d, template.HTMLEscapeString(unit)))
+		}
+		return ""
+	},
+	"percent": func(p *float64) string { return fmt.Sprintf("%+.0f%%", *p) },
+	// number shows a custom field statistic to at most two decimals.
+	"number": func(f *float64) string {
+		return strconv.FormatFloat(math.Round(*f*100)/100, 'f', -1, 64)
+	},
+	"mul100": func(f float64) float64 { return f * 100 },
+}).Parse(reportTemplate))
+
+// kg shows a weight or volume to at most one decimal.
+func kg(v float64) string {
+	return strconv.FormatFloat(math.Round(v*10)/10, 'f', -1, 64) + " kg"
+}
+
+// RenderHTML renders r as a standalone HTML document, styled to print
+// cleanly to PDF from a browser.
+func RenderHTML(r schemas.ReportResponse) ([]byte, error) {
+	var b bytes.Buffer
+	if err := document.Execute(&b, r); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
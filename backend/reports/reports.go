@@ -0,0 +1,461 @@
+// Package reports builds weekly and monthly training summaries: volume,
+// the change against the previous period, personal records, progress
+// on planned sessions, and volume by tag and custom field values. A
+// scheduled job generates and stores each completed period's report so it
+// can be revisited as it was first reported.
+//
+// Lifting volume and weight records count working sets only; warm-ups
+// are left out.
+//
+// Periods are calendar weeks (Monday to Sunday) and months in UTC.
+package reports
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"time"
+
+	"workout-tracker/backend/jobs"
+	"workout-tracker/backend/models"
+	"workout-tracker/backend/schemas"
+	"workout-tracker/backend/training"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// KindGenerate is the job that stores every active user's report for the
+// last completed period.
+const KindGenerate = "reports.generate"
+
+// Metrics personal records are set in.
+const (
+	MetricDuration = "duration"
+	MetricDistance = "distance"
+	MetricWeight   = "weight"
+)
+
+// GenerateArgs are the arguments of a KindGenerate job.
+type GenerateArgs struct {
+	Period string `json:"period"`
+}
+
+// ErrUnknownPeriod is returned for periods other than weekly and monthly.
+var ErrUnknownPeriod = errors.New("unknown report period")
+
+// Bounds returns the period containing t as [start, end).
+func Bounds(period string, t time.Time) (time.Time, time.Time, error) {
+	t = t.UTC()
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	switch period {
+	case models.ReportPeriodWeekly:
+		start := day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+		return start, start.AddDate(0, 0, 7), nil
+	case models.ReportPeriodMonthly:
+		start := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+		return start, start.AddDate(0, 1, 0), nil
+	}
+	return time.Time{}, time.Time{}, ErrUnknownPeriod
+}
+
+// LastCompleted returns the most recent period that ended by now.
+func LastCompleted(period string, now time.Time) (time.Time, time.Time, error) {
+	start, _, err := Bounds(period, now)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	return Bounds(period, start.Add(-time.Nanosecond))
+}
+
+// Build computes userID's report for the period starting at start.
+func Build(db *gorm.DB, userID int64, period string, start time.Time) (schemas.ReportData, error) {
+	start, end, err := Bounds(period, start)
+	if err != nil {
+		return schemas.ReportData{}, err
+	}
+	prevStart, _, _ := Bounds(period, start.Add(-time.Nanosecond))
+	data := schemas.ReportData{
+		Period:          period,
+		Start:           start,
+		End:             end,
+		PersonalRecords: []schemas.ReportRecord{},
+		Workouts:        []schemas.ReportWorkout{},
+		Tags:            []schemas.ReportTag{},
+		CustomFields:    []schemas.ReportField{},
+	}
+
+	if data.Totals, err = totals(db, userID, start, end); err != nil {
+		return data, err
+	}
+	prev, err := totals(db, userID, prevStart, start)
+	if err != nil {
+		return data, err
+	}
+	data.Comparison = schemas.ReportComparison{
+		Previous:           prev,
+		SessionsDelta:      data.Totals.Sessions - prev.Sessions,
+		TotalMinutesDelta:  data.Totals.TotalMinutes - prev.TotalMinutes,
+		TotalDistanceDelta: data.Totals.TotalDistanceMeters - prev.TotalDistanceMeters,
+		TotalVolumeDelta:   data.Totals.TotalVolumeKg - prev.TotalVolumeKg,
+	}
+	if prev.TotalMinutes > 0 {
+		pct := float64(data.Totals.TotalMinutes-prev.TotalMinutes) / float64(prev.TotalMinutes) * 100
+		data.Comparison.TotalMinutesPercent = &pct
+	}
+
+	var workouts []models.Workout
+	err = db.Where("user_id = ? AND performed_at >= ? AND performed_at < ?", userID, start, end).
+		Order("performed_at, id").
+		Find(&workouts).Error
+	if err != nil {
+		return data, err
+	}
+	for _, w := range workouts {
+		sum := training.Sum(w.Exercises)
+		data.Workouts = append(data.Workouts, schemas.ReportWorkout{
+			ID:              w.PublicID,
+			Name:            w.Name,
+			PerformedAt:     w.PerformedAt,
+			DurationMinutes: w.DurationMinutes,
+			DistanceMeters:  w.DistanceMeters,
+			WorkingSets:     sum.WorkingSets,
+			VolumeKg:        sum.VolumeKg,
+			Tags:            w.Tags,
+			CustomFields:    w.CustomFields,
+		})
+	}
+	data.Tags = tagVolume(workouts)
+	if data.CustomFields, err = fieldSummaries(db, userID, workouts); err != nil {
+		return data, err
+	}
+
+	if data.PersonalRecords, err = records(db, userID, start, end); err != nil {
+		return data, err
+	}
+	lifts, err := weightRecords(db, userID, start, end)
+	if err != nil {
+		return data, err
+	}
+	data.PersonalRecords = append(data.PersonalRecords, lifts...)
+	slices.SortStableFunc(data.PersonalRecords, func(a, b schemas.ReportRecord) int {
+		return a.PerformedAt.Compare(b.PerformedAt)
+	})
+
+	var goals struct {
+		Planned, Completed, Skipped, Missed int
+	}
+	err = db.Model(&models.PlannedSession{}).
+		Select("COUNT(*) AS planned, "+
+			"COUNT(*) FILTER (WHERE status = ?) AS completed, "+
+			"COUNT(*) FILTER (WHERE status = ?) AS skipped, "+
+			"COUNT(*) FILTER (WHERE status = ?) AS missed",
+			models.SessionStatusDone, models.SessionStatusSkipped, models.SessionStatusMissed).
+		Where("user_id = ? AND scheduled_at >= ? AND scheduled_at < ?", userID, start, end).
+		Scan(&goals).Error
+	if err != nil {
+		return data, err
+	}
+	data.Goals = schemas.ReportGoals{Planned: goals.Planned, Completed: goals.Completed, Skipped: goals.Skipped, Missed: goals.Missed}
+	if goals.Planned > 0 {
+		data.Goals.Progress = float64(goals.Completed) / float64(goals.Planned)
+	}
+	return data, nil
+}
+
+// workingSets joins each workout to the number of its working sets and
+// the volume they moved.
+const workingSets = `LEFT JOIN LATERAL (
+  SELECT COUNT(*) AS sets, SUM((s->>'reps')::float8 * (s->>'weight_kg')::float8) AS volume
+  FROM jsonb_array_elements(workouts.exercises) e, jsonb_array_elements(e->'sets') s
+  WHERE NOT COALESCE((s->>'warmup')::boolean, false)
+) lifted ON true`
+
+func totals(db *gorm.DB, userID int64, from, to time.Time) (schemas.ReportTotals, error) {
+	var t schemas.ReportTotals
+	err := db.Model(&models.Workout{}).
+		Select("COUNT(*) AS sessions, "+
+			"COALESCE(SUM(duration_minutes), 0) AS total_minutes, "+
+			"COALESCE(SUM(distance_meters), 0) AS total_distance_meters, "+
+			"COUNT(DISTINCT DATE(performed_at)) AS active_days, "+
+			"COALESCE(SUM(lifted.sets), 0) AS working_sets, "+
+			"COALESCE(SUM(lifted.volume), 0) AS total_volume_kg").
+		Joins(workingSets).
+		Where("user_id = ? AND performed_at >= ? AND performed_at < ?", userID, from, to).
+		Scan(&t).Error
+	return t, err
+}
+
+// tagVolume totals workouts by tag, most sessions first.
+func tagVolume(workouts []models.Workout) []schemas.ReportTag {
+	byTag := map[string]*schemas.ReportTag{}
+	out := []schemas.ReportTag{}
+	for _, w := range workouts {
+		for _, tag := range w.Tags {
+			t := byTag[tag]
+			if t == nil {
+				t = &schemas.ReportTag{Tag: tag}
+				byTag[tag] = t
+			}
+			t.Sessions++
+			t.TotalMinutes += w.DurationMinutes
+			t.TotalDistanceMeters += w.DistanceMeters
+			t.TotalVolumeKg += training.Sum(w.Exercises).VolumeKg
+		}
+	}
+	for _, t := range byTag {
+		out = append(out, *t)
+	}
+	slices.SortFunc(out, func(a, b schemas.ReportTag) int {
+		if c := cmp.Compare(b.Sessions, a.Sessions); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Tag, b.Tag)
+	})
+	return out
+}
+
+// fieldSummaries summarizes the values workouts hold for userID's custom
+// fields, in key order. Values of fields since deleted, or of another
+// type than the field now has, are left out.
+func fieldSummaries(db *gorm.DB, userID int64, workouts []models.Workout) ([]schemas.ReportField, error) {
+	out := []schemas.ReportField{}
+	if !slices.ContainsFunc(workouts, func(w models.Workout) bool { return len(w.CustomFields) > 0 }) {
+		return out, nil
+	}
+	var defs []models.CustomField
+	if err := db.Where("user_id = ?", userID).Order("key").Find(&defs).Error; err != nil {
+		return nil, err
+	}
+	for _, def := range defs {
+		f := schemas.ReportField{Key: def.Key, Name: def.Name, Type: def.Type, Unit: def.Unit}
+		counts := map[string]int{}
+		var sum, lo, hi float64
+		trues := 0
+		for _, w := range workouts {
+			switch v := w.CustomFields[def.Key].(type) {
+			case float64:
+				if def.Type != models.FieldTypeNumber {
+					continue
+				}
+				if f.Count == 0 || v < lo {
+					lo = v
+				}
+				if f.Count == 0 || v > hi {
+					hi = v
+				}
+				sum += v
+			case bool:
+				if def.Type != models.FieldTypeBoolean {
+					continue
+				}
+				if v {
+					trues++
+				}
+			case string:
+				if def.Type == models.FieldTypeEnum {
+					counts[v]++
+				} else if def.Type != models.FieldTypeText {
+					continue
+				}
+			default:
+				continue
+			}
+			f.Count++
+		}
+		if f.Count == 0 {
+			continue
+		}
+		switch def.Type {
+		case models.FieldTypeNumber:
+			avg := sum / float64(f.Count)
+			f.Sum, f.Average, f.Min, f.Max = &sum, &avg, &lo, &hi
+		case models.FieldTypeBoolean:
+			f.TrueCount = &trues
+		case models.FieldTypeEnum:
+			for _, option := range def.Options {
+				if n := counts[option]; n > 0 {
+					f.Values = append(f.Values, schemas.ReportFieldValue{Value: option, Count: n})
+					delete(counts, option)
+				}
+			}
+			// Options since removed from the field come last.
+			for _, value := range slices.Sorted(maps.Keys(counts)) {
+				f.Values = append(f.Values, schemas.ReportFieldValue{Value: value, Count: counts[value]})
+			}
+		}
+		out = append(out, f)
+	}
+	return out, nil
+}
+
+// records finds the workouts in [from, to) that beat the user's best
+// duration or distance up to then. A user's first workout sets none.
+func records(db *gorm.DB, userID int64, from, to time.Time) ([]schemas.ReportRecord, error) {
+	var rows []struct {
+		PublicID        uuid.UUID
+		Name            string
+		PerformedAt     time.Time
+		DurationMinutes int
+		DistanceMeters  int
+		Prior           int
+		BestDuration    int
+		BestDistance    int
+	}
+	err := db.Raw(`SELECT * FROM (
+  SELECT id, public_id, name, performed_at, duration_minutes, distance_meters,
+    COUNT(*) OVER prior AS prior,
+    COALESCE(MAX(duration_minutes) OVER prior, 0) AS best_duration,
+    COALESCE(MAX(distance_meters) OVER prior, 0) AS best_distance
+  FROM workouts
+  WHERE user_id = ? AND performed_at < ? AND deleted_at IS NULL
+  WINDOW prior AS (ORDER BY performed_at, id ROWS BETWEEN UNBOUNDED PRECEDING AND 1 PRECEDING)
+) history WHERE performed_at >= ? ORDER BY performed_at, id`, userID, to, from).Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	out := []schemas.ReportRecord{}
+	for _, r := range rows {
+		if r.Prior == 0 {
+			continue
+		}
+		rec := schemas.ReportRecord{WorkoutID: r.PublicID, WorkoutName: r.Name, PerformedAt: r.PerformedAt}
+		if r.DurationMinutes > r.BestDuration {
+			rec.Metric, rec.Value, rec.Previous = MetricDuration, float64(r.DurationMinutes), float64(r.BestDuration)
+			out = append(out, rec)
+		}
+		if r.DistanceMeters > r.BestDistance {
+			rec.Metric, rec.Value, rec.Previous = MetricDistance, float64(r.DistanceMeters), float64(r.BestDistance)
+			out = append(out, rec)
+		}
+	}
+	return out, nil
+}
+
+// weightRecords finds the exercises of workouts in [from, to) lifted
+// heavier than ever before, by the heaviest working set. Exercises match
+// by name, whatever its case; the first workout with an exercise sets no
+// record for it.
+func weightRecords(db *gorm.DB, userID int64, from, to time.Time) ([]schemas.ReportRecord, error) {
+	var rows []struct {
+		PublicID    uuid.UUID
+		Name        string
+		PerformedAt time.Time
+		Exercise    string
+		Weight      float64
+		Best        float64
+	}
+	err := db.Raw(`SELECT * FROM (
+  SELECT w.public_id, w.name, w.performed_at, lift.exercise, lift.weight,
+    COUNT(*) OVER prior AS prior,
+    COALESCE(MAX(lift.weight) OVER prior, 0) AS best
+  FROM workouts w
+  CROSS JOIN LATERAL (
+    SELECT MIN(e->>'name') AS exercise, lower(e->>'name') AS exercise_key, MAX((s->>'weight_kg')::float8) AS weight
+    FROM jsonb_array_elements(w.exercises) e, jsonb_array_elements(e->'sets') s
+    WHERE s->>'weight_kg' IS NOT NULL AND NOT COALESCE((s->>'warmup')::boolean, false)
+    GROUP BY lower(e->>'name')
+  ) lift
+  WHERE w.user_id = ? AND w.performed_at < ? AND w.deleted_at IS NULL
+  WINDOW prior AS (PARTITION BY lift.exercise_key ORDER BY w.performed_at, w.id ROWS BETWEEN UNBOUNDED PRECEDING AND 1 PRECEDING)
+) history WHERE performed_at >= ? AND prior > 0 AND weight > best ORDER BY performed_at, exercise`, userID, to, from).Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	out := make([]schemas.ReportRecord, len(rows))
+	for i, r := range rows {
+		out[i] = schemas.ReportRecord{
+			WorkoutID: r.PublicID, WorkoutName: r.Name, PerformedAt: r.PerformedAt,
+			Metric: MetricWeight, Exercise: r.Exercise, Value: r.Weight, Previous: r.Best,
+		}
+	}
+	return out, nil
+}
+
+// Store saves data as userID's report, replacing one generated earlier
+// for the same period. A replaced report keeps its ID and creation time,
+// which are read back into the result.
+func Store(db *gorm.DB, userID int64, data schemas.ReportData) (models.Report, error) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return models.Report{}, err
+	}
+	r := models.Report{UserID: userID, Period: data.Period, PeriodStart: data.Start, PeriodEnd: data.End, Data: raw}
+	err = db.Omit(clause.Associations).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "period"}, {Name: "period_start"}},
+		DoUpdates: clause.AssignmentColumns([]string{"period_end", "data", "updated_at"}),
+	}, clause.Returning{Columns: []clause.Column{{Name: "id"}, {Name: "public_id"}, {Name: "created_at"}}}).Create(&r).Error
+	return r, err
+}
+
+// Generator runs the scheduled report generation.
+type Generator struct {
+	db *gorm.DB
+
+	// Now is the clock; tests may pin it.
+	Now func() time.Time
+}
+
+// New returns a Generator storing reports in db.
+func New(db *gorm.DB) *Generator {
+	return &Generator{db: db, Now: time.Now}
+}
+
+// Register installs the generation job on r and schedules it on s early
+// on Mondays for the past week and on the 1st for the past month.
+func (g *Generator) Register(r *jobs.Runner, s *jobs.Scheduler) error {
+	r.Handle(KindGenerate, func(ctx context.Context, job models.Job) error {
+		var args GenerateArgs
+		if err := json.Unmarshal(job.Args, &args); err != nil {
+			return err
+		}
+		return g.GenerateAll(ctx, args.Period)
+	})
+	if err := s.Every("reports-weekly", "0 2 * * 1", KindGenerate, GenerateArgs{Period: models.ReportPeriodWeekly}); err != nil {
+		return err
+	}
+	return s.Every("reports-monthly", "0 2 1 * *", KindGenerate, GenerateArgs{Period: models.ReportPeriodMonthly})
+}
+
+// GenerateAll stores the last completed period's report for every user
+// who trained or had sessions planned in it or the period before. One
+// user's failure doesn't stop the rest; the job fails at the end so it is
+// retried, and reports already stored are simply regenerated.
+func (g *Generator) GenerateAll(ctx context.Context, period string) error {
+	start, end, err := LastCompleted(period, g.Now())
+	if err != nil {
+		return err
+	}
+	prevStart, _, _ := Bounds(period, start.Add(-time.Nanosecond))
+	db := g.db.WithContext(ctx)
+
+	var userIDs []int64
+	err = db.Raw(`SELECT user_id FROM workouts WHERE performed_at >= ? AND performed_at < ? AND deleted_at IS NULL
+UNION
+SELECT user_id FROM planned_sessions WHERE scheduled_at >= ? AND scheduled_at < ? AND deleted_at IS NULL
+ORDER BY user_id`, prevStart, end, start, end).Scan(&userIDs).Error
+	if err != nil {
+		return err
+	}
+	var failed int
+	for _, id := range userIDs {
+		data, err := Build(db, id, period, start)
+		if err == nil {
+			_, err = Store(db, id, data)
+		}
+		if err != nil {
+			failed++
+			slog.ErrorContext(ctx, "reports: generation failed", "user_id", id, "period", period, "err", err)
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d %s reports failed", failed, len(userIDs), period)
+	}
+	return nil
+}
@@ -3,8 +3,19 @@ package backend
 import (
 	"context"
 	"net/http"
+	"os"
+	"path/filepath"
+	"time"
 
+	"workout-tracker/backend/attachments"
+	"workout-tracker/backend/audit"
+	"workout-tracker/backend/blob"
+	"workout-tracker/backend/email"
+	"workout-tracker/backend/events"
 	"workout-tracker/backend/handlers"
+	"workout-tracker/backend/idempotency"
+	"workout-tracker/backend/jobs"
+	"workout-tracker/backend/notify"
 
 	"github.com/danielgtaylor/huma/v2"
 	"gorm.io/gorm"
@@ -18,10 +29,110 @@ type healthOutput struct {
 	Body healthBody
 }
 
+// Option customises the collaborators RegisterRoutes hands to handlers.
+// Anything not configured falls back to an in-process or no-op default, so
+// tests and the schema generator can call RegisterRoutes(api, db).
+type Option func(*options)
+
+type options struct {
+	notifier notify.Notifier
+	bus      events.Bus
+	signer   *email.Signer
+	// retentionDays is how long deleted workouts stay in the trash.
+	retentionDays int
+	idempotency   *idempotency.Store
+	blobs         blob.Store
+	urlSigner     *attachments.Signer
+	limits        attachments.Limits
+}
+
+// WithNotifier routes user-facing notifications (follows, likes, comments)
+// to n instead of discarding them.
+func WithNotifier(n notify.Notifier) Option {
+	return func(o *options) { o.notifier = n }
+}
+
+// WithEventBus sets the bus the /api/v1/events stream subscribes to. It
+// should be the bus db's change callbacks publish on (see
+// events.RegisterCallbacks). Defaults to an in-process bus.
+func WithEventBus(b events.Bus) Option {
+	return func(o *options) { o.bus = b }
+}
+
+// WithUnsubscribeSigner sets the key unsubscribe links are checked with. It
+// must be the signer the email.Mailer uses; the default is a random key
+// that accepts no mailed link.
+func WithUnsubscribeSigner(s *email.Signer) Option {
+	return func(o *options) { o.signer = s }
+}
+
+// WithTrashRetention tells clients how many days deleted workouts are kept
+// before they are purged. It should match the retention the purge job runs
+// with; defaults to jobs.DefaultRetentionDays.
+func WithTrashRetention(days int) Option {
+	return func(o *options) { o.retentionDays = days }
+}
+
+// WithIdempotency keeps the responses to POST requests made with an
+// Idempotency-Key in store. Defaults to a store on db with
+// idempotency.DefaultWindow.
+func WithIdempotency(store *idempotency.Store) Option {
+	return func(o *options) { o.idempotency = store }
+}
+
+// WithBlobStore keeps workout attachments in store. Defaults to a
+// directory under os.TempDir(), which is only fit for development.
+func WithBlobStore(store blob.Store) Option {
+	return func(o *options) { o.blobs = store }
+}
+
+// WithAttachmentSigner sets the key attachment download URLs are signed
+// with. Every instance serving the API must use the same one; the default
+// is a random key.
+func WithAttachmentSigner(s *attachments.Signer) Option {
+	return func(o *options) { o.urlSigner = s }
+}
+
+// WithAttachmentLimits bounds the size of uploaded files and each user's
+// storage. Unset limits default to attachments.DefaultMaxSize and
+// attachments.DefaultQuota.
+func WithAttachmentLimits(l attachments.Limits) Option {
+	return func(o *options) { o.limits = l }
+}
+
 // RegisterRoutes wires all API routes onto the given Huma API.
 // db may be nil when called from the schema generator (routes are registered
 // for type introspection only; handlers are never invoked).
-func RegisterRoutes(api huma.API, db *gorm.DB) {
+func RegisterRoutes(api huma.API, db *gorm.DB, opts ...Option) {
+	o := options{notifier: notify.Nop{}, bus: events.NewMemoryBus(), retentionDays: jobs.DefaultRetentionDays}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.signer == nil {
+		o.signer = email.NewSigner("")
+	}
+	if o.idempotency == nil {
+		o.idempotency = idempotency.New(db, idempotency.DefaultWindow)
+	}
+	if o.blobs == nil {
+		o.blobs = blob.NewFS(filepath.Join(os.TempDir(), "workout-tracker-attachments"))
+	}
+	if o.urlSigner == nil {
+		o.urlSigner = attachments.NewSigner("")
+	}
+	if o.limits.MaxSize <= 0 {
+		o.limits.MaxSize = attachments.DefaultMaxSize
+	}
+	if o.limits.Quota <= 0 {
+		o.limits.Quota = attachments.DefaultQuota
+	}
+	// Attribute every write a request makes in the audit log. Middleware
+	// only applies to operations registered after it.
+	api.UseMiddleware(audit.Middleware)
+	// Retried POSTs carrying the same Idempotency-Key get the first
+	// response back instead of running again.
+	o.idempotency.Use(api)
+
 	huma.Register(api, huma.Operation{
 		OperationID: "health",
 		Method:      http.MethodGet,
@@ -31,7 +142,35 @@ func RegisterRoutes(api huma.API, db *gorm.DB) {
 		return &healthOutput{Body: healthBody{Status: "ok"}}, nil
 	})
 	uh := handlers.NewUserHandler(db)
-	wh := handlers.NewWorkoutHandler(db)
+	wh := handlers.NewWorkoutHandler(db, time.Duration(o.retentionDays)*24*time.Hour)
+	oh := handlers.NewOrganizationHandler(db)
+	sh := handlers.NewSocialHandler(db, o.notifier)
+	ch := handlers.NewChallengeHandler(db)
+	eh := handlers.NewEventHandler(db, o.bus)
+	whk := handlers.NewWebhookHandler(db)
+	jh := handlers.NewJobHandler(db)
+	ph := handlers.NewPlanHandler(db)
+	nh := handlers.NewNotificationHandler(db, o.signer)
+	rh := handlers.NewReportHandler(db)
+	ah := handlers.NewAuditHandler(db)
+	syh := handlers.NewSyncHandler(db)
+	srh := handlers.NewSearchHandler(db)
+	fh := handlers.NewFieldHandler(db)
+	ath := handlers.NewAttachmentHandler(db, o.blobs, o.urlSigner, o.limits)
 	uh.RegisterRoutes(api)
 	wh.RegisterRoutes(api)
+	oh.RegisterRoutes(api)
+	sh.RegisterRoutes(api)
+	ch.RegisterRoutes(api)
+	eh.RegisterRoutes(api)
+	whk.RegisterRoutes(api)
+	jh.RegisterRoutes(api)
+	ph.RegisterRoutes(api)
+	nh.RegisterRoutes(api)
+	rh.RegisterRoutes(api)
+	ah.RegisterRoutes(api)
+	syh.RegisterRoutes(api)
+	srh.RegisterRoutes(api)
+	fh.RegisterRoutes(api)
+	ath.RegisterRoutes(api)
 }
@@ -0,0 +1,87 @@
+package schemas
+
+import (
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/google/uuid"
+)
+
+// --- inputs ---
+
+// AttachmentUpload is the multipart form a file is uploaded in.
+type AttachmentUpload struct {
+	File huma.FormFile `form:"file" required:"true" doc:"The photo, video or other file to attach"`
+}
+
+type UploadAttachmentInput struct {
+	WorkoutID uuid.UUID `path:"workoutId" doc:"Workout ID"`
+	UserID    uuid.UUID `query:"userId" doc:"Uploading user ID (dev only; derived from auth token in production)"`
+	RawBody   huma.MultipartFormFiles[AttachmentUpload]
+}
+
+type ListAttachmentsInput struct {
+	WorkoutID uuid.UUID `path:"workoutId" doc:"Workout ID"`
+}
+
+type GetAttachmentInput struct {
+	AttachmentID uuid.UUID `path:"attachmentId" doc:"Attachment ID"`
+}
+
+type AttachmentUsageInput struct {
+	UserID uuid.UUID `query:"userId" doc:"Report usage of this user (dev only; derived from auth token in production)"`
+}
+
+// DownloadAttachmentInput is a signed download URL, as handed out in
+// AttachmentResponse.
+type DownloadAttachmentInput struct {
+	AttachmentID uuid.UUID `path:"attachmentId" doc:"Attachment ID"`
+	Variant      string    `query:"variant" enum:"original,thumbnail" default:"original" doc:"The file as uploaded, or a photo's thumbnail"`
+	Expires      int64     `query:"expires" required:"true" doc:"Unix time the URL expires at"`
+	Signature    string    `query:"signature" required:"true" doc:"Signature of the attachment, variant and expiry"`
+}
+
+// --- responses ---
+
+// AttachmentResponse describes an attachment. Its URLs are relative to
+// the API's origin, need no authentication and expire at ExpiresAt;
+// fetch the attachment again for fresh ones.
+type AttachmentResponse struct {
+	ID           uuid.UUID `json:"id"`
+	WorkoutID    uuid.UUID `json:"workout_id"`
+	Kind         string    `json:"kind" enum:"photo,video,file"`
+	Filename     string    `json:"filename"`
+	ContentType  string    `json:"content_type"`
+	Size         int64     `json:"size" doc:"Size of the file in bytes"`
+	Width        int       `json:"width,omitempty" doc:"Width of a photo in pixels"`
+	Height       int       `json:"height,omitempty" doc:"Height of a photo in pixels"`
+	URL          string    `json:"url" doc:"Signed download URL of the file"`
+	ThumbnailURL string    `json:"thumbnail_url,omitempty" doc:"Signed download URL of a photo's thumbnail"`
+	ExpiresAt    time.Time `json:"expires_at" doc:"When the URLs stop working"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+type AttachmentUsageResponse struct {
+	Used        int64 `json:"used" doc:"Bytes the caller's attachments take up"`
+	Quota       int64 `json:"quota" doc:"Bytes the caller may store"`
+	Attachments int64 `json:"attachments" doc:"Number of the caller's attachments"`
+}
+
+// --- outputs ---
+
+type UploadAttachmentOutput struct {
+	Status int
+	Body   *AttachmentResponse
+}
+
+type ListAttachmentsOutput struct {
+	Body []AttachmentResponse
+}
+
+type GetAttachmentOutput struct {
+	Body *AttachmentResponse
+}
+
+type AttachmentUsageOutput struct {
+	Body AttachmentUsageResponse
+}
@@ -0,0 +1,52 @@
+package schemas
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// --- inputs ---
+
+type ListAuditLogsInput struct {
+	ActorUserID  uuid.UUID `query:"actorUserId" doc:"Filter by the local user who made the change"`
+	Subject      string    `query:"subject" doc:"Filter by Zitadel subject"`
+	ResourceType string    `query:"resourceType" enum:"workout,user" doc:"Filter by resource type"`
+	ResourceID   uuid.UUID `query:"resourceId" doc:"Filter by resource ID"`
+	Action       string    `query:"action" enum:"create,update,delete" doc:"Filter by action"`
+	RequestID    string    `query:"requestId" doc:"Filter by request ID (the X-Request-ID response header)"`
+	From         time.Time `query:"from" doc:"Earliest entry (inclusive, RFC 3339)"`
+	To           time.Time `query:"to" doc:"Latest entry (exclusive, RFC 3339)"`
+	Cursor       string    `query:"cursor" doc:"Opaque cursor from a previous page's next_cursor"`
+	Limit        int       `query:"limit" minimum:"1" maximum:"100" default:"50" doc:"Page size, newest first"`
+}
+
+// --- responses ---
+
+type AuditLogResponse struct {
+	ID           uuid.UUID       `json:"id"`
+	CreatedAt    time.Time       `json:"created_at"`
+	ActorUserID  *uuid.UUID      `json:"actor_user_id,omitempty" doc:"Absent for background jobs"`
+	Subject      string          `json:"subject,omitempty"`
+	TokenType    string          `json:"token_type,omitempty"`
+	ClientID     string          `json:"client_id,omitempty"`
+	Action       string          `json:"action"`
+	ResourceType string          `json:"resource_type"`
+	ResourceID   *uuid.UUID      `json:"resource_id,omitempty" doc:"Absent for bulk statements"`
+	Changes      json.RawMessage `json:"changes" doc:"Changed fields as {field: {old, new}}; bulk statements record statement and rows_affected"`
+	IP           string          `json:"ip,omitempty"`
+	UserAgent    string          `json:"user_agent,omitempty"`
+	RequestID    string          `json:"request_id,omitempty"`
+}
+
+type AuditLogPage struct {
+	Items      []AuditLogResponse `json:"items"`
+	NextCursor string             `json:"next_cursor,omitempty" doc:"Pass as ?cursor to fetch the next page; absent on the last page"`
+}
+
+// --- outputs ---
+
+type ListAuditLogsOutput struct {
+	Body *AuditLogPage
+}
@@ -0,0 +1,53 @@
+package schemas
+
+import (
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/google/uuid"
+)
+
+// MaxBatchOperations is how many operations one batch request may carry.
+const MaxBatchOperations = 100
+
+const (
+	// BatchAtomic applies every operation of a batch or, if any fails, none.
+	BatchAtomic = "atomic"
+	// BatchBestEffort applies the operations that succeed and reports the
+	// others.
+	BatchBestEffort = "best_effort"
+)
+
+// --- inputs ---
+
+type BatchWorkoutsInput struct {
+	Body struct {
+		Mode       string                  `json:"mode,omitempty" enum:"atomic,best_effort" default:"atomic" doc:"atomic: all operations are applied or none are; best_effort: operations that fail are skipped"`
+		Operations []WorkoutBatchOperation `json:"operations" minItems:"1" maxItems:"100" doc:"Operations, applied in order"`
+	}
+}
+
+// WorkoutBatchOperation creates, updates or deletes one workout.
+type WorkoutBatchOperation struct {
+	Op        string         `json:"op" enum:"create,update,delete" doc:"What to do"`
+	WorkoutID uuid.UUID      `json:"workout_id,omitempty" doc:"Workout to update or delete"`
+	IfMatch   string         `json:"if_match,omitempty" doc:"Only update or delete the workout while its ETag still matches, as with the If-Match header"`
+	Workout   *NewWorkout    `json:"workout,omitempty" doc:"Workout to create"`
+	Patch     map[string]any `json:"patch,omitempty" doc:"JSON Merge Patch of the workout's fields to update; null clears a field"`
+}
+
+// --- outputs / response bodies ---
+
+// WorkoutBatchResult is the outcome of one operation of a batch.
+type WorkoutBatchResult struct {
+	Index   int              `json:"index" doc:"Position of the operation in the request"`
+	Status  int              `json:"status" doc:"HTTP status the operation would have got as a request of its own; 424 when an atomic batch was abandoned because of another operation"`
+	Workout *WorkoutResponse `json:"workout,omitempty" doc:"The workout as created or updated"`
+	Error   *huma.ErrorModel `json:"error,omitempty" doc:"Why the operation failed"`
+}
+
+type BatchWorkoutsOutput struct {
+	Body struct {
+		Applied int                  `json:"applied" doc:"Number of operations applied"`
+		Failed  int                  `json:"failed" doc:"Number of operations that failed or were abandoned"`
+		Results []WorkoutBatchResult `json:"results"`
+	}
+}
@@ -0,0 +1,97 @@
+package schemas
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// --- inputs ---
+
+type ListChallengesInput struct {
+	OrganizationID uuid.UUID `query:"organizationId" doc:"Only challenges of this organization"`
+	Status         string    `query:"status" enum:"upcoming,active,ended" doc:"Filter by lifecycle status"`
+}
+
+type CreateChallengeInput struct {
+	Body struct {
+		ID             uuid.UUID  `json:"id,omitempty" format:"uuid" doc:"Public ID for the new challenge, for clients that assign their own; generated when omitted"`
+		UserID         uuid.UUID  `json:"user_id,omitempty" doc:"Creator user ID (dev only; derived from auth token in production)"`
+		OrganizationID *uuid.UUID `json:"organization_id,omitempty" doc:"Organization running the challenge; omit for a site-wide challenge (admins only)"`
+		Name           string     `json:"name" minLength:"1" doc:"Challenge name"`
+		Description    string     `json:"description,omitempty" doc:"Optional description"`
+		Metric         string     `json:"metric" enum:"total_minutes,sessions,distance" doc:"What participants compete on; distance is the longest distance covered in a single workout"`
+		StartDate      string     `json:"start_date" format:"date" doc:"First day of the challenge, in timezone"`
+		EndDate        string     `json:"end_date" format:"date" doc:"Last day of the challenge (inclusive), in timezone"`
+		Timezone       string     `json:"timezone,omitempty" default:"UTC" doc:"IANA timezone the dates are interpreted in"`
+		LateLogHours   int        `json:"late_log_hours,omitempty" minimum:"0" maximum:"720" default:"48" doc:"Hours after the end during which late-logged workouts still count"`
+	}
+}
+
+type GetChallengeInput struct {
+	ChallengeID uuid.UUID `path:"challengeId" doc:"Challenge ID"`
+}
+
+type JoinChallengeInput struct {
+	ChallengeID uuid.UUID `path:"challengeId" doc:"Challenge ID"`
+	Body        struct {
+		UserID uuid.UUID `json:"user_id,omitempty" doc:"Joining user ID (dev only; derived from auth token in production)"`
+	}
+}
+
+type LeaveChallengeInput struct {
+	ChallengeID uuid.UUID `path:"challengeId" doc:"Challenge ID"`
+	UserID      uuid.UUID `query:"userId" doc:"Leaving user ID (dev only; derived from auth token in production)"`
+}
+
+// --- outputs / response bodies ---
+
+type ChallengeResponse struct {
+	ID             uuid.UUID  `json:"id"`
+	OrganizationID *uuid.UUID `json:"organization_id,omitempty"`
+	CreatedByID    uuid.UUID  `json:"created_by_id"`
+	Name           string     `json:"name"`
+	Description    string     `json:"description,omitempty"`
+	Metric         string     `json:"metric"`
+	Timezone       string     `json:"timezone"`
+	StartsAt       time.Time  `json:"starts_at"`
+	EndsAt         time.Time  `json:"ends_at"`
+	LateLogHours   int        `json:"late_log_hours"`
+	FreezesAt      time.Time  `json:"freezes_at" doc:"When results are frozen; workouts logged later no longer count"`
+	FinalizedAt    *time.Time `json:"finalized_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+type StandingEntry struct {
+	Rank   int       `json:"rank"`
+	UserID uuid.UUID `json:"user_id"`
+	Name   string    `json:"name"`
+	Value  int64     `json:"value"`
+}
+
+// ChallengeStandings is both the standings response body and the payload of
+// the standings stream's events.
+type ChallengeStandings struct {
+	ChallengeID uuid.UUID       `json:"challenge_id"`
+	Metric      string          `json:"metric"`
+	Final       bool            `json:"final" doc:"True once results are frozen"`
+	AsOf        time.Time       `json:"as_of"`
+	Entries     []StandingEntry `json:"entries"`
+}
+
+type GetChallengeOutput struct {
+	Body *ChallengeResponse
+}
+
+type CreateChallengeOutput struct {
+	Status int
+	Body   *ChallengeResponse
+}
+
+type ListChallengesOutput struct {
+	Body []ChallengeResponse
+}
+
+type ChallengeStandingsOutput struct {
+	Body *ChallengeStandings
+}
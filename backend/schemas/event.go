@@ -0,0 +1,33 @@
+package schemas
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// --- inputs ---
+
+type StreamEventsInput struct {
+	LastEventID int64     `header:"Last-Event-ID" doc:"Resume after this event ID (sent automatically by EventSource on reconnect)"`
+	After       int64     `query:"after" doc:"Resume after this event ID, for clients that cannot set Last-Event-ID"`
+	UserID      uuid.UUID `query:"userId" doc:"Viewer user ID (dev only; derived from auth token in production)"`
+}
+
+// --- outputs / response bodies ---
+
+// WorkoutEvent is the data of a workout change event. Workout holds the
+// workout as it was right after the change (for deletions, right before).
+type WorkoutEvent struct {
+	EventID    int64           `json:"event_id"`
+	WorkoutID  uuid.UUID       `json:"workout_id"`
+	Workout    WorkoutResponse `json:"workout"`
+	OccurredAt time.Time       `json:"occurred_at"`
+}
+
+// Distinct named types so the SSE stream can map each to its own event name.
+type (
+	WorkoutCreatedEvent WorkoutEvent
+	WorkoutUpdatedEvent WorkoutEvent
+	WorkoutDeletedEvent WorkoutEvent
+)
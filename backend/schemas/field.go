@@ -0,0 +1,89 @@
+package schemas
+
+import (
+	"time"
+
+	"workout-tracker/backend/patch"
+
+	"github.com/google/uuid"
+)
+
+// --- inputs ---
+
+type ListCustomFieldsInput struct {
+	UserID uuid.UUID `query:"userId" doc:"List fields of this user (dev only; derived from auth token in production)"`
+}
+
+type CreateCustomFieldInput struct {
+	Body struct {
+		ID      uuid.UUID `json:"id,omitempty" format:"uuid" doc:"Public ID for the new field, for clients that assign their own; generated when omitted"`
+		UserID  uuid.UUID `json:"user_id,omitempty" doc:"Owner user ID (dev only; derived from auth token in production)"`
+		Key     string    `json:"key" pattern:"^[a-z][a-z0-9_]*$" maxLength:"40" doc:"Key the field's values are stored under on workouts, e.g. sleep_hours"`
+		Name    string    `json:"name" minLength:"1" maxLength:"100" doc:"Display name"`
+		Type    string    `json:"type" enum:"number,text,enum,boolean" doc:"Type of the field's values"`
+		Options []string  `json:"options,omitempty" maxItems:"50" doc:"Values an enum field allows"`
+		Unit    string    `json:"unit,omitempty" maxLength:"20" doc:"Unit of a number field, e.g. h"`
+	}
+}
+
+type GetCustomFieldInput struct {
+	FieldID uuid.UUID `path:"fieldId" doc:"Custom field ID"`
+}
+
+// CustomFieldFields are the fields a client may change on a custom field:
+// the document a patch applies to. Its key and type are fixed, since
+// workouts hold values under them.
+type CustomFieldFields struct {
+	Name    string   `json:"name" minLength:"1" maxLength:"100" doc:"Display name"`
+	Options []string `json:"options" required:"false" maxItems:"50" doc:"Values an enum field allows; workouts keep values already recorded"`
+	Unit    string   `json:"unit" required:"false" maxLength:"20" doc:"Unit of a number field, e.g. h"`
+}
+
+// UpdateCustomFieldInput is a JSON Merge Patch, or a JSON Patch when sent
+// as application/json-patch+json, of the field's CustomFieldFields.
+type UpdateCustomFieldInput struct {
+	FieldID     uuid.UUID  `path:"fieldId" doc:"Custom field ID"`
+	ContentType string     `header:"Content-Type" doc:"application/merge-patch+json (or application/json) or application/json-patch+json"`
+	Body        patch.Body `contentType:"application/merge-patch+json"`
+}
+
+type ListTagsInput struct {
+	UserID uuid.UUID `query:"userId" doc:"List tags of this user (dev only; derived from auth token in production)"`
+}
+
+// --- responses ---
+
+type CustomFieldResponse struct {
+	ID        uuid.UUID `json:"id"`
+	Key       string    `json:"key"`
+	Name      string    `json:"name"`
+	Type      string    `json:"type"`
+	Options   []string  `json:"options,omitempty"`
+	Unit      string    `json:"unit,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type TagResponse struct {
+	Tag      string `json:"tag"`
+	Workouts int64  `json:"workouts" doc:"Number of the caller's workouts with the tag"`
+}
+
+// --- outputs ---
+
+type ListCustomFieldsOutput struct {
+	Body []CustomFieldResponse
+}
+
+type CreateCustomFieldOutput struct {
+	Status int
+	Body   *CustomFieldResponse
+}
+
+type GetCustomFieldOutput struct {
+	Body *CustomFieldResponse
+}
+
+type ListTagsOutput struct {
+	Body []TagResponse
+}
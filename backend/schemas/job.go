@@ -0,0 +1,63 @@
+package schemas
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// --- inputs ---
+
+type ListJobsInput struct {
+	Queue  string `query:"queue" doc:"Filter by queue"`
+	Kind   string `query:"kind" doc:"Filter by job kind"`
+	Status string `query:"status" enum:"pending,running,succeeded,dead" doc:"Filter by status; dead jobs form the dead-letter queue"`
+	Limit  int    `query:"limit" minimum:"1" maximum:"500" default:"100" doc:"Maximum number of jobs to return, newest first"`
+}
+
+type GetJobInput struct {
+	JobID uuid.UUID `path:"jobId" doc:"Job ID"`
+}
+
+// --- responses ---
+
+type JobResponse struct {
+	ID          uuid.UUID       `json:"id"`
+	Queue       string          `json:"queue"`
+	Kind        string          `json:"kind"`
+	Args        json.RawMessage `json:"args,omitempty"`
+	Status      string          `json:"status"`
+	RunAt       time.Time       `json:"run_at"`
+	Attempts    int             `json:"attempts"`
+	MaxAttempts int             `json:"max_attempts"`
+	LockedUntil *time.Time      `json:"locked_until,omitempty"`
+	LastError   string          `json:"last_error,omitempty"`
+	FinishedAt  *time.Time      `json:"finished_at,omitempty"`
+	UniqueKey   *string         `json:"unique_key,omitempty"`
+	CreatedAt   time.Time       `json:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+}
+
+// JobQueueStats counts a queue's jobs by status.
+type JobQueueStats struct {
+	Queue     string `json:"queue"`
+	Pending   int64  `json:"pending"`
+	Running   int64  `json:"running"`
+	Succeeded int64  `json:"succeeded"`
+	Dead      int64  `json:"dead"`
+}
+
+// --- outputs ---
+
+type ListJobsOutput struct {
+	Body []JobResponse
+}
+
+type GetJobOutput struct {
+	Body *JobResponse
+}
+
+type JobStatsOutput struct {
+	Body []JobQueueStats
+}
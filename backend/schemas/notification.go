@@ -0,0 +1,41 @@
+package schemas
+
+import "github.com/google/uuid"
+
+// --- requests ---
+
+type GetNotificationPreferencesInput struct {
+	UserID uuid.UUID `query:"userId" doc:"Preferences of this user (dev only; derived from auth token in production)"`
+}
+
+type UpdateNotificationPreferencesInput struct {
+	Body struct {
+		UserID uuid.UUID `json:"user_id,omitempty" doc:"User ID (dev only; derived from auth token in production)"`
+		// Email maps categories to whether they are emailed; categories left
+		// out are unchanged.
+		Email map[string]bool `json:"email" doc:"Email on/off by category: weekly_summary, achievements, invites, reminders, or all"`
+	}
+}
+
+type UnsubscribeInput struct {
+	Token string `query:"token" required:"true" doc:"Signed token from the unsubscribe link"`
+}
+
+// --- responses ---
+
+type NotificationPreferencesResponse struct {
+	Email map[string]bool `json:"email"`
+}
+
+type NotificationPreferencesOutput struct {
+	Body NotificationPreferencesResponse
+}
+
+type UnsubscribeResponse struct {
+	Category     string `json:"category"`
+	Unsubscribed bool   `json:"unsubscribed"`
+}
+
+type UnsubscribeOutput struct {
+	Body UnsubscribeResponse
+}
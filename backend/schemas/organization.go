@@ -0,0 +1,196 @@
+package schemas
+
+import (
+	"time"
+
+	"workout-tracker/backend/patch"
+
+	"github.com/google/uuid"
+)
+
+// --- inputs ---
+
+type ListOrganizationsInput struct {
+	UserID uuid.UUID `query:"userId" doc:"List organizations of this user (dev only; derived from auth token in production)"`
+}
+
+type CreateOrganizationInput struct {
+	Body struct {
+		ID     uuid.UUID `json:"id,omitempty" format:"uuid" doc:"Public ID for the new organization, for clients that assign their own; generated when omitted"`
+		UserID uuid.UUID `json:"user_id,omitempty" doc:"Owner user ID (dev only; derived from auth token in production)"`
+		Name   string    `json:"name" minLength:"1" doc:"Organization name"`
+	}
+}
+
+type GetOrganizationInput struct {
+	OrgID uuid.UUID `path:"orgId" doc:"Organization ID"`
+}
+
+type ListMembersInput struct {
+	OrgID uuid.UUID `path:"orgId" doc:"Organization ID"`
+}
+
+type AddMemberInput struct {
+	OrgID uuid.UUID `path:"orgId" doc:"Organization ID"`
+	Body  struct {
+		UserID uuid.UUID `json:"user_id" doc:"User to add"`
+		Role   string    `json:"role,omitempty" enum:"owner,coach,member" default:"member" doc:"Membership role"`
+	}
+}
+
+// MemberFields are the fields a client may change on a membership: the
+// document a patch applies to.
+type MemberFields struct {
+	Role       string `json:"role" enum:"owner,coach,member" doc:"Membership role (owners only)"`
+	ShareStats bool   `json:"share_stats" required:"false" doc:"Include this member in aggregates and leaderboards (member only)"`
+}
+
+// UpdateMemberInput is a JSON Merge Patch, or a JSON Patch when sent as
+// application/json-patch+json, of the membership's MemberFields.
+type UpdateMemberInput struct {
+	OrgID       uuid.UUID  `path:"orgId" doc:"Organization ID"`
+	UserID      uuid.UUID  `path:"userId" doc:"Member user ID"`
+	ContentType string     `header:"Content-Type" doc:"application/merge-patch+json (or application/json) or application/json-patch+json"`
+	Body        patch.Body `contentType:"application/merge-patch+json"`
+}
+
+type RemoveMemberInput struct {
+	OrgID  uuid.UUID `path:"orgId" doc:"Organization ID"`
+	UserID uuid.UUID `path:"userId" doc:"Member user ID"`
+}
+
+type ListTemplatesInput struct {
+	OrgID uuid.UUID `path:"orgId" doc:"Organization ID"`
+}
+
+type CreateTemplateInput struct {
+	OrgID uuid.UUID `path:"orgId" doc:"Organization ID"`
+	Body  struct {
+		ID              uuid.UUID `json:"id,omitempty" format:"uuid" doc:"Public ID for the new template, for clients that assign their own; generated when omitted"`
+		AuthorID        uuid.UUID `json:"author_id,omitempty" doc:"Author user ID (dev only; derived from auth token in production)"`
+		Name            string    `json:"name" minLength:"1" doc:"Template name"`
+		Description     string    `json:"description,omitempty" doc:"Optional description"`
+		DurationMinutes int       `json:"duration_minutes" minimum:"0" doc:"Planned duration in minutes"`
+	}
+}
+
+type DeleteTemplateInput struct {
+	OrgID      uuid.UUID `path:"orgId" doc:"Organization ID"`
+	TemplateID uuid.UUID `path:"templateId" doc:"Template ID"`
+}
+
+// PeriodParams bounds an aggregate query. Both ends default to the last 30 days.
+type PeriodParams struct {
+	From time.Time `query:"from" doc:"Start of the period (inclusive, RFC 3339)"`
+	To   time.Time `query:"to" doc:"End of the period (exclusive, RFC 3339)"`
+}
+
+type OrgStatsInput struct {
+	OrgID uuid.UUID `path:"orgId" doc:"Organization ID"`
+	PeriodParams
+}
+
+type LeaderboardInput struct {
+	OrgID uuid.UUID `path:"orgId" doc:"Organization ID"`
+	PeriodParams
+	Metric string `query:"metric" enum:"total_minutes,sessions,active_days" default:"total_minutes" doc:"Ranking metric"`
+	Limit  int    `query:"limit" minimum:"1" maximum:"100" default:"10" doc:"Maximum number of entries"`
+}
+
+// --- outputs / response bodies ---
+
+type OrganizationResponse struct {
+	ID        uuid.UUID `json:"id"`
+	Name      string    `json:"name"`
+	Role      string    `json:"role,omitempty" doc:"The caller's role, when known"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type MemberResponse struct {
+	UserID     uuid.UUID `json:"user_id"`
+	Name       string    `json:"name"`
+	Role       string    `json:"role"`
+	ShareStats bool      `json:"share_stats"`
+	JoinedAt   time.Time `json:"joined_at"`
+}
+
+type TemplateResponse struct {
+	ID              uuid.UUID  `json:"id"`
+	OrganizationID  *uuid.UUID `json:"organization_id,omitempty"`
+	AuthorID        uuid.UUID  `json:"author_id"`
+	Name            string     `json:"name"`
+	Description     string     `json:"description,omitempty"`
+	DurationMinutes int        `json:"duration_minutes"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+type TeamVolumeResponse struct {
+	From         time.Time `json:"from"`
+	To           time.Time `json:"to"`
+	Members      int64     `json:"members" doc:"Opted-in members who logged at least one workout"`
+	Sessions     int64     `json:"sessions"`
+	TotalMinutes int64     `json:"total_minutes"`
+}
+
+type AttendanceEntry struct {
+	UserID     uuid.UUID `json:"user_id"`
+	Name       string    `json:"name"`
+	Sessions   int64     `json:"sessions"`
+	ActiveDays int64     `json:"active_days"`
+}
+
+type LeaderboardEntry struct {
+	Rank   int       `json:"rank"`
+	UserID uuid.UUID `json:"user_id"`
+	Name   string    `json:"name"`
+	Value  int64     `json:"value"`
+}
+
+type GetOrganizationOutput struct {
+	Body *OrganizationResponse
+}
+
+type CreateOrganizationOutput struct {
+	Status int
+	Body   *OrganizationResponse
+}
+
+type ListOrganizationsOutput struct {
+	Body []OrganizationResponse
+}
+
+type MemberOutput struct {
+	Body *MemberResponse
+}
+
+type AddMemberOutput struct {
+	Status int
+	Body   *MemberResponse
+}
+
+type ListMembersOutput struct {
+	Body []MemberResponse
+}
+
+type CreateTemplateOutput struct {
+	Status int
+	Body   *TemplateResponse
+}
+
+type ListTemplatesOutput struct {
+	Body []TemplateResponse
+}
+
+type TeamVolumeOutput struct {
+	Body *TeamVolumeResponse
+}
+
+type AttendanceOutput struct {
+	Body []AttendanceEntry
+}
+
+type LeaderboardOutput struct {
+	Body []LeaderboardEntry
+}
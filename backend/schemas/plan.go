@@ -0,0 +1,184 @@
+package schemas
+
+import (
+	"time"
+
+	"workout-tracker/backend/patch"
+
+	"github.com/google/uuid"
+)
+
+// --- inputs ---
+
+type ListPlansInput struct {
+	UserID uuid.UUID `query:"userId" doc:"List plans of this user (dev only; derived from auth token in production)"`
+}
+
+type CreatePlanInput struct {
+	Body struct {
+		ID                  uuid.UUID `json:"id,omitempty" format:"uuid" doc:"Public ID for the new plan, for clients that assign their own; generated when omitted"`
+		UserID              uuid.UUID `json:"user_id,omitempty" doc:"Owner user ID (dev only; derived from auth token in production)"`
+		Name                string    `json:"name" minLength:"1" doc:"What the session is"`
+		Description         string    `json:"description,omitempty" doc:"Optional description"`
+		DurationMinutes     int       `json:"duration_minutes,omitempty" minimum:"0" doc:"Planned duration in minutes"`
+		StartsAt            time.Time `json:"starts_at" doc:"First (or only) session; DTSTART of the recurrence rule"`
+		Timezone            string    `json:"timezone,omitempty" default:"UTC" doc:"IANA time zone recurrences are expanded in"`
+		RRule               string    `json:"rrule,omitempty" example:"FREQ=WEEKLY;BYDAY=MO,WE,FR" doc:"RFC 5545 recurrence rule (FREQ DAILY, WEEKLY or MONTHLY; INTERVAL, COUNT, UNTIL, BYDAY, BYMONTHDAY); one-off when omitted"`
+		ReminderChannels    []string  `json:"reminder_channels,omitempty" enum:"email,webhook,push" doc:"Where reminders and missed-session reports go; none when empty"`
+		RemindMinutesBefore int       `json:"remind_minutes_before,omitempty" minimum:"0" maximum:"10080" default:"60" doc:"Reminder lead time"`
+		AutoReschedule      bool      `json:"auto_reschedule,omitempty" doc:"Move missed sessions to the same time the next day"`
+	}
+}
+
+type GetPlanInput struct {
+	PlanID uuid.UUID `path:"planId" doc:"Planned workout ID"`
+}
+
+// PlanFields are the fields a client sets on a plan: the document a patch
+// applies to.
+type PlanFields struct {
+	Name                string    `json:"name" minLength:"1" doc:"What the session is"`
+	Description         string    `json:"description" required:"false" doc:"Optional description"`
+	DurationMinutes     int       `json:"duration_minutes" required:"false" minimum:"0" doc:"Planned duration in minutes"`
+	StartsAt            time.Time `json:"starts_at" doc:"First (or only) session; DTSTART of the recurrence rule"`
+	Timezone            string    `json:"timezone" doc:"IANA time zone recurrences are expanded in"`
+	RRule               string    `json:"rrule" required:"false" doc:"RFC 5545 recurrence rule; the plan is one-off without one"`
+	ReminderChannels    []string  `json:"reminder_channels" required:"false" enum:"email,webhook,push" doc:"Where reminders and missed-session reports go; none when empty"`
+	RemindMinutesBefore int       `json:"remind_minutes_before" required:"false" minimum:"0" maximum:"10080" doc:"Reminder lead time"`
+	AutoReschedule      bool      `json:"auto_reschedule" required:"false" doc:"Move missed sessions to the same time the next day"`
+}
+
+// UpdatePlanInput is a JSON Merge Patch, or a JSON Patch when sent as
+// application/json-patch+json, of the plan's PlanFields.
+type UpdatePlanInput struct {
+	PlanID      uuid.UUID  `path:"planId" doc:"Planned workout ID"`
+	ContentType string     `header:"Content-Type" doc:"application/merge-patch+json (or application/json) or application/json-patch+json"`
+	Body        patch.Body `contentType:"application/merge-patch+json"`
+}
+
+type ListSessionsInput struct {
+	UserID uuid.UUID `query:"userId" doc:"List sessions of this user (dev only; derived from auth token in production)"`
+	From   time.Time `query:"from" doc:"Earliest scheduled time (defaults to now)"`
+	To     time.Time `query:"to" doc:"Latest scheduled time, exclusive (defaults to two weeks after from)"`
+	Status string    `query:"status" enum:"scheduled,done,missed,skipped" doc:"Filter by status"`
+}
+
+type GetSessionInput struct {
+	SessionID uuid.UUID `path:"sessionId" doc:"Planned session ID"`
+}
+
+type CompleteSessionInput struct {
+	SessionID uuid.UUID `path:"sessionId" doc:"Planned session ID"`
+	Body      struct {
+		WorkoutID uuid.UUID `json:"workout_id" doc:"The logged workout that fulfilled the session"`
+	}
+}
+
+type RescheduleSessionInput struct {
+	SessionID uuid.UUID `path:"sessionId" doc:"Planned session ID"`
+	Body      struct {
+		ScheduledAt time.Time `json:"scheduled_at" doc:"New time; must be in the future"`
+	}
+}
+
+type MissedSessionsInput struct {
+	UserID uuid.UUID `query:"userId" doc:"Report on this user (dev only; derived from auth token in production)"`
+	From   time.Time `query:"from" doc:"Start of the period (defaults to 30 days ago)"`
+	To     time.Time `query:"to" doc:"End of the period, exclusive (defaults to now)"`
+}
+
+type CreatePushSubscriptionInput struct {
+	Body struct {
+		UserID   uuid.UUID `json:"user_id,omitempty" doc:"Owner user ID (dev only; derived from auth token in production)"`
+		Endpoint string    `json:"endpoint" format:"uri" doc:"PushSubscription.endpoint"`
+		Keys     struct {
+			P256dh string `json:"p256dh" minLength:"1" doc:"PushSubscription key p256dh (base64url)"`
+			Auth   string `json:"auth" minLength:"1" doc:"PushSubscription key auth (base64url)"`
+		} `json:"keys"`
+	}
+}
+
+type DeletePushSubscriptionInput struct {
+	SubscriptionID uuid.UUID `path:"subscriptionId" doc:"Push subscription ID"`
+}
+
+// --- responses ---
+
+type PlannedWorkoutResponse struct {
+	ID                  uuid.UUID `json:"id"`
+	UserID              uuid.UUID `json:"user_id"`
+	Name                string    `json:"name"`
+	Description         string    `json:"description,omitempty"`
+	DurationMinutes     int       `json:"duration_minutes"`
+	StartsAt            time.Time `json:"starts_at"`
+	Timezone            string    `json:"timezone"`
+	RRule               string    `json:"rrule,omitempty"`
+	ReminderChannels    []string  `json:"reminder_channels"`
+	RemindMinutesBefore int       `json:"remind_minutes_before"`
+	AutoReschedule      bool      `json:"auto_reschedule"`
+	CreatedAt           time.Time `json:"created_at"`
+	UpdatedAt           time.Time `json:"updated_at"`
+}
+
+type PlannedSessionResponse struct {
+	ID          uuid.UUID  `json:"id"`
+	PlanID      uuid.UUID  `json:"plan_id"`
+	Name        string     `json:"name,omitempty"`
+	OccursAt    time.Time  `json:"occurs_at" doc:"The slot the plan generated"`
+	ScheduledAt time.Time  `json:"scheduled_at" doc:"When the session is due; differs from occurs_at once rescheduled"`
+	Status      string     `json:"status"`
+	WorkoutID   *uuid.UUID `json:"workout_id,omitempty"`
+	RemindedAt  *time.Time `json:"reminded_at,omitempty"`
+	MissedAt    *time.Time `json:"missed_at,omitempty"`
+	Reschedules int        `json:"reschedules"`
+}
+
+type MissedSessionsReport struct {
+	From      time.Time `json:"from"`
+	To        time.Time `json:"to"`
+	Planned   int64     `json:"planned" doc:"Sessions scheduled in the period"`
+	Done      int64     `json:"done"`
+	Skipped   int64     `json:"skipped"`
+	Missed    int64     `json:"missed" doc:"Sessions that were missed, including ones since rescheduled"`
+	Adherence float64   `json:"adherence" doc:"Share of past sessions that were done, 0-1"`
+	// Sessions lists the missed sessions, most recent first.
+	Sessions []PlannedSessionResponse `json:"sessions"`
+}
+
+type PushSubscriptionResponse struct {
+	ID        uuid.UUID `json:"id"`
+	Endpoint  string    `json:"endpoint"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// --- outputs ---
+
+type ListPlansOutput struct {
+	Body []PlannedWorkoutResponse
+}
+
+type CreatePlanOutput struct {
+	Status int
+	Body   *PlannedWorkoutResponse
+}
+
+type GetPlanOutput struct {
+	Body *PlannedWorkoutResponse
+}
+
+type ListSessionsOutput struct {
+	Body []PlannedSessionResponse
+}
+
+type GetSessionOutput struct {
+	Body *PlannedSessionResponse
+}
+
+type MissedSessionsOutput struct {
+	Body *MissedSessionsReport
+}
+
+type CreatePushSubscriptionOutput struct {
+	Status int
+	Body   *PushSubscriptionResponse
+}
@@ -0,0 +1,152 @@
+package schemas
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// --- inputs ---
+
+type ListReportsInput struct {
+	UserID uuid.UUID `query:"userId" doc:"Reports of this user (dev only; derived from auth token in production)"`
+	Period string    `query:"period" enum:"weekly,monthly" doc:"Only reports for this period"`
+	Limit  int       `query:"limit" minimum:"1" maximum:"100" default:"20" doc:"Maximum number of reports, newest first"`
+}
+
+type GetReportInput struct {
+	UserID uuid.UUID `query:"userId" doc:"Report of this user (dev only; derived from auth token in production)"`
+	Period string    `path:"period" enum:"weekly,monthly" doc:"Report period"`
+	Start  string    `query:"start" format:"date" doc:"Any day in the period; defaults to the last completed one. Periods in progress are reported so far but not stored."`
+}
+
+// --- responses ---
+
+// ReportTotals are the training volume of one period.
+type ReportTotals struct {
+	Sessions            int     `json:"sessions"`
+	TotalMinutes        int     `json:"total_minutes"`
+	TotalDistanceMeters int     `json:"total_distance_meters"`
+	ActiveDays          int     `json:"active_days"`
+	WorkingSets         int     `json:"working_sets" doc:"Sets logged, not counting warm-ups"`
+	TotalVolumeKg       float64 `json:"total_volume_kg" doc:"Reps times weight over all working sets"`
+}
+
+// ReportComparison is the change against the previous period, in absolute
+// numbers and (when the previous period wasn't empty) percent.
+type ReportComparison struct {
+	Previous            ReportTotals `json:"previous"`
+	SessionsDelta       int          `json:"sessions_delta"`
+	TotalMinutesDelta   int          `json:"total_minutes_delta"`
+	TotalDistanceDelta  int          `json:"total_distance_meters_delta"`
+	TotalVolumeDelta    float64      `json:"total_volume_kg_delta"`
+	TotalMinutesPercent *float64     `json:"total_minutes_percent,omitempty"`
+}
+
+type ReportRecord struct {
+	WorkoutID   uuid.UUID `json:"workout_id"`
+	WorkoutName string    `json:"workout_name"`
+	PerformedAt time.Time `json:"performed_at"`
+	Metric      string    `json:"metric" enum:"duration,distance,weight"`
+	Exercise    string    `json:"exercise,omitempty" doc:"The exercise a weight record was set in"`
+	Value       float64   `json:"value" doc:"Minutes, meters or kilograms"`
+	Previous    float64   `json:"previous" doc:"Best before this workout"`
+}
+
+// ReportGoals is progress on the user's planned sessions in the period.
+type ReportGoals struct {
+	Planned   int     `json:"planned"`
+	Completed int     `json:"completed"`
+	Skipped   int     `json:"skipped"`
+	Missed    int     `json:"missed"`
+	Progress  float64 `json:"progress" doc:"Share of planned sessions completed, 0-1"`
+}
+
+type ReportWorkout struct {
+	ID              uuid.UUID      `json:"id"`
+	Name            string         `json:"name"`
+	PerformedAt     time.Time      `json:"performed_at"`
+	DurationMinutes int            `json:"duration_minutes"`
+	DistanceMeters  int            `json:"distance_meters"`
+	WorkingSets     int            `json:"working_sets,omitempty"`
+	VolumeKg        float64        `json:"volume_kg,omitempty"`
+	Tags            []string       `json:"tags,omitempty"`
+	CustomFields    map[string]any `json:"custom_fields,omitempty"`
+}
+
+// ReportTag is the training volume of the period's workouts with one tag.
+type ReportTag struct {
+	Tag                 string  `json:"tag"`
+	Sessions            int     `json:"sessions"`
+	TotalMinutes        int     `json:"total_minutes"`
+	TotalDistanceMeters int     `json:"total_distance_meters"`
+	TotalVolumeKg       float64 `json:"total_volume_kg"`
+}
+
+// ReportField summarizes the values of one custom field recorded in the
+// period: the spread of a number field, how often a boolean one was set,
+// and how often each option of an enum one was picked.
+type ReportField struct {
+	Key       string             `json:"key"`
+	Name      string             `json:"name"`
+	Type      string             `json:"type" enum:"number,text,enum,boolean"`
+	Unit      string             `json:"unit,omitempty"`
+	Count     int                `json:"count" doc:"Workouts the field was recorded on"`
+	Sum       *float64           `json:"sum,omitempty"`
+	Average   *float64           `json:"average,omitempty"`
+	Min       *float64           `json:"min,omitempty"`
+	Max       *float64           `json:"max,omitempty"`
+	TrueCount *int               `json:"true_count,omitempty"`
+	Values    []ReportFieldValue `json:"values,omitempty"`
+}
+
+type ReportFieldValue struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// ReportData is the content of a report, stored as generated.
+type ReportData struct {
+	Period          string           `json:"period" enum:"weekly,monthly"`
+	Start           time.Time        `json:"start"`
+	End             time.Time        `json:"end" doc:"Exclusive"`
+	Totals          ReportTotals     `json:"totals"`
+	Comparison      ReportComparison `json:"comparison"`
+	PersonalRecords []ReportRecord   `json:"personal_records"`
+	Goals           ReportGoals      `json:"goals"`
+	Workouts        []ReportWorkout  `json:"workouts"`
+	Tags            []ReportTag      `json:"tags" doc:"Volume by tag, most sessions first"`
+	CustomFields    []ReportField    `json:"custom_fields" doc:"Custom fields recorded in the period"`
+}
+
+type ReportResponse struct {
+	ID          *uuid.UUID `json:"id,omitempty" doc:"Unset for periods still in progress, which are not stored"`
+	UserID      uuid.UUID  `json:"user_id"`
+	GeneratedAt time.Time  `json:"generated_at"`
+	Partial     bool       `json:"partial" doc:"The period has not ended yet"`
+	ReportData
+}
+
+type ReportSummary struct {
+	ID          uuid.UUID `json:"id"`
+	Period      string    `json:"period"`
+	Start       time.Time `json:"start"`
+	End         time.Time `json:"end"`
+	GeneratedAt time.Time `json:"generated_at"`
+}
+
+// --- outputs ---
+
+type ListReportsOutput struct {
+	Body []ReportSummary
+}
+
+type GetReportOutput struct {
+	Body *ReportResponse
+}
+
+type ReportDocumentOutput struct {
+	ContentType        string `header:"Content-Type"`
+	ContentDisposition string `header:"Content-Disposition"`
+	Body               []byte
+}
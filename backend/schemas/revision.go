@@ -0,0 +1,55 @@
+package schemas
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// --- inputs ---
+
+type ListRevisionsInput struct {
+	WorkoutID uuid.UUID `path:"workoutId" doc:"Workout ID"`
+}
+
+type DiffRevisionsInput struct {
+	WorkoutID uuid.UUID `path:"workoutId" doc:"Workout ID"`
+	Revision  int       `path:"revision" minimum:"1" doc:"Revision number"`
+	Against   int       `query:"against" minimum:"0" doc:"Revision to compare with (defaults to the one before)"`
+}
+
+type RestoreRevisionInput struct {
+	WorkoutID uuid.UUID `path:"workoutId" doc:"Workout ID"`
+	Revision  int       `path:"revision" minimum:"1" doc:"Revision to restore"`
+}
+
+// --- responses ---
+
+type WorkoutRevisionResponse struct {
+	Revision     int             `json:"revision"`
+	AuthorID     *uuid.UUID      `json:"author_id,omitempty" doc:"User who made the change"`
+	RestoredFrom *int            `json:"restored_from,omitempty" doc:"Revision this one restored"`
+	CreatedAt    time.Time       `json:"created_at"`
+	Workout      WorkoutResponse `json:"workout" doc:"The workout as this revision left it"`
+}
+
+type FieldChange struct {
+	Old any `json:"old"`
+	New any `json:"new"`
+}
+
+type WorkoutRevisionDiff struct {
+	From    int                    `json:"from" doc:"Revision compared against; 0 when revision 1 is compared with nothing"`
+	To      int                    `json:"to"`
+	Changes map[string]FieldChange `json:"changes" doc:"Fields that differ, keyed by field name"`
+}
+
+// --- outputs ---
+
+type ListRevisionsOutput struct {
+	Body []WorkoutRevisionResponse
+}
+
+type DiffRevisionsOutput struct {
+	Body *WorkoutRevisionDiff
+}
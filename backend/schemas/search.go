@@ -0,0 +1,50 @@
+package schemas
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// --- inputs ---
+
+type SearchInput struct {
+	Q      string    `query:"q" minLength:"1" maxLength:"200" doc:"Words to search for. Supports \"quoted phrases\", OR and -excluded words unless prefix is set"`
+	Prefix bool      `query:"prefix" doc:"Match the last word as a prefix, for typeahead as the user types"`
+	UserID uuid.UUID `query:"userId" doc:"Search as this user (dev only; derived from auth token in production). Without one, dev mode searches everything"`
+	Cursor string    `query:"cursor" doc:"Opaque cursor from a previous page's next_cursor"`
+	Limit  int       `query:"limit" minimum:"1" maximum:"50" default:"20" doc:"Page size, best matches first"`
+}
+
+// --- responses ---
+
+// HighlightFragment is a run of text from a search result; Match marks the
+// runs that matched the query.
+type HighlightFragment struct {
+	Text  string `json:"text"`
+	Match bool   `json:"match,omitempty"`
+}
+
+type SearchResult struct {
+	Kind      string    `json:"kind" enum:"workout,exercise" doc:"What matched"`
+	ID        uuid.UUID `json:"id" doc:"ID of the record that matched"`
+	WorkoutID uuid.UUID `json:"workout_id" doc:"Workout the match belongs to; for workouts, the workout itself"`
+	Title     string    `json:"title"`
+	// Highlights are returned as fragments rather than marked-up text so
+	// clients never render user content as HTML.
+	TitleHighlight []HighlightFragment `json:"title_highlight" doc:"Title with the matching words marked"`
+	Snippet        []HighlightFragment `json:"snippet" doc:"Excerpts of the record's longer text around the matching words; empty when it has none"`
+	PerformedAt    time.Time           `json:"performed_at"`
+	Rank           float64             `json:"rank" doc:"Relevance; higher is better. Only comparable within one search"`
+}
+
+type SearchPage struct {
+	Items      []SearchResult `json:"items"`
+	NextCursor string         `json:"next_cursor,omitempty" doc:"Pass as ?cursor to fetch the next page; absent on the last page"`
+}
+
+// --- outputs ---
+
+type SearchOutput struct {
+	Body *SearchPage
+}
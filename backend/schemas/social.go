@@ -0,0 +1,108 @@
+package schemas
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// --- inputs ---
+
+type FollowInput struct {
+	UserID uuid.UUID `path:"userId" doc:"User to follow"`
+	Body   struct {
+		FollowerID uuid.UUID `json:"follower_id,omitempty" doc:"Follower user ID (dev only; derived from auth token in production)"`
+	}
+}
+
+type UnfollowInput struct {
+	UserID     uuid.UUID `path:"userId" doc:"User to unfollow"`
+	FollowerID uuid.UUID `query:"followerId" doc:"Follower user ID (dev only; derived from auth token in production)"`
+}
+
+type ListFollowsInput struct {
+	UserID uuid.UUID `path:"userId" doc:"User ID"`
+}
+
+type FeedInput struct {
+	UserID uuid.UUID `query:"userId" doc:"Viewer user ID (dev only; derived from auth token in production)"`
+	Cursor string    `query:"cursor" doc:"Opaque cursor from a previous page's next_cursor"`
+	Limit  int       `query:"limit" minimum:"1" maximum:"100" default:"20" doc:"Page size"`
+}
+
+type LikeWorkoutInput struct {
+	WorkoutID uuid.UUID `path:"workoutId" doc:"Workout ID"`
+	Body      struct {
+		UserID uuid.UUID `json:"user_id,omitempty" doc:"Liking user ID (dev only; derived from auth token in production)"`
+	}
+}
+
+type UnlikeWorkoutInput struct {
+	WorkoutID uuid.UUID `path:"workoutId" doc:"Workout ID"`
+	UserID    uuid.UUID `query:"userId" doc:"Liking user ID (dev only; derived from auth token in production)"`
+}
+
+type ListCommentsInput struct {
+	WorkoutID uuid.UUID `path:"workoutId" doc:"Workout ID"`
+}
+
+type CreateCommentInput struct {
+	WorkoutID uuid.UUID `path:"workoutId" doc:"Workout ID"`
+	Body      struct {
+		ID     uuid.UUID `json:"id,omitempty" format:"uuid" doc:"Public ID for the new comment, for clients that assign their own; generated when omitted"`
+		UserID uuid.UUID `json:"user_id,omitempty" doc:"Author user ID (dev only; derived from auth token in production)"`
+		Body   string    `json:"body" minLength:"1" maxLength:"2000" doc:"Comment text"`
+	}
+}
+
+type DeleteCommentInput struct {
+	WorkoutID uuid.UUID `path:"workoutId" doc:"Workout ID"`
+	CommentID uuid.UUID `path:"commentId" doc:"Comment ID"`
+}
+
+// --- outputs / response bodies ---
+
+type FollowResponse struct {
+	UserID uuid.UUID `json:"user_id"`
+	Name   string    `json:"name"`
+	Since  time.Time `json:"since"`
+}
+
+type FeedItem struct {
+	WorkoutResponse
+	AuthorName   string `json:"author_name"`
+	LikeCount    int64  `json:"like_count"`
+	CommentCount int64  `json:"comment_count"`
+	LikedByMe    bool   `json:"liked_by_me"`
+}
+
+type FeedPage struct {
+	Items      []FeedItem `json:"items"`
+	NextCursor string     `json:"next_cursor,omitempty" doc:"Pass as ?cursor to fetch the next page; absent on the last page"`
+}
+
+type CommentResponse struct {
+	ID         uuid.UUID `json:"id"`
+	WorkoutID  uuid.UUID `json:"workout_id"`
+	UserID     uuid.UUID `json:"user_id"`
+	AuthorName string    `json:"author_name"`
+	Body       string    `json:"body"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type ListFollowsOutput struct {
+	Body []FollowResponse
+}
+
+type FeedOutput struct {
+	Body *FeedPage
+}
+
+type CreateCommentOutput struct {
+	Status int
+	Body   *CommentResponse
+}
+
+type ListCommentsOutput struct {
+	Body []CommentResponse
+}
@@ -0,0 +1,78 @@
+package schemas
+
+import (
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/google/uuid"
+)
+
+// Outcomes of a change pushed by a syncing client.
+const (
+	// SyncApplied: the change was applied as sent.
+	SyncApplied = "applied"
+	// SyncConflict: some or all of the change lost to changes made on the
+	// server since the client's base version; see the conflicts.
+	SyncConflict = "conflict"
+	// SyncRejected: the change was not applied; see the error.
+	SyncRejected = "rejected"
+)
+
+// --- inputs ---
+
+type SyncInput struct {
+	Body struct {
+		UserID  uuid.UUID    `json:"user_id,omitempty" doc:"User to sync (dev only; derived from auth token in production)"`
+		Cursor  string       `json:"cursor,omitempty" doc:"next_cursor from the previous sync; omit to fetch every workout"`
+		Limit   int          `json:"limit,omitempty" minimum:"1" maximum:"500" default:"100" doc:"Most changed workouts to return"`
+		Changes []SyncChange `json:"changes,omitempty" maxItems:"100" doc:"Changes made on the client since its last sync, applied before changes are read"`
+	}
+}
+
+// SyncChange is a change a client made, possibly offline, to one workout.
+// Workouts the client created carry the ID it gave them and are created by
+// the first sync that sends them.
+type SyncChange struct {
+	ID          uuid.UUID      `json:"id" format:"uuid" doc:"ID of the workout; a client creating one picks a fresh UUID for it, ideally v7"`
+	BaseVersion int64          `json:"base_version,omitempty" doc:"Version of the workout the change was made to; 0 for a workout created on the client"`
+	Deleted     bool           `json:"deleted,omitempty" doc:"The client deleted the workout"`
+	Fields      map[string]any `json:"fields,omitempty" doc:"Fields the client changed, as a JSON Merge Patch: null clears a field. A new workout needs at least a name; performed_at defaults to now."`
+}
+
+// --- outputs / response bodies ---
+
+// SyncedWorkout is a workout's state as of a sync: its fields, or a
+// tombstone when it has been deleted.
+type SyncedWorkout struct {
+	ID        uuid.UUID        `json:"id"`
+	Version   int64            `json:"version"`
+	Deleted   bool             `json:"deleted" doc:"Tombstone: the workout was deleted and the client should drop it"`
+	DeletedAt *time.Time       `json:"deleted_at,omitempty"`
+	Workout   *WorkoutResponse `json:"workout,omitempty" doc:"The workout; absent on tombstones"`
+}
+
+// SyncFieldConflict is a field both the client and the server changed.
+// The server's value is kept.
+type SyncFieldConflict struct {
+	Field       string `json:"field"`
+	ClientValue any    `json:"client_value"`
+	ServerValue any    `json:"server_value"`
+}
+
+// SyncChangeResult is the outcome of one pushed change.
+type SyncChangeResult struct {
+	Index     int                 `json:"index" doc:"Position of the change in the request"`
+	Status    string              `json:"status" enum:"applied,conflict,rejected"`
+	Conflicts []SyncFieldConflict `json:"conflicts,omitempty" doc:"Fields where the server's value was kept over the client's"`
+	Workout   *SyncedWorkout      `json:"workout,omitempty" doc:"The workout as the server now has it"`
+	Error     *huma.ErrorModel    `json:"error,omitempty" doc:"Why the change was rejected"`
+}
+
+type SyncOutput struct {
+	Body struct {
+		Results    []SyncChangeResult `json:"results" doc:"Outcome of each pushed change, in order"`
+		Changes    []SyncedWorkout    `json:"changes" doc:"Workouts changed or deleted since the cursor, oldest change first"`
+		NextCursor string             `json:"next_cursor" doc:"Pass as cursor on the next sync"`
+		HasMore    bool               `json:"has_more" doc:"More changes are waiting; sync again straight away"`
+	}
+}
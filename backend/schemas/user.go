@@ -1,6 +1,13 @@
 package schemas
 
-import "time"
+import (
+	"time"
+
+	"workout-tracker/backend/patch"
+
+	"github.com/danielgtaylor/huma/v2/conditional"
+	"github.com/google/uuid"
+)
 
 // --- inputs ---
 
@@ -10,27 +17,58 @@ type ListUsersInput struct {
 
 type CreateUserInput struct {
 	Body struct {
-		Email    string `json:"email" format:"email" doc:"User email address"`
-		Name     string `json:"name" minLength:"1" doc:"Display name"`
-		Password string `json:"password" minLength:"8" doc:"Password (min 8 characters)"`
+		ID       uuid.UUID `json:"id,omitempty" format:"uuid" doc:"Public ID for the new user, for clients that assign their own; generated when omitted"`
+		Email    string    `json:"email" format:"email" doc:"User email address"`
+		Name     string    `json:"name" minLength:"1" doc:"Display name"`
+		Password string    `json:"password" minLength:"8" doc:"Password (min 8 characters)"`
 	}
 }
 
 type GetUserInput struct {
-	UserID int64 `path:"userId" doc:"User ID"`
+	UserID uuid.UUID `path:"userId" doc:"User ID"`
+	conditional.Params
+}
+
+// UserFields are the fields a client sets on a user: the body of a full
+// replace, and the document a patch applies to.
+type UserFields struct {
+	Email string `json:"email" format:"email" doc:"User email address"`
+	Name  string `json:"name" minLength:"1" doc:"Display name"`
+}
+
+// UpdateUserInput is a JSON Merge Patch, or a JSON Patch when sent as
+// application/json-patch+json, of the user's UserFields.
+type UpdateUserInput struct {
+	UserID uuid.UUID `path:"userId" doc:"User ID"`
+	conditional.Params
+	ContentType string     `header:"Content-Type" doc:"application/merge-patch+json (or application/json) or application/json-patch+json"`
+	Body        patch.Body `contentType:"application/merge-patch+json"`
+}
+
+type ReplaceUserInput struct {
+	UserID uuid.UUID `path:"userId" doc:"User ID"`
+	conditional.Params
+	Body UserFields
 }
 
 // --- outputs / response bodies ---
 
 type UserResponse struct {
-	ID        int64     `json:"id"`
+	ID        uuid.UUID `json:"id"`
 	Email     string    `json:"email"`
 	Name      string    `json:"name"`
+	Version   int64     `json:"version" doc:"Incremented on every change; the ETag carries it"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
 
 type GetUserOutput struct {
+	ETag string `header:"ETag"`
+	Body *UserResponse
+}
+
+type UpdateUserOutput struct {
+	ETag string `header:"ETag"`
 	Body *UserResponse
 }
 
@@ -0,0 +1,118 @@
+package schemas
+
+import (
+	"time"
+
+	"workout-tracker/backend/patch"
+
+	"github.com/google/uuid"
+)
+
+// --- inputs ---
+
+type ListWebhooksInput struct {
+	UserID uuid.UUID `query:"userId" doc:"List webhooks of this user (dev only; derived from auth token in production)"`
+}
+
+type CreateWebhookInput struct {
+	Body struct {
+		ID         uuid.UUID `json:"id,omitempty" format:"uuid" doc:"Public ID for the new webhook, for clients that assign their own; generated when omitted"`
+		UserID     uuid.UUID `json:"user_id,omitempty" doc:"Owner user ID (dev only; derived from auth token in production)"`
+		URL        string    `json:"url" format:"uri" doc:"Endpoint deliveries are POSTed to"`
+		EventTypes []string  `json:"event_types,omitempty" enum:"workout.created,workout.updated,workout.deleted,user.created,user.updated,user.deleted,planned_session.reminder,planned_session.missed" doc:"Event types to deliver; all when empty"`
+		Secret     string    `json:"secret,omitempty" minLength:"16" doc:"Signing secret; generated when omitted"`
+		SiteWide   bool      `json:"site_wide,omitempty" doc:"Receive every user's events (admins only)"`
+	}
+}
+
+type GetWebhookInput struct {
+	WebhookID uuid.UUID `path:"webhookId" doc:"Webhook ID"`
+}
+
+// WebhookFields are the fields a client may change on a webhook: the
+// document a patch applies to.
+type WebhookFields struct {
+	URL        string   `json:"url" format:"uri" doc:"Endpoint deliveries are POSTed to"`
+	EventTypes []string `json:"event_types" required:"false" enum:"workout.created,workout.updated,workout.deleted,user.created,user.updated,user.deleted,planned_session.reminder,planned_session.missed" doc:"Event types to deliver; all when empty"`
+	Active     bool     `json:"active" required:"false" doc:"Whether deliveries are made; false pauses them"`
+}
+
+// UpdateWebhookInput is a JSON Merge Patch, or a JSON Patch when sent as
+// application/json-patch+json, of the webhook's WebhookFields.
+type UpdateWebhookInput struct {
+	WebhookID   uuid.UUID  `path:"webhookId" doc:"Webhook ID"`
+	ContentType string     `header:"Content-Type" doc:"application/merge-patch+json (or application/json) or application/json-patch+json"`
+	Body        patch.Body `contentType:"application/merge-patch+json"`
+}
+
+type ListDeliveriesInput struct {
+	WebhookID uuid.UUID `path:"webhookId" doc:"Webhook ID"`
+	Status    string    `query:"status" enum:"pending,succeeded,failed" doc:"Filter by delivery status"`
+}
+
+type GetDeliveryInput struct {
+	WebhookID  uuid.UUID `path:"webhookId" doc:"Webhook ID"`
+	DeliveryID uuid.UUID `path:"deliveryId" doc:"Delivery ID"`
+}
+
+// --- responses ---
+
+type WebhookResponse struct {
+	ID         uuid.UUID  `json:"id"`
+	UserID     *uuid.UUID `json:"user_id,omitempty"`
+	URL        string     `json:"url"`
+	EventTypes []string   `json:"event_types"`
+	// Secret is only returned when the webhook is created.
+	Secret    string    `json:"secret,omitempty"`
+	Active    bool      `json:"active"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type WebhookAttemptResponse struct {
+	ID         uuid.UUID `json:"id"`
+	StatusCode int       `json:"status_code,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	DurationMS int64     `json:"duration_ms"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type WebhookDeliveryResponse struct {
+	ID            uuid.UUID                `json:"id"`
+	WebhookID     uuid.UUID                `json:"webhook_id"`
+	EventID       int64                    `json:"event_id"`
+	Status        string                   `json:"status"`
+	Attempts      int                      `json:"attempts"`
+	NextAttemptAt *time.Time               `json:"next_attempt_at,omitempty"`
+	DeliveredAt   *time.Time               `json:"delivered_at,omitempty"`
+	CreatedAt     time.Time                `json:"created_at"`
+	History       []WebhookAttemptResponse `json:"history,omitempty" doc:"Every attempt, oldest first (single delivery only)"`
+}
+
+// --- outputs ---
+
+type ListWebhooksOutput struct {
+	Body []WebhookResponse
+}
+
+type CreateWebhookOutput struct {
+	Status int
+	Body   *WebhookResponse
+}
+
+type GetWebhookOutput struct {
+	Body *WebhookResponse
+}
+
+type ListDeliveriesOutput struct {
+	Body []WebhookDeliveryResponse
+}
+
+type GetDeliveryOutput struct {
+	Body *WebhookDeliveryResponse
+}
+
+type RedeliverOutput struct {
+	Status int
+	Body   *WebhookDeliveryResponse
+}
@@ -1,64 +1,157 @@
 package schemas
 
-import "time"
+import (
+	"time"
+
+	"workout-tracker/backend/patch"
+	"workout-tracker/backend/training"
+
+	"github.com/danielgtaylor/huma/v2/conditional"
+	"github.com/google/uuid"
+)
 
 // --- inputs ---
 
 type ListWorkoutsInput struct {
-	UserID int64 `query:"userId" doc:"Filter workouts by user ID"`
+	UserID uuid.UUID `query:"userId" doc:"Filter workouts by user ID"`
+	Tags   []string  `query:"tag" doc:"Only workouts with all of these tags, comma-separated"`
+	Fields []string  `query:"field,explode" doc:"Only workouts whose custom field matches key:op:value, where op is eq or ne, or for number fields lt, lte, gt or gte; e.g. sleep_hours:gte:7. Repeat to match several."`
 }
 
 type GetWorkoutInput struct {
-	WorkoutID int64 `path:"workoutId" doc:"Workout ID"`
+	WorkoutID uuid.UUID `path:"workoutId" doc:"Workout ID"`
+	conditional.Params
 }
 
 type CreateWorkoutInput struct {
-	Body struct {
-		UserID          int64  `json:"user_id,omitempty" doc:"Owner user ID (dev only; derived from auth token in production)"`
-		Name            string `json:"name" minLength:"1" doc:"Workout name"`
-		Description     string `json:"description,omitempty" doc:"Optional description"`
-		DurationMinutes int    `json:"duration_minutes" minimum:"0" doc:"Duration in minutes"`
-	}
+	Body NewWorkout
+}
+
+// NewWorkout is a workout to create.
+type NewWorkout struct {
+	ID              uuid.UUID           `json:"id,omitempty" format:"uuid" doc:"Public ID for the new workout, for clients that assign their own; generated when omitted"`
+	UserID          uuid.UUID           `json:"user_id,omitempty" doc:"Owner user ID (dev only; derived from auth token in production)"`
+	Name            string              `json:"name" minLength:"1" doc:"Workout name"`
+	Description     string              `json:"description,omitempty" doc:"Optional description"`
+	DurationMinutes int                 `json:"duration_minutes" minimum:"0" doc:"Duration in minutes"`
+	DistanceMeters  int                 `json:"distance_meters,omitempty" minimum:"0" doc:"Distance covered in meters"`
+	PerformedAt     time.Time           `json:"performed_at,omitempty" doc:"When the workout took place (defaults to now)"`
+	Visibility      string              `json:"visibility,omitempty" enum:"private,followers,public" default:"private" doc:"Who may see this workout"`
+	Tags            []string            `json:"tags,omitempty" maxItems:"20" doc:"Labels such as deload or travel, stored trimmed and lower-cased"`
+	CustomFields    map[string]any      `json:"custom_fields,omitempty" doc:"Values of the owner's custom fields, by key"`
+	Exercises       []training.Exercise `json:"exercises,omitempty" maxItems:"50" doc:"Exercises performed, in order, with their sets"`
+	Groups          []training.Group    `json:"groups,omitempty" maxItems:"50" doc:"Supersets, circuits and intervals the exercises were performed in"`
+}
+
+// WorkoutFields are the fields a client sets on a workout: the body of a
+// full replace, and the document a patch applies to.
+type WorkoutFields struct {
+	Name            string              `json:"name" minLength:"1" doc:"Workout name"`
+	Description     string              `json:"description" required:"false" doc:"Optional description"`
+	DurationMinutes int                 `json:"duration_minutes" minimum:"0" doc:"Duration in minutes"`
+	DistanceMeters  int                 `json:"distance_meters" required:"false" minimum:"0" doc:"Distance covered in meters"`
+	PerformedAt     time.Time           `json:"performed_at" doc:"When the workout took place"`
+	Visibility      string              `json:"visibility" required:"false" enum:"private,followers,public" default:"private" doc:"Who may see this workout"`
+	Tags            []string            `json:"tags" required:"false" maxItems:"20" doc:"Labels such as deload or travel, stored trimmed and lower-cased"`
+	CustomFields    map[string]any      `json:"custom_fields" required:"false" doc:"Values of the owner's custom fields, by key; null removes a value"`
+	Exercises       []training.Exercise `json:"exercises" required:"false" maxItems:"50" doc:"Exercises performed, in order, with their sets"`
+	Groups          []training.Group    `json:"groups" required:"false" maxItems:"50" doc:"Supersets, circuits and intervals the exercises were performed in"`
 }
 
+// UpdateWorkoutInput is a JSON Merge Patch, or a JSON Patch when sent as
+// application/json-patch+json, of the workout's WorkoutFields.
 type UpdateWorkoutInput struct {
-	WorkoutID int64 `path:"workoutId" doc:"Workout ID"`
-	Body      struct {
-		Name            string `json:"name,omitempty" doc:"Workout name"`
-		Description     string `json:"description,omitempty" doc:"Optional description"`
-		DurationMinutes int    `json:"duration_minutes,omitempty" minimum:"0" doc:"Duration in minutes"`
-	}
+	WorkoutID uuid.UUID `path:"workoutId" doc:"Workout ID"`
+	conditional.Params
+	ContentType string     `header:"Content-Type" doc:"application/merge-patch+json (or application/json) or application/json-patch+json"`
+	Body        patch.Body `contentType:"application/merge-patch+json"`
+}
+
+type ReplaceWorkoutInput struct {
+	WorkoutID uuid.UUID `path:"workoutId" doc:"Workout ID"`
+	conditional.Params
+	Body WorkoutFields
 }
 
 type DeleteWorkoutInput struct {
-	WorkoutID int64 `path:"workoutId" doc:"Workout ID"`
+	WorkoutID uuid.UUID `path:"workoutId" doc:"Workout ID"`
+	conditional.Params
+}
+
+type ListTrashInput struct {
+	UserID uuid.UUID `query:"userId" doc:"Trash of this user (dev only; derived from auth token in production)"`
+}
+
+type EmptyTrashInput struct {
+	UserID uuid.UUID `query:"userId" doc:"Trash of this user (dev only; derived from auth token in production)"`
+}
+
+type RestoreWorkoutInput struct {
+	WorkoutID uuid.UUID `path:"workoutId" doc:"Workout ID"`
+}
+
+type PurgeWorkoutInput struct {
+	WorkoutID uuid.UUID `path:"workoutId" doc:"Workout ID"`
 }
 
 // --- outputs / response bodies ---
 
 type WorkoutResponse struct {
-	ID              int64     `json:"id"`
-	UserID          int64     `json:"user_id"`
-	Name            string    `json:"name"`
-	Description     string    `json:"description,omitempty"`
-	DurationMinutes int       `json:"duration_minutes"`
-	CreatedAt       time.Time `json:"created_at"`
-	UpdatedAt       time.Time `json:"updated_at"`
+	ID              uuid.UUID           `json:"id"`
+	UserID          uuid.UUID           `json:"user_id"`
+	Name            string              `json:"name"`
+	Description     string              `json:"description,omitempty"`
+	DurationMinutes int                 `json:"duration_minutes"`
+	DistanceMeters  int                 `json:"distance_meters,omitempty"`
+	PerformedAt     time.Time           `json:"performed_at"`
+	Visibility      string              `json:"visibility"`
+	Tags            []string            `json:"tags"`
+	CustomFields    map[string]any      `json:"custom_fields"`
+	Exercises       []training.Exercise `json:"exercises"`
+	Groups          []training.Group    `json:"groups"`
+	Version         int64               `json:"version" doc:"Incremented on every change; the ETag carries it"`
+	CreatedAt       time.Time           `json:"created_at"`
+	UpdatedAt       time.Time           `json:"updated_at"`
+}
+
+// WorkoutDetailResponse is a workout with its exercises arranged as
+// performed.
+type WorkoutDetailResponse struct {
+	WorkoutResponse
+	Structure []training.Node `json:"structure" doc:"Exercises in order, nested in the groups they were performed in"`
+}
+
+type TrashedWorkoutResponse struct {
+	WorkoutResponse
+	DeletedAt time.Time `json:"deleted_at"`
+	PurgesAt  time.Time `json:"purges_at" doc:"When the workout will be deleted for good"`
 }
 
 type GetWorkoutOutput struct {
+	ETag string `header:"ETag"`
 	Body *WorkoutResponse
 }
 
+type GetWorkoutDetailOutput struct {
+	ETag string `header:"ETag"`
+	Body *WorkoutDetailResponse
+}
+
 type CreateWorkoutOutput struct {
 	Status int
+	ETag   string `header:"ETag"`
 	Body   *WorkoutResponse
 }
 
 type UpdateWorkoutOutput struct {
+	ETag string `header:"ETag"`
 	Body *WorkoutResponse
 }
 
 type ListWorkoutsOutput struct {
 	Body []WorkoutResponse
 }
+
+type ListTrashOutput struct {
+	Body []TrashedWorkoutResponse
+}
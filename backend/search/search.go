@@ -0,0 +1,193 @@
+// Package search finds records by the words in them. Every searchable
+// table has a search_vector column Postgres generates from its text, with
+// a GIN index over it, so results are always current and a search is an
+// index scan rather than a pass over every row.
+//
+// Vectors hold each word twice: stemmed by the english configuration, so
+// "runs" finds "running", and as written by the simple configuration, so
+// a prefix typed so far ("runn") can match before it stems the same way.
+//
+// Workouts also index the names and notes of their exercises in a
+// separate vector. It finds the workouts worth looking into; each of
+// their exercises is then matched on its own, so a search for "pause
+// squat" lands on the exercise it was noted on.
+package search
+
+import (
+	"regexp"
+	"strings"
+	"time"
+
+	"workout-tracker/backend/models"
+	"workout-tracker/backend/schemas"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Kinds of record a search returns.
+const (
+	KindWorkout  = "workout"
+	KindExercise = "exercise"
+)
+
+// Text search configurations for full queries and for prefix queries.
+const (
+	Config       = "english"
+	PrefixConfig = "simple"
+)
+
+// Highlighted words are marked with characters from Unicode's private use
+// area, which fragments splits on, rather than HTML tags.
+const (
+	startSel = "\uE000"
+	stopSel  = "\uE001"
+)
+
+const (
+	// titleOptions highlight the whole title.
+	titleOptions = `HighlightAll=true, StartSel="` + startSel + `", StopSel="` + stopSel + `"`
+	// snippetOptions pick up to two short excerpts around the matches.
+	snippetOptions = `MaxFragments=2, MaxWords=20, MinWords=8, FragmentDelimiter=" … ", StartSel="` + startSel + `", StopSel="` + stopSel + `"`
+)
+
+// Options describe one page of a search.
+type Options struct {
+	// Text is what the user typed.
+	Text string
+	// Prefix matches the last word of Text as a prefix, for typeahead.
+	// Text is then taken as plain words, without websearch syntax.
+	Prefix bool
+	// ViewerID limits results to what that user may see; 0 sees
+	// everything.
+	ViewerID      int64
+	Limit, Offset int
+}
+
+// workoutMatches selects the workouts matching the query in q. Every kind
+// of record selects the same columns, so more kinds can be searched by
+// adding them to the union. snippet is the text excerpts are taken from;
+// headlines are only worked out for the page returned, being far costlier
+// than matching.
+const workoutMatches = `SELECT 'workout' AS kind, w.public_id AS id, w.public_id AS workout_id, w.name AS title, w.performed_at,
+    ts_rank_cd(w.search_vector, q.query) AS rank, COALESCE(w.description, '') AS snippet
+  FROM q, workouts w
+  WHERE w.search_vector @@ q.query AND w.deleted_at IS NULL`
+
+// exerciseMatches selects the exercises matching the query in q, with the
+// notes of the exercise and its sets as the snippet.
+const exerciseMatches = `SELECT 'exercise' AS kind, (x.e->>'id')::uuid AS id, w.public_id AS workout_id, x.name AS title, w.performed_at,
+    ts_rank_cd(v.vector, q.query) AS rank, x.notes AS snippet
+  FROM q, workouts w,
+    LATERAL (SELECT e, e->>'name' AS name,
+        concat_ws(' ', e->>'notes', (SELECT string_agg(s->>'notes', ' ') FROM jsonb_array_elements(e->'sets') s)) AS notes
+      FROM jsonb_array_elements(w.exercises) e) x,
+    LATERAL (SELECT setweight(to_tsvector('english', x.name) || to_tsvector('simple', x.name), 'A') ||
+        setweight(to_tsvector('english', x.notes) || to_tsvector('simple', x.notes), 'B') AS vector) v
+  WHERE w.exercise_search_vector @@ q.query AND v.vector @@ q.query AND w.deleted_at IS NULL`
+
+// visibleWorkouts restricts the workouts w to those @viewer may see, as
+// the API's canViewWorkout does.
+const visibleWorkouts = ` AND (w.user_id = @viewer OR w.visibility = @public
+    OR (w.visibility = @followers AND w.user_id IN (SELECT followee_id FROM follows WHERE follower_id = @viewer AND deleted_at IS NULL)))`
+
+// Search returns the records matching o.Text that o.ViewerID may see, best
+// matches first.
+func Search(db *gorm.DB, o Options) ([]schemas.SearchResult, error) {
+	config, query, parse := Config, o.Text, "websearch_to_tsquery"
+	if o.Prefix {
+		config, query, parse = PrefixConfig, PrefixQuery(o.Text), "to_tsquery"
+		if query == "" {
+			return []schemas.SearchResult{}, nil
+		}
+	}
+	matches := []string{workoutMatches, exerciseMatches}
+	if o.ViewerID != 0 {
+		for i := range matches {
+			matches[i] += visibleWorkouts
+		}
+	}
+	var rows []struct {
+		Kind            string
+		ID              uuid.UUID
+		WorkoutID       uuid.UUID
+		Title           string
+		PerformedAt     time.Time
+		Rank            float64
+		TitleHeadline   string
+		SnippetHeadline string
+	}
+	err := db.Raw(`WITH q AS (SELECT `+parse+`('`+config+`', @query) AS query, '`+config+`'::regconfig AS config)
+SELECT page.kind, page.id, page.workout_id, page.title, page.performed_at, page.rank,
+    ts_headline(q.config, page.title, q.query, @title_options) AS title_headline,
+    ts_headline(q.config, page.snippet, q.query, @snippet_options) AS snippet_headline
+  FROM q, (SELECT * FROM (`+strings.Join(matches, "\nUNION ALL\n")+`) matches
+    ORDER BY rank DESC, performed_at DESC, id
+    LIMIT @limit OFFSET @offset) page
+ORDER BY page.rank DESC, page.performed_at DESC, page.id`, map[string]any{
+		"query":           query,
+		"title_options":   titleOptions,
+		"snippet_options": snippetOptions,
+		"viewer":          o.ViewerID,
+		"public":          models.VisibilityPublic,
+		"followers":       models.VisibilityFollowers,
+		"limit":           o.Limit,
+		"offset":          o.Offset,
+	}).Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	out := make([]schemas.SearchResult, len(rows))
+	for i, r := range rows {
+		out[i] = schemas.SearchResult{
+			Kind:           r.Kind,
+			ID:             r.ID,
+			WorkoutID:      r.WorkoutID,
+			Title:          r.Title,
+			TitleHighlight: fragments(r.TitleHeadline),
+			Snippet:        fragments(r.SnippetHeadline),
+			PerformedAt:    r.PerformedAt,
+			Rank:           r.Rank,
+		}
+	}
+	return out, nil
+}
+
+var word = regexp.MustCompile(`[\p{L}\p{N}]+`)
+
+// PrefixQuery turns text into a tsquery matching records with all its
+// words, the last of them as a prefix. Anything but letters and digits is
+// dropped, so the result is always valid tsquery syntax. It is empty when
+// text has no words.
+func PrefixQuery(text string) string {
+	words := word.FindAllString(strings.ToLower(text), -1)
+	if len(words) == 0 {
+		return ""
+	}
+	for i, w := range words {
+		words[i] = "'" + w + "'"
+	}
+	return strings.Join(words, " & ") + ":*"
+}
+
+// fragments splits a headline Postgres marked up with startSel and stopSel
+// into runs of text.
+func fragments(headline string) []schemas.HighlightFragment {
+	out := []schemas.HighlightFragment{}
+	match := false
+	for headline != "" {
+		sel := startSel
+		if match {
+			sel = stopSel
+		}
+		text, rest, found := strings.Cut(headline, sel)
+		if text != "" {
+			out = append(out, schemas.HighlightFragment{Text: text, Match: match})
+		}
+		if !found {
+			break
+		}
+		headline, match = rest, !match
+	}
+	return out
+}
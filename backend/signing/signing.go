@@ -0,0 +1,49 @@
+// Package signing signs short payloads with HMAC-SHA256. Links that grant
+// something without a login, such as unsubscribing or downloading an
+// attachment, carry such a signature instead of a token kept in the
+// database.
+package signing
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+)
+
+// Key signs payloads and checks their signatures.
+type Key struct {
+	key []byte
+}
+
+// NewKey returns a Key for secret. An empty secret gets a random key,
+// which is fine for development but invalidates every signature handed
+// out before a restart.
+func NewKey(secret string) *Key {
+	key := []byte(secret)
+	if len(key) == 0 {
+		key = make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			panic(err)
+		}
+	}
+	return &Key{key: key}
+}
+
+// Sign returns the signature of payload, base64url-encoded without
+// padding.
+func (k *Key) Sign(payload string) string {
+	return base64.RawURLEncoding.EncodeToString(k.mac(payload))
+}
+
+// Valid reports whether signature is payload's, in constant time.
+func (k *Key) Valid(payload, signature string) bool {
+	got, err := base64.RawURLEncoding.DecodeString(signature)
+	return err == nil && hmac.Equal(got, k.mac(payload))
+}
+
+func (k *Key) mac(payload string) []byte {
+	h := hmac.New(sha256.New, k.key)
+	h.Write([]byte(payload))
+	return h.Sum(nil)
+}
@@ -0,0 +1,310 @@
+package backend_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"workout-tracker/backend"
+	"workout-tracker/backend/attachments"
+	"workout-tracker/backend/blob"
+	"workout-tracker/backend/blob/blobtest"
+	"workout-tracker/backend/schemas"
+)
+
+// attachmentCols returns the column names that GORM scans for an Attachment row.
+func attachmentCols() []string {
+	return []string{"id", "public_id", "created_at", "updated_at", "deleted_at", "workout_id", "user_id", "kind",
+		"filename", "content_type", "size", "storage_key", "thumbnail_key", "thumbnail_size", "width", "height"}
+}
+
+// testPNG returns a w×h PNG image.
+func testPNG(t *testing.T, w, h int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := range h {
+		for x := range w {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+		}
+	}
+	var b bytes.Buffer
+	require.NoError(t, png.Encode(&b, img))
+	return b.Bytes()
+}
+
+// multipartFile returns a multipart form holding data as its file field,
+// and the Content-Type header to send it with.
+func multipartFile(t *testing.T, filename string, data []byte) (string, *bytes.Buffer) {
+	t.Helper()
+	var b bytes.Buffer
+	mw := multipart.NewWriter(&b)
+	fw, err := mw.CreateFormFile("file", filename)
+	require.NoError(t, err)
+	_, err = fw.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, mw.Close())
+	return "Content-Type: " + mw.FormDataContentType(), &b
+}
+
+// storedFiles lists the files under dir.
+func storedFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	var files []string
+	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			rel, _ := filepath.Rel(dir, path)
+			files = append(files, filepath.ToSlash(rel))
+		}
+		return err
+	})
+	require.NoError(t, err)
+	return files
+}
+
+// expectAttachmentWorkout mocks loading user 1's workout 4 for an upload.
+func expectAttachmentWorkout(mock sqlmock.Sqlmock, ownerID int64) {
+	mock.ExpectQuery(`SELECT \* FROM "workouts" WHERE public_id = \$1 AND "workouts"."deleted_at" IS NULL`).
+		WillReturnRows(sqlmock.NewRows(workoutCols()).
+			AddRow(int64(4), pub(4).String(), fixedTime, fixedTime, nil, ownerID, "Leg day", "", 60))
+}
+
+// expectQuotaCheck mocks the locked usage lookup of user 1 finding used
+// bytes in use.
+func expectQuotaCheck(mock sqlmock.Sqlmock, used int64) {
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT "id" FROM "users" WHERE "users"."id" = \$1 AND "users"."deleted_at" IS NULL ORDER BY "users"."id" LIMIT \$2 FOR UPDATE`).
+		WithArgs(1, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectQuery(`SELECT COALESCE\(SUM\(size \+ COALESCE\(thumbnail_size, 0\)\), 0\) AS used, COUNT\(\*\) AS attachments FROM "attachments" WHERE user_id = \$1`).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"used", "attachments"}).AddRow(used, 3))
+}
+
+func TestUploadAttachment_PhotoGetsThumbnail(t *testing.T) {
+	db, mock := newMockDB(t)
+	dir := t.TempDir()
+	api := newTestAPI(t, db, backend.WithBlobStore(blob.NewFS(dir)))
+	photo := testPNG(t, 640, 480)
+
+	expectUserLookup(mock, 1)
+	expectAttachmentWorkout(mock, 1)
+	expectQuotaCheck(mock, 0)
+	mock.ExpectExec(`INSERT INTO "attachments"`).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), nil, int64(1), int64(4), int64(1), "photo",
+			"squat.png", "image/png", int64(len(photo)), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), 640, 480).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	header, body := multipartFile(t, `C:\Users\me\squat.png`, photo)
+	resp := api.Post("/api/v1/workouts/"+pub(4).String()+"/attachments?userId="+pub(1).String(), header, body)
+
+	require.Equal(t, http.StatusCreated, resp.Code, resp.Body.String())
+	var got schemas.AttachmentResponse
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &got))
+	assert.Equal(t, pub(4), got.WorkoutID)
+	assert.Equal(t, "photo", got.Kind)
+	assert.Equal(t, "squat.png", got.Filename)
+	assert.Equal(t, 640, got.Width)
+	assert.Equal(t, 480, got.Height)
+	assert.NotEmpty(t, got.URL)
+	assert.NotEmpty(t, got.ThumbnailURL)
+	assert.WithinDuration(t, time.Now().Add(attachments.DefaultURLTTL), got.ExpiresAt, 5*time.Second)
+	assert.ElementsMatch(t, []string{
+		"attachments/" + got.ID.String(),
+		"attachments/" + got.ID.String() + "-thumbnail",
+	}, storedFiles(t, dir))
+
+	thumb, err := os.ReadFile(filepath.Join(dir, "attachments", got.ID.String()+"-thumbnail"))
+	require.NoError(t, err)
+	cfg, err := jpeg.DecodeConfig(bytes.NewReader(thumb))
+	require.NoError(t, err)
+	assert.Equal(t, 320, cfg.Width)
+	assert.Equal(t, 240, cfg.Height)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUploadAttachment_QuotaExceededRemovesBlobs(t *testing.T) {
+	db, mock := newMockDB(t)
+	dir := t.TempDir()
+	api := newTestAPI(t, db,
+		backend.WithBlobStore(blob.NewFS(dir)),
+		backend.WithAttachmentLimits(attachments.Limits{MaxSize: 1 << 20, Quota: 1 << 20}))
+
+	expectUserLookup(mock, 1)
+	expectAttachmentWorkout(mock, 1)
+	expectQuotaCheck(mock, 1<<20-10)
+	mock.ExpectRollback()
+
+	header, body := multipartFile(t, "notes.txt", []byte("a training log longer than ten bytes"))
+	resp := api.Post("/api/v1/workouts/"+pub(4).String()+"/attachments?userId="+pub(1).String(), header, body)
+
+	assert.Equal(t, http.StatusForbidden, resp.Code, resp.Body.String())
+	assert.Contains(t, resp.Body.String(), "storage quota of 1 MiB exceeded")
+	assert.Empty(t, storedFiles(t, dir))
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUploadAttachment_TooLarge(t *testing.T) {
+	db, mock := newMockDB(t)
+	dir := t.TempDir()
+	api := newTestAPI(t, db,
+		backend.WithBlobStore(blob.NewFS(dir)),
+		backend.WithAttachmentLimits(attachments.Limits{MaxSize: 8}))
+
+	expectUserLookup(mock, 1)
+	expectAttachmentWorkout(mock, 1)
+
+	header, body := multipartFile(t, "notes.txt", []byte("more than eight bytes"))
+	resp := api.Post("/api/v1/workouts/"+pub(4).String()+"/attachments?userId="+pub(1).String(), header, body)
+
+	assert.Equal(t, http.StatusRequestEntityTooLarge, resp.Code, resp.Body.String())
+	assert.Empty(t, storedFiles(t, dir))
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUploadAttachment_OversizedBodyRefusedUnread(t *testing.T) {
+	db, mock := newMockDB(t)
+	dir := t.TempDir()
+	api := newTestAPI(t, db,
+		backend.WithBlobStore(blob.NewFS(dir)),
+		backend.WithAttachmentLimits(attachments.Limits{MaxSize: 8}))
+
+	// Far past the file limit plus the form's overhead, so the body is
+	// cut off while it is read, before the handler or database see it.
+	header, body := multipartFile(t, "notes.txt", bytes.Repeat([]byte("x"), 1<<20))
+	resp := api.Post("/api/v1/workouts/"+pub(4).String()+"/attachments?userId="+pub(1).String(), header, body)
+
+	assert.Equal(t, http.StatusRequestEntityTooLarge, resp.Code, resp.Body.String())
+	assert.Empty(t, storedFiles(t, dir))
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUploadAttachment_OnlyOwner(t *testing.T) {
+	db, mock := newMockDB(t)
+	api := newTestAPI(t, db, backend.WithBlobStore(blob.NewFS(t.TempDir())))
+
+	expectUserLookup(mock, 2)
+	expectAttachmentWorkout(mock, 1)
+
+	header, body := multipartFile(t, "notes.txt", []byte("hello"))
+	resp := api.Post("/api/v1/workouts/"+pub(4).String()+"/attachments?userId="+pub(2).String(), header, body)
+
+	assert.Equal(t, http.StatusForbidden, resp.Code, resp.Body.String())
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+// downloadSetup stores a text attachment 9 and returns an API serving it
+// with signer.
+func downloadSetup(t *testing.T) (*attachments.Signer, sqlmock.Sqlmock, func(path string) *http.Response) {
+	t.Helper()
+	db, mock := newMockDB(t)
+	store := blob.NewFS(t.TempDir())
+	require.NoError(t, store.Put(context.Background(), attachments.StorageKey(pub(9)), bytes.NewReader([]byte("12 x 5 @ 100kg")), 14, "text/plain; charset=utf-8"))
+	signer := attachments.NewSigner("test-secret")
+	api := newTestAPI(t, db, backend.WithBlobStore(store), backend.WithAttachmentSigner(signer))
+	return signer, mock, func(path string) *http.Response { return api.Get(path).Result() }
+}
+
+func downloadPath(signer *attachments.Signer, variant string, expires time.Time) string {
+	return "/files/attachments/" + pub(9).String() + "?" + url.Values{
+		"variant":   {variant},
+		"expires":   {strconv.FormatInt(expires.Unix(), 10)},
+		"signature": {signer.Sign(pub(9), variant, expires)},
+	}.Encode()
+}
+
+func TestDownloadAttachment_SignedURL(t *testing.T) {
+	signer, mock, get := downloadSetup(t)
+	mock.ExpectQuery(`SELECT \* FROM "attachments" WHERE public_id = \$1 AND "attachments"."deleted_at" IS NULL`).
+		WithArgs(pub(9), 1).
+		WillReturnRows(sqlmock.NewRows(attachmentCols()).
+			AddRow(int64(9), pub(9).String(), fixedTime, fixedTime, nil, int64(4), int64(1), "file",
+				"log.txt", "text/plain; charset=utf-8", int64(14), attachments.StorageKey(pub(9)), "", 0, 0, 0))
+
+	resp := get(downloadPath(signer, attachments.VariantOriginal, time.Now().Add(time.Minute)))
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var b bytes.Buffer
+	_, err := b.ReadFrom(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "12 x 5 @ 100kg", b.String())
+	assert.Equal(t, "text/plain; charset=utf-8", resp.Header.Get("Content-Type"))
+	assert.Equal(t, `attachment; filename=log.txt`, resp.Header.Get("Content-Disposition"))
+	assert.Equal(t, "nosniff", resp.Header.Get("X-Content-Type-Options"))
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDownloadAttachment_ExpiredURL(t *testing.T) {
+	signer, mock, get := downloadSetup(t)
+
+	resp := get(downloadPath(signer, attachments.VariantOriginal, time.Now().Add(-time.Second)))
+
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDownloadAttachment_TamperedURL(t *testing.T) {
+	signer, mock, get := downloadSetup(t)
+	expires := time.Now().Add(time.Minute)
+	// A signature for the thumbnail doesn't open the original, nor one
+	// made with another key, nor one for another expiry.
+	tampered := []string{
+		"/files/attachments/" + pub(9).String() + "?variant=original&expires=" + strconv.FormatInt(expires.Unix(), 10) +
+			"&signature=" + signer.Sign(pub(9), attachments.VariantThumbnail, expires),
+		downloadPath(attachments.NewSigner("other-secret"), attachments.VariantOriginal, expires),
+		"/files/attachments/" + pub(9).String() + "?variant=original&expires=" + strconv.FormatInt(expires.Add(time.Hour).Unix(), 10) +
+			"&signature=" + signer.Sign(pub(9), attachments.VariantOriginal, expires),
+	}
+
+	for _, path := range tampered {
+		resp := get(path)
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode, path)
+	}
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSweepAttachments(t *testing.T) {
+	db, mock := newMockDB(t)
+	srv := blobtest.NewS3("attachments", "us-east-1", testCreds)
+	defer srv.Close()
+	store := srv.Store()
+	ctx := context.Background()
+	for _, key := range []string{attachments.StorageKey(pub(9)), attachments.ThumbnailKey(pub(9)), attachments.StorageKey(pub(10))} {
+		require.NoError(t, store.Put(ctx, key, bytes.NewReader([]byte("x")), 1, ""))
+	}
+
+	mock.ExpectQuery(`SELECT \* FROM "attachments" WHERE deleted_at IS NOT NULL ORDER BY id LIMIT \$1`).
+		WillReturnRows(sqlmock.NewRows(attachmentCols()).
+			AddRow(int64(9), pub(9).String(), fixedTime, fixedTime, fixedTime, int64(4), int64(1), "photo",
+				"a.png", "image/png", int64(1), attachments.StorageKey(pub(9)), attachments.ThumbnailKey(pub(9)), 1, 1, 1))
+	mock.ExpectBegin()
+	mock.ExpectExec(`DELETE FROM "attachments" WHERE "attachments"."id" = \$1`).
+		WithArgs(int64(9)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	n, err := attachments.NewSweeper(db, store).Sweep(ctx)
+
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.Equal(t, 1, srv.Len())
+	_, _, ok := srv.Object(attachments.StorageKey(pub(10)))
+	assert.True(t, ok)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
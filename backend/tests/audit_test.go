@@ -0,0 +1,272 @@
+package backend_test
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/danielgtaylor/huma/v2/adapters/humagin"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"workout-tracker/backend/audit"
+	"workout-tracker/backend/schemas"
+)
+
+// jsonArg matches a JSON column argument semantically.
+type jsonArg string
+
+func (j jsonArg) Match(v driver.Value) bool {
+	var raw []byte
+	switch b := v.(type) {
+	case []byte:
+		raw = b
+	case string:
+		raw = []byte(b)
+	default:
+		return false
+	}
+	var got, want any
+	if json.Unmarshal(raw, &got) != nil || json.Unmarshal([]byte(j), &want) != nil {
+		return false
+	}
+	return reflect.DeepEqual(got, want)
+}
+
+func TestAudit_CreateRecordsActorAndRequest(t *testing.T) {
+	db, mock := newMockDB(t)
+	require.NoError(t, audit.RegisterCallbacks(db))
+	api := newAuthedTestAPI(t, db, "zitadel-sub-5")
+
+	mock.ExpectQuery(`SELECT \* FROM "users" WHERE zitadel_id`).
+		WillReturnRows(sqlmock.NewRows(userCols()).
+			AddRow(int64(5), pub(5).String(), fixedTime, fixedTime, nil, "zitadel-sub-5", "m@example.com", "Member", "secret-hash"))
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO "workouts"`).
+		WillReturnResult(sqlmock.NewResult(9, 1))
+	expectOwner(mock, 5)
+	mock.ExpectExec(`INSERT INTO "audit_logs" \("public_id","created_at","actor_user_id","subject","token_type","client_id","action","resource_type","resource_id","resource_public_id","changes","ip","user_agent","request_id"\)`).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), 5, "zitadel-sub-5", "Bearer", "", "create", "workout", 9, pub(9),
+			jsonArg(`{
+				"id": {"old": null, "new": "`+pub(9).String()+`"},
+				"user_id": {"old": null, "new": "`+pub(5).String()+`"},
+				"name": {"old": null, "new": "Morning Run"},
+				"duration_minutes": {"old": null, "new": 30},
+				"performed_at": {"old": null, "new": "2024-01-15T10:00:00Z"},
+				"visibility": {"old": null, "new": "private"},
+				"tags": {"old": null, "new": []},
+				"custom_fields": {"old": null, "new": {}},
+				"exercises": {"old": null, "new": []},
+				"groups": {"old": null, "new": []},
+				"deleted_at": {"old": null, "new": null}
+			}`),
+			"127.0.0.1", "test-agent/1.0", "req-123").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	expectRevision(mock, 5, 0)
+	mock.ExpectCommit()
+
+	resp := api.Post("/api/v1/workouts",
+		"X-Request-ID: req-123",
+		"User-Agent: test-agent/1.0",
+		// Without a trusted proxy in front, forwarded headers are ignored.
+		"X-Forwarded-For: 203.0.113.7, 10.0.0.1",
+		map[string]any{"id": pub(9), "name": "Morning Run", "duration_minutes": 30, "performed_at": "2024-01-15T10:00:00Z"})
+
+	require.Equal(t, http.StatusCreated, resp.Code, resp.Body.String())
+	assert.Equal(t, "req-123", resp.Header().Get("X-Request-ID"))
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAudit_ClientIPTrustsOnlyConfiguredProxies(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	require.NoError(t, r.SetTrustedProxies([]string{"10.0.0.0/8"}))
+	r.Use(audit.ClientIP)
+	api := humagin.New(r, huma.DefaultConfig("test", "1.0.0"))
+	api.UseMiddleware(audit.Middleware)
+	huma.Get(api, "/ip", func(ctx context.Context, _ *struct{}) (*struct{ Body string }, error) {
+		return &struct{ Body string }{Body: audit.FromContext(ctx).IP}, nil
+	})
+
+	tests := map[string]struct {
+		remote string
+		want   string
+	}{
+		"through trusted proxy": {"10.0.0.1:4321", "203.0.113.7"},
+		"direct from client":    {"198.51.100.9:4321", "198.51.100.9"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/ip", nil)
+			req.RemoteAddr = tt.remote
+			req.Header.Set("X-Forwarded-For", "203.0.113.7")
+			rec := httptest.NewRecorder()
+
+			r.ServeHTTP(rec, req)
+
+			require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+			assert.Equal(t, `"`+tt.want+`"`, strings.TrimSpace(rec.Body.String()))
+		})
+	}
+}
+
+func TestAudit_UpdateRecordsOnlyChangedFields(t *testing.T) {
+	db, mock := newMockDB(t)
+	require.NoError(t, audit.RegisterCallbacks(db))
+	api := newTestAPI(t, db)
+
+	mock.ExpectQuery(`SELECT \* FROM "workouts"`).
+		WillReturnRows(sqlmock.NewRows(append(workoutCols(), "visibility", "tags", "custom_fields", "exercises", "groups")).
+			AddRow(int64(4), pub(4).String(), fixedTime, fixedTime, nil, int64(1), "Old Name", "", 20, "private", "[]", "{}", "[]", "[]"))
+	mock.ExpectBegin()
+	// The row as it is before the update, read in the same transaction.
+	mock.ExpectQuery(`SELECT \* FROM "workouts" WHERE id = \$1 LIMIT \$2`).
+		WithArgs(4, 1).
+		WillReturnRows(sqlmock.NewRows(append(workoutCols(), "visibility", "tags", "custom_fields", "exercises", "groups")).
+			AddRow(int64(4), pub(4).String(), fixedTime, fixedTime, nil, int64(1), "Old Name", "", 20, "private", "[]", "{}", "[]", "[]"))
+	expectOwner(mock, 1)
+	mock.ExpectExec(`UPDATE "workouts" SET`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectOwner(mock, 1)
+	mock.ExpectExec(`INSERT INTO "audit_logs"`).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), nil, "", "", "", "update", "workout", 4, pub(4),
+			jsonArg(`{"name": {"old": "Old Name", "new": "New Name"}}`),
+			"127.0.0.1", "", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(2, 1))
+	expectRevision(mock, 1, 1)
+	mock.ExpectCommit()
+
+	resp := api.Patch("/api/v1/workouts/"+pub(4).String(), map[string]any{"name": "New Name"})
+
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	// Without a client-supplied ID one is generated.
+	assert.Len(t, resp.Header().Get("X-Request-ID"), 32)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAudit_DeleteRecordsRemovedFields(t *testing.T) {
+	db, mock := newMockDB(t)
+	require.NoError(t, audit.RegisterCallbacks(db))
+	api := newTestAPI(t, db)
+
+	mock.ExpectQuery(`SELECT \* FROM "workouts"`).
+		WillReturnRows(sqlmock.NewRows(append(workoutCols(), "tags", "custom_fields", "exercises", "groups")).
+			AddRow(int64(4), pub(4).String(), fixedTime, fixedTime, nil, int64(1), "Morning Run", "", 30, "[]", "{}", "[]", "[]"))
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "workouts" SET "deleted_at"`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectOwner(mock, 1)
+	mock.ExpectExec(`INSERT INTO "audit_logs"`).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), nil, "", "", "", "delete", "workout", 4, pub(4),
+			jsonArg(`{
+				"id": {"old": "`+pub(4).String()+`", "new": null},
+				"user_id": {"old": "`+pub(1).String()+`", "new": null},
+				"name": {"old": "Morning Run", "new": null},
+				"duration_minutes": {"old": 30, "new": null},
+				"performed_at": {"old": "0001-01-01T00:00:00Z", "new": null},
+				"visibility": {"old": "", "new": null},
+				"tags": {"old": [], "new": null},
+				"custom_fields": {"old": {}, "new": null},
+				"exercises": {"old": [], "new": null},
+				"groups": {"old": [], "new": null}
+			}`),
+			sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(3, 1))
+	mock.ExpectCommit()
+
+	resp := api.Delete("/api/v1/workouts/" + pub(4).String())
+
+	require.Equal(t, http.StatusNoContent, resp.Code, resp.Body.String())
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAudit_BulkStatementRecordsSQL(t *testing.T) {
+	db, mock := newMockDB(t)
+	require.NoError(t, audit.RegisterCallbacks(db))
+	api := newTestAPI(t, db)
+
+	mock.ExpectQuery(`SELECT \* FROM "workouts" WHERE public_id = \$1 AND deleted_at IS NOT NULL`).
+		WillReturnRows(sqlmock.NewRows(workoutCols()).
+			AddRow(int64(4), pub(4).String(), fixedTime, fixedTime, fixedTime, int64(1), "Morning Run", "", 30))
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "planned_sessions"`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`UPDATE "attachments"`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`DELETE FROM "workout_likes"`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`DELETE FROM "workout_comments"`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`DELETE FROM "workout_revisions"`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`DELETE FROM "workouts" WHERE id IN \(\$1\)`).
+		WithArgs(4).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`INSERT INTO "audit_logs"`).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), nil, "", "", "", "delete", "workout", nil, nil,
+			jsonArg(`{"statement": "DELETE FROM \"workouts\" WHERE id IN (4)", "rows_affected": 1}`),
+			sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(4, 1))
+	mock.ExpectCommit()
+
+	resp := api.Delete("/api/v1/workouts/trash/" + pub(4).String())
+
+	require.Equal(t, http.StatusNoContent, resp.Code, resp.Body.String())
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func auditLogCols() []string {
+	return []string{"id", "public_id", "created_at", "actor_user_id", "subject", "token_type", "client_id", "action",
+		"resource_type", "resource_id", "resource_public_id", "changes", "ip", "user_agent", "request_id"}
+}
+
+func TestListAuditLogs_FiltersAndPages(t *testing.T) {
+	db, mock := newMockDB(t)
+	api := newTestAPI(t, db)
+
+	mock.ExpectQuery(`SELECT \* FROM "audit_logs" WHERE resource_type = \$1 AND resource_public_id = \$2 ORDER BY created_at DESC, id DESC LIMIT \$3`).
+		WithArgs("workout", pub(4), 2).
+		WillReturnRows(sqlmock.NewRows(auditLogCols()).
+			AddRow(int64(11), pub(11).String(), fixedTime, int64(5), "zitadel-sub-5", "Bearer", "", "update", "workout", int64(4), pub(4).String(),
+				[]byte(`{"name":{"old":"a","new":"b"}}`), "203.0.113.7", "curl", "req-2").
+			AddRow(int64(10), pub(10).String(), fixedTime, int64(5), "zitadel-sub-5", "Bearer", "", "create", "workout", int64(4), pub(4).String(),
+				[]byte(`{}`), "203.0.113.7", "curl", "req-1"))
+	expectPublicIDs(mock, "users", 5)
+
+	resp := api.Get("/api/v1/admin/audit-logs?resourceType=workout&resourceId=" + pub(4).String() + "&limit=1")
+
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	var page schemas.AuditLogPage
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &page))
+	require.Len(t, page.Items, 1)
+	assert.Equal(t, pub(11), page.Items[0].ID)
+	assert.Equal(t, pub(5), *page.Items[0].ActorUserID)
+	assert.Equal(t, pub(4), *page.Items[0].ResourceID)
+	assert.Equal(t, "req-2", page.Items[0].RequestID)
+	assert.JSONEq(t, `{"name":{"old":"a","new":"b"}}`, string(page.Items[0].Changes))
+	require.NotEmpty(t, page.NextCursor)
+
+	// The cursor carries the public ID of the page's last entry.
+	mock.ExpectQuery(`SELECT "id" FROM "audit_logs" WHERE public_id = \$1`).
+		WithArgs(pub(11), 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(int64(11)))
+	mock.ExpectQuery(`SELECT \* FROM "audit_logs" WHERE \(created_at, id\) < \(\$1, \$2\)`).
+		WithArgs(fixedTime, 11, 2).
+		WillReturnRows(sqlmock.NewRows(auditLogCols()))
+	resp = api.Get("/api/v1/admin/audit-logs?limit=1&cursor=" + page.NextCursor)
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestListAuditLogs_RequiresAdmin(t *testing.T) {
+	db, mock := newMockDB(t)
+	api := newAuthedTestAPI(t, db, "zitadel-sub-5")
+
+	resp := api.Get("/api/v1/admin/audit-logs")
+
+	assert.Equal(t, http.StatusForbidden, resp.Code)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
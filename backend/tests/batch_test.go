@@ -0,0 +1,203 @@
+package backend_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"workout-tracker/backend/schemas"
+)
+
+func batchResults(t *testing.T, body []byte) schemas.BatchWorkoutsOutput {
+	t.Helper()
+	var out schemas.BatchWorkoutsOutput
+	require.NoError(t, json.Unmarshal(body, &out.Body))
+	return out
+}
+
+func TestBatchWorkouts_AtomicAppliesAll(t *testing.T) {
+	db, mock := newMockDB(t)
+	api := newTestAPI(t, db)
+
+	mock.ExpectBegin()
+	expectUserLookup(mock, 1)
+	mock.ExpectExec(`INSERT INTO "workouts"`).WillReturnResult(sqlmock.NewResult(9, 1))
+	expectRevision(mock, 1, 0)
+	expectVersionedWorkout(mock)
+	mock.ExpectExec(`UPDATE "workouts" SET .* WHERE version = \$\d+`).
+		WithArgs(pub(4), fixedTime, sqlmock.AnyArg(), nil, int64(4), int64(1), "Intervals", "", 50, 0, sqlmock.AnyArg(), "private", "[]", "{}", "[]", "[]", int64(3), int64(4)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectRevision(mock, 1, 3)
+	mock.ExpectQuery(`SELECT \* FROM "workouts" WHERE public_id = \$1`).
+		WithArgs(pub(5), 1).
+		WillReturnRows(sqlmock.NewRows(append(workoutCols(), "version")).
+			AddRow(int64(5), pub(5).String(), fixedTime, fixedTime, nil, int64(1), "Old", "", 20, int64(1)))
+	mock.ExpectExec(`UPDATE "workouts" SET "deleted_at"=\$1 WHERE "workouts"."id" = \$2`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	resp := api.Post("/api/v1/workouts:batch", map[string]any{
+		"operations": []map[string]any{
+			{"op": "create", "workout": map[string]any{"user_id": pub(1), "name": "Morning Run", "duration_minutes": 30}},
+			{"op": "update", "workout_id": pub(4), "if_match": `"3"`, "patch": map[string]any{"name": "Intervals"}},
+			{"op": "delete", "workout_id": pub(5)},
+		},
+	})
+
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	out := batchResults(t, resp.Body.Bytes())
+	assert.Equal(t, 3, out.Body.Applied)
+	assert.Equal(t, 0, out.Body.Failed)
+	require.Len(t, out.Body.Results, 3)
+	assert.Equal(t, http.StatusCreated, out.Body.Results[0].Status)
+	assert.Equal(t, "Morning Run", out.Body.Results[0].Workout.Name)
+	assert.Equal(t, http.StatusOK, out.Body.Results[1].Status)
+	assert.Equal(t, pub(4), out.Body.Results[1].Workout.ID)
+	assert.Equal(t, int64(4), out.Body.Results[1].Workout.Version)
+	assert.Equal(t, http.StatusNoContent, out.Body.Results[2].Status)
+	assert.Nil(t, out.Body.Results[2].Workout)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestBatchWorkouts_AtomicRollsBackOnFailure(t *testing.T) {
+	db, mock := newMockDB(t)
+	api := newTestAPI(t, db)
+
+	mock.ExpectBegin()
+	expectUserLookup(mock, 1)
+	mock.ExpectExec(`INSERT INTO "workouts"`).WillReturnResult(sqlmock.NewResult(9, 1))
+	expectRevision(mock, 1, 0)
+	expectVersionedWorkout(mock)
+	mock.ExpectRollback()
+
+	resp := api.Post("/api/v1/workouts:batch", map[string]any{
+		"operations": []map[string]any{
+			{"op": "create", "workout": map[string]any{"user_id": pub(1), "name": "Morning Run", "duration_minutes": 30}},
+			{"op": "delete", "workout_id": pub(4), "if_match": `"2"`},
+			{"op": "delete", "workout_id": pub(5)},
+		},
+	})
+
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	out := batchResults(t, resp.Body.Bytes())
+	assert.Equal(t, 0, out.Body.Applied)
+	assert.Equal(t, 3, out.Body.Failed)
+	statuses := []int{}
+	for _, r := range out.Body.Results {
+		statuses = append(statuses, r.Status)
+		require.NotNil(t, r.Error)
+		assert.Nil(t, r.Workout)
+	}
+	assert.Equal(t, []int{http.StatusFailedDependency, http.StatusPreconditionFailed, http.StatusFailedDependency}, statuses)
+	assert.Contains(t, out.Body.Results[0].Error.Detail, "rolled back")
+	assert.Contains(t, out.Body.Results[2].Error.Detail, "not attempted")
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestBatchWorkouts_BestEffortSkipsFailures(t *testing.T) {
+	db, mock := newMockDB(t)
+	api := newTestAPI(t, db)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`SAVEPOINT sp`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT \* FROM "workouts" WHERE public_id = \$1`).
+		WithArgs(pub(99), 1).
+		WillReturnRows(sqlmock.NewRows(workoutCols()))
+	mock.ExpectExec(`ROLLBACK TO SAVEPOINT sp`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`SAVEPOINT sp`).WillReturnResult(sqlmock.NewResult(0, 0))
+	expectVersionedWorkout(mock)
+	mock.ExpectExec(`UPDATE "workouts" SET "deleted_at"=\$1 WHERE version = \$2 AND "workouts"."id" = \$3`).
+		WithArgs(sqlmock.AnyArg(), 3, 4).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	resp := api.Post("/api/v1/workouts:batch", map[string]any{
+		"mode": "best_effort",
+		"operations": []map[string]any{
+			{"op": "update", "workout_id": pub(99), "patch": map[string]any{"name": "Intervals"}},
+			{"op": "delete", "workout_id": pub(4), "if_match": `"3"`},
+		},
+	})
+
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	out := batchResults(t, resp.Body.Bytes())
+	assert.Equal(t, 1, out.Body.Applied)
+	assert.Equal(t, 1, out.Body.Failed)
+	assert.Equal(t, http.StatusNotFound, out.Body.Results[0].Status)
+	assert.Equal(t, http.StatusNoContent, out.Body.Results[1].Status)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestBatchWorkouts_BestEffortReportsInvalidFields(t *testing.T) {
+	db, mock := newMockDB(t)
+	api := newTestAPI(t, db)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`SAVEPOINT sp`).WillReturnResult(sqlmock.NewResult(0, 0))
+	expectUserLookup(mock, 1)
+	mock.ExpectExec(`ROLLBACK TO SAVEPOINT sp`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`SAVEPOINT sp`).WillReturnResult(sqlmock.NewResult(0, 0))
+	expectVersionedWorkout(mock)
+	mock.ExpectExec(`UPDATE "workouts" SET "deleted_at"=\$1 WHERE version = \$2 AND "workouts"."id" = \$3`).
+		WithArgs(sqlmock.AnyArg(), 3, 4).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	resp := api.Post("/api/v1/workouts:batch", map[string]any{
+		"mode": "best_effort",
+		"operations": []map[string]any{
+			{"op": "create", "workout": map[string]any{"user_id": pub(1), "name": "Morning Run", "duration_minutes": 30, "tags": []string{" "}}},
+			{"op": "delete", "workout_id": pub(4), "if_match": `"3"`},
+		},
+	})
+
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	out := batchResults(t, resp.Body.Bytes())
+	assert.Equal(t, 1, out.Body.Applied)
+	assert.Equal(t, 1, out.Body.Failed)
+	assert.Equal(t, http.StatusUnprocessableEntity, out.Body.Results[0].Status)
+	require.NotNil(t, out.Body.Results[0].Error)
+	assert.Equal(t, "tags must not be blank", out.Body.Results[0].Error.Detail)
+	assert.Equal(t, http.StatusNoContent, out.Body.Results[1].Status)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestBatchWorkouts_ServerErrorAbortsBatch(t *testing.T) {
+	db, mock := newMockDB(t)
+	api := newTestAPI(t, db)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`SAVEPOINT sp`).WillReturnResult(sqlmock.NewResult(0, 0))
+	expectUserLookup(mock, 1)
+	mock.ExpectExec(`INSERT INTO "workouts"`).WillReturnError(sqlmock.ErrCancelled)
+	mock.ExpectExec(`ROLLBACK TO SAVEPOINT sp`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	resp := api.Post("/api/v1/workouts:batch", map[string]any{
+		"mode": "best_effort",
+		"operations": []map[string]any{
+			{"op": "create", "workout": map[string]any{"user_id": pub(1), "name": "Morning Run", "duration_minutes": 30}},
+		},
+	})
+
+	assert.Equal(t, http.StatusInternalServerError, resp.Code)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestBatchWorkouts_LimitsOperations(t *testing.T) {
+	db, mock := newMockDB(t)
+	api := newTestAPI(t, db)
+
+	ops := make([]map[string]any, schemas.MaxBatchOperations+1)
+	for i := range ops {
+		ops[i] = map[string]any{"op": "delete", "workout_id": pub(int64(i + 1))}
+	}
+	resp := api.Post("/api/v1/workouts:batch", map[string]any{"operations": ops})
+
+	assert.Equal(t, http.StatusUnprocessableEntity, resp.Code)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
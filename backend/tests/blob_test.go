@@ -0,0 +1,103 @@
+package backend_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"workout-tracker/backend/blob"
+	"workout-tracker/backend/blob/blobtest"
+)
+
+var testCreds = blob.Credentials{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"}
+
+// TestSignV4_ReferenceVector checks the signer against the worked example
+// of the AWS Signature Version 4 documentation.
+func TestSignV4_ReferenceVector(t *testing.T) {
+	req, err := http.NewRequest(http.MethodGet, "https://iam.amazonaws.com/?Action=ListUsers&Version=2010-05-08", nil)
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+
+	blob.SignV4(req, testCreds, "us-east-1", "iam", blob.EmptyPayloadHash, time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC))
+
+	assert.Equal(t, "20150830T123600Z", req.Header.Get("X-Amz-Date"))
+	assert.Equal(t, "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/iam/aws4_request, "+
+		"SignedHeaders=content-type;host;x-amz-date, "+
+		"Signature=5d672d79c15b13162d9279b0855cfba6789a8edb4c82c400e06b5924a6f2b5d7",
+		req.Header.Get("Authorization"))
+}
+
+func TestS3Store_RoundTrip(t *testing.T) {
+	srv := blobtest.NewS3("attachments", "eu-west-1", testCreds)
+	defer srv.Close()
+	store := srv.Store()
+	ctx := context.Background()
+
+	data := []byte("progress photo")
+	require.NoError(t, store.Put(ctx, "attachments/a/b.jpg", bytes.NewReader(data), int64(len(data)), "image/jpeg"))
+	stored, contentType, ok := srv.Object("attachments/a/b.jpg")
+	require.True(t, ok)
+	assert.Equal(t, data, stored)
+	assert.Equal(t, "image/jpeg", contentType)
+
+	rc, err := store.Get(ctx, "attachments/a/b.jpg")
+	require.NoError(t, err)
+	got, err := io.ReadAll(rc)
+	require.NoError(t, err)
+	require.NoError(t, rc.Close())
+	assert.Equal(t, data, got)
+
+	require.NoError(t, store.Delete(ctx, "attachments/a/b.jpg"))
+	assert.Equal(t, 0, srv.Len())
+	_, err = store.Get(ctx, "attachments/a/b.jpg")
+	assert.ErrorIs(t, err, blob.ErrNotFound)
+	// Deletes are idempotent.
+	assert.NoError(t, store.Delete(ctx, "attachments/a/b.jpg"))
+}
+
+func TestS3Store_WrongSecretRejected(t *testing.T) {
+	srv := blobtest.NewS3("attachments", "eu-west-1", testCreds)
+	defer srv.Close()
+	store := srv.Store()
+	store.SecretAccessKey = "not-the-secret"
+
+	err := store.Put(context.Background(), "k", strings.NewReader("x"), 1, "text/plain")
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "SignatureDoesNotMatch")
+	assert.Equal(t, 0, srv.Len())
+}
+
+func TestFSStore_RoundTrip(t *testing.T) {
+	store := blob.NewFS(t.TempDir())
+	ctx := context.Background()
+
+	require.NoError(t, store.Put(ctx, "attachments/a/b", strings.NewReader("first"), 5, ""))
+	require.NoError(t, store.Put(ctx, "attachments/a/b", strings.NewReader("second"), 6, ""))
+	rc, err := store.Get(ctx, "attachments/a/b")
+	require.NoError(t, err)
+	got, err := io.ReadAll(rc)
+	require.NoError(t, err)
+	require.NoError(t, rc.Close())
+	assert.Equal(t, "second", string(got))
+
+	require.NoError(t, store.Delete(ctx, "attachments/a/b"))
+	_, err = store.Get(ctx, "attachments/a/b")
+	assert.ErrorIs(t, err, blob.ErrNotFound)
+	assert.NoError(t, store.Delete(ctx, "attachments/a/b"))
+}
+
+func TestFSStore_InvalidKeys(t *testing.T) {
+	store := blob.NewFS(t.TempDir())
+	for _, key := range []string{"", "../escape", "a//b", "a/./b", "/abs", "a b", "a/"} {
+		err := store.Put(context.Background(), key, strings.NewReader("x"), 1, "")
+		assert.Error(t, err, "key %q", key)
+	}
+}
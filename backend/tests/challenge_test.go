@@ -0,0 +1,236 @@
+package backend_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"workout-tracker/backend/challenges"
+	"workout-tracker/backend/models"
+	"workout-tracker/backend/schemas"
+)
+
+func TestCreateChallenge_WindowFollowsTimezone(t *testing.T) {
+	db, mock := newMockDB(t)
+	api := newTestAPI(t, db)
+
+	expectUserLookup(mock, 1)
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO "challenges"`).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	expectPublicIDs(mock, "users", 1)
+
+	resp := api.Post("/api/v1/challenges", map[string]any{
+		"user_id":    pub(1),
+		"name":       "March Madness",
+		"metric":     "total_minutes",
+		"start_date": "2024-03-01",
+		"end_date":   "2024-03-31",
+		"timezone":   "Europe/Berlin",
+	})
+
+	require.Equal(t, http.StatusCreated, resp.Code, resp.Body.String())
+	var body schemas.ChallengeResponse
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &body))
+	assert.Equal(t, pub(1), body.CreatedByID)
+	// Local midnight in Berlin: CET at the start, CEST (after DST) at the end.
+	assert.Equal(t, time.Date(2024, 2, 29, 23, 0, 0, 0, time.UTC), body.StartsAt.UTC())
+	assert.Equal(t, time.Date(2024, 3, 31, 22, 0, 0, 0, time.UTC), body.EndsAt.UTC())
+	assert.Equal(t, body.EndsAt.Add(48*time.Hour), body.FreezesAt)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCreateChallenge_UnknownTimezone(t *testing.T) {
+	db, mock := newMockDB(t)
+	api := newTestAPI(t, db)
+
+	expectUserLookup(mock, 1)
+	resp := api.Post("/api/v1/challenges", map[string]any{
+		"user_id":    pub(1),
+		"name":       "Bad Zone",
+		"metric":     "sessions",
+		"start_date": "2024-03-01",
+		"end_date":   "2024-03-31",
+		"timezone":   "Mars/Olympus_Mons",
+	})
+
+	assert.Equal(t, http.StatusUnprocessableEntity, resp.Code)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestJoinChallenge_AfterEnd(t *testing.T) {
+	db, mock := newMockDB(t)
+	api := newTestAPI(t, db)
+
+	expectUserLookup(mock, 2)
+	mock.ExpectQuery(`SELECT \* FROM "challenges" WHERE public_id = \$1`).
+		WithArgs(pub(1), 1).
+		WillReturnRows(sqlmock.NewRows(challengeCols()).
+			AddRow(int64(1), pub(1).String(), fixedTime, fixedTime, nil, nil, int64(1), "Jan", "", "sessions", "UTC",
+				fixedTime, fixedTime.AddDate(0, 1, 0), 48, nil))
+
+	resp := api.Post("/api/v1/challenges/"+pub(1).String()+"/join", map[string]any{"user_id": pub(2)})
+
+	assert.Equal(t, http.StatusConflict, resp.Code)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetStandings_FreezesEndedChallenge(t *testing.T) {
+	db, mock := newMockDB(t)
+	api := newTestAPI(t, db)
+
+	mock.ExpectQuery(`SELECT \* FROM "challenges"`).
+		WillReturnRows(sqlmock.NewRows(challengeCols()).
+			AddRow(int64(1), pub(1).String(), fixedTime, fixedTime, nil, nil, int64(1), "Jan", "", "total_minutes", "UTC",
+				fixedTime, fixedTime.AddDate(0, 1, 0), 48, nil))
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "challenges" SET "finalized_at"=.* WHERE \(id = \$\d+ AND finalized_at IS NULL\)`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`SELECT challenge_participants.user_id, users.public_id AS user_public_id, users.name, COALESCE\(SUM\(workouts.duration_minutes\), 0\) AS value` +
+		`.*LEFT JOIN workouts .*workouts.performed_at >= .*workouts.created_at <`).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "user_public_id", "name", "value"}).
+			AddRow(int64(2), pub(2).String(), "Alice", int64(300)).
+			AddRow(int64(3), pub(3).String(), "Bob", int64(120)))
+	mock.ExpectExec(`UPDATE "challenge_participants" SET "final_rank"=\$1,"final_value"=\$2`).
+		WithArgs(1, int64(300), sqlmock.AnyArg(), int64(1), int64(2)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE "challenge_participants" SET "final_rank"=\$1,"final_value"=\$2`).
+		WithArgs(2, int64(120), sqlmock.AnyArg(), int64(1), int64(3)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectQuery(`SELECT \* FROM "challenge_participants" WHERE challenge_id = \$1 .*ORDER BY final_rank`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "challenge_id", "user_id", "final_rank", "final_value"}).
+			AddRow(int64(1), int64(1), int64(2), 1, int64(300)).
+			AddRow(int64(2), int64(1), int64(3), 2, int64(120)))
+	mock.ExpectQuery(`SELECT \* FROM "users"`).
+		WillReturnRows(sqlmock.NewRows(userCols()).
+			AddRow(int64(2), pub(2).String(), fixedTime, fixedTime, nil, nil, "alice@example.com", "Alice", "").
+			AddRow(int64(3), pub(3).String(), fixedTime, fixedTime, nil, nil, "bob@example.com", "Bob", ""))
+
+	resp := api.Get("/api/v1/challenges/" + pub(1).String() + "/standings")
+
+	require.Equal(t, http.StatusOK, resp.Code)
+	var body schemas.ChallengeStandings
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &body))
+	assert.True(t, body.Final)
+	require.Len(t, body.Entries, 2)
+	assert.Equal(t, schemas.StandingEntry{Rank: 1, UserID: pub(2), Name: "Alice", Value: 300}, body.Entries[0])
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestStandings_DistanceRanksLongestWorkout(t *testing.T) {
+	db, mock := newMockDB(t)
+	c := models.Challenge{Metric: models.ChallengeMetricDistance, StartsAt: fixedTime, EndsAt: fixedTime.AddDate(0, 1, 0)}
+	c.ID = 1
+
+	mock.ExpectQuery(`SELECT .*COALESCE\(MAX\(workouts.distance_meters\), 0\) AS value`).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "user_public_id", "name", "value"}).
+			AddRow(int64(2), pub(2).String(), "Alice", int64(42195)).
+			AddRow(int64(3), pub(3).String(), "Bob", int64(21097)))
+
+	standings, err := challenges.Standings(db, c)
+
+	require.NoError(t, err)
+	require.Len(t, standings, 2)
+	assert.Equal(t, challenges.Standing{Rank: 1, UserID: 2, UserPublicID: pub(2), Name: "Alice", Value: 42195}, standings[0])
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestStreamStandings_EndsAfterFinalResults(t *testing.T) {
+	db, mock := newMockDB(t)
+	api := newTestAPI(t, db)
+
+	finalized := fixedTime.AddDate(0, 1, 3)
+	mock.ExpectQuery(`SELECT \* FROM "challenges"`).
+		WillReturnRows(sqlmock.NewRows(challengeCols()).
+			AddRow(int64(1), pub(1).String(), fixedTime, fixedTime, nil, nil, int64(1), "Jan", "", "sessions", "UTC",
+				fixedTime, fixedTime.AddDate(0, 1, 0), 48, finalized))
+	mock.ExpectQuery(`SELECT \* FROM "challenge_participants"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "challenge_id", "user_id", "final_rank", "final_value"}).
+			AddRow(int64(1), int64(1), int64(2), 1, int64(12)))
+	mock.ExpectQuery(`SELECT \* FROM "users"`).
+		WillReturnRows(sqlmock.NewRows(userCols()).
+			AddRow(int64(2), pub(2).String(), fixedTime, fixedTime, nil, nil, "alice@example.com", "Alice", ""))
+
+	resp := api.Get("/api/v1/challenges/" + pub(1).String() + "/standings/stream")
+
+	require.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, "text/event-stream", resp.Header().Get("Content-Type"))
+	assert.Contains(t, resp.Body.String(), "event: standings\n")
+	assert.Contains(t, resp.Body.String(), `"final":true`)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetStandings_OrganizationMembersOnly(t *testing.T) {
+	for _, path := range []string{"/standings", "/standings/stream"} {
+		t.Run(path, func(t *testing.T) {
+			db, mock := newMockDB(t)
+			api := newAuthedTestAPI(t, db, "zitadel-sub-5")
+
+			mock.ExpectQuery(`SELECT \* FROM "challenges"`).
+				WillReturnRows(sqlmock.NewRows(challengeCols()).
+					AddRow(int64(1), pub(1).String(), fixedTime, fixedTime, nil, int64(7), int64(1), "Jan", "", "sessions", "UTC",
+						fixedTime, fixedTime.AddDate(0, 1, 0), 48, nil))
+			mock.ExpectQuery(`SELECT \* FROM "users" WHERE zitadel_id`).
+				WillReturnRows(sqlmock.NewRows(userCols()).
+					AddRow(int64(5), pub(5).String(), fixedTime, fixedTime, nil, "zitadel-sub-5", "o@example.com", "Outsider", ""))
+			mock.ExpectQuery(`SELECT \* FROM "memberships" WHERE \(organization_id = \$1 AND user_id = \$2\)`).
+				WithArgs(int64(7), int64(5), 1).
+				WillReturnRows(sqlmock.NewRows(membershipCols()))
+
+			resp := api.Get("/api/v1/challenges/" + pub(1).String() + path)
+
+			if path == "/standings" {
+				assert.Equal(t, http.StatusNotFound, resp.Code, resp.Body.String())
+			} else {
+				assert.NotContains(t, resp.Body.String(), "event: standings")
+			}
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestGetChallenge_HiddenFromNonMembers(t *testing.T) {
+	db, mock := newMockDB(t)
+	api := newAuthedTestAPI(t, db, "zitadel-sub-5")
+
+	mock.ExpectQuery(`SELECT \* FROM "challenges"`).
+		WillReturnRows(sqlmock.NewRows(challengeCols()).
+			AddRow(int64(1), pub(1).String(), fixedTime, fixedTime, nil, int64(7), int64(1), "Jan", "", "sessions", "UTC",
+				fixedTime, fixedTime.AddDate(0, 1, 0), 48, nil))
+	mock.ExpectQuery(`SELECT \* FROM "users" WHERE zitadel_id`).
+		WillReturnRows(sqlmock.NewRows(userCols()).
+			AddRow(int64(5), pub(5).String(), fixedTime, fixedTime, nil, "zitadel-sub-5", "o@example.com", "Outsider", ""))
+	mock.ExpectQuery(`SELECT \* FROM "memberships" WHERE \(organization_id = \$1 AND user_id = \$2\)`).
+		WithArgs(int64(7), int64(5), 1).
+		WillReturnRows(sqlmock.NewRows(membershipCols()))
+
+	resp := api.Get("/api/v1/challenges/" + pub(1).String())
+
+	assert.Equal(t, http.StatusNotFound, resp.Code, resp.Body.String())
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestListChallenges_OnlyMembersSeeOrganizationChallenges(t *testing.T) {
+	db, mock := newMockDB(t)
+	api := newAuthedTestAPI(t, db, "zitadel-sub-5")
+
+	mock.ExpectQuery(`SELECT \* FROM "users" WHERE zitadel_id`).
+		WillReturnRows(sqlmock.NewRows(userCols()).
+			AddRow(int64(5), pub(5).String(), fixedTime, fixedTime, nil, "zitadel-sub-5", "o@example.com", "Outsider", ""))
+	mock.ExpectQuery(`SELECT \* FROM "challenges" WHERE \(organization_id IS NULL OR organization_id IN \(SELECT "organization_id" FROM "memberships" WHERE user_id = \$1 AND "memberships"."deleted_at" IS NULL\)\) AND "challenges"."deleted_at" IS NULL ORDER BY starts_at DESC`).
+		WithArgs(int64(5)).
+		WillReturnRows(sqlmock.NewRows(challengeCols()))
+
+	resp := api.Get("/api/v1/challenges")
+
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	assert.JSONEq(t, `[]`, resp.Body.String())
+	require.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,373 @@
+package backend_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/http"
+	"net/mail"
+	"strings"
+	"testing"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"workout-tracker/backend"
+	"workout-tracker/backend/email"
+	"workout-tracker/backend/events"
+	"workout-tracker/backend/notify"
+	"workout-tracker/backend/notify/smtptest"
+	"workout-tracker/backend/schemas"
+	"workout-tracker/backend/training"
+)
+
+// newCaptureMailer returns a Mailer sending through a fresh capture server.
+func newCaptureMailer(t *testing.T, signer *email.Signer) (*email.Mailer, *smtptest.Server, sqlmock.Sqlmock) {
+	t.Helper()
+	srv, err := smtptest.NewServer()
+	require.NoError(t, err)
+	t.Cleanup(srv.Close)
+
+	db, mock := newMockDB(t)
+	relay := &notify.SMTP{Addr: srv.Addr, From: mail.Address{Name: "Workout Tracker", Address: "noreply@example.com"}}
+	m, err := email.New(db, relay, signer, "https://app.example.com/")
+	require.NoError(t, err)
+	return m, srv, mock
+}
+
+func expectRecipient(mock sqlmock.Sqlmock) {
+	mock.ExpectQuery(`SELECT \* FROM "users" WHERE "users"."id" = \$1`).
+		WillReturnRows(sqlmock.NewRows(userCols()).
+			AddRow(7, pub(7).String(), fixedTime, fixedTime, nil, nil, "alice@example.com", "Alice", ""))
+}
+
+// parts returns the decoded bodies of a multipart/alternative message by
+// content type.
+func parts(t *testing.T, msg *mail.Message) map[string]string {
+	t.Helper()
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	require.NoError(t, err)
+	require.Equal(t, "multipart/alternative", mediaType)
+	out := map[string]string{}
+	r := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		p, err := r.NextRawPart()
+		if err == io.EOF {
+			return out
+		}
+		require.NoError(t, err)
+		require.Equal(t, "quoted-printable", p.Header.Get("Content-Transfer-Encoding"))
+		body, err := io.ReadAll(quotedprintable.NewReader(p))
+		require.NoError(t, err)
+		typ, _, _ := mime.ParseMediaType(p.Header.Get("Content-Type"))
+		out[typ] = string(body)
+	}
+}
+
+func TestMailer_DeliverRendersMultipartWithUnsubscribe(t *testing.T) {
+	signer := email.NewSigner("test-secret")
+	m, srv, mock := newCaptureMailer(t, signer)
+
+	expectRecipient(mock)
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "notification_preferences" WHERE user_id = \$1 AND category IN \(\$2,\$3\) AND NOT email`).
+		WithArgs(7, email.CategoryAchievements, email.CategoryAll).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+
+	err := m.Deliver(context.Background(), 7, email.TemplatePRAchieved, &email.PRAchievedData{
+		WorkoutID:   pub(3),
+		WorkoutName: "Sunday <long> run",
+		Records:     []email.Record{{Metric: email.MetricDistance, Value: 21100, Previous: 15000}},
+	})
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	msgs := srv.Messages()
+	require.Len(t, msgs, 1)
+	assert.Equal(t, "noreply@example.com", msgs[0].From)
+	assert.Equal(t, []string{"alice@example.com"}, msgs[0].To)
+
+	msg, err := msgs[0].Parse()
+	require.NoError(t, err)
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	require.NoError(t, err)
+	assert.Equal(t, "New personal record: Sunday <long> run", subject)
+	assert.Equal(t, "List-Unsubscribe=One-Click", msg.Header.Get("List-Unsubscribe-Post"))
+
+	link := strings.Trim(msg.Header.Get("List-Unsubscribe"), "<>")
+	require.True(t, strings.HasPrefix(link, "https://app.example.com/unsubscribe?token="), link)
+	userID, category, err := signer.Verify(strings.TrimPrefix(link, "https://app.example.com/unsubscribe?token="))
+	require.NoError(t, err)
+	assert.Equal(t, pub(7), userID)
+	assert.Equal(t, email.CategoryAchievements, category)
+
+	body := parts(t, msg)
+	assert.Contains(t, body["text/plain"], "Hi Alice,")
+	assert.Contains(t, body["text/plain"], "Longest distance: 21.10 km (previous best 15.00 km)")
+	assert.Contains(t, body["text/plain"], "Unsubscribe: "+link)
+	assert.Contains(t, body["text/html"], "Sunday &lt;long&gt; run")
+	assert.Contains(t, body["text/html"], `href="`+link+`"`)
+}
+
+func TestMailer_DeliverSkipsUnsubscribedCategory(t *testing.T) {
+	m, srv, mock := newCaptureMailer(t, email.NewSigner("test-secret"))
+
+	expectRecipient(mock)
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "notification_preferences"`).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+
+	err := m.Deliver(context.Background(), 7, email.TemplateWeeklySummary, &email.WeeklySummaryData{Workouts: 2})
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+	assert.Empty(t, srv.Messages())
+}
+
+func TestMailer_WelcomeIgnoresPreferences(t *testing.T) {
+	m, srv, mock := newCaptureMailer(t, email.NewSigner("test-secret"))
+
+	// Account mail is transactional: no preference lookup, no unsubscribe.
+	expectRecipient(mock)
+
+	require.NoError(t, m.Deliver(context.Background(), 7, email.TemplateWelcome, &email.WelcomeData{}))
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	msgs := srv.Messages()
+	require.Len(t, msgs, 1)
+	msg, err := msgs[0].Parse()
+	require.NoError(t, err)
+	assert.Equal(t, "Welcome to Workout Tracker", msg.Header.Get("Subject"))
+	assert.Empty(t, msg.Header.Get("List-Unsubscribe"))
+	body := parts(t, msg)
+	assert.NotContains(t, body["text/plain"], "Unsubscribe")
+	assert.Contains(t, body["text/plain"], "Get started: https://app.example.com")
+}
+
+func TestSigner_RejectsTamperedTokens(t *testing.T) {
+	s := email.NewSigner("test-secret")
+	token := s.Token(pub(42), email.CategoryWeeklySummary)
+
+	userID, category, err := s.Verify(token)
+	require.NoError(t, err)
+	assert.Equal(t, pub(42), userID)
+	assert.Equal(t, email.CategoryWeeklySummary, category)
+
+	forged := email.NewSigner("other-secret").Token(pub(43), email.CategoryWeeklySummary)
+	payload, _, _ := strings.Cut(forged, ".")
+	_, sig, _ := strings.Cut(token, ".")
+
+	for name, tok := range map[string]string{
+		"other key":         forged,
+		"swapped payload":   payload + "." + sig,
+		"missing signature": payload,
+		"garbage":           "not-a-token",
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, _, err := s.Verify(tok)
+			assert.ErrorIs(t, err, email.ErrInvalidToken)
+		})
+	}
+}
+
+func TestUnsubscribe_OneClick(t *testing.T) {
+	db, mock := newMockDB(t)
+	signer := email.NewSigner("test-secret")
+	api := newTestAPI(t, db, backend.WithUnsubscribeSigner(signer))
+
+	// The token names the user by public ID.
+	mock.ExpectQuery(`SELECT "id" FROM "users" WHERE public_id = \$1`).
+		WithArgs(pub(42), 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(int64(42)))
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO "notification_preferences" .* ON CONFLICT \("user_id","category"\) DO UPDATE SET "email"="excluded"."email","updated_at"="excluded"."updated_at"`).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), 42, email.CategoryWeeklySummary, false).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	resp := api.Post("/unsubscribe?token=" + signer.Token(pub(42), email.CategoryWeeklySummary))
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	var body schemas.UnsubscribeResponse
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &body))
+	assert.Equal(t, schemas.UnsubscribeResponse{Category: email.CategoryWeeklySummary, Unsubscribed: true}, body)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUnsubscribe_InvalidToken(t *testing.T) {
+	db, mock := newMockDB(t)
+	api := newTestAPI(t, db, backend.WithUnsubscribeSigner(email.NewSigner("test-secret")))
+
+	token := email.NewSigner("other-secret").Token(pub(42), email.CategoryAll)
+	resp := api.Get("/unsubscribe?token=" + token)
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestNotificationPreferences_GetDefaultsAndUpdate(t *testing.T) {
+	db, mock := newMockDB(t)
+	api := newTestAPI(t, db)
+
+	expectUserLookup(mock, 5)
+	mock.ExpectQuery(`SELECT \* FROM "notification_preferences" WHERE user_id = \$1`).
+		WithArgs(5).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "category", "email"}).
+			AddRow(1, 5, email.CategoryInvites, false))
+
+	resp := api.Get("/api/v1/me/notification-preferences?userId=" + pub(5).String())
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	var got schemas.NotificationPreferencesResponse
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &got))
+	assert.Equal(t, map[string]bool{
+		email.CategoryWeeklySummary: true,
+		email.CategoryAchievements:  true,
+		email.CategoryInvites:       false,
+		email.CategoryReminders:     true,
+		email.CategoryAll:           true,
+	}, got.Email)
+
+	expectUserLookup(mock, 5)
+	resp = api.Put("/api/v1/me/notification-preferences", map[string]any{
+		"user_id": pub(5),
+		"email":   map[string]bool{"marketing": false},
+	})
+	assert.Equal(t, http.StatusUnprocessableEntity, resp.Code)
+
+	expectUserLookup(mock, 5)
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO "notification_preferences"`).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), 5, email.CategoryAchievements, false).
+		WillReturnResult(sqlmock.NewResult(2, 1))
+	mock.ExpectExec(`INSERT INTO "notification_preferences"`).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), 5, email.CategoryInvites, true).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	mock.ExpectQuery(`SELECT \* FROM "notification_preferences" WHERE user_id = \$1`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "category", "email"}).
+			AddRow(1, 5, email.CategoryInvites, true).
+			AddRow(2, 5, email.CategoryAchievements, false))
+
+	resp = api.Put("/api/v1/me/notification-preferences", map[string]any{
+		"user_id": pub(5),
+		"email":   map[string]bool{email.CategoryInvites: true, email.CategoryAchievements: false},
+	})
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &got))
+	assert.False(t, got.Email[email.CategoryAchievements])
+	assert.True(t, got.Email[email.CategoryInvites])
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMailer_HandleEventQueuesPersonalRecord(t *testing.T) {
+	db, mock := newMockDB(t)
+	m, err := email.New(db, email.LogSender{}, email.NewSigner("test-secret"), "")
+	require.NoError(t, err)
+
+	workoutCreated := func(id, userID int64, w schemas.WorkoutResponse) events.Event {
+		w.ID, w.UserID = pub(id), pub(userID)
+		payload, err := json.Marshal(w)
+		require.NoError(t, err)
+		return events.Event{Type: events.TypeWorkoutCreated, ResourceType: events.ResourceWorkout,
+			ResourceID: id, ResourcePublicID: w.ID, UserID: userID, Payload: payload}
+	}
+	bestQuery := `SELECT COUNT\(\*\) AS n, COALESCE\(MAX\(duration_minutes\), 0\) AS max_duration, ` +
+		`COALESCE\(MAX\(distance_meters\), 0\) AS max_distance FROM "workouts" ` +
+		`WHERE \(user_id = \$1 AND id <> \$2\) AND "workouts"."deleted_at" IS NULL`
+
+	// Longer than any previous workout, but not further.
+	mock.ExpectQuery(bestQuery).
+		WithArgs(7, 9).
+		WillReturnRows(sqlmock.NewRows([]string{"n", "max_duration", "max_distance"}).AddRow(4, 60, 10000))
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO "jobs"`).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	require.NoError(t, m.HandleEvent(context.Background(), db,
+		workoutCreated(9, 7, schemas.WorkoutResponse{Name: "Tempo", DurationMinutes: 95, DistanceMeters: 8000})))
+
+	// A first workout sets no records.
+	mock.ExpectQuery(bestQuery).
+		WithArgs(8, 10).
+		WillReturnRows(sqlmock.NewRows([]string{"n", "max_duration", "max_distance"}).AddRow(0, 0, 0))
+	require.NoError(t, m.HandleEvent(context.Background(), db,
+		workoutCreated(10, 8, schemas.WorkoutResponse{Name: "First", DurationMinutes: 30})))
+
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	records, err := func() ([]email.Record, error) {
+		mock.ExpectQuery(bestQuery).
+			WillReturnRows(sqlmock.NewRows([]string{"n", "max_duration", "max_distance"}).AddRow(2, 60, 10000))
+		return email.PersonalRecords(db, 7, 11, schemas.WorkoutResponse{DurationMinutes: 61, DistanceMeters: 12000})
+	}()
+	require.NoError(t, err)
+	assert.Equal(t, []email.Record{
+		{Metric: email.MetricDuration, Value: 61, Previous: 60},
+		{Metric: email.MetricDistance, Value: 12000, Previous: 10000},
+	}, records)
+}
+
+func TestPersonalRecords_LiftsIgnoreWarmups(t *testing.T) {
+	db, mock := newMockDB(t)
+	reps := 5
+	kg := func(v float64) *float64 { return &v }
+	w := schemas.WorkoutResponse{DurationMinutes: 45, Exercises: []training.Exercise{
+		// The warm-up is heavier than the previous best; the working sets
+		// are not.
+		{Name: "Back squat", Sets: []training.Set{
+			{Reps: &reps, WeightKg: kg(150), Warmup: true},
+			{Reps: &reps, WeightKg: kg(95)},
+		}},
+		{Name: "Deadlift", Sets: []training.Set{
+			{Reps: &reps, WeightKg: kg(60), Warmup: true},
+			{Reps: &reps, WeightKg: kg(142.5)},
+		}},
+		// First time benched: no record.
+		{Name: "Bench press", Sets: []training.Set{{Reps: &reps, WeightKg: kg(80)}}},
+	}}
+
+	mock.ExpectQuery(`SELECT COUNT\(\*\) AS n`).
+		WillReturnRows(sqlmock.NewRows([]string{"n", "max_duration", "max_distance"}).AddRow(3, 60, 0))
+	mock.ExpectQuery(`SELECT lower\(e->>'name'\) AS exercise, MAX\(\(s->>'weight_kg'\)::float8\) AS best `+
+		`FROM workouts w, jsonb_array_elements\(w.exercises\) e, jsonb_array_elements\(e->'sets'\) s `+
+		`WHERE w.user_id = \$1 AND w.id <> \$2 AND w.deleted_at IS NULL AND lower\(e->>'name'\) IN \(\$3,\$4,\$5\) `+
+		`AND s->>'weight_kg' IS NOT NULL AND NOT COALESCE\(\(s->>'warmup'\)::boolean, false\) `+
+		`GROUP BY lower\(e->>'name'\)`).
+		WithArgs(7, 11, "back squat", "bench press", "deadlift").
+		WillReturnRows(sqlmock.NewRows([]string{"exercise", "best"}).
+			AddRow("back squat", 100.0).
+			AddRow("deadlift", 140.0))
+
+	records, err := email.PersonalRecords(db, 7, 11, w)
+
+	require.NoError(t, err)
+	assert.Equal(t, []email.Record{
+		{Metric: email.MetricWeight, Exercise: "Deadlift", Value: 142.5, Previous: 140},
+	}, records)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAddMember_CoachQueuesInvite(t *testing.T) {
+	db, mock := newMockDB(t)
+	api := newTestAPI(t, db)
+
+	mock.ExpectQuery(`SELECT \* FROM "organizations" WHERE public_id = \$1`).
+		WithArgs(pub(3), 1).
+		WillReturnRows(sqlmock.NewRows(organizationCols()).AddRow(3, pub(3).String(), fixedTime, fixedTime, nil, nil, "Harriers"))
+	mock.ExpectQuery(`SELECT \* FROM "users" WHERE public_id = \$1`).
+		WithArgs(pub(7), 1).
+		WillReturnRows(sqlmock.NewRows(userCols()).AddRow(7, pub(7).String(), fixedTime, fixedTime, nil, nil, "alice@example.com", "Alice", ""))
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "memberships"`).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO "memberships"`).WillReturnResult(sqlmock.NewResult(11, 1))
+	mock.ExpectExec(`INSERT INTO "jobs"`).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), email.Queue, email.KindSend,
+			jsonArg(`{"user_id": 7, "template": "coach_invite", "data": {"organization_id": "`+pub(3).String()+`", "organization_name": "Harriers"}}`),
+			sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(),
+			sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	resp := api.Post("/api/v1/organizations/"+pub(3).String()+"/members", map[string]any{"user_id": pub(7), "role": "coach"})
+	require.Equal(t, http.StatusCreated, resp.Code, resp.Body.String())
+	require.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,121 @@
+package backend_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"workout-tracker/backend/schemas"
+)
+
+// expectVersionedWorkout mocks loading workout 4 at version 3.
+func expectVersionedWorkout(mock sqlmock.Sqlmock) {
+	mock.ExpectQuery(`SELECT \* FROM "workouts" WHERE public_id = \$1`).
+		WithArgs(pub(4), 1).
+		WillReturnRows(sqlmock.NewRows(append(workoutCols(), "version")).
+			AddRow(int64(4), pub(4).String(), fixedTime, fixedTime, nil, int64(1), "Tempo run", "", 50, int64(3)))
+}
+
+func TestGetWorkout_ReturnsETag(t *testing.T) {
+	db, mock := newMockDB(t)
+	api := newTestAPI(t, db)
+
+	expectVersionedWorkout(mock)
+	expectPublicIDs(mock, "users", 1)
+	resp := api.Get("/api/v1/workouts/" + pub(4).String())
+
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	assert.Equal(t, `"3"`, resp.Header().Get("ETag"))
+	var got schemas.WorkoutResponse
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &got))
+	assert.Equal(t, int64(3), got.Version)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetWorkout_IfNoneMatchNotModified(t *testing.T) {
+	db, mock := newMockDB(t)
+	api := newTestAPI(t, db)
+
+	expectVersionedWorkout(mock)
+	resp := api.Get("/api/v1/workouts/"+pub(4).String(), `If-None-Match: "3"`)
+
+	assert.Equal(t, http.StatusNotModified, resp.Code)
+	assert.Equal(t, `"3"`, resp.Header().Get("ETag"))
+
+	// A stale copy gets the full representation.
+	expectVersionedWorkout(mock)
+	expectPublicIDs(mock, "users", 1)
+	resp = api.Get("/api/v1/workouts/"+pub(4).String(), `If-None-Match: "2"`)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpdateWorkout_IfMatchMismatch(t *testing.T) {
+	db, mock := newMockDB(t)
+	api := newTestAPI(t, db)
+
+	expectVersionedWorkout(mock)
+	resp := api.Patch("/api/v1/workouts/"+pub(4).String(), `If-Match: "2"`, map[string]any{"name": "Intervals"})
+
+	assert.Equal(t, http.StatusPreconditionFailed, resp.Code)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpdateWorkout_IfMatchBumpsVersion(t *testing.T) {
+	db, mock := newMockDB(t)
+	api := newTestAPI(t, db)
+
+	expectVersionedWorkout(mock)
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "workouts" SET .*"version"=\$5,.* WHERE version = \$17 AND "workouts"."deleted_at" IS NULL AND "id" = \$18`).
+		WithArgs(pub(4), fixedTime, sqlmock.AnyArg(), nil, int64(4), int64(1), "Intervals", "", 50, 0, sqlmock.AnyArg(), "private", "[]", "{}", "[]", "[]", int64(3), int64(4)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectRevision(mock, 1, 3)
+	mock.ExpectCommit()
+
+	resp := api.Patch("/api/v1/workouts/"+pub(4).String(), `If-Match: "3"`, map[string]any{"name": "Intervals"})
+
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	assert.Equal(t, `"4"`, resp.Header().Get("ETag"))
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpdateWorkout_ConcurrentWriteIsNotClobbered(t *testing.T) {
+	db, mock := newMockDB(t)
+	api := newTestAPI(t, db)
+
+	expectVersionedWorkout(mock)
+	mock.ExpectBegin()
+	// Another request bumped the version between the read and this write.
+	mock.ExpectExec(`UPDATE "workouts" SET .* WHERE version = \$\d+`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	resp := api.Patch("/api/v1/workouts/"+pub(4).String(), map[string]any{"name": "Intervals"})
+
+	assert.Equal(t, http.StatusPreconditionFailed, resp.Code)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDeleteWorkout_IfMatch(t *testing.T) {
+	db, mock := newMockDB(t)
+	api := newTestAPI(t, db)
+
+	expectVersionedWorkout(mock)
+	resp := api.Delete("/api/v1/workouts/"+pub(4).String(), `If-Match: "1"`)
+	assert.Equal(t, http.StatusPreconditionFailed, resp.Code)
+
+	expectVersionedWorkout(mock)
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "workouts" SET "deleted_at"=\$1 WHERE version = \$2 AND "workouts"."id" = \$3`).
+		WithArgs(sqlmock.AnyArg(), 3, 4).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	resp = api.Delete("/api/v1/workouts/"+pub(4).String(), `If-Match: "3"`)
+	assert.Equal(t, http.StatusNoContent, resp.Code, resp.Body.String())
+	require.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,342 @@
+package backend_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"workout-tracker/backend"
+	"workout-tracker/backend/events"
+	"workout-tracker/backend/models"
+	"workout-tracker/backend/schemas"
+
+	"gorm.io/gorm"
+)
+
+// closedBus is a Bus whose subscriptions end immediately, so an event stream
+// returns as soon as it has replayed the log.
+type closedBus struct{}
+
+func (closedBus) Publish(events.Event) {}
+
+func (closedBus) Subscribe() (<-chan events.Event, func()) {
+	ch := make(chan events.Event)
+	close(ch)
+	return ch, func() {}
+}
+
+// scriptedBus is a Bus whose subscriptions deliver a fixed list of events
+// and then end.
+type scriptedBus []events.Event
+
+func (scriptedBus) Publish(events.Event) {}
+
+func (b scriptedBus) Subscribe() (<-chan events.Event, func()) {
+	ch := make(chan events.Event, len(b))
+	for _, e := range b {
+		ch <- e
+	}
+	close(ch)
+	return ch, func() {}
+}
+
+func eventCols() []string {
+	return []string{"id", "created_at", "type", "resource_type", "resource_id", "resource_public_id", "user_id", "visibility", "payload"}
+}
+
+// newRecordingDB returns a mock database with the change-data callbacks
+// publishing on an in-memory bus.
+func newRecordingDB(t *testing.T) (*gorm.DB, sqlmock.Sqlmock, *events.MemoryBus) {
+	t.Helper()
+	db, mock := newMockDB(t)
+	bus := events.NewMemoryBus()
+	require.NoError(t, events.RegisterCallbacks(db, bus))
+	return db, mock, bus
+}
+
+func TestCreateWorkout_PublishesEventAfterCommit(t *testing.T) {
+	db, mock, bus := newRecordingDB(t)
+	api := newTestAPI(t, db, backend.WithEventBus(bus))
+	sub, unsubscribe := bus.Subscribe()
+	defer unsubscribe()
+
+	expectUserLookup(mock, 1)
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO "workouts"`).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	expectOwner(mock, 1)
+	mock.ExpectExec(`INSERT INTO "events"`).
+		WillReturnResult(sqlmock.NewResult(7, 1))
+	expectRevision(mock, 1, 0)
+	mock.ExpectCommit()
+
+	resp := api.Post("/api/v1/workouts", map[string]any{
+		"id":               pub(8),
+		"user_id":          pub(1),
+		"name":             "Morning Run",
+		"duration_minutes": 30,
+		"visibility":       "public",
+	})
+	require.Equal(t, http.StatusCreated, resp.Code)
+
+	select {
+	case e := <-sub:
+		assert.Equal(t, int64(7), e.ID)
+		assert.Equal(t, events.TypeWorkoutCreated, e.Type)
+		assert.Equal(t, int64(1), e.UserID)
+		assert.Equal(t, pub(8), e.ResourcePublicID)
+		assert.Equal(t, "public", e.Visibility)
+		var w schemas.WorkoutResponse
+		require.NoError(t, json.Unmarshal(e.Payload, &w))
+		assert.Equal(t, pub(8), w.ID)
+		assert.Equal(t, pub(1), w.UserID)
+		assert.Equal(t, "Morning Run", w.Name)
+	case <-time.After(time.Second):
+		t.Fatal("no event published")
+	}
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCreateWorkout_NoEventOnRollback(t *testing.T) {
+	db, mock, bus := newRecordingDB(t)
+	api := newTestAPI(t, db, backend.WithEventBus(bus))
+	sub, unsubscribe := bus.Subscribe()
+	defer unsubscribe()
+
+	expectUserLookup(mock, 1)
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO "workouts"`).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	expectOwner(mock, 1)
+	mock.ExpectExec(`INSERT INTO "events"`).
+		WillReturnError(assert.AnError)
+	mock.ExpectRollback()
+
+	resp := api.Post("/api/v1/workouts", map[string]any{"user_id": pub(1), "name": "Ghost", "duration_minutes": 5})
+
+	assert.Equal(t, http.StatusInternalServerError, resp.Code)
+	assert.Empty(t, sub)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCreateWorkout_NoEventWhenLaterStepRollsBack(t *testing.T) {
+	db, mock, bus := newRecordingDB(t)
+	api := newTestAPI(t, db, backend.WithEventBus(bus))
+	sub, unsubscribe := bus.Subscribe()
+	defer unsubscribe()
+
+	// The workout and its event are written, but the handler's transaction
+	// fails afterwards.
+	expectUserLookup(mock, 1)
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO "workouts"`).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	expectOwner(mock, 1)
+	mock.ExpectExec(`INSERT INTO "events"`).
+		WillReturnResult(sqlmock.NewResult(7, 1))
+	expectPublicIDs(mock, "users", 1)
+	mock.ExpectQuery(`SELECT COALESCE\(MAX\(revision\), 0\) FROM "workout_revisions"`).
+		WillReturnError(assert.AnError)
+	mock.ExpectRollback()
+
+	resp := api.Post("/api/v1/workouts", map[string]any{"user_id": pub(1), "name": "Ghost", "duration_minutes": 5})
+
+	assert.Equal(t, http.StatusInternalServerError, resp.Code)
+	assert.Empty(t, sub)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRegisterCallbacks_DropsEventsOfRolledBackSavepoint(t *testing.T) {
+	db, mock, bus := newRecordingDB(t)
+	sub, unsubscribe := bus.Subscribe()
+	defer unsubscribe()
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO "users"`).WillReturnResult(sqlmock.NewResult(3, 1))
+	mock.ExpectExec(`INSERT INTO "events"`).WillReturnResult(sqlmock.NewResult(10, 1))
+	mock.ExpectExec(`SAVEPOINT sp`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`INSERT INTO "users"`).WillReturnResult(sqlmock.NewResult(4, 1))
+	mock.ExpectExec(`INSERT INTO "events"`).WillReturnResult(sqlmock.NewResult(11, 1))
+	mock.ExpectExec(`ROLLBACK TO SAVEPOINT sp`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&models.User{BaseModel: models.BaseModel{PublicID: pub(3)}, Email: "ada@example.com", Name: "Ada"}).Error; err != nil {
+			return err
+		}
+		require.NoError(t, tx.SavePoint("sp").Error)
+		if err := tx.Create(&models.User{BaseModel: models.BaseModel{PublicID: pub(4)}, Email: "bob@example.com", Name: "Bob"}).Error; err != nil {
+			return err
+		}
+		require.NoError(t, tx.RollbackTo("sp").Error)
+		// Nothing is published before the commit.
+		assert.Empty(t, sub)
+		return nil
+	})
+
+	require.NoError(t, err)
+	require.Len(t, sub, 1)
+	assert.Equal(t, int64(10), (<-sub).ID)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDeleteWorkout_LogsDeletedEvent(t *testing.T) {
+	db, mock, bus := newRecordingDB(t)
+	api := newTestAPI(t, db, backend.WithEventBus(bus))
+	sub, unsubscribe := bus.Subscribe()
+	defer unsubscribe()
+
+	mock.ExpectQuery(`SELECT \* FROM "workouts"`).
+		WillReturnRows(sqlmock.NewRows(append(workoutCols(), "visibility")).
+			AddRow(int64(4), pub(4).String(), fixedTime, fixedTime, nil, int64(2), "Swim", "", 40, "followers"))
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "workouts" SET "deleted_at"`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectOwner(mock, 2)
+	mock.ExpectExec(`INSERT INTO "events"`).
+		WithArgs(sqlmock.AnyArg(), events.TypeWorkoutDeleted, events.ResourceWorkout, int64(4), pub(4), int64(2), "followers", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(9, 1))
+	mock.ExpectCommit()
+
+	resp := api.Delete("/api/v1/workouts/" + pub(4).String())
+
+	require.Equal(t, http.StatusNoContent, resp.Code)
+	e := <-sub
+	assert.Equal(t, events.TypeWorkoutDeleted, e.Type)
+	assert.Equal(t, int64(4), e.ResourceID)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCreateUser_LogsUserEvent(t *testing.T) {
+	db, mock, bus := newRecordingDB(t)
+	api := newTestAPI(t, db, backend.WithEventBus(bus))
+	sub, unsubscribe := bus.Subscribe()
+	defer unsubscribe()
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO "users"`).
+		WillReturnResult(sqlmock.NewResult(3, 1))
+	mock.ExpectExec(`INSERT INTO "events"`).
+		WithArgs(sqlmock.AnyArg(), events.TypeUserCreated, events.ResourceUser, int64(3), pub(3), int64(3), "private", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(10, 1))
+	mock.ExpectCommit()
+
+	resp := api.Post("/api/v1/users", map[string]any{"id": pub(3), "email": "ada@example.com", "name": "Ada", "password": "hunter22"})
+
+	require.Equal(t, http.StatusCreated, resp.Code, resp.Body.String())
+	e := <-sub
+	assert.Equal(t, events.TypeUserCreated, e.Type)
+	assert.NotContains(t, string(e.Payload), "password")
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPGBus_PublishNotifies(t *testing.T) {
+	db, mock := newMockDB(t)
+	bus := events.NewPGBus(db, "")
+
+	mock.ExpectExec(`SELECT pg_notify\(\$1, \$2\)`).
+		WithArgs(events.Channel, "42").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	bus.Publish(events.Event{ID: 42})
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPGBus_CatchUpDeliversEachEventOnce(t *testing.T) {
+	db, mock := newMockDB(t)
+	bus := events.NewPGBus(db, "")
+	sub, unsubscribe := bus.Subscribe()
+	defer unsubscribe()
+
+	// The first catch-up only notes where the log ends.
+	mock.ExpectQuery(`SELECT COALESCE\(MAX\(id\), 0\) FROM events`).
+		WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(int64(20)))
+	mock.ExpectQuery(`SELECT \* FROM "events" WHERE id > \$1 ORDER BY id`).
+		WithArgs(int64(20), 500).
+		WillReturnRows(sqlmock.NewRows(eventCols()).
+			AddRow(int64(21), fixedTime, "workout.created", "workout", int64(1), pub(1).String(), int64(1), "private", []byte(`{}`)).
+			AddRow(int64(23), fixedTime, "workout.created", "workout", int64(2), pub(2).String(), int64(1), "private", []byte(`{}`)))
+	// 22 commits late; the lookback window still finds it and 21/23 are not
+	// delivered twice.
+	mock.ExpectQuery(`SELECT \* FROM "events" WHERE id > \$1 ORDER BY id`).
+		WithArgs(int64(20), 500).
+		WillReturnRows(sqlmock.NewRows(eventCols()).
+			AddRow(int64(21), fixedTime, "workout.created", "workout", int64(1), pub(1).String(), int64(1), "private", []byte(`{}`)).
+			AddRow(int64(22), fixedTime, "workout.updated", "workout", int64(3), pub(3).String(), int64(1), "private", []byte(`{}`)).
+			AddRow(int64(23), fixedTime, "workout.created", "workout", int64(2), pub(2).String(), int64(1), "private", []byte(`{}`)))
+
+	require.NoError(t, bus.CatchUp())
+	require.NoError(t, bus.CatchUp())
+	require.NoError(t, bus.CatchUp())
+
+	var got []int64
+	for len(sub) > 0 {
+		got = append(got, (<-sub).ID)
+	}
+	assert.Equal(t, []int64{21, 23, 22}, got)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestStreamEvents_ResumesFromLastEventID(t *testing.T) {
+	db, mock := newMockDB(t)
+	api := newTestAPI(t, db, backend.WithEventBus(closedBus{}))
+
+	payload := func(name string) []byte { return []byte(`{"name":"` + name + `"}`) }
+	expectUserLookup(mock, 1)
+	mock.ExpectQuery(`SELECT \* FROM "events" WHERE id > \$1 ORDER BY id`).
+		WithArgs(int64(5), 500).
+		WillReturnRows(sqlmock.NewRows(eventCols()).
+			AddRow(int64(6), fixedTime, "workout.created", "workout", int64(10), pub(10).String(), int64(1), "private", payload("Mine")).
+			AddRow(int64(7), fixedTime, "workout.created", "workout", int64(11), pub(11).String(), int64(2), "private", payload("Hidden")).
+			AddRow(int64(8), fixedTime, "workout.updated", "workout", int64(12), pub(12).String(), int64(3), "followers", payload("Followed")))
+	// Event 8 is followers-only, so the viewer's follow is checked.
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "follows"`).
+		WithArgs(int64(1), int64(3)).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+
+	resp := api.Get("/api/v1/events?userId="+pub(1).String(), "Last-Event-ID: 5")
+
+	require.Equal(t, http.StatusOK, resp.Code)
+	body := resp.Body.String()
+	assert.Contains(t, body, "id: 6\nevent: workout.created\n")
+	assert.Contains(t, body, "id: 8\nevent: workout.updated\n")
+	assert.NotContains(t, body, "Hidden")
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestStreamEvents_DeliversLateCommittedEvents(t *testing.T) {
+	db, mock := newMockDB(t)
+	workoutEvent := func(id int64) events.Event {
+		return events.Event{ID: id, Type: events.TypeWorkoutCreated, ResourceType: events.ResourceWorkout,
+			ResourcePublicID: pub(id), UserID: 1, Visibility: "private", Payload: []byte(`{}`)}
+	}
+	// 23 is both replayed and published live; 22 commits after the replay
+	// has read past it.
+	api := newTestAPI(t, db, backend.WithEventBus(scriptedBus{workoutEvent(23), workoutEvent(22)}))
+
+	expectUserLookup(mock, 1)
+	mock.ExpectQuery(`SELECT \* FROM "events" WHERE id > \$1 ORDER BY id`).
+		WithArgs(int64(20), 500).
+		WillReturnRows(sqlmock.NewRows(eventCols()).
+			AddRow(int64(21), fixedTime, "workout.created", "workout", int64(1), pub(21).String(), int64(1), "private", []byte(`{}`)).
+			AddRow(int64(23), fixedTime, "workout.created", "workout", int64(2), pub(23).String(), int64(1), "private", []byte(`{}`)))
+
+	resp := api.Get("/api/v1/events?userId="+pub(1).String(), "Last-Event-ID: 20")
+
+	require.Equal(t, http.StatusOK, resp.Code)
+	var got []string
+	for _, line := range strings.Split(resp.Body.String(), "\n") {
+		if id, ok := strings.CutPrefix(line, "id: "); ok {
+			got = append(got, id)
+		}
+	}
+	assert.Equal(t, []string{"21", "23", "22"}, got)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,180 @@
+package backend_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"workout-tracker/backend/schemas"
+	"workout-tracker/backend/search"
+	"workout-tracker/backend/training"
+)
+
+func TestTrainingSum_LeavesOutWarmups(t *testing.T) {
+	reps, light, heavy := 5, 60.0, 100.0
+	got := training.Sum([]training.Exercise{
+		{Name: "Squat", Sets: []training.Set{
+			{Reps: &reps, WeightKg: &light, Warmup: true},
+			{Reps: &reps, WeightKg: &heavy},
+			{Reps: &reps, WeightKg: &heavy, Failure: true},
+		}},
+		{Name: "Plank", Sets: []training.Set{{DurationSeconds: &reps}}},
+	})
+	assert.Equal(t, training.Totals{WorkingSets: 3, VolumeKg: 1000}, got)
+}
+
+func TestCreateWorkout_WithExercises(t *testing.T) {
+	db, mock := newMockDB(t)
+	api := newTestAPI(t, db)
+
+	expectUserLookup(mock, 1)
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO "workouts" \(.*"exercises","groups"\)`).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), nil, int64(1), int64(1), "Legs", "", 60, 0,
+			"private", sqlmock.AnyArg(), "[]", "{}", sqlmock.AnyArg(), "[]").
+		WillReturnResult(sqlmock.NewResult(7, 1))
+	expectRevision(mock, 1, 0)
+	mock.ExpectCommit()
+
+	resp := api.Post("/api/v1/workouts", map[string]any{
+		"user_id":          pub(1),
+		"name":             "Legs",
+		"duration_minutes": 60,
+		"exercises": []map[string]any{{
+			"id":    pub(30),
+			"name":  " Back squat ",
+			"notes": "Belt from the second working set",
+			"sets": []map[string]any{
+				{"reps": 5, "weight_kg": 60, "warmup": true},
+				{"reps": 5, "weight_kg": 100, "rpe": 7.5, "tempo": "3-1-x-0"},
+				{"reps": 8, "weight_kg": 80, "drop_set": true, "failure": true, "notes": " lost depth on the last rep "},
+			},
+		}, {
+			"name": "Plank",
+			"sets": []map[string]any{{"duration_seconds": 60, "rir": 0}},
+		}},
+	})
+
+	require.Equal(t, http.StatusCreated, resp.Code, resp.Body.String())
+	var body schemas.WorkoutResponse
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &body))
+	require.Len(t, body.Exercises, 2)
+	squat := body.Exercises[0]
+	assert.Equal(t, pub(30), squat.ID)
+	assert.Equal(t, "Back squat", squat.Name)
+	require.Len(t, squat.Sets, 3)
+	assert.True(t, squat.Sets[0].Warmup)
+	assert.Equal(t, "3-1-X-0", squat.Sets[1].Tempo)
+	require.NotNil(t, squat.Sets[1].RPE)
+	assert.Equal(t, 7.5, *squat.Sets[1].RPE)
+	assert.Equal(t, "lost depth on the last rep", squat.Sets[2].Notes)
+	// Exercises without an ID are given one.
+	assert.Equal(t, uuid.Version(7), body.Exercises[1].ID.Version())
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCreateWorkout_RejectsInvalidSets(t *testing.T) {
+	tests := map[string]map[string]any{
+		"RPE and RIR":         {"reps": 5, "rpe": 8, "rir": 2},
+		"warm-up to failure":  {"reps": 5, "warmup": true, "failure": true},
+		"first set dropped":   {"reps": 5, "drop_set": true},
+		"no reps or duration": {"weight_kg": 100},
+	}
+	for name, set := range tests {
+		t.Run(name, func(t *testing.T) {
+			db, mock := newMockDB(t)
+			api := newTestAPI(t, db)
+
+			expectUserLookup(mock, 1)
+			mock.ExpectBegin()
+			mock.ExpectRollback()
+
+			resp := api.Post("/api/v1/workouts", map[string]any{
+				"user_id":          pub(1),
+				"name":             "Legs",
+				"duration_minutes": 60,
+				"exercises":        []map[string]any{{"name": "Squat", "sets": []map[string]any{set}}},
+			})
+
+			assert.Equal(t, http.StatusUnprocessableEntity, resp.Code, resp.Body.String())
+			assert.Contains(t, resp.Body.String(), "exercise 1 (Squat), set 1")
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestCreateWorkout_RejectsMalformedExercises(t *testing.T) {
+	tests := map[string]map[string]any{
+		"tempo":      {"name": "Squat", "sets": []map[string]any{{"reps": 5, "tempo": "slow"}}},
+		"RPE step":   {"name": "Squat", "sets": []map[string]any{{"reps": 5, "rpe": 7.3}}},
+		"RIR range":  {"name": "Squat", "sets": []map[string]any{{"reps": 5, "rir": 11}}},
+		"empty name": {"name": "", "sets": []map[string]any{}},
+	}
+	for name, exercise := range tests {
+		t.Run(name, func(t *testing.T) {
+			db, mock := newMockDB(t)
+			api := newTestAPI(t, db)
+
+			resp := api.Post("/api/v1/workouts", map[string]any{
+				"user_id":          pub(1),
+				"name":             "Legs",
+				"duration_minutes": 60,
+				"exercises":        []map[string]any{exercise},
+			})
+
+			assert.Equal(t, http.StatusUnprocessableEntity, resp.Code, resp.Body.String())
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestCreateWorkout_RejectsDuplicateExerciseIDs(t *testing.T) {
+	db, mock := newMockDB(t)
+	api := newTestAPI(t, db)
+
+	expectUserLookup(mock, 1)
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+
+	resp := api.Post("/api/v1/workouts", map[string]any{
+		"user_id":          pub(1),
+		"name":             "Legs",
+		"duration_minutes": 60,
+		"exercises": []map[string]any{
+			{"id": pub(30), "name": "Squat", "sets": []map[string]any{}},
+			{"id": pub(30), "name": "Lunge", "sets": []map[string]any{}},
+		},
+	})
+
+	assert.Equal(t, http.StatusUnprocessableEntity, resp.Code, resp.Body.String())
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSearch_FindsExercises(t *testing.T) {
+	db, mock := newMockDB(t)
+	api := newTestAPI(t, db)
+
+	mock.ExpectQuery(`WITH q AS .* SELECT 'exercise' AS kind, \(x.e->>'id'\)::uuid AS id, w.public_id AS workout_id, x.name AS title, .*` +
+		`jsonb_array_elements\(w.exercises\) e\) x, .* WHERE w.exercise_search_vector @@ q.query AND v.vector @@ q.query`).
+		WillReturnRows(sqlmock.NewRows(searchCols()).
+			AddRow(search.KindExercise, pub(30).String(), pub(4).String(), "Pause squat", fixedTime, 0.5,
+				"Pause squat", "two seconds in the hole"))
+
+	resp := api.Get("/api/v1/search?q=pause")
+
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	var page schemas.SearchPage
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &page))
+	require.Len(t, page.Items, 1)
+	got := page.Items[0]
+	assert.Equal(t, search.KindExercise, got.Kind)
+	assert.Equal(t, pub(30), got.ID)
+	assert.Equal(t, pub(4), got.WorkoutID)
+	assert.Equal(t, []schemas.HighlightFragment{{Text: "two seconds in the hole"}}, got.Snippet)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
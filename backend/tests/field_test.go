@@ -0,0 +1,206 @@
+package backend_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"workout-tracker/backend/schemas"
+)
+
+func customFieldCols() []string {
+	return []string{"id", "public_id", "created_at", "updated_at", "deleted_at", "version",
+		"user_id", "key", "name", "type", "options", "unit"}
+}
+
+// expectCustomFields mocks loading user 1's sleep_hours and mood fields.
+func expectCustomFields(mock sqlmock.Sqlmock) {
+	mock.ExpectQuery(`SELECT \* FROM "custom_fields" WHERE user_id = \$1 AND "custom_fields"."deleted_at" IS NULL`).
+		WithArgs(int64(1)).
+		WillReturnRows(sqlmock.NewRows(customFieldCols()).
+			AddRow(int64(1), pub(1).String(), fixedTime, fixedTime, nil, 1, int64(1), "sleep_hours", "Sleep", "number", nil, "h").
+			AddRow(int64(2), pub(2).String(), fixedTime, fixedTime, nil, 1, int64(1), "mood", "Mood", "enum", `["good","meh","bad"]`, ""))
+}
+
+func TestCreateWorkout_NormalizesTagsAndChecksCustomFields(t *testing.T) {
+	db, mock := newMockDB(t)
+	api := newTestAPI(t, db)
+
+	expectUserLookup(mock, 1)
+	mock.ExpectBegin()
+	expectCustomFields(mock)
+	mock.ExpectExec(`INSERT INTO "workouts" \(.*"tags","custom_fields","exercises","groups"\)`).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), nil, int64(1), int64(1), "Hotel gym", "", 40, 0,
+			"private", sqlmock.AnyArg(), `["deload","travel"]`, `{"mood":"meh","sleep_hours":6.5}`, "[]", "[]").
+		WillReturnResult(sqlmock.NewResult(7, 1))
+	expectRevision(mock, 1, 0)
+	mock.ExpectCommit()
+
+	resp := api.Post("/api/v1/workouts", map[string]any{
+		"user_id":          pub(1),
+		"name":             "Hotel gym",
+		"duration_minutes": 40,
+		"tags":             []string{"Travel ", "deload", "DELOAD"},
+		"custom_fields":    map[string]any{"sleep_hours": 6.5, "mood": "meh"},
+	})
+
+	require.Equal(t, http.StatusCreated, resp.Code, resp.Body.String())
+	var body schemas.WorkoutResponse
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &body))
+	assert.Equal(t, []string{"deload", "travel"}, body.Tags)
+	assert.Equal(t, map[string]any{"sleep_hours": 6.5, "mood": "meh"}, body.CustomFields)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCreateWorkout_RejectsInvalidCustomFields(t *testing.T) {
+	tests := map[string]map[string]any{
+		"unknown field":  {"weather": "sunny"},
+		"wrong type":     {"sleep_hours": "seven"},
+		"not an option":  {"mood": "great"},
+		"enum as number": {"mood": 3},
+	}
+	for name, fields := range tests {
+		t.Run(name, func(t *testing.T) {
+			db, mock := newMockDB(t)
+			api := newTestAPI(t, db)
+
+			expectUserLookup(mock, 1)
+			mock.ExpectBegin()
+			expectCustomFields(mock)
+			mock.ExpectRollback()
+
+			resp := api.Post("/api/v1/workouts", map[string]any{
+				"user_id":          pub(1),
+				"name":             "Run",
+				"duration_minutes": 30,
+				"custom_fields":    fields,
+			})
+
+			assert.Equal(t, http.StatusUnprocessableEntity, resp.Code, resp.Body.String())
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestListWorkouts_FiltersByTagsAndCustomFields(t *testing.T) {
+	db, mock := newMockDB(t)
+	api := newTestAPI(t, db)
+
+	expectUserLookup(mock, 1)
+	expectCustomFields(mock)
+	mock.ExpectQuery(`SELECT \* FROM "workouts" WHERE user_id = \(SELECT "id" FROM "users" WHERE public_id = \$1 AND "users"."deleted_at" IS NULL\) `+
+		`AND tags @> \$2 `+
+		`AND CASE WHEN jsonb_typeof\(custom_fields -> \$3\) = 'number' THEN \(custom_fields ->> \$4\)::numeric END >= \$5 `+
+		`AND custom_fields @> \$6 AND "workouts"."deleted_at" IS NULL`).
+		WithArgs(pub(1), `["deload","travel"]`, "sleep_hours", "sleep_hours", 7.0, `{"mood":"good"}`).
+		WillReturnRows(sqlmock.NewRows(append(workoutCols(), "tags", "custom_fields")).
+			AddRow(int64(4), pub(4).String(), fixedTime, fixedTime, nil, int64(1), "Hotel gym", "", 40,
+				`["deload","travel"]`, `{"sleep_hours":7.5,"mood":"good"}`))
+	expectPublicIDs(mock, "users", 1)
+
+	resp := api.Get("/api/v1/workouts?userId=" + pub(1).String() + "&tag=Travel,deload" +
+		"&field=sleep_hours:gte:7&field=mood:eq:good")
+
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	var body []schemas.WorkoutResponse
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &body))
+	require.Len(t, body, 1)
+	assert.Equal(t, []string{"deload", "travel"}, body[0].Tags)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestListWorkouts_RejectsBadFieldFilters(t *testing.T) {
+	for _, filter := range []string{"mood:gt:good", "sleep_hours:gte:lots", "sleep_hours:about:7", "weather:eq:sunny"} {
+		t.Run(filter, func(t *testing.T) {
+			db, mock := newMockDB(t)
+			api := newTestAPI(t, db)
+
+			expectUserLookup(mock, 1)
+			expectCustomFields(mock)
+
+			resp := api.Get("/api/v1/workouts?userId=" + pub(1).String() + "&field=" + filter)
+
+			assert.Equal(t, http.StatusUnprocessableEntity, resp.Code, resp.Body.String())
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestCreateCustomField_EnumNeedsOptions(t *testing.T) {
+	db, mock := newMockDB(t)
+	api := newTestAPI(t, db)
+
+	expectUserLookup(mock, 1)
+
+	resp := api.Post("/api/v1/custom-fields", map[string]any{
+		"user_id": pub(1), "key": "mood", "name": "Mood", "type": "enum", "options": []string{" ", ""},
+	})
+
+	assert.Equal(t, http.StatusUnprocessableEntity, resp.Code, resp.Body.String())
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCreateCustomField_DuplicateKey(t *testing.T) {
+	db, mock := newMockDB(t)
+	api := newTestAPI(t, db)
+
+	expectUserLookup(mock, 1)
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO "custom_fields" \("public_id","created_at","updated_at","deleted_at","version","user_id","key","name","type","options","unit"\)`).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), nil, int64(1), int64(1), "mood", "Mood", "enum", `["good","bad"]`, "").
+		WillReturnError(&pgconn.PgError{Code: "23505"})
+	mock.ExpectRollback()
+
+	resp := api.Post("/api/v1/custom-fields", map[string]any{
+		"user_id": pub(1), "key": "mood", "name": " Mood", "type": "enum", "options": []string{"good", "bad", "good "},
+	})
+
+	assert.Equal(t, http.StatusConflict, resp.Code, resp.Body.String())
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestListTags_CountsWorkouts(t *testing.T) {
+	db, mock := newMockDB(t)
+	api := newTestAPI(t, db)
+
+	expectUserLookup(mock, 1)
+	mock.ExpectQuery(`SELECT tag, COUNT\(\*\) AS workouts FROM workouts, jsonb_array_elements_text\(workouts.tags\) AS tag ` +
+		`WHERE workouts.user_id = \$1 AND workouts.deleted_at IS NULL GROUP BY tag ORDER BY workouts DESC, tag`).
+		WithArgs(int64(1)).
+		WillReturnRows(sqlmock.NewRows([]string{"tag", "workouts"}).AddRow("deload", 4).AddRow("travel", 1))
+
+	resp := api.Get("/api/v1/tags?userId=" + pub(1).String())
+
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	assert.JSONEq(t, `[{"tag":"deload","workouts":4},{"tag":"travel","workouts":1}]`, resp.Body.String())
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpdateCustomField_MergePatchClearsUnit(t *testing.T) {
+	db, mock := newMockDB(t)
+	api := newTestAPI(t, db)
+
+	mock.ExpectQuery(`SELECT \* FROM "custom_fields" WHERE public_id = \$1`).
+		WithArgs(pub(1), 1).
+		WillReturnRows(sqlmock.NewRows(customFieldCols()).
+			AddRow(int64(1), pub(1).String(), fixedTime, fixedTime, nil, 1, int64(1), "sleep_hours", "Sleep", "number", nil, "h"))
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "custom_fields" SET`).
+		WithArgs(pub(1), fixedTime, sqlmock.AnyArg(), nil, 1, int64(1), "sleep_hours", "Sleep", "number", nil, "", int64(1)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	resp := api.Patch("/api/v1/custom-fields/"+pub(1).String(), map[string]any{"unit": nil})
+
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	var body schemas.CustomFieldResponse
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &body))
+	assert.Equal(t, "Sleep", body.Name)
+	assert.Empty(t, body.Unit)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
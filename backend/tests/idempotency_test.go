@@ -0,0 +1,174 @@
+package backend_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/danielgtaylor/huma/v2/humatest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"workout-tracker/backend"
+	"workout-tracker/backend/idempotency"
+)
+
+const createBody = `{"user_id":"00000000-0000-7000-8000-000000000001","name":"Morning Run","duration_minutes":30,"performed_at":"2024-01-15T10:00:00Z"}`
+
+func newIdempotentTestAPI(t *testing.T, db *gorm.DB) humatest.TestAPI {
+	t.Helper()
+	_, api := humatest.New(t)
+	store := idempotency.New(db, 0)
+	store.Now = func() time.Time { return fixedTime }
+	backend.RegisterRoutes(api, db, backend.WithIdempotency(store))
+	return api
+}
+
+func fingerprintOf(path, body string) string {
+	sum := sha256.Sum256([]byte("POST " + path + "?\n" + body))
+	return hex.EncodeToString(sum[:])
+}
+
+func idempotencyKeyCols() []string {
+	return []string{"id", "created_at", "expires_at", "locked_until", "scope", "key", "fingerprint", "status", "header", "body"}
+}
+
+func expectKeyClaim(mock sqlmock.Sqlmock, claimed bool) {
+	mock.ExpectBegin()
+	mock.ExpectExec(`DELETE FROM "idempotency_keys" WHERE scope = \$1 AND key = \$2 AND \(expires_at <= \$3 OR \(status = 0 AND locked_until <= \$4\)\)`).
+		WithArgs("", "key-1", fixedTime, fixedTime).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	affected := int64(0)
+	if claimed {
+		affected = 1
+	}
+	mock.ExpectExec(`INSERT INTO "idempotency_keys" .* ON CONFLICT DO NOTHING`).
+		WithArgs(fixedTime, fixedTime.Add(idempotency.DefaultWindow), fixedTime.Add(30*time.Second), "", "key-1", fingerprintOf("/api/v1/workouts", createBody), 0, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, affected))
+	mock.ExpectCommit()
+}
+
+func TestIdempotency_StoresFirstResponse(t *testing.T) {
+	db, mock := newMockDB(t)
+	api := newIdempotentTestAPI(t, db)
+
+	expectKeyClaim(mock, true)
+	expectUserLookup(mock, 1)
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO "workouts"`).WillReturnResult(sqlmock.NewResult(9, 1))
+	expectRevision(mock, 1, 0)
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "idempotency_keys" SET "body"=\$1,"header"=\$2,"status"=\$3 WHERE status = 0 AND "id" = \$4`).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), http.StatusCreated, 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	resp := api.Post("/api/v1/workouts", "Idempotency-Key: key-1", "Content-Type: application/json", strings.NewReader(createBody))
+
+	require.Equal(t, http.StatusCreated, resp.Code, resp.Body.String())
+	assert.Empty(t, resp.Header().Get(idempotency.ReplayedHeader))
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestIdempotency_LostClaimKeepsRetryResponse(t *testing.T) {
+	db, mock := newMockDB(t)
+	api := newIdempotentTestAPI(t, db)
+
+	expectKeyClaim(mock, true)
+	expectUserLookup(mock, 1)
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO "workouts"`).WillReturnResult(sqlmock.NewResult(9, 1))
+	expectRevision(mock, 1, 0)
+	mock.ExpectCommit()
+	// A retry took the key over meanwhile: nothing is stored, and the
+	// retry's claim is not released.
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "idempotency_keys" SET .* WHERE status = 0 AND "id" = \$4`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	resp := api.Post("/api/v1/workouts", "Idempotency-Key: key-1", "Content-Type: application/json", strings.NewReader(createBody))
+
+	require.Equal(t, http.StatusCreated, resp.Code, resp.Body.String())
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestIdempotency_ReplaysStoredResponse(t *testing.T) {
+	db, mock := newMockDB(t)
+	api := newIdempotentTestAPI(t, db)
+
+	expectKeyClaim(mock, false)
+	mock.ExpectQuery(`SELECT \* FROM "idempotency_keys" WHERE scope = \$1 AND key = \$2 LIMIT \$3`).
+		WithArgs("", "key-1", 1).
+		WillReturnRows(sqlmock.NewRows(idempotencyKeyCols()).
+			AddRow(int64(1), fixedTime, fixedTime, fixedTime, "", "key-1", fingerprintOf("/api/v1/workouts", createBody), http.StatusCreated,
+				[]byte(`{"Content-Type":["application/json"],"Etag":["\"1\""]}`), []byte(`{"id":9}`)))
+
+	resp := api.Post("/api/v1/workouts", "Idempotency-Key: key-1", "Content-Type: application/json", strings.NewReader(createBody))
+
+	// The workout is not created again.
+	require.Equal(t, http.StatusCreated, resp.Code, resp.Body.String())
+	assert.Equal(t, "true", resp.Header().Get(idempotency.ReplayedHeader))
+	assert.Equal(t, `"1"`, resp.Header().Get("ETag"))
+	assert.JSONEq(t, `{"id":9}`, resp.Body.String())
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestIdempotency_KeyReusedForDifferentRequest(t *testing.T) {
+	db, mock := newMockDB(t)
+	api := newIdempotentTestAPI(t, db)
+
+	expectKeyClaim(mock, false)
+	mock.ExpectQuery(`SELECT \* FROM "idempotency_keys"`).
+		WillReturnRows(sqlmock.NewRows(idempotencyKeyCols()).
+			AddRow(int64(1), fixedTime, fixedTime, fixedTime, "", "key-1", "another-request", http.StatusCreated, []byte(`{}`), []byte(`{}`)))
+
+	resp := api.Post("/api/v1/workouts", "Idempotency-Key: key-1", "Content-Type: application/json", strings.NewReader(createBody))
+
+	assert.Equal(t, http.StatusUnprocessableEntity, resp.Code)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestIdempotency_FirstAttemptInFlight(t *testing.T) {
+	db, mock := newMockDB(t)
+	api := newIdempotentTestAPI(t, db)
+
+	expectKeyClaim(mock, false)
+	mock.ExpectQuery(`SELECT \* FROM "idempotency_keys"`).
+		WillReturnRows(sqlmock.NewRows(idempotencyKeyCols()).
+			AddRow(int64(1), fixedTime, fixedTime, fixedTime, "", "key-1", fingerprintOf("/api/v1/workouts", createBody), 0, nil, nil))
+
+	resp := api.Post("/api/v1/workouts", "Idempotency-Key: key-1", "Content-Type: application/json", strings.NewReader(createBody))
+
+	assert.Equal(t, http.StatusConflict, resp.Code)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestIdempotency_ServerErrorReleasesKey(t *testing.T) {
+	db, mock := newMockDB(t)
+	api := newIdempotentTestAPI(t, db)
+
+	expectKeyClaim(mock, true)
+	expectUserLookup(mock, 1)
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO "workouts"`).WillReturnError(sqlmock.ErrCancelled)
+	mock.ExpectRollback()
+	mock.ExpectBegin()
+	mock.ExpectExec(`DELETE FROM "idempotency_keys" WHERE "idempotency_keys"."id" = \$1`).
+		WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	resp := api.Post("/api/v1/workouts", "Idempotency-Key: key-1", "Content-Type: application/json", strings.NewReader(createBody))
+
+	assert.Equal(t, http.StatusInternalServerError, resp.Code)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
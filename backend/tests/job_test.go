@@ -0,0 +1,254 @@
+package backend_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"workout-tracker/backend/jobs"
+	"workout-tracker/backend/models"
+)
+
+func newTestRunner(db *gorm.DB) *jobs.Runner {
+	r := jobs.NewRunner(db)
+	r.Now = func() time.Time { return fixedTime }
+	return r
+}
+
+// expectClaim queues the lease of job 7 of kind "report.send" on the default
+// queue, as its attempts-th try.
+func expectClaim(mock sqlmock.Sqlmock, attempts, maxAttempts int) {
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM "jobs" WHERE queue = \$1 AND \(\(status = \$2 AND run_at <= \$3\) OR \(status = \$4 AND locked_until < \$5\)\) ORDER BY run_at,"jobs"."id" LIMIT \$6 FOR UPDATE SKIP LOCKED`).
+		WithArgs("default", "pending", fixedTime, "running", fixedTime, 1).
+		WillReturnRows(sqlmock.NewRows(jobCols()).
+			AddRow(int64(7), pub(7).String(), fixedTime, fixedTime, "default", "report.send", []byte(`{"user_id":1}`), "pending",
+				fixedTime, attempts-1, maxAttempts, nil, "", nil, nil))
+	mock.ExpectExec(`UPDATE "jobs" SET "updated_at"=\$1,"status"=\$2,"attempts"=\$3,"locked_until"=\$4 WHERE "id" = \$5`).
+		WithArgs(sqlmock.AnyArg(), "running", attempts, fixedTime.Add(5*time.Minute), int64(7)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+}
+
+func TestRunner_RunsClaimedJobAndMarksItSucceeded(t *testing.T) {
+	db, mock := newMockDB(t)
+	r := newTestRunner(db)
+	var got json.RawMessage
+	r.Handle("report.send", func(_ context.Context, job models.Job) error {
+		got = job.Args
+		return nil
+	})
+
+	expectClaim(mock, 1, 5)
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "jobs" SET "finished_at"=\$1,"last_error"=\$2,"locked_until"=\$3,"status"=\$4,"updated_at"=\$5 WHERE id = \$6 AND status = \$7 AND locked_until = \$8`).
+		WithArgs(fixedTime, "", nil, "succeeded", sqlmock.AnyArg(), int64(7), "running", fixedTime.Add(5*time.Minute)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	ran, err := r.RunNext(context.Background(), jobs.DefaultQueue)
+
+	require.NoError(t, err)
+	assert.True(t, ran)
+	assert.JSONEq(t, `{"user_id":1}`, string(got))
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRunner_FailedJobIsRetriedWithBackoff(t *testing.T) {
+	db, mock := newMockDB(t)
+	r := newTestRunner(db)
+	r.Handle("report.send", func(context.Context, models.Job) error { return assert.AnError })
+
+	expectClaim(mock, 2, 5)
+	// Second attempt failed: retried 15s·4 = 1 minute later.
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "jobs" SET "last_error"=\$1,"locked_until"=\$2,"run_at"=\$3,"status"=\$4,"updated_at"=\$5 WHERE id = \$6 AND status = \$7 AND locked_until = \$8`).
+		WithArgs(assert.AnError.Error(), nil, fixedTime.Add(time.Minute), "pending", sqlmock.AnyArg(), int64(7), "running", fixedTime.Add(5*time.Minute)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	ran, err := r.RunNext(context.Background(), jobs.DefaultQueue)
+
+	require.NoError(t, err)
+	assert.True(t, ran)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRunner_PanickingJobIsDeadAfterMaxAttempts(t *testing.T) {
+	db, mock := newMockDB(t)
+	r := newTestRunner(db)
+	r.Handle("report.send", func(context.Context, models.Job) error { panic("boom") })
+
+	expectClaim(mock, 3, 3)
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "jobs" SET "finished_at"=\$1,"last_error"=\$2,"locked_until"=\$3,"status"=\$4,"updated_at"=\$5 WHERE id = \$6 AND status = \$7 AND locked_until = \$8`).
+		WithArgs(fixedTime, "panic: boom", nil, "dead", sqlmock.AnyArg(), int64(7), "running", fixedTime.Add(5*time.Minute)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	ran, err := r.RunNext(context.Background(), jobs.DefaultQueue)
+
+	require.NoError(t, err)
+	assert.True(t, ran)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRunner_DiscardsOutcomeAfterLosingLease(t *testing.T) {
+	db, mock := newMockDB(t)
+	r := newTestRunner(db)
+	r.Handle("report.send", func(context.Context, models.Job) error { return nil })
+
+	expectClaim(mock, 1, 5)
+	// The run outlasted its lease and another worker has claimed the job.
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "jobs" SET .* WHERE id = \$6 AND status = \$7 AND locked_until = \$8`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	ran, err := r.RunNext(context.Background(), jobs.DefaultQueue)
+
+	assert.True(t, ran)
+	assert.ErrorIs(t, err, jobs.ErrLeaseLost)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRunner_NothingDue(t *testing.T) {
+	db, mock := newMockDB(t)
+	r := newTestRunner(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM "jobs"`).
+		WillReturnRows(sqlmock.NewRows(jobCols()))
+	mock.ExpectRollback()
+
+	ran, err := r.RunNext(context.Background(), jobs.DefaultQueue)
+
+	require.NoError(t, err)
+	assert.False(t, ran)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestParseSchedule(t *testing.T) {
+	monday9 := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC) // a Monday
+	tests := []struct {
+		spec  string
+		at    time.Time
+		match bool
+	}{
+		{"* * * * *", monday9.Add(17 * time.Minute), true},
+		{"0 9 * * 1", monday9, true},
+		{"0 9 * * 1", monday9.AddDate(0, 0, 1), false},
+		{"*/15 * * * *", monday9.Add(45 * time.Minute), true},
+		{"*/15 * * * *", monday9.Add(50 * time.Minute), false},
+		{"30 3 * * *", monday9, false},
+		{"0 8-10 * * 1-5", monday9, true},
+		{"0 9 * * 0,6", monday9, false},
+		{"0 9 * * 7", monday9.AddDate(0, 0, 6), true},
+		// Both day fields restricted: either one matching is enough.
+		{"0 9 15 * 1", monday9, true},
+		{"0 9 1 * 5", monday9, true},
+	}
+	for _, tt := range tests {
+		s, err := jobs.ParseSchedule(tt.spec)
+		require.NoError(t, err, tt.spec)
+		assert.Equal(t, tt.match, s.Matches(tt.at), "%s at %s", tt.spec, tt.at)
+	}
+
+	for _, bad := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "5-1 * * * *", "*/0 * * * *", "a * * * *"} {
+		_, err := jobs.ParseSchedule(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestScheduler_EnqueuesDueTaskOncePerSlot(t *testing.T) {
+	db, mock := newMockDB(t)
+	s := jobs.NewScheduler(db)
+	require.NoError(t, s.Every("finalize-challenges", "*/15 * * * *", "challenges.finalize", nil))
+	require.NoError(t, s.Every("purge-deleted", "30 3 * * *", "maintenance.purge_deleted", jobs.PurgeArgs{RetentionDays: 30}))
+
+	slot := fixedTime.Add(15 * time.Minute)
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO "jobs" .* ON CONFLICT DO NOTHING`).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), "default", "challenges.finalize", []byte("null"), "pending",
+			0, 5, nil, "", nil, "finalize-challenges:2024-01-01T12:15:00Z", slot).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	require.NoError(t, s.Tick(slot.Add(20*time.Second)))
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPurgeOutbox_RemovesOnlyFinishedMessages(t *testing.T) {
+	db, mock := newMockDB(t)
+	cutoff := fixedTime.AddDate(0, 0, -jobs.DefaultRetentionDays)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`DELETE FROM "outbox_messages" WHERE processed_at < \$1 OR failed_at < \$2`).
+		WithArgs(cutoff, cutoff).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectCommit()
+
+	n, err := jobs.PurgeOutbox(db, cutoff)
+
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), n)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestListJobs_RequiresAdmin(t *testing.T) {
+	db, mock := newMockDB(t)
+	api := newAuthedTestAPI(t, db, "zitadel-sub-5")
+
+	resp := api.Get("/api/v1/admin/jobs?status=dead")
+
+	assert.Equal(t, http.StatusForbidden, resp.Code)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestListJobs_FiltersDeadLetterQueue(t *testing.T) {
+	db, mock := newMockDB(t)
+	api := newTestAPI(t, db)
+
+	mock.ExpectQuery(`SELECT \* FROM "jobs" WHERE queue = \$1 AND status = \$2 ORDER BY id DESC LIMIT \$3`).
+		WithArgs("email", "dead", 100).
+		WillReturnRows(sqlmock.NewRows(jobCols()).
+			AddRow(int64(7), pub(7).String(), fixedTime, fixedTime, "email", "report.send", []byte(`{}`), "dead",
+				fixedTime, 5, 5, nil, "smtp: connection refused", fixedTime, nil))
+
+	resp := api.Get("/api/v1/admin/jobs?queue=email&status=dead")
+
+	require.Equal(t, http.StatusOK, resp.Code)
+	var body []map[string]any
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &body))
+	require.Len(t, body, 1)
+	assert.Equal(t, "smtp: connection refused", body[0]["last_error"])
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRetryJob_OnlyDeadOrPending(t *testing.T) {
+	db, mock := newMockDB(t)
+	api := newTestAPI(t, db)
+
+	mock.ExpectQuery(`SELECT \* FROM "jobs" WHERE public_id = \$1`).
+		WithArgs(pub(7), 1).
+		WillReturnRows(sqlmock.NewRows(jobCols()).
+			AddRow(int64(7), pub(7).String(), fixedTime, fixedTime, "default", "report.send", []byte(`{}`), "running",
+				fixedTime, 1, 5, fixedTime.Add(5*time.Minute), "", nil, nil))
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "jobs" SET "attempts"=\$1,"finished_at"=\$2,"run_at"=\$3,"status"=\$4,"updated_at"=\$5 WHERE id = \$6 AND status IN \(\$7,\$8\)`).
+		WithArgs(0, nil, sqlmock.AnyArg(), "pending", sqlmock.AnyArg(), int64(7), "dead", "pending").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	resp := api.Post("/api/v1/admin/jobs/" + pub(7).String() + "/retry")
+
+	assert.Equal(t, http.StatusConflict, resp.Code)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
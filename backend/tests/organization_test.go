@@ -0,0 +1,188 @@
+package backend_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"workout-tracker/backend/schemas"
+)
+
+func TestCreateOrganization(t *testing.T) {
+	db, mock := newMockDB(t)
+	api := newTestAPI(t, db)
+
+	expectUserLookup(mock, 1)
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO "organizations"`).
+		WithArgs(pub(3), sqlmock.AnyArg(), sqlmock.AnyArg(), nil, int64(1), nil, "Barbell Club").
+		WillReturnResult(sqlmock.NewResult(3, 1))
+	mock.ExpectExec(`INSERT INTO "memberships"`).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	resp := api.Post("/api/v1/organizations", map[string]any{
+		"id":      pub(3),
+		"user_id": pub(1),
+		"name":    "Barbell Club",
+	})
+
+	require.Equal(t, http.StatusCreated, resp.Code, resp.Body.String())
+	var body schemas.OrganizationResponse
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &body))
+	assert.Equal(t, pub(3), body.ID)
+	assert.Equal(t, "Barbell Club", body.Name)
+	assert.Equal(t, "owner", body.Role)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetOrganization_NotFound(t *testing.T) {
+	db, mock := newMockDB(t)
+	api := newTestAPI(t, db)
+
+	mock.ExpectQuery(`SELECT \* FROM "organizations" WHERE public_id = \$1`).
+		WithArgs(pub(99), 1).
+		WillReturnRows(sqlmock.NewRows(organizationCols()))
+
+	resp := api.Get("/api/v1/organizations/" + pub(99).String())
+
+	assert.Equal(t, http.StatusNotFound, resp.Code)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetOrganization_ClaimedOrganizationJoinedOnFirstRequest(t *testing.T) {
+	db, mock := newMockDB(t)
+	api := newClaimsTestAPI(t, db, "zitadel-sub-5", map[string]any{
+		"urn:zitadel:iam:user:resourceowner:id":   "zitadel-org-7",
+		"urn:zitadel:iam:user:resourceowner:name": "Barbell Club",
+	})
+
+	mock.ExpectQuery(`SELECT \* FROM "organizations" WHERE public_id = \$1`).
+		WithArgs(pub(7), 1).
+		WillReturnRows(sqlmock.NewRows(organizationCols()).
+			AddRow(int64(7), pub(7).String(), fixedTime, fixedTime, nil, "zitadel-org-7", "Barbell Club"))
+	mock.ExpectQuery(`SELECT \* FROM "users" WHERE zitadel_id`).
+		WillReturnRows(sqlmock.NewRows(userCols()).
+			AddRow(int64(5), pub(5).String(), fixedTime, fixedTime, nil, "zitadel-sub-5", "m@example.com", "Member", ""))
+	// The claim is synced while resolving the caller, before their
+	// membership is checked.
+	mock.ExpectQuery(`SELECT \* FROM "organizations" WHERE "organizations"."zitadel_org_id" = \$1`).
+		WithArgs("zitadel-org-7", 1).
+		WillReturnRows(sqlmock.NewRows(organizationCols()).
+			AddRow(int64(7), pub(7).String(), fixedTime, fixedTime, nil, "zitadel-org-7", "Barbell Club"))
+	mock.ExpectQuery(`SELECT \* FROM "memberships" WHERE \("memberships"."organization_id" = \$1 AND "memberships"."user_id" = \$2\)`).
+		WithArgs(int64(7), int64(5), 1).
+		WillReturnRows(sqlmock.NewRows(membershipCols()))
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO "memberships"`).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	mock.ExpectQuery(`SELECT \* FROM "memberships" WHERE \(organization_id = \$1 AND user_id = \$2\)`).
+		WithArgs(int64(7), int64(5), 1).
+		WillReturnRows(sqlmock.NewRows(membershipCols()).
+			AddRow(int64(1), pub(1).String(), fixedTime, fixedTime, nil, int64(7), int64(5), "member", false))
+
+	resp := api.Get("/api/v1/organizations/" + pub(7).String())
+
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	var body schemas.OrganizationResponse
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &body))
+	assert.Equal(t, "member", body.Role)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAddMember_RequiresOwnerOrCoach(t *testing.T) {
+	db, mock := newMockDB(t)
+	api := newAuthedTestAPI(t, db, "zitadel-sub-5")
+
+	mock.ExpectQuery(`SELECT \* FROM "organizations"`).
+		WillReturnRows(sqlmock.NewRows(organizationCols()).
+			AddRow(int64(1), pub(1).String(), fixedTime, fixedTime, nil, nil, "Barbell Club"))
+	mock.ExpectQuery(`SELECT \* FROM "users" WHERE zitadel_id`).
+		WillReturnRows(sqlmock.NewRows(userCols()).
+			AddRow(int64(5), pub(5).String(), fixedTime, fixedTime, nil, "zitadel-sub-5", "m@example.com", "Member", ""))
+	mock.ExpectQuery(`SELECT \* FROM "memberships"`).
+		WillReturnRows(sqlmock.NewRows(membershipCols()).
+			AddRow(int64(3), pub(3).String(), fixedTime, fixedTime, nil, int64(1), int64(5), "member", true))
+
+	resp := api.Post("/api/v1/organizations/"+pub(1).String()+"/members", map[string]any{"user_id": pub(6)})
+
+	assert.Equal(t, http.StatusForbidden, resp.Code)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTeamVolume(t *testing.T) {
+	db, mock := newMockDB(t)
+	api := newTestAPI(t, db)
+
+	mock.ExpectQuery(`SELECT \* FROM "organizations"`).
+		WillReturnRows(sqlmock.NewRows(organizationCols()).
+			AddRow(int64(1), pub(1).String(), fixedTime, fixedTime, nil, nil, "Barbell Club"))
+	mock.ExpectQuery(`SELECT COUNT\(DISTINCT workouts.user_id\).*JOIN memberships.*share_stats`).
+		WillReturnRows(sqlmock.NewRows([]string{"members", "sessions", "total_minutes"}).
+			AddRow(int64(3), int64(12), int64(540)))
+
+	resp := api.Get("/api/v1/organizations/" + pub(1).String() + "/stats/volume?from=2024-01-01T00:00:00Z&to=2024-02-01T00:00:00Z")
+
+	require.Equal(t, http.StatusOK, resp.Code)
+	var body schemas.TeamVolumeResponse
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &body))
+	assert.Equal(t, int64(3), body.Members)
+	assert.Equal(t, int64(12), body.Sessions)
+	assert.Equal(t, int64(540), body.TotalMinutes)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestLeaderboard_TiesShareRank(t *testing.T) {
+	db, mock := newMockDB(t)
+	api := newTestAPI(t, db)
+
+	mock.ExpectQuery(`SELECT \* FROM "organizations"`).
+		WillReturnRows(sqlmock.NewRows(organizationCols()).
+			AddRow(int64(1), pub(1).String(), fixedTime, fixedTime, nil, nil, "Barbell Club"))
+	mock.ExpectQuery(`SELECT users.public_id AS user_id, users.name, COUNT\(\*\) AS value`).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "name", "value"}).
+			AddRow(pub(2).String(), "Alice", int64(5)).
+			AddRow(pub(3).String(), "Bob", int64(5)).
+			AddRow(pub(4).String(), "Carol", int64(2)))
+
+	resp := api.Get("/api/v1/organizations/" + pub(1).String() + "/leaderboard?metric=sessions")
+
+	require.Equal(t, http.StatusOK, resp.Code)
+	var body []schemas.LeaderboardEntry
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &body))
+	require.Len(t, body, 3)
+	assert.Equal(t, []int{1, 1, 3}, []int{body[0].Rank, body[1].Rank, body[2].Rank})
+	assert.Equal(t, pub(2), body[0].UserID)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpdateMember_MergePatchZeroesShareStats(t *testing.T) {
+	db, mock := newMockDB(t)
+	api := newTestAPI(t, db)
+
+	mock.ExpectQuery(`SELECT \* FROM "organizations"`).
+		WillReturnRows(sqlmock.NewRows(organizationCols()).
+			AddRow(int64(1), pub(1).String(), fixedTime, fixedTime, nil, nil, "Barbell Club"))
+	mock.ExpectQuery(`SELECT "memberships"."id".* FROM "memberships" LEFT JOIN "users" "User"`).
+		WillReturnRows(sqlmock.NewRows(append(membershipCols(), "User__id", "User__public_id", "User__name")).
+			AddRow(int64(3), pub(3).String(), fixedTime, fixedTime, nil, int64(1), int64(5), "member", true, int64(5), pub(5).String(), "Max"))
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "memberships" SET`).
+		WithArgs(pub(3), fixedTime, sqlmock.AnyArg(), nil, 0, int64(1), int64(5), "member", false, int64(3)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	resp := api.Patch("/api/v1/organizations/"+pub(1).String()+"/members/"+pub(5).String(), map[string]any{"share_stats": false})
+
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	var body schemas.MemberResponse
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &body))
+	assert.Equal(t, "member", body.Role)
+	assert.False(t, body.ShareStats)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
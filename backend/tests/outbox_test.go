@@ -0,0 +1,142 @@
+package backend_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"workout-tracker/backend"
+	"workout-tracker/backend/events"
+)
+
+func outboxCols() []string {
+	return []string{"id", "created_at", "consumer", "event_id", "attempts",
+		"available_at", "processed_at", "failed_at", "last_error"}
+}
+
+func newTestOutbox(t *testing.T, db *gorm.DB) *events.Outbox {
+	t.Helper()
+	o, err := events.NewOutbox(db)
+	require.NoError(t, err)
+	o.Now = func() time.Time { return fixedTime }
+	return o
+}
+
+// expectLease queues the lease of one message (ID 3, event 9) for consumer.
+func expectLease(mock sqlmock.Sqlmock, consumer string, attempts int) {
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM "outbox_messages" WHERE consumer = \$1 .* ORDER BY id LIMIT \$3 FOR UPDATE SKIP LOCKED`).
+		WithArgs(consumer, fixedTime, 100).
+		WillReturnRows(sqlmock.NewRows(outboxCols()).
+			AddRow(int64(3), fixedTime, consumer, int64(9), attempts, fixedTime, nil, nil, ""))
+	mock.ExpectQuery(`SELECT \* FROM "events" WHERE id IN \(\$1\)`).
+		WithArgs(int64(9)).
+		WillReturnRows(sqlmock.NewRows(eventCols()).
+			AddRow(int64(9), fixedTime, "workout.created", "workout", int64(4), pub(4).String(), int64(1), "public", []byte(`{}`)))
+	mock.ExpectExec(`SAVEPOINT outbox_message`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+}
+
+func TestOutbox_WritesMessagePerConsumerInSameTransaction(t *testing.T) {
+	db, mock, bus := newRecordingDB(t)
+	o := newTestOutbox(t, db)
+	o.Register("webhooks", func(context.Context, *gorm.DB, events.Event) error { return nil })
+	o.Register("achievements", func(context.Context, *gorm.DB, events.Event) error { return nil })
+	api := newTestAPI(t, db, backend.WithEventBus(bus))
+
+	expectUserLookup(mock, 1)
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO "workouts"`).
+		WillReturnResult(sqlmock.NewResult(4, 1))
+	expectOwner(mock, 1)
+	mock.ExpectExec(`INSERT INTO "events"`).
+		WillReturnResult(sqlmock.NewResult(9, 1))
+	mock.ExpectExec(`INSERT INTO "outbox_messages"`).
+		WithArgs(sqlmock.AnyArg(), "achievements", int64(9), 0, fixedTime, nil, nil, "",
+			sqlmock.AnyArg(), "webhooks", int64(9), 0, fixedTime, nil, nil, "").
+		WillReturnResult(sqlmock.NewResult(1, 2))
+	expectRevision(mock, 1, 0)
+	mock.ExpectCommit()
+
+	resp := api.Post("/api/v1/workouts", map[string]any{"user_id": pub(1), "name": "Intervals", "duration_minutes": 25})
+
+	assert.Equal(t, http.StatusCreated, resp.Code)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestOutbox_ProcessCommitsHandlerWorkWithMessage(t *testing.T) {
+	db, mock := newMockDB(t)
+	o := newTestOutbox(t, db)
+	var handled []int64
+	o.Register("achievements", func(_ context.Context, tx *gorm.DB, e events.Event) error {
+		handled = append(handled, e.ID)
+		return tx.Exec("INSERT INTO achievements (event_id) VALUES (?)", e.ID).Error
+	})
+
+	expectLease(mock, "achievements", 0)
+	mock.ExpectExec(`INSERT INTO achievements`).
+		WithArgs(int64(9)).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`UPDATE "outbox_messages" SET "attempts"=\$1,"processed_at"=\$2 WHERE id = \$3`).
+		WithArgs(1, fixedTime, int64(3)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	n, err := o.Process(context.Background(), "achievements")
+
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.Equal(t, []int64{9}, handled)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestOutbox_FailedHandlerIsRolledBackAndRetried(t *testing.T) {
+	db, mock := newMockDB(t)
+	o := newTestOutbox(t, db)
+	o.Register("achievements", func(_ context.Context, tx *gorm.DB, e events.Event) error {
+		tx.Exec("INSERT INTO achievements (event_id) VALUES (?)", e.ID)
+		return assert.AnError
+	})
+
+	expectLease(mock, "achievements", 1)
+	mock.ExpectExec(`INSERT INTO achievements`).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`ROLLBACK TO SAVEPOINT outbox_message`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	// Second attempt: retried 2² seconds later.
+	mock.ExpectExec(`UPDATE "outbox_messages" SET "attempts"=\$1,"available_at"=\$2,"last_error"=\$3 WHERE id = \$4`).
+		WithArgs(2, fixedTime.Add(4*time.Second), assert.AnError.Error(), int64(3)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	_, err := o.Process(context.Background(), "achievements")
+
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestOutbox_GivesUpAfterMaxAttempts(t *testing.T) {
+	db, mock := newMockDB(t)
+	o := newTestOutbox(t, db)
+	o.MaxAttempts = 2
+	o.Register("achievements", func(context.Context, *gorm.DB, events.Event) error { return assert.AnError })
+
+	expectLease(mock, "achievements", 1)
+	mock.ExpectExec(`ROLLBACK TO SAVEPOINT outbox_message`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`UPDATE "outbox_messages" SET "attempts"=\$1,"failed_at"=\$2,"last_error"=\$3`).
+		WithArgs(2, fixedTime, assert.AnError.Error(), int64(3)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	_, err := o.Process(context.Background(), "achievements")
+
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,188 @@
+package backend_test
+
+import (
+	"net/http"
+	"testing"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"workout-tracker/backend/patch"
+)
+
+func TestMerge(t *testing.T) {
+	// Examples from RFC 7396, appendix A.
+	cases := []struct{ doc, patch, want string }{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`{"e":null}`, `{"a":1}`, `{"a":1,"e":null}`},
+		{`{"a":"foo"}`, `null`, `null`},
+		{`{"a":"foo"}`, `"bar"`, `"bar"`},
+	}
+	for _, c := range cases {
+		got, err := patch.Merge([]byte(c.doc), []byte(c.patch))
+		require.NoError(t, err)
+		assert.JSONEq(t, c.want, string(got), "%s + %s", c.doc, c.patch)
+	}
+}
+
+func TestApply(t *testing.T) {
+	doc := []byte(`{"name":"Run","tags":["a","b"],"meta":{"x/y":1}}`)
+	got, err := patch.Apply(doc, []patch.Op{
+		{Op: "test", Path: "/name", Value: []byte(`"Run"`)},
+		{Op: "replace", Path: "/name", Value: []byte(`"Ride"`)},
+		{Op: "add", Path: "/tags/1", Value: []byte(`"c"`)},
+		{Op: "add", Path: "/tags/-", Value: []byte(`"d"`)},
+		{Op: "remove", Path: "/tags/0"},
+		{Op: "move", From: "/meta/x~1y", Path: "/count"},
+		{Op: "copy", From: "/count", Path: "/meta/copy"},
+	})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"name":"Ride","tags":["c","b","d"],"meta":{"copy":1},"count":1}`, string(got))
+
+	_, err = patch.Apply(doc, []patch.Op{{Op: "test", Path: "/name", Value: []byte(`"Ride"`)}})
+	assert.ErrorIs(t, err, patch.ErrTestFailed)
+	_, err = patch.Apply(doc, []patch.Op{{Op: "replace", Path: "/missing", Value: []byte(`1`)}})
+	assert.ErrorIs(t, err, patch.ErrPath)
+	_, err = patch.Apply(doc, []patch.Op{{Op: "add", Path: "/name"}})
+	assert.Error(t, err)
+}
+
+// expectWorkoutWithDetails mocks loading workout 4 with a description and
+// distance set.
+func expectWorkoutWithDetails(mock sqlmock.Sqlmock) {
+	mock.ExpectQuery(`SELECT \* FROM "workouts" WHERE public_id = \$1`).
+		WillReturnRows(sqlmock.NewRows(append(workoutCols(), "distance_meters", "performed_at", "visibility", "version")).
+			AddRow(int64(4), pub(4).String(), fixedTime, fixedTime, nil, int64(1), "Tempo run", "Felt good", 50, 8000, fixedTime, "public", int64(2)))
+}
+
+func TestUpdateWorkout_MergePatchClearsAndZeroes(t *testing.T) {
+	db, mock := newMockDB(t)
+	api := newTestAPI(t, db)
+
+	expectWorkoutWithDetails(mock)
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "workouts" SET`).
+		WithArgs(pub(4), fixedTime, sqlmock.AnyArg(), nil, int64(3), int64(1), "Tempo run", "", 0, 8000, fixedTime, "public", "[]", "{}", "[]", "[]", int64(2), int64(4)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectRevision(mock, 1, 2)
+	mock.ExpectCommit()
+
+	resp := api.Patch("/api/v1/workouts/"+pub(4).String(), "Content-Type: application/merge-patch+json",
+		map[string]any{"description": nil, "duration_minutes": 0})
+
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	assert.Equal(t, `"3"`, resp.Header().Get("ETag"))
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpdateWorkout_MergePatchMustLeaveValidWorkout(t *testing.T) {
+	db, mock := newMockDB(t)
+	api := newTestAPI(t, db)
+
+	expectWorkoutWithDetails(mock)
+	resp := api.Patch("/api/v1/workouts/"+pub(4).String(), map[string]any{"name": nil, "visibility": "everyone"})
+
+	require.Equal(t, http.StatusUnprocessableEntity, resp.Code, resp.Body.String())
+	assert.Contains(t, resp.Body.String(), "body.visibility")
+	assert.Contains(t, resp.Body.String(), "name")
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpdateWorkout_JSONPatch(t *testing.T) {
+	db, mock := newMockDB(t)
+	api := newTestAPI(t, db)
+
+	expectWorkoutWithDetails(mock)
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "workouts" SET`).
+		WithArgs(pub(4), fixedTime, sqlmock.AnyArg(), nil, int64(3), int64(1), "Intervals", "Felt good", 50, 0, fixedTime, "public", "[]", "{}", "[]", "[]", int64(2), int64(4)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectRevision(mock, 1, 2)
+	mock.ExpectCommit()
+
+	resp := api.Patch("/api/v1/workouts/"+pub(4).String(), "Content-Type: application/json-patch+json", []map[string]any{
+		{"op": "test", "path": "/name", "value": "Tempo run"},
+		{"op": "replace", "path": "/name", "value": "Intervals"},
+		{"op": "replace", "path": "/distance_meters", "value": 0},
+	})
+
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpdateWorkout_JSONPatchFailedTest(t *testing.T) {
+	db, mock := newMockDB(t)
+	api := newTestAPI(t, db)
+
+	expectWorkoutWithDetails(mock)
+	resp := api.Patch("/api/v1/workouts/"+pub(4).String(), "Content-Type: application/json-patch+json", []map[string]any{
+		{"op": "test", "path": "/name", "value": "Easy run"},
+		{"op": "replace", "path": "/name", "value": "Intervals"},
+	})
+
+	assert.Equal(t, http.StatusConflict, resp.Code)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestReplaceWorkout_ClearsOmittedFields(t *testing.T) {
+	db, mock := newMockDB(t)
+	api := newTestAPI(t, db)
+
+	expectWorkoutWithDetails(mock)
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "workouts" SET`).
+		WithArgs(pub(4), fixedTime, sqlmock.AnyArg(), nil, int64(3), int64(1), "Intervals", "", 30, 0, fixedTime, "private", "[]", "{}", "[]", "[]", int64(2), int64(4)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectRevision(mock, 1, 2)
+	mock.ExpectCommit()
+
+	resp := api.Put("/api/v1/workouts/"+pub(4).String(), map[string]any{
+		"name": "Intervals", "duration_minutes": 30, "performed_at": fixedTime,
+	})
+
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpdateUser_MergePatch(t *testing.T) {
+	db, mock := newMockDB(t)
+	api := newTestAPI(t, db)
+
+	mock.ExpectQuery(`SELECT \* FROM "users" WHERE public_id = \$1`).
+		WillReturnRows(sqlmock.NewRows(append(userCols(), "version")).
+			AddRow(int64(5), pub(5).String(), fixedTime, fixedTime, nil, nil, "m@example.com", "Member", "hash", int64(1)))
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "users" SET .* WHERE version = \$\d+`).
+		WithArgs(pub(5), fixedTime, sqlmock.AnyArg(), nil, int64(2), nil, "m@example.com", "Max", "hash", int64(1), int64(5)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	resp := api.Patch("/api/v1/users/"+pub(5).String(), `If-Match: "1"`, map[string]any{"name": "Max"})
+
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	assert.Equal(t, `"2"`, resp.Header().Get("ETag"))
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpdateUser_OtherUserForbidden(t *testing.T) {
+	db, mock := newMockDB(t)
+	api := newAuthedTestAPI(t, db, "zitadel-sub-5")
+
+	mock.ExpectQuery(`SELECT \* FROM "users" WHERE zitadel_id`).
+		WillReturnRows(sqlmock.NewRows(userCols()).
+			AddRow(int64(5), pub(5).String(), fixedTime, fixedTime, nil, "zitadel-sub-5", "m@example.com", "Member", ""))
+	mock.ExpectQuery(`SELECT \* FROM "users" WHERE public_id = \$1`).
+		WithArgs(pub(6), 1).
+		WillReturnRows(sqlmock.NewRows(userCols()).
+			AddRow(int64(6), pub(6).String(), fixedTime, fixedTime, nil, "zitadel-sub-6", "o@example.com", "Other", ""))
+
+	resp := api.Patch("/api/v1/users/"+pub(6).String(), map[string]any{"name": "Max"})
+
+	assert.Equal(t, http.StatusForbidden, resp.Code)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,439 @@
+package backend_test
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"workout-tracker/backend/events"
+	"workout-tracker/backend/notify"
+	"workout-tracker/backend/planner"
+)
+
+func TestRecurrence_Between(t *testing.T) {
+	ams, err := time.LoadLocation("Europe/Amsterdam")
+	require.NoError(t, err)
+	day := func(y int, m time.Month, d, hh int, loc *time.Location) time.Time {
+		return time.Date(y, m, d, hh, 0, 0, 0, loc)
+	}
+
+	tests := []struct {
+		name     string
+		rule     string
+		dtstart  time.Time
+		from, to time.Time
+		want     []time.Time
+	}{
+		{
+			name:    "weekly on three days",
+			rule:    "FREQ=WEEKLY;BYDAY=MO,WE,FR",
+			dtstart: day(2024, 1, 3, 7, time.UTC), // Wednesday
+			from:    day(2024, 1, 1, 0, time.UTC),
+			to:      day(2024, 1, 10, 0, time.UTC),
+			want: []time.Time{
+				day(2024, 1, 3, 7, time.UTC), day(2024, 1, 5, 7, time.UTC), day(2024, 1, 8, 7, time.UTC),
+			},
+		},
+		{
+			name:    "every other day, counted from dtstart",
+			rule:    "FREQ=DAILY;INTERVAL=2;COUNT=3",
+			dtstart: day(2024, 1, 1, 18, time.UTC),
+			from:    day(2024, 1, 4, 0, time.UTC),
+			to:      day(2024, 2, 1, 0, time.UTC),
+			want:    []time.Time{day(2024, 1, 5, 18, time.UTC)},
+		},
+		{
+			name:    "last Friday of the month",
+			rule:    "FREQ=MONTHLY;BYDAY=-1FR",
+			dtstart: day(2024, 1, 1, 9, time.UTC),
+			from:    day(2024, 1, 1, 0, time.UTC),
+			to:      day(2024, 3, 1, 0, time.UTC),
+			want:    []time.Time{day(2024, 1, 26, 9, time.UTC), day(2024, 2, 23, 9, time.UTC)},
+		},
+		{
+			name:    "the 31st only in months that have one",
+			rule:    "FREQ=MONTHLY;BYMONTHDAY=31",
+			dtstart: day(2024, 1, 31, 9, time.UTC),
+			from:    day(2024, 1, 1, 0, time.UTC),
+			to:      day(2024, 4, 1, 0, time.UTC),
+			want:    []time.Time{day(2024, 1, 31, 9, time.UTC), day(2024, 3, 31, 9, time.UTC)},
+		},
+		{
+			name:    "until is inclusive",
+			rule:    "FREQ=WEEKLY;UNTIL=20240115",
+			dtstart: day(2024, 1, 1, 7, time.UTC),
+			from:    day(2024, 1, 1, 0, time.UTC),
+			to:      day(2024, 3, 1, 0, time.UTC),
+			want:    []time.Time{day(2024, 1, 1, 7, time.UTC), day(2024, 1, 8, 7, time.UTC), day(2024, 1, 15, 7, time.UTC)},
+		},
+		{
+			name:    "local time survives daylight saving",
+			rule:    "FREQ=WEEKLY",
+			dtstart: day(2024, 3, 24, 7, ams),
+			from:    day(2024, 3, 1, 0, time.UTC),
+			to:      day(2024, 4, 1, 0, time.UTC),
+			want:    []time.Time{day(2024, 3, 24, 7, ams), day(2024, 3, 31, 7, ams)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := planner.ParseRRule(tt.rule)
+			require.NoError(t, err)
+			got := r.Between(tt.dtstart, tt.from, tt.to)
+			require.Len(t, got, len(tt.want))
+			for i := range got {
+				assert.True(t, tt.want[i].Equal(got[i]), "occurrence %d: want %s, got %s", i, tt.want[i], got[i])
+			}
+		})
+	}
+	// 07:00 in Amsterdam is 06:00 UTC before the switch and 05:00 after.
+	r, _ := planner.ParseRRule("FREQ=WEEKLY")
+	got := r.Between(day(2024, 3, 24, 7, ams), day(2024, 3, 1, 0, time.UTC), day(2024, 4, 1, 0, time.UTC))
+	assert.Equal(t, []int{6, 5}, []int{got[0].UTC().Hour(), got[1].UTC().Hour()})
+}
+
+func TestParseRRule_Rejects(t *testing.T) {
+	for _, rule := range []string{
+		"", "BYDAY=MO", "FREQ=HOURLY", "FREQ=WEEKLY;INTERVAL=0", "FREQ=WEEKLY;BYDAY=XX",
+		"FREQ=WEEKLY;BYDAY=1MO", "FREQ=DAILY;COUNT=2;UNTIL=20240101", "FREQ=DAILY;BYSETPOS=1",
+	} {
+		_, err := planner.ParseRRule(rule)
+		assert.Error(t, err, rule)
+	}
+}
+
+func TestCreatePlan_MaterializesOneOffSession(t *testing.T) {
+	db, mock := newMockDB(t)
+	api := newTestAPI(t, db)
+	startsAt := time.Now().Add(48 * time.Hour).UTC().Truncate(time.Second)
+
+	expectUserLookup(mock, 1)
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO "planned_workouts"`).
+		WillReturnResult(sqlmock.NewResult(3, 1))
+	mock.ExpectExec(`INSERT INTO "planned_sessions" .* ON CONFLICT DO NOTHING`).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), nil, int64(1), int64(3), int64(1), startsAt, startsAt, "scheduled", nil, nil, nil, 0).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	expectPublicIDs(mock, "users", 1)
+
+	resp := api.Post("/api/v1/planned-workouts", map[string]any{
+		"user_id":           pub(1),
+		"name":              "Long run",
+		"starts_at":         startsAt,
+		"reminder_channels": []string{"push"},
+	})
+
+	require.Equal(t, http.StatusCreated, resp.Code, resp.Body.String())
+	var body map[string]any
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &body))
+	assert.Equal(t, pub(1).String(), body["user_id"])
+	assert.Equal(t, "UTC", body["timezone"])
+	assert.EqualValues(t, 60, body["remind_minutes_before"])
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCreatePlan_RejectsBadRule(t *testing.T) {
+	db, mock := newMockDB(t)
+	api := newTestAPI(t, db)
+
+	expectUserLookup(mock, 1)
+	resp := api.Post("/api/v1/planned-workouts", map[string]any{
+		"user_id":   pub(1),
+		"name":      "Intervals",
+		"starts_at": fixedTime,
+		"rrule":     "FREQ=HOURLY",
+	})
+
+	assert.Equal(t, http.StatusUnprocessableEntity, resp.Code)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCompleteSession_RequiresOwnWorkout(t *testing.T) {
+	db, mock := newMockDB(t)
+	api := newTestAPI(t, db)
+
+	mock.ExpectQuery(`SELECT \* FROM "planned_sessions" WHERE public_id = \$1`).
+		WithArgs(pub(5), 1).
+		WillReturnRows(sqlmock.NewRows(plannedSessionCols()).
+			AddRow(int64(5), pub(5).String(), fixedTime, fixedTime, nil, int64(3), int64(1), fixedTime, fixedTime, "missed", nil, nil, fixedTime, 0))
+	mock.ExpectQuery(`SELECT \* FROM "planned_workouts" WHERE "planned_workouts"."id" = \$1`).
+		WithArgs(int64(3)).
+		WillReturnRows(sqlmock.NewRows(plannedWorkoutCols()).
+			AddRow(int64(3), pub(3).String(), fixedTime, fixedTime, nil, int64(1), "Long run", "", 60, fixedTime, "UTC", "", nil, 60, false))
+	mock.ExpectQuery(`SELECT \* FROM "workouts" WHERE \(user_id = \$1 AND public_id = \$2\)`).
+		WithArgs(int64(1), pub(9), 1).
+		WillReturnRows(sqlmock.NewRows(workoutCols()).
+			AddRow(int64(9), pub(9).String(), fixedTime, fixedTime, nil, int64(1), "Run", "", 62))
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "planned_sessions" SET "updated_at"=\$1,"status"=\$2,"workout_id"=\$3`).
+		WithArgs(sqlmock.AnyArg(), "done", int64(9), int64(5)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	expectPublicIDs(mock, "workouts", 9)
+
+	resp := api.Post("/api/v1/planned-sessions/"+pub(5).String()+"/complete", map[string]any{"workout_id": pub(9)})
+
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	var body map[string]any
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &body))
+	assert.Equal(t, "done", body["status"])
+	assert.Equal(t, pub(9).String(), body["workout_id"])
+	assert.Equal(t, pub(3).String(), body["plan_id"])
+	assert.Equal(t, "Long run", body["name"])
+	assert.NotNil(t, body["missed_at"], "a late log keeps the session counted as missed")
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+// recordingChannel keeps every message it is asked to send.
+type recordingChannel struct {
+	sent []notify.Message
+	to   []notify.Recipient
+}
+
+func (c *recordingChannel) Send(_ context.Context, to notify.Recipient, m notify.Message) error {
+	c.sent = append(c.sent, m)
+	c.to = append(c.to, to)
+	return nil
+}
+
+// expectDueSession queues the planner's due-session query returning session
+// 5 of plan 3, owned by user 1, scheduled at scheduledAt.
+func expectDueSession(mock sqlmock.Sqlmock, scheduledAt time.Time, autoReschedule bool) {
+	mock.ExpectQuery(`SELECT planned_sessions.\* FROM "planned_sessions" JOIN planned_workouts ON .* WHERE planned_sessions.status = \$1 AND`).
+		WillReturnRows(sqlmock.NewRows(plannedSessionCols()).
+			AddRow(int64(5), pub(5).String(), fixedTime, fixedTime, nil, int64(3), int64(1), scheduledAt, scheduledAt, "scheduled", nil, nil, nil, 0))
+	mock.ExpectQuery(`SELECT \* FROM "planned_workouts" WHERE "planned_workouts"."id" = \$1`).
+		WithArgs(int64(3)).
+		WillReturnRows(sqlmock.NewRows(plannedWorkoutCols()).
+			AddRow(int64(3), pub(3).String(), fixedTime, fixedTime, nil, int64(1), "Long run", "", 60, scheduledAt, "Europe/Amsterdam",
+				"", []byte(`["push","email"]`), 30, autoReschedule))
+	mock.ExpectQuery(`SELECT \* FROM "users" WHERE "users"."id" = \$1`).
+		WithArgs(int64(1)).
+		WillReturnRows(sqlmock.NewRows(userCols()).
+			AddRow(int64(1), pub(1).String(), fixedTime, fixedTime, nil, nil, "alice@example.com", "Alice", ""))
+}
+
+func TestSendReminders_ClaimsSessionBeforeSending(t *testing.T) {
+	db, mock := newMockDB(t)
+	push := &recordingChannel{}
+	p := planner.New(db, notify.Channels{notify.ChannelPush: push})
+	p.Now = func() time.Time { return fixedTime }
+	scheduledAt := fixedTime.Add(20 * time.Minute)
+
+	expectDueSession(mock, scheduledAt, false)
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "planned_sessions" SET "reminded_at"=\$1,"updated_at"=\$2 WHERE \(id = \$3 AND reminded_at IS NULL\)`).
+		WithArgs(fixedTime, sqlmock.AnyArg(), int64(5)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	require.NoError(t, p.SendReminders(context.Background()))
+
+	// Email is selected on the plan but not configured, so only push sends.
+	require.Len(t, push.sent, 1)
+	m := push.sent[0]
+	assert.Equal(t, events.TypeSessionReminder, m.Kind)
+	assert.Equal(t, "Reminder: Long run at 13:20", m.Subject)
+	assert.Equal(t, events.ResourceSession, m.ResourceType)
+	assert.Equal(t, int64(5), m.ResourceID)
+	assert.Equal(t, pub(5), m.ResourcePublicID)
+	assert.Equal(t, "alice@example.com", push.to[0].Email)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSendReminders_SkipsSessionClaimedElsewhere(t *testing.T) {
+	db, mock := newMockDB(t)
+	push := &recordingChannel{}
+	p := planner.New(db, notify.Channels{notify.ChannelPush: push})
+	p.Now = func() time.Time { return fixedTime }
+
+	expectDueSession(mock, fixedTime.Add(20*time.Minute), false)
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "planned_sessions" SET "reminded_at"`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	require.NoError(t, p.SendReminders(context.Background()))
+
+	assert.Empty(t, push.sent)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestReportMissed_AutoReschedulesToNextDay(t *testing.T) {
+	db, mock := newMockDB(t)
+	push := &recordingChannel{}
+	p := planner.New(db, notify.Channels{notify.ChannelPush: push})
+	p.Now = func() time.Time { return fixedTime }
+	scheduledAt := fixedTime.Add(-20 * time.Hour)
+	next := scheduledAt.AddDate(0, 0, 1)
+
+	expectDueSession(mock, scheduledAt, true)
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "planned_sessions" SET "missed_at"=\$1,"reminded_at"=\$2,"reschedules"=\$3,"scheduled_at"=\$4,"updated_at"=\$5 WHERE \(id = \$6 AND status = \$7 AND scheduled_at = \$8\)`).
+		WithArgs(fixedTime, nil, 1, next, sqlmock.AnyArg(), int64(5), "scheduled", scheduledAt).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	require.NoError(t, p.ReportMissed(context.Background()))
+
+	require.Len(t, push.sent, 1)
+	m := push.sent[0]
+	assert.Equal(t, events.TypeSessionMissed, m.Kind)
+	assert.Contains(t, m.Text, "moved to Monday 1 January at 17:00 CET")
+	data := m.Data.(planner.SessionPayload)
+	assert.Equal(t, pub(5), data.SessionID)
+	assert.Equal(t, "scheduled", data.Status)
+	assert.True(t, next.Equal(*data.RescheduledTo))
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+// decryptPush undoes notify.EncryptPush as a browser would (RFC 8291).
+func decryptPush(t *testing.T, ua *ecdh.PrivateKey, authSecret, body []byte) []byte {
+	t.Helper()
+	salt, rs, idlen := body[:16], binary.BigEndian.Uint32(body[16:20]), int(body[20])
+	assert.EqualValues(t, 4096, rs)
+	asPublic := body[21 : 21+idlen]
+	as, err := ecdh.P256().NewPublicKey(asPublic)
+	require.NoError(t, err)
+	shared, err := ua.ECDH(as)
+	require.NoError(t, err)
+	info := "WebPush: info\x00" + string(ua.PublicKey().Bytes()) + string(asPublic)
+	ikm, err := hkdf.Key(sha256.New, shared, authSecret, info, 32)
+	require.NoError(t, err)
+	cek, err := hkdf.Key(sha256.New, ikm, salt, "Content-Encoding: aes128gcm\x00", 16)
+	require.NoError(t, err)
+	nonce, err := hkdf.Key(sha256.New, ikm, salt, "Content-Encoding: nonce\x00", 12)
+	require.NoError(t, err)
+	block, err := aes.NewCipher(cek)
+	require.NoError(t, err)
+	gcm, err := cipher.NewGCM(block)
+	require.NoError(t, err)
+	plain, err := gcm.Open(nil, nonce, body[21+idlen:], nil)
+	require.NoError(t, err)
+	require.Equal(t, byte(0x02), plain[len(plain)-1], "last record delimiter")
+	return plain[:len(plain)-1]
+}
+
+func TestWebPush_SendsEncryptedPayloadAndDropsGoneSubscriptions(t *testing.T) {
+	ua, err := ecdh.P256().GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	authSecret := make([]byte, 16)
+	_, _ = rand.Read(authSecret)
+	vapid, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	vapidRaw, err := vapid.Bytes()
+	require.NoError(t, err)
+
+	var got []byte
+	var authHeader string
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/gone" {
+			w.WriteHeader(http.StatusGone)
+			return
+		}
+		assert.Equal(t, "aes128gcm", r.Header.Get("Content-Encoding"))
+		assert.Equal(t, "86400", r.Header.Get("TTL"))
+		authHeader = r.Header.Get("Authorization")
+		got, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer srv.Close()
+
+	db, mock := newMockDB(t)
+	enc := base64.RawURLEncoding
+	push, err := notify.NewWebPush(db, enc.EncodeToString(vapidRaw), "mailto:ops@example.com")
+	require.NoError(t, err)
+	// The test server listens on loopback, which the default client refuses.
+	push.Client = srv.Client()
+
+	mock.ExpectQuery(`SELECT \* FROM "push_subscriptions" WHERE user_id = \$1`).
+		WithArgs(int64(1)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "updated_at", "deleted_at", "user_id", "endpoint", "p256dh", "auth"}).
+			AddRow(int64(1), fixedTime, fixedTime, nil, int64(1), srv.URL+"/ok", enc.EncodeToString(ua.PublicKey().Bytes()), enc.EncodeToString(authSecret)).
+			AddRow(int64(2), fixedTime, fixedTime, nil, int64(1), srv.URL+"/gone", enc.EncodeToString(ua.PublicKey().Bytes()), enc.EncodeToString(authSecret)))
+	mock.ExpectBegin()
+	mock.ExpectExec(`DELETE FROM "push_subscriptions" WHERE "push_subscriptions"."id" = \$1`).
+		WithArgs(int64(2)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	err = push.Send(context.Background(), notify.Recipient{UserID: 1}, notify.Message{
+		Kind: events.TypeSessionReminder, Subject: "Reminder: Long run at 07:00", Text: "Long run is planned.",
+	})
+
+	require.NoError(t, err)
+	var payload notify.PushPayload
+	require.NoError(t, json.Unmarshal(decryptPush(t, ua, authSecret, got), &payload))
+	assert.Equal(t, "Reminder: Long run at 07:00", payload.Title)
+	assert.Equal(t, events.TypeSessionReminder, payload.Kind)
+	assert.True(t, strings.HasPrefix(authHeader, "vapid t="), authHeader)
+	assert.Contains(t, authHeader, ", k="+push.PublicKey())
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCreatePushSubscription_RejectsUnsafeEndpoints(t *testing.T) {
+	for _, endpoint := range []string{
+		"http://fcm.googleapis.com/fcm/send/abc",
+		"https://169.254.169.254/latest/meta-data",
+		"https://10.0.0.5/push",
+		"https://localhost/push",
+	} {
+		t.Run(endpoint, func(t *testing.T) {
+			db, mock := newMockDB(t)
+			api := newTestAPI(t, db)
+
+			resp := api.Post("/api/v1/push-subscriptions", map[string]any{
+				"user_id":  pub(1),
+				"endpoint": endpoint,
+				"keys":     map[string]any{"p256dh": "BPk", "auth": "c2Vj"},
+			})
+
+			assert.Equal(t, http.StatusUnprocessableEntity, resp.Code, resp.Body.String())
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestUpdatePlan_MergePatchClearsAndZeroes(t *testing.T) {
+	db, mock := newMockDB(t)
+	api := newTestAPI(t, db)
+
+	mock.ExpectQuery(`SELECT \* FROM "planned_workouts" WHERE public_id = \$1`).
+		WithArgs(pub(3), 1).
+		WillReturnRows(sqlmock.NewRows(plannedWorkoutCols()).
+			AddRow(int64(3), pub(3).String(), fixedTime, fixedTime, nil, int64(1), "Long run", "Easy pace", 60, fixedTime, "UTC", "",
+				`["email","push"]`, 60, true))
+	mock.ExpectBegin()
+	// The schedule is unchanged, so no sessions are regenerated.
+	mock.ExpectExec(`UPDATE "planned_workouts" SET`).
+		WithArgs(pub(3), fixedTime, sqlmock.AnyArg(), nil, 0, int64(1), "Long run", "", 60, fixedTime, "UTC", "", nil, 0, false, int64(3)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	expectPublicIDs(mock, "users", 1)
+
+	resp := api.Patch("/api/v1/planned-workouts/"+pub(3).String(), "Content-Type: application/merge-patch+json",
+		map[string]any{"description": nil, "reminder_channels": nil, "auto_reschedule": false, "remind_minutes_before": 0})
+
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	require.NoError(t, mock.ExpectationsWereMet())
+}
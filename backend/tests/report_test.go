@@ -0,0 +1,230 @@
+package backend_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"workout-tracker/backend/models"
+	"workout-tracker/backend/reports"
+	"workout-tracker/backend/schemas"
+)
+
+func TestReportBounds(t *testing.T) {
+	tests := []struct {
+		name       string
+		period     string
+		at         time.Time
+		start, end time.Time
+	}{
+		{"sunday belongs to the week before", models.ReportPeriodWeekly,
+			time.Date(2024, 1, 14, 23, 0, 0, 0, time.UTC),
+			time.Date(2024, 1, 8, 0, 0, 0, 0, time.UTC), time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)},
+		{"monday starts a week", models.ReportPeriodWeekly,
+			time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC),
+			time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC), time.Date(2024, 1, 22, 0, 0, 0, 0, time.UTC)},
+		{"week across a year boundary", models.ReportPeriodWeekly,
+			time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC),
+			time.Date(2024, 12, 30, 0, 0, 0, 0, time.UTC), time.Date(2025, 1, 6, 0, 0, 0, 0, time.UTC)},
+		{"leap february", models.ReportPeriodMonthly,
+			time.Date(2024, 2, 29, 8, 0, 0, 0, time.UTC),
+			time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, end, err := reports.Bounds(tt.period, tt.at)
+			require.NoError(t, err)
+			assert.Equal(t, tt.start, start)
+			assert.Equal(t, tt.end, end)
+		})
+	}
+
+	_, _, err := reports.Bounds("daily", fixedTime)
+	assert.ErrorIs(t, err, reports.ErrUnknownPeriod)
+}
+
+func TestGetReport_Stored(t *testing.T) {
+	db, mock := newMockDB(t)
+	api := newTestAPI(t, db)
+
+	start := time.Date(2024, 1, 8, 0, 0, 0, 0, time.UTC)
+	data, err := json.Marshal(schemas.ReportData{
+		Period: models.ReportPeriodWeekly, Start: start, End: start.AddDate(0, 0, 7),
+		Totals: schemas.ReportTotals{Sessions: 3, TotalMinutes: 150},
+	})
+	require.NoError(t, err)
+
+	expectUserLookup(mock, 5)
+	expectPublicIDs(mock, "users", 5)
+	mock.ExpectQuery(`SELECT \* FROM "reports" WHERE user_id = \$1 AND period = \$2 AND period_start = \$3 ORDER BY "reports"."id" LIMIT \$4`).
+		WithArgs(5, models.ReportPeriodWeekly, start, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "public_id", "created_at", "updated_at", "user_id", "period", "period_start", "period_end", "data"}).
+			AddRow(12, pub(12).String(), fixedTime, fixedTime, 5, models.ReportPeriodWeekly, start, start.AddDate(0, 0, 7), data))
+
+	// Any day of the week finds the report.
+	resp := api.Get("/api/v1/me/reports/weekly?userId=" + pub(5).String() + "&start=2024-01-10")
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	var got schemas.ReportResponse
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &got))
+	require.NotNil(t, got.ID)
+	assert.Equal(t, pub(12), *got.ID)
+	assert.Equal(t, pub(5), got.UserID)
+	assert.False(t, got.Partial)
+	assert.Equal(t, 3, got.Totals.Sessions)
+	assert.Equal(t, start, got.Start)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+// expectReportBuild mocks the queries reports.Build runs for a week in
+// which user 5 logged two workouts, one of them a longer-than-ever run and
+// the other a heavier-than-ever squat after a warm-up set.
+func expectReportBuild(mock sqlmock.Sqlmock, start time.Time) {
+	totalsCols := []string{"sessions", "total_minutes", "total_distance_meters", "active_days", "working_sets", "total_volume_kg"}
+	mock.ExpectQuery(`SELECT COUNT\(\*\) AS sessions, .* FROM "workouts" LEFT JOIN LATERAL \(.* WHERE NOT COALESCE\(\(s->>'warmup'\)::boolean, false\) \) lifted ON true `+
+		`WHERE \(user_id = \$1 AND performed_at >= \$2 AND performed_at < \$3\)`).
+		WithArgs(5, start, start.AddDate(0, 0, 7)).
+		WillReturnRows(sqlmock.NewRows(totalsCols).AddRow(2, 130, 12000, 2, 2, 1000))
+	mock.ExpectQuery(`SELECT COUNT\(\*\) AS sessions, .* FROM "workouts"`).
+		WithArgs(5, start.AddDate(0, 0, -7), start).
+		WillReturnRows(sqlmock.NewRows(totalsCols).AddRow(1, 100, 0, 1, 0, 0))
+	mock.ExpectQuery(`SELECT \* FROM "workouts" WHERE \(user_id = \$1 AND performed_at >= \$2 AND performed_at < \$3\) AND "workouts"."deleted_at" IS NULL ORDER BY performed_at, id`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "public_id", "user_id", "name", "duration_minutes", "distance_meters", "performed_at", "tags", "custom_fields", "exercises"}).
+			AddRow(20, pub(20).String(), 5, "Easy <run>", 40, 0, start.Add(8*time.Hour), `["deload"]`, `{"sleep_hours":6,"mood":"meh"}`,
+				`[{"name":"Squat","sets":[{"reps":5,"weight_kg":60,"warmup":true},{"reps":5,"weight_kg":100},{"reps":5,"weight_kg":100,"rir":1}]}]`).
+			AddRow(21, pub(21).String(), 5, "Long run", 90, 12000, start.AddDate(0, 0, 6), `["deload","travel"]`, `{"sleep_hours":8.5,"gone":1}`, `[]`))
+	mock.ExpectQuery(`SELECT \* FROM "custom_fields" WHERE user_id = \$1 AND "custom_fields"."deleted_at" IS NULL ORDER BY key`).
+		WithArgs(5).
+		WillReturnRows(sqlmock.NewRows(customFieldCols()).
+			AddRow(int64(2), pub(2).String(), fixedTime, fixedTime, nil, 1, int64(5), "mood", "Mood", "enum", `["good","meh","bad"]`, "").
+			AddRow(int64(1), pub(1).String(), fixedTime, fixedTime, nil, 1, int64(5), "sleep_hours", "Sleep", "number", nil, "h"))
+	mock.ExpectQuery(`SELECT \* FROM \(.* WINDOW prior AS .*\) history WHERE performed_at >= \$3`).
+		WithArgs(5, start.AddDate(0, 0, 7), start).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "public_id", "name", "performed_at", "duration_minutes", "distance_meters", "prior", "best_duration", "best_distance"}).
+			AddRow(20, pub(20).String(), "Easy <run>", start.Add(8*time.Hour), 40, 0, 5, 100, 10000).
+			AddRow(21, pub(21).String(), "Long run", start.AddDate(0, 0, 6), 90, 12000, 6, 100, 10000))
+	mock.ExpectQuery(`SELECT \* FROM \(.* CROSS JOIN LATERAL \(.* WHERE s->>'weight_kg' IS NOT NULL AND NOT COALESCE\(\(s->>'warmup'\)::boolean, false\) .* `+
+		`WINDOW prior AS \(PARTITION BY lift.exercise_key .*\) history WHERE performed_at >= \$3 AND prior > 0 AND weight > best`).
+		WithArgs(5, start.AddDate(0, 0, 7), start).
+		WillReturnRows(sqlmock.NewRows([]string{"public_id", "name", "performed_at", "exercise", "weight", "prior", "best"}).
+			AddRow(pub(20).String(), "Easy <run>", start.Add(8*time.Hour), "Squat", 100.0, 3, 95.0))
+	mock.ExpectQuery(`SELECT COUNT\(\*\) AS planned, .* FROM "planned_sessions" WHERE \(user_id = \$4 AND scheduled_at >= \$5 AND scheduled_at < \$6\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"planned", "completed", "skipped", "missed"}).AddRow(4, 3, 0, 1))
+}
+
+func TestGetReport_GeneratesAndStoresMissingReport(t *testing.T) {
+	db, mock := newMockDB(t)
+	api := newTestAPI(t, db)
+	start := time.Date(2024, 1, 8, 0, 0, 0, 0, time.UTC)
+
+	expectUserLookup(mock, 5)
+	expectPublicIDs(mock, "users", 5)
+	mock.ExpectQuery(`SELECT \* FROM "reports"`).WillReturnRows(sqlmock.NewRows(nil))
+	expectReportBuild(mock, start)
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO "reports" .* ON CONFLICT \("user_id","period","period_start"\) DO UPDATE SET "period_end"="excluded"."period_end","data"="excluded"."data","updated_at"="excluded"."updated_at"`).
+		WillReturnResult(sqlmock.NewResult(13, 1))
+	mock.ExpectCommit()
+
+	resp := api.Get("/api/v1/me/reports/weekly?userId=" + pub(5).String() + "&start=2024-01-08")
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	var got schemas.ReportResponse
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &got))
+
+	assert.Equal(t, schemas.ReportTotals{Sessions: 2, TotalMinutes: 130, TotalDistanceMeters: 12000, ActiveDays: 2, WorkingSets: 2, TotalVolumeKg: 1000}, got.Totals)
+	assert.Equal(t, 1, got.Comparison.SessionsDelta)
+	assert.Equal(t, 30, got.Comparison.TotalMinutesDelta)
+	require.NotNil(t, got.Comparison.TotalMinutesPercent)
+	assert.InDelta(t, 30.0, *got.Comparison.TotalMinutesPercent, 0.001)
+	assert.Equal(t, 1000.0, got.Comparison.TotalVolumeDelta)
+	assert.Equal(t, []schemas.ReportRecord{
+		{WorkoutID: pub(20), WorkoutName: "Easy <run>", PerformedAt: start.Add(8 * time.Hour), Metric: reports.MetricWeight, Exercise: "Squat", Value: 100, Previous: 95},
+		{WorkoutID: pub(21), WorkoutName: "Long run", PerformedAt: start.AddDate(0, 0, 6), Metric: reports.MetricDistance, Value: 12000, Previous: 10000},
+	}, got.PersonalRecords)
+	assert.Equal(t, schemas.ReportGoals{Planned: 4, Completed: 3, Missed: 1, Progress: 0.75}, got.Goals)
+	require.Len(t, got.Workouts, 2)
+	// The warm-up set counts towards neither sets nor volume.
+	assert.Equal(t, 2, got.Workouts[0].WorkingSets)
+	assert.Equal(t, 1000.0, got.Workouts[0].VolumeKg)
+	assert.Equal(t, []schemas.ReportTag{
+		{Tag: "deload", Sessions: 2, TotalMinutes: 130, TotalDistanceMeters: 12000, TotalVolumeKg: 1000},
+		{Tag: "travel", Sessions: 1, TotalMinutes: 90, TotalDistanceMeters: 12000},
+	}, got.Tags)
+	sum, avg, lo, hi := 14.5, 7.25, 6.0, 8.5
+	assert.Equal(t, []schemas.ReportField{
+		{Key: "mood", Name: "Mood", Type: "enum", Count: 1, Values: []schemas.ReportFieldValue{{Value: "meh", Count: 1}}},
+		{Key: "sleep_hours", Name: "Sleep", Type: "number", Unit: "h", Count: 2, Sum: &sum, Average: &avg, Min: &lo, Max: &hi},
+	}, got.CustomFields)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetReportDocument_RendersHTML(t *testing.T) {
+	db, mock := newMockDB(t)
+	api := newTestAPI(t, db)
+	start := time.Date(2024, 1, 8, 0, 0, 0, 0, time.UTC)
+
+	expectUserLookup(mock, 5)
+	expectPublicIDs(mock, "users", 5)
+	mock.ExpectQuery(`SELECT \* FROM "reports"`).WillReturnRows(sqlmock.NewRows(nil))
+	expectReportBuild(mock, start)
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO "reports"`).WillReturnResult(sqlmock.NewResult(13, 1))
+	mock.ExpectCommit()
+
+	resp := api.Get("/api/v1/me/reports/weekly/document?userId=" + pub(5).String() + "&start=2024-01-08")
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	assert.Equal(t, "text/html; charset=utf-8", resp.Header().Get("Content-Type"))
+	assert.Equal(t, `attachment; filename="weekly-report-2024-01-08.html"`, resp.Header().Get("Content-Disposition"))
+	body := resp.Body.String()
+	assert.Contains(t, body, "Weekly report: week of 8 January 2024")
+	assert.Contains(t, body, "Easy &lt;run&gt;")
+	assert.Contains(t, body, "3 of 4 planned sessions completed (75%)")
+	assert.Contains(t, body, "<td>12.00 km</td><td>10.00 km</td>")
+	assert.Contains(t, body, "<td>travel</td><td>1</td><td>1h 30m</td>")
+	assert.Contains(t, body, "<td>Easy &lt;run&gt; · Squat</td><td>Mon 8 Jan 2024</td><td>100 kg</td><td>95 kg</td>")
+	assert.Contains(t, body, "<strong>1000 kg</strong>volume in 2 working sets")
+	assert.Contains(t, body, "<td>Sleep</td><td>2</td><td>average 7.25 h, 6–8.5</td>")
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetReport_FuturePeriodRejected(t *testing.T) {
+	db, mock := newMockDB(t)
+	api := newTestAPI(t, db)
+
+	expectUserLookup(mock, 5)
+
+	resp := api.Get("/api/v1/me/reports/monthly?userId=" + pub(5).String() + "&start=2999-01-01")
+	assert.Equal(t, http.StatusUnprocessableEntity, resp.Code)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestStoreReport_KeepsIDOfReplacedReport(t *testing.T) {
+	// Unlike newMockDB, this database issues RETURNING clauses.
+	sqlDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { sqlDB.Close() })
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	require.NoError(t, err)
+	start := time.Date(2024, 1, 8, 0, 0, 0, 0, time.UTC)
+
+	// The stored row already existed, so the update keeps its IDs.
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO "reports" .* ON CONFLICT .* DO UPDATE SET .* RETURNING "id","public_id","created_at"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "public_id", "created_at"}).AddRow(int64(13), pub(13).String(), fixedTime))
+	mock.ExpectCommit()
+
+	r, err := reports.Store(db, 5, schemas.ReportData{Period: models.ReportPeriodWeekly, Start: start, End: start.AddDate(0, 0, 7)})
+
+	require.NoError(t, err)
+	assert.Equal(t, int64(13), r.ID)
+	assert.Equal(t, pub(13), r.PublicID)
+	assert.Equal(t, fixedTime, r.CreatedAt)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,147 @@
+package backend_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"workout-tracker/backend/schemas"
+)
+
+// revisionCols returns the column names that GORM scans for a WorkoutRevision row.
+func revisionCols() []string {
+	return []string{"id", "created_at", "workout_id", "revision", "author_id", "restored_from", "snapshot"}
+}
+
+// snapshot returns a stored revision of workout 4.
+func snapshot(name string, minutes int) []byte {
+	return fmt.Appendf(nil, `{"id":%q,"user_id":%q,"name":%q,"duration_minutes":%d,`+
+		`"performed_at":"2024-01-01T12:00:00Z","visibility":"private",`+
+		`"created_at":"2024-01-01T12:00:00Z","updated_at":"2024-01-02T12:00:00Z"}`, pub(4), pub(1), name, minutes)
+}
+
+func expectWorkout(mock sqlmock.Sqlmock) {
+	mock.ExpectQuery(`SELECT \* FROM "workouts" WHERE public_id = \$1`).
+		WillReturnRows(sqlmock.NewRows(workoutCols()).
+			AddRow(int64(4), pub(4).String(), fixedTime, fixedTime, nil, int64(1), "Tempo run", "", 50))
+}
+
+func TestListRevisions(t *testing.T) {
+	db, mock := newMockDB(t)
+	api := newTestAPI(t, db)
+
+	expectWorkout(mock)
+	mock.ExpectQuery(`SELECT \* FROM "workout_revisions" WHERE workout_id = \$1 ORDER BY revision DESC`).
+		WithArgs(4).
+		WillReturnRows(sqlmock.NewRows(revisionCols()).
+			AddRow(int64(8), fixedTime, int64(4), 2, int64(7), nil, snapshot("Tempo run", 50)).
+			AddRow(int64(5), fixedTime, int64(4), 1, int64(1), nil, snapshot("Easy run", 40)))
+	expectPublicIDs(mock, "users", 1, 7)
+
+	resp := api.Get("/api/v1/workouts/" + pub(4).String() + "/revisions")
+
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	var got []schemas.WorkoutRevisionResponse
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &got))
+	require.Len(t, got, 2)
+	assert.Equal(t, 2, got[0].Revision)
+	require.NotNil(t, got[0].AuthorID)
+	assert.Equal(t, pub(7), *got[0].AuthorID)
+	assert.Equal(t, pub(4), got[0].Workout.ID)
+	assert.Equal(t, "Tempo run", got[0].Workout.Name)
+	assert.Equal(t, "Easy run", got[1].Workout.Name)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDiffRevisions_AgainstPrevious(t *testing.T) {
+	db, mock := newMockDB(t)
+	api := newTestAPI(t, db)
+
+	expectWorkout(mock)
+	mock.ExpectQuery(`SELECT \* FROM "workout_revisions" WHERE workout_id = \$1 AND revision = \$2`).
+		WithArgs(4, 2, 1).
+		WillReturnRows(sqlmock.NewRows(revisionCols()).
+			AddRow(int64(8), fixedTime, int64(4), 2, int64(7), nil, snapshot("Tempo run", 50)))
+	mock.ExpectQuery(`SELECT \* FROM "workout_revisions" WHERE workout_id = \$1 AND revision = \$2`).
+		WithArgs(4, 1, 1).
+		WillReturnRows(sqlmock.NewRows(revisionCols()).
+			AddRow(int64(5), fixedTime, int64(4), 1, int64(1), nil, snapshot("Easy run", 40)))
+
+	resp := api.Get("/api/v1/workouts/" + pub(4).String() + "/revisions/2/diff")
+
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	var got schemas.WorkoutRevisionDiff
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &got))
+	assert.Equal(t, 1, got.From)
+	assert.Equal(t, 2, got.To)
+	// Timestamps every write touches are not reported.
+	assert.Equal(t, map[string]schemas.FieldChange{
+		"name":             {Old: "Easy run", New: "Tempo run"},
+		"duration_minutes": {Old: float64(40), New: float64(50)},
+	}, got.Changes)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDiffRevisions_UnknownRevision(t *testing.T) {
+	db, mock := newMockDB(t)
+	api := newTestAPI(t, db)
+
+	expectWorkout(mock)
+	mock.ExpectQuery(`SELECT \* FROM "workout_revisions"`).
+		WillReturnRows(sqlmock.NewRows(revisionCols()))
+
+	resp := api.Get("/api/v1/workouts/" + pub(4).String() + "/revisions/9/diff")
+
+	assert.Equal(t, http.StatusNotFound, resp.Code)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRestoreRevision(t *testing.T) {
+	db, mock := newMockDB(t)
+	api := newTestAPI(t, db)
+
+	expectWorkout(mock)
+	mock.ExpectQuery(`SELECT \* FROM "workout_revisions" WHERE workout_id = \$1 AND revision = \$2`).
+		WithArgs(4, 1, 1).
+		WillReturnRows(sqlmock.NewRows(revisionCols()).
+			AddRow(int64(5), fixedTime, int64(4), 1, int64(1), nil, snapshot("Easy run", 40)))
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "workouts" SET .*"name"=\$\d+,"description"=\$\d+,"duration_minutes"=\$\d+`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectPublicIDs(mock, "users", 1)
+	mock.ExpectQuery(`SELECT COALESCE\(MAX\(revision\), 0\) FROM "workout_revisions"`).
+		WillReturnRows(sqlmock.NewRows([]string{"coalesce"}).AddRow(2))
+	mock.ExpectExec(`INSERT INTO "workout_revisions" \("created_at","workout_id","revision","author_id","restored_from","snapshot"\)`).
+		WithArgs(sqlmock.AnyArg(), 4, 3, nil, 1, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(9, 1))
+	mock.ExpectCommit()
+
+	resp := api.Post("/api/v1/workouts/" + pub(4).String() + "/revisions/1/restore")
+
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	var got schemas.WorkoutResponse
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &got))
+	assert.Equal(t, "Easy run", got.Name)
+	assert.Equal(t, 40, got.DurationMinutes)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRestoreRevision_OtherUsersWorkoutIsHidden(t *testing.T) {
+	db, mock := newMockDB(t)
+	api := newAuthedTestAPI(t, db, "zitadel-sub-5")
+
+	expectWorkout(mock)
+	mock.ExpectQuery(`SELECT \* FROM "users" WHERE zitadel_id`).
+		WillReturnRows(sqlmock.NewRows(userCols()).
+			AddRow(int64(5), pub(5).String(), fixedTime, fixedTime, nil, "zitadel-sub-5", "m@example.com", "Member", ""))
+
+	resp := api.Post("/api/v1/workouts/" + pub(4).String() + "/revisions/1/restore")
+
+	assert.Equal(t, http.StatusNotFound, resp.Code)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,131 @@
+package backend_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"workout-tracker/backend/models"
+	"workout-tracker/backend/schemas"
+	"workout-tracker/backend/search"
+)
+
+func searchCols() []string {
+	return []string{"kind", "id", "workout_id", "title", "performed_at", "rank", "title_headline", "snippet_headline"}
+}
+
+func TestPrefixQuery(t *testing.T) {
+	tests := []struct{ text, want string }{
+		{"Tempo ru", "'tempo' & 'ru':*"},
+		{"5k: it's ", "'5k' & 'it' & 's':*"},
+		{"'); DROP", "'drop':*"},
+		{"Über", "'über':*"},
+		{" -- ", ""},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, search.PrefixQuery(tt.text), tt.text)
+	}
+}
+
+func TestSearch_RanksAndHighlights(t *testing.T) {
+	db, mock := newMockDB(t)
+	api := newTestAPI(t, db)
+
+	mock.ExpectQuery(`WITH q AS \(SELECT websearch_to_tsquery\('english', \$1\) AS query, 'english'::regconfig AS config\) `+
+		`SELECT page.kind, .* ts_headline\(q.config, page.title, q.query, \$2\) AS title_headline, `+
+		`ts_headline\(q.config, page.snippet, q.query, \$3\) AS snippet_headline `+
+		`FROM q, \(SELECT \* FROM \(SELECT 'workout' AS kind, .* FROM q, workouts w WHERE w.search_vector @@ q.query AND w.deleted_at IS NULL `+
+		`UNION ALL SELECT 'exercise' AS kind, .* WHERE w.exercise_search_vector @@ q.query AND v.vector @@ q.query AND w.deleted_at IS NULL\) matches `+
+		`ORDER BY rank DESC, performed_at DESC, id LIMIT \$4 OFFSET \$5\) page `+
+		`ORDER BY page.rank DESC, page.performed_at DESC, page.id`).
+		WithArgs("tempo run", sqlmock.AnyArg(), sqlmock.AnyArg(), 2, 0).
+		WillReturnRows(sqlmock.NewRows(searchCols()).
+			AddRow(search.KindWorkout, pub(4).String(), pub(4).String(), "Tempo run", fixedTime, 0.6,
+				"\uE000Tempo\uE001 \uE000run\uE001", "").
+			AddRow(search.KindWorkout, pub(7).String(), pub(7).String(), "Long Sunday", fixedTime, 0.2,
+				"Long Sunday", "easy pace, then a \uE000tempo\uE001 finish"))
+
+	resp := api.Get("/api/v1/search?q=tempo+run&limit=1")
+
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	var page schemas.SearchPage
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &page))
+	require.Len(t, page.Items, 1)
+	got := page.Items[0]
+	assert.Equal(t, pub(4), got.ID)
+	assert.Equal(t, pub(4), got.WorkoutID)
+	assert.Equal(t, []schemas.HighlightFragment{
+		{Text: "Tempo", Match: true}, {Text: " "}, {Text: "run", Match: true},
+	}, got.TitleHighlight)
+	assert.Empty(t, got.Snippet)
+	assert.NotEmpty(t, page.NextCursor)
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	// The next page carries on after the first result.
+	mock.ExpectQuery(`WITH q AS`).
+		WithArgs("tempo run", sqlmock.AnyArg(), sqlmock.AnyArg(), 2, 1).
+		WillReturnRows(sqlmock.NewRows(searchCols()).
+			AddRow(search.KindWorkout, pub(7).String(), pub(7).String(), "Long Sunday", fixedTime, 0.2,
+				"Long Sunday", "easy pace, then a \uE000tempo\uE001 finish"))
+
+	resp = api.Get("/api/v1/search?q=tempo+run&limit=1&cursor=" + page.NextCursor)
+
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	page = schemas.SearchPage{}
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &page))
+	require.Len(t, page.Items, 1)
+	assert.Equal(t, []schemas.HighlightFragment{
+		{Text: "easy pace, then a "}, {Text: "tempo", Match: true}, {Text: " finish"},
+	}, page.Items[0].Snippet)
+	assert.Empty(t, page.NextCursor)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSearch_PrefixScopedToViewer(t *testing.T) {
+	db, mock := newMockDB(t)
+	api := newAuthedTestAPI(t, db, "zitadel-sub-5")
+
+	mock.ExpectQuery(`SELECT \* FROM "users" WHERE zitadel_id`).
+		WillReturnRows(sqlmock.NewRows(userCols()).
+			AddRow(int64(5), pub(5).String(), fixedTime, fixedTime, nil, "zitadel-sub-5", "m@example.com", "Member", ""))
+	// Both workouts and exercises are limited to the workouts the viewer
+	// may see.
+	mock.ExpectQuery(`WITH q AS \(SELECT to_tsquery\('simple', \$1\) AS query, 'simple'::regconfig AS config\) .*`+
+		`AND \(w.user_id = \$4 OR w.visibility = \$5 OR \(w.visibility = \$6 AND w.user_id IN \(SELECT followee_id FROM follows WHERE follower_id = \$7 AND deleted_at IS NULL\)\)\) `+
+		`UNION ALL .* AND \(w.user_id = \$8 OR w.visibility = \$9 OR \(w.visibility = \$10 AND w.user_id IN \(SELECT followee_id FROM follows WHERE follower_id = \$11 AND deleted_at IS NULL\)\)\)\) matches`).
+		WithArgs("'tempo' & 'ru':*", sqlmock.AnyArg(), sqlmock.AnyArg(),
+			int64(5), models.VisibilityPublic, models.VisibilityFollowers, int64(5),
+			int64(5), models.VisibilityPublic, models.VisibilityFollowers, int64(5), 21, 0).
+		WillReturnRows(sqlmock.NewRows(searchCols()))
+
+	resp := api.Get("/api/v1/search?q=Tempo+ru&prefix=true")
+
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	assert.JSONEq(t, `{"items":[]}`, resp.Body.String())
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSearch_PrefixWithoutWordsMatchesNothing(t *testing.T) {
+	db, mock := newMockDB(t)
+	api := newTestAPI(t, db)
+
+	resp := api.Get("/api/v1/search?q=--&prefix=true")
+
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	assert.JSONEq(t, `{"items":[]}`, resp.Body.String())
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSearch_InvalidCursor(t *testing.T) {
+	db, mock := newMockDB(t)
+	api := newTestAPI(t, db)
+
+	resp := api.Get("/api/v1/search?q=run&cursor=LTE")
+
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,173 @@
+package backend_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"workout-tracker/backend"
+	"workout-tracker/backend/notify"
+	"workout-tracker/backend/schemas"
+)
+
+// recordingNotifier captures notifications for assertions.
+type recordingNotifier struct {
+	got []notify.Notification
+}
+
+func (r *recordingNotifier) Notify(_ context.Context, n notify.Notification) error {
+	r.got = append(r.got, n)
+	return nil
+}
+
+func TestFollow_CannotFollowSelf(t *testing.T) {
+	db, mock := newMockDB(t)
+	api := newTestAPI(t, db)
+
+	expectUserLookup(mock, 1)
+	mock.ExpectQuery(`SELECT \* FROM "users" WHERE public_id = \$1`).
+		WillReturnRows(sqlmock.NewRows(userCols()).
+			AddRow(int64(1), pub(1).String(), fixedTime, fixedTime, nil, nil, "alice@example.com", "Alice", ""))
+
+	resp := api.Post("/api/v1/users/"+pub(1).String()+"/follow", map[string]any{"follower_id": pub(1)})
+
+	assert.Equal(t, http.StatusUnprocessableEntity, resp.Code)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestFollow_NotifiesFollowee(t *testing.T) {
+	db, mock := newMockDB(t)
+	rec := &recordingNotifier{}
+	api := newTestAPI(t, db, backend.WithNotifier(rec))
+
+	expectUserLookup(mock, 1)
+	mock.ExpectQuery(`SELECT \* FROM "users"`).
+		WillReturnRows(sqlmock.NewRows(userCols()).
+			AddRow(int64(2), pub(2).String(), fixedTime, fixedTime, nil, nil, "bob@example.com", "Bob", ""))
+	mock.ExpectQuery(`SELECT \* FROM "follows"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "follower_id", "followee_id"}))
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO "follows"`).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	resp := api.Post("/api/v1/users/"+pub(2).String()+"/follow", map[string]any{"follower_id": pub(1)})
+
+	assert.Equal(t, http.StatusNoContent, resp.Code)
+	require.Len(t, rec.got, 1)
+	assert.Equal(t, notify.Notification{Kind: notify.KindFollow, RecipientID: 2, ActorID: 1}, rec.got[0])
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestFeed_PaginatesWithCursor(t *testing.T) {
+	db, mock := newMockDB(t)
+	api := newTestAPI(t, db)
+
+	older := fixedTime.Add(-time.Hour)
+	expectUserLookup(mock, 1)
+	mock.ExpectQuery(`SELECT \* FROM "workouts" WHERE user_id IN \(SELECT "followee_id" FROM "follows" WHERE follower_id = \$1.*\) AND visibility IN .*ORDER BY created_at DESC, id DESC LIMIT \$\d+`).
+		WillReturnRows(sqlmock.NewRows(append(workoutCols(), "visibility")).
+			AddRow(int64(9), pub(9).String(), fixedTime, fixedTime, nil, int64(2), "Leg Day", "", 50, "followers").
+			AddRow(int64(7), pub(7).String(), older, older, nil, int64(3), "Long Run", "", 90, "public"))
+	mock.ExpectQuery(`SELECT \* FROM "users"`).
+		WillReturnRows(sqlmock.NewRows(userCols()).
+			AddRow(int64(2), pub(2).String(), fixedTime, fixedTime, nil, nil, "bob@example.com", "Bob", ""))
+	mock.ExpectQuery(`SELECT workout_id, COUNT\(\*\) AS n FROM "workout_likes"`).
+		WillReturnRows(sqlmock.NewRows([]string{"workout_id", "n"}).AddRow(int64(9), int64(4)))
+	mock.ExpectQuery(`SELECT workout_id, COUNT\(\*\) AS n FROM "workout_comments"`).
+		WillReturnRows(sqlmock.NewRows([]string{"workout_id", "n"}))
+	mock.ExpectQuery(`SELECT "workout_id" FROM "workout_likes"`).
+		WillReturnRows(sqlmock.NewRows([]string{"workout_id"}).AddRow(int64(9)))
+
+	resp := api.Get("/api/v1/feed?userId=" + pub(1).String() + "&limit=1")
+
+	require.Equal(t, http.StatusOK, resp.Code)
+	var body schemas.FeedPage
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &body))
+	require.Len(t, body.Items, 1)
+	assert.Equal(t, "Leg Day", body.Items[0].Name)
+	assert.Equal(t, "Bob", body.Items[0].AuthorName)
+	assert.Equal(t, int64(4), body.Items[0].LikeCount)
+	assert.True(t, body.Items[0].LikedByMe)
+	require.NotEmpty(t, body.NextCursor)
+
+	// The cursor names the last workout by public ID, resolved again here.
+	expectUserLookup(mock, 1)
+	mock.ExpectQuery(`SELECT "id" FROM "workouts" WHERE public_id = \$1`).
+		WithArgs(pub(9), 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(int64(9)))
+	mock.ExpectQuery(`SELECT \* FROM "workouts" WHERE .*\(created_at, id\) < \(\$\d+, \$\d+\)`).
+		WillReturnRows(sqlmock.NewRows(append(workoutCols(), "visibility")))
+	resp = api.Get("/api/v1/feed?userId=" + pub(1).String() + "&limit=1&cursor=" + body.NextCursor)
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetWorkout_PrivateHiddenFromOthers(t *testing.T) {
+	db, mock := newMockDB(t)
+	api := newAuthedTestAPI(t, db, "zitadel-sub-5")
+
+	mock.ExpectQuery(`SELECT \* FROM "workouts"`).
+		WillReturnRows(sqlmock.NewRows(append(workoutCols(), "visibility")).
+			AddRow(int64(1), pub(1).String(), fixedTime, fixedTime, nil, int64(2), "Secret Session", "", 30, "private"))
+	mock.ExpectQuery(`SELECT \* FROM "users" WHERE zitadel_id`).
+		WillReturnRows(sqlmock.NewRows(userCols()).
+			AddRow(int64(5), pub(5).String(), fixedTime, fixedTime, nil, "zitadel-sub-5", "m@example.com", "Mallory", ""))
+
+	resp := api.Get("/api/v1/workouts/" + pub(1).String())
+
+	assert.Equal(t, http.StatusNotFound, resp.Code)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestChannelNotifier_RendersLike(t *testing.T) {
+	db, mock := newMockDB(t)
+	push, mail := &recordingChannel{}, &recordingChannel{}
+	n := notify.NewChannelNotifier(db, notify.Channels{notify.ChannelPush: push, notify.ChannelEmail: mail})
+
+	mock.ExpectQuery(`SELECT \* FROM "users" WHERE "users"."id" = \$1`).
+		WithArgs(int64(2), 1).
+		WillReturnRows(sqlmock.NewRows(userCols()).
+			AddRow(int64(2), pub(2).String(), fixedTime, fixedTime, nil, nil, "bob@example.com", "Bob", ""))
+	mock.ExpectQuery(`SELECT \* FROM "users" WHERE "users"."id" = \$1`).
+		WithArgs(int64(1), 1).
+		WillReturnRows(sqlmock.NewRows(userCols()).
+			AddRow(int64(1), pub(1).String(), fixedTime, fixedTime, nil, nil, "alice@example.com", "Alice", ""))
+	mock.ExpectQuery(`SELECT \* FROM "workouts" WHERE "workouts"."id" = \$1`).
+		WithArgs(int64(4), 1).
+		WillReturnRows(sqlmock.NewRows(workoutCols()).
+			AddRow(int64(4), pub(4).String(), fixedTime, fixedTime, nil, int64(2), "Hill repeats", "", 45))
+
+	err := n.Notify(context.Background(), notify.Notification{Kind: notify.KindLike, RecipientID: 2, ActorID: 1, WorkoutID: 4})
+
+	require.NoError(t, err)
+	// Likes are only pushed.
+	assert.Empty(t, mail.sent)
+	require.Len(t, push.sent, 1)
+	m := push.sent[0]
+	assert.Equal(t, notify.KindLike, m.Kind)
+	assert.Equal(t, `Alice liked your workout "Hill repeats"`, m.Subject)
+	assert.Equal(t, pub(4), m.ResourcePublicID)
+	assert.Equal(t, "bob@example.com", push.to[0].Email)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestChannelNotifier_SkipsDeletedRecipients(t *testing.T) {
+	db, mock := newMockDB(t)
+	push := &recordingChannel{}
+	n := notify.NewChannelNotifier(db, notify.Channels{notify.ChannelPush: push})
+
+	mock.ExpectQuery(`SELECT \* FROM "users" WHERE "users"."id" = \$1`).
+		WithArgs(int64(2), 1).
+		WillReturnRows(sqlmock.NewRows(userCols()))
+
+	require.NoError(t, n.Notify(context.Background(), notify.Notification{Kind: notify.KindReminder, RecipientID: 2}))
+	assert.Empty(t, push.sent)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
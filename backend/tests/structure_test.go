@@ -0,0 +1,220 @@
+package backend_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"workout-tracker/backend/schemas"
+	"workout-tracker/backend/training"
+)
+
+// shape describes nodes as exercise names, with groups as their type
+// followed by their children in brackets.
+func shape(nodes []training.Node) []any {
+	out := []any{}
+	for _, n := range nodes {
+		if n.Group != nil {
+			out = append(out, n.Group.Type, shape(n.Children))
+		} else {
+			out = append(out, n.Exercise.Name)
+		}
+	}
+	return out
+}
+
+func groupRef(n int64) *uuid.UUID {
+	id := pub(n)
+	return &id
+}
+
+func TestTrainingTree_NestsGroupsInOrder(t *testing.T) {
+	groups := []training.Group{
+		{ID: pub(51), ParentID: groupRef(50), Type: training.GroupSuperset},
+		{ID: pub(50), Type: training.GroupCircuit},
+	}
+	exercises := []training.Exercise{
+		{Name: "Squat"},
+		{Name: "Pull-up", GroupID: groupRef(51)},
+		{Name: "Dip", GroupID: groupRef(51)},
+		{Name: "Burpee", GroupID: groupRef(50)},
+		{Name: "Plank"},
+	}
+
+	tree, err := training.Tree(groups, exercises)
+
+	require.NoError(t, err)
+	assert.Equal(t, []any{
+		"Squat",
+		"circuit", []any{"superset", []any{"Pull-up", "Dip"}, "Burpee"},
+		"Plank",
+	}, shape(tree))
+}
+
+func TestTrainingTree_Rejects(t *testing.T) {
+	tests := map[string]struct {
+		groups    []training.Group
+		exercises []training.Exercise
+	}{
+		"split group": {
+			[]training.Group{{ID: pub(50), Type: training.GroupSuperset}},
+			[]training.Exercise{{Name: "A", GroupID: groupRef(50)}, {Name: "B"}, {Name: "C", GroupID: groupRef(50)}},
+		},
+		"unknown group": {
+			nil,
+			[]training.Exercise{{Name: "A", GroupID: groupRef(50)}},
+		},
+		"unknown parent": {
+			[]training.Group{{ID: pub(50), ParentID: groupRef(49), Type: training.GroupCircuit}},
+			[]training.Exercise{{Name: "A", GroupID: groupRef(50)}},
+		},
+		"cycle": {
+			[]training.Group{
+				{ID: pub(50), ParentID: groupRef(51), Type: training.GroupCircuit},
+				{ID: pub(51), ParentID: groupRef(50), Type: training.GroupCircuit},
+			},
+			[]training.Exercise{{Name: "A", GroupID: groupRef(50)}},
+		},
+		"too deep": {
+			[]training.Group{
+				{ID: pub(50), Type: training.GroupCircuit},
+				{ID: pub(51), ParentID: groupRef(50), Type: training.GroupCircuit},
+				{ID: pub(52), ParentID: groupRef(51), Type: training.GroupCircuit},
+				{ID: pub(53), ParentID: groupRef(52), Type: training.GroupCircuit},
+			},
+			[]training.Exercise{{Name: "A", GroupID: groupRef(53)}},
+		},
+		"empty group": {
+			[]training.Group{{ID: pub(50), Type: training.GroupAMRAP}},
+			[]training.Exercise{{Name: "A"}},
+		},
+		"duplicate group": {
+			[]training.Group{{ID: pub(50), Type: training.GroupAMRAP}, {ID: pub(50), Type: training.GroupEMOM}},
+			[]training.Exercise{{Name: "A", GroupID: groupRef(50)}},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := training.Tree(tt.groups, tt.exercises)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestCreateWorkout_WithGroups(t *testing.T) {
+	db, mock := newMockDB(t)
+	api := newTestAPI(t, db)
+
+	expectUserLookup(mock, 1)
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO "workouts" \(.*"exercises","groups"\)`).
+		WillReturnResult(sqlmock.NewResult(7, 1))
+	expectRevision(mock, 1, 0)
+	mock.ExpectCommit()
+
+	resp := api.Post("/api/v1/workouts", map[string]any{
+		"user_id":          pub(1),
+		"name":             "Conditioning",
+		"duration_minutes": 20,
+		"groups": []map[string]any{
+			{"id": pub(50), "type": "tabata", "name": " Finisher "},
+			{"id": pub(51), "type": "amrap", "duration_seconds": 600, "rounds": 7},
+		},
+		"exercises": []map[string]any{
+			{"name": "Thruster", "group_id": pub(51), "sets": []map[string]any{{"reps": 10, "weight_kg": 30}}},
+			{"name": "Row", "group_id": pub(51), "sets": []map[string]any{{"reps": 15}}},
+			{"name": "Air bike", "group_id": pub(50), "sets": []map[string]any{{"duration_seconds": 240}}},
+		},
+	})
+
+	require.Equal(t, http.StatusCreated, resp.Code, resp.Body.String())
+	var body schemas.WorkoutResponse
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &body))
+	require.Len(t, body.Groups, 2)
+	tabata := body.Groups[0]
+	assert.Equal(t, "Finisher", tabata.Name)
+	// Tabatas get the standard timings.
+	require.NotNil(t, tabata.Rounds)
+	require.NotNil(t, tabata.WorkSeconds)
+	require.NotNil(t, tabata.RestSeconds)
+	assert.Equal(t, []int{8, 20, 10}, []int{*tabata.Rounds, *tabata.WorkSeconds, *tabata.RestSeconds})
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCreateWorkout_RejectsInvalidGroups(t *testing.T) {
+	set := []map[string]any{{"reps": 10}}
+	tests := map[string]struct {
+		group     map[string]any
+		exercises int
+	}{
+		"superset of one":       {map[string]any{"type": "superset"}, 1},
+		"giant set of two":      {map[string]any{"type": "giant_set"}, 2},
+		"AMRAP without cap":     {map[string]any{"type": "amrap"}, 1},
+		"EMOM without rounds":   {map[string]any{"type": "emom"}, 1},
+		"EMOM with rest":        {map[string]any{"type": "emom", "rounds": 10, "rest_seconds": 30}, 1},
+		"circuit with work":     {map[string]any{"type": "circuit", "work_seconds": 40}, 2},
+		"Tabata with time cap":  {map[string]any{"type": "tabata", "duration_seconds": 240}, 1},
+		"group without members": {map[string]any{"type": "emom", "rounds": 10}, 0},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			db, mock := newMockDB(t)
+			api := newTestAPI(t, db)
+
+			expectUserLookup(mock, 1)
+			mock.ExpectBegin()
+			mock.ExpectRollback()
+
+			tt.group["id"] = pub(50)
+			exercises := []map[string]any{{"name": "Squat", "sets": set}}
+			for range tt.exercises {
+				exercises = append(exercises, map[string]any{"name": "Burpee", "group_id": pub(50), "sets": set})
+			}
+			resp := api.Post("/api/v1/workouts", map[string]any{
+				"user_id":          pub(1),
+				"name":             "Conditioning",
+				"duration_minutes": 20,
+				"groups":           []map[string]any{tt.group},
+				"exercises":        exercises,
+			})
+
+			assert.Equal(t, http.StatusUnprocessableEntity, resp.Code, resp.Body.String())
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestGetWorkout_ReturnsStructure(t *testing.T) {
+	db, mock := newMockDB(t)
+	api := newTestAPI(t, db)
+
+	mock.ExpectQuery(`SELECT \* FROM "workouts" WHERE public_id = \$1`).
+		WithArgs(pub(4), 1).
+		WillReturnRows(sqlmock.NewRows(append(workoutCols(), "exercises", "groups")).
+			AddRow(int64(4), pub(4).String(), fixedTime, fixedTime, nil, int64(1), "Upper", "", 50,
+				`[{"id":"`+pub(30).String()+`","name":"Bench press","sets":[{"reps":5,"weight_kg":80}]},`+
+					`{"id":"`+pub(31).String()+`","name":"Chin-up","group_id":"`+pub(50).String()+`","sets":[{"reps":8}]},`+
+					`{"id":"`+pub(32).String()+`","name":"Dip","group_id":"`+pub(50).String()+`","sets":[{"reps":12}]}]`,
+				`[{"id":"`+pub(50).String()+`","type":"superset","name":"A","rounds":3,"rest_seconds":90}]`))
+	expectPublicIDs(mock, "users", 1)
+
+	resp := api.Get("/api/v1/workouts/" + pub(4).String())
+
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	var got schemas.WorkoutDetailResponse
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &got))
+	assert.Len(t, got.Exercises, 3)
+	assert.Equal(t, []any{"Bench press", "superset", []any{"Chin-up", "Dip"}}, shape(got.Structure))
+	superset := got.Structure[1].Group
+	require.NotNil(t, superset)
+	assert.Equal(t, "A", superset.Name)
+	require.NotNil(t, superset.Rounds)
+	assert.Equal(t, 3, *superset.Rounds)
+	assert.Equal(t, pub(31), got.Structure[1].Children[0].Exercise.ID)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
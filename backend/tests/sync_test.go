@@ -0,0 +1,248 @@
+package backend_test
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"workout-tracker/backend/schemas"
+)
+
+const syncEventsQuery = `SELECT resource_id, resource_public_id, MAX\(id\) AS last_event, MAX\(created_at\) AS changed_at FROM "events" ` +
+	`WHERE user_id = \$1 AND resource_type = \$2 AND id > \$3 GROUP BY resource_id, resource_public_id ORDER BY last_event LIMIT \$4`
+
+func syncCursor(eventID string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(eventID))
+}
+
+func syncOutput(t *testing.T, body []byte) schemas.SyncOutput {
+	t.Helper()
+	var out schemas.SyncOutput
+	require.NoError(t, json.Unmarshal(body, &out.Body))
+	return out
+}
+
+// expectSyncUser mocks the lookups of user 1, the syncing user.
+func expectSyncUser(mock sqlmock.Sqlmock) {
+	expectUserLookup(mock, 1)
+	expectPublicIDs(mock, "users", 1)
+}
+
+func expectNoSyncChanges(mock sqlmock.Sqlmock) {
+	mock.ExpectQuery(syncEventsQuery).
+		WillReturnRows(sqlmock.NewRows([]string{"resource_id", "resource_public_id", "last_event", "changed_at"}))
+}
+
+func TestSync_ReturnsChangesAndTombstones(t *testing.T) {
+	db, mock := newMockDB(t)
+	api := newTestAPI(t, db)
+
+	expectSyncUser(mock)
+	mock.ExpectQuery(syncEventsQuery).
+		WithArgs(1, "workout", 7, 3).
+		WillReturnRows(sqlmock.NewRows([]string{"resource_id", "resource_public_id", "last_event", "changed_at"}).
+			AddRow(int64(4), pub(4).String(), int64(10), fixedTime).
+			AddRow(int64(5), pub(5).String(), int64(12), fixedTime).
+			AddRow(int64(6), pub(6).String(), int64(13), fixedTime))
+	mock.ExpectQuery(`SELECT \* FROM "workouts" WHERE id IN \(\$1,\$2\)`).
+		WithArgs(4, 5).
+		WillReturnRows(sqlmock.NewRows(append(workoutCols(), "version")).
+			AddRow(int64(4), pub(4).String(), fixedTime, fixedTime, nil, int64(1), "Tempo run", "", 50, int64(3)).
+			AddRow(int64(5), pub(5).String(), fixedTime, fixedTime, fixedTime, int64(1), "Old", "", 20, int64(2)))
+
+	resp := api.Post("/api/v1/sync", map[string]any{"user_id": pub(1), "cursor": syncCursor("7"), "limit": 2})
+
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	out := syncOutput(t, resp.Body.Bytes())
+	require.Len(t, out.Body.Changes, 2)
+	assert.Equal(t, int64(3), out.Body.Changes[0].Version)
+	assert.Equal(t, "Tempo run", out.Body.Changes[0].Workout.Name)
+	assert.True(t, out.Body.Changes[1].Deleted)
+	assert.Nil(t, out.Body.Changes[1].Workout)
+	assert.Equal(t, syncCursor("12"), out.Body.NextCursor)
+	assert.True(t, out.Body.HasMore)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSync_CursorTrailsUnsettledChanges(t *testing.T) {
+	db, mock := newMockDB(t)
+	api := newTestAPI(t, db)
+
+	expectSyncUser(mock)
+	mock.ExpectQuery(syncEventsQuery).
+		WillReturnRows(sqlmock.NewRows([]string{"resource_id", "resource_public_id", "last_event", "changed_at"}).
+			AddRow(int64(4), pub(4).String(), int64(10), fixedTime).
+			AddRow(int64(5), pub(5).String(), int64(12), time.Now()))
+	mock.ExpectQuery(`SELECT \* FROM "workouts" WHERE id IN`).
+		WillReturnRows(sqlmock.NewRows(workoutCols()))
+
+	resp := api.Post("/api/v1/sync", map[string]any{"user_id": pub(1)})
+
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	out := syncOutput(t, resp.Body.Bytes())
+	// Both are sent, purged ones as bare tombstones, but the cursor only
+	// moves past the settled change.
+	require.Len(t, out.Body.Changes, 2)
+	assert.True(t, out.Body.Changes[0].Deleted)
+	assert.Equal(t, pub(4), out.Body.Changes[0].ID)
+	assert.Equal(t, syncCursor("10"), out.Body.NextCursor)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSync_CreatesOfflineWorkout(t *testing.T) {
+	db, mock := newMockDB(t)
+	api := newTestAPI(t, db)
+
+	expectSyncUser(mock)
+	clientID := "0192b4a0-7c1e-7d3a-9f00-3c1b2a4d5e6f"
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM "workouts" WHERE public_id = \$1 LIMIT \$2`).
+		WithArgs(clientID, 1).
+		WillReturnRows(sqlmock.NewRows(workoutCols()))
+	mock.ExpectExec(`INSERT INTO "workouts" \("public_id",`).
+		WillReturnResult(sqlmock.NewResult(9, 1))
+	expectRevision(mock, 1, 0)
+	mock.ExpectCommit()
+	expectNoSyncChanges(mock)
+
+	resp := api.Post("/api/v1/sync", map[string]any{
+		"user_id": pub(1),
+		"changes": []map[string]any{
+			{"id": clientID, "fields": map[string]any{"name": "Basement session", "duration_minutes": 40}},
+		},
+	})
+
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	out := syncOutput(t, resp.Body.Bytes())
+	require.Len(t, out.Body.Results, 1)
+	assert.Equal(t, schemas.SyncApplied, out.Body.Results[0].Status)
+	require.NotNil(t, out.Body.Results[0].Workout)
+	assert.Equal(t, clientID, out.Body.Results[0].Workout.Workout.ID.String())
+	assert.Equal(t, "Basement session", out.Body.Results[0].Workout.Workout.Name)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSync_ConflictingFieldKeepsServerValue(t *testing.T) {
+	db, mock := newMockDB(t)
+	api := newTestAPI(t, db)
+
+	expectSyncUser(mock)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM "workouts" WHERE public_id = \$1 LIMIT \$2`).
+		WithArgs(pub(4), 1).
+		WillReturnRows(sqlmock.NewRows(append(workoutCols(), "performed_at", "visibility", "version")).
+			AddRow(int64(4), pub(4).String(), fixedTime, fixedTime, nil, int64(1), "Tempo run", "", 50, fixedTime, "private", int64(3)))
+	// The client's copy was version 2, before the server renamed it.
+	mock.ExpectQuery(`SELECT \* FROM "workout_revisions" WHERE workout_id = \$1 AND snapshot->>'version' = \$2 ORDER BY revision DESC LIMIT \$3`).
+		WithArgs(4, "2", 1).
+		WillReturnRows(sqlmock.NewRows(revisionCols()).
+			AddRow(int64(5), fixedTime, int64(4), 2, int64(1), nil, snapshot("Easy run", 50)))
+	mock.ExpectExec(`UPDATE "workouts" SET .* WHERE version = \$\d+`).
+		WithArgs(pub(4), fixedTime, sqlmock.AnyArg(), nil, int64(4), int64(1), "Tempo run", "", 60, 0, fixedTime, "private", "[]", "{}", "[]", "[]", int64(3), int64(4)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectRevision(mock, 1, 2)
+	mock.ExpectCommit()
+	expectNoSyncChanges(mock)
+
+	resp := api.Post("/api/v1/sync", map[string]any{
+		"user_id": pub(1),
+		"changes": []map[string]any{
+			{"id": pub(4), "base_version": 2, "fields": map[string]any{"name": "Long run", "duration_minutes": 60}},
+		},
+	})
+
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	out := syncOutput(t, resp.Body.Bytes())
+	res := out.Body.Results[0]
+	assert.Equal(t, schemas.SyncConflict, res.Status)
+	assert.Equal(t, []schemas.SyncFieldConflict{{Field: "name", ClientValue: "Long run", ServerValue: "Tempo run"}}, res.Conflicts)
+	assert.Equal(t, "Tempo run", res.Workout.Workout.Name)
+	assert.Equal(t, 60, res.Workout.Workout.DurationMinutes)
+	assert.Equal(t, int64(4), res.Workout.Version)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSync_ServerDeletionWins(t *testing.T) {
+	db, mock := newMockDB(t)
+	api := newTestAPI(t, db)
+
+	expectSyncUser(mock)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM "workouts" WHERE public_id = \$1`).
+		WillReturnRows(sqlmock.NewRows(append(workoutCols(), "version")).
+			AddRow(int64(4), pub(4).String(), fixedTime, fixedTime, fixedTime, int64(1), "Tempo run", "", 50, int64(3)))
+	mock.ExpectCommit()
+	expectNoSyncChanges(mock)
+
+	resp := api.Post("/api/v1/sync", map[string]any{
+		"user_id": pub(1),
+		"changes": []map[string]any{
+			{"id": pub(4), "base_version": 2, "fields": map[string]any{"name": "Long run"}},
+		},
+	})
+
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	res := syncOutput(t, resp.Body.Bytes()).Body.Results[0]
+	assert.Equal(t, schemas.SyncConflict, res.Status)
+	assert.Equal(t, "deleted", res.Conflicts[0].Field)
+	assert.True(t, res.Workout.Deleted)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSync_ClientDeletionOfChangedWorkoutRefused(t *testing.T) {
+	db, mock := newMockDB(t)
+	api := newTestAPI(t, db)
+
+	expectSyncUser(mock)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM "workouts" WHERE public_id = \$1`).
+		WillReturnRows(sqlmock.NewRows(append(workoutCols(), "version")).
+			AddRow(int64(4), pub(4).String(), fixedTime, fixedTime, nil, int64(1), "Tempo run", "", 50, int64(3)))
+	mock.ExpectQuery(`SELECT \* FROM "workout_revisions"`).
+		WillReturnRows(sqlmock.NewRows(revisionCols()))
+	mock.ExpectCommit()
+	expectNoSyncChanges(mock)
+
+	resp := api.Post("/api/v1/sync", map[string]any{
+		"user_id": pub(1),
+		"changes": []map[string]any{{"id": pub(4), "base_version": 2, "deleted": true}},
+	})
+
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	res := syncOutput(t, resp.Body.Bytes()).Body.Results[0]
+	assert.Equal(t, schemas.SyncConflict, res.Status)
+	assert.False(t, res.Workout.Deleted)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSync_RejectsInvalidChange(t *testing.T) {
+	db, mock := newMockDB(t)
+	api := newTestAPI(t, db)
+
+	expectSyncUser(mock)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM "workouts" WHERE public_id = \$1`).
+		WillReturnRows(sqlmock.NewRows(workoutCols()))
+	mock.ExpectRollback()
+	expectNoSyncChanges(mock)
+
+	resp := api.Post("/api/v1/sync", map[string]any{
+		"user_id": pub(1),
+		"changes": []map[string]any{
+			{"id": "0192b4a0-7c1e-7d3a-9f00-3c1b2a4d5e6f", "fields": map[string]any{"duration_minutes": 40}},
+		},
+	})
+
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	res := syncOutput(t, resp.Body.Bytes()).Body.Results[0]
+	assert.Equal(t, schemas.SyncRejected, res.Status)
+	require.NotNil(t, res.Error)
+	assert.Equal(t, http.StatusUnprocessableEntity, res.Error.Status)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
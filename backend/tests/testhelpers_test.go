@@ -1,12 +1,18 @@
 package backend_test
 
 import (
+	"fmt"
 	"testing"
 	"time"
 
 	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/danielgtaylor/huma/v2"
 	"github.com/danielgtaylor/huma/v2/humatest"
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/require"
+	"github.com/zitadel/oidc/v3/pkg/oidc"
+	"github.com/zitadel/zitadel-go/v3/pkg/authorization"
+	"github.com/zitadel/zitadel-go/v3/pkg/authorization/oauth"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
@@ -17,6 +23,12 @@ import (
 // fixedTime is a stable timestamp used across test assertions.
 var fixedTime = time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
 
+// pub returns the public ID the tests give the record with ID id, so the
+// two can be told apart and matched up in assertions.
+func pub(id int64) uuid.UUID {
+	return uuid.MustParse(fmt.Sprintf("00000000-0000-7000-8000-%012d", id))
+}
+
 // newMockDB returns a GORM DB backed by go-sqlmock and its companion mock
 // controller. WithoutReturning is set so that INSERT/UPDATE/DELETE produce
 // Exec calls (easier to mock) rather than Query…RETURNING calls.
@@ -38,21 +50,126 @@ func newMockDB(t *testing.T) (*gorm.DB, sqlmock.Sqlmock) {
 }
 
 // newTestAPI wires all routes onto a humatest API backed by db.
-func newTestAPI(t *testing.T, db *gorm.DB) humatest.TestAPI {
+func newTestAPI(t *testing.T, db *gorm.DB, opts ...backend.Option) humatest.TestAPI {
+	t.Helper()
+	_, api := humatest.New(t)
+	backend.RegisterRoutes(api, db, opts...)
+	return api
+}
+
+// newAuthedTestAPI is like newTestAPI but attaches a Zitadel auth context for
+// subject to every request, as middleware.Auth does for a valid token.
+func newAuthedTestAPI(t *testing.T, db *gorm.DB, subject string) humatest.TestAPI {
+	t.Helper()
+	return newClaimsTestAPI(t, db, subject, nil)
+}
+
+// newClaimsTestAPI is newAuthedTestAPI with extra token claims, such as the
+// urn:zitadel:iam:user:resourceowner ones naming the caller's organization.
+func newClaimsTestAPI(t *testing.T, db *gorm.DB, subject string, claims map[string]any) humatest.TestAPI {
 	t.Helper()
 	_, api := humatest.New(t)
+	api.UseMiddleware(func(ctx huma.Context, next func(huma.Context)) {
+		authCtx := &oauth.IntrospectionContext{
+			IntrospectionResponse: oidc.IntrospectionResponse{Active: true, Subject: subject, Claims: claims},
+		}
+		next(huma.WithContext(ctx, authorization.WithAuthContext(ctx.Context(), authCtx)))
+	})
 	backend.RegisterRoutes(api, db)
 	return api
 }
 
 // workoutCols returns the column names that GORM scans for a Workout row.
 func workoutCols() []string {
-	return []string{"id", "created_at", "updated_at", "deleted_at",
+	return []string{"id", "public_id", "created_at", "updated_at", "deleted_at",
 		"user_id", "name", "description", "duration_minutes"}
 }
 
 // userCols returns the column names that GORM scans for a User row.
 func userCols() []string {
-	return []string{"id", "created_at", "updated_at", "deleted_at",
+	return []string{"id", "public_id", "created_at", "updated_at", "deleted_at",
 		"zitadel_id", "email", "name", "password_hash"}
 }
+
+// organizationCols returns the column names that GORM scans for an Organization row.
+func organizationCols() []string {
+	return []string{"id", "public_id", "created_at", "updated_at", "deleted_at", "zitadel_org_id", "name"}
+}
+
+// membershipCols returns the column names that GORM scans for a Membership row.
+func membershipCols() []string {
+	return []string{"id", "public_id", "created_at", "updated_at", "deleted_at",
+		"organization_id", "user_id", "role", "share_stats"}
+}
+
+// challengeCols returns the column names that GORM scans for a Challenge row.
+func challengeCols() []string {
+	return []string{"id", "public_id", "created_at", "updated_at", "deleted_at", "organization_id", "created_by_id",
+		"name", "description", "metric", "timezone", "starts_at", "ends_at", "late_log_hours", "finalized_at"}
+}
+
+// webhookCols returns the column names that GORM scans for a Webhook row.
+func webhookCols() []string {
+	return []string{"id", "public_id", "created_at", "updated_at", "deleted_at",
+		"user_id", "url", "event_types", "secret", "active"}
+}
+
+// deliveryCols returns the column names that GORM scans for a WebhookDelivery row.
+func deliveryCols() []string {
+	return []string{"id", "public_id", "created_at", "updated_at", "deleted_at", "webhook_id", "event_id",
+		"status", "attempts", "next_attempt_at", "delivered_at"}
+}
+
+// jobCols returns the column names that GORM scans for a Job row.
+func jobCols() []string {
+	return []string{"id", "public_id", "created_at", "updated_at", "queue", "kind", "args", "status", "run_at",
+		"attempts", "max_attempts", "locked_until", "last_error", "finished_at", "unique_key"}
+}
+
+// plannedWorkoutCols returns the column names that GORM scans for a PlannedWorkout row.
+func plannedWorkoutCols() []string {
+	return []string{"id", "public_id", "created_at", "updated_at", "deleted_at", "user_id", "name", "description",
+		"duration_minutes", "starts_at", "timezone", "rrule", "reminder_channels", "remind_minutes_before", "auto_reschedule"}
+}
+
+// plannedSessionCols returns the column names that GORM scans for a PlannedSession row.
+func plannedSessionCols() []string {
+	return []string{"id", "public_id", "created_at", "updated_at", "deleted_at", "plan_id", "user_id", "occurs_at",
+		"scheduled_at", "status", "workout_id", "reminded_at", "missed_at", "reschedules"}
+}
+
+// expectPublicIDs mocks the lookup of the public IDs of table's records
+// ids.
+func expectPublicIDs(mock sqlmock.Sqlmock, table string, ids ...int64) {
+	rows := sqlmock.NewRows([]string{"id", "public_id"})
+	for _, id := range ids {
+		rows.AddRow(id, pub(id).String())
+	}
+	mock.ExpectQuery(`SELECT "id","public_id" FROM "` + table + `" WHERE id IN`).WillReturnRows(rows)
+}
+
+// expectUserLookup mocks resolving the dev-mode user with public ID
+// pub(id).
+func expectUserLookup(mock sqlmock.Sqlmock, id int64) {
+	mock.ExpectQuery(`SELECT "id" FROM "users" WHERE public_id = \$1`).
+		WithArgs(pub(id), 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(id))
+}
+
+// expectRevision mocks recordRevision storing a revision of owner ownerID's
+// workout after last.
+func expectRevision(mock sqlmock.Sqlmock, ownerID int64, last int) {
+	expectPublicIDs(mock, "users", ownerID)
+	mock.ExpectQuery(`SELECT COALESCE\(MAX\(revision\), 0\) FROM "workout_revisions" WHERE workout_id = \$1`).
+		WillReturnRows(sqlmock.NewRows([]string{"coalesce"}).AddRow(last))
+	mock.ExpectExec(`INSERT INTO "workout_revisions"`).
+		WillReturnResult(sqlmock.NewResult(int64(last+1), 1))
+}
+
+// expectOwner mocks the change feed looking up the public ID of user
+// userID, the owner of a workout it describes.
+func expectOwner(mock sqlmock.Sqlmock, userID int64) {
+	mock.ExpectQuery(`SELECT "public_id" FROM "users" WHERE id = \$1`).
+		WithArgs(userID, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"public_id"}).AddRow(pub(userID).String()))
+}
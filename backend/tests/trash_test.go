@@ -0,0 +1,151 @@
+package backend_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"workout-tracker/backend"
+	"workout-tracker/backend/schemas"
+)
+
+func TestListTrash(t *testing.T) {
+	db, mock := newMockDB(t)
+	api := newTestAPI(t, db, backend.WithTrashRetention(7))
+
+	expectUserLookup(mock, 1)
+	mock.ExpectQuery(`SELECT \* FROM "workouts" WHERE user_id = \$1 AND deleted_at IS NOT NULL ORDER BY deleted_at DESC, id DESC`).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(workoutCols()).
+			AddRow(int64(4), pub(4).String(), fixedTime, fixedTime, fixedTime, int64(1), "Morning Run", "", 30))
+	expectPublicIDs(mock, "users", 1)
+
+	resp := api.Get("/api/v1/workouts/trash?userId=" + pub(1).String())
+
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	var got []schemas.TrashedWorkoutResponse
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &got))
+	require.Len(t, got, 1)
+	assert.Equal(t, pub(4), got[0].ID)
+	assert.Equal(t, pub(1), got[0].UserID)
+	assert.Equal(t, fixedTime, got[0].DeletedAt)
+	assert.Equal(t, fixedTime.AddDate(0, 0, 7), got[0].PurgesAt)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRestoreWorkout(t *testing.T) {
+	db, mock := newMockDB(t)
+	api := newTestAPI(t, db)
+
+	mock.ExpectQuery(`SELECT \* FROM "workouts" WHERE public_id = \$1 AND deleted_at IS NOT NULL`).
+		WithArgs(pub(4), 1).
+		WillReturnRows(sqlmock.NewRows(workoutCols()).
+			AddRow(int64(4), pub(4).String(), fixedTime, fixedTime, fixedTime, int64(1), "Morning Run", "", 30))
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "workouts" SET "deleted_at"=\$1,"version"=version \+ 1,"updated_at"=\$2 WHERE "id" = \$3`).
+		WithArgs(nil, sqlmock.AnyArg(), 4).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	expectPublicIDs(mock, "users", 1)
+
+	resp := api.Post("/api/v1/workouts/" + pub(4).String() + "/restore")
+
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRestoreWorkout_OtherUsersTrashIsHidden(t *testing.T) {
+	db, mock := newMockDB(t)
+	api := newAuthedTestAPI(t, db, "zitadel-sub-5")
+
+	mock.ExpectQuery(`SELECT \* FROM "workouts" WHERE public_id = \$1 AND deleted_at IS NOT NULL`).
+		WillReturnRows(sqlmock.NewRows(workoutCols()).
+			AddRow(int64(4), pub(4).String(), fixedTime, fixedTime, fixedTime, int64(1), "Morning Run", "", 30))
+	mock.ExpectQuery(`SELECT \* FROM "users" WHERE zitadel_id`).
+		WillReturnRows(sqlmock.NewRows(userCols()).
+			AddRow(int64(5), pub(5).String(), fixedTime, fixedTime, nil, "zitadel-sub-5", "m@example.com", "Member", ""))
+
+	resp := api.Post("/api/v1/workouts/" + pub(4).String() + "/restore")
+
+	assert.Equal(t, http.StatusNotFound, resp.Code)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPurgeWorkout(t *testing.T) {
+	db, mock := newMockDB(t)
+	api := newTestAPI(t, db)
+
+	mock.ExpectQuery(`SELECT \* FROM "workouts" WHERE public_id = \$1 AND deleted_at IS NOT NULL`).
+		WillReturnRows(sqlmock.NewRows(workoutCols()).
+			AddRow(int64(4), pub(4).String(), fixedTime, fixedTime, fixedTime, int64(1), "Morning Run", "", 30))
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "planned_sessions" SET "workout_id"=\$1,"updated_at"=\$2 WHERE workout_id IN \(\$3\)`).
+		WithArgs(nil, sqlmock.AnyArg(), 4).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`UPDATE "attachments" SET "deleted_at"=\$1 WHERE workout_id IN \(\$2\) AND "attachments"."deleted_at" IS NULL`).
+		WithArgs(sqlmock.AnyArg(), 4).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`DELETE FROM "workout_likes" WHERE workout_id IN \(\$1\)`).
+		WithArgs(4).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec(`DELETE FROM "workout_comments" WHERE workout_id IN \(\$1\)`).
+		WithArgs(4).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`DELETE FROM "workout_revisions" WHERE workout_id IN \(\$1\)`).
+		WithArgs(4).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectExec(`DELETE FROM "workouts" WHERE id IN \(\$1\)`).
+		WithArgs(4).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	resp := api.Delete("/api/v1/workouts/trash/" + pub(4).String())
+
+	assert.Equal(t, http.StatusNoContent, resp.Code, resp.Body.String())
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPurgeWorkout_LiveWorkoutNotInTrash(t *testing.T) {
+	db, mock := newMockDB(t)
+	api := newTestAPI(t, db)
+
+	mock.ExpectQuery(`SELECT \* FROM "workouts" WHERE public_id = \$1 AND deleted_at IS NOT NULL`).
+		WillReturnRows(sqlmock.NewRows(workoutCols()))
+
+	resp := api.Delete("/api/v1/workouts/trash/" + pub(4).String())
+
+	assert.Equal(t, http.StatusNotFound, resp.Code)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestEmptyTrash(t *testing.T) {
+	db, mock := newMockDB(t)
+	api := newTestAPI(t, db)
+
+	trashed := `SELECT "id" FROM "workouts" WHERE user_id = \$\d AND deleted_at IS NOT NULL`
+	expectUserLookup(mock, 1)
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "planned_sessions" SET .* WHERE workout_id IN \(` + trashed + `\)`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`UPDATE "attachments" SET "deleted_at"=\$\d WHERE workout_id IN \(` + trashed + `\)`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`DELETE FROM "workout_likes" WHERE workout_id IN \(` + trashed + `\)`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`DELETE FROM "workout_comments" WHERE workout_id IN \(` + trashed + `\)`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`DELETE FROM "workout_revisions" WHERE workout_id IN \(` + trashed + `\)`).
+		WillReturnResult(sqlmock.NewResult(0, 5))
+	mock.ExpectExec(`DELETE FROM "workouts" WHERE id IN \(` + trashed + `\)`).
+		WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectCommit()
+
+	resp := api.Delete("/api/v1/workouts/trash?userId=" + pub(1).String())
+
+	assert.Equal(t, http.StatusNoContent, resp.Code, resp.Body.String())
+	require.NoError(t, mock.ExpectationsWereMet())
+}
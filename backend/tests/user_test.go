@@ -31,8 +31,8 @@ func TestListUsers_WithResults(t *testing.T) {
 	api := newTestAPI(t, db)
 
 	rows := sqlmock.NewRows(userCols()).
-		AddRow(int64(1), fixedTime, fixedTime, nil, nil, "alice@example.com", "Alice", "hash1").
-		AddRow(int64(2), fixedTime, fixedTime, nil, nil, "bob@example.com", "Bob", "hash2")
+		AddRow(int64(1), pub(1).String(), fixedTime, fixedTime, nil, nil, "alice@example.com", "Alice", "hash1").
+		AddRow(int64(2), pub(2).String(), fixedTime, fixedTime, nil, nil, "bob@example.com", "Bob", "hash2")
 	mock.ExpectQuery(`SELECT \* FROM "users"`).WillReturnRows(rows)
 
 	resp := api.Get("/api/v1/users")
@@ -52,14 +52,14 @@ func TestGetUser_Found(t *testing.T) {
 
 	mock.ExpectQuery(`SELECT \* FROM "users"`).
 		WillReturnRows(sqlmock.NewRows(userCols()).
-			AddRow(int64(1), fixedTime, fixedTime, nil, nil, "alice@example.com", "Alice", "hash"))
+			AddRow(int64(1), pub(1).String(), fixedTime, fixedTime, nil, nil, "alice@example.com", "Alice", "hash"))
 
-	resp := api.Get("/api/v1/users/1")
+	resp := api.Get("/api/v1/users/" + pub(1).String())
 
 	require.Equal(t, http.StatusOK, resp.Code)
 	var body schemas.UserResponse
 	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &body))
-	assert.Equal(t, int64(1), body.ID)
+	assert.Equal(t, pub(1), body.ID)
 	assert.Equal(t, "alice@example.com", body.Email)
 	assert.Equal(t, "Alice", body.Name)
 	// PasswordHash must NOT be exposed
@@ -74,7 +74,7 @@ func TestGetUser_NotFound(t *testing.T) {
 	mock.ExpectQuery(`SELECT \* FROM "users"`).
 		WillReturnRows(sqlmock.NewRows(userCols())) // no rows → 404
 
-	resp := api.Get("/api/v1/users/99")
+	resp := api.Get("/api/v1/users/" + pub(99).String())
 
 	assert.Equal(t, http.StatusNotFound, resp.Code)
 	require.NoError(t, mock.ExpectationsWereMet())
@@ -0,0 +1,314 @@
+package backend_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strconv"
+	"testing"
+	"time"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"workout-tracker/backend/events"
+	"workout-tracker/backend/netguard"
+	"workout-tracker/backend/schemas"
+	"workout-tracker/backend/webhooks"
+)
+
+const webhookSecret = "0123456789abcdef0123456789abcdef"
+
+// received is one request captured by the test receiver.
+type received struct {
+	header http.Header
+	body   []byte
+}
+
+// newReceiver starts an httptest server that answers every request with
+// status and records it on the returned channel.
+func newReceiver(t *testing.T, status int) (*httptest.Server, <-chan received) {
+	t.Helper()
+	got := make(chan received, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		got <- received{header: r.Header.Clone(), body: body}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, got
+}
+
+// expectDueDelivery queues the reads and claim DeliverDue makes for one due
+// delivery (ID 5) of event 9 to a webhook at url that has already been
+// tried attempts times.
+func expectDueDelivery(mock sqlmock.Sqlmock, url string, attempts int) {
+	mock.ExpectQuery(`SELECT \* FROM "webhook_deliveries" WHERE \(status = \$1 AND next_attempt_at <= \$2\)`).
+		WillReturnRows(sqlmock.NewRows(deliveryCols()).
+			AddRow(int64(5), pub(5).String(), fixedTime, fixedTime, nil, int64(2), int64(9), "pending", attempts, fixedTime, nil))
+	mock.ExpectQuery(`SELECT \* FROM "events" WHERE "events"."id" = \$1`).
+		WillReturnRows(sqlmock.NewRows(eventCols()).
+			AddRow(int64(9), fixedTime, "workout.created", "workout", int64(4), pub(4).String(), int64(1), "public", []byte(`{"name":"Tempo Run"}`)))
+	mock.ExpectQuery(`SELECT \* FROM "webhooks" WHERE "webhooks"."id" = \$1`).
+		WillReturnRows(sqlmock.NewRows(webhookCols()).
+			AddRow(int64(2), pub(2).String(), fixedTime, fixedTime, nil, int64(1), url, nil, webhookSecret, true))
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "webhook_deliveries" SET "next_attempt_at"=\$1,"updated_at"=\$2 WHERE \(id = \$3 AND status = \$4 AND next_attempt_at = \$5\)`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+}
+
+func newTestDispatcher(t *testing.T) (*webhooks.Dispatcher, sqlmock.Sqlmock) {
+	t.Helper()
+	db, mock := newMockDB(t)
+	d := webhooks.NewDispatcher(db)
+	d.Now = func() time.Time { return fixedTime }
+	// The test receivers listen on loopback, which the default client
+	// refuses.
+	d.Client = &http.Client{Timeout: 5 * time.Second}
+	return d, mock
+}
+
+func TestDispatcher_DeliversSignedPayload(t *testing.T) {
+	d, mock := newTestDispatcher(t)
+	srv, got := newReceiver(t, http.StatusNoContent)
+
+	expectDueDelivery(mock, srv.URL, 0)
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO "webhook_attempts"`).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), int64(5), http.StatusNoContent, "", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`UPDATE "webhook_deliveries" SET "attempts"=\$1,"delivered_at"=\$2,"status"=\$3`).
+		WithArgs(1, fixedTime, "succeeded", sqlmock.AnyArg(), int64(5)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	require.NoError(t, d.DeliverDue(context.Background()))
+
+	r := <-got
+	ts := strconv.FormatInt(fixedTime.Unix(), 10)
+	assert.Equal(t, ts, r.header.Get(webhooks.HeaderTimestamp))
+	assert.Equal(t, "workout.created", r.header.Get(webhooks.HeaderEvent))
+	assert.Equal(t, pub(5).String(), r.header.Get(webhooks.HeaderDelivery))
+	assert.Equal(t, webhooks.Sign(webhookSecret, fixedTime.Unix(), r.body), r.header.Get(webhooks.HeaderSignature))
+	var p webhooks.Payload
+	require.NoError(t, json.Unmarshal(r.body, &p))
+	assert.Equal(t, int64(9), p.EventID)
+	assert.JSONEq(t, `{"name":"Tempo Run"}`, string(p.Data))
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDispatcher_RetriesWithBackoff(t *testing.T) {
+	d, mock := newTestDispatcher(t)
+	srv, got := newReceiver(t, http.StatusInternalServerError)
+
+	// Third failure: the next try is 30s << 2 away.
+	expectDueDelivery(mock, srv.URL, 2)
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO "webhook_attempts"`).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), int64(5), http.StatusInternalServerError, "unexpected status 500", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`UPDATE "webhook_deliveries" SET "attempts"=\$1,"next_attempt_at"=\$2`).
+		WithArgs(3, fixedTime.Add(2*time.Minute), sqlmock.AnyArg(), int64(5)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	require.NoError(t, d.DeliverDue(context.Background()))
+
+	<-got
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDispatcher_FailsAfterMaxAttempts(t *testing.T) {
+	d, mock := newTestDispatcher(t)
+	d.MaxAttempts = 3
+	srv, got := newReceiver(t, http.StatusBadGateway)
+
+	expectDueDelivery(mock, srv.URL, 2)
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO "webhook_attempts"`).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`UPDATE "webhook_deliveries" SET "attempts"=\$1,"status"=\$2`).
+		WithArgs(3, "failed", sqlmock.AnyArg(), int64(5)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	require.NoError(t, d.DeliverDue(context.Background()))
+
+	<-got
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDispatcher_RefusesInternalAddresses(t *testing.T) {
+	db, mock := newMockDB(t)
+	d := webhooks.NewDispatcher(db)
+	d.Now = func() time.Time { return fixedTime }
+	srv, got := newReceiver(t, http.StatusNoContent)
+
+	expectDueDelivery(mock, srv.URL, 0)
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO "webhook_attempts"`).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), int64(5), 0, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`UPDATE "webhook_deliveries" SET "attempts"=\$1,"next_attempt_at"=\$2`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	require.NoError(t, d.DeliverDue(context.Background()))
+
+	assert.Empty(t, got)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestNetguard_Allowed(t *testing.T) {
+	for addr, want := range map[string]bool{
+		"93.184.216.34":    true,
+		"2606:4700::1111":  true,
+		"127.0.0.1":        false,
+		"10.1.2.3":         false,
+		"172.16.0.1":       false,
+		"192.168.1.1":      false,
+		"169.254.169.254":  false,
+		"100.64.0.1":       false,
+		"0.0.0.0":          false,
+		"::1":              false,
+		"fe80::1":          false,
+		"fd00:ec2::254":    false,
+		"::ffff:127.0.0.1": false,
+	} {
+		assert.Equal(t, want, netguard.Allowed(netip.MustParseAddr(addr)), addr)
+	}
+}
+
+func TestCreateWebhook_RejectsUnsafeURLs(t *testing.T) {
+	for _, url := range []string{
+		"ftp://hooks.example.com/workouts",
+		"http://169.254.169.254/latest/meta-data",
+		"http://127.0.0.1:8080/admin",
+		"http://[::1]/hook",
+		"http://localhost/hook",
+		"http://db/hook",
+	} {
+		t.Run(url, func(t *testing.T) {
+			db, mock := newMockDB(t)
+			api := newTestAPI(t, db)
+
+			resp := api.Post("/api/v1/webhooks", map[string]any{"user_id": pub(1), "url": url})
+
+			assert.Equal(t, http.StatusUnprocessableEntity, resp.Code, resp.Body.String())
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestDispatcher_EnqueueMatchesSubscriptions(t *testing.T) {
+	db, mock := newMockDB(t)
+	d := webhooks.NewDispatcher(db)
+	d.Now = func() time.Time { return fixedTime }
+
+	mock.ExpectQuery(`SELECT \* FROM "webhooks" WHERE \(active AND \(user_id IS NULL OR user_id = \$1\)\)`).
+		WithArgs(int64(1)).
+		WillReturnRows(sqlmock.NewRows(webhookCols()).
+			AddRow(int64(2), pub(2).String(), fixedTime, fixedTime, nil, int64(1), "https://a.example", nil, webhookSecret, true).
+			AddRow(int64(3), pub(3).String(), fixedTime, fixedTime, nil, nil, "https://b.example", []byte(`["workout.deleted"]`), webhookSecret, true))
+	// Only webhook 2 subscribes to workout.created.
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO "webhook_deliveries" .* ON CONFLICT DO NOTHING`).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), nil, int64(1), int64(2), int64(9), "pending", 0, fixedTime, nil).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	require.NoError(t, d.Enqueue(context.Background(), db, events.Event{ID: 9, Type: events.TypeWorkoutCreated, UserID: 1}))
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCreateWebhook_GeneratesSecret(t *testing.T) {
+	db, mock := newMockDB(t)
+	api := newTestAPI(t, db)
+
+	expectUserLookup(mock, 1)
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO "webhooks"`).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	expectPublicIDs(mock, "users", 1)
+
+	resp := api.Post("/api/v1/webhooks", map[string]any{
+		"user_id":     pub(1),
+		"url":         "https://hooks.example.com/workouts",
+		"event_types": []string{"workout.created"},
+	})
+
+	require.Equal(t, http.StatusCreated, resp.Code)
+	var body map[string]any
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &body))
+	assert.Len(t, body["secret"], 64)
+	assert.Equal(t, pub(1).String(), body["user_id"])
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCreateWebhook_SiteWideRequiresAdmin(t *testing.T) {
+	db, mock := newMockDB(t)
+	api := newAuthedTestAPI(t, db, "zitadel-sub-5")
+
+	resp := api.Post("/api/v1/webhooks", map[string]any{
+		"url":       "https://hooks.example.com/all",
+		"site_wide": true,
+	})
+
+	assert.Equal(t, http.StatusForbidden, resp.Code)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRedeliver(t *testing.T) {
+	db, mock := newMockDB(t)
+	api := newTestAPI(t, db)
+
+	mock.ExpectQuery(`SELECT \* FROM "webhooks"`).
+		WillReturnRows(sqlmock.NewRows(webhookCols()).
+			AddRow(int64(2), pub(2).String(), fixedTime, fixedTime, nil, int64(1), "https://a.example", nil, webhookSecret, true))
+	mock.ExpectQuery(`SELECT \* FROM "webhook_deliveries" WHERE \(webhook_id = \$1 AND public_id = \$2\)`).
+		WithArgs(int64(2), pub(5), 1).
+		WillReturnRows(sqlmock.NewRows(deliveryCols()).
+			AddRow(int64(5), pub(5).String(), fixedTime, fixedTime, nil, int64(2), int64(9), "failed", 8, fixedTime, nil))
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "webhook_deliveries" SET "updated_at"=\$1,"status"=\$2,"attempts"=\$3,"next_attempt_at"=\$4,"delivered_at"=\$5`).
+		WithArgs(sqlmock.AnyArg(), "pending", 0, sqlmock.AnyArg(), nil, int64(5)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	resp := api.Post("/api/v1/webhooks/"+pub(2).String()+"/deliveries/"+pub(5).String()+"/redeliver", map[string]any{})
+
+	require.Equal(t, http.StatusAccepted, resp.Code)
+	assert.Contains(t, resp.Body.String(), `"status":"pending"`)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpdateWebhook_MergePatchPausesAndClearsEventTypes(t *testing.T) {
+	db, mock := newMockDB(t)
+	api := newTestAPI(t, db)
+
+	mock.ExpectQuery(`SELECT \* FROM "webhooks" WHERE public_id = \$1`).
+		WithArgs(pub(2), 1).
+		WillReturnRows(sqlmock.NewRows(webhookCols()).
+			AddRow(int64(2), pub(2).String(), fixedTime, fixedTime, nil, int64(1), "https://hooks.example.com/in", `["workout.created"]`, webhookSecret, true))
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "webhooks" SET`).
+		WithArgs(pub(2), fixedTime, sqlmock.AnyArg(), nil, 0, int64(1), "https://hooks.example.com/in", nil, webhookSecret, false, int64(2)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	expectPublicIDs(mock, "users", 1)
+
+	resp := api.Patch("/api/v1/webhooks/"+pub(2).String(), map[string]any{"active": false, "event_types": nil})
+
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	var body schemas.WebhookResponse
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &body))
+	assert.False(t, body.Active)
+	assert.Empty(t, body.EventTypes)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
@@ -6,6 +6,8 @@ import (
 	"testing"
 
 	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
@@ -31,9 +33,10 @@ func TestListWorkouts_WithResults(t *testing.T) {
 	api := newTestAPI(t, db)
 
 	rows := sqlmock.NewRows(workoutCols()).
-		AddRow(int64(1), fixedTime, fixedTime, nil, int64(1), "Morning Run", "5km run", 30).
-		AddRow(int64(2), fixedTime, fixedTime, nil, int64(1), "Evening Yoga", "", 45)
+		AddRow(int64(1), pub(1).String(), fixedTime, fixedTime, nil, int64(1), "Morning Run", "5km run", 30).
+		AddRow(int64(2), pub(2).String(), fixedTime, fixedTime, nil, int64(1), "Evening Yoga", "", 45)
 	mock.ExpectQuery(`SELECT \* FROM "workouts"`).WillReturnRows(rows)
+	expectPublicIDs(mock, "users", 1)
 
 	resp := api.Get("/api/v1/workouts")
 
@@ -51,17 +54,19 @@ func TestListWorkouts_FilterByUser(t *testing.T) {
 	api := newTestAPI(t, db)
 
 	rows := sqlmock.NewRows(workoutCols()).
-		AddRow(int64(1), fixedTime, fixedTime, nil, int64(42), "Pull Day", "", 60)
-	mock.ExpectQuery(`SELECT \* FROM "workouts"`).
+		AddRow(int64(1), pub(1).String(), fixedTime, fixedTime, nil, int64(42), "Pull Day", "", 60)
+	mock.ExpectQuery(`SELECT \* FROM "workouts" WHERE user_id = \(SELECT "id" FROM "users" WHERE public_id = \$1`).
+		WithArgs(pub(42)).
 		WillReturnRows(rows)
+	expectPublicIDs(mock, "users", 42)
 
-	resp := api.Get("/api/v1/workouts?userId=42")
+	resp := api.Get("/api/v1/workouts?userId=" + pub(42).String())
 
 	require.Equal(t, http.StatusOK, resp.Code)
 	var body []schemas.WorkoutResponse
 	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &body))
 	assert.Len(t, body, 1)
-	assert.Equal(t, int64(42), body[0].UserID)
+	assert.Equal(t, pub(42), body[0].UserID)
 	require.NoError(t, mock.ExpectationsWereMet())
 }
 
@@ -69,16 +74,18 @@ func TestGetWorkout_Found(t *testing.T) {
 	db, mock := newMockDB(t)
 	api := newTestAPI(t, db)
 
-	mock.ExpectQuery(`SELECT \* FROM "workouts"`).
+	mock.ExpectQuery(`SELECT \* FROM "workouts" WHERE public_id = \$1`).
+		WithArgs(pub(1), 1).
 		WillReturnRows(sqlmock.NewRows(workoutCols()).
-			AddRow(int64(1), fixedTime, fixedTime, nil, int64(1), "Morning Run", "5km run", 30))
+			AddRow(int64(1), pub(1).String(), fixedTime, fixedTime, nil, int64(1), "Morning Run", "5km run", 30))
+	expectPublicIDs(mock, "users", 1)
 
-	resp := api.Get("/api/v1/workouts/1")
+	resp := api.Get("/api/v1/workouts/" + pub(1).String())
 
 	require.Equal(t, http.StatusOK, resp.Code)
 	var body schemas.WorkoutResponse
 	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &body))
-	assert.Equal(t, int64(1), body.ID)
+	assert.Equal(t, pub(1), body.ID)
 	assert.Equal(t, "Morning Run", body.Name)
 	assert.Equal(t, 30, body.DurationMinutes)
 	require.NoError(t, mock.ExpectationsWereMet())
@@ -91,7 +98,7 @@ func TestGetWorkout_NotFound(t *testing.T) {
 	mock.ExpectQuery(`SELECT \* FROM "workouts"`).
 		WillReturnRows(sqlmock.NewRows(workoutCols())) // no rows → ErrRecordNotFound
 
-	resp := api.Get("/api/v1/workouts/99")
+	resp := api.Get("/api/v1/workouts/" + pub(99).String())
 
 	assert.Equal(t, http.StatusNotFound, resp.Code)
 	require.NoError(t, mock.ExpectationsWereMet())
@@ -101,19 +108,74 @@ func TestCreateWorkout(t *testing.T) {
 	db, mock := newMockDB(t)
 	api := newTestAPI(t, db)
 
+	expectUserLookup(mock, 1)
 	mock.ExpectBegin()
 	mock.ExpectExec(`INSERT INTO "workouts"`).
 		WillReturnResult(sqlmock.NewResult(1, 1))
+	expectRevision(mock, 1, 0)
 	mock.ExpectCommit()
 
 	resp := api.Post("/api/v1/workouts", map[string]any{
-		"user_id":          1,
+		"user_id":          pub(1),
 		"name":             "Morning Run",
 		"description":      "5km run",
 		"duration_minutes": 30,
 	})
 
-	assert.Equal(t, http.StatusCreated, resp.Code)
+	require.Equal(t, http.StatusCreated, resp.Code, resp.Body.String())
+	var body schemas.WorkoutResponse
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &body))
+	// Without an ID from the client a UUIDv7 is generated.
+	assert.Equal(t, uuid.Version(7), body.ID.Version())
+	assert.Equal(t, pub(1), body.UserID)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCreateWorkout_ClientSuppliedID(t *testing.T) {
+	db, mock := newMockDB(t)
+	api := newTestAPI(t, db)
+
+	expectUserLookup(mock, 1)
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO "workouts" \("public_id",`).
+		WithArgs(pub(7), sqlmock.AnyArg(), sqlmock.AnyArg(), nil, int64(1), int64(1), "Morning Run", "", 30, 0,
+			"private", sqlmock.AnyArg(), "[]", "{}", "[]", "[]").
+		WillReturnResult(sqlmock.NewResult(7, 1))
+	expectRevision(mock, 1, 0)
+	mock.ExpectCommit()
+
+	resp := api.Post("/api/v1/workouts", map[string]any{
+		"id":               pub(7),
+		"user_id":          pub(1),
+		"name":             "Morning Run",
+		"duration_minutes": 30,
+	})
+
+	require.Equal(t, http.StatusCreated, resp.Code, resp.Body.String())
+	var body schemas.WorkoutResponse
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &body))
+	assert.Equal(t, pub(7), body.ID)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCreateWorkout_TakenIDConflicts(t *testing.T) {
+	db, mock := newMockDB(t)
+	api := newTestAPI(t, db)
+
+	expectUserLookup(mock, 1)
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO "workouts"`).
+		WillReturnError(&pgconn.PgError{Code: "23505"})
+	mock.ExpectRollback()
+
+	resp := api.Post("/api/v1/workouts", map[string]any{
+		"id":               pub(7),
+		"user_id":          pub(1),
+		"name":             "Morning Run",
+		"duration_minutes": 30,
+	})
+
+	assert.Equal(t, http.StatusConflict, resp.Code, resp.Body.String())
 	require.NoError(t, mock.ExpectationsWereMet())
 }
 
@@ -123,14 +185,15 @@ func TestUpdateWorkout(t *testing.T) {
 
 	mock.ExpectQuery(`SELECT \* FROM "workouts"`).
 		WillReturnRows(sqlmock.NewRows(workoutCols()).
-			AddRow(int64(1), fixedTime, fixedTime, nil, int64(1), "Old Name", "", 20))
+			AddRow(int64(1), pub(1).String(), fixedTime, fixedTime, nil, int64(1), "Old Name", "", 20))
 
 	mock.ExpectBegin()
 	mock.ExpectExec(`UPDATE "workouts" SET`).
 		WillReturnResult(sqlmock.NewResult(1, 1))
+	expectRevision(mock, 1, 1)
 	mock.ExpectCommit()
 
-	resp := api.Patch("/api/v1/workouts/1", map[string]any{
+	resp := api.Patch("/api/v1/workouts/"+pub(1).String(), map[string]any{
 		"name":             "New Name",
 		"duration_minutes": 45,
 	})
@@ -143,13 +206,17 @@ func TestDeleteWorkout(t *testing.T) {
 	db, mock := newMockDB(t)
 	api := newTestAPI(t, db)
 
+	mock.ExpectQuery(`SELECT \* FROM "workouts"`).
+		WillReturnRows(sqlmock.NewRows(workoutCols()).
+			AddRow(int64(1), pub(1).String(), fixedTime, fixedTime, nil, int64(1), "Morning Run", "", 30))
+
 	// Soft-delete: GORM issues UPDATE SET deleted_at=... rather than DELETE.
 	mock.ExpectBegin()
 	mock.ExpectExec(`UPDATE "workouts" SET "deleted_at"`).
 		WillReturnResult(sqlmock.NewResult(0, 1))
 	mock.ExpectCommit()
 
-	resp := api.Delete("/api/v1/workouts/1")
+	resp := api.Delete("/api/v1/workouts/" + pub(1).String())
 
 	assert.Equal(t, http.StatusNoContent, resp.Code)
 	require.NoError(t, mock.ExpectationsWereMet())
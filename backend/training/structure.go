@@ -0,0 +1,150 @@
+package training
+
+import (
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+// Group types. Supersets, giant sets and circuits are exercises performed
+// back to back for a number of rounds; EMOMs, AMRAPs and Tabatas are
+// timed intervals.
+const (
+	GroupSuperset = "superset"
+	GroupGiantSet = "giant_set"
+	GroupCircuit  = "circuit"
+	GroupEMOM     = "emom"
+	GroupAMRAP    = "amrap"
+	GroupTabata   = "tabata"
+)
+
+// MaxDepth is how deeply groups nest, counting the outermost.
+const MaxDepth = 3
+
+// Group gathers exercises, and groups nested in it, performed together.
+// Its exercises name it as their GroupID and must be listed one after
+// another, so a workout's exercises in order are also the order of its
+// structure. Sets of grouped exercises are logged as performed, one per
+// round, so they count towards volume as any other.
+type Group struct {
+	ID              uuid.UUID  `json:"id" format:"uuid" doc:"ID of the group, which its exercises and nested groups refer to"`
+	ParentID        *uuid.UUID `json:"parent_id,omitempty" format:"uuid" doc:"Group this one is nested in"`
+	Type            string     `json:"type" enum:"superset,giant_set,circuit,emom,amrap,tabata"`
+	Name            string     `json:"name,omitempty" maxLength:"100" doc:"Label such as A or Finisher"`
+	Rounds          *int       `json:"rounds,omitempty" minimum:"1" maximum:"1000" doc:"Rounds performed; for AMRAPs, full rounds completed. Tabatas default to 8."`
+	WorkSeconds     *int       `json:"work_seconds,omitempty" minimum:"1" maximum:"3600" doc:"Length of each interval of an EMOM (60 by default), or of each work period of a Tabata (20 by default)"`
+	RestSeconds     *int       `json:"rest_seconds,omitempty" minimum:"0" maximum:"3600" doc:"Rest between rounds; for Tabatas, after each work period (10 by default)"`
+	DurationSeconds *int       `json:"duration_seconds,omitempty" minimum:"1" maximum:"86400" doc:"Time cap of an AMRAP"`
+}
+
+// Node is one entry of a workout's structure: an exercise, or a group with
+// what is performed in it as Children.
+type Node struct {
+	Exercise *Exercise `json:"exercise,omitempty"`
+	Group    *Group    `json:"group,omitempty"`
+	Children []Node    `json:"children,omitempty" doc:"Exercises and groups of a group, in order"`
+}
+
+// Tree arranges exercises into the groups they belong to, keeping their
+// order. It fails when an exercise or group refers to a group that isn't
+// among groups, when groups nest in a cycle or deeper than MaxDepth, when
+// the exercises of a group aren't listed one after another, and when a
+// group has no exercises.
+func Tree(groups []Group, exercises []Exercise) ([]Node, error) {
+	byID := make(map[uuid.UUID]*Group, len(groups))
+	for i := range groups {
+		g := &groups[i]
+		if byID[g.ID] != nil {
+			return nil, fmt.Errorf("group %d: duplicate id %s", i+1, g.ID)
+		}
+		byID[g.ID] = g
+	}
+	paths := make(map[uuid.UUID][]*Group, len(groups))
+	// path returns the groups from the outermost down to id.
+	var path func(id uuid.UUID, depth int) ([]*Group, error)
+	path = func(id uuid.UUID, depth int) ([]*Group, error) {
+		if p, ok := paths[id]; ok {
+			return p, nil
+		}
+		g := byID[id]
+		if g == nil {
+			return nil, fmt.Errorf("no group %s", id)
+		}
+		// Groups nesting in themselves never end, so they too run past
+		// MaxDepth.
+		if depth >= MaxDepth {
+			return nil, fmt.Errorf("group %s nests more than %d deep", id, MaxDepth)
+		}
+		var p []*Group
+		if g.ParentID != nil {
+			parent, err := path(*g.ParentID, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			p = append(p, parent...)
+		}
+		p = append(p, g)
+		if len(p) > MaxDepth {
+			return nil, fmt.Errorf("group %s nests more than %d deep", id, MaxDepth)
+		}
+		paths[id] = p
+		return p, nil
+	}
+	for _, g := range groups {
+		if _, err := path(g.ID, 0); err != nil {
+			return nil, err
+		}
+	}
+
+	var root []Node
+	// open are the nodes of the groups the last exercise was in, outermost
+	// first. Nodes are only appended to the innermost, so pointers to the
+	// open ones stay valid.
+	var open []*Node
+	done := make(map[uuid.UUID]bool, len(groups))
+	for i := range exercises {
+		e := &exercises[i]
+		var p []*Group
+		if e.GroupID != nil {
+			var err error
+			if p, err = path(*e.GroupID, 0); err != nil {
+				return nil, fmt.Errorf("exercise %d (%s): %w", i+1, e.Name, err)
+			}
+		}
+		k := 0
+		for k < len(open) && k < len(p) && open[k].Group == p[k] {
+			k++
+		}
+		for _, n := range open[k:] {
+			done[n.Group.ID] = true
+		}
+		open = open[:k]
+		for _, g := range p[k:] {
+			if done[g.ID] {
+				return nil, fmt.Errorf("exercise %d (%s): the exercises of group %s must be listed one after another", i+1, e.Name, g.ID)
+			}
+			open = append(open, appendNode(&root, open, Node{Group: g}))
+		}
+		appendNode(&root, open, Node{Exercise: e})
+	}
+	for _, n := range open {
+		done[n.Group.ID] = true
+	}
+	for _, g := range groups {
+		if !done[g.ID] {
+			return nil, fmt.Errorf("group %s has no exercises", g.ID)
+		}
+	}
+	return root, nil
+}
+
+// appendNode adds n to the innermost of open, or to root when none are,
+// and returns where it now is.
+func appendNode(root *[]Node, open []*Node, n Node) *Node {
+	list := root
+	if len(open) > 0 {
+		list = &open[len(open)-1].Children
+	}
+	*list = append(*list, n)
+	return &(*list)[len(*list)-1]
+}
@@ -0,0 +1,75 @@
+// Package training describes what was done in a workout: the exercises
+// performed and each of their sets, with how hard a set was (RPE or RIR),
+// its tempo, and whether it was a warm-up, a drop set or taken to failure.
+//
+// Exercises may be grouped into supersets, giant sets and circuits, or
+// timed structures such as EMOMs, AMRAPs and Tabatas, which nest; Tree
+// arranges them as performed.
+//
+// Workouts store their exercises and groups as JSON documents, so these
+// types are both what the database holds and what the API exchanges.
+// Warm-up sets are recorded but left out of training volume and personal
+// records.
+package training
+
+import "github.com/google/uuid"
+
+// Exercise is one exercise of a workout, with its sets in the order they
+// were performed.
+type Exercise struct {
+	ID      uuid.UUID  `json:"id,omitempty" format:"uuid" doc:"Stable ID of the exercise within the workout; assigned when omitted"`
+	Name    string     `json:"name" minLength:"1" maxLength:"100" doc:"Exercise name, e.g. Back squat"`
+	Notes   string     `json:"notes,omitempty" maxLength:"2000" doc:"Notes on the exercise as a whole, such as cues or setup"`
+	GroupID *uuid.UUID `json:"group_id,omitempty" format:"uuid" doc:"Group the exercise was performed in; a group's exercises are listed one after another"`
+	Sets    []Set      `json:"sets" maxItems:"100" doc:"Sets in the order they were performed"`
+}
+
+// Set is one set of an exercise. A set records reps, a duration or both;
+// effort is given as RPE or as RIR, not both.
+type Set struct {
+	Reps            *int     `json:"reps,omitempty" minimum:"0" maximum:"10000" doc:"Repetitions performed"`
+	WeightKg        *float64 `json:"weight_kg,omitempty" minimum:"0" maximum:"2000" doc:"Load in kilograms; omitted for bodyweight sets"`
+	DurationSeconds *int     `json:"duration_seconds,omitempty" minimum:"0" maximum:"86400" doc:"Time under load, for timed sets such as planks"`
+	RPE             *float64 `json:"rpe,omitempty" minimum:"1" maximum:"10" multipleOf:"0.5" doc:"Rating of perceived exertion, 1-10 in steps of 0.5"`
+	RIR             *int     `json:"rir,omitempty" minimum:"0" maximum:"10" doc:"Repetitions in reserve"`
+	Tempo           string   `json:"tempo,omitempty" pattern:"^([0-9]{1,2}|[xX])(-([0-9]{1,2}|[xX])){3}$" doc:"Seconds of the eccentric, bottom pause, concentric and top pause, e.g. 3-1-1-0; X means explosive"`
+	Warmup          bool     `json:"warmup,omitempty" doc:"A warm-up set, left out of volume and personal records"`
+	DropSet         bool     `json:"drop_set,omitempty" doc:"Performed straight after the previous set at a lower load"`
+	Failure         bool     `json:"failure,omitempty" doc:"Taken to muscular failure"`
+	Notes           string   `json:"notes,omitempty" maxLength:"500" doc:"Notes on this set"`
+}
+
+// Working reports whether s counts towards volume and records, which
+// every set but warm-ups does.
+func (s Set) Working() bool {
+	return !s.Warmup
+}
+
+// Volume returns the load s moved, reps times weight, in kilograms. Sets
+// without both, and warm-ups, move none.
+func (s Set) Volume() float64 {
+	if !s.Working() || s.Reps == nil || s.WeightKg == nil {
+		return 0
+	}
+	return float64(*s.Reps) * *s.WeightKg
+}
+
+// Totals are the working sets of some exercises and the volume they moved.
+type Totals struct {
+	WorkingSets int
+	VolumeKg    float64
+}
+
+// Sum totals the working sets of exercises.
+func Sum(exercises []Exercise) Totals {
+	var t Totals
+	for _, e := range exercises {
+		for _, s := range e.Sets {
+			if s.Working() {
+				t.WorkingSets++
+				t.VolumeKg += s.Volume()
+			}
+		}
+	}
+	return t
+}
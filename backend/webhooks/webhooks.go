@@ -0,0 +1,299 @@
+// Package webhooks delivers change events to integrator-registered URLs.
+//
+// The Dispatcher consumes the events outbox, turning each event into one
+// WebhookDelivery per subscribed webhook, then POSTs a signed JSON payload
+// for every due delivery, retrying failures with exponential backoff.
+// Deliveries and every attempt are persisted, so retries survive restarts
+// and integrators can inspect what was sent.
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"workout-tracker/backend/events"
+	"workout-tracker/backend/models"
+	"workout-tracker/backend/netguard"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Request headers sent with every delivery.
+const (
+	HeaderSignature = "X-Webhook-Signature"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderDelivery  = "X-Webhook-Delivery"
+)
+
+// Consumer is the Dispatcher's name in the events outbox.
+const Consumer = "webhooks"
+
+// leaseTimeout is how long a claimed delivery is hidden from other workers.
+// It must outlast Client.Timeout so a crashed attempt is retried, not raced.
+const leaseTimeout = time.Minute
+
+// dueBatch bounds how many due deliveries one pass claims.
+const dueBatch = 100
+
+// Payload is the JSON body POSTed to a webhook.
+type Payload struct {
+	EventID      int64           `json:"event_id"`
+	Type         string          `json:"type"`
+	ResourceType string          `json:"resource_type"`
+	ResourceID   uuid.UUID       `json:"resource_id"`
+	OccurredAt   time.Time       `json:"occurred_at"`
+	Data         json.RawMessage `json:"data"`
+}
+
+// Sign returns the signature header value for body sent at timestamp:
+// "sha256=" followed by the hex HMAC-SHA256 of "<timestamp>.<body>" keyed
+// with the webhook secret. Receivers should recompute it and reject stale
+// timestamps.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Backoff doubles the delay after every failed attempt, starting at 30
+// seconds and capped at six hours.
+func Backoff(attempt int) time.Duration {
+	const base, ceiling = 30 * time.Second, 6 * time.Hour
+	if attempt > 20 {
+		return ceiling
+	}
+	return min(base<<(attempt-1), ceiling)
+}
+
+// Dispatcher queues and sends webhook deliveries. The exported fields may be
+// adjusted before Run is called.
+type Dispatcher struct {
+	db *gorm.DB
+
+	// Client sends deliveries. The default one refuses to connect to
+	// internal addresses; see netguard.
+	Client *http.Client
+	// MaxPerEndpoint bounds how many deliveries to one webhook are in
+	// flight at once. Deliveries to different webhooks are sent in
+	// parallel, so one slow receiver doesn't hold up the rest.
+	MaxPerEndpoint int
+	// MaxAttempts is how many tries a delivery gets before it is failed.
+	MaxAttempts int
+	// Backoff returns the delay before retrying after the given attempt.
+	Backoff func(attempt int) time.Duration
+	// PollInterval is how often due deliveries are checked.
+	PollInterval time.Duration
+	// Now is the clock; tests may pin it.
+	Now func() time.Time
+}
+
+func NewDispatcher(db *gorm.DB) *Dispatcher {
+	return &Dispatcher{
+		db:             db,
+		Client:         netguard.Client(10 * time.Second),
+		MaxPerEndpoint: 4,
+		MaxAttempts:    8,
+		Backoff:        Backoff,
+		PollInterval:   5 * time.Second,
+		Now:            time.Now,
+	}
+}
+
+// Run sends due deliveries every PollInterval until ctx is cancelled.
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.PollInterval)
+	defer ticker.Stop()
+	for {
+		if err := d.DeliverDue(ctx); err != nil {
+			slog.Error("webhooks: delivery pass failed", "err", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Enqueue creates a pending delivery of e for every active webhook that
+// subscribes to it. It is the Dispatcher's outbox handler, so the
+// deliveries are written in the outbox's transaction through tx.
+func (d *Dispatcher) Enqueue(ctx context.Context, tx *gorm.DB, e events.Event) error {
+	var hooks []models.Webhook
+	err := tx.Where("active AND (user_id IS NULL OR user_id = ?)", e.UserID).Find(&hooks).Error
+	if err != nil {
+		return err
+	}
+	now := d.Now()
+	var rows []models.WebhookDelivery
+	for _, h := range hooks {
+		if h.Subscribes(e.Type) {
+			rows = append(rows, models.WebhookDelivery{
+				WebhookID:     h.ID,
+				EventID:       e.ID,
+				Status:        models.DeliveryStatusPending,
+				NextAttemptAt: now,
+			})
+		}
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+	// Should the same event ever be handed over twice, the unique
+	// (webhook_id, event_id) index keeps it to one delivery.
+	return tx.Omit(clause.Associations).
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&rows).Error
+}
+
+// DeliverDue sends every pending delivery whose next attempt is due and
+// waits for the sends to finish. Deliveries are claimed in due order and
+// then sent concurrently, at most MaxPerEndpoint at a time per webhook.
+func (d *Dispatcher) DeliverDue(ctx context.Context) error {
+	var due []models.WebhookDelivery
+	err := d.db.Preload("Webhook").Preload("Event").
+		Where("status = ? AND next_attempt_at <= ?", models.DeliveryStatusPending, d.Now()).
+		Order("next_attempt_at").
+		Limit(dueBatch).
+		Find(&due).Error
+	if err != nil {
+		return err
+	}
+
+	var (
+		wg    sync.WaitGroup
+		mu    sync.Mutex
+		errs  []error
+		slots = make(map[int64]chan struct{})
+	)
+	for _, dl := range due {
+		if ctx.Err() != nil {
+			break
+		}
+		claimed, err := d.claim(dl)
+		if err != nil {
+			mu.Lock()
+			errs = append(errs, err)
+			mu.Unlock()
+			break
+		}
+		if !claimed {
+			continue
+		}
+		slot, ok := slots[dl.WebhookID]
+		if !ok {
+			slot = make(chan struct{}, max(d.MaxPerEndpoint, 1))
+			slots[dl.WebhookID] = slot
+		}
+		wg.Go(func() {
+			slot <- struct{}{}
+			defer func() { <-slot }()
+			// A delivery left unsent on shutdown is retried once its
+			// lease runs out.
+			if ctx.Err() != nil {
+				return
+			}
+			if err := d.attempt(ctx, dl); err != nil {
+				mu.Lock()
+				errs = append(errs, err)
+				mu.Unlock()
+			}
+		})
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// claim leases dl to this worker by pushing its next attempt past the
+// lease timeout, failing if another worker got there first.
+func (d *Dispatcher) claim(dl models.WebhookDelivery) (bool, error) {
+	res := d.db.Model(&models.WebhookDelivery{}).
+		Where("id = ? AND status = ? AND next_attempt_at = ?", dl.ID, models.DeliveryStatusPending, dl.NextAttemptAt).
+		Update("next_attempt_at", d.Now().Add(leaseTimeout))
+	return res.RowsAffected == 1, res.Error
+}
+
+// attempt sends dl once and records the outcome.
+func (d *Dispatcher) attempt(ctx context.Context, dl models.WebhookDelivery) error {
+	rec := models.WebhookAttempt{DeliveryID: dl.ID}
+	switch {
+	case dl.Webhook.ID == 0:
+		rec.Error = "webhook was deleted"
+	case !dl.Webhook.Active:
+		rec.Error = "webhook is disabled"
+	default:
+		rec.StatusCode, rec.DurationMS, rec.Error = d.send(ctx, dl)
+	}
+
+	now := d.Now()
+	updates := map[string]any{"attempts": dl.Attempts + 1}
+	switch {
+	case rec.Error == "":
+		updates["status"] = models.DeliveryStatusSucceeded
+		updates["delivered_at"] = now
+	case dl.Webhook.ID == 0 || !dl.Webhook.Active || dl.Attempts+1 >= d.MaxAttempts:
+		updates["status"] = models.DeliveryStatusFailed
+	default:
+		updates["next_attempt_at"] = now.Add(d.Backoff(dl.Attempts + 1))
+	}
+	return d.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit(clause.Associations).Create(&rec).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.WebhookDelivery{}).Where("id = ?", dl.ID).Updates(updates).Error
+	})
+}
+
+// send POSTs the signed payload for dl, returning the response status, how
+// long the request took and an error description if it did not succeed.
+func (d *Dispatcher) send(ctx context.Context, dl models.WebhookDelivery) (int, int64, string) {
+	body, err := json.Marshal(Payload{
+		EventID:      dl.Event.ID,
+		Type:         dl.Event.Type,
+		ResourceType: dl.Event.ResourceType,
+		ResourceID:   dl.Event.ResourcePublicID,
+		OccurredAt:   dl.Event.CreatedAt,
+		Data:         dl.Event.Payload,
+	})
+	if err != nil {
+		return 0, 0, err.Error()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, dl.Webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, 0, err.Error()
+	}
+	ts := d.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderSignature, Sign(dl.Webhook.Secret, ts, body))
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(ts, 10))
+	req.Header.Set(HeaderEvent, dl.Event.Type)
+	req.Header.Set(HeaderDelivery, dl.PublicID.String())
+
+	start := time.Now()
+	resp, err := d.Client.Do(req)
+	elapsed := time.Since(start).Milliseconds()
+	if err != nil {
+		return 0, elapsed, err.Error()
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, elapsed, fmt.Sprintf("unexpected status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, elapsed, ""
+}
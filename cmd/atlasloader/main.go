@@ -16,6 +16,30 @@ func main() {
 	stmts, err := gormschema.New("postgres").Load(
 		&models.User{},
 		&models.Workout{},
+		&models.Organization{},
+		&models.Membership{},
+		&models.WorkoutTemplate{},
+		&models.Follow{},
+		&models.WorkoutLike{},
+		&models.WorkoutComment{},
+		&models.Challenge{},
+		&models.ChallengeParticipant{},
+		&models.Event{},
+		&models.Webhook{},
+		&models.WebhookDelivery{},
+		&models.WebhookAttempt{},
+		&models.OutboxMessage{},
+		&models.Job{},
+		&models.PlannedWorkout{},
+		&models.PlannedSession{},
+		&models.PushSubscription{},
+		&models.NotificationPreference{},
+		&models.Report{},
+		&models.AuditLog{},
+		&models.WorkoutRevision{},
+		&models.IdempotencyKey{},
+		&models.CustomField{},
+		&models.Attachment{},
 	)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "failed to load gorm schema: %v\n", err)
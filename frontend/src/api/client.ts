@@ -1,4 +1,14 @@
 import createClient from 'openapi-fetch'
+import { useAuth } from '@/composables/useAuth.js'
 import type { paths } from '../generated/api'
 
 export const api = createClient<paths>({ baseUrl: '/' })
+
+// Send the signed-in user's access token, when there is one, with every call.
+api.use({
+  onRequest({ request }) {
+    const { token } = useAuth()
+    if (token.value) request.headers.set('Authorization', `Bearer ${token.value}`)
+    return request
+  },
+})
@@ -1,23 +1,35 @@
 import { ref } from 'vue'
 
-// Module-level refs so state is shared across all callers.
-const userId = ref(localStorage.getItem('userId') ? Number(localStorage.getItem('userId')) : null)
+// Module-level refs so state is shared across all callers. User IDs are
+// public UUIDs, kept as strings. token is the identity provider's access
+// token, sent as a bearer token; it stays null in dev mode, where the API
+// takes the user from the request instead.
+const userId = ref(localStorage.getItem('userId') || null)
 const userName = ref(localStorage.getItem('userName') || null)
+const token = ref(localStorage.getItem('token') || null)
 
 export function useAuth() {
-  function login(id, name) {
+  function login(id, name, accessToken = null) {
     userId.value = id
     userName.value = name
-    localStorage.setItem('userId', String(id))
+    token.value = accessToken
+    localStorage.setItem('userId', id)
     localStorage.setItem('userName', name)
+    if (accessToken) {
+      localStorage.setItem('token', accessToken)
+    } else {
+      localStorage.removeItem('token')
+    }
   }
 
   function logout() {
     userId.value = null
     userName.value = null
+    token.value = null
     localStorage.removeItem('userId')
     localStorage.removeItem('userName')
+    localStorage.removeItem('token')
   }
 
-  return { userId, userName, login, logout }
+  return { userId, userName, token, login, logout }
 }
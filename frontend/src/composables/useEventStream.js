@@ -0,0 +1,98 @@
+import { onBeforeUnmount, watch } from 'vue'
+import { useAuth } from '@/composables/useAuth.js'
+
+const defaultRetryMs = 3000
+
+// Streams /api/v1/events for the signed-in user, calling onEvent(type, data)
+// for each event of the given types. The stream is read with fetch rather
+// than EventSource, which cannot send the Authorization header the API
+// requires once sign-in goes through the identity provider. Like
+// EventSource it reconnects after the connection drops and resumes from
+// the last event seen via Last-Event-ID. It is (re)opened whenever the
+// user changes and closed with the calling component.
+export function useEventStream(types, onEvent) {
+  const { userId, token } = useAuth()
+  let controller = null
+
+  const close = () => {
+    controller?.abort()
+    controller = null
+  }
+
+  watch(
+    [userId, token],
+    ([id, bearer]) => {
+      close()
+      if (!id) return
+      controller = new AbortController()
+      stream(id, bearer, controller.signal, (type, data) => {
+        if (types.includes(type)) onEvent(type, data)
+      })
+    },
+    { immediate: true },
+  )
+  onBeforeUnmount(close)
+}
+
+async function stream(id, bearer, signal, dispatch) {
+  let lastEventId = ''
+  let retryMs = defaultRetryMs
+  while (!signal.aborted) {
+    try {
+      const headers = { Accept: 'text/event-stream' }
+      if (bearer) headers.Authorization = `Bearer ${bearer}`
+      if (lastEventId) headers['Last-Event-ID'] = lastEventId
+      const res = await fetch(`/api/v1/events?userId=${encodeURIComponent(id)}`, {
+        headers,
+        signal,
+      })
+      if (!res.ok || !res.body) throw new Error(`event stream: ${res.status}`)
+      for await (const msg of messages(res.body)) {
+        if (msg.id) lastEventId = msg.id
+        if (msg.retry) retryMs = msg.retry
+        if (msg.data !== undefined) dispatch(msg.event || 'message', msg.data)
+      }
+    } catch {
+      if (signal.aborted) return
+    }
+    await new Promise((resolve) => setTimeout(resolve, retryMs))
+  }
+}
+
+// Parses a text/event-stream body into messages, per the SSE spec's field
+// rules: a blank line ends a message, and data lines are joined by "\n".
+async function* messages(body) {
+  let buf = ''
+  let msg = {}
+  for await (const chunk of body.pipeThrough(new TextDecoderStream())) {
+    buf += chunk
+    const lines = buf.split(/\r\n|\r|\n/)
+    buf = lines.pop()
+    for (const line of lines) {
+      if (line === '') {
+        yield msg
+        msg = {}
+        continue
+      }
+      if (line.startsWith(':')) continue
+      const colon = line.indexOf(':')
+      const field = colon < 0 ? line : line.slice(0, colon)
+      let value = colon < 0 ? '' : line.slice(colon + 1)
+      if (value.startsWith(' ')) value = value.slice(1)
+      switch (field) {
+        case 'event':
+          msg.event = value
+          break
+        case 'data':
+          msg.data = msg.data === undefined ? value : `${msg.data}\n${value}`
+          break
+        case 'id':
+          msg.id = value
+          break
+        case 'retry':
+          if (/^\d+$/.test(value)) msg.retry = Number(value)
+          break
+      }
+    }
+  }
+}
@@ -1,18 +1,36 @@
 package main
 
 import (
+	"cmp"
 	"context"
 	"log"
 	"net/http"
+	"net/mail"
+	"net/smtp"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/danielgtaylor/huma/v2"
 	"github.com/danielgtaylor/huma/v2/adapters/humagin"
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
+	"gorm.io/gorm"
 	"workout-tracker/backend"
+	"workout-tracker/backend/attachments"
+	"workout-tracker/backend/audit"
+	"workout-tracker/backend/blob"
 	"workout-tracker/backend/db"
+	"workout-tracker/backend/email"
+	"workout-tracker/backend/events"
+	"workout-tracker/backend/idempotency"
+	"workout-tracker/backend/jobs"
 	"workout-tracker/backend/middleware"
+	"workout-tracker/backend/notify"
+	"workout-tracker/backend/planner"
+	"workout-tracker/backend/reports"
+	"workout-tracker/backend/webhooks"
 )
 
 func main() {
@@ -24,11 +42,77 @@ func main() {
 		log.Fatal("DATABASE_URL is not set")
 	}
 
-	database, err := db.Connect(dsn)
+	database, bus, err := db.Connect(dsn)
 	if err != nil {
 		log.Fatal("Failed to connect to database:", err)
 	}
 
+	// Side effects of a change run from the outbox, each consumer seeing each
+	// event exactly once however many instances are running.
+	outbox, err := events.NewOutbox(database)
+	if err != nil {
+		log.Fatal("Failed to set up outbox:", err)
+	}
+	dispatcher := webhooks.NewDispatcher(database)
+	outbox.Register(webhooks.Consumer, dispatcher.Enqueue)
+
+	// Background jobs share the database with every other instance; the
+	// scheduler's unique keys keep periodic tasks to one run per slot.
+	runner := jobs.NewRunner(database)
+	scheduler := jobs.NewScheduler(database)
+	// Deleted rows, workouts in users' trash among them, are kept this many
+	// days before the nightly purge removes them.
+	retentionDays, _ := strconv.Atoi(os.Getenv("TRASH_RETENTION_DAYS"))
+	if retentionDays <= 0 {
+		retentionDays = jobs.DefaultRetentionDays
+	}
+	// Responses to POSTs made with an Idempotency-Key are replayed to
+	// retries for this many hours.
+	idempotencyHours, _ := strconv.Atoi(os.Getenv("IDEMPOTENCY_WINDOW_HOURS"))
+	idempotencyKeys := idempotency.New(database, time.Duration(idempotencyHours)*time.Hour)
+	if err := idempotencyKeys.Register(runner, scheduler); err != nil {
+		log.Fatal("Failed to set up idempotency jobs:", err)
+	}
+	signer := email.NewSigner(os.Getenv("EMAIL_TOKEN_SECRET"))
+	mailer, err := newMailer(database, signer)
+	if err != nil {
+		log.Fatal("Failed to set up email:", err)
+	}
+	if err := mailer.Register(runner, scheduler); err != nil {
+		log.Fatal("Failed to set up email jobs:", err)
+	}
+	outbox.Register(email.Consumer, mailer.HandleEvent)
+	if err := reports.New(database).Register(runner, scheduler); err != nil {
+		log.Fatal("Failed to set up reports:", err)
+	}
+	blobs := newBlobStore()
+	if err := attachments.NewSweeper(database, blobs).Register(runner, scheduler); err != nil {
+		log.Fatal("Failed to set up attachment jobs:", err)
+	}
+	// Uploads are capped per file and per user, in MiB.
+	maxUploadMB, _ := strconv.ParseInt(os.Getenv("ATTACHMENT_MAX_MB"), 10, 64)
+	quotaMB, _ := strconv.ParseInt(os.Getenv("ATTACHMENT_QUOTA_MB"), 10, 64)
+	channels, err := reminderChannels(database, mailer)
+	if err != nil {
+		log.Fatal("Failed to set up reminder channels:", err)
+	}
+	if err := planner.New(database, channels).Register(runner, scheduler); err != nil {
+		log.Fatal("Failed to set up planner:", err)
+	}
+	// Follows, likes, comments and inactivity reminders go out on the same
+	// channels as planned-session reminders.
+	notifier := notify.NewChannelNotifier(database, channels)
+	if err := jobs.RegisterMaintenance(runner, scheduler, notifier, retentionDays); err != nil {
+		log.Fatal("Failed to set up jobs:", err)
+	}
+
+	ctx := context.Background()
+	go bus.Run(ctx)
+	go outbox.Run(ctx, bus)
+	go dispatcher.Run(ctx)
+	go runner.Run(ctx)
+	go scheduler.Run(ctx)
+
 	// Zitadel auth is optional: set ZITADEL_DOMAIN to enable it.
 	var authorizer *middleware.Authorizer
 	if domain := os.Getenv("ZITADEL_DOMAIN"); domain != "" {
@@ -45,11 +129,18 @@ func main() {
 	}
 
 	r := gin.Default()
+	// Client addresses for the audit log come from X-Forwarded-For only
+	// when the request arrives through one of these proxies.
+	if err := r.SetTrustedProxies(trustedProxies()); err != nil {
+		log.Fatal("Invalid TRUSTED_PROXIES:", err)
+	}
+	r.Use(audit.ClientIP)
 
 	r.Use(func(c *gin.Context) {
 		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
 		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Request-ID, Idempotency-Key, If-Match, If-None-Match")
+		c.Writer.Header().Set("Access-Control-Expose-Headers", "X-Request-ID, Idempotent-Replayed, ETag")
 		if c.Request.Method == http.MethodOptions {
 			c.AbortWithStatus(http.StatusNoContent)
 			return
@@ -62,7 +153,16 @@ func main() {
 	config := huma.DefaultConfig("Workout Tracker API", "1.0.0")
 	api := humagin.New(r, config)
 
-	backend.RegisterRoutes(api, database)
+	backend.RegisterRoutes(api, database,
+		backend.WithEventBus(bus),
+		backend.WithNotifier(notifier),
+		backend.WithUnsubscribeSigner(signer),
+		backend.WithTrashRetention(retentionDays),
+		backend.WithIdempotency(idempotencyKeys),
+		backend.WithBlobStore(blobs),
+		backend.WithAttachmentSigner(attachments.NewSigner(os.Getenv("ATTACHMENT_URL_SECRET"))),
+		backend.WithAttachmentLimits(attachments.Limits{MaxSize: maxUploadMB << 20, Quota: quotaMB << 20}),
+	)
 
 	port := os.Getenv("PORT")
 	if port == "" {
@@ -77,3 +177,75 @@ func main() {
 		log.Fatal(err)
 	}
 }
+
+// newMailer sends email through the relay at SMTP_ADDR, or only logs it
+// when that is unset.
+func newMailer(database *gorm.DB, signer *email.Signer) (*email.Mailer, error) {
+	var sender email.Sender = email.LogSender{}
+	if addr := os.Getenv("SMTP_ADDR"); addr != "" {
+		from, err := mail.ParseAddress(os.Getenv("SMTP_FROM"))
+		if err != nil {
+			return nil, err
+		}
+		relay := &notify.SMTP{Addr: addr, From: *from}
+		if user := os.Getenv("SMTP_USERNAME"); user != "" {
+			host, _, _ := strings.Cut(addr, ":")
+			relay.Auth = smtp.PlainAuth("", user, os.Getenv("SMTP_PASSWORD"), host)
+		}
+		sender = relay
+	}
+	baseURL := os.Getenv("APP_BASE_URL")
+	if baseURL == "" {
+		baseURL = "http://localhost:" + cmp.Or(os.Getenv("PORT"), "8080")
+	}
+	return email.New(database, sender, signer, baseURL)
+}
+
+// trustedProxies returns the comma-separated addresses and CIDR ranges in
+// TRUSTED_PROXIES, or nil, trusting no proxy, when it is unset.
+func trustedProxies() []string {
+	var proxies []string
+	for p := range strings.SplitSeq(os.Getenv("TRUSTED_PROXIES"), ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			proxies = append(proxies, p)
+		}
+	}
+	return proxies
+}
+
+// newBlobStore keeps attachments in the S3-compatible bucket named by
+// ATTACHMENTS_S3_BUCKET, or else in the ATTACHMENTS_DIR directory.
+func newBlobStore() blob.Store {
+	bucket := os.Getenv("ATTACHMENTS_S3_BUCKET")
+	if bucket == "" {
+		return blob.NewFS(cmp.Or(os.Getenv("ATTACHMENTS_DIR"), "data/attachments"))
+	}
+	region := cmp.Or(os.Getenv("ATTACHMENTS_S3_REGION"), "us-east-1")
+	return &blob.S3{
+		Endpoint: cmp.Or(os.Getenv("ATTACHMENTS_S3_ENDPOINT"), "https://s3."+region+".amazonaws.com"),
+		Region:   region,
+		Bucket:   bucket,
+		Credentials: blob.Credentials{
+			AccessKeyID:     os.Getenv("ATTACHMENTS_S3_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("ATTACHMENTS_S3_SECRET_ACCESS_KEY"),
+		},
+	}
+}
+
+// reminderChannels configures the channels planned-session reminders can go
+// out on. Webhooks and email are always available (email is only logged
+// without SMTP settings); Web Push only when its key is present.
+func reminderChannels(database *gorm.DB, mailer *email.Mailer) (notify.Channels, error) {
+	channels := notify.Channels{
+		notify.ChannelWebhook: &notify.Webhook{DB: database},
+		notify.ChannelEmail:   mailer,
+	}
+	if key := os.Getenv("VAPID_PRIVATE_KEY"); key != "" {
+		push, err := notify.NewWebPush(database, key, os.Getenv("VAPID_SUBJECT"))
+		if err != nil {
+			return nil, err
+		}
+		channels[notify.ChannelPush] = push
+	}
+	return channels, nil
+}
@@ -0,0 +1,53 @@
+-- Create "organizations" table
+CREATE TABLE "public"."organizations" (
+  "id" bigserial NOT NULL,
+  "created_at" timestamptz NULL,
+  "updated_at" timestamptz NULL,
+  "deleted_at" timestamptz NULL,
+  "zitadel_org_id" text NULL,
+  "name" text NOT NULL,
+  PRIMARY KEY ("id")
+);
+-- Create index "idx_organizations_deleted_at" to table: "organizations"
+CREATE INDEX "idx_organizations_deleted_at" ON "public"."organizations" ("deleted_at");
+-- Create index "idx_organizations_zitadel_org_id" to table: "organizations"
+CREATE UNIQUE INDEX "idx_organizations_zitadel_org_id" ON "public"."organizations" ("zitadel_org_id");
+-- Create "memberships" table
+CREATE TABLE "public"."memberships" (
+  "id" bigserial NOT NULL,
+  "created_at" timestamptz NULL,
+  "updated_at" timestamptz NULL,
+  "deleted_at" timestamptz NULL,
+  "organization_id" bigint NOT NULL,
+  "user_id" bigint NOT NULL,
+  "role" text NOT NULL DEFAULT 'member',
+  "share_stats" boolean NOT NULL DEFAULT false,
+  PRIMARY KEY ("id"),
+  CONSTRAINT "fk_memberships_organization" FOREIGN KEY ("organization_id") REFERENCES "public"."organizations" ("id") ON UPDATE NO ACTION ON DELETE NO ACTION,
+  CONSTRAINT "fk_memberships_user" FOREIGN KEY ("user_id") REFERENCES "public"."users" ("id") ON UPDATE NO ACTION ON DELETE NO ACTION
+);
+-- Create index "idx_memberships_deleted_at" to table: "memberships"
+CREATE INDEX "idx_memberships_deleted_at" ON "public"."memberships" ("deleted_at");
+-- Create index "idx_memberships_org_user" to table: "memberships"
+CREATE UNIQUE INDEX "idx_memberships_org_user" ON "public"."memberships" ("organization_id", "user_id");
+-- Create index "idx_memberships_user_id" to table: "memberships"
+CREATE INDEX "idx_memberships_user_id" ON "public"."memberships" ("user_id");
+-- Create "workout_templates" table
+CREATE TABLE "public"."workout_templates" (
+  "id" bigserial NOT NULL,
+  "created_at" timestamptz NULL,
+  "updated_at" timestamptz NULL,
+  "deleted_at" timestamptz NULL,
+  "organization_id" bigint NULL,
+  "author_id" bigint NOT NULL,
+  "name" text NOT NULL,
+  "description" text NULL,
+  "duration_minutes" bigint NULL,
+  PRIMARY KEY ("id"),
+  CONSTRAINT "fk_workout_templates_author" FOREIGN KEY ("author_id") REFERENCES "public"."users" ("id") ON UPDATE NO ACTION ON DELETE NO ACTION,
+  CONSTRAINT "fk_workout_templates_organization" FOREIGN KEY ("organization_id") REFERENCES "public"."organizations" ("id") ON UPDATE NO ACTION ON DELETE NO ACTION
+);
+-- Create index "idx_workout_templates_deleted_at" to table: "workout_templates"
+CREATE INDEX "idx_workout_templates_deleted_at" ON "public"."workout_templates" ("deleted_at");
+-- Create index "idx_workout_templates_organization_id" to table: "workout_templates"
+CREATE INDEX "idx_workout_templates_organization_id" ON "public"."workout_templates" ("organization_id");
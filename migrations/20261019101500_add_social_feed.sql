@@ -0,0 +1,57 @@
+-- Drop index "idx_workouts_user_id" from table: "workouts"
+DROP INDEX "public"."idx_workouts_user_id";
+-- Modify "workouts" table
+ALTER TABLE "public"."workouts" ADD COLUMN "visibility" text NOT NULL DEFAULT 'private';
+-- Create index "idx_workouts_feed" to table: "workouts"
+CREATE INDEX "idx_workouts_feed" ON "public"."workouts" ("user_id", "created_at" DESC, "id" DESC);
+-- Create "follows" table
+CREATE TABLE "public"."follows" (
+  "id" bigserial NOT NULL,
+  "created_at" timestamptz NULL,
+  "updated_at" timestamptz NULL,
+  "deleted_at" timestamptz NULL,
+  "follower_id" bigint NOT NULL,
+  "followee_id" bigint NOT NULL,
+  PRIMARY KEY ("id"),
+  CONSTRAINT "fk_follows_followee" FOREIGN KEY ("followee_id") REFERENCES "public"."users" ("id") ON UPDATE NO ACTION ON DELETE NO ACTION,
+  CONSTRAINT "fk_follows_follower" FOREIGN KEY ("follower_id") REFERENCES "public"."users" ("id") ON UPDATE NO ACTION ON DELETE NO ACTION
+);
+-- Create index "idx_follows_deleted_at" to table: "follows"
+CREATE INDEX "idx_follows_deleted_at" ON "public"."follows" ("deleted_at");
+-- Create index "idx_follows_followee_id" to table: "follows"
+CREATE INDEX "idx_follows_followee_id" ON "public"."follows" ("followee_id");
+-- Create index "idx_follows_pair" to table: "follows"
+CREATE UNIQUE INDEX "idx_follows_pair" ON "public"."follows" ("follower_id", "followee_id");
+-- Create "workout_comments" table
+CREATE TABLE "public"."workout_comments" (
+  "id" bigserial NOT NULL,
+  "created_at" timestamptz NULL,
+  "updated_at" timestamptz NULL,
+  "deleted_at" timestamptz NULL,
+  "workout_id" bigint NOT NULL,
+  "user_id" bigint NOT NULL,
+  "body" text NOT NULL,
+  PRIMARY KEY ("id"),
+  CONSTRAINT "fk_workout_comments_user" FOREIGN KEY ("user_id") REFERENCES "public"."users" ("id") ON UPDATE NO ACTION ON DELETE NO ACTION,
+  CONSTRAINT "fk_workout_comments_workout" FOREIGN KEY ("workout_id") REFERENCES "public"."workouts" ("id") ON UPDATE NO ACTION ON DELETE NO ACTION
+);
+-- Create index "idx_workout_comments_deleted_at" to table: "workout_comments"
+CREATE INDEX "idx_workout_comments_deleted_at" ON "public"."workout_comments" ("deleted_at");
+-- Create index "idx_workout_comments_workout_id" to table: "workout_comments"
+CREATE INDEX "idx_workout_comments_workout_id" ON "public"."workout_comments" ("workout_id");
+-- Create "workout_likes" table
+CREATE TABLE "public"."workout_likes" (
+  "id" bigserial NOT NULL,
+  "created_at" timestamptz NULL,
+  "updated_at" timestamptz NULL,
+  "deleted_at" timestamptz NULL,
+  "workout_id" bigint NOT NULL,
+  "user_id" bigint NOT NULL,
+  PRIMARY KEY ("id"),
+  CONSTRAINT "fk_workout_likes_user" FOREIGN KEY ("user_id") REFERENCES "public"."users" ("id") ON UPDATE NO ACTION ON DELETE NO ACTION,
+  CONSTRAINT "fk_workout_likes_workout" FOREIGN KEY ("workout_id") REFERENCES "public"."workouts" ("id") ON UPDATE NO ACTION ON DELETE NO ACTION
+);
+-- Create index "idx_workout_likes_deleted_at" to table: "workout_likes"
+CREATE INDEX "idx_workout_likes_deleted_at" ON "public"."workout_likes" ("deleted_at");
+-- Create index "idx_workout_likes_pair" to table: "workout_likes"
+CREATE UNIQUE INDEX "idx_workout_likes_pair" ON "public"."workout_likes" ("workout_id", "user_id");
@@ -0,0 +1,50 @@
+-- Modify "workouts" table
+ALTER TABLE "public"."workouts" ADD COLUMN "distance_meters" bigint NULL, ADD COLUMN "performed_at" timestamptz NOT NULL DEFAULT now();
+-- Backfill "performed_at" for workouts logged before the column existed
+UPDATE "public"."workouts" SET "performed_at" = "created_at" WHERE "created_at" IS NOT NULL;
+-- Create index "idx_workouts_performed_at" to table: "workouts"
+CREATE INDEX "idx_workouts_performed_at" ON "public"."workouts" ("performed_at");
+-- Create "challenges" table
+CREATE TABLE "public"."challenges" (
+  "id" bigserial NOT NULL,
+  "created_at" timestamptz NULL,
+  "updated_at" timestamptz NULL,
+  "deleted_at" timestamptz NULL,
+  "organization_id" bigint NULL,
+  "created_by_id" bigint NOT NULL,
+  "name" text NOT NULL,
+  "description" text NULL,
+  "metric" text NOT NULL,
+  "timezone" text NOT NULL DEFAULT 'UTC',
+  "starts_at" timestamptz NOT NULL,
+  "ends_at" timestamptz NOT NULL,
+  "late_log_hours" bigint NOT NULL DEFAULT 48,
+  "finalized_at" timestamptz NULL,
+  PRIMARY KEY ("id"),
+  CONSTRAINT "fk_challenges_created_by" FOREIGN KEY ("created_by_id") REFERENCES "public"."users" ("id") ON UPDATE NO ACTION ON DELETE NO ACTION,
+  CONSTRAINT "fk_challenges_organization" FOREIGN KEY ("organization_id") REFERENCES "public"."organizations" ("id") ON UPDATE NO ACTION ON DELETE NO ACTION
+);
+-- Create index "idx_challenges_deleted_at" to table: "challenges"
+CREATE INDEX "idx_challenges_deleted_at" ON "public"."challenges" ("deleted_at");
+-- Create index "idx_challenges_organization_id" to table: "challenges"
+CREATE INDEX "idx_challenges_organization_id" ON "public"."challenges" ("organization_id");
+-- Create "challenge_participants" table
+CREATE TABLE "public"."challenge_participants" (
+  "id" bigserial NOT NULL,
+  "created_at" timestamptz NULL,
+  "updated_at" timestamptz NULL,
+  "deleted_at" timestamptz NULL,
+  "challenge_id" bigint NOT NULL,
+  "user_id" bigint NOT NULL,
+  "final_rank" bigint NULL,
+  "final_value" bigint NULL,
+  PRIMARY KEY ("id"),
+  CONSTRAINT "fk_challenge_participants_challenge" FOREIGN KEY ("challenge_id") REFERENCES "public"."challenges" ("id") ON UPDATE NO ACTION ON DELETE NO ACTION,
+  CONSTRAINT "fk_challenge_participants_user" FOREIGN KEY ("user_id") REFERENCES "public"."users" ("id") ON UPDATE NO ACTION ON DELETE NO ACTION
+);
+-- Create index "idx_challenge_participants_deleted_at" to table: "challenge_participants"
+CREATE INDEX "idx_challenge_participants_deleted_at" ON "public"."challenge_participants" ("deleted_at");
+-- Create index "idx_challenge_participants_pair" to table: "challenge_participants"
+CREATE UNIQUE INDEX "idx_challenge_participants_pair" ON "public"."challenge_participants" ("challenge_id", "user_id");
+-- Create index "idx_challenge_participants_user_id" to table: "challenge_participants"
+CREATE INDEX "idx_challenge_participants_user_id" ON "public"."challenge_participants" ("user_id");
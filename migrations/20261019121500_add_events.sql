@@ -0,0 +1,12 @@
+-- Create "events" table
+CREATE TABLE "public"."events" (
+  "id" bigserial NOT NULL,
+  "created_at" timestamptz NOT NULL,
+  "type" text NOT NULL,
+  "resource_type" text NOT NULL,
+  "resource_id" bigint NOT NULL,
+  "user_id" bigint NOT NULL,
+  "visibility" text NULL,
+  "payload" jsonb NULL,
+  PRIMARY KEY ("id")
+);
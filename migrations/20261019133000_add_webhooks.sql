@@ -0,0 +1,53 @@
+-- Create "webhooks" table
+CREATE TABLE "public"."webhooks" (
+  "id" bigserial NOT NULL,
+  "created_at" timestamptz NULL,
+  "updated_at" timestamptz NULL,
+  "deleted_at" timestamptz NULL,
+  "user_id" bigint NULL,
+  "url" text NOT NULL,
+  "event_types" jsonb NULL,
+  "secret" text NOT NULL,
+  "active" boolean NOT NULL DEFAULT true,
+  PRIMARY KEY ("id"),
+  CONSTRAINT "fk_webhooks_user" FOREIGN KEY ("user_id") REFERENCES "public"."users" ("id") ON UPDATE NO ACTION ON DELETE NO ACTION
+);
+-- Create index "idx_webhooks_deleted_at" to table: "webhooks"
+CREATE INDEX "idx_webhooks_deleted_at" ON "public"."webhooks" ("deleted_at");
+-- Create index "idx_webhooks_user_id" to table: "webhooks"
+CREATE INDEX "idx_webhooks_user_id" ON "public"."webhooks" ("user_id");
+-- Create "webhook_deliveries" table
+CREATE TABLE "public"."webhook_deliveries" (
+  "id" bigserial NOT NULL,
+  "created_at" timestamptz NULL,
+  "updated_at" timestamptz NULL,
+  "deleted_at" timestamptz NULL,
+  "webhook_id" bigint NOT NULL,
+  "event_id" bigint NOT NULL,
+  "status" text NOT NULL DEFAULT 'pending',
+  "attempts" bigint NOT NULL DEFAULT 0,
+  "next_attempt_at" timestamptz NOT NULL,
+  "delivered_at" timestamptz NULL,
+  PRIMARY KEY ("id"),
+  CONSTRAINT "fk_webhook_deliveries_event" FOREIGN KEY ("event_id") REFERENCES "public"."events" ("id") ON UPDATE NO ACTION ON DELETE NO ACTION,
+  CONSTRAINT "fk_webhook_deliveries_webhook" FOREIGN KEY ("webhook_id") REFERENCES "public"."webhooks" ("id") ON UPDATE NO ACTION ON DELETE NO ACTION
+);
+-- Create index "idx_webhook_deliveries_deleted_at" to table: "webhook_deliveries"
+CREATE INDEX "idx_webhook_deliveries_deleted_at" ON "public"."webhook_deliveries" ("deleted_at");
+-- Create index "idx_webhook_deliveries_due" to table: "webhook_deliveries"
+CREATE INDEX "idx_webhook_deliveries_due" ON "public"."webhook_deliveries" ("status", "next_attempt_at");
+-- Create index "idx_webhook_deliveries_event" to table: "webhook_deliveries"
+CREATE UNIQUE INDEX "idx_webhook_deliveries_event" ON "public"."webhook_deliveries" ("webhook_id", "event_id");
+-- Create "webhook_attempts" table
+CREATE TABLE "public"."webhook_attempts" (
+  "id" bigserial NOT NULL,
+  "created_at" timestamptz NOT NULL,
+  "delivery_id" bigint NOT NULL,
+  "status_code" bigint NULL,
+  "error" text NULL,
+  "duration_ms" bigint NOT NULL,
+  PRIMARY KEY ("id"),
+  CONSTRAINT "fk_webhook_attempts_delivery" FOREIGN KEY ("delivery_id") REFERENCES "public"."webhook_deliveries" ("id") ON UPDATE NO ACTION ON DELETE NO ACTION
+);
+-- Create index "idx_webhook_attempts_delivery_id" to table: "webhook_attempts"
+CREATE INDEX "idx_webhook_attempts_delivery_id" ON "public"."webhook_attempts" ("delivery_id");
@@ -0,0 +1,18 @@
+-- Create "outbox_messages" table
+CREATE TABLE "public"."outbox_messages" (
+  "id" bigserial NOT NULL,
+  "created_at" timestamptz NOT NULL,
+  "consumer" text NOT NULL,
+  "event_id" bigint NOT NULL,
+  "attempts" bigint NOT NULL DEFAULT 0,
+  "available_at" timestamptz NOT NULL,
+  "processed_at" timestamptz NULL,
+  "failed_at" timestamptz NULL,
+  "last_error" text NULL,
+  PRIMARY KEY ("id"),
+  CONSTRAINT "fk_outbox_messages_event" FOREIGN KEY ("event_id") REFERENCES "public"."events" ("id") ON UPDATE NO ACTION ON DELETE NO ACTION
+);
+-- Create index "idx_outbox_messages_consumer_event" to table: "outbox_messages"
+CREATE UNIQUE INDEX "idx_outbox_messages_consumer_event" ON "public"."outbox_messages" ("consumer", "event_id");
+-- Create index "idx_outbox_messages_pending" to table: "outbox_messages"
+CREATE INDEX "idx_outbox_messages_pending" ON "public"."outbox_messages" ("consumer", "available_at") WHERE ((processed_at IS NULL) AND (failed_at IS NULL));
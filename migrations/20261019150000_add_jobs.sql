@@ -0,0 +1,24 @@
+-- Create "jobs" table
+CREATE TABLE "public"."jobs" (
+  "id" bigserial NOT NULL,
+  "created_at" timestamptz NOT NULL,
+  "updated_at" timestamptz NOT NULL,
+  "queue" text NOT NULL DEFAULT 'default',
+  "kind" text NOT NULL,
+  "args" jsonb NULL,
+  "status" text NOT NULL DEFAULT 'pending',
+  "run_at" timestamptz NOT NULL DEFAULT now(),
+  "attempts" bigint NOT NULL DEFAULT 0,
+  "max_attempts" bigint NOT NULL DEFAULT 5,
+  "locked_until" timestamptz NULL,
+  "last_error" text NULL,
+  "finished_at" timestamptz NULL,
+  "unique_key" text NULL,
+  PRIMARY KEY ("id")
+);
+-- Create index "idx_jobs_due" to table: "jobs"
+CREATE INDEX "idx_jobs_due" ON "public"."jobs" ("queue", "status", "run_at");
+-- Create index "idx_jobs_kind" to table: "jobs"
+CREATE INDEX "idx_jobs_kind" ON "public"."jobs" ("kind");
+-- Create index "idx_jobs_unique_key" to table: "jobs"
+CREATE UNIQUE INDEX "idx_jobs_unique_key" ON "public"."jobs" ("unique_key");
@@ -0,0 +1,67 @@
+-- Create "planned_workouts" table
+CREATE TABLE "public"."planned_workouts" (
+  "id" bigserial NOT NULL,
+  "created_at" timestamptz NULL,
+  "updated_at" timestamptz NULL,
+  "deleted_at" timestamptz NULL,
+  "user_id" bigint NOT NULL,
+  "name" text NOT NULL,
+  "description" text NULL,
+  "duration_minutes" bigint NULL,
+  "starts_at" timestamptz NOT NULL,
+  "timezone" text NOT NULL DEFAULT 'UTC',
+  "rrule" text NULL,
+  "reminder_channels" jsonb NULL,
+  "remind_minutes_before" bigint NOT NULL DEFAULT 60,
+  "auto_reschedule" boolean NOT NULL DEFAULT false,
+  PRIMARY KEY ("id"),
+  CONSTRAINT "fk_planned_workouts_user" FOREIGN KEY ("user_id") REFERENCES "public"."users" ("id") ON UPDATE NO ACTION ON DELETE NO ACTION
+);
+-- Create index "idx_planned_workouts_deleted_at" to table: "planned_workouts"
+CREATE INDEX "idx_planned_workouts_deleted_at" ON "public"."planned_workouts" ("deleted_at");
+-- Create index "idx_planned_workouts_user_id" to table: "planned_workouts"
+CREATE INDEX "idx_planned_workouts_user_id" ON "public"."planned_workouts" ("user_id");
+-- Create "planned_sessions" table
+CREATE TABLE "public"."planned_sessions" (
+  "id" bigserial NOT NULL,
+  "created_at" timestamptz NULL,
+  "updated_at" timestamptz NULL,
+  "deleted_at" timestamptz NULL,
+  "plan_id" bigint NOT NULL,
+  "user_id" bigint NOT NULL,
+  "occurs_at" timestamptz NOT NULL,
+  "scheduled_at" timestamptz NOT NULL,
+  "status" text NOT NULL DEFAULT 'scheduled',
+  "workout_id" bigint NULL,
+  "reminded_at" timestamptz NULL,
+  "missed_at" timestamptz NULL,
+  "reschedules" bigint NOT NULL DEFAULT 0,
+  PRIMARY KEY ("id"),
+  CONSTRAINT "fk_planned_sessions_plan" FOREIGN KEY ("plan_id") REFERENCES "public"."planned_workouts" ("id") ON UPDATE NO ACTION ON DELETE NO ACTION,
+  CONSTRAINT "fk_planned_sessions_workout" FOREIGN KEY ("workout_id") REFERENCES "public"."workouts" ("id") ON UPDATE NO ACTION ON DELETE NO ACTION
+);
+-- Create index "idx_planned_sessions_deleted_at" to table: "planned_sessions"
+CREATE INDEX "idx_planned_sessions_deleted_at" ON "public"."planned_sessions" ("deleted_at");
+-- Create index "idx_planned_sessions_occurrence" to table: "planned_sessions"
+CREATE UNIQUE INDEX "idx_planned_sessions_occurrence" ON "public"."planned_sessions" ("plan_id", "occurs_at");
+-- Create index "idx_planned_sessions_user_scheduled" to table: "planned_sessions"
+CREATE INDEX "idx_planned_sessions_user_scheduled" ON "public"."planned_sessions" ("user_id", "scheduled_at");
+-- Create "push_subscriptions" table
+CREATE TABLE "public"."push_subscriptions" (
+  "id" bigserial NOT NULL,
+  "created_at" timestamptz NULL,
+  "updated_at" timestamptz NULL,
+  "deleted_at" timestamptz NULL,
+  "user_id" bigint NOT NULL,
+  "endpoint" text NOT NULL,
+  "p256dh" text NOT NULL,
+  "auth" text NOT NULL,
+  PRIMARY KEY ("id"),
+  CONSTRAINT "fk_push_subscriptions_user" FOREIGN KEY ("user_id") REFERENCES "public"."users" ("id") ON UPDATE NO ACTION ON DELETE NO ACTION
+);
+-- Create index "idx_push_subscriptions_deleted_at" to table: "push_subscriptions"
+CREATE INDEX "idx_push_subscriptions_deleted_at" ON "public"."push_subscriptions" ("deleted_at");
+-- Create index "idx_push_subscriptions_endpoint" to table: "push_subscriptions"
+CREATE UNIQUE INDEX "idx_push_subscriptions_endpoint" ON "public"."push_subscriptions" ("endpoint");
+-- Create index "idx_push_subscriptions_user_id" to table: "push_subscriptions"
+CREATE INDEX "idx_push_subscriptions_user_id" ON "public"."push_subscriptions" ("user_id");
@@ -0,0 +1,13 @@
+-- Create "notification_preferences" table
+CREATE TABLE "public"."notification_preferences" (
+  "id" bigserial NOT NULL,
+  "created_at" timestamptz NOT NULL,
+  "updated_at" timestamptz NOT NULL,
+  "user_id" bigint NOT NULL,
+  "category" text NOT NULL,
+  "email" boolean NOT NULL,
+  PRIMARY KEY ("id"),
+  CONSTRAINT "fk_notification_preferences_user" FOREIGN KEY ("user_id") REFERENCES "public"."users" ("id") ON UPDATE NO ACTION ON DELETE NO ACTION
+);
+-- Create index "idx_notification_preferences_user_category" to table: "notification_preferences"
+CREATE UNIQUE INDEX "idx_notification_preferences_user_category" ON "public"."notification_preferences" ("user_id", "category");
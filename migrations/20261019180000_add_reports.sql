@@ -0,0 +1,15 @@
+-- Create "reports" table
+CREATE TABLE "public"."reports" (
+  "id" bigserial NOT NULL,
+  "created_at" timestamptz NOT NULL,
+  "updated_at" timestamptz NOT NULL,
+  "user_id" bigint NOT NULL,
+  "period" text NOT NULL,
+  "period_start" timestamptz NOT NULL,
+  "period_end" timestamptz NOT NULL,
+  "data" jsonb NOT NULL,
+  PRIMARY KEY ("id"),
+  CONSTRAINT "fk_reports_user" FOREIGN KEY ("user_id") REFERENCES "public"."users" ("id") ON UPDATE NO ACTION ON DELETE NO ACTION
+);
+-- Create index "idx_reports_user_period" to table: "reports"
+CREATE UNIQUE INDEX "idx_reports_user_period" ON "public"."reports" ("user_id", "period", "period_start");
@@ -0,0 +1,27 @@
+-- Create "audit_logs" table
+CREATE TABLE "public"."audit_logs" (
+  "id" bigserial NOT NULL,
+  "created_at" timestamptz NOT NULL,
+  "actor_user_id" bigint NULL,
+  "subject" text NOT NULL DEFAULT '',
+  "token_type" text NOT NULL DEFAULT '',
+  "client_id" text NOT NULL DEFAULT '',
+  "action" text NOT NULL,
+  "resource_type" text NOT NULL,
+  "resource_id" bigint NULL,
+  "changes" jsonb NOT NULL,
+  "ip" text NOT NULL DEFAULT '',
+  "user_agent" text NOT NULL DEFAULT '',
+  "request_id" text NOT NULL DEFAULT '',
+  PRIMARY KEY ("id")
+);
+-- Create index "idx_audit_logs_actor_user_id" to table: "audit_logs"
+CREATE INDEX "idx_audit_logs_actor_user_id" ON "public"."audit_logs" ("actor_user_id");
+-- Create index "idx_audit_logs_created_at" to table: "audit_logs"
+CREATE INDEX "idx_audit_logs_created_at" ON "public"."audit_logs" ("created_at");
+-- Create index "idx_audit_logs_request_id" to table: "audit_logs"
+CREATE INDEX "idx_audit_logs_request_id" ON "public"."audit_logs" ("request_id");
+-- Create index "idx_audit_logs_resource" to table: "audit_logs"
+CREATE INDEX "idx_audit_logs_resource" ON "public"."audit_logs" ("resource_type", "resource_id");
+-- Create index "idx_audit_logs_subject" to table: "audit_logs"
+CREATE INDEX "idx_audit_logs_subject" ON "public"."audit_logs" ("subject");
@@ -0,0 +1,29 @@
+-- Create "workout_revisions" table
+CREATE TABLE "public"."workout_revisions" (
+  "id" bigserial NOT NULL,
+  "created_at" timestamptz NOT NULL,
+  "workout_id" bigint NOT NULL,
+  "revision" bigint NOT NULL,
+  "author_id" bigint NULL,
+  "restored_from" bigint NULL,
+  "snapshot" jsonb NOT NULL,
+  PRIMARY KEY ("id"),
+  CONSTRAINT "fk_workout_revisions_author" FOREIGN KEY ("author_id") REFERENCES "public"."users" ("id") ON UPDATE NO ACTION ON DELETE NO ACTION,
+  CONSTRAINT "fk_workout_revisions_workout" FOREIGN KEY ("workout_id") REFERENCES "public"."workouts" ("id") ON UPDATE NO ACTION ON DELETE NO ACTION
+);
+-- Create index "idx_workout_revisions_workout_revision" to table: "workout_revisions"
+CREATE UNIQUE INDEX "idx_workout_revisions_workout_revision" ON "public"."workout_revisions" ("workout_id", "revision");
+-- Backfill revision 1 of existing workouts from their current state
+INSERT INTO "public"."workout_revisions" ("created_at", "workout_id", "revision", "snapshot")
+SELECT "updated_at", "id", 1, jsonb_build_object(
+  'id', "id",
+  'user_id', "user_id",
+  'name', "name",
+  'description', "description",
+  'duration_minutes', "duration_minutes",
+  'distance_meters', "distance_meters",
+  'performed_at', "performed_at",
+  'visibility', "visibility",
+  'created_at', "created_at",
+  'updated_at', "updated_at"
+) FROM "public"."workouts";
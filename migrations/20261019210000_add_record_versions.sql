@@ -0,0 +1,30 @@
+-- Modify "users" table
+ALTER TABLE "public"."users" ADD COLUMN "version" bigint NOT NULL DEFAULT 1;
+-- Modify "workouts" table
+ALTER TABLE "public"."workouts" ADD COLUMN "version" bigint NOT NULL DEFAULT 1;
+-- Modify "organizations" table
+ALTER TABLE "public"."organizations" ADD COLUMN "version" bigint NOT NULL DEFAULT 1;
+-- Modify "memberships" table
+ALTER TABLE "public"."memberships" ADD COLUMN "version" bigint NOT NULL DEFAULT 1;
+-- Modify "workout_templates" table
+ALTER TABLE "public"."workout_templates" ADD COLUMN "version" bigint NOT NULL DEFAULT 1;
+-- Modify "follows" table
+ALTER TABLE "public"."follows" ADD COLUMN "version" bigint NOT NULL DEFAULT 1;
+-- Modify "workout_likes" table
+ALTER TABLE "public"."workout_likes" ADD COLUMN "version" bigint NOT NULL DEFAULT 1;
+-- Modify "workout_comments" table
+ALTER TABLE "public"."workout_comments" ADD COLUMN "version" bigint NOT NULL DEFAULT 1;
+-- Modify "challenges" table
+ALTER TABLE "public"."challenges" ADD COLUMN "version" bigint NOT NULL DEFAULT 1;
+-- Modify "challenge_participants" table
+ALTER TABLE "public"."challenge_participants" ADD COLUMN "version" bigint NOT NULL DEFAULT 1;
+-- Modify "webhooks" table
+ALTER TABLE "public"."webhooks" ADD COLUMN "version" bigint NOT NULL DEFAULT 1;
+-- Modify "webhook_deliveries" table
+ALTER TABLE "public"."webhook_deliveries" ADD COLUMN "version" bigint NOT NULL DEFAULT 1;
+-- Modify "planned_workouts" table
+ALTER TABLE "public"."planned_workouts" ADD COLUMN "version" bigint NOT NULL DEFAULT 1;
+-- Modify "planned_sessions" table
+ALTER TABLE "public"."planned_sessions" ADD COLUMN "version" bigint NOT NULL DEFAULT 1;
+-- Modify "push_subscriptions" table
+ALTER TABLE "public"."push_subscriptions" ADD COLUMN "version" bigint NOT NULL DEFAULT 1;
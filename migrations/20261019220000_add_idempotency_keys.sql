@@ -0,0 +1,17 @@
+-- Create "idempotency_keys" table
+CREATE TABLE "public"."idempotency_keys" (
+  "id" bigserial NOT NULL,
+  "created_at" timestamptz NOT NULL,
+  "expires_at" timestamptz NOT NULL,
+  "scope" text NOT NULL,
+  "key" text NOT NULL,
+  "fingerprint" text NOT NULL,
+  "status" bigint NOT NULL DEFAULT 0,
+  "header" jsonb NULL,
+  "body" bytea NULL,
+  PRIMARY KEY ("id")
+);
+-- Create index "idx_idempotency_keys_expires_at" to table: "idempotency_keys"
+CREATE INDEX "idx_idempotency_keys_expires_at" ON "public"."idempotency_keys" ("expires_at");
+-- Create index "idx_idempotency_keys_scope_key" to table: "idempotency_keys"
+CREATE UNIQUE INDEX "idx_idempotency_keys_scope_key" ON "public"."idempotency_keys" ("scope", "key");
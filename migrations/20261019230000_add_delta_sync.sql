@@ -0,0 +1,6 @@
+-- Modify "workouts" table
+ALTER TABLE "public"."workouts" ADD COLUMN "client_id" uuid NULL;
+-- Create index "idx_workouts_user_client_id" to table: "workouts"
+CREATE UNIQUE INDEX "idx_workouts_user_client_id" ON "public"."workouts" ("user_id", "client_id");
+-- Create index "idx_events_user_resource" to table: "events"
+CREATE INDEX "idx_events_user_resource" ON "public"."events" ("user_id", "resource_type", "id");
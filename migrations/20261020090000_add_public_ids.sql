@@ -0,0 +1,172 @@
+-- Create "uuid_generate_v7" function, used to backfill public IDs ordered by creation time
+CREATE FUNCTION "public"."uuid_generate_v7" ("ts" timestamptz) RETURNS uuid LANGUAGE sql VOLATILE AS $$
+SELECT encode(set_bit(set_bit(overlay(uuid_send(gen_random_uuid()) PLACING substring(int8send((extract(epoch FROM ts) * 1000)::bigint) FROM 3) FROM 1 FOR 6), 52, 1), 53, 1), 'hex')::uuid
+$$;
+-- Modify "users" table
+ALTER TABLE "public"."users" ADD COLUMN "public_id" uuid NULL;
+UPDATE "public"."users" SET "public_id" = "public"."uuid_generate_v7"(COALESCE("created_at", now()));
+ALTER TABLE "public"."users" ALTER COLUMN "public_id" SET NOT NULL;
+-- Create index "idx_users_public_id" to table: "users"
+CREATE UNIQUE INDEX "idx_users_public_id" ON "public"."users" ("public_id");
+-- Modify "workouts" table
+ALTER TABLE "public"."workouts" ADD COLUMN "public_id" uuid NULL;
+-- Keep the UUIDs offline clients gave the workouts they created
+UPDATE "public"."workouts" SET "public_id" = "client_id" WHERE "client_id" IS NOT NULL;
+UPDATE "public"."workouts" SET "public_id" = "public"."uuid_generate_v7"(COALESCE("created_at", now())) WHERE "public_id" IS NULL;
+ALTER TABLE "public"."workouts" ALTER COLUMN "public_id" SET NOT NULL;
+-- Create index "idx_workouts_public_id" to table: "workouts"
+CREATE UNIQUE INDEX "idx_workouts_public_id" ON "public"."workouts" ("public_id");
+-- Drop index "idx_workouts_user_client_id" from table: "workouts"
+DROP INDEX "public"."idx_workouts_user_client_id";
+ALTER TABLE "public"."workouts" DROP COLUMN "client_id";
+-- Modify "organizations" table
+ALTER TABLE "public"."organizations" ADD COLUMN "public_id" uuid NULL;
+UPDATE "public"."organizations" SET "public_id" = "public"."uuid_generate_v7"(COALESCE("created_at", now()));
+ALTER TABLE "public"."organizations" ALTER COLUMN "public_id" SET NOT NULL;
+-- Create index "idx_organizations_public_id" to table: "organizations"
+CREATE UNIQUE INDEX "idx_organizations_public_id" ON "public"."organizations" ("public_id");
+-- Modify "memberships" table
+ALTER TABLE "public"."memberships" ADD COLUMN "public_id" uuid NULL;
+UPDATE "public"."memberships" SET "public_id" = "public"."uuid_generate_v7"(COALESCE("created_at", now()));
+ALTER TABLE "public"."memberships" ALTER COLUMN "public_id" SET NOT NULL;
+-- Create index "idx_memberships_public_id" to table: "memberships"
+CREATE UNIQUE INDEX "idx_memberships_public_id" ON "public"."memberships" ("public_id");
+-- Modify "workout_templates" table
+ALTER TABLE "public"."workout_templates" ADD COLUMN "public_id" uuid NULL;
+UPDATE "public"."workout_templates" SET "public_id" = "public"."uuid_generate_v7"(COALESCE("created_at", now()));
+ALTER TABLE "public"."workout_templates" ALTER COLUMN "public_id" SET NOT NULL;
+-- Create index "idx_workout_templates_public_id" to table: "workout_templates"
+CREATE UNIQUE INDEX "idx_workout_templates_public_id" ON "public"."workout_templates" ("public_id");
+-- Modify "follows" table
+ALTER TABLE "public"."follows" ADD COLUMN "public_id" uuid NULL;
+UPDATE "public"."follows" SET "public_id" = "public"."uuid_generate_v7"(COALESCE("created_at", now()));
+ALTER TABLE "public"."follows" ALTER COLUMN "public_id" SET NOT NULL;
+-- Create index "idx_follows_public_id" to table: "follows"
+CREATE UNIQUE INDEX "idx_follows_public_id" ON "public"."follows" ("public_id");
+-- Modify "workout_likes" table
+ALTER TABLE "public"."workout_likes" ADD COLUMN "public_id" uuid NULL;
+UPDATE "public"."workout_likes" SET "public_id" = "public"."uuid_generate_v7"(COALESCE("created_at", now()));
+ALTER TABLE "public"."workout_likes" ALTER COLUMN "public_id" SET NOT NULL;
+-- Create index "idx_workout_likes_public_id" to table: "workout_likes"
+CREATE UNIQUE INDEX "idx_workout_likes_public_id" ON "public"."workout_likes" ("public_id");
+-- Modify "workout_comments" table
+ALTER TABLE "public"."workout_comments" ADD COLUMN "public_id" uuid NULL;
+UPDATE "public"."workout_comments" SET "public_id" = "public"."uuid_generate_v7"(COALESCE("created_at", now()));
+ALTER TABLE "public"."workout_comments" ALTER COLUMN "public_id" SET NOT NULL;
+-- Create index "idx_workout_comments_public_id" to table: "workout_comments"
+CREATE UNIQUE INDEX "idx_workout_comments_public_id" ON "public"."workout_comments" ("public_id");
+-- Modify "challenges" table
+ALTER TABLE "public"."challenges" ADD COLUMN "public_id" uuid NULL;
+UPDATE "public"."challenges" SET "public_id" = "public"."uuid_generate_v7"(COALESCE("created_at", now()));
+ALTER TABLE "public"."challenges" ALTER COLUMN "public_id" SET NOT NULL;
+-- Create index "idx_challenges_public_id" to table: "challenges"
+CREATE UNIQUE INDEX "idx_challenges_public_id" ON "public"."challenges" ("public_id");
+-- Modify "challenge_participants" table
+ALTER TABLE "public"."challenge_participants" ADD COLUMN "public_id" uuid NULL;
+UPDATE "public"."challenge_participants" SET "public_id" = "public"."uuid_generate_v7"(COALESCE("created_at", now()));
+ALTER TABLE "public"."challenge_participants" ALTER COLUMN "public_id" SET NOT NULL;
+-- Create index "idx_challenge_participants_public_id" to table: "challenge_participants"
+CREATE UNIQUE INDEX "idx_challenge_participants_public_id" ON "public"."challenge_participants" ("public_id");
+-- Modify "webhooks" table
+ALTER TABLE "public"."webhooks" ADD COLUMN "public_id" uuid NULL;
+UPDATE "public"."webhooks" SET "public_id" = "public"."uuid_generate_v7"(COALESCE("created_at", now()));
+ALTER TABLE "public"."webhooks" ALTER COLUMN "public_id" SET NOT NULL;
+-- Create index "idx_webhooks_public_id" to table: "webhooks"
+CREATE UNIQUE INDEX "idx_webhooks_public_id" ON "public"."webhooks" ("public_id");
+-- Modify "webhook_deliveries" table
+ALTER TABLE "public"."webhook_deliveries" ADD COLUMN "public_id" uuid NULL;
+UPDATE "public"."webhook_deliveries" SET "public_id" = "public"."uuid_generate_v7"(COALESCE("created_at", now()));
+ALTER TABLE "public"."webhook_deliveries" ALTER COLUMN "public_id" SET NOT NULL;
+-- Create index "idx_webhook_deliveries_public_id" to table: "webhook_deliveries"
+CREATE UNIQUE INDEX "idx_webhook_deliveries_public_id" ON "public"."webhook_deliveries" ("public_id");
+-- Modify "webhook_attempts" table
+ALTER TABLE "public"."webhook_attempts" ADD COLUMN "public_id" uuid NULL;
+UPDATE "public"."webhook_attempts" SET "public_id" = "public"."uuid_generate_v7"(COALESCE("created_at", now()));
+ALTER TABLE "public"."webhook_attempts" ALTER COLUMN "public_id" SET NOT NULL;
+-- Create index "idx_webhook_attempts_public_id" to table: "webhook_attempts"
+CREATE UNIQUE INDEX "idx_webhook_attempts_public_id" ON "public"."webhook_attempts" ("public_id");
+-- Modify "jobs" table
+ALTER TABLE "public"."jobs" ADD COLUMN "public_id" uuid NULL;
+UPDATE "public"."jobs" SET "public_id" = "public"."uuid_generate_v7"(COALESCE("created_at", now()));
+ALTER TABLE "public"."jobs" ALTER COLUMN "public_id" SET NOT NULL;
+-- Create index "idx_jobs_public_id" to table: "jobs"
+CREATE UNIQUE INDEX "idx_jobs_public_id" ON "public"."jobs" ("public_id");
+-- Modify "planned_workouts" table
+ALTER TABLE "public"."planned_workouts" ADD COLUMN "public_id" uuid NULL;
+UPDATE "public"."planned_workouts" SET "public_id" = "public"."uuid_generate_v7"(COALESCE("created_at", now()));
+ALTER TABLE "public"."planned_workouts" ALTER COLUMN "public_id" SET NOT NULL;
+-- Create index "idx_planned_workouts_public_id" to table: "planned_workouts"
+CREATE UNIQUE INDEX "idx_planned_workouts_public_id" ON "public"."planned_workouts" ("public_id");
+-- Modify "planned_sessions" table
+ALTER TABLE "public"."planned_sessions" ADD COLUMN "public_id" uuid NULL;
+UPDATE "public"."planned_sessions" SET "public_id" = "public"."uuid_generate_v7"(COALESCE("created_at", now()));
+ALTER TABLE "public"."planned_sessions" ALTER COLUMN "public_id" SET NOT NULL;
+-- Create index "idx_planned_sessions_public_id" to table: "planned_sessions"
+CREATE UNIQUE INDEX "idx_planned_sessions_public_id" ON "public"."planned_sessions" ("public_id");
+-- Modify "push_subscriptions" table
+ALTER TABLE "public"."push_subscriptions" ADD COLUMN "public_id" uuid NULL;
+UPDATE "public"."push_subscriptions" SET "public_id" = "public"."uuid_generate_v7"(COALESCE("created_at", now()));
+ALTER TABLE "public"."push_subscriptions" ALTER COLUMN "public_id" SET NOT NULL;
+-- Create index "idx_push_subscriptions_public_id" to table: "push_subscriptions"
+CREATE UNIQUE INDEX "idx_push_subscriptions_public_id" ON "public"."push_subscriptions" ("public_id");
+-- Modify "reports" table
+ALTER TABLE "public"."reports" ADD COLUMN "public_id" uuid NULL;
+UPDATE "public"."reports" SET "public_id" = "public"."uuid_generate_v7"(COALESCE("created_at", now()));
+ALTER TABLE "public"."reports" ALTER COLUMN "public_id" SET NOT NULL;
+-- Create index "idx_reports_public_id" to table: "reports"
+CREATE UNIQUE INDEX "idx_reports_public_id" ON "public"."reports" ("public_id");
+-- Modify "audit_logs" table
+ALTER TABLE "public"."audit_logs" ADD COLUMN "public_id" uuid NULL, ADD COLUMN "resource_public_id" uuid NULL;
+UPDATE "public"."audit_logs" SET "public_id" = "public"."uuid_generate_v7"(COALESCE("created_at", now()));
+ALTER TABLE "public"."audit_logs" ALTER COLUMN "public_id" SET NOT NULL;
+-- Create index "idx_audit_logs_public_id" to table: "audit_logs"
+CREATE UNIQUE INDEX "idx_audit_logs_public_id" ON "public"."audit_logs" ("public_id");
+-- Backfill the public IDs of audited resources
+UPDATE "public"."audit_logs" SET "resource_public_id" = w."public_id" FROM "public"."workouts" w WHERE "audit_logs"."resource_type" = 'workout' AND w."id" = "audit_logs"."resource_id";
+UPDATE "public"."audit_logs" SET "resource_public_id" = u."public_id" FROM "public"."users" u WHERE "audit_logs"."resource_type" = 'user' AND u."id" = "audit_logs"."resource_id";
+-- Create index "idx_audit_logs_resource_public_id" to table: "audit_logs"
+CREATE INDEX "idx_audit_logs_resource_public_id" ON "public"."audit_logs" ("resource_type", "resource_public_id");
+-- Modify "events" table
+ALTER TABLE "public"."events" ADD COLUMN "resource_public_id" uuid NULL;
+UPDATE "public"."events" SET "resource_public_id" = w."public_id" FROM "public"."workouts" w WHERE "events"."resource_type" = 'workout' AND w."id" = "events"."resource_id";
+UPDATE "public"."events" SET "resource_public_id" = u."public_id" FROM "public"."users" u WHERE "events"."resource_type" = 'user' AND u."id" = "events"."resource_id";
+UPDATE "public"."events" SET "resource_public_id" = s."public_id" FROM "public"."planned_sessions" s WHERE "events"."resource_type" = 'planned_session' AND s."id" = "events"."resource_id";
+-- Rewrite logged workout and user payloads to the public IDs the API now returns; purged resources get a null ID
+UPDATE "public"."events" SET "payload" = ("payload" - 'client_id') || jsonb_build_object(
+  'id', "resource_public_id",
+  'user_id', (SELECT u."public_id" FROM "public"."users" u WHERE u."id" = "events"."user_id")
+) WHERE "resource_type" = 'workout' AND "payload" IS NOT NULL;
+UPDATE "public"."events" SET "payload" = "payload" || jsonb_build_object('id', "resource_public_id")
+WHERE "resource_type" = 'user' AND "payload" IS NOT NULL;
+UPDATE "public"."events" SET "payload" = "payload" || jsonb_build_object(
+  'session_id', "resource_public_id",
+  'plan_id', (SELECT p."public_id" FROM "public"."planned_workouts" p WHERE p."id" = ("events"."payload"->>'plan_id')::bigint)
+) WHERE "resource_type" = 'planned_session' AND "payload" IS NOT NULL;
+-- Rewrite revision snapshots likewise
+UPDATE "public"."workout_revisions" SET "snapshot" = ("snapshot" - 'client_id') || jsonb_build_object(
+  'id', w."public_id",
+  'user_id', (SELECT u."public_id" FROM "public"."users" u WHERE u."id" = w."user_id")
+) FROM "public"."workouts" w WHERE w."id" = "workout_revisions"."workout_id";
+-- Rewrite the workout IDs in stored reports
+UPDATE "public"."reports" SET "data" = jsonb_set(jsonb_set("data",
+  '{workouts}', COALESCE((
+    SELECT jsonb_agg(jsonb_set(e.value, '{id}', COALESCE(to_jsonb(w."public_id"), 'null')) ORDER BY e.ordinality)
+    FROM jsonb_array_elements("data"->'workouts') WITH ORDINALITY e
+    LEFT JOIN "public"."workouts" w ON w."id" = (e.value->>'id')::bigint
+  ), '[]')),
+  '{personal_records}', COALESCE((
+    SELECT jsonb_agg(jsonb_set(e.value, '{workout_id}', COALESCE(to_jsonb(w."public_id"), 'null')) ORDER BY e.ordinality)
+    FROM jsonb_array_elements("data"->'personal_records') WITH ORDINALITY e
+    LEFT JOIN "public"."workouts" w ON w."id" = (e.value->>'workout_id')::bigint
+  ), '[]'));
+-- Rewrite the IDs in queued emails, which links in them carry
+UPDATE "public"."jobs" SET "args" = jsonb_set("args", '{data,workout_id}', COALESCE(to_jsonb(w."public_id"), 'null'))
+FROM "public"."workouts" w
+WHERE "jobs"."kind" = 'email.send' AND "jobs"."status" <> 'succeeded' AND "jobs"."args"->>'template' = 'pr_achieved'
+  AND w."id" = ("jobs"."args"#>>'{data,workout_id}')::bigint;
+UPDATE "public"."jobs" SET "args" = jsonb_set("args", '{data,organization_id}', COALESCE(to_jsonb(o."public_id"), 'null'))
+FROM "public"."organizations" o
+WHERE "jobs"."kind" = 'email.send' AND "jobs"."status" <> 'succeeded' AND "jobs"."args"->>'template' = 'coach_invite'
+  AND o."id" = ("jobs"."args"#>>'{data,organization_id}')::bigint;
+-- Drop "uuid_generate_v7" function
+DROP FUNCTION "public"."uuid_generate_v7"(timestamptz);
@@ -0,0 +1,4 @@
+-- Modify "workouts" table
+ALTER TABLE "public"."workouts" ADD COLUMN "search_vector" tsvector NULL GENERATED ALWAYS AS (setweight(to_tsvector('english'::regconfig, COALESCE(name, '')) || to_tsvector('simple'::regconfig, COALESCE(name, '')), 'A') || setweight(to_tsvector('english'::regconfig, COALESCE(description, '')) || to_tsvector('simple'::regconfig, COALESCE(description, '')), 'B')) STORED;
+-- Create index "idx_workouts_search" to table: "workouts"
+CREATE INDEX "idx_workouts_search" ON "public"."workouts" USING gin ("search_vector");
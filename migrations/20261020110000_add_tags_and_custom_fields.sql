@@ -0,0 +1,29 @@
+-- Modify "workouts" table
+ALTER TABLE "public"."workouts" ADD COLUMN "tags" jsonb NOT NULL DEFAULT '[]', ADD COLUMN "custom_fields" jsonb NOT NULL DEFAULT '{}';
+-- Create index "idx_workouts_tags" to table: "workouts"
+CREATE INDEX "idx_workouts_tags" ON "public"."workouts" USING gin ("tags");
+-- Create index "idx_workouts_custom_fields" to table: "workouts"
+CREATE INDEX "idx_workouts_custom_fields" ON "public"."workouts" USING gin ("custom_fields");
+-- Create "custom_fields" table
+CREATE TABLE "public"."custom_fields" (
+  "id" bigserial NOT NULL,
+  "public_id" uuid NOT NULL,
+  "created_at" timestamptz NULL,
+  "updated_at" timestamptz NULL,
+  "deleted_at" timestamptz NULL,
+  "version" bigint NOT NULL DEFAULT 1,
+  "user_id" bigint NOT NULL,
+  "key" text NOT NULL,
+  "name" text NOT NULL,
+  "type" text NOT NULL,
+  "options" jsonb NULL,
+  "unit" text NULL,
+  PRIMARY KEY ("id"),
+  CONSTRAINT "fk_custom_fields_user" FOREIGN KEY ("user_id") REFERENCES "public"."users" ("id") ON UPDATE NO ACTION ON DELETE NO ACTION
+);
+-- Create index "idx_custom_fields_public_id" to table: "custom_fields"
+CREATE UNIQUE INDEX "idx_custom_fields_public_id" ON "public"."custom_fields" ("public_id");
+-- Create index "idx_custom_fields_deleted_at" to table: "custom_fields"
+CREATE INDEX "idx_custom_fields_deleted_at" ON "public"."custom_fields" ("deleted_at");
+-- Create index "idx_custom_fields_user_key" to table: "custom_fields"
+CREATE UNIQUE INDEX "idx_custom_fields_user_key" ON "public"."custom_fields" ("user_id", "key") WHERE (deleted_at IS NULL);
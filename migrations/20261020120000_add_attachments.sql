@@ -0,0 +1,30 @@
+-- Create "attachments" table
+CREATE TABLE "public"."attachments" (
+  "id" bigserial NOT NULL,
+  "public_id" uuid NOT NULL,
+  "created_at" timestamptz NULL,
+  "updated_at" timestamptz NULL,
+  "deleted_at" timestamptz NULL,
+  "version" bigint NOT NULL DEFAULT 1,
+  "workout_id" bigint NOT NULL,
+  "user_id" bigint NOT NULL,
+  "kind" text NOT NULL,
+  "filename" text NOT NULL,
+  "content_type" text NOT NULL,
+  "size" bigint NOT NULL,
+  "storage_key" text NOT NULL,
+  "thumbnail_key" text NULL,
+  "thumbnail_size" bigint NULL,
+  "width" bigint NULL,
+  "height" bigint NULL,
+  PRIMARY KEY ("id"),
+  CONSTRAINT "fk_attachments_user" FOREIGN KEY ("user_id") REFERENCES "public"."users" ("id") ON UPDATE NO ACTION ON DELETE NO ACTION
+);
+-- Create index "idx_attachments_public_id" to table: "attachments"
+CREATE UNIQUE INDEX "idx_attachments_public_id" ON "public"."attachments" ("public_id");
+-- Create index "idx_attachments_deleted_at" to table: "attachments"
+CREATE INDEX "idx_attachments_deleted_at" ON "public"."attachments" ("deleted_at");
+-- Create index "idx_attachments_workout_id" to table: "attachments"
+CREATE INDEX "idx_attachments_workout_id" ON "public"."attachments" ("workout_id");
+-- Create index "idx_attachments_user_id" to table: "attachments"
+CREATE INDEX "idx_attachments_user_id" ON "public"."attachments" ("user_id");
@@ -0,0 +1,4 @@
+-- Modify "workouts" table
+ALTER TABLE "public"."workouts" ADD COLUMN "exercises" jsonb NOT NULL DEFAULT '[]', ADD COLUMN "exercise_search_vector" tsvector NULL GENERATED ALWAYS AS (setweight(to_tsvector('english'::regconfig, jsonb_path_query_array(exercises, '$[*].name')) || to_tsvector('simple'::regconfig, jsonb_path_query_array(exercises, '$[*].name')), 'A') || setweight(to_tsvector('english'::regconfig, jsonb_path_query_array(exercises, '$[*].notes')) || to_tsvector('simple'::regconfig, jsonb_path_query_array(exercises, '$[*].notes')) || to_tsvector('english'::regconfig, jsonb_path_query_array(exercises, '$[*].sets[*].notes')) || to_tsvector('simple'::regconfig, jsonb_path_query_array(exercises, '$[*].sets[*].notes')), 'B')) STORED;
+-- Create index "idx_workouts_exercise_search" to table: "workouts"
+CREATE INDEX "idx_workouts_exercise_search" ON "public"."workouts" USING gin ("exercise_search_vector");
@@ -0,0 +1,2 @@
+-- Modify "workouts" table
+ALTER TABLE "public"."workouts" ADD COLUMN "groups" jsonb NOT NULL DEFAULT '[]';
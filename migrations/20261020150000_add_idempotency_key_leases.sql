@@ -0,0 +1,4 @@
+-- Modify "idempotency_keys" table
+ALTER TABLE "public"."idempotency_keys" ADD COLUMN "locked_until" timestamptz NULL;
+UPDATE "public"."idempotency_keys" SET "locked_until" = "created_at" + interval '1 minute';
+ALTER TABLE "public"."idempotency_keys" ALTER COLUMN "locked_until" SET NOT NULL;